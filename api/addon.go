@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ListAddons lists the add-ons of the catalog with their installed versions on the cluster
+func ListAddons(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListAddons"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	addons, err := cluster.ListAddons(commonCluster)
+	if err != nil {
+		log.Errorf("Error during listing add-ons: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing add-ons",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, addons)
+}
+
+// InstallAddon installs the add-on of the catalog with the add-ons it depends on
+func InstallAddon(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "InstallAddon"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !getCatalogAddon(c) {
+		return
+	}
+	addons, err := cluster.ResolveAddon(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	for _, addon := range addons {
+		attributes := clusterPolicyAttributes(commonCluster)
+		attributes[auth.PolicyAttributeChart] = addon.Chart
+		if !authorizePolicies(c, auth.PolicyActionDeploymentCreate, attributes) {
+			return
+		}
+	}
+	installed, err := cluster.InstallAddon(commonCluster, c.Param("name"))
+	if err != nil {
+		log.Errorf("Error during installing add-on: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during installing add-on",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, installed)
+}
+
+// UpgradeAddon upgrades the installed add-on to the version of the catalog
+func UpgradeAddon(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "UpgradeAddon"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !getCatalogAddon(c) {
+		return
+	}
+	addon, _ := cluster.GetCatalogAddon(c.Param("name"))
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = addon.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, attributes) {
+		return
+	}
+	installed, err := cluster.UpgradeAddon(commonCluster, addon.Name)
+	if err != nil {
+		code, message := http.StatusBadRequest, "Error during upgrading add-on"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "The add-on isn't installed"
+		}
+		log.Errorf("%s: %s", message, err.Error())
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, installed)
+}
+
+// DeleteAddon removes the installed add-on from the cluster
+func DeleteAddon(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteAddon"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !getCatalogAddon(c) {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentDelete, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteAddon(commonCluster, c.Param("name")); err != nil {
+		code, message := http.StatusBadRequest, "Error during deleting add-on"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "The add-on isn't installed"
+		}
+		log.Errorf("%s: %s", message, err.Error())
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getCatalogAddon checks that the add-on of the request is in the catalog, it aborts the request if it isn't
+func getCatalogAddon(c *gin.Context) bool {
+	if _, ok := cluster.GetCatalogAddon(c.Param("name")); !ok {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Add-on not found",
+			Error:   "unknown add-on: " + c.Param("name"),
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// alertRuleRequest describes an alerting rule of the organization, the rule is deployed to every cluster of the
+// organization without a cluster ID
+type alertRuleRequest struct {
+	ClusterID   uint              `json:"clusterId"`
+	Name        string            `json:"name" binding:"required"`
+	Expr        string            `json:"expr" binding:"required"`
+	For         string            `json:"for"`
+	Severity    string            `json:"severity" binding:"required"`
+	Summary     string            `json:"summary"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+}
+
+// alertRuleResponse describes an alerting rule with its labels
+type alertRuleResponse struct {
+	*model.AlertRuleModel
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// alertReceiverRequest describes a receiver of the organization, the settings are the settings of its type
+type alertReceiverRequest struct {
+	ClusterID uint              `json:"clusterId"`
+	Name      string            `json:"name" binding:"required"`
+	Type      string            `json:"type" binding:"required"`
+	Settings  map[string]string `json:"settings" binding:"required"`
+	Matchers  map[string]string `json:"matchers"`
+}
+
+// alertReceiverResponse describes a receiver with its settings and the labels of its alerts
+type alertReceiverResponse struct {
+	*model.AlertReceiverModel
+	Settings map[string]string `json:"settings"`
+	Matchers map[string]string `json:"matchers,omitempty"`
+}
+
+// ListAlertRules lists the alerting rules of the organization, filtered by the clusterId query parameter
+func ListAlertRules(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListAlertRules"})
+	clusterID, _ := strconv.ParseUint(c.Query("clusterId"), 10, 32)
+	rules, err := model.ListAlertRules(auth.GetCurrentOrganization(c.Request).ID, uint(clusterID))
+	if err != nil {
+		log.Errorf("Error listing alerting rules: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing alerting rules",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := []alertRuleResponse{}
+	for i := range rules {
+		response = append(response, alertRuleResponse{&rules[i], rules[i].GetLabels()})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetAlertRule sends back an alerting rule of the organization
+func GetAlertRule(c *gin.Context) {
+	rule, ok := getAlertRuleFromRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, alertRuleResponse{rule, rule.GetLabels()})
+}
+
+// CreateAlertRule saves an alerting rule and deploys it to the clusters of the rule
+func CreateAlertRule(c *gin.Context) {
+	rule := &model.AlertRuleModel{OrganizationID: auth.GetCurrentOrganization(c.Request).ID}
+	if !bindAlertRule(c, rule) {
+		return
+	}
+	saveAlerting(c, "alerting rule", rule.Save, rule.OrganizationID, rule.ClusterModelID, 0, http.StatusCreated, alertRuleResponse{rule, rule.GetLabels()})
+}
+
+// UpdateAlertRule replaces an alerting rule and deploys it to the clusters of the rule
+func UpdateAlertRule(c *gin.Context) {
+	rule, ok := getAlertRuleFromRequest(c)
+	if !ok {
+		return
+	}
+	previousCluster := rule.ClusterModelID
+	if !authorizeAlerting(c, rule.OrganizationID, previousCluster) || !bindAlertRule(c, rule) {
+		return
+	}
+	saveAlerting(c, "alerting rule", rule.Save, rule.OrganizationID, rule.ClusterModelID, previousCluster, http.StatusOK, alertRuleResponse{rule, rule.GetLabels()})
+}
+
+// DeleteAlertRule deletes an alerting rule and removes it from the clusters of the rule
+func DeleteAlertRule(c *gin.Context) {
+	rule, ok := getAlertRuleFromRequest(c)
+	if !ok || !authorizeAlerting(c, rule.OrganizationID, rule.ClusterModelID) {
+		return
+	}
+	saveAlerting(c, "alerting rule", rule.Delete, rule.OrganizationID, rule.ClusterModelID, 0, http.StatusOK, alertRuleResponse{rule, rule.GetLabels()})
+}
+
+// ListAlertReceivers lists the receivers of the organization, filtered by the clusterId query parameter
+func ListAlertReceivers(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListAlertReceivers"})
+	clusterID, _ := strconv.ParseUint(c.Query("clusterId"), 10, 32)
+	receivers, err := model.ListAlertReceivers(auth.GetCurrentOrganization(c.Request).ID, uint(clusterID))
+	if err != nil {
+		log.Errorf("Error listing receivers: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing receivers",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := []alertReceiverResponse{}
+	for i := range receivers {
+		response = append(response, newAlertReceiverResponse(&receivers[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetAlertReceiver sends back a receiver of the organization
+func GetAlertReceiver(c *gin.Context) {
+	receiver, ok := getAlertReceiverFromRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newAlertReceiverResponse(receiver))
+}
+
+// CreateAlertReceiver saves a receiver and deploys it to the Alertmanagers of the clusters of the receiver
+func CreateAlertReceiver(c *gin.Context) {
+	receiver := &model.AlertReceiverModel{OrganizationID: auth.GetCurrentOrganization(c.Request).ID}
+	if !bindAlertReceiver(c, receiver) {
+		return
+	}
+	saveAlerting(c, "receiver", receiver.Save, receiver.OrganizationID, receiver.ClusterModelID, 0, http.StatusCreated, newAlertReceiverResponse(receiver))
+}
+
+// UpdateAlertReceiver replaces a receiver and deploys it to the Alertmanagers of the clusters of the receiver
+func UpdateAlertReceiver(c *gin.Context) {
+	receiver, ok := getAlertReceiverFromRequest(c)
+	if !ok {
+		return
+	}
+	previousCluster := receiver.ClusterModelID
+	if !authorizeAlerting(c, receiver.OrganizationID, previousCluster) || !bindAlertReceiver(c, receiver) {
+		return
+	}
+	saveAlerting(c, "receiver", receiver.Save, receiver.OrganizationID, receiver.ClusterModelID, previousCluster, http.StatusOK, newAlertReceiverResponse(receiver))
+}
+
+// DeleteAlertReceiver deletes a receiver and removes it from the Alertmanagers of the clusters of the receiver
+func DeleteAlertReceiver(c *gin.Context) {
+	receiver, ok := getAlertReceiverFromRequest(c)
+	if !ok || !authorizeAlerting(c, receiver.OrganizationID, receiver.ClusterModelID) {
+		return
+	}
+	saveAlerting(c, "receiver", receiver.Delete, receiver.OrganizationID, receiver.ClusterModelID, 0, http.StatusOK, newAlertReceiverResponse(receiver))
+}
+
+func newAlertReceiverResponse(receiver *model.AlertReceiverModel) alertReceiverResponse {
+	return alertReceiverResponse{receiver, receiver.GetSettings(), receiver.GetMatchers()}
+}
+
+// bindAlertRule parses the alerting rule of the request into the rule, it aborts the request if the rule is
+// invalid or the cluster of the rule isn't allowed
+func bindAlertRule(c *gin.Context, rule *model.AlertRuleModel) bool {
+	var request alertRuleRequest
+	if !bindAlertingRequest(c, &request) {
+		return false
+	}
+	rule.ClusterModelID = request.ClusterID
+	rule.Name, rule.Expr, rule.For = request.Name, request.Expr, request.For
+	rule.Severity, rule.Summary, rule.Description = request.Severity, request.Summary, request.Description
+	rule.SetLabels(request.Labels)
+	if err := cluster.ValidateAlertRule(*rule); err != nil {
+		alertingBadRequest(c, err)
+		return false
+	}
+	return authorizeAlerting(c, rule.OrganizationID, rule.ClusterModelID)
+}
+
+// bindAlertReceiver parses the receiver of the request into the receiver, it aborts the request if the receiver
+// is invalid, its name is taken or the cluster of the receiver isn't allowed
+func bindAlertReceiver(c *gin.Context, receiver *model.AlertReceiverModel) bool {
+	var request alertReceiverRequest
+	if !bindAlertingRequest(c, &request) {
+		return false
+	}
+	if other, err := model.GetAlertReceiverByName(receiver.OrganizationID, request.Name); err == nil && other.ID != receiver.ID {
+		alertingBadRequest(c, fmt.Errorf("receiver %s already exists", request.Name))
+		return false
+	}
+	receiver.ClusterModelID = request.ClusterID
+	receiver.Name, receiver.Type = request.Name, request.Type
+	receiver.SetSettings(request.Settings)
+	receiver.SetMatchers(request.Matchers)
+	if err := cluster.ValidateAlertReceiver(*receiver); err != nil {
+		alertingBadRequest(c, err)
+		return false
+	}
+	return authorizeAlerting(c, receiver.OrganizationID, receiver.ClusterModelID)
+}
+
+func bindAlertingRequest(c *gin.Context, request interface{}) bool {
+	if err := c.BindJSON(request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func alertingBadRequest(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, components.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: err.Error(),
+		Error:   err.Error(),
+	})
+}
+
+// authorizeAlerting checks that the cluster is a cluster of the organization and the policies allow updating it,
+// the alerting of every cluster is authorized without cluster attributes
+func authorizeAlerting(c *gin.Context, organizationID, clusterID uint) bool {
+	if clusterID == 0 {
+		return authorizePolicies(c, auth.PolicyActionClusterUpdate, nil)
+	}
+	var modelCluster model.ClusterModel
+	query := model.ClusterModel{OrganizationId: organizationID}
+	query.ID = clusterID
+	if err := model.GetDB().Where(query).First(&modelCluster).Error; err != nil {
+		if model.IsErrorGormNotFound(err) {
+			err = fmt.Errorf("cluster %d not found", clusterID)
+		}
+		alertingBadRequest(c, err)
+		return false
+	}
+	commonCluster, err := cluster.GetCommonClusterFromModel(&modelCluster)
+	if err != nil {
+		alertingBadRequest(c, err)
+		return false
+	}
+	return authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster))
+}
+
+// saveAlerting saves the change and deploys the alerting of the clusters of the change, the previous cluster of an
+// updated rule or receiver is redeployed as well
+func saveAlerting(c *gin.Context, kind string, save func() error, organizationID, clusterID, previousCluster uint, code int, response interface{}) {
+	log := logger.WithFields(logrus.Fields{"tag": "SaveAlerting"})
+	if err := save(); err != nil {
+		log.Errorf("Error saving %s: %s", kind, err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error saving " + kind,
+			Error:   err.Error(),
+		})
+		return
+	}
+	err := cluster.DeployAlerting(organizationID, clusterID)
+	if err == nil && clusterID != 0 && previousCluster != clusterID {
+		err = cluster.DeployAlerting(organizationID, previousCluster)
+	}
+	if err != nil {
+		log.Errorf("Error deploying %s: %s", kind, err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "The " + kind + " is saved, error deploying it",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(code, response)
+}
+
+// getAlertRuleFromRequest loads the alerting rule of the path, it aborts the request if it doesn't exist
+func getAlertRuleFromRequest(c *gin.Context) (*model.AlertRuleModel, bool) {
+	id, err := strconv.ParseUint(c.Param("ruleid"), 10, 32)
+	if err != nil {
+		alertingBadRequest(c, fmt.Errorf("invalid alerting rule ID: %s", c.Param("ruleid")))
+		return nil, false
+	}
+	rule, err := model.GetAlertRule(auth.GetCurrentOrganization(c.Request).ID, uint(id))
+	if err != nil {
+		alertingNotFound(c, "alerting rule", err)
+		return nil, false
+	}
+	return rule, true
+}
+
+// getAlertReceiverFromRequest loads the receiver of the path, it aborts the request if it doesn't exist
+func getAlertReceiverFromRequest(c *gin.Context) (*model.AlertReceiverModel, bool) {
+	id, err := strconv.ParseUint(c.Param("receiverid"), 10, 32)
+	if err != nil {
+		alertingBadRequest(c, fmt.Errorf("invalid receiver ID: %s", c.Param("receiverid")))
+		return nil, false
+	}
+	receiver, err := model.GetAlertReceiver(auth.GetCurrentOrganization(c.Request).ID, uint(id))
+	if err != nil {
+		alertingNotFound(c, "receiver", err)
+		return nil, false
+	}
+	return receiver, true
+}
+
+func alertingNotFound(c *gin.Context, kind string, err error) {
+	code, message := http.StatusInternalServerError, "Error getting "+kind
+	if model.IsErrorGormNotFound(err) {
+		code, message = http.StatusNotFound, kind+" not found"
+	}
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
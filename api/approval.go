@@ -0,0 +1,453 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/scm"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// deploymentApprovalSettings marks a cluster as protected, the deployments of a protected cluster wait for
+// the approval of one of the approvers (logins), the admins of the organization if there are none
+type deploymentApprovalSettings struct {
+	Protected bool     `json:"protected"`
+	Approvers []string `json:"approvers"`
+}
+
+// resolveDeploymentRequest is the reason of the approval or the rejection of a deployment
+type resolveDeploymentRequest struct {
+	Comment string `json:"comment"`
+}
+
+// errorRecorder keeps the body of the response of the deployment of an approval, the error of a failed
+// deployment is saved to the approval
+type errorRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write implements gin.ResponseWriter
+func (w *errorRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString implements gin.ResponseWriter
+func (w *errorRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// GetDeploymentApprovalSettings returns whether the deployments of the cluster need an approval and its approvers
+func GetDeploymentApprovalSettings(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	modelCluster := commonCluster.GetModel()
+	c.JSON(http.StatusOK, deploymentApprovalSettings{
+		Protected: modelCluster.Protected,
+		Approvers: modelCluster.GetDeploymentApprovers(),
+	})
+}
+
+// SetDeploymentApprovalSettings protects the cluster or removes its protection and sets the approvers of its
+// deployments, the pending deployments aren't changed
+func SetDeploymentApprovalSettings(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SetDeploymentApprovalSettings"})
+	var request deploymentApprovalSettings
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	users, err := auth.GetUsersByLogin(request.Approvers)
+	if err == nil && len(users) != len(request.Approvers) {
+		err = fmt.Errorf("unknown approvers, the approvers must be the logins of users")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid approvers",
+			Error:   err.Error(),
+		})
+		return
+	}
+	modelCluster := commonCluster.GetModel()
+	modelCluster.Protected = request.Protected
+	modelCluster.SetDeploymentApprovers(request.Approvers)
+	if err := commonCluster.Persist(); err != nil {
+		log.Errorf("Error during cluster save %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during cluster save",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// requestDeploymentApproval saves the deployment request of a protected cluster as a pending approval and notifies
+// its approvers, the deployment is run when it's approved
+func requestDeploymentApproval(c *gin.Context, commonCluster cluster.CommonCluster, action, releaseName, chart string, request interface{}) {
+	log := logger.WithFields(logrus.Fields{"tag": "RequestDeploymentApproval"})
+	data, err := json.Marshal(request)
+	approval := &model.DeploymentApprovalModel{
+		OrganizationID: commonCluster.GetOrg(),
+		ClusterID:      commonCluster.GetID(),
+		Action:         action,
+		ReleaseName:    releaseName,
+		Chart:          chart,
+		Request:        string(data),
+		Status:         model.ApprovalPending,
+		RequestedBy:    auth.GetCurrentActor(c),
+	}
+	if user := auth.GetCurrentUser(c.Request); user != nil {
+		approval.RequesterID = user.ID
+	}
+	commit := commitFromRequest(c)
+	if commit != nil {
+		approval.CommitRepository, approval.CommitSHA = commit.Repository, commit.SHA
+	}
+	if err == nil {
+		err = approval.Save()
+	}
+	if err != nil {
+		log.Errorf("Error during saving deployment approval: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving deployment approval",
+			Error:   err.Error(),
+		})
+		return
+	}
+	log.Infof("%s on protected cluster %s waits for approval %d", approvalSubject(action, chart), commonCluster.GetName(), approval.ID)
+	message := fmt.Sprintf("%s on cluster %s waits for approval %d", approvalSubject(action, chart), commonCluster.GetName(), approval.ID)
+	recordClusterEvent(c, commonCluster, notify.EventApprovalRequested, releaseName, message)
+	go notifyApprovers(commonCluster, message)
+	go reportCommitStatus(commonCluster, releaseName, commit, scm.StatePending, "Waiting for approval")
+	c.JSON(http.StatusAccepted, approval)
+}
+
+// notifyApprovers mails the approvers of the deployments of the cluster and posts the message to Slack
+func notifyApprovers(commonCluster cluster.CommonCluster, message string) {
+	log := logger.WithFields(logrus.Fields{"tag": "NotifyApprovers"})
+	var approvers []auth.User
+	var err error
+	if logins := commonCluster.GetModel().GetDeploymentApprovers(); len(logins) > 0 {
+		approvers, err = auth.GetUsersByLogin(logins)
+	} else {
+		approvers, err = auth.GetOrganizationAdmins(commonCluster.GetOrg())
+	}
+	if err != nil {
+		log.Errorf("Error loading the approvers of cluster %s: %s", commonCluster.GetName(), err.Error())
+		return
+	}
+	emails := []string{}
+	for _, approver := range approvers {
+		if approver.Email != "" {
+			emails = append(emails, approver.Email)
+		}
+	}
+	if err := notify.SendMail(emails, "Pipeline deployment waiting for approval", message); err != nil {
+		log.Errorf("Error mailing the approvers of cluster %s: %s", commonCluster.GetName(), err.Error())
+	}
+	if err := notify.SlackNotify(message); err != nil {
+		log.Errorf("Error notifying Slack: %s", err.Error())
+	}
+}
+
+// ListDeploymentApprovals lists the deployment approvals of the organization filtered by the status and the
+// clusterId query parameters
+func ListDeploymentApprovals(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListDeploymentApprovals"})
+	var clusterID uint64
+	if value := c.Query("clusterId"); value != "" {
+		var err error
+		if clusterID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid clusterId",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	approvals, err := model.ListDeploymentApprovals(auth.GetCurrentOrganization(c.Request).ID, strings.ToUpper(c.Query("status")), uint(clusterID))
+	if err != nil {
+		log.Errorf("Error during listing deployment approvals: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing deployment approvals",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, approvals)
+}
+
+// GetDeploymentApproval returns a deployment approval of the organization
+func GetDeploymentApproval(c *gin.Context) {
+	if approval, ok := getDeploymentApproval(c); ok {
+		c.JSON(http.StatusOK, approval)
+	}
+}
+
+// ApproveDeployment approves a pending deployment and runs it, a failed deployment is saved with its error
+func ApproveDeployment(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ApproveDeployment"})
+	approval, commonCluster, comment, ok := resolveDeploymentApproval(c, model.ApprovalApproved)
+	if !ok {
+		return
+	}
+	recordClusterEvent(c, commonCluster, notify.EventDeploymentApproved, approval.ReleaseName,
+		fmt.Sprintf("%s on cluster %s is approved: %s", approvalSubject(approval.Action, approval.Chart), commonCluster.GetName(), comment))
+
+	var commit *ciCommit
+	if approval.CommitSHA != "" {
+		commit = &ciCommit{Repository: approval.CommitRepository, SHA: approval.CommitSHA}
+	}
+	recorder := &errorRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	if deploy, ok := approvedDeployments[approval.Action]; ok {
+		deploy(c, commonCluster, approval, commit)
+	} else {
+		deploymentError(c, "Error running the approved deployment", fmt.Errorf("unknown action %q", approval.Action))
+	}
+	if c.Writer.Written() {
+		var response components.ErrorResponse
+		json.Unmarshal(recorder.body.Bytes(), &response)
+		approval.Status, approval.Error = model.ApprovalFailed, response.Error
+		log.Infof("Approved deployment %d of cluster %s failed: %s", approval.ID, commonCluster.GetName(), response.Error)
+		if err := approval.Save(); err != nil {
+			log.Errorf("Error during saving deployment approval: %s", err.Error())
+		}
+		return
+	}
+	if err := approval.Save(); err != nil {
+		log.Errorf("Error during saving deployment approval: %s", err.Error())
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+// approvedDeployment runs the held request of an approved deployment, it responds with the error if the request fails
+type approvedDeployment func(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, commit *ciCommit)
+
+// approvedDeployments are the deployments of the approval actions
+var approvedDeployments = map[string]approvedDeployment{
+	model.ApprovalActionCreate: func(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, commit *ciCommit) {
+		var request createDeploymentRequest
+		if err := json.Unmarshal([]byte(approval.Request), &request); err != nil {
+			deploymentError(c, "Error parsing the deployment request", err)
+			return
+		}
+		if response, ok := installDeployment(c, commonCluster, &request, commit); ok {
+			approval.ReleaseName = response.ReleaseName
+		}
+	},
+	model.ApprovalActionUpgrade: func(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, commit *ciCommit) {
+		var request upgradeDeploymentRequest
+		if err := json.Unmarshal([]byte(approval.Request), &request); err != nil {
+			deploymentError(c, "Error parsing the deployment request", err)
+			return
+		}
+		if response, ok := upgradeRelease(c, commonCluster, approval.ReleaseName, &request, commit); ok {
+			approval.Revision = response.Revision
+		}
+	},
+	model.ApprovalActionRollback: func(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, commit *ciCommit) {
+		var request rollbackDeploymentRequest
+		if err := json.Unmarshal([]byte(approval.Request), &request); err != nil {
+			deploymentError(c, "Error parsing the deployment request", err)
+			return
+		}
+		if response, ok := rollbackRelease(c, commonCluster, approval.ReleaseName, &request); ok {
+			approval.Revision = response.Revision
+		}
+	},
+	model.ApprovalActionApply: func(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, commit *ciCommit) {
+		var request manifestRequest
+		if err := json.Unmarshal([]byte(approval.Request), &request); err != nil {
+			deploymentError(c, "Error parsing the deployment request", err)
+			return
+		}
+		applyManifests(c, commonCluster, &request)
+	},
+}
+
+// approvalSubject describes the held request of an approval in the messages
+func approvalSubject(action, chart string) string {
+	if action == model.ApprovalActionApply {
+		return "The apply of manifests"
+	}
+	return fmt.Sprintf("The %s of chart %s", action, chart)
+}
+
+// checkClusterUnprotected aborts the requests changing the deployments of a protected cluster which can't wait
+// for an approval
+func checkClusterUnprotected(c *gin.Context, commonCluster cluster.CommonCluster, operation string) bool {
+	if !commonCluster.GetModel().Protected {
+		return true
+	}
+	c.JSON(http.StatusConflict, components.ErrorResponse{
+		Code:    http.StatusConflict,
+		Message: "The cluster is protected",
+		Error:   fmt.Sprintf("%s isn't allowed on protected clusters, their deployments need an approval", operation),
+	})
+	return false
+}
+
+// RejectDeployment rejects a pending deployment, the requester can reject (withdraw) it too
+func RejectDeployment(c *gin.Context) {
+	approval, commonCluster, comment, ok := resolveDeploymentApproval(c, model.ApprovalRejected)
+	if !ok {
+		return
+	}
+	recordClusterEvent(c, commonCluster, notify.EventDeploymentRejected, approval.ReleaseName,
+		fmt.Sprintf("%s on cluster %s is rejected: %s", approvalSubject(approval.Action, approval.Chart), commonCluster.GetName(), comment))
+	if approval.CommitSHA != "" {
+		commit := &ciCommit{Repository: approval.CommitRepository, SHA: approval.CommitSHA}
+		go reportCommitStatus(commonCluster, approval.ReleaseName, commit, scm.StateFailure, "Deployment rejected")
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+// resolveDeploymentApproval checks that the caller can resolve the pending deployment and saves the status,
+// it aborts the request if the deployment can't be resolved
+func resolveDeploymentApproval(c *gin.Context, status string) (*model.DeploymentApprovalModel, cluster.CommonCluster, string, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": "ResolveDeploymentApproval"})
+	var request resolveDeploymentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&request); err != nil {
+			log.Errorf("Error parsing request: %s", err.Error())
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Error parsing request",
+				Error:   err.Error(),
+			})
+			return nil, nil, "", false
+		}
+	}
+	approval, ok := getDeploymentApproval(c)
+	if !ok {
+		return nil, nil, "", false
+	}
+	modelCluster, err := model.QueryCluster(map[string]interface{}{"id": approval.ClusterID, "organization_id": approval.OrganizationID})
+	var commonCluster cluster.CommonCluster
+	if err == nil {
+		commonCluster, err = cluster.GetCommonClusterFromModel(modelCluster)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Cluster not found",
+			Error:   err.Error(),
+		})
+		return nil, nil, "", false
+	}
+	if reason := approverError(c, commonCluster, approval, status); reason != "" {
+		c.JSON(http.StatusForbidden, components.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Need more privileges",
+			Error:   reason,
+		})
+		return nil, nil, "", false
+	}
+	resolved, err := approval.Resolve(status, auth.GetCurrentActor(c), request.Comment)
+	if err != nil {
+		log.Errorf("Error during saving deployment approval: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving deployment approval",
+			Error:   err.Error(),
+		})
+		return nil, nil, "", false
+	} else if !resolved {
+		c.JSON(http.StatusConflict, components.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "The deployment isn't pending",
+			Error:   fmt.Sprintf("deployment approval %d is resolved already", approval.ID),
+		})
+		return nil, nil, "", false
+	}
+	log.Infof("Deployment approval %d of cluster %s is %s by %s", approval.ID, commonCluster.GetName(), status, approval.ResolvedBy)
+	return approval, commonCluster, request.Comment, true
+}
+
+// approverError returns why the caller can't resolve the pending deployment, it's empty if the caller is an
+// approver of the cluster. Only users approve deployments and never their own ones, but they can reject them.
+func approverError(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, status string) string {
+	user := auth.GetCurrentUser(c.Request)
+	if user == nil || auth.GetCurrentServiceAccount(c.Request) != nil {
+		return "only users can resolve deployment approvals"
+	}
+	if user.ID == approval.RequesterID {
+		if status == model.ApprovalRejected {
+			return ""
+		}
+		return "deployments can't be approved by their requester"
+	}
+	approvers := commonCluster.GetModel().GetDeploymentApprovers()
+	if len(approvers) == 0 {
+		if auth.HasRole(auth.GetCurrentOrganizationRole(c.Request), auth.RoleAdmin) {
+			return ""
+		}
+		return "the deployments of the cluster are approved by the admins of the organization"
+	}
+	for _, approver := range approvers {
+		if approver == user.Login {
+			return ""
+		}
+	}
+	return "the caller isn't an approver of the deployments of the cluster"
+}
+
+// getDeploymentApproval loads the deployment approval of the request, it aborts the request if it doesn't exist
+func getDeploymentApproval(c *gin.Context) (*model.DeploymentApprovalModel, bool) {
+	id, err := strconv.ParseUint(c.Param("approvalid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Deployment approval not found",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	approval, err := model.GetDeploymentApproval(auth.GetCurrentOrganization(c.Request).ID, uint(id))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting deployment approval"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "Deployment approval not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return approval, true
+}
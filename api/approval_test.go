@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	qorauth "github.com/qor/auth"
+)
+
+const (
+	approvalRequester = 1
+	approvalApprover  = 2
+	approvalOther     = 3
+)
+
+var approvalUsers = map[uint]*auth.User{
+	approvalRequester: {ID: approvalRequester, Login: "jdoe"},
+	approvalApprover:  {ID: approvalApprover, Login: "alice"},
+	approvalOther:     {ID: approvalOther, Login: "bob"},
+}
+
+// setUpApprovals replaces the database with a test one holding a protected cluster and a pending upgrade of
+// the requester, the held upgrades are counted instead of run
+func setUpApprovals(t *testing.T, approvers []string, fail bool) (*model.DeploymentApprovalModel, *int, func()) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error during opening database: %s", err.Error())
+	}
+	// every connection of an in-memory database is a new database
+	db.DB().SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.ClusterModel{}, &model.DeploymentApprovalModel{}, &model.EventModel{}, &model.WebhookModel{}).Error; err != nil {
+		t.Fatalf("Error during migrating database: %s", err.Error())
+	}
+	model.SetDB(db)
+
+	modelCluster := &model.ClusterModel{Name: "prod", Cloud: cluster.Kubernetes, OrganizationId: 1, Protected: true}
+	modelCluster.SetDeploymentApprovers(approvers)
+	if err := db.Save(modelCluster).Error; err != nil {
+		t.Fatalf("Error during saving cluster: %s", err.Error())
+	}
+	approval := &model.DeploymentApprovalModel{
+		OrganizationID: 1,
+		ClusterID:      modelCluster.ID,
+		Action:         model.ApprovalActionUpgrade,
+		ReleaseName:    "web",
+		Chart:          "stable/nginx",
+		Request:        "{}",
+		Status:         model.ApprovalPending,
+		RequesterID:    approvalRequester,
+	}
+	if err := approval.Save(); err != nil {
+		t.Fatalf("Error during saving approval: %s", err.Error())
+	}
+
+	runs := new(int)
+	upgrade := approvedDeployments[model.ApprovalActionUpgrade]
+	approvedDeployments[model.ApprovalActionUpgrade] = func(c *gin.Context, commonCluster cluster.CommonCluster, approval *model.DeploymentApprovalModel, commit *ciCommit) {
+		*runs++
+		if fail {
+			deploymentError(c, "Error upgrading deployment", errUpgradeFailed)
+			return
+		}
+		approval.Revision = 2
+	}
+	return approval, runs, func() {
+		approvedDeployments[model.ApprovalActionUpgrade] = upgrade
+		db.Close()
+	}
+}
+
+var errUpgradeFailed = errors.New("upgrade failed")
+
+// resolveApproval posts the approval or the rejection of the user with the organization role to the handler
+func resolveApproval(t *testing.T, handler gin.HandlerFunc, approvalID, userID uint, role string) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orgs/:orgid/deployments/:approvalid/resolve", func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), qorauth.CurrentUser, approvalUsers[userID])
+		ctx = context.WithValue(ctx, auth.CurrentOrganization, &auth.Organization{ID: 1})
+		ctx = context.WithValue(ctx, auth.CurrentOrganizationRole, role)
+		c.Request = c.Request.WithContext(ctx)
+	}, handler)
+
+	recorder := httptest.NewRecorder()
+	path := "/orgs/1/deployments/" + strconv.Itoa(int(approvalID)) + "/resolve"
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, nil))
+	return recorder.Code
+}
+
+func TestApproveDeployment(t *testing.T) {
+
+	cases := []struct {
+		name           string
+		approvers      []string
+		user           uint
+		role           string
+		fail           bool
+		expectedCode   int
+		expectedStatus string
+	}{
+		{name: "self-approval", approvers: []string{"jdoe", "alice"}, user: approvalRequester, role: auth.RoleAdmin, expectedCode: http.StatusForbidden, expectedStatus: model.ApprovalPending},
+		{name: "non-approver", approvers: []string{"alice"}, user: approvalOther, role: auth.RoleAdmin, expectedCode: http.StatusForbidden, expectedStatus: model.ApprovalPending},
+		{name: "approver", approvers: []string{"alice"}, user: approvalApprover, role: auth.RoleMember, expectedCode: http.StatusOK, expectedStatus: model.ApprovalApproved},
+		{name: "admin without approvers", user: approvalOther, role: auth.RoleAdmin, expectedCode: http.StatusOK, expectedStatus: model.ApprovalApproved},
+		{name: "member without approvers", user: approvalOther, role: auth.RoleMember, expectedCode: http.StatusForbidden, expectedStatus: model.ApprovalPending},
+		{name: "failed deployment", approvers: []string{"alice"}, user: approvalApprover, role: auth.RoleMember, fail: true, expectedCode: http.StatusBadRequest, expectedStatus: model.ApprovalFailed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			approval, runs, tearDown := setUpApprovals(t, tc.approvers, tc.fail)
+			defer tearDown()
+
+			if code := resolveApproval(t, ApproveDeployment, approval.ID, tc.user, tc.role); code != tc.expectedCode {
+				t.Errorf("Expected %v, got: %v", tc.expectedCode, code)
+			}
+			saved, err := model.GetDeploymentApproval(1, approval.ID)
+			if err != nil {
+				t.Fatalf("Error during getting approval: %s", err.Error())
+			}
+			if saved.Status != tc.expectedStatus {
+				t.Errorf("Expected %v, got: %v", tc.expectedStatus, saved.Status)
+			}
+			// the held upgrade is run only when the approval is resolved
+			expectedRuns := 0
+			if tc.expectedStatus != model.ApprovalPending {
+				expectedRuns = 1
+			}
+			if *runs != expectedRuns {
+				t.Errorf("Expected %v, got: %v", expectedRuns, *runs)
+			}
+			switch tc.expectedStatus {
+			case model.ApprovalApproved:
+				if saved.Revision != 2 || saved.ResolvedBy != strconv.Itoa(int(tc.user)) {
+					t.Errorf("Expected %v, got: %v", "revision 2 resolved by "+strconv.Itoa(int(tc.user)), saved)
+				}
+			case model.ApprovalFailed:
+				if saved.Error != errUpgradeFailed.Error() {
+					t.Errorf("Expected %v, got: %v", errUpgradeFailed.Error(), saved.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveDeploymentOnce(t *testing.T) {
+
+	approval, runs, tearDown := setUpApprovals(t, []string{"alice", "bob"}, false)
+	defer tearDown()
+
+	if code := resolveApproval(t, ApproveDeployment, approval.ID, approvalApprover, auth.RoleMember); code != http.StatusOK {
+		t.Fatalf("Expected %v, got: %v", http.StatusOK, code)
+	}
+	// a resolved approval is neither approved again nor rejected
+	if code := resolveApproval(t, ApproveDeployment, approval.ID, approvalOther, auth.RoleMember); code != http.StatusConflict {
+		t.Errorf("Expected %v, got: %v", http.StatusConflict, code)
+	}
+	if code := resolveApproval(t, RejectDeployment, approval.ID, approvalOther, auth.RoleMember); code != http.StatusConflict {
+		t.Errorf("Expected %v, got: %v", http.StatusConflict, code)
+	}
+	if *runs != 1 {
+		t.Errorf("Expected %v, got: %v", 1, *runs)
+	}
+}
+
+func TestRejectDeployment(t *testing.T) {
+
+	cases := []struct {
+		name         string
+		user         uint
+		expectedCode int
+	}{
+		// the requester can withdraw the deployment
+		{name: "requester", user: approvalRequester, expectedCode: http.StatusOK},
+		{name: "approver", user: approvalApprover, expectedCode: http.StatusOK},
+		{name: "non-approver", user: approvalOther, expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			approval, runs, tearDown := setUpApprovals(t, []string{"alice"}, false)
+			defer tearDown()
+
+			if code := resolveApproval(t, RejectDeployment, approval.ID, tc.user, auth.RoleMember); code != tc.expectedCode {
+				t.Errorf("Expected %v, got: %v", tc.expectedCode, code)
+			}
+			if *runs != 0 {
+				t.Errorf("Expected %v, got: %v", 0, *runs)
+			}
+		})
+	}
+}
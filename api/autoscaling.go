@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ListAutoscalingPolicies lists the autoscaling policies of the workloads of the deployment
+func ListAutoscalingPolicies(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	policies, err := cluster.ListAutoscalingPolicies(commonCluster, c.Param("name"))
+	if err != nil {
+		autoscalingError(c, "Error listing autoscaling policies", err)
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// SetAutoscalingPolicy creates or replaces the autoscaling policy of a workload of the deployment
+func SetAutoscalingPolicy(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SetAutoscalingPolicy"})
+	var policy cluster.AutoscalingPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	applied, err := cluster.SetAutoscalingPolicy(commonCluster, c.Param("name"), policy)
+	if err != nil {
+		autoscalingError(c, "Error setting autoscaling policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, applied)
+}
+
+// DeleteAutoscalingPolicy deletes the autoscaling policy of a workload of the deployment
+func DeleteAutoscalingPolicy(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteAutoscalingPolicy(commonCluster, c.Param("name"), c.Param("workload")); err != nil {
+		autoscalingError(c, "Error deleting autoscaling policy", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetAutoscalingMetrics sends back the custom and external metrics the autoscaling policies of the cluster can use
+func GetAutoscalingMetrics(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	metrics, err := cluster.GetAutoscalingMetrics(commonCluster)
+	if err != nil {
+		autoscalingError(c, "Error listing autoscaling metrics", err)
+		return
+	}
+	c.JSON(http.StatusOK, metrics)
+}
+
+// GetDeploymentRecommendations sends back the recommended resource requests of the workloads of the deployment
+func GetDeploymentRecommendations(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	recommendations, err := cluster.GetDeploymentRecommendations(commonCluster, c.Param("name"))
+	if err != nil {
+		autoscalingError(c, "Error getting recommendations", err)
+		return
+	}
+	c.JSON(http.StatusOK, recommendations)
+}
+
+// autoscalingError responds with the status of the Kubernetes error
+func autoscalingError(c *gin.Context, message string, err error) {
+	code := http.StatusBadRequest
+	if statusErr, ok := errors.Cause(err).(k8sErrors.APIStatus); ok && statusErr.Status().Code != 0 {
+		code = int(statusErr.Status().Code)
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// GetBackupBucket sends back the backup bucket of the cluster
+func GetBackupBucket(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetBackupBucket"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	bucket, err := cluster.GetBackupBucket(commonCluster)
+	if err != nil {
+		log.Errorf("Error getting backup bucket: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting backup bucket",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if bucket == nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster has no backup bucket",
+			Error:   "The cluster has no backup bucket",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, bucket)
+}
+
+// SetBackupBucket sets the backup bucket of the cluster, the backup add-on is installed with the bucket
+func SetBackupBucket(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SetBackupBucket"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var bucket cluster.BackupBucket
+	if err := c.BindJSON(&bucket); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.EnableBackups(commonCluster, bucket); err != nil {
+		log.Errorf("Error setting backup bucket: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error setting backup bucket",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, bucket)
+}
+
+// DeleteBackupBucket removes the backup add-on and the backup bucket of the cluster, the backups are kept
+func DeleteBackupBucket(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteBackupBucket"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DisableBackups(commonCluster); err != nil {
+		code := http.StatusBadRequest
+		if model.IsErrorGormNotFound(err) {
+			code = http.StatusNotFound
+		}
+		log.Errorf("Error deleting backup bucket: %s", err.Error())
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: "Error deleting backup bucket",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// ListBackups lists the backups in the backup bucket of the cluster
+func ListBackups(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	backups, err := cluster.ListBackups(commonCluster)
+	if err != nil {
+		backupError(c, "Error listing backups", err)
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// CreateBackup starts an on-demand backup of the cluster
+func CreateBackup(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "CreateBackup"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request cluster.BackupRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	backup, err := cluster.CreateBackup(commonCluster, request)
+	if err != nil {
+		backupError(c, "Error creating backup", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, backup)
+}
+
+// GetBackup sends back a backup of the cluster and its progress
+func GetBackup(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	backup, err := cluster.GetBackup(commonCluster, c.Param("name"))
+	if err != nil {
+		backupError(c, "Error getting backup", err)
+		return
+	}
+	c.JSON(http.StatusOK, backup)
+}
+
+// DeleteBackup deletes a backup from the backup bucket of the cluster
+func DeleteBackup(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteBackup(commonCluster, c.Param("name")); err != nil {
+		backupError(c, "Error deleting backup", err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// RestoreBackup restores a backup of the cluster to the cluster or to the target cluster of the organization
+func RestoreBackup(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "RestoreBackup"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request cluster.RestoreRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	target := commonCluster
+	if request.TargetClusterID != 0 && request.TargetClusterID != commonCluster.GetID() {
+		modelCluster, err := model.QueryCluster(map[string]interface{}{
+			"id":              request.TargetClusterID,
+			"organization_id": auth.GetCurrentOrganization(c.Request).ID,
+		})
+		if err != nil {
+			c.JSON(http.StatusNotFound, components.ErrorResponse{
+				Code:    http.StatusNotFound,
+				Message: "Target cluster not found",
+				Error:   err.Error(),
+			})
+			return
+		}
+		if target, err = cluster.GetCommonClusterFromModel(modelCluster); err != nil {
+			log.Errorf("GetCommonClusterFromModel failed: %s", err.Error())
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Error parsing request",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(target)) {
+		return
+	}
+	restore, err := cluster.RestoreBackup(commonCluster, c.Param("name"), target, request)
+	if err != nil {
+		backupError(c, "Error restoring backup", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, restore)
+}
+
+// ListRestores lists the restores of the cluster
+func ListRestores(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	restores, err := cluster.ListRestores(commonCluster)
+	if err != nil {
+		backupError(c, "Error listing restores", err)
+		return
+	}
+	c.JSON(http.StatusOK, restores)
+}
+
+// GetRestore sends back a restore of the cluster and its progress
+func GetRestore(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	restore, err := cluster.GetRestore(commonCluster, c.Param("name"))
+	if err != nil {
+		backupError(c, "Error getting restore", err)
+		return
+	}
+	c.JSON(http.StatusOK, restore)
+}
+
+// ListBackupSchedules lists the backup schedules of the cluster
+func ListBackupSchedules(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	schedules, err := cluster.ListBackupSchedules(commonCluster)
+	if err != nil {
+		backupError(c, "Error listing backup schedules", err)
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// SetBackupSchedule creates a backup schedule of the cluster, or replaces the schedule of the name parameter
+func SetBackupSchedule(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SetBackupSchedule"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var schedule cluster.BackupSchedule
+	if err := c.BindJSON(&schedule); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if name := c.Param("name"); name != "" {
+		schedule.Name = name
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	applied, err := cluster.SetBackupSchedule(commonCluster, schedule)
+	if err != nil {
+		backupError(c, "Error setting backup schedule", err)
+		return
+	}
+	c.JSON(http.StatusOK, applied)
+}
+
+// DeleteBackupSchedule deletes a backup schedule of the cluster, its backups are kept
+func DeleteBackupSchedule(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteBackupSchedule(commonCluster, c.Param("name")); err != nil {
+		backupError(c, "Error deleting backup schedule", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// backupError responds the error of a backup operation, the missing Velero resources are not found
+func backupError(c *gin.Context, message string, err error) {
+	code := http.StatusBadRequest
+	if k8sErrors.IsNotFound(err) {
+		code = http.StatusNotFound
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
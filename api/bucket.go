@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/objectstore"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ListBuckets lists the buckets created by Pipeline for the organization, filtered by the provider parameter
+func ListBuckets(c *gin.Context) {
+	buckets, err := objectstore.ListManagedBuckets(auth.GetCurrentOrganization(c.Request).ID, c.Query("provider"))
+	if err != nil {
+		bucketError(c, "Error listing buckets", err)
+		return
+	}
+	c.JSON(http.StatusOK, buckets)
+}
+
+// CreateBucket creates a bucket with a cloud secret of the organization, the bucket already managed by Pipeline
+// is sent back unchanged
+func CreateBucket(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "CreateBucket"})
+	var request objectstore.BucketRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	bucket, created, err := objectstore.EnsureManagedBucket(auth.GetCurrentOrganization(c.Request).ID, auth.GetCurrentActor(c), request)
+	if err != nil {
+		bucketError(c, "Error creating bucket", err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, bucket)
+		return
+	}
+	c.JSON(http.StatusCreated, bucket)
+}
+
+// GetBucket sends back a bucket of the provider created by Pipeline for the organization
+func GetBucket(c *gin.Context) {
+	bucket, err := objectstore.GetManagedBucket(auth.GetCurrentOrganization(c.Request).ID, c.Param("provider"), c.Param("name"))
+	if err != nil {
+		bucketError(c, "Error getting bucket", err)
+		return
+	}
+	c.JSON(http.StatusOK, bucket)
+}
+
+// DeleteBucket deletes a bucket of the provider created by Pipeline for the organization
+func DeleteBucket(c *gin.Context) {
+	if err := objectstore.DeleteManagedBucket(auth.GetCurrentOrganization(c.Request).ID, c.Param("provider"), c.Param("name")); err != nil {
+		bucketError(c, "Error deleting bucket", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListAccountBuckets lists every bucket of the cloud account of the secret, the query parameters are the
+// settings of the provider
+func ListAccountBuckets(c *gin.Context) {
+	settings := map[string]string{}
+	for name := range c.Request.URL.Query() {
+		settings[name] = c.Query(name)
+	}
+	buckets, err := objectstore.ListAccountBuckets(auth.GetCurrentOrganization(c.Request).ID, c.Param("secretid"), settings)
+	if err != nil {
+		bucketError(c, "Error listing buckets", err)
+		return
+	}
+	c.JSON(http.StatusOK, buckets)
+}
+
+// bucketError responds the error of a bucket operation, the status of the errors of the providers is kept
+func bucketError(c *gin.Context, message string, err error) {
+	code := http.StatusBadRequest
+	if model.IsErrorGormNotFound(err) {
+		code = http.StatusNotFound
+	} else if status := objectstore.StatusCode(err); status == http.StatusNotFound || status == http.StatusConflict || status == http.StatusForbidden {
+		code = status
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+
+	htype "github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// canaryRequest describes a canary or blue-green release of a deployment, the ingress routes the weight
+// percent of the traffic of the stable service to the canary service. The canary is rolled back if the
+// value of the SLO query is above the threshold.
+type canaryRequest struct {
+	Strategy string                 `json:"strategy" binding:"required"`
+	Chart    string                 `json:"chart" binding:"required"`
+	Values   map[string]interface{} `json:"values"`
+	Weight   int                    `json:"weight"`
+	Ingress  struct {
+		Namespace     string `json:"namespace"`
+		Name          string `json:"name"`
+		StableService string `json:"stableService"`
+		CanaryService string `json:"canaryService"`
+	} `json:"ingress"`
+	SLO struct {
+		Query     string  `json:"query"`
+		Threshold float64 `json:"threshold"`
+	} `json:"slo"`
+}
+
+// GetCanary sends back the last canary of the deployment
+func GetCanary(c *gin.Context) {
+	canary, _, ok := getCanary(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, canary)
+}
+
+// StartCanary installs a canary or blue-green release of the deployment alongside the stable release
+func StartCanary(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "StartCanary"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request canaryRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	canary := &model.CanaryModel{
+		ClusterModelID: commonCluster.GetID(),
+		ReleaseName:    c.Param("name"),
+		Strategy:       request.Strategy,
+		Chart:          request.Chart,
+		Namespace:      request.Ingress.Namespace,
+		Ingress:        request.Ingress.Name,
+		StableService:  request.Ingress.StableService,
+		CanaryService:  request.Ingress.CanaryService,
+		Weight:         request.Weight,
+		Query:          request.SLO.Query,
+		Threshold:      request.SLO.Threshold,
+	}
+	canary.SetValues(request.Values)
+	if err := cluster.ValidateCanary(*canary); err != nil {
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = request.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentCreate, attributes) {
+		return
+	}
+	if !checkClusterUnprotected(c, commonCluster, "Starting a canary") {
+		return
+	}
+	if !addOrganizationRepositories(c, commonCluster) {
+		return
+	}
+	if err := cluster.StartCanary(commonCluster, canary); err != nil {
+		log.Errorf("Error starting canary: %s", err.Error())
+		deploymentError(c, "Error starting canary", err)
+		return
+	}
+	c.JSON(http.StatusCreated, canary)
+}
+
+// PromoteCanary upgrades the stable release of the deployment to the canary and removes the canary release
+func PromoteCanary(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "PromoteCanary"})
+	canary, commonCluster, ok := getCanary(c)
+	if !ok {
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = canary.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, attributes) {
+		return
+	}
+	if !checkClusterUnprotected(c, commonCluster, "Promoting a canary") {
+		return
+	}
+	if !addOrganizationRepositories(c, commonCluster) {
+		return
+	}
+	upgrade, err := cluster.PromoteCanary(commonCluster, canary)
+	if upgrade != nil {
+		saveDeploymentState(commonCluster, canary.ReleaseName, canary.Chart, upgrade.Release.Version, upgrade.Release.Info.Status.Code.String(), canary.GetValues(), nil)
+	}
+	if err != nil {
+		log.Errorf("Error promoting canary: %s", err.Error())
+		deploymentError(c, "Error promoting canary", err)
+		return
+	}
+	c.JSON(http.StatusOK, canary)
+}
+
+// AbortCanary removes the canary release of the deployment, the stable release gets every request again
+func AbortCanary(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "AbortCanary"})
+	canary, commonCluster, ok := getCanary(c)
+	if !ok {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.AbortCanary(commonCluster, canary, cluster.CanaryAborted, ""); err != nil {
+		log.Errorf("Error aborting canary: %s", err.Error())
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error aborting canary",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, canary)
+}
+
+// getCanary loads the canary of the deployment of the request, it aborts the request if there's none
+func getCanary(c *gin.Context) (*model.CanaryModel, cluster.CommonCluster, bool) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return nil, nil, false
+	}
+	canary, err := model.GetCanary(commonCluster.GetID(), c.Param("name"))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting canary"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "The deployment has no canary"
+		}
+		c.JSON(code, htype.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, nil, false
+	}
+	return canary, commonCluster, true
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// certificateRequest describes a certificate of the DNS names in the domain of the cluster, the secret of
+// the certificate is <name>-tls unless it's set
+type certificateRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Namespace  string   `json:"namespace" binding:"required"`
+	SecretName string   `json:"secretName"`
+	DNSNames   []string `json:"dnsNames" binding:"required"`
+}
+
+// certificateResponse describes a certificate with its DNS names
+type certificateResponse struct {
+	*model.CertificateModel
+	DNSNames []string `json:"dnsNames"`
+}
+
+// ListCertificates lists the certificates of the cluster with their last checked status
+func ListCertificates(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListCertificates"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	certificates, err := model.ListCertificates(commonCluster.GetID())
+	if err != nil {
+		log.Errorf("Error listing certificates: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing certificates",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := []certificateResponse{}
+	for i := range certificates {
+		response = append(response, certificateResponse{&certificates[i], certificates[i].GetDNSNames()})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RequestCertificate requests a certificate from the ACME issuer of the cluster
+func RequestCertificate(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "RequestCertificate"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request certificateRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if _, err := model.GetCertificate(commonCluster.GetID(), request.Namespace, request.Name); err == nil {
+		c.JSON(http.StatusConflict, components.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "Certificate already exists",
+			Error:   "Certificate already exists",
+		})
+		return
+	}
+	certificate := &model.CertificateModel{Name: request.Name, Namespace: request.Namespace, SecretName: request.SecretName}
+	certificate.SetDNSNames(request.DNSNames)
+	if err := cluster.RequestCertificate(commonCluster, certificate); err != nil {
+		log.Errorf("Error requesting certificate: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error requesting certificate",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, certificateResponse{certificate, certificate.GetDNSNames()})
+}
+
+// GetCertificate checks the certificate on the cluster and sends back its status and expiry
+func GetCertificate(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetCertificate"})
+	commonCluster, certificate, ok := getCertificateFromRequest(c)
+	if !ok {
+		return
+	}
+	if err := cluster.CheckCertificate(commonCluster, certificate); err != nil {
+		log.Errorf("Error checking certificate: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error checking certificate",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, certificateResponse{certificate, certificate.GetDNSNames()})
+}
+
+// DeleteCertificate deletes the certificate and its secret from the cluster
+func DeleteCertificate(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteCertificate"})
+	commonCluster, certificate, ok := getCertificateFromRequest(c)
+	if !ok {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteCertificate(commonCluster, certificate); err != nil {
+		log.Errorf("Error deleting certificate: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error deleting certificate",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// getCertificateFromRequest loads the certificate of the path, it aborts the request if it doesn't exist
+func getCertificateFromRequest(c *gin.Context) (cluster.CommonCluster, *model.CertificateModel, bool) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return nil, nil, false
+	}
+	certificate, err := model.GetCertificate(commonCluster.GetID(), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error getting certificate"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "Certificate not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, nil, false
+	}
+	return commonCluster, certificate, true
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterCostsResponse is the cost report of a cluster with its daily costs per node pool
+type clusterCostsResponse struct {
+	*cluster.CostReport
+	Days []model.ClusterCostModel `json:"days"`
+}
+
+// GetOrganizationCosts sends back the spend of the clusters of the organization between the from and to days,
+// the deleted clusters are in the report too
+func GetOrganizationCosts(c *gin.Context) {
+	filter := model.ClusterCostModel{OrganizationID: auth.GetCurrentOrganization(c.Request).ID}
+	from, to, costs, ok := queryClusterCosts(c, filter)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, cluster.NewCostReport(from, to, costs))
+}
+
+// GetClusterCosts sends back the spend of the cluster between the from and to days with its daily costs
+func GetClusterCosts(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	filter := model.ClusterCostModel{OrganizationID: commonCluster.GetOrg(), ClusterModelID: commonCluster.GetID()}
+	from, to, costs, ok := queryClusterCosts(c, filter)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, clusterCostsResponse{CostReport: cluster.NewCostReport(from, to, costs), Days: costs})
+}
+
+// clusterUtilizationResponse is the utilization report of a cluster with its daily utilization per node pool
+type clusterUtilizationResponse struct {
+	*cluster.UtilizationReport
+	Days []model.ClusterUtilizationModel `json:"days"`
+}
+
+// GetClusterUtilization sends back the allocatable, requested and used resources of the node pools of the cluster
+// between the from and to days with the idle and unused shares of their spend
+func GetClusterUtilization(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetClusterUtilization"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	filter := model.ClusterCostModel{OrganizationID: commonCluster.GetOrg(), ClusterModelID: commonCluster.GetID()}
+	from, to, costs, ok := queryClusterCosts(c, filter)
+	if !ok {
+		return
+	}
+	utilization, err := model.QueryClusterUtilization(model.ClusterUtilizationModel{
+		OrganizationID: commonCluster.GetOrg(),
+		ClusterModelID: commonCluster.GetID(),
+	}, from, to)
+	if err != nil {
+		log.Errorf("Error during listing cluster utilization: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing cluster utilization",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, clusterUtilizationResponse{
+		UtilizationReport: cluster.NewUtilizationReport(from, to, utilization, costs),
+		Days:              utilization,
+	})
+}
+
+// queryClusterCosts loads the daily costs matching the filter between the days of the request
+func queryClusterCosts(c *gin.Context, filter model.ClusterCostModel) (string, string, []model.ClusterCostModel, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetCluster})
+	from, to, err := cluster.CostReportDays(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid report days",
+			Error:   err.Error(),
+		})
+		return "", "", nil, false
+	}
+	costs, err := model.QueryClusterCosts(filter, from, to)
+	if err != nil {
+		log.Errorf("Error during listing cluster costs: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing cluster costs",
+			Error:   err.Error(),
+		})
+		return "", "", nil, false
+	}
+	return from, to, costs, true
+}
@@ -0,0 +1,396 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/scm"
+	"github.com/ghodss/yaml"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Secrets of the Drone repositories used by the Pipeline plugin steps
+const (
+	ciSecretEndpoint = "plugin_endpoint"
+	ciSecretToken    = "plugin_token"
+)
+
+// Headers of the deployment requests of the CI builds naming the owner/name repository and the commit of the build
+const (
+	ciRepositoryHeader = "X-Pipeline-Repository"
+	ciCommitHeader     = "X-Pipeline-Commit"
+)
+
+// maxCommitStatusDescription is the length of the descriptions of the commit statuses accepted by GitHub
+const maxCommitStatusDescription = 140
+
+// ciChartPlaceholder is the chart of the generated pipeline configs of the repositories without a chart
+const ciChartPlaceholder = "[[your-chart]]"
+
+// ciRepositoryRequest activates a repository in the CI/CD flow deploying to the cluster of the request, the
+// token is the Pipeline token of the builds
+type ciRepositoryRequest struct {
+	Repository string `json:"repository" binding:"required"`
+	Branch     string `json:"branch"`
+	Chart      string `json:"chart"`
+	Token      string `json:"token"`
+}
+
+// ciRepositoryResponse is the activated repository with its generated .pipeline.yml and the secrets to set in
+// Drone by hand
+type ciRepositoryResponse struct {
+	*model.CIRepositoryModel
+	Config         string   `json:"config"`
+	MissingSecrets []string `json:"missingSecrets"`
+}
+
+// ListCIRepositories lists the repositories of the organization activated in the CI/CD flow
+func ListCIRepositories(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListCIRepositories"})
+	if !droneEnabled(c) {
+		return
+	}
+	repositories, err := model.ListCIRepositories(auth.GetCurrentOrganization(c.Request).ID)
+	if err != nil {
+		log.Errorf("Error listing CI repositories: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing CI repositories",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, repositories)
+}
+
+// CreateCIRepository activates a GitHub or GitLab repository of the user in Drone, which installs the webhook of
+// the builds on the repository, sets the Drone secrets of the Pipeline plugin and binds the repository to the
+// cluster of the request; the generated .pipeline.yml is sent back
+func CreateCIRepository(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "CreateCIRepository"})
+	if !droneEnabled(c) {
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request ciRepositoryRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	parts := strings.Split(request.Repository, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid repository",
+			Error:   fmt.Sprintf("the repository must be owner/name: %q", request.Repository),
+		})
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	if request.Chart != "" {
+		attributes[auth.PolicyAttributeChart] = request.Chart
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentCreate, attributes) {
+		return
+	}
+	user, ok := currentDroneUser(c)
+	if !ok {
+		return
+	}
+
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	repository, err := model.GetCIRepository(organizationID, parts[0], parts[1])
+	if model.IsErrorGormNotFound(err) {
+		repository, err = &model.CIRepositoryModel{OrganizationID: organizationID, Owner: parts[0], Name: parts[1]}, nil
+	}
+	if err != nil {
+		log.Errorf("Error getting CI repository: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting CI repository",
+			Error:   err.Error(),
+		})
+		return
+	}
+	droneRepo, err := auth.ActivateDroneRepo(user.Login, repository.Owner, repository.Name)
+	if err != nil {
+		abortWithDroneError(c, "Error activating repository", err)
+		return
+	}
+	secrets := map[string]string{ciSecretEndpoint: viper.GetString("drone.pipelineEndpoint"), ciSecretToken: request.Token}
+	missingSecrets := []string{}
+	for _, name := range []string{ciSecretEndpoint, ciSecretToken} {
+		if secrets[name] == "" {
+			missingSecrets = append(missingSecrets, name)
+			continue
+		}
+		if err := auth.SetDroneSecret(user.Login, repository.Owner, repository.Name, name, secrets[name]); err != nil {
+			abortWithDroneError(c, "Error setting repository secret", err)
+			return
+		}
+	}
+
+	repository.ClusterID = commonCluster.GetID()
+	repository.Branch = request.Branch
+	if repository.Branch == "" {
+		repository.Branch = "master"
+	}
+	repository.Chart = request.Chart
+	repository.UserID = user.ID
+	repository.DroneID = droneRepo.ID
+	repository.Link = droneRepo.Link
+	if err := repository.Save(); err != nil {
+		log.Errorf("Error saving CI repository: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error saving CI repository",
+			Error:   err.Error(),
+		})
+		return
+	}
+	config, err := generatePipelineConfig(repository, commonCluster.GetModel())
+	if err != nil {
+		log.Errorf("Error generating pipeline config: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error generating pipeline config",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, ciRepositoryResponse{CIRepositoryModel: repository, Config: string(config), MissingSecrets: missingSecrets})
+}
+
+// GetCIRepositoryConfig sends back the generated .pipeline.yml of a repository of the organization
+func GetCIRepositoryConfig(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetCIRepositoryConfig"})
+	repository, ok := getCIRepositoryFromRequest(c)
+	if !ok {
+		return
+	}
+	modelCluster, err := model.QueryCluster(map[string]interface{}{"id": repository.ClusterID, "organization_id": repository.OrganizationID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster of the repository is not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	config, err := generatePipelineConfig(repository, modelCluster)
+	if err != nil {
+		log.Errorf("Error generating pipeline config: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error generating pipeline config",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/x-yaml", config)
+}
+
+// DeleteCIRepository deactivates a repository of the organization in Drone, which removes its webhook, and
+// deletes its binding
+func DeleteCIRepository(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteCIRepository"})
+	repository, ok := getCIRepositoryFromRequest(c)
+	if !ok {
+		return
+	}
+	user, ok := currentDroneUser(c)
+	if !ok {
+		return
+	}
+	if err := auth.DeactivateDroneRepo(user.Login, repository.Owner, repository.Name); err != nil {
+		abortWithDroneError(c, "Error deactivating repository", err)
+		return
+	}
+	if err := repository.Delete(); err != nil {
+		log.Errorf("Error deleting CI repository: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error deleting CI repository",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// generatePipelineConfig generates the .pipeline.yml building the image of the repository on the pushes to its
+// branch and deploying it to the cluster with the chart of the repository
+func generatePipelineConfig(repository *model.CIRepositoryModel, modelCluster *model.ClusterModel) ([]byte, error) {
+	image := strings.ToLower(repository.FullName())
+	chart := repository.Chart
+	if chart == "" {
+		chart = ciChartPlaceholder
+	}
+	when := map[string]interface{}{"branch": repository.Branch}
+	return yaml.Marshal(map[string]interface{}{
+		"pipeline": map[string]interface{}{
+			"build_image": map[string]interface{}{
+				"image":   "plugins/docker",
+				"repo":    image,
+				"tags":    []string{"${DRONE_COMMIT_SHA}"},
+				"secrets": []string{"docker_username", "docker_password"},
+				"when":    when,
+			},
+			"deploy_application": map[string]interface{}{
+				"image":                   viper.GetString("drone.pluginImage"),
+				"cluster_name":            modelCluster.Name,
+				"cluster_provider":        modelCluster.Cloud,
+				"deployment_name":         chart,
+				"deployment_release_name": strings.ToLower(repository.Name),
+				"deployment_values": map[string]interface{}{
+					"image": map[string]interface{}{"repository": image, "tag": "${DRONE_COMMIT_SHA}"},
+				},
+				"secrets": []string{ciSecretEndpoint, ciSecretToken},
+				"when":    when,
+			},
+		},
+	})
+}
+
+// ciCommit is the commit of the CI build deploying a release
+type ciCommit struct {
+	Repository string
+	SHA        string
+}
+
+// commitFromRequest returns the commit of the CI build of the deployment request, nil if it's not sent by a build
+func commitFromRequest(c *gin.Context) *ciCommit {
+	repository, sha := c.GetHeader(ciRepositoryHeader), c.GetHeader(ciCommitHeader)
+	if repository == "" || sha == "" {
+		return nil
+	}
+	return &ciCommit{Repository: repository, SHA: sha}
+}
+
+// reportCommitStatus reports the state of the deployment of the release to the commit with the SCM token of the
+// user who activated the repository, the errors are only logged; the commits of the repositories not activated
+// through Pipeline aren't reported
+func reportCommitStatus(commonCluster cluster.CommonCluster, releaseName string, commit *ciCommit, state, description string) {
+	if commit == nil || !viper.GetBool("drone.enabled") {
+		return
+	}
+	log := logger.WithFields(logrus.Fields{"tag": "ReportCommitStatus"})
+	parts := strings.Split(commit.Repository, "/")
+	if len(parts) != 2 {
+		log.Warnf("Invalid repository of commit %s: %q", commit.SHA, commit.Repository)
+		return
+	}
+	repository, err := model.GetCIRepository(commonCluster.GetOrg(), parts[0], parts[1])
+	if err != nil {
+		log.Debugf("Repository %s isn't activated through Pipeline: %s", commit.Repository, err.Error())
+		return
+	}
+	token, err := auth.GetSCMToken(repository.UserID)
+	if err != nil {
+		log.Warnf("Error getting the SCM token of repository %s: %s", commit.Repository, err.Error())
+		return
+	}
+	client, err := scm.NewClient(viper.GetString("scm.provider"), viper.GetString("scm.apiURL"), token)
+	if err != nil {
+		log.Warnf("Error creating SCM client: %s", err.Error())
+		return
+	}
+	targetURL := ""
+	if endpoint := viper.GetString("drone.pipelineEndpoint"); endpoint != "" {
+		targetURL = fmt.Sprintf("%s/orgs/%d/clusters/%d/deployments/%s", strings.TrimSuffix(endpoint, "/"), commonCluster.GetOrg(), commonCluster.GetID(), releaseName)
+	}
+	if len(description) > maxCommitStatusDescription {
+		description = description[:maxCommitStatusDescription-3] + "..."
+	}
+	err = client.SetCommitStatus(repository.Owner, repository.Name, commit.SHA, scm.CommitStatus{
+		State:       state,
+		TargetURL:   targetURL,
+		Description: description,
+		Context:     viper.GetString("scm.statusContext"),
+	})
+	if err != nil {
+		log.Warnf("Error reporting %s status of commit %s of repository %s: %s", state, commit.SHA, commit.Repository, err.Error())
+	}
+}
+
+// droneEnabled aborts the request if the Drone integration is disabled
+func droneEnabled(c *gin.Context) bool {
+	if !viper.GetBool("drone.enabled") {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The CI/CD integration is disabled",
+			Error:   "drone.enabled is false",
+		})
+		return false
+	}
+	return true
+}
+
+// currentDroneUser returns the user of the request, the service accounts have no Drone user
+func currentDroneUser(c *gin.Context) (*auth.User, bool) {
+	user := auth.GetCurrentUser(c.Request)
+	if user == nil || auth.GetCurrentServiceAccount(c.Request) != nil {
+		c.JSON(http.StatusForbidden, components.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Only users can manage the CI/CD repositories",
+			Error:   "the CI/CD repositories are activated with the SCM account of the user",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// abortWithDroneError responds the error of the Drone API, the Drone responses are passed through
+func abortWithDroneError(c *gin.Context, message string, err error) {
+	log := logger.WithFields(logrus.Fields{"tag": "Drone"})
+	log.Errorf("%s: %s", message, err.Error())
+	code := http.StatusBadGateway
+	if droneErr, ok := err.(*auth.DroneError); ok && droneErr.StatusCode < http.StatusInternalServerError {
+		code = droneErr.StatusCode
+	}
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+// getCIRepositoryFromRequest loads the repository of the owner and name path parameters of the organization
+func getCIRepositoryFromRequest(c *gin.Context) (*model.CIRepositoryModel, bool) {
+	if !droneEnabled(c) {
+		return nil, false
+	}
+	repository, err := model.GetCIRepository(auth.GetCurrentOrganization(c.Request).ID, c.Param("owner"), c.Param("name"))
+	if model.IsErrorGormNotFound(err) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "CI repository not found",
+			Error:   err.Error(),
+		})
+		return nil, false
+	} else if err != nil {
+		log.Errorf("Error getting CI repository: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting CI repository",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return repository, true
+}
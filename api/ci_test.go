@@ -0,0 +1,56 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/ghodss/yaml"
+)
+
+func TestGeneratePipelineConfig(t *testing.T) {
+
+	modelCluster := &model.ClusterModel{Name: "cluster1", Cloud: "amazon"}
+	cases := []struct {
+		name  string
+		chart string
+	}{
+		{name: "chart", chart: "stable/nginx"},
+		{name: "no chart", chart: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repository := &model.CIRepositoryModel{Owner: "Acme", Name: "Web", Branch: "release", Chart: tc.chart}
+			data, err := generatePipelineConfig(repository, modelCluster)
+			if err != nil {
+				t.Fatalf("Error during generating pipeline config: %s", err.Error())
+			}
+			var config struct {
+				Pipeline map[string]map[string]interface{} `json:"pipeline"`
+			}
+			if err := yaml.Unmarshal(data, &config); err != nil {
+				t.Fatalf("Error during parsing pipeline config: %s", err.Error())
+			}
+			build, deploy := config.Pipeline["build_image"], config.Pipeline["deploy_application"]
+			if build["repo"] != "acme/web" {
+				t.Errorf("Expected image acme/web, got: %v", build["repo"])
+			}
+			if deploy["cluster_name"] != "cluster1" || deploy["cluster_provider"] != "amazon" {
+				t.Errorf("Expected cluster1 on amazon, got: %v on %v", deploy["cluster_name"], deploy["cluster_provider"])
+			}
+			expectedChart := tc.chart
+			if expectedChart == "" {
+				expectedChart = ciChartPlaceholder
+			}
+			if deploy["deployment_name"] != expectedChart || deploy["deployment_release_name"] != "web" {
+				t.Errorf("Expected the %s web release, got: %v %v", expectedChart, deploy["deployment_name"], deploy["deployment_release_name"])
+			}
+			for _, step := range []map[string]interface{}{build, deploy} {
+				when, _ := step["when"].(map[string]interface{})
+				if when["branch"] != "release" {
+					t.Errorf("Expected the steps on the release branch, got: %v", step["when"])
+				}
+			}
+		})
+	}
+}
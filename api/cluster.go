@@ -2,16 +2,21 @@ package api
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/banzaicloud/banzai-types/components"
 	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/apiversion"
 	"github.com/banzaicloud/pipeline/auth"
 	"github.com/banzaicloud/pipeline/cluster"
 	"github.com/banzaicloud/pipeline/config"
 	"github.com/banzaicloud/pipeline/helm"
 	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/pagination"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -71,6 +76,27 @@ func GetCommonClusterFromRequest(c *gin.Context) (cluster.CommonCluster, bool) {
 	return commonCLuster, true
 }
 
+// createClusterRequest is the create cluster request with the node pools, the GKE release channel,
+// the EKS properties, the deletion protection, the cluster-autoscaler, the network and the tags of the cluster
+type createClusterRequest struct {
+	components.CreateClusterRequest
+	NodePools          []cluster.NodePool        `json:"nodePools,omitempty"`
+	ReleaseChannel     string                    `json:"releaseChannel,omitempty"`
+	EKS                *cluster.CreateClusterEKS `json:"eks,omitempty"`
+	DeletionProtection bool                      `json:"deletionProtection,omitempty"`
+	Autoscaler         bool                      `json:"autoscaler,omitempty"`
+	// Profile is the name of the cluster profile of the organization the request is applied to
+	Profile string `json:"profile,omitempty"`
+	// Network is the VPC, the subnets and the private API endpoint of the cluster
+	Network *cluster.Network `json:"network,omitempty"`
+	// Tags are the tags of the cloud resources and the labels of the nodes of the cluster
+	Tags map[string]string `json:"tags,omitempty"`
+	// DNS is the cloud secret of the DNS records of the ingresses of the cluster
+	DNS *cluster.DNS `json:"dns,omitempty"`
+	// Monitoring installs the Prometheus and Grafana monitoring stack on the cluster
+	Monitoring bool `json:"monitoring,omitempty"`
+}
+
 // CreateCluster creates a K8S cluster in the cloud
 func CreateCluster(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateCluster})
@@ -79,9 +105,14 @@ func CreateCluster(c *gin.Context) {
 	log.Info("Cluster creation stared")
 
 	log.Debug("Bind json into CreateClusterRequest struct")
-	// bind request body to struct
-	var createClusterRequest components.CreateClusterRequest
-	if err := c.BindJSON(&createClusterRequest); err != nil {
+	// bind request body to struct, the request can be applied to a cluster profile
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	body, err := ioutil.ReadAll(c.Request.Body)
+	var request *createClusterRequest
+	if err == nil {
+		request, err = parseCreateClusterRequest(organizationID, body)
+	}
+	if err != nil {
 		log.Error(errors.Wrap(err, "Error parsing request"))
 		c.JSON(http.StatusBadRequest, components.ErrorResponse{
 			Code:    http.StatusBadRequest,
@@ -91,47 +122,32 @@ func CreateCluster(c *gin.Context) {
 		return
 	}
 	log.Debug("Parsing request succeeded")
+	createClusterRequest := request.CreateClusterRequest
+
+	if !authorizePolicies(c, auth.PolicyActionClusterCreate, map[string]string{
+		auth.PolicyAttributeCloud:            createClusterRequest.Cloud,
+		auth.PolicyAttributeLocation:         createClusterRequest.Location,
+		auth.PolicyAttributeNodeInstanceType: createClusterRequest.NodeInstanceType,
+		auth.PolicyAttributeCluster:          createClusterRequest.Name,
+	}) {
+		return
+	}
 
-	log.Info("Searching entry with name: ", createClusterRequest.Name)
-
-	// check exists cluster name
-	var existingCluster model.ClusterModel
-	database := model.GetDB()
-	database.Raw("SELECT * FROM "+model.ClusterModel.TableName(existingCluster)+" WHERE name = ?;",
-		createClusterRequest.Name).Scan(&existingCluster)
-
-	////TODO check if error handling is enough
-	//existingCluster, err := model.QueryCluster(filter)
-	//if err != nil {
-	//	log.Error(err)
-	//	c.JSON(http.StatusBadRequest, ErrorResponse{
-	//		Code:    http.StatusBadRequest,
-	//		Message: "Error parsing request",
-	//		Error:   err.Error(),
-	//	})
-	//	return
-	//}
+	// the dry run validates the request without provisioning the cluster
+	if c.Query("dryRun") == "true" {
+		validateCreateCluster(c, request, organizationID)
+		return
+	}
 
-	if existingCluster.ID != 0 {
-		// duplicated entry
-		err := fmt.Errorf("duplicate entry: %s", existingCluster.Name)
-		log.Error(err)
-		c.JSON(http.StatusBadRequest, components.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-			Error:   err.Error(),
-		})
+	if !checkClusterName(c, createClusterRequest.Name) {
 		return
 	}
 
 	log.Info("Creating new entry with cloud type: ", createClusterRequest.Cloud)
 
-	var commonCluster cluster.CommonCluster
-
 	// TODO check validation
 	// This is the common part of cluster flow
-	organizationID := auth.GetCurrentOrganization(c.Request).ID
-	commonCluster, err := cluster.CreateCommonClusterFromRequest(&createClusterRequest, organizationID)
+	commonCluster, err := newCommonCluster(request, organizationID)
 	if err != nil {
 		log.Errorf("Error during creating common cluster model: %s", err.Error())
 		c.JSON(http.StatusBadRequest, components.ErrorResponse{
@@ -141,19 +157,24 @@ func CreateCluster(c *gin.Context) {
 		})
 		return
 	}
-	// Create cluster
-	err = commonCluster.CreateCluster()
-	if err != nil {
-		log.Errorf("Error during cluster creation: %s", err.Error())
+
+	if validationErrors := setClusterProperties(commonCluster, request); len(validationErrors) != 0 {
+		message := validationErrors[0].Message
+		log.Errorf("Error setting cluster properties: %s", message)
 		c.JSON(http.StatusBadRequest, components.ErrorResponse{
 			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-			Error:   err.Error(),
+			Message: message,
+			Error:   message,
 		})
 		return
 	}
 
-	// Persist the cluster in Database
+	if !checkClusterSecret(c, commonCluster) {
+		return
+	}
+
+	// Persist the pending cluster in Database, it's created in the background
+	commonCluster.GetModel().Status = cluster.StatusPending
 	err = commonCluster.Persist()
 	if err != nil {
 		log.Errorf("Error persisting cluster in database: %s", err.Error())
@@ -172,23 +193,281 @@ func CreateCluster(c *gin.Context) {
 		cluster.UpdatePrometheusPostHook,
 		cluster.InstallHelmPostHook,
 		cluster.InstallIngressControllerPostHook,
+		cluster.InstallExternalDNSPostHook,
+		cluster.InstallCertManagerPostHook,
+		cluster.InstallMonitoringPostHook,
+		cluster.InstallClusterAutoscalerPostHook,
+		cluster.InstallTerminationHandlerPostHook,
 	}
-	go cluster.RunPostHooks(postHookFunctions, commonCluster)
+	go createCluster(commonCluster, postHookFunctions)
+	recordClusterEvent(c, commonCluster, notify.EventClusterCreated, commonCluster.GetName(), fmt.Sprintf("Cluster %s creation started on %s", commonCluster.GetName(), commonCluster.GetType()))
 
-	response, err := commonCluster.GetStatus()
+	response, err := cluster.GetStatusResponse(commonCluster)
 	if err != nil {
 		log.Errorf("Error during getting cluster status: %s", err.Error())
 		c.JSON(http.StatusBadRequest, components.ErrorResponse{
 			Code:    http.StatusBadRequest,
-			Message: "Error parsing request",
+			Message: "Error during getting cluster status",
 			Error:   err.Error(),
 		})
 		return
 	}
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusAccepted, response)
 	return
 }
 
+// newCommonCluster creates the cluster of the request, it isn't persisted
+func newCommonCluster(request *createClusterRequest, organizationID uint) (cluster.CommonCluster, error) {
+	if request.Cloud == cluster.EKS {
+		return cluster.CreateEKSClusterFromRequest(&request.CreateClusterRequest, request.EKS, organizationID)
+	}
+	return cluster.CreateCommonClusterFromRequest(&request.CreateClusterRequest, organizationID)
+}
+
+// setClusterProperties sets the properties of the request which aren't in the common create cluster request,
+// the invalid properties are returned with their fields
+func setClusterProperties(commonCluster cluster.CommonCluster, request *createClusterRequest) []cluster.ValidationError {
+	validationErrors := []cluster.ValidationError{}
+	cluster.SetDeletionProtection(commonCluster, request.DeletionProtection)
+	cluster.SetMonitoring(commonCluster, request.Monitoring)
+	if err := cluster.SetClusterAutoscaler(commonCluster, request.Autoscaler); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "autoscaler", Message: err.Error()})
+	}
+	if err := cluster.SetNodePools(commonCluster, request.NodePools); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "nodePools", Message: err.Error()})
+	}
+	if err := cluster.SetReleaseChannel(commonCluster, request.ReleaseChannel); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "releaseChannel", Message: err.Error()})
+	}
+	if err := cluster.SetNetwork(commonCluster, request.Network); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "network", Message: err.Error()})
+	}
+	if err := cluster.SetTags(commonCluster, request.Tags); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "tags", Message: err.Error()})
+	}
+	if err := setClusterDNS(commonCluster, request.DNS); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "dns", Message: err.Error()})
+	}
+	return validationErrors
+}
+
+// createCluster creates the cluster in the cloud and runs the posthooks, the progress is recorded in the status of the cluster
+func createCluster(commonCluster cluster.CommonCluster, postHookFunctions []func(commonCluster cluster.CommonCluster)) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateCluster})
+	if err := cluster.SetStatus(commonCluster, cluster.StatusCreating, ""); err != nil {
+		log.Errorf("Error during setting cluster status: %s", err.Error())
+		return
+	}
+	// the SSH key is authorized on the nodes by the creation
+	err := cluster.RunStep(commonCluster, "GenerateSSHKey", func() error {
+		return cluster.GenerateSSHKey(commonCluster)
+	})
+	if err == nil {
+		err = cluster.RunStep(commonCluster, "CreateCluster", commonCluster.CreateCluster)
+	}
+	if err == nil {
+		err = commonCluster.Persist()
+	}
+	// the async clusters are being provisioned, the posthooks need the ready cluster
+	if asyncCluster, ok := commonCluster.(cluster.AsyncCluster); ok && err == nil {
+		err = cluster.RunStep(commonCluster, "WaitForCluster", asyncCluster.WaitForCluster)
+	}
+	// the posthooks of the private clusters fail without a connection to the private endpoint
+	if err == nil && commonCluster.GetModel().PrivateEndpoint {
+		err = cluster.RunStep(commonCluster, "CheckAPIEndpoint", func() error {
+			return cluster.CheckAPIEndpoint(commonCluster)
+		})
+	}
+	if err != nil {
+		log.Errorf("Error during cluster creation: %s", err.Error())
+		setClusterStatus(commonCluster, cluster.StatusError, err.Error())
+		return
+	}
+	cluster.RunPostHooks(postHookFunctions, commonCluster)
+	setClusterStatus(commonCluster, cluster.StatusRunning, "")
+}
+
+// setClusterStatus sets the status of the cluster at the end of an operation, the error is logged
+func setClusterStatus(commonCluster cluster.CommonCluster, status, message string) {
+	if err := cluster.SetStatus(commonCluster, status, message); err != nil {
+		log.Errorf("Error during setting cluster status: %s", err.Error())
+	}
+}
+
+// abortWithStatusError responds the error of a cluster status transition, the cluster is busy with another operation
+func abortWithStatusError(c *gin.Context, err error) {
+	code := http.StatusInternalServerError
+	if _, ok := err.(*cluster.StatusTransitionError); ok {
+		code = http.StatusConflict
+	}
+	log.Errorf("Error during setting cluster status: %s", err.Error())
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: err.Error(),
+		Error:   err.Error(),
+	})
+}
+
+// GetClusterPhase returns the status of the cluster with the last error and the steps of its current operation
+func GetClusterPhase(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetClusterStatus})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	response, err := cluster.GetStatusResponse(commonCluster)
+	if err != nil {
+		log.Errorf("Error during getting cluster status: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during getting cluster status",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetClusterTags returns the tags of the cluster
+func GetClusterTags(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	c.JSON(http.StatusOK, commonCluster.GetModel().GetTags())
+}
+
+// GetClusterCost returns the estimated monthly cost of the cluster with the current prices of its cloud
+func GetClusterCost(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	cost, err := cluster.EstimateCost(commonCluster)
+	if err != nil {
+		log.Errorf("Error during estimating cluster cost: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during estimating cluster cost",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, cost)
+}
+
+// deletionConfirmationResponse is the response of the first deletion request of a cluster
+type deletionConfirmationResponse struct {
+	Status            int       `json:"status"`
+	Message           string    `json:"message"`
+	ConfirmationToken string    `json:"confirmationToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+// requestClusterDeletion responds the token confirming the deletion of the cluster
+func requestClusterDeletion(c *gin.Context, commonCluster cluster.CommonCluster) {
+	token, expiry, err := cluster.RequestDeletion(commonCluster)
+	if err == nil {
+		err = commonCluster.Persist()
+	}
+	if err != nil {
+		abortWithDeletionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deletionConfirmationResponse{
+		Status:            http.StatusOK,
+		Message:           "Confirm the deletion with the confirm query parameter",
+		ConfirmationToken: token,
+		ExpiresAt:         expiry,
+	})
+}
+
+func abortWithDeletionError(c *gin.Context, err error) {
+	log.Errorf("Error during deleting cluster: %s", err.Error())
+	code := http.StatusInternalServerError
+	switch err {
+	case cluster.ErrDeletionProtected:
+		code = http.StatusConflict
+	case cluster.ErrDeletionNotConfirmed:
+		code = http.StatusBadRequest
+	}
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: err.Error(),
+		Error:   err.Error(),
+	})
+}
+
+// deletionProtectionRequest enables or disables the deletion protection of a cluster
+type deletionProtectionRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetDeletionProtection enables or disables the deletion protection of a cluster
+func SetDeletionProtection(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	var request deletionProtectionRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	cluster.SetDeletionProtection(commonCluster, request.Enabled)
+	if err := commonCluster.Persist(); err != nil {
+		log.Errorf("Error during cluster save %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during cluster save",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// checkClusterName aborts the request if a cluster with the name exists
+func checkClusterName(c *gin.Context, name string) bool {
+	if err := validateClusterName(name); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// validateClusterName checks that the name isn't used by another cluster
+func validateClusterName(name string) error {
+	log.Info("Searching entry with name: ", name)
+
+	// check exists cluster name
+	var existingCluster model.ClusterModel
+	database := model.GetDB()
+	database.Raw("SELECT * FROM "+model.ClusterModel.TableName(existingCluster)+" WHERE name = ?;",
+		name).Scan(&existingCluster)
+
+	if existingCluster.ID != 0 {
+		// duplicated entry
+		return fmt.Errorf("duplicate entry: %s", existingCluster.Name)
+	}
+	return nil
+}
+
 // GetClusterStatus retrieves the cluster status
 func GetClusterStatus(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetClusterStatus})
@@ -208,7 +487,7 @@ func GetClusterStatus(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, response)
+	c.JSON(response.Status, response)
 	return
 }
 
@@ -254,6 +533,52 @@ func GetClusterConfig(c *gin.Context) {
 	return
 }
 
+// expiringConfigResponse is a kubeconfig with expiring credentials
+type expiringConfigResponse struct {
+	Status    int       `json:"status"`
+	Data      string    `json:"data"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// GetClusterKubeconfig generates a kubeconfig of the cluster whose credentials expire after the ttl parameter
+func GetClusterKubeconfig(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagFetchClusterConfig})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	ttl, err := cluster.KubeconfigTTL(c.Query("ttl"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid TTL",
+			Error:   err.Error(),
+		})
+		return
+	}
+	config, err := cluster.GenerateExpiringConfig(commonCluster, ttl)
+	if err != nil {
+		log.Errorf("Error during generating config: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during generating config",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	switch c.NegotiateFormat(gin.MIMEPlain, gin.MIMEJSON) {
+	case gin.MIMEJSON:
+		c.JSON(http.StatusOK, expiringConfigResponse{
+			Status:    http.StatusOK,
+			Data:      string(config.Config),
+			ExpiresAt: config.ExpiresAt,
+		})
+	default:
+		c.String(http.StatusOK, string(config.Config))
+	}
+}
+
 func GetApiEndpoint(c *gin.Context) {
 	commonCluster, ok := GetCommonClusterFromRequest(c)
 	if !ok {
@@ -292,6 +617,9 @@ func UpdateCluster(c *gin.Context) {
 	if ok != true {
 		return
 	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
 
 	if commonCluster.GetType() != updateRequest.Cloud {
 		msg := fmt.Sprintf("Stored cloud type [%s] and request cloud type [%s] not equal", commonCluster.GetType(), updateRequest.Cloud)
@@ -319,7 +647,12 @@ func UpdateCluster(c *gin.Context) {
 		return
 	}
 
-	if err := updateRequest.Validate(); err != nil {
+	// the EKS clusters are updated with the amazon properties
+	validate := updateRequest.Validate
+	if commonCluster.GetType() == cluster.EKS {
+		validate = updateRequest.UpdateClusterAmazon.Validate
+	}
+	if err := validate(); err != nil {
 		log.Errorf("Validation failed: %s", err.Error())
 		c.JSON(http.StatusBadRequest, components.ErrorResponse{
 			Code:    http.StatusBadRequest,
@@ -329,22 +662,33 @@ func UpdateCluster(c *gin.Context) {
 		return
 	}
 
-	// TODO check if validation can be applied sooner
-	err := commonCluster.UpdateCluster(updateRequest)
-	if err != nil {
-		// validation failed
-		log.Errorf("Update failed: %s", err.Error())
-		c.JSON(http.StatusBadRequest, components.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-			Error:   err.Error(),
-		})
+	if err := cluster.SetStatus(commonCluster, cluster.StatusUpdating, ""); err != nil {
+		abortWithStatusError(c, err)
 		return
 	}
-	// save the updated cluster to database
-	if err := commonCluster.Persist(); err != nil {
-		log.Errorf("Error during cluster save %s", err.Error())
-	}
+
+	go func() {
+		err := cluster.RunStep(commonCluster, "UpdateCluster", func() error {
+			return commonCluster.UpdateCluster(updateRequest)
+		})
+		if err != nil {
+			log.Errorf("Update failed: %s", err.Error())
+			setClusterStatus(commonCluster, cluster.StatusError, err.Error())
+			return
+		}
+		// save the updated cluster to database
+		if err := commonCluster.Persist(); err != nil {
+			log.Errorf("Error during cluster save %s", err.Error())
+		}
+		// the node counts are the bounds of the autoscaler on Amazon
+		if err := cluster.ConfigureClusterAutoscaler(commonCluster); err != nil {
+			log.Errorf("Error during autoscaler update: %s", err.Error())
+			setClusterStatus(commonCluster, cluster.StatusError, err.Error())
+			return
+		}
+		setClusterStatus(commonCluster, cluster.StatusRunning, "")
+	}()
+	recordClusterEvent(c, commonCluster, notify.EventClusterUpdated, commonCluster.GetName(), fmt.Sprintf("Cluster %s update started", commonCluster.GetName()))
 
 	c.JSON(http.StatusAccepted, components.UpdateClusterResponse{
 		Status: http.StatusAccepted,
@@ -358,62 +702,97 @@ func DeleteCluster(c *gin.Context) {
 	if ok != true {
 		return
 	}
+	if !authorizePolicies(c, auth.PolicyActionClusterDelete, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
 	log.Info("Delete cluster start")
 
+	// the deletion is confirmed with the token of the first request
+	token := c.Query("confirm")
+	if token == "" {
+		requestClusterDeletion(c, commonCluster)
+		return
+	}
+	if err := cluster.ValidateStatusTransition(cluster.ClusterStatus(commonCluster), cluster.StatusDeleting); err != nil {
+		abortWithStatusError(c, err)
+		return
+	}
+	if err := cluster.ConfirmDeletion(commonCluster, token); err != nil {
+		abortWithDeletionError(c, err)
+		return
+	}
+	if err := commonCluster.Persist(); err != nil {
+		log.Errorf("Error during cluster save %s", err.Error())
+	}
+	if err := cluster.SetStatus(commonCluster, cluster.StatusDeleting, ""); err != nil {
+		abortWithStatusError(c, err)
+		return
+	}
+
 	forceParam := c.DefaultQuery("force", "false")
 	force, err := strconv.ParseBool(forceParam)
 	if err != nil {
 		force = false
 	}
+	drain, _ := strconv.ParseBool(c.DefaultQuery("drain", "false"))
 
-	config, err := commonCluster.GetK8sConfig()
+	go deleteCluster(commonCluster, drain, force)
+	recordClusterEvent(c, commonCluster, notify.EventClusterDeleted, commonCluster.GetName(), fmt.Sprintf("Cluster %s deletion started", commonCluster.GetName()))
+
+	c.JSON(http.StatusAccepted, components.DeleteClusterResponse{
+		Status:     http.StatusAccepted,
+		Name:       commonCluster.GetName(),
+		Message:    "Cluster deletion started",
+		ResourceID: commonCluster.GetID(),
+	})
+	return
+}
+
+// deleteCluster drains the cluster if it's requested, deletes its deployments and deletes it from the cloud and
+// the database, the errors are ignored with force
+func deleteCluster(commonCluster cluster.CommonCluster, drain, force bool) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagDeleteCluster})
+
+	err := cluster.RunStep(commonCluster, "DeleteDeployments", func() error {
+		if drain && commonCluster.GetType() != cluster.Kubernetes {
+			if err := cluster.DrainCluster(commonCluster); err != nil {
+				return errors.Wrap(err, "error during draining cluster")
+			}
+		}
+		config, err := commonCluster.GetK8sConfig()
+		if err != nil {
+			return errors.Wrap(err, "error during getting kubeconfig")
+		}
+		// the deployments of the imported clusters are kept, the cluster is only removed from Pipeline
+		if commonCluster.GetType() != cluster.Kubernetes {
+			if err := helm.DeleteAllDeployment(config); err != nil {
+				log.Errorf("Problem deleting deployment: %s", err)
+			}
+		}
+		return nil
+	})
+	if err == nil || force {
+		err = cluster.RunStep(commonCluster, "DeleteCluster", commonCluster.DeleteCluster)
+	}
 	if err != nil && !force {
-		log.Errorf("Error during getting kubeconfig: %s", err.Error())
-		c.JSON(http.StatusBadRequest, components.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: "Error during getting kubeconfig",
-			Error:   err.Error(),
-		})
+		log.Errorf("Error during delete cluster: %s", err.Error())
+		setClusterStatus(commonCluster, cluster.StatusError, err.Error())
 		return
 	}
 
-	err = helm.DeleteAllDeployment(config)
-	if err != nil {
-		log.Errorf("Problem deleting deployment: %s", err)
+	if err := cluster.DeleteSSHKey(commonCluster); err != nil {
+		log.Errorf("Error during deleting the SSH key: %s", err.Error())
 	}
 
-	err = commonCluster.DeleteCluster()
-	if err != nil && !force {
-		log.Errorf(errors.Wrap(err, "Error during delete cluster").Error())
-		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Error during delete cluster",
-			Error:   err.Error(),
-		})
+	if err := commonCluster.DeleteFromDatabase(); err != nil {
+		log.Errorf("Error during delete cluster from database: %s", err.Error())
+		setClusterStatus(commonCluster, cluster.StatusError, err.Error())
 		return
 	}
-	deleteName := commonCluster.GetName()
-	deleteId := commonCluster.GetID()
-	err = commonCluster.DeleteFromDatabase()
-	if err != nil && !force {
-		log.Errorf(errors.Wrap(err, "Error during delete cluster from database").Error())
-		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Error during delete cluster",
-			Error:   err.Error(),
-		})
-	}
+	cluster.NotifyProgress(commonCluster.GetID())
 
 	// Asyncron update prometheus
-	go cluster.UpdatePrometheus()
-
-	c.JSON(http.StatusAccepted, components.DeleteClusterResponse{
-		Status:     http.StatusAccepted,
-		Name:       deleteName,
-		Message:    "Cluster deleted successfully",
-		ResourceID: deleteId,
-	})
-	return
+	cluster.UpdatePrometheus()
 }
 
 // FetchClusters fetches all the K8S clusters from the cloud
@@ -421,11 +800,22 @@ func FetchClusters(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetCluster})
 	log.Info("Fetching clusters")
 
+	// the clusters are filtered with tag=key or tag=key:value parameters
+	tagFilters := c.QueryArray("tag")
+	page, err := pagination.Parse(c, clusterPagination)
+	if err != nil {
+		pagination.BadRequest(c, err)
+		return
+	}
+
 	var clusters []model.ClusterModel //TODO change this to CommonClusterStatus
-	db := model.GetDB()
 	organization := auth.GetCurrentOrganization(c.Request)
-	organization.Name = ""
-	err := db.Model(organization).Related(&clusters).Error
+	query := page.Query(model.GetDB().Where("organization_id = ?", organization.ID))
+	if len(tagFilters) != 0 {
+		// the tags are matched after loading the clusters, the page is cut after the matching
+		query = query.Limit(-1)
+	}
+	err = query.Find(&clusters).Error
 	if err != nil {
 		log.Errorf("Error listing clusters: %s", err.Error())
 		c.JSON(http.StatusBadRequest, components.ErrorResponse{
@@ -435,8 +825,20 @@ func FetchClusters(c *gin.Context) {
 		})
 		return
 	}
-	response := make([]components.GetClusterStatusResponse, 0)
+	matching := clusters[:0]
 	for _, cl := range clusters {
+		if cluster.MatchTags(cl.GetTags(), tagFilters) {
+			matching = append(matching, cl)
+		}
+	}
+	length, next := page.Next(len(matching), func(i int) (interface{}, interface{}) {
+		return clusterSortValue(&matching[i], page.Sort), matching[i].ID
+	})
+	pagination.SetNext(c, next)
+
+	response := make([]components.GetClusterStatusResponse, 0)
+	responseV2 := clustersResponseV2{Clusters: []clusterResponseV2{}, NextCursor: next}
+	for _, cl := range matching[:length] {
 		commonCluster, err := cluster.GetCommonClusterFromModel(&cl)
 		if err == nil {
 			status, err := commonCluster.GetStatus()
@@ -446,14 +848,75 @@ func FetchClusters(c *gin.Context) {
 			} else {
 				log.Debugf("Append cluster to list: %s", commonCluster.GetName())
 				response = append(response, *status)
+				responseV2.Clusters = append(responseV2.Clusters, newClusterResponseV2(commonCluster, status))
 			}
 		} else {
 			log.Errorf("convert ClusterModel to CommonCluster failed: %s ", err.Error())
 		}
 	}
+	if !apiversion.IsV1(c) {
+		c.JSON(http.StatusOK, responseV2)
+		return
+	}
 	c.JSON(http.StatusOK, response)
 }
 
+// clusterResponseV2 is a cluster in the v2 API with the status of the cluster in place of the HTTP status
+type clusterResponseV2 struct {
+	ID               uint   `json:"id"`
+	Name             string `json:"name"`
+	Cloud            string `json:"cloud"`
+	Location         string `json:"location"`
+	NodeInstanceType string `json:"nodeInstanceType,omitempty"`
+	Status           string `json:"status"`
+	StatusMessage    string `json:"statusMessage,omitempty"`
+}
+
+type clustersResponseV2 struct {
+	Clusters   []clusterResponseV2 `json:"clusters"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+// clusterPagination are the fields the clusters can be sorted and filtered by
+var clusterPagination = pagination.Options{
+	Fields: map[string]pagination.Field{
+		"name":      {Column: "name", Sort: true, Filter: true},
+		"cloud":     {Column: "cloud", Sort: true, Filter: true},
+		"location":  {Column: "location", Sort: true, Filter: true},
+		"status":    {Column: "status", Sort: true, Filter: true},
+		"createdAt": {Column: "created_at", Sort: true},
+	},
+}
+
+// clusterSortValue returns the value of the sort field of the cluster
+func clusterSortValue(cl *model.ClusterModel, sort string) interface{} {
+	switch sort {
+	case "name":
+		return cl.Name
+	case "cloud":
+		return cl.Cloud
+	case "location":
+		return cl.Location
+	case "status":
+		return cl.Status
+	case "createdAt":
+		return cl.CreatedAt
+	}
+	return nil
+}
+
+func newClusterResponseV2(commonCluster cluster.CommonCluster, status *components.GetClusterStatusResponse) clusterResponseV2 {
+	return clusterResponseV2{
+		ID:               status.ResourceID,
+		Name:             status.Name,
+		Cloud:            status.Cloud,
+		Location:         status.Location,
+		NodeInstanceType: status.NodeInstanceType,
+		Status:           cluster.ClusterStatus(commonCluster),
+		StatusMessage:    commonCluster.GetModel().StatusMessage,
+	}
+}
+
 // FetchCluster fetch a K8S cluster in the cloud
 func FetchCluster(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetClusterStatus})
@@ -472,7 +935,11 @@ func FetchCluster(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, status)
+	if !apiversion.IsV1(c) {
+		c.JSON(http.StatusOK, newClusterResponseV2(commonCluster, status))
+		return
+	}
+	c.JSON(status.Status, status)
 }
 
 //Status
@@ -502,3 +969,30 @@ func FetchCluster(c *gin.Context) {
 //
 //}
 //
+
+// GetAKSVersions lists the Kubernetes versions AKS supports in the location with the Azure secret
+func GetAKSVersions(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetAKSVersions"})
+	location := c.Query("location")
+	secretID := c.Query("secret_id")
+	if location == "" || secretID == "" {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "location and secret_id are required",
+			Error:   "location and secret_id are required",
+		})
+		return
+	}
+	organizationID := strconv.FormatUint(uint64(auth.GetCurrentOrganization(c.Request).ID), 10)
+	versions, err := cluster.GetAKSVersions(organizationID, secretID, location)
+	if err != nil {
+		log.Errorf("Error listing AKS versions: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error listing AKS versions",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterProfileRequest is a named cluster profile of the organization, Cluster is the create cluster request
+// of the clusters of the profile without the cluster name
+type clusterProfileRequest struct {
+	Name    string          `json:"name" binding:"required"`
+	Cluster json.RawMessage `json:"cluster" binding:"required"`
+}
+
+// clusterProfileResponse is a cluster profile with its create cluster request
+type clusterProfileResponse struct {
+	model.ClusterProfileModel
+	Cluster json.RawMessage `json:"cluster"`
+}
+
+func newClusterProfileResponse(profile model.ClusterProfileModel) clusterProfileResponse {
+	return clusterProfileResponse{ClusterProfileModel: profile, Cluster: json.RawMessage(profile.Spec)}
+}
+
+// validateClusterProfile validates the create cluster request of a profile, the cloud is required
+// and the name of the cluster is given when the cluster is created
+func validateClusterProfile(spec json.RawMessage) (*createClusterRequest, error) {
+	var request createClusterRequest
+	if err := json.Unmarshal(spec, &request); err != nil {
+		return nil, err
+	}
+	if request.Name != "" {
+		return nil, fmt.Errorf("cluster profiles have no cluster name")
+	}
+	if request.Profile != "" {
+		return nil, fmt.Errorf("cluster profiles can't reference other profiles")
+	}
+	switch request.Cloud {
+	case constants.Amazon, constants.Azure, constants.Google, cluster.EKS:
+	default:
+		return nil, constants.ErrorNotSupportedCloudType
+	}
+	if err := cluster.ValidateNodePools(request.NodePools); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// parseCreateClusterRequest parses the create cluster request, the fields of the request override the fields
+// of the create cluster request of the referenced profile
+func parseCreateClusterRequest(organizationID uint, body []byte) (*createClusterRequest, error) {
+	var reference struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.Unmarshal(body, &reference); err != nil {
+		return nil, err
+	}
+	if reference.Profile == "" {
+		return mergeCreateClusterRequest(nil, body)
+	}
+	profile, err := model.GetClusterProfile(organizationID, reference.Profile)
+	if model.IsErrorGormNotFound(err) {
+		return nil, fmt.Errorf("cluster profile %s not found", reference.Profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mergeCreateClusterRequest([]byte(profile.Spec), body)
+}
+
+// mergeCreateClusterRequest decodes the request on top of the spec of a profile and validates the result, the
+// objects of the spec are merged with the objects of the request and the lists are replaced
+func mergeCreateClusterRequest(spec, body []byte) (*createClusterRequest, error) {
+	var request createClusterRequest
+	if spec != nil {
+		if err := json.Unmarshal(spec, &request); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ListOrganizationClusterProfiles lists the cluster profiles of the organization
+func ListOrganizationClusterProfiles(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetClusterProfile})
+	profiles, err := model.ListClusterProfiles(auth.GetCurrentOrganization(c.Request).ID)
+	if err != nil {
+		log.Errorf("Error listing cluster profiles: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing cluster profiles",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := make([]clusterProfileResponse, 0, len(profiles))
+	for _, profile := range profiles {
+		response = append(response, newClusterProfileResponse(profile))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetOrganizationClusterProfile sends back a cluster profile of the organization
+func GetOrganizationClusterProfile(c *gin.Context) {
+	profile, err := model.GetClusterProfile(auth.GetCurrentOrganization(c.Request).ID, c.Param(nameKey))
+	if err != nil {
+		sendBackGetProfileErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newClusterProfileResponse(*profile))
+}
+
+// CreateOrganizationClusterProfile saves a new cluster profile of the organization
+func CreateOrganizationClusterProfile(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagSetClusterProfile})
+	var request clusterProfileRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	if _, err := model.GetClusterProfile(organizationID, request.Name); err == nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Cluster profile with the given name already exists",
+			Error:   "Cluster profile with the given name already exists",
+		})
+		return
+	}
+	profile := &model.ClusterProfileModel{OrganizationID: organizationID, Name: request.Name}
+	saveClusterProfile(c, profile, request.Cluster, http.StatusCreated)
+}
+
+// UpdateOrganizationClusterProfile replaces the create cluster request of a cluster profile, the clusters created
+// with the profile before aren't changed
+func UpdateOrganizationClusterProfile(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateClusterProfile})
+	profile, err := model.GetClusterProfile(auth.GetCurrentOrganization(c.Request).ID, c.Param(nameKey))
+	if err != nil {
+		sendBackGetProfileErrorResponse(c, err)
+		return
+	}
+	// the name of the profile is optional, the body isn't validated by the binding
+	var request clusterProfileRequest
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &request)
+	}
+	if err == nil && request.Name != "" && request.Name != profile.Name {
+		err = fmt.Errorf("cluster profile %s can't be renamed", profile.Name)
+	}
+	if err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	saveClusterProfile(c, profile, request.Cluster, http.StatusOK)
+}
+
+// saveClusterProfile validates the create cluster request of the profile and saves the profile
+func saveClusterProfile(c *gin.Context, profile *model.ClusterProfileModel, spec json.RawMessage, code int) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagSetClusterProfile})
+	request, err := validateClusterProfile(spec)
+	if err != nil {
+		log.Errorf("Invalid cluster profile %s: %s", profile.Name, err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid cluster profile",
+			Error:   err.Error(),
+		})
+		return
+	}
+	profile.Cloud = request.Cloud
+	profile.Location = request.Location
+	profile.Spec = string(spec)
+	if err := profile.Save(); err != nil {
+		log.Errorf("Error during persist cluster profile: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during persist cluster profile",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(code, newClusterProfileResponse(*profile))
+}
+
+// DeleteOrganizationClusterProfile deletes a cluster profile of the organization, the clusters created
+// with the profile are kept
+func DeleteOrganizationClusterProfile(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagDeleteClusterProfile})
+	profile, err := model.GetClusterProfile(auth.GetCurrentOrganization(c.Request).ID, c.Param(nameKey))
+	if err != nil {
+		sendBackGetProfileErrorResponse(c, err)
+		return
+	}
+	if err := profile.Delete(); err != nil {
+		log.Errorf("Error during profile delete: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during profile delete",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusOK)
+}
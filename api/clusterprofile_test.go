@@ -0,0 +1,74 @@
+package api
+
+import (
+	"testing"
+)
+
+const clusterProfileSpec = `{
+	"cloud": "azure",
+	"location": "westeurope",
+	"nodeInstanceType": "Standard_D2_v2",
+	"secret_id": "secret1",
+	"autoscaler": true,
+	"nodePools": [{"name": "pool1", "count": 3}],
+	"properties": {"azure": {"node": {"resourceGroup": "rg1", "agentCount": 3, "agentName": "agent", "kubernetesVersion": "1.9.6"}}}
+}`
+
+func TestMergeCreateClusterRequest(t *testing.T) {
+
+	body := `{
+		"name": "cluster1",
+		"profile": "prod-small",
+		"nodePools": [{"name": "pool1", "count": 5}],
+		"properties": {"azure": {"node": {"kubernetesVersion": "1.10.3"}}}
+	}`
+	request, err := mergeCreateClusterRequest([]byte(clusterProfileSpec), []byte(body))
+	if err != nil {
+		t.Fatalf("Error during mergeCreateClusterRequest: %s", err.Error())
+	}
+	if request.Name != "cluster1" || request.Cloud != "azure" || request.Location != "westeurope" || !request.Autoscaler {
+		t.Errorf("Expected the fields of the profile and the request, got: %#v", request)
+	}
+	if len(request.NodePools) != 1 || request.NodePools[0].Count != 5 {
+		t.Errorf("Expected the node pools of the request, got: %v", request.NodePools)
+	}
+	node := request.Properties.CreateClusterAzure.Node
+	if node.KubernetesVersion != "1.10.3" || node.AgentName != "agent" || node.ResourceGroup != "rg1" {
+		t.Errorf("Expected the merged node properties, got: %#v", node)
+	}
+
+	if _, err := mergeCreateClusterRequest([]byte(clusterProfileSpec), []byte(`{"profile": "prod-small"}`)); err == nil {
+		t.Errorf("Expected error, the cluster name is required")
+	}
+}
+
+func TestValidateClusterProfile(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		spec        string
+		expectError bool
+	}{
+		{name: "profile", spec: clusterProfileSpec},
+		{name: "cluster name", spec: `{"name": "cluster1", "cloud": "azure"}`, expectError: true},
+		{name: "nested profile", spec: `{"profile": "prod", "cloud": "azure"}`, expectError: true},
+		{name: "missing cloud", spec: `{"location": "westeurope"}`, expectError: true},
+		{name: "invalid node pools", spec: `{"cloud": "azure", "nodePools": [{"name": "pool1", "count": 0}]}`, expectError: true},
+		{name: "invalid JSON", spec: `{"cloud": `, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validateClusterProfile([]byte(tc.spec))
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during validateClusterProfile: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// InjectSecretRequest describes a Pipeline secret to be written into a Kubernetes Secret
+type InjectSecretRequest struct {
+	SecretID string `json:"secretId" binding:"required"`
+	// Name of the Kubernetes Secret
+	Name string `json:"name" binding:"required"`
+	// Namespace of the Kubernetes Secret, the namespace of the deployments by default
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// injectSecrets writes the Pipeline secrets into the cluster, it responds with the error of the first failed injection
+func injectSecrets(c *gin.Context, commonCluster cluster.CommonCluster, requests []InjectSecretRequest) ([]model.SecretInjectionModel, bool) {
+	var injections []model.SecretInjectionModel
+	if len(requests) > 0 && !auth.HasScope(auth.GetCurrentScopes(c.Request), auth.ScopeResourceSecret+":read") {
+		c.AbortWithStatusJSON(http.StatusForbidden, components.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Need more privileges",
+			Error:   "access token has no \"secret:read\" scope",
+		})
+		return nil, false
+	}
+	for _, request := range requests {
+		if request.Namespace == "" {
+			request.Namespace = helm.DefaultNamespace
+		}
+		injection, err := cluster.InjectSecret(commonCluster, request.SecretID, request.Namespace, request.Name)
+		if err != nil {
+			log.Errorf("Error during injecting secret %s: %s", request.SecretID, err.Error())
+			code := http.StatusBadRequest
+			if err == secret.ErrSecretNotFound {
+				code = http.StatusNotFound
+			}
+			c.AbortWithStatusJSON(code, components.ErrorResponse{
+				Code:    code,
+				Message: "Error during injecting secret",
+				Error:   err.Error(),
+			})
+			return nil, false
+		}
+		injections = append(injections, *injection)
+	}
+	return injections, true
+}
+
+// InjectClusterSecret writes a Pipeline secret into a Kubernetes Secret of the cluster,
+// the Kubernetes Secret is updated with the later versions of the Pipeline secret
+func InjectClusterSecret(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Inject Secret"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if !ok {
+		return
+	}
+	var request InjectSecretRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during binding",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if injections, ok := injectSecrets(c, commonCluster, []InjectSecretRequest{request}); ok {
+		c.JSON(http.StatusCreated, injections[0])
+	}
+}
+
+// ListClusterSecrets returns the Pipeline secrets injected into the cluster
+func ListClusterSecrets(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "List Injected Secrets"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if !ok {
+		return
+	}
+	injections, err := cluster.ListInjectedSecrets(commonCluster)
+	if err != nil {
+		log.Errorf("Error during listing injected secrets: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing injected secrets",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, injections)
+}
+
+// RemoveClusterSecret deletes an injected Kubernetes Secret (in the namespace of the ?namespace= parameter) from the cluster
+func RemoveClusterSecret(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Remove Injected Secret"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if !ok {
+		return
+	}
+	namespace := c.DefaultQuery("namespace", helm.DefaultNamespace)
+	err := cluster.RemoveInjectedSecret(commonCluster, namespace, c.Param("name"))
+	if model.IsErrorGormNotFound(err) {
+		c.AbortWithStatusJSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Injected secret not found",
+			Error:   err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Errorf("Error during removing injected secret: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during removing injected secret",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ListDatabases lists the databases of the applications of the cluster
+func ListDatabases(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	databases, err := cluster.ListDatabases(commonCluster)
+	if err != nil {
+		databaseError(c, "Error listing databases", err)
+		return
+	}
+	c.JSON(http.StatusOK, databases)
+}
+
+// CreateDatabase starts the creation of a database for the applications of the cluster, the database which
+// already exists is sent back unchanged
+func CreateDatabase(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateDeployment})
+	var request cluster.DatabaseRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	database, created, err := cluster.EnsureDatabase(commonCluster, auth.GetCurrentActor(c), request)
+	if err != nil {
+		databaseError(c, "Error creating database", err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, database)
+		return
+	}
+	c.JSON(http.StatusAccepted, database)
+}
+
+// GetDatabase sends back a database of the cluster with its status
+func GetDatabase(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	database, err := cluster.GetDatabase(commonCluster, c.Param("name"))
+	if err != nil {
+		databaseError(c, "Error getting database", err)
+		return
+	}
+	c.JSON(http.StatusOK, database)
+}
+
+// DeleteDatabase deletes a database of the cluster with its connection secret
+func DeleteDatabase(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteDatabase(commonCluster, c.Param("name")); err != nil {
+		databaseError(c, "Error deleting database", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// provisionDatabases ensures the databases of a deployment, the existing databases of the cluster are reused
+func provisionDatabases(c *gin.Context, commonCluster cluster.CommonCluster, requests []cluster.DatabaseRequest) bool {
+	for _, request := range requests {
+		if _, _, err := cluster.EnsureDatabase(commonCluster, auth.GetCurrentActor(c), request); err != nil {
+			databaseError(c, "Error creating database", err)
+			return false
+		}
+	}
+	return true
+}
+
+// databaseError responds the error of a database operation
+func databaseError(c *gin.Context, message string, err error) {
+	code := http.StatusBadRequest
+	if _, ok := err.(cluster.DatabaseNotFoundError); ok || err == secret.ErrSecretNotFound {
+		code = http.StatusNotFound
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	c.AbortWithStatusJSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetClusterDNS sends back the DNS zone and the domain of the ingress hosts of the cluster
+func GetClusterDNS(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetClusterDNS"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	dns, err := cluster.GetClusterDNS(commonCluster)
+	if err != nil {
+		log.Errorf("Error getting cluster DNS: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting cluster DNS",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if dns == nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster has no DNS",
+			Error:   "The cluster has no DNS",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, dns)
+}
+
+// setClusterDNS enables the DNS automation of the cluster in the domain of its organization
+func setClusterDNS(commonCluster cluster.CommonCluster, dns *cluster.DNS) error {
+	if dns == nil {
+		return cluster.SetDNS(commonCluster, nil, "")
+	}
+	var organization auth.Organization
+	if err := model.GetDB().First(&organization, commonCluster.GetOrg()).Error; err != nil {
+		return err
+	}
+	return cluster.SetDNS(commonCluster, dns, organization.Name)
+}
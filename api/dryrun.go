@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// dryRunResponse is the result of the validation of a create cluster request, the request is valid without errors,
+// the cost is the estimated cost of the cluster if the prices are known
+type dryRunResponse struct {
+	Valid  bool                      `json:"valid"`
+	Errors []cluster.ValidationError `json:"errors"`
+	Cost   *cluster.CostEstimate     `json:"cost,omitempty"`
+}
+
+func newDryRunResponse(validationErrors []cluster.ValidationError, cost *cluster.CostEstimate) dryRunResponse {
+	return dryRunResponse{Valid: len(validationErrors) == 0, Errors: validationErrors, Cost: cost}
+}
+
+// validateCreateCluster runs every check of the cluster creation and sends back the failed ones, nothing is
+// persisted or provisioned. The cloud isn't checked without valid credentials.
+func validateCreateCluster(c *gin.Context, request *createClusterRequest, organizationID uint) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateCluster})
+	log.Infof("Validating the creation of cluster %s", request.Name)
+
+	validationErrors := []cluster.ValidationError{}
+	if err := validateClusterName(request.Name); err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "name", Message: err.Error()})
+	}
+	commonCluster, err := newCommonCluster(request, organizationID)
+	if err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "properties", Message: err.Error()})
+		c.JSON(http.StatusOK, newDryRunResponse(validationErrors, nil))
+		return
+	}
+	validationErrors = append(validationErrors, setClusterProperties(commonCluster, request)...)
+
+	item, err := verifyClusterSecret(commonCluster)
+	if err == nil && item.Status == secret.StatusInvalid {
+		err = errors.Errorf("invalid cloud credentials: %s", item.StatusMessage)
+	}
+	var cost *cluster.CostEstimate
+	if err != nil {
+		validationErrors = append(validationErrors, cluster.ValidationError{Field: "secretId", Message: err.Error()})
+	} else {
+		validationErrors = append(validationErrors, cluster.ValidateCreation(commonCluster)...)
+		// the estimation isn't a check of the creation, the response has no cost without the prices
+		if cost, err = cluster.EstimateCost(commonCluster); err != nil {
+			log.Warnf("Error estimating the cost of cluster %s: %s", request.Name, err.Error())
+		}
+	}
+	log.Infof("Creation of cluster %s has %d validation errors", request.Name, len(validationErrors))
+	c.JSON(http.StatusOK, newDryRunResponse(validationErrors, cost))
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// etcdSnapshotPolicyRequest describes the schedule, the S3 bucket and the retention of the etcd snapshots
+type etcdSnapshotPolicyRequest struct {
+	Schedule   string `json:"schedule" binding:"required"`
+	TimeZone   string `json:"timeZone"`
+	Bucket     string `json:"bucket" binding:"required"`
+	Region     string `json:"region" binding:"required"`
+	Prefix     string `json:"prefix"`
+	SecretID   string `json:"secretId" binding:"required"`
+	Retention  int    `json:"retention"`
+	MaxAgeDays int    `json:"maxAgeDays"`
+}
+
+// GetEtcdSnapshotPolicy sends back the etcd snapshot policy of the cluster
+func GetEtcdSnapshotPolicy(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	policy, err := model.GetEtcdSnapshotPolicy(commonCluster.GetID())
+	if err != nil {
+		etcdSnapshotError(c, "Error getting etcd snapshot policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetEtcdSnapshotPolicy creates or replaces the etcd snapshot policy of the cluster
+func SetEtcdSnapshotPolicy(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	var request etcdSnapshotPolicyRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	policy := &model.EtcdSnapshotPolicyModel{
+		Schedule:   request.Schedule,
+		TimeZone:   request.TimeZone,
+		Bucket:     request.Bucket,
+		Region:     request.Region,
+		Prefix:     request.Prefix,
+		SecretID:   request.SecretID,
+		Retention:  request.Retention,
+		MaxAgeDays: request.MaxAgeDays,
+	}
+	if err := cluster.SetEtcdSnapshotPolicy(commonCluster, policy); err != nil {
+		etcdSnapshotError(c, "Error setting etcd snapshot policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteEtcdSnapshotPolicy removes the etcd snapshot policy of the cluster, the snapshots are kept in the bucket
+func DeleteEtcdSnapshotPolicy(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := model.DeleteEtcdSnapshotPolicy(commonCluster.GetID()); err != nil {
+		log.Errorf("Error during deleting etcd snapshot policy: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during deleting etcd snapshot policy",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListEtcdSnapshots lists the etcd snapshots of the cluster, the newest is the first
+func ListEtcdSnapshots(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	snapshots, err := cluster.ListEtcdSnapshots(commonCluster)
+	if err != nil {
+		etcdSnapshotError(c, "Error listing etcd snapshots", err)
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// CreateEtcdSnapshot takes an on-demand etcd snapshot of the cluster, the retention of the policy is applied
+func CreateEtcdSnapshot(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	snapshot, err := cluster.TakeEtcdSnapshot(commonCluster)
+	if err != nil {
+		etcdSnapshotError(c, "Error taking etcd snapshot", err)
+		return
+	}
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// GetEtcdSnapshot sends back an etcd snapshot of the cluster
+func GetEtcdSnapshot(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	snapshot, err := cluster.GetEtcdSnapshot(commonCluster, c.Param("name"))
+	if err != nil {
+		etcdSnapshotError(c, "Error getting etcd snapshot", err)
+		return
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// DeleteEtcdSnapshot deletes an etcd snapshot of the cluster from the bucket
+func DeleteEtcdSnapshot(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteEtcdSnapshot(commonCluster, c.Param("name")); err != nil {
+		etcdSnapshotError(c, "Error deleting etcd snapshot", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RestoreEtcdSnapshot restores an etcd snapshot on the master of the cluster in the background, the cluster
+// is updating until the control plane is back
+func RestoreEtcdSnapshot(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	name := c.Param("name")
+	if _, err := cluster.CheckEtcdSnapshots(commonCluster); err != nil {
+		etcdSnapshotError(c, "Error restoring etcd snapshot", err)
+		return
+	}
+	if _, err := cluster.GetEtcdSnapshot(commonCluster, name); err != nil {
+		etcdSnapshotError(c, "Error restoring etcd snapshot", err)
+		return
+	}
+	updateClusterInBackground(c, commonCluster, func() error {
+		return cluster.RestoreEtcdSnapshot(commonCluster, name)
+	})
+}
+
+// etcdSnapshotError responds the error of an etcd snapshot operation, the missing policies and snapshots are
+// not found
+func etcdSnapshotError(c *gin.Context, message string, err error) {
+	code := http.StatusBadRequest
+	if _, ok := err.(*cluster.EtcdSnapshotNotFoundError); ok {
+		code = http.StatusNotFound
+	} else if model.IsErrorGormNotFound(err) {
+		code, err = http.StatusNotFound, errors.New("the cluster has no etcd snapshot policy")
+	}
+	log.Errorf("%s: %s", message, err.Error())
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
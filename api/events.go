@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Pagination of the events
+const (
+	defaultEventLimit = 50
+	maxEventLimit     = 500
+)
+
+// EventsResponse is the paginated API response of ListEvents
+type EventsResponse struct {
+	Events []model.EventModel `json:"events"`
+	Page   int                `json:"page"`
+	Limit  int                `json:"limit"`
+	Total  int                `json:"total"`
+	// NextCursor is the cursor of the next page in v2, the cursor is in the X-Next-Cursor header in v1
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// eventPagination are the fields the events can be sorted and filtered by besides the filter query parameters
+var eventPagination = pagination.Options{
+	Fields: map[string]pagination.Field{
+		"time":      {Column: "time", Sort: true},
+		"type":      {Column: "type", Filter: true},
+		"clusterId": {Column: "cluster_id", Filter: true},
+	},
+	DefaultSort:  "-time",
+	DefaultLimit: defaultEventLimit,
+	MaxLimit:     maxEventLimit,
+}
+
+// ListEvents lists the events of the organization, the newest first, filtered by the type, clusterId, since and
+// until query parameters and paginated by the page and limit or by the cursor query parameters
+func ListEvents(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListEvents"})
+	filter, err := parseEventFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid event filter",
+			Error:   err.Error(),
+		})
+		return
+	}
+	page, err := pagination.Parse(c, eventPagination)
+	if err != nil {
+		pagination.BadRequest(c, err)
+		return
+	}
+	events, total, err := model.ListEvents(auth.GetCurrentOrganization(c.Request).ID, filter, page)
+	if err != nil {
+		log.Errorf("Error listing events: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing events",
+			Error:   err.Error(),
+		})
+		return
+	}
+	length, next := page.Next(len(events), func(i int) (interface{}, interface{}) {
+		return events[i].Time, events[i].ID
+	})
+	pagination.SetNext(c, next)
+	response := EventsResponse{Events: events[:length], Page: filter.Page, Limit: filter.Limit, Total: total}
+	if !apiversion.IsV1(c) {
+		response.NextCursor = next
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// parseEventFilter parses the filter of the events, the types are comma separated and the times are RFC3339
+func parseEventFilter(query url.Values) (model.EventFilter, error) {
+	filter := model.EventFilter{Page: 1, Limit: defaultEventLimit}
+	for _, types := range query["type"] {
+		for _, eventType := range strings.Split(types, ",") {
+			if !isEventType(eventType) {
+				return filter, fmt.Errorf("unknown event type: %q", eventType)
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+	if value := query.Get("clusterId"); value != "" {
+		clusterID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid clusterId: %q", value)
+		}
+		filter.ClusterID = uint(clusterID)
+	}
+	for name, field := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %q", name, value)
+			}
+			*field = parsed
+		}
+	}
+	for name, field := range map[string]*int{"page": &filter.Page, "limit": &filter.Limit} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				return filter, fmt.Errorf("invalid %s: %q", name, value)
+			}
+			*field = parsed
+		}
+	}
+	if filter.Limit > maxEventLimit {
+		return filter, fmt.Errorf("the limit must be at most %d", maxEventLimit)
+	}
+	return filter, nil
+}
+
+// isEventType checks whether the type is a recorded event type
+func isEventType(eventType string) bool {
+	for _, t := range notify.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// recordClusterEvent records an event of a resource of the cluster by the actor of the request
+func recordClusterEvent(c *gin.Context, commonCluster cluster.CommonCluster, eventType, resource, message string) {
+	notify.RecordEvent(model.EventModel{
+		OrganizationID: commonCluster.GetOrg(),
+		Type:           eventType,
+		Actor:          auth.GetCurrentActor(c),
+		ClusterID:      commonCluster.GetID(),
+		Resource:       resource,
+		Message:        message,
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseEventFilter(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		query       string
+		types       []string
+		clusterID   uint
+		page        int
+		limit       int
+		expectError bool
+	}{
+		{name: "defaults", query: "", page: 1, limit: defaultEventLimit},
+		{name: "types", query: "type=cluster.created,cluster.deleted&type=token.revoked", types: []string{"cluster.created", "cluster.deleted", "token.revoked"}, page: 1, limit: defaultEventLimit},
+		{name: "cluster and page", query: "clusterId=7&page=3&limit=20", clusterID: 7, page: 3, limit: 20},
+		{name: "time range", query: "since=2018-06-01T00:00:00Z&until=2018-06-02T00:00:00Z", page: 1, limit: defaultEventLimit},
+		{name: "unknown type", query: "type=cluster.exploded", expectError: true},
+		{name: "invalid cluster", query: "clusterId=abc", expectError: true},
+		{name: "invalid since", query: "since=yesterday", expectError: true},
+		{name: "zero page", query: "page=0", expectError: true},
+		{name: "limit too high", query: "limit=1000", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tc.query)
+			filter, err := parseEventFilter(query)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during parseEventFilter: %s", err.Error())
+			}
+			if !reflect.DeepEqual(filter.Types, tc.types) || filter.ClusterID != tc.clusterID || filter.Page != tc.page || filter.Limit != tc.limit {
+				t.Errorf("Unexpected filter: %#v", filter)
+			}
+			if (query.Get("since") != "") == filter.Since.IsZero() || (query.Get("until") != "") == filter.Until.IsZero() {
+				t.Errorf("Expected the time range, got: %#v", filter)
+			}
+		})
+	}
+}
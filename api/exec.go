@@ -0,0 +1,337 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Length of the session log of a cluster
+const (
+	defaultPodSessionLimit = 50
+	maxPodSessionLimit     = 500
+)
+
+// execQueryParameters are the query parameters of the exec requests passed to the API server
+var execQueryParameters = []string{"command", "container", "stdin", "stdout", "stderr", "tty"}
+
+// ExecPod proxies a WebSocket exec session (eg.: kubectl exec) to a container of a pod of the cluster, the
+// command, container, stdin, stdout, stderr and tty query parameters are passed to the API server
+func ExecPod(c *gin.Context) {
+	proxyPodSession(c, helm.PodExec)
+}
+
+// PortForwardPod proxies a WebSocket port-forward session to the ports query parameter of a pod of the cluster
+func PortForwardPod(c *gin.Context) {
+	proxyPodSession(c, helm.PodPortForward)
+}
+
+// proxyPodSession opens the exec or port-forward session and pipes the WebSocket connection of the client to
+// the API server of the cluster until either side closes it, the session is audited
+func proxyPodSession(c *gin.Context, kind string) {
+	log := logger.WithFields(logrus.Fields{"tag": "ProxyPodSession"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !isWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "WebSocket upgrade required",
+			Error:   "the request must upgrade the connection to the WebSocket protocol",
+		})
+		return
+	}
+	if !isAllowedOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, components.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Forbidden origin",
+			Error:   fmt.Sprintf("WebSocket sessions can't be opened from %s", c.Request.Header.Get("Origin")),
+		})
+		return
+	}
+	session, query, err := newPodSession(c, commonCluster, kind)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid session request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeNamespace] = session.Namespace
+	if !authorizePolicies(c, auth.PolicyActionClusterExec, attributes) {
+		return
+	}
+	kubeConfig, ok := clusterK8sConfig(c, commonCluster)
+	if !ok {
+		return
+	}
+
+	upstream, err := helm.DialPod(kubeConfig, session.Namespace, session.Pod, kind, query, c.Request.Header, viper.GetDuration("exec.dialTimeout"))
+	if err != nil {
+		log.Errorf("Error opening %s session of pod %s/%s: %s", kind, session.Namespace, session.Pod, err.Error())
+		code := http.StatusBadGateway
+		if upgradeErr, ok := err.(*helm.UpgradeError); ok {
+			code = upgradeErr.StatusCode
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: fmt.Sprintf("Error opening %s session", kind),
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer upstream.Close()
+	if err := session.Save(); err != nil {
+		log.Errorf("Error saving %s session: %s", kind, err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error saving session",
+			Error:   err.Error(),
+		})
+		return
+	}
+	eventType, message := notify.EventPodExec, session.Command
+	if kind == helm.PodPortForward {
+		eventType, message = notify.EventPodPortForward, session.Ports
+	}
+	recordClusterEvent(c, commonCluster, eventType, path.Join(session.Namespace, session.Pod), message)
+
+	client, buffer, err := c.Writer.Hijack()
+	if err != nil {
+		log.Errorf("Error hijacking the connection of %s session %d: %s", kind, session.ID, err.Error())
+		finishPodSession(session, err)
+		return
+	}
+	defer client.Close()
+	log.Infof("%s opened %s session %d of pod %s/%s of cluster %d", session.Actor, kind, session.ID, session.Namespace, session.Pod, session.ClusterID)
+
+	var recorder *helm.StdinRecorder
+	if kind == helm.PodExec && viper.GetBool("exec.recordStdin") {
+		recorder = helm.NewStdinRecorder(upstream.Response.Header.Get("Sec-Websocket-Protocol"), viper.GetInt("exec.stdinLimit"))
+	}
+	err = pipePodSession(client, buffer, upstream, session, recorder)
+	if recorder != nil {
+		session.Stdin, session.StdinTruncated = recorder.Stdin(), recorder.Truncated
+	}
+	finishPodSession(session, err)
+	log.Infof("%s closed %s session %d of pod %s/%s of cluster %d", session.Actor, kind, session.ID, session.Namespace, session.Pod, session.ClusterID)
+}
+
+// newPodSession returns the audit record and the API server query of the session request
+func newPodSession(c *gin.Context, commonCluster cluster.CommonCluster, kind string) (*model.PodSessionModel, url.Values, error) {
+	session := &model.PodSessionModel{
+		OrganizationID: commonCluster.GetOrg(),
+		ClusterID:      commonCluster.GetID(),
+		Actor:          auth.GetCurrentActor(c),
+		RemoteAddr:     auth.RequestIP(c.Request),
+		Kind:           kind,
+		Namespace:      c.Param("namespace"),
+		Pod:            c.Param("pod"),
+		StartedAt:      time.Now().UTC(),
+	}
+	requestQuery := c.Request.URL.Query()
+	query := url.Values{}
+	if kind == helm.PodPortForward {
+		ports := requestQuery["ports"]
+		if len(ports) == 0 {
+			return nil, nil, fmt.Errorf("the ports query parameter is required")
+		}
+		for _, port := range ports {
+			for _, p := range strings.Split(port, ",") {
+				if number, err := strconv.ParseUint(p, 10, 16); err != nil || number == 0 {
+					return nil, nil, fmt.Errorf("invalid port: %q", p)
+				}
+			}
+		}
+		query["ports"] = ports
+		session.Ports = strings.Join(ports, ",")
+		return session, query, nil
+	}
+	for _, name := range execQueryParameters {
+		if values, ok := requestQuery[name]; ok {
+			query[name] = values
+		}
+	}
+	if len(query["command"]) == 0 {
+		return nil, nil, fmt.Errorf("the command query parameter is required")
+	}
+	session.Container = query.Get("container")
+	session.Command = strings.Join(query["command"], " ")
+	session.TTY, _ = strconv.ParseBool(query.Get("tty"))
+	return session, query, nil
+}
+
+// pipePodSession sends the switching protocols response of the API server to the client and copies the frames
+// in both directions, the first side closing the connection ends the session
+func pipePodSession(client net.Conn, buffer *bufio.ReadWriter, upstream *helm.PodConnection, session *model.PodSessionModel, recorder *helm.StdinRecorder) error {
+	// the timeouts of the HTTP server don't apply to the sessions
+	client.SetDeadline(time.Time{})
+	fmt.Fprintf(buffer, "HTTP/1.1 %s\r\n", upstream.Response.Status)
+	upstream.Response.Header.Write(buffer)
+	buffer.WriteString("\r\n")
+	if err := buffer.Flush(); err != nil {
+		return err
+	}
+
+	var input io.Reader = buffer.Reader
+	if recorder != nil {
+		input = io.TeeReader(buffer.Reader, recorder)
+	}
+	var wg sync.WaitGroup
+	var inputErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		session.BytesIn, inputErr = io.Copy(upstream, input)
+		// the API server ends the session after the client is gone
+		upstream.Close()
+	}()
+	var err error
+	session.BytesOut, err = io.Copy(client, upstream)
+	client.Close()
+	wg.Wait()
+	if err == nil {
+		err = inputErr
+	}
+	return err
+}
+
+// finishPodSession saves the end of the session, the closed connections aren't errors
+func finishPodSession(session *model.PodSessionModel, err error) {
+	log := logger.WithFields(logrus.Fields{"tag": "FinishPodSession"})
+	finished := time.Now().UTC()
+	session.FinishedAt = &finished
+	if err != nil && !isClosedConnection(err) {
+		session.Error = err.Error()
+	}
+	if err := session.Save(); err != nil {
+		log.Errorf("Error saving session %d: %s", session.ID, err.Error())
+	}
+}
+
+// isClosedConnection checks whether the error is reading or writing a connection closed by the other side
+func isClosedConnection(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection") || strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// isWebSocketUpgrade checks whether the request upgrades the connection to the WebSocket protocol
+func isWebSocketUpgrade(request *http.Request) bool {
+	if !strings.EqualFold(request.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, token := range strings.Split(request.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedOrigin checks the origin of a WebSocket upgrade, browsers send the session cookie with the upgrades
+// of any site so only Pipeline itself and the origins of cors.AllowOrigins can open sessions. The requests
+// without an origin aren't sent by browsers (eg.: kubectl) and are allowed.
+func isAllowedOrigin(request *http.Request) bool {
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if originURL, err := url.Parse(origin); err == nil && strings.EqualFold(originURL.Host, request.Host) {
+		return true
+	}
+	for _, allowed := range viper.GetStringSlice("cors.AllowOrigins") {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListPodSessions lists the latest exec and port-forward sessions of the cluster without their recorded input,
+// the limit query parameter is the number of the sessions
+func ListPodSessions(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListPodSessions"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPodSessionLimit)))
+	if err == nil && (limit < 1 || limit > maxPodSessionLimit) {
+		err = fmt.Errorf("the limit must be between 1 and %d", maxPodSessionLimit)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid limit",
+			Error:   err.Error(),
+		})
+		return
+	}
+	sessions, err := model.ListPodSessions(commonCluster.GetID(), limit)
+	if err != nil {
+		log.Errorf("Error listing sessions: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing sessions",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// GetPodSession sends back a session of the cluster with its recorded input
+func GetPodSession(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetPodSession"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("sessionid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid session ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+	session, err := model.GetPodSession(commonCluster.GetID(), uint(id))
+	if model.IsErrorGormNotFound(err) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Session not found",
+			Error:   err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Errorf("Error getting session: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting session",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestIsAllowedOrigin(t *testing.T) {
+
+	allowOrigins := viper.GetStringSlice("cors.AllowOrigins")
+	viper.Set("cors.AllowOrigins", []string{"https://ui.example.com/"})
+	defer viper.Set("cors.AllowOrigins", allowOrigins)
+
+	cases := []struct {
+		origin   string
+		expected bool
+	}{
+		{origin: "", expected: true},
+		{origin: "https://pipeline.example.com", expected: true},
+		{origin: "http://pipeline.example.com", expected: true},
+		{origin: "https://ui.example.com", expected: true},
+		{origin: "https://evil.example.com"},
+		{origin: "https://pipeline.example.com.evil.com"},
+		{origin: "null"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.origin, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "https://pipeline.example.com/api/v1/orgs/1/clusters/1/namespaces/default/pods/web/exec", nil)
+			if tc.origin != "" {
+				request.Header.Set("Origin", tc.origin)
+			}
+			if allowed := isAllowedOrigin(request); allowed != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fieldSelection is the selection of the fields of a JSON object, a nil selection of a field keeps the whole field
+type fieldSelection map[string]fieldSelection
+
+// parseFieldSelection parses the comma separated fields, the fields of the nested objects are selected by
+// their paths (clusters.name), the arrays are transparent: the selection applies to their elements
+func parseFieldSelection(fields string) fieldSelection {
+	selection := fieldSelection{}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		current := selection
+		names := strings.Split(field, ".")
+		for i, name := range names {
+			next, selected := current[name]
+			if selected && next == nil {
+				// the whole field is already selected
+				break
+			}
+			if i == len(names)-1 {
+				current[name] = nil
+				break
+			}
+			if next == nil {
+				next = fieldSelection{}
+				current[name] = next
+			}
+			current = next
+		}
+	}
+	return selection
+}
+
+// selectFields trims the JSON value to the selected fields, the order of the kept fields doesn't change
+func selectFields(data []byte, selection fieldSelection) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return data, nil
+	}
+	switch data[0] {
+	case '[':
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil, err
+		}
+		for i, element := range elements {
+			selected, err := selectFields(element, selection)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = selected
+		}
+		return json.Marshal(elements)
+	case '{':
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		var buffer bytes.Buffer
+		buffer.WriteByte('{')
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			name, _ := token.(string)
+			nested, selected := selection[name]
+			if !selected {
+				continue
+			}
+			if nested != nil {
+				if value, err = selectFields(value, nested); err != nil {
+					return nil, err
+				}
+			}
+			if buffer.Len() > 1 {
+				buffer.WriteByte(',')
+			}
+			key, _ := json.Marshal(name)
+			buffer.Write(key)
+			buffer.WriteByte(':')
+			buffer.Write(value)
+		}
+		buffer.WriteByte('}')
+		return buffer.Bytes(), nil
+	}
+	return data, nil
+}
+
+// bufferedResponseWriter keeps the body of the response to write it after the handlers
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+//SelectFields trims the JSON responses of the GET requests to the fields of the fields query parameter
+//(fields=clusters.name,clusters.status,nextCursor), the errors are sent back as they are and the streams and
+//the upgraded connections aren't buffered
+func SelectFields(c *gin.Context) {
+	fields := c.Query("fields")
+	if fields == "" || c.Request.Method != http.MethodGet ||
+		c.GetHeader("Upgrade") != "" || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return
+	}
+	writer := &bufferedResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	body := writer.body.Bytes()
+	status := writer.Status()
+	if status >= http.StatusOK && status < http.StatusMultipleChoices &&
+		strings.HasPrefix(writer.Header().Get("Content-Type"), gin.MIMEJSON) {
+		if selected, err := selectFields(body, parseFieldSelection(fields)); err == nil {
+			body = selected
+		} else {
+			logger.Warnf("Error selecting the fields of the response: %s", err.Error())
+		}
+	}
+	writer.ResponseWriter.Write(body)
+}
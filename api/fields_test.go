@@ -0,0 +1,61 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/api"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSelectFields(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(api.SelectFields)
+	clusters := []gin.H{
+		{"id": 1, "name": "dev", "status": "RUNNING", "location": "eu-west-1", "nodePools": []gin.H{{"name": "pool1", "count": 2}}},
+		{"id": 2, "name": "prod", "status": "ERROR", "location": "us-east-1"},
+	}
+	router.GET("/clusters", func(c *gin.Context) {
+		c.JSON(http.StatusOK, clusters)
+	})
+	router.GET("/v2/clusters", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"clusters": clusters, "nextCursor": "abc"})
+	})
+	router.GET("/error", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "Cluster not found"})
+	})
+
+	cases := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "list", path: "/clusters?fields=name,status", expectedCode: http.StatusOK,
+			expectedBody: `[{"name":"dev","status":"RUNNING"},{"name":"prod","status":"ERROR"}]`},
+		{name: "nested", path: "/clusters?fields=id,nodePools.name", expectedCode: http.StatusOK,
+			expectedBody: `[{"id":1,"nodePools":[{"name":"pool1"}]},{"id":2}]`},
+		{name: "envelope", path: "/v2/clusters?fields=clusters.name,nextCursor", expectedCode: http.StatusOK,
+			expectedBody: `{"clusters":[{"name":"dev"},{"name":"prod"}],"nextCursor":"abc"}`},
+		{name: "whole field", path: "/v2/clusters?fields=clusters.name,clusters", expectedCode: http.StatusOK,
+			expectedBody: `{"clusters":[{"id":1,"location":"eu-west-1","name":"dev","nodePools":[{"count":2,"name":"pool1"}],"status":"RUNNING"},{"id":2,"location":"us-east-1","name":"prod","status":"ERROR"}]}`},
+		{name: "error", path: "/error?fields=name", expectedCode: http.StatusNotFound,
+			expectedBody: `{"code":404,"message":"Cluster not found"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected %v, got: %v", tc.expectedCode, recorder.Code)
+			}
+			if body := recorder.Body.String(); body != tc.expectedBody {
+				t.Errorf("Expected %v, got: %v", tc.expectedBody, body)
+			}
+		})
+	}
+}
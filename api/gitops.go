@@ -0,0 +1,208 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// gitOpsAppRequest describes an application synced from the path of the branch of a Git repository,
+// the secret is the password secret of the basic auth of the repository
+type gitOpsAppRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Repository string `json:"repository" binding:"required"`
+	Branch     string `json:"branch"`
+	Path       string `json:"path"`
+	SecretID   string `json:"secretId"`
+	Kind       string `json:"kind" binding:"required"`
+	Chart      string `json:"chart"`
+	AutoSync   bool   `json:"autoSync"`
+}
+
+// gitOpsAppResponse is an application with the differences of the cluster from the repository
+type gitOpsAppResponse struct {
+	*model.GitOpsAppModel
+	Drift []string `json:"drift,omitempty"`
+}
+
+// ListGitOpsApps lists the GitOps applications of the cluster with their sync statuses
+func ListGitOpsApps(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListGitOpsApps"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	apps, err := model.ListGitOpsApps(commonCluster.GetID())
+	if err != nil {
+		log.Errorf("Error during listing GitOps applications: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing GitOps applications",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := []gitOpsAppResponse{}
+	for i := range apps {
+		response = append(response, gitOpsAppResponse{GitOpsAppModel: &apps[i], Drift: apps[i].GetDrift()})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetGitOpsApp sends back the GitOps application of the cluster with its sync status
+func GetGitOpsApp(c *gin.Context) {
+	app, _, ok := getGitOpsApp(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gitOpsAppResponse{GitOpsAppModel: app, Drift: app.GetDrift()})
+}
+
+// CreateGitOpsApp registers a GitOps application of the cluster, applications with auto sync are synced
+// in the background unless the cluster is protected
+func CreateGitOpsApp(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "CreateGitOpsApp"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request gitOpsAppRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if request.Branch == "" {
+		request.Branch = "master"
+	}
+	app := &model.GitOpsAppModel{
+		ClusterModelID: commonCluster.GetID(),
+		Name:           request.Name,
+		RepositoryURL:  request.Repository,
+		Branch:         request.Branch,
+		Path:           request.Path,
+		SecretID:       request.SecretID,
+		Kind:           request.Kind,
+		Chart:          request.Chart,
+		AutoSync:       request.AutoSync,
+		SyncStatus:     cluster.GitOpsUnknown,
+	}
+	err := cluster.ValidateGitOpsApp(*app)
+	if err == nil {
+		if _, getErr := model.GetGitOpsApp(app.ClusterModelID, app.Name); getErr == nil {
+			err = fmt.Errorf("duplicate application name: %s", app.Name)
+		}
+	}
+	if err == nil && app.SecretID != "" {
+		err = checkRepositorySecret(commonCluster.GetOrg(), app.SecretID, app.RepositoryURL)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = app.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentCreate, attributes) {
+		return
+	}
+	if err := app.Save(); err != nil {
+		log.Errorf("Error during saving GitOps application: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving GitOps application",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if app.AutoSync && !commonCluster.GetModel().Protected {
+		sync := *app
+		go func() {
+			if err := cluster.SyncGitOpsApp(commonCluster, &sync); err != nil {
+				log.Errorf("Error during syncing GitOps application %s: %s", sync.Name, err.Error())
+			}
+		}()
+	}
+	c.JSON(http.StatusCreated, gitOpsAppResponse{GitOpsAppModel: app})
+}
+
+// SyncGitOpsApp applies the head of the branch of the GitOps application to the cluster, it isn't allowed on
+// protected clusters
+func SyncGitOpsApp(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SyncGitOpsApp"})
+	app, commonCluster, ok := getGitOpsApp(c)
+	if !ok {
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = app.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, attributes) {
+		return
+	}
+	if !checkClusterUnprotected(c, commonCluster, "Syncing a GitOps application") {
+		return
+	}
+	if err := cluster.SyncGitOpsApp(commonCluster, app); err != nil {
+		log.Errorf("Error during syncing GitOps application: %s", err.Error())
+		deploymentError(c, "Error during syncing GitOps application", err)
+		return
+	}
+	c.JSON(http.StatusOK, gitOpsAppResponse{GitOpsAppModel: app})
+}
+
+// DeleteGitOpsApp stops syncing the GitOps application, its resources are kept on the cluster
+func DeleteGitOpsApp(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteGitOpsApp"})
+	app, commonCluster, ok := getGitOpsApp(c)
+	if !ok {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentDelete, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteGitOpsApp(app); err != nil {
+		log.Errorf("Error during deleting GitOps application: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during deleting GitOps application",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getGitOpsApp loads the GitOps application of the request, it aborts the request if it doesn't exist
+func getGitOpsApp(c *gin.Context) (*model.GitOpsAppModel, cluster.CommonCluster, bool) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return nil, nil, false
+	}
+	app, err := model.GetGitOpsApp(commonCluster.GetID(), c.Param("name"))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting GitOps application"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "GitOps application not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, nil, false
+	}
+	return app, commonCluster, true
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/vaultclient"
+	"github.com/gin-gonic/gin"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/spf13/viper"
+)
+
+// Statuses of the health checks
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// HealthCheck checks a dependency of Pipeline, it returns an error if the dependency is unavailable
+type HealthCheck func(ctx context.Context) error
+
+// HealthCheckResult is the status of a dependency
+type HealthCheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthResponse is the response of the health endpoints
+type HealthResponse struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// DefaultHealthChecks returns the checks of the database, the seal status of Vault and the statestore
+func DefaultHealthChecks() map[string]HealthCheck {
+	return map[string]HealthCheck{
+		"database":   checkDatabase,
+		"vault":      checkVault,
+		"statestore": checkStateStore,
+	}
+}
+
+func checkDatabase(ctx context.Context) error {
+	return model.GetDB().DB().PingContext(ctx)
+}
+
+var healthVaultOnce sync.Once
+var healthVault *vaultapi.Client
+var healthVaultErr error
+
+// checkVault reads the seal status of Vault (sys/health doesn't need a token),
+// it fails fast while the circuit breaker of the Vault clients is open
+func checkVault(ctx context.Context) error {
+	healthVaultOnce.Do(func() {
+		healthVault, healthVaultErr = vaultapi.NewClient(vaultclient.NewConfig())
+	})
+	if healthVaultErr != nil {
+		return healthVaultErr
+	}
+	health, err := healthVault.Sys().Health()
+	if err != nil {
+		return err
+	}
+	if !health.Initialized {
+		return errors.New("Vault is not initialized")
+	}
+	if health.Sealed {
+		return errors.New("Vault is sealed")
+	}
+	return nil
+}
+
+// checkStateStore checks whether the statestore directory of the clusters is writable
+func checkStateStore(ctx context.Context) error {
+	path := viper.GetString("statestore.path")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(path, ".healthz")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// runHealthCheck runs a check with the timeout of health.timeout
+func runHealthCheck(ctx context.Context, check HealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, viper.GetDuration("health.timeout"))
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- check(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HealthHandler runs the checks in parallel and responds 503 if one of the required checks fails,
+// every check is required if none is listed; the result of each check is in the response
+func HealthHandler(checks map[string]HealthCheck, required ...string) gin.HandlerFunc {
+	if len(required) == 0 {
+		for name := range checks {
+			required = append(required, name)
+		}
+	}
+	return func(c *gin.Context) {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		response := HealthResponse{Status: HealthStatusOK, Checks: make(map[string]HealthCheckResult, len(checks))}
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check HealthCheck) {
+				defer wg.Done()
+				result := HealthCheckResult{Status: HealthStatusOK}
+				if err := runHealthCheck(c.Request.Context(), check); err != nil {
+					result = HealthCheckResult{Status: HealthStatusUnavailable, Error: err.Error()}
+				}
+				mu.Lock()
+				response.Checks[name] = result
+				mu.Unlock()
+			}(name, check)
+		}
+		wg.Wait()
+
+		code := http.StatusOK
+		for _, name := range required {
+			if response.Checks[name].Status != HealthStatusOK {
+				response.Status = HealthStatusUnavailable
+				code = http.StatusServiceUnavailable
+			}
+		}
+		c.JSON(code, response)
+	}
+}
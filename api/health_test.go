@@ -0,0 +1,66 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/api"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthHandler(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	sealed := func(ctx context.Context) error { return errors.New("Vault is sealed") }
+	checks := map[string]api.HealthCheck{"database": ok, "vault": sealed}
+
+	cases := []struct {
+		name         string
+		required     []string
+		expectedCode int
+		expected     api.HealthResponse
+	}{
+		{
+			name:         "liveness",
+			required:     []string{"database"},
+			expectedCode: http.StatusOK,
+			expected: api.HealthResponse{Status: api.HealthStatusOK, Checks: map[string]api.HealthCheckResult{
+				"database": {Status: api.HealthStatusOK},
+				"vault":    {Status: api.HealthStatusUnavailable, Error: "Vault is sealed"},
+			}},
+		},
+		{
+			name:         "readiness",
+			expectedCode: http.StatusServiceUnavailable,
+			expected: api.HealthResponse{Status: api.HealthStatusUnavailable, Checks: map[string]api.HealthCheckResult{
+				"database": {Status: api.HealthStatusOK},
+				"vault":    {Status: api.HealthStatusUnavailable, Error: "Vault is sealed"},
+			}},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/healthz", api.HealthHandler(checks, tc.required...))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			var response api.HealthResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("expected status %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if !reflect.DeepEqual(tc.expected, response) {
+				t.Errorf("expected response %v, got %v", tc.expected, response)
+			}
+		})
+	}
+}
@@ -4,10 +4,18 @@ import (
 	"fmt"
 	htype "github.com/banzaicloud/banzai-types/components/helm"
 	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
 	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/imagescan"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/pagination"
+	"github.com/banzaicloud/pipeline/scm"
 	"github.com/ghodss/yaml"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"k8s.io/helm/pkg/timeconv"
 	"net/http"
 	"k8s.io/helm/pkg/proto/hapi/release"
@@ -15,11 +23,16 @@ import (
 
 // GetK8sConfig returns the Kubernetes config
 func GetK8sConfig(c *gin.Context) (*[]byte, bool) {
-	log := logger.WithFields(logrus.Fields{"tag": "GetKubernetesConfig"})
 	commonCluster, ok := GetCommonClusterFromRequest(c)
 	if ok != true {
 		return nil, false
 	}
+	return clusterK8sConfig(c, commonCluster)
+}
+
+// clusterK8sConfig returns the Kubernetes config of the cluster of the request
+func clusterK8sConfig(c *gin.Context, commonCluster cluster.CommonCluster) (*[]byte, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetKubernetesConfig"})
 	kubeConfig, err := commonCluster.GetK8sConfig()
 	if err != nil {
 		log.Errorf("Error getting config: %s", err.Error())
@@ -33,6 +46,14 @@ func GetK8sConfig(c *gin.Context) (*[]byte, bool) {
 	return kubeConfig, true
 }
 
+// createDeploymentRequest is a deployment request with the Pipeline secrets to inject before the install
+type createDeploymentRequest struct {
+	htype.CreateDeploymentRequest
+	Secrets []InjectSecretRequest `json:"secrets,omitempty"`
+	// Databases are created for the release, their connection secrets are injected once they're ready
+	Databases []cluster.DatabaseRequest `json:"databases,omitempty"`
+}
+
 // CreateDeployment creates a Helm deployment
 func CreateDeployment(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateDeployment})
@@ -41,7 +62,7 @@ func CreateDeployment(c *gin.Context) {
 		return
 	}
 	log.Info("Get cluster succeeded")
-	var deployment *htype.CreateDeploymentRequest
+	var deployment *createDeploymentRequest
 	err := c.BindJSON(&deployment)
 	if err != nil {
 		log.Errorf("Error parsing request: %s", err.Error())
@@ -54,6 +75,26 @@ func CreateDeployment(c *gin.Context) {
 	}
 	log.Info("Parse deployment succeeded")
 
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = deployment.Name
+	if !authorizePolicies(c, auth.PolicyActionDeploymentCreate, attributes) {
+		return
+	}
+	if commonCluster.GetModel().Protected {
+		requestDeploymentApproval(c, commonCluster, model.ApprovalActionCreate, deployment.ReleaseName, deployment.Name, deployment)
+		return
+	}
+	if scheduleOperation(c, commonCluster, model.ScheduledDeploymentCreate, deployment.ReleaseName, deployment) {
+		return
+	}
+	if response, ok := installDeployment(c, commonCluster, deployment, commitFromRequest(c)); ok {
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// installDeployment installs the chart of the deployment request, it aborts the request on error
+func installDeployment(c *gin.Context, commonCluster cluster.CommonCluster, deployment *createDeploymentRequest, commit *ciCommit) (*htype.CreateDeploymentResponse, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateDeployment})
 	log.Debugf("Creating chart %s with version %s and release name %s", deployment.Name, deployment.Version, deployment.ReleaseName)
 	var values []byte
 	if deployment.Values != "" {
@@ -65,7 +106,7 @@ func CreateDeployment(c *gin.Context) {
 				Message: "Error parsing request",
 				Error:   err.Error(),
 			})
-			return
+			return nil, false
 		}
 		values, err = yaml.JSONToYAML(parsedJSON)
 		if err != nil {
@@ -75,7 +116,7 @@ func CreateDeployment(c *gin.Context) {
 				Message: "Error parsing request",
 				Error:   err.Error(),
 			})
-			return
+			return nil, false
 		}
 	}
 	kubeConfig, err := commonCluster.GetK8sConfig()
@@ -86,7 +127,23 @@ func CreateDeployment(c *gin.Context) {
 			Message: "Error getting kubeconfig",
 			Error:   err.Error(),
 		})
-		return
+		return nil, false
+	}
+
+	if _, ok := injectSecrets(c, commonCluster, deployment.Secrets); !ok {
+		return nil, false
+	}
+	if !provisionDatabases(c, commonCluster, deployment.Databases) {
+		return nil, false
+	}
+	if !addOrganizationRepositories(c, commonCluster) {
+		return nil, false
+	}
+
+	if !checkDeploymentImages(c, commonCluster, deployment.ReleaseName, commit, func() (string, error) {
+		return helm.RenderDeployment(deployment.Name, deployment.ReleaseName, values, kubeConfig, commonCluster.GetName())
+	}) {
+		return nil, false
 	}
 
 	log.Debug("Custom values: ", string(values))
@@ -94,12 +151,9 @@ func CreateDeployment(c *gin.Context) {
 	if err != nil {
 		//TODO distinguish error codes
 		log.Errorf("Error during create deployment. %s", err.Error())
-		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: "Error creating deployment",
-			Error:   err.Error(),
-		})
-		return
+		go reportCommitStatus(commonCluster, deployment.ReleaseName, commit, scm.StateFailure, err.Error())
+		deploymentError(c, "Error creating deployment", err)
+		return nil, false
 	}
 	log.Info("Create deployment succeeded")
 
@@ -108,12 +162,13 @@ func CreateDeployment(c *gin.Context) {
 
 	log.Debug("Release name: ", releaseName)
 	log.Debug("Release notes: ", releaseNotes)
-	response := htype.CreateDeploymentResponse{
+	desiredValues, _ := deployment.Values.(map[string]interface{})
+	saveDeploymentState(commonCluster, releaseName, deployment.Name, release.Release.Version, release.Release.Info.Status.Code.String(), desiredValues, commit)
+	recordClusterEvent(c, commonCluster, notify.EventDeploymentCreated, releaseName, fmt.Sprintf("Chart %s deployed as %s to cluster %s", deployment.Name, releaseName, commonCluster.GetName()))
+	return &htype.CreateDeploymentResponse{
 		ReleaseName: releaseName,
 		Notes:       releaseNotes,
-	}
-	c.JSON(http.StatusCreated, response)
-	return
+	}, true
 }
 
 // ListDeployments lists a Helm deployment
@@ -124,6 +179,12 @@ func ListDeployments(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c, deploymentPagination)
+	if err != nil {
+		pagination.BadRequest(c, err)
+		return
+	}
+
 	log.Info("Get deployments")
 	response, err := helm.ListDeployments(nil, kubeConfig)
 	if err != nil {
@@ -137,7 +198,20 @@ func ListDeployments(c *gin.Context) {
 	}
 	var releases []htype.ListDeploymentResponse
 	if len(response.Releases) > 0 {
-		for _, r := range response.Releases {
+		// the releases are listed by Tiller, the page is cut from every release
+		items := make([]pagination.Item, len(response.Releases))
+		for i, r := range response.Releases {
+			items[i] = pagination.Item{ID: r.Name, Fields: map[string]interface{}{
+				"name":    r.Name,
+				"chart":   r.Chart.Metadata.Name,
+				"status":  r.Info.Status.Code.String(),
+				"updated": timeconv.Time(r.Info.LastDeployed),
+			}}
+		}
+		indexes, next := page.Slice(items)
+		pagination.SetNext(c, next)
+		for _, i := range indexes {
+			r := response.Releases[i]
 			body := htype.ListDeploymentResponse{
 				Name:    r.Name,
 				Chart:   fmt.Sprintf("%s-%s", r.Chart.Metadata.Name, r.Chart.Metadata.Version),
@@ -153,6 +227,22 @@ func ListDeployments(c *gin.Context) {
 	return
 }
 
+// deploymentPagination are the fields the deployments can be sorted and filtered by, chart is the name of the chart
+var deploymentPagination = pagination.Options{
+	Fields: map[string]pagination.Field{
+		"name":    {Sort: true, Filter: true},
+		"chart":   {Sort: true, Filter: true},
+		"status":  {Sort: true, Filter: true},
+		"updated": {Sort: true},
+	},
+}
+
+// deploymentStatusResponse is the status of the release with the rollout status of its workloads
+type deploymentStatusResponse struct {
+	htype.DeploymentStatusResponse
+	Rollout *helm.RolloutStatus `json:"rollout,omitempty"`
+}
+
 // HelmDeploymentStatus checks the status of a deployment through the helm client API
 func HelmDeploymentStatus(c *gin.Context) {
 
@@ -188,11 +278,22 @@ func HelmDeploymentStatus(c *gin.Context) {
 		}
 	}
 
+	response := deploymentStatusResponse{
+		DeploymentStatusResponse: htype.DeploymentStatusResponse{
+			Status:  statusCode,
+			Message: msg,
+		},
+	}
+	if err == nil {
+		rollout, err := helm.GetRolloutStatus(name, kubeConfig)
+		if err != nil {
+			log.Warnf("Error getting the rollout status of deployment %s: %s", name, err.Error())
+		}
+		response.Rollout = rollout
+	}
+
 	log.Infof("deployment status for [%s] is [%d]", name, status)
-	c.JSON(statusCode, htype.DeploymentStatusResponse{
-		Status:  statusCode,
-		Message: msg,
-	})
+	c.JSON(statusCode, response)
 }
 
 // InitHelmOnCluster installs Helm on AKS cluster and configure the Helm client
@@ -274,10 +375,305 @@ func GetTillerStatus(c *gin.Context) {
 	return
 }
 
-//UpgradeDeployment - N/A
+// upgradeDeploymentRequest describes the new values of a Helm deployment, the chart is the chart of the
+// deployment if it's missing
+type upgradeDeploymentRequest struct {
+	Chart  string                 `json:"chart"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// upgradeDeploymentResponse describes the new revision of a Helm deployment and the changes of its values
+type upgradeDeploymentResponse struct {
+	ReleaseName string             `json:"releaseName"`
+	Revision    int32              `json:"revision"`
+	Changes     []helm.ValueChange `json:"changes"`
+}
+
+// rollbackDeploymentRequest describes the revision a Helm deployment is rolled back to
+type rollbackDeploymentRequest struct {
+	Revision int32 `json:"revision" binding:"required"`
+}
+
+// deploymentResponse describes the live release and its drift from the values deployed by Pipeline,
+// the release has no desired state if it wasn't deployed by Pipeline
+type deploymentResponse struct {
+	ReleaseName string                 `json:"releaseName"`
+	Revision    int32                  `json:"revision"`
+	Values      map[string]interface{} `json:"values"`
+	Desired     *model.DeploymentModel `json:"desired,omitempty"`
+	Drifted     bool                   `json:"drifted"`
+	Drift       []helm.ValueChange     `json:"drift,omitempty"`
+}
+
+// GetDeployment sends back the values of the release and their drift from the desired values
+func GetDeployment(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetDeployment"})
+	name := c.Param("name")
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	kubeConfig, ok := clusterK8sConfig(c, commonCluster)
+	if ok != true {
+		return
+	}
+	revision, values, err := helm.GetDeploymentValues(name, kubeConfig)
+	if err != nil {
+		log.Errorf("Error getting deployment: %s", err.Error())
+		c.JSON(http.StatusNotFound, htype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Error getting deployment",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := deploymentResponse{ReleaseName: name, Revision: revision, Values: values}
+	desired, err := model.GetDeployment(commonCluster.GetID(), name)
+	if err == nil {
+		response.Desired = desired
+		response.Drift = helm.DiffValues(desired.GetValues(), values)
+		response.Drifted = len(response.Drift) > 0 || desired.Revision != revision
+	} else if !model.IsErrorGormNotFound(err) {
+		log.Errorf("Error getting deployment state: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, htype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting deployment state",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpgradeDeployment upgrades a Helm deployment with new values and sends back the changes of the values
 func UpgradeDeployment(c *gin.Context) {
-	c.Status(http.StatusNotImplemented)
-	return
+	log := logger.WithFields(logrus.Fields{"tag": "UpgradeDeployment"})
+	name := c.Param("name")
+	var request upgradeDeploymentRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if request.Chart == "" {
+		if desired, err := model.GetDeployment(commonCluster.GetID(), name); err == nil {
+			request.Chart = desired.Chart
+		}
+	}
+	if request.Chart == "" {
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "The chart of the deployment is required",
+			Error:   "the deployment wasn't created by Pipeline, its chart is unknown",
+		})
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = request.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, attributes) {
+		return
+	}
+	if commonCluster.GetModel().Protected {
+		requestDeploymentApproval(c, commonCluster, model.ApprovalActionUpgrade, name, request.Chart, request)
+		return
+	}
+	if scheduleOperation(c, commonCluster, model.ScheduledDeploymentUpgrade, name, request) {
+		return
+	}
+	if response, ok := upgradeRelease(c, commonCluster, name, &request, commitFromRequest(c)); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// upgradeRelease upgrades the release to the chart and the values of the upgrade request, it aborts the request
+// on error
+func upgradeRelease(c *gin.Context, commonCluster cluster.CommonCluster, name string, request *upgradeDeploymentRequest, commit *ciCommit) (*upgradeDeploymentResponse, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": "UpgradeDeployment"})
+	kubeConfig, ok := clusterK8sConfig(c, commonCluster)
+	if ok != true {
+		return nil, false
+	}
+	_, values, err := helm.GetDeploymentValues(name, kubeConfig)
+	if err != nil {
+		log.Errorf("Error getting deployment: %s", err.Error())
+		c.JSON(http.StatusNotFound, htype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Error getting deployment",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	if !addOrganizationRepositories(c, commonCluster) {
+		return nil, false
+	}
+	if !checkDeploymentImages(c, commonCluster, name, commit, func() (string, error) {
+		return helm.RenderUpgrade(name, request.Chart, request.Values, kubeConfig, commonCluster.GetName())
+	}) {
+		return nil, false
+	}
+	upgrade, err := helm.UpgradeDeploymentFromRepo(name, request.Chart, request.Values, kubeConfig, commonCluster.GetName())
+	if err != nil {
+		log.Errorf("Error upgrading deployment: %s", err.Error())
+		go reportCommitStatus(commonCluster, name, commit, scm.StateFailure, err.Error())
+		deploymentError(c, "Error upgrading deployment", err)
+		return nil, false
+	}
+	saveDeploymentState(commonCluster, name, request.Chart, upgrade.Release.Version, upgrade.Release.Info.Status.Code.String(), request.Values, commit)
+	recordClusterEvent(c, commonCluster, notify.EventDeploymentUpgraded, name, fmt.Sprintf("Deployment %s of cluster %s upgraded to revision %d", name, commonCluster.GetName(), upgrade.Release.Version))
+	return &upgradeDeploymentResponse{
+		ReleaseName: name,
+		Revision:    upgrade.Release.Version,
+		Changes:     helm.DiffValues(values, request.Values),
+	}, true
+}
+
+// RollbackDeployment rolls a Helm deployment back to a revision, the values of the revision become the desired values
+func RollbackDeployment(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "RollbackDeployment"})
+	name := c.Param("name")
+	var request rollbackDeploymentRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if commonCluster.GetModel().Protected {
+		var chart string
+		if desired, err := model.GetDeployment(commonCluster.GetID(), name); err == nil {
+			chart = desired.Chart
+		}
+		requestDeploymentApproval(c, commonCluster, model.ApprovalActionRollback, name, chart, request)
+		return
+	}
+	if response, ok := rollbackRelease(c, commonCluster, name, &request); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// rollbackRelease rolls the release back to the revision of the request, it aborts the request if the rollback fails
+func rollbackRelease(c *gin.Context, commonCluster cluster.CommonCluster, name string, request *rollbackDeploymentRequest) (*upgradeDeploymentResponse, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": "RollbackDeployment"})
+	kubeConfig, ok := clusterK8sConfig(c, commonCluster)
+	if ok != true {
+		return nil, false
+	}
+	revision, err := helm.RollbackDeployment(name, request.Revision, kubeConfig)
+	if err != nil {
+		log.Errorf("Error rolling back deployment: %s", err.Error())
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error rolling back deployment",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	if desired, err := model.GetDeployment(commonCluster.GetID(), name); err == nil {
+		if _, values, err := helm.GetDeploymentValues(name, kubeConfig); err == nil {
+			saveDeploymentState(commonCluster, name, desired.Chart, revision, release.Status_DEPLOYED.String(), values, nil)
+		} else {
+			log.Warnf("Error getting the values of deployment %s: %s", name, err.Error())
+		}
+	}
+	recordClusterEvent(c, commonCluster, notify.EventDeploymentRolledBack, name, fmt.Sprintf("Deployment %s of cluster %s rolled back to revision %d", name, commonCluster.GetName(), request.Revision))
+	return &upgradeDeploymentResponse{ReleaseName: name, Revision: revision, Changes: []helm.ValueChange{}}, true
+}
+
+// GetDeploymentHistory lists the revisions of a Helm deployment
+func GetDeploymentHistory(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetDeploymentHistory"})
+	name := c.Param("name")
+	kubeConfig, ok := GetK8sConfig(c)
+	if ok != true {
+		return
+	}
+	revisions, err := helm.DeploymentHistory(name, kubeConfig)
+	if err != nil {
+		log.Errorf("Error listing deployment history: %s", err.Error())
+		c.JSON(http.StatusNotFound, htype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Error listing deployment history",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// saveDeploymentState persists the desired state of the release deployed by Pipeline, the error is only logged
+// because the release is already deployed
+func saveDeploymentState(commonCluster cluster.CommonCluster, releaseName, chart string, revision int32, status string, values map[string]interface{}, commit *ciCommit) {
+	deployment := &model.DeploymentModel{
+		ClusterModelID: commonCluster.GetID(),
+		ReleaseName:    releaseName,
+		Chart:          chart,
+		Revision:       revision,
+		Status:         status,
+		Rollout:        helm.RolloutProgressing,
+	}
+	deployment.SetValues(values)
+	if commit != nil {
+		deployment.CommitRepository, deployment.CommitSHA = commit.Repository, commit.SHA
+	}
+	if err := deployment.Save(); err != nil {
+		log.Warnf("Error saving the state of deployment %s: %s", releaseName, err.Error())
+		return
+	}
+	cluster.NotifyProgress(commonCluster.GetID())
+	go reportCommitStatus(commonCluster, releaseName, commit, scm.StatePending, fmt.Sprintf("Rolling out revision %d", revision))
+	go watchRollout(commonCluster, releaseName, revision)
+}
+
+// watchRollout polls the workloads of the revision of the release until they are ready or the rollout
+// times out, the outcome is saved to the state of the release unless it was deployed again meanwhile
+func watchRollout(commonCluster cluster.CommonCluster, releaseName string, revision int32) {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		log.Warnf("Error getting kubeconfig for the rollout of deployment %s: %s", releaseName, err.Error())
+		return
+	}
+	rollout, message := helm.RolloutReady, ""
+	status, err := helm.WaitForRollout(releaseName, kubeConfig, viper.GetDuration("helm.rolloutInterval"), viper.GetDuration("helm.rolloutTimeout"))
+	if err != nil {
+		rollout, message = helm.RolloutFailed, err.Error()
+	} else {
+		message = status.Summary()
+	}
+	deployment, err := model.GetDeployment(commonCluster.GetID(), releaseName)
+	if err != nil || deployment.Revision != revision {
+		return
+	}
+	deployment.Rollout = rollout
+	deployment.RolloutMessage = message
+	if err := deployment.Save(); err != nil {
+		log.Warnf("Error saving the rollout of deployment %s: %s", releaseName, err.Error())
+	}
+	cluster.NotifyProgress(commonCluster.GetID())
+	if deployment.CommitSHA != "" {
+		state := scm.StateSuccess
+		if rollout == helm.RolloutFailed {
+			state = scm.StateFailure
+		}
+		reportCommitStatus(commonCluster, releaseName, &ciCommit{Repository: deployment.CommitRepository, SHA: deployment.CommitSHA}, state, message)
+	}
 }
 
 //DeleteDeployment deletes a Helm deployment
@@ -285,11 +681,24 @@ func DeleteDeployment(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": "DeleteDeployment"})
 	name := c.Param("name")
 	log.Infof("Delete deployment: %s", name)
-	kubeConfig, ok := GetK8sConfig(c)
+	commonCluster, ok := GetCommonClusterFromRequest(c)
 	if ok != true {
 		return
 	}
-	err := helm.DeleteDeployment(name, kubeConfig)
+	if !authorizePolicies(c, auth.PolicyActionDeploymentDelete, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		log.Errorf("Error getting config: %s", err.Error())
+		c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error getting kubeconfig",
+			Error:   err.Error(),
+		})
+		return
+	}
+	err = helm.DeleteDeployment(name, kubeConfig)
 	if err != nil {
 		// error during delete deployment
 		log.Errorf("Error deleting deployment: %s", err.Error())
@@ -300,9 +709,93 @@ func DeleteDeployment(c *gin.Context) {
 		})
 		return
 	}
+	if err := model.DeleteDeployment(commonCluster.GetID(), name); err != nil {
+		log.Warnf("Error deleting the state of deployment %s: %s", name, err.Error())
+	}
+	if err := cluster.DeleteReleaseAutoscaling(commonCluster, name); err != nil {
+		log.Warnf("Error deleting the autoscaling policies of deployment %s: %s", name, err.Error())
+	}
+	if err := cluster.DeleteReleaseRecommendations(commonCluster, name); err != nil {
+		log.Warnf("Error deleting the vertical pod autoscalers of deployment %s: %s", name, err.Error())
+	}
+	cluster.NotifyProgress(commonCluster.GetID())
+	recordClusterEvent(c, commonCluster, notify.EventDeploymentDeleted, name, fmt.Sprintf("Deployment %s of cluster %s deleted", name, commonCluster.GetName()))
 	c.JSON(http.StatusOK, htype.DeleteResponse{
 		Status:  http.StatusOK,
 		Message: "Deployment deleted!",
 		Name:    name,
 	})
 }
+
+// valuesValidationErrorResponse is the error response of the deployment values not matching the schemas of the chart
+type valuesValidationErrorResponse struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Error   string            `json:"error"`
+	Fields  []helm.ValueError `json:"fields"`
+}
+
+// imageScanErrorResponse is the error response of a deployment with images the scanning policy doesn't allow
+type imageScanErrorResponse struct {
+	Code    int                         `json:"code"`
+	Message string                      `json:"message"`
+	Error   string                      `json:"error"`
+	Images  []imagescan.ImageViolations `json:"images"`
+}
+
+// checkDeploymentImages scans the images of the manifest of a release against the scanning policy of the
+// organization, the manifest is only rendered if the policy is enabled. It aborts the request if the images
+// aren't allowed.
+func checkDeploymentImages(c *gin.Context, commonCluster cluster.CommonCluster, releaseName string, commit *ciCommit, render func() (string, error)) bool {
+	log := logger.WithFields(logrus.Fields{"tag": "CheckDeploymentImages"})
+	policy, err := cluster.ImageScanPolicy(commonCluster.GetOrg())
+	if err == nil && policy == nil {
+		return true
+	}
+	var manifest string
+	var images []string
+	if err == nil {
+		manifest, err = render()
+	}
+	if err == nil {
+		images, err = helm.ManifestImages(manifest)
+	}
+	if err == nil {
+		err = cluster.CheckImages(commonCluster, images)
+	}
+	if err != nil {
+		log.Errorf("Error checking the images of deployment %s: %s", releaseName, err.Error())
+		go reportCommitStatus(commonCluster, releaseName, commit, scm.StateFailure, err.Error())
+		deploymentError(c, "Error checking deployment images", err)
+		return false
+	}
+	return true
+}
+
+// deploymentError responds the error of an install or an upgrade, the invalid fields of the values and the
+// vulnerable images are listed
+func deploymentError(c *gin.Context, message string, err error) {
+	if policyErr, ok := err.(*imagescan.PolicyError); ok {
+		c.JSON(http.StatusBadRequest, imageScanErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Images of the deployment have vulnerabilities",
+			Error:   err.Error(),
+			Images:  policyErr.Images,
+		})
+		return
+	}
+	if validationErr, ok := err.(*helm.ValuesValidationError); ok {
+		c.JSON(http.StatusBadRequest, valuesValidationErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid deployment values",
+			Error:   err.Error(),
+			Fields:  validationErr.Errors,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
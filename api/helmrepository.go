@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// addHelmRepositoryRequest describes a chart repository, the secret is the password secret of its basic auth
+// or a cloud secret of an OCI registry
+type addHelmRepositoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	URL      string `json:"url" binding:"required"`
+	SecretID string `json:"secretId"`
+}
+
+// ListHelmRepositories lists the chart repositories of the organization
+func ListHelmRepositories(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListHelmRepositories"})
+	repositories, err := model.ListHelmRepositories(auth.GetCurrentOrganization(c.Request).ID)
+	if err != nil {
+		log.Errorf("Error during listing chart repositories: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing chart repositories",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, repositories)
+}
+
+// AddHelmRepository registers a chart repository of the organization, its index is downloaded before it's saved
+func AddHelmRepository(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "AddHelmRepository"})
+	var request addHelmRepositoryRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	err := helm.ValidateRepository(request.Name, request.URL)
+	if err == nil {
+		if _, getErr := model.GetHelmRepository(organizationID, request.Name); getErr == nil {
+			err = fmt.Errorf("duplicate chart repository name: %s", request.Name)
+		}
+	}
+	if err == nil && request.SecretID != "" {
+		err = checkRepositorySecret(organizationID, request.SecretID, request.URL)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	repository := &model.HelmRepositoryModel{
+		OrganizationID: organizationID,
+		Name:           request.Name,
+		URL:            request.URL,
+		SecretID:       request.SecretID,
+	}
+	if err := helm.RefreshRepositoryIndex(repository); err != nil {
+		log.Errorf("Error during adding chart repository: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during adding chart repository",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, repository)
+}
+
+// RefreshHelmRepository downloads the index of the chart repository of the organization
+func RefreshHelmRepository(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "RefreshHelmRepository"})
+	repository, ok := getHelmRepository(c)
+	if !ok {
+		return
+	}
+	if err := helm.RefreshRepositoryIndex(repository); err != nil {
+		log.Errorf("Error during refreshing chart repository: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during refreshing chart repository",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, repository)
+}
+
+// DeleteHelmRepository removes the chart repository of the organization, the deployed charts aren't changed
+func DeleteHelmRepository(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteHelmRepository"})
+	repository, ok := getHelmRepository(c)
+	if !ok {
+		return
+	}
+	if err := repository.Delete(); err != nil {
+		log.Errorf("Error during deleting chart repository: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during deleting chart repository",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SearchHelmCharts searches the charts of the chart repositories of the organization by the q query parameter
+func SearchHelmCharts(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SearchHelmCharts"})
+	charts, err := helm.SearchCharts(auth.GetCurrentOrganization(c.Request).ID, c.Query("q"))
+	if err != nil {
+		log.Errorf("Error during searching charts: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during searching charts",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, charts)
+}
+
+// getHelmRepository loads the chart repository of the request, it aborts the request if it doesn't exist
+func getHelmRepository(c *gin.Context) (*model.HelmRepositoryModel, bool) {
+	repository, err := model.GetHelmRepository(auth.GetCurrentOrganization(c.Request).ID, c.Param("name"))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting chart repository"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "Chart repository not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return repository, true
+}
+
+// checkRepositorySecret checks that the secret of the organization holds the basic auth credentials of a repository,
+// the OCI registries can log in with cloud secrets too
+func checkRepositorySecret(organizationID uint, secretID, repositoryURL string) error {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(organizationID), 10), secretID)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(repositoryURL, "oci://") {
+		switch item.SecretType {
+		case secret.Password, secret.Amazon, secret.Google, secret.Azure:
+			return nil
+		}
+		return fmt.Errorf("the secret of an OCI registry must be a %s, %s, %s or %s, not a %s",
+			secret.Password, secret.Amazon, secret.Google, secret.Azure, item.SecretType)
+	}
+	if item.SecretType != secret.Password {
+		return fmt.Errorf("the secret of a chart repository must be a %s, not a %s", secret.Password, item.SecretType)
+	}
+	return nil
+}
+
+// addOrganizationRepositories adds the chart repositories of the organization to the Helm home of the cluster,
+// it aborts the request on error
+func addOrganizationRepositories(c *gin.Context, commonCluster cluster.CommonCluster) bool {
+	if err := helm.AddOrganizationRepositories(commonCluster.GetOrg(), commonCluster.GetName()); err != nil {
+		log.Errorf("Error adding the chart repositories of the organization: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error adding the chart repositories of the organization",
+			Error:   err.Error(),
+		})
+		return false
+	}
+	return true
+}
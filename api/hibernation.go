@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// hibernationScheduleRequest describes the cron expressions of the suspend and the resume of a cluster
+type hibernationScheduleRequest struct {
+	Suspend  string `json:"suspend" binding:"required"`
+	Resume   string `json:"resume" binding:"required"`
+	TimeZone string `json:"timeZone"`
+}
+
+// GetHibernationSchedule sends back the hibernation schedule of the cluster
+func GetHibernationSchedule(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	schedule, err := model.GetHibernationSchedule(commonCluster.GetID())
+	if model.IsErrorGormNotFound(err) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster has no hibernation schedule",
+			Error:   err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Errorf("Error during getting hibernation schedule: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during getting hibernation schedule",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// SetHibernationSchedule creates or replaces the hibernation schedule of the cluster
+func SetHibernationSchedule(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	var request hibernationScheduleRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	schedule := &model.HibernationScheduleModel{
+		ClusterModelID: commonCluster.GetID(),
+		Suspend:        request.Suspend,
+		Resume:         request.Resume,
+		TimeZone:       request.TimeZone,
+	}
+	err := cluster.CheckHibernation(commonCluster)
+	if err == nil {
+		err = cluster.ValidateHibernationSchedule(*schedule)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := model.SaveHibernationSchedule(schedule); err != nil {
+		log.Errorf("Error during saving hibernation schedule: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving hibernation schedule",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteHibernationSchedule removes the hibernation schedule of the cluster, the cluster isn't resumed
+func DeleteHibernationSchedule(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := model.DeleteHibernationSchedule(commonCluster.GetID()); err != nil {
+		log.Errorf("Error during deleting hibernation schedule: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during deleting hibernation schedule",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SuspendCluster scales the node pools of the running cluster to zero (or stops the AKS cluster) in the background
+func SuspendCluster(c *gin.Context) {
+	startHibernationAction(c, cluster.HibernationSuspend)
+}
+
+// ResumeCluster scales the node pools of the hibernated cluster back (or starts the AKS cluster) in the background
+func ResumeCluster(c *gin.Context) {
+	startHibernationAction(c, cluster.HibernationResume)
+}
+
+// startHibernationAction starts the suspend or the resume of the cluster and responds its status
+func startHibernationAction(c *gin.Context, action string) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.CheckHibernation(commonCluster); err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := cluster.StartHibernationAction(commonCluster, action); err != nil {
+		abortWithStatusError(c, err)
+		return
+	}
+	response, err := cluster.GetStatusResponse(commonCluster)
+	if err != nil {
+		log.Errorf("Error during getting cluster status: %s", err.Error())
+	}
+	c.JSON(http.StatusAccepted, response)
+}
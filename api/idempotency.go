@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The headers of the idempotent requests, the replayed responses are marked with the Idempotent-Replayed header
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxIdempotencyKeyLength is the size of the keys in the database
+const maxIdempotencyKeyLength = 255
+
+// recordingResponseWriter writes the response and keeps a copy of its body
+type recordingResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestFingerprint is the SHA-256 hash of the API version, the method, the path and the body of the request,
+// the JSON bodies are hashed in their canonical form so the order of their fields doesn't matter
+func requestFingerprint(version, method, path string, body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		body, _ = json.Marshal(value)
+	}
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(version), []byte(method), []byte(path), body} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+//Idempotent runs the requests of a caller of the organization with the same Idempotency-Key header only once: the
+//retries of a request get the saved response of the first request, a key reused for another request is rejected.
+//The requests without the header are run as usual, the server errors and the panics aren't saved so the retries
+//run them again.
+func Idempotent(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "Idempotent"})
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid idempotency key",
+			Error:   "the idempotency key is longer than 255 characters",
+		})
+		return
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error reading request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	organizationID, caller := auth.GetCurrentOrganization(c.Request).ID, auth.GetCurrentActor(c)
+	fingerprint := requestFingerprint(apiversion.FromRequest(c.Request), c.Request.Method, c.Request.URL.Path, body)
+	expiry := time.Now().Add(-viper.GetDuration("idempotency.ttl"))
+	if err := model.DeleteExpiredIdempotencyKeys(organizationID, expiry); err != nil {
+		log.Warnf("Error deleting expired idempotency keys: %s", err.Error())
+	}
+
+	idempotencyKey := &model.IdempotencyKeyModel{OrganizationID: organizationID, Caller: caller, Key: key, Fingerprint: fingerprint}
+	if err := model.CreateIdempotencyKey(idempotencyKey); err != nil {
+		// the key is already used, by this request or by another one
+		saved, getErr := model.GetIdempotencyKey(organizationID, caller, key)
+		if getErr != nil {
+			log.Errorf("Error loading idempotency key: %s", getErr.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Error loading idempotency key",
+				Error:   getErr.Error(),
+			})
+			return
+		}
+		replayIdempotentRequest(c, saved, fingerprint)
+		return
+	}
+
+	// the request of a panicking handler would stay in progress until the key expires
+	defer func() {
+		if r := recover(); r != nil {
+			if err := model.DeleteIdempotencyKey(idempotencyKey); err != nil {
+				log.Errorf("Error deleting idempotency key %q: %s", key, err.Error())
+			}
+			panic(r)
+		}
+	}()
+	writer := &recordingResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	if status := writer.Status(); status >= http.StatusInternalServerError {
+		err = model.DeleteIdempotencyKey(idempotencyKey)
+	} else {
+		idempotencyKey.StatusCode = status
+		idempotencyKey.ContentType = writer.Header().Get("Content-Type")
+		idempotencyKey.Body = writer.body.String()
+		err = model.SaveIdempotencyResponse(idempotencyKey)
+	}
+	if err != nil {
+		log.Errorf("Error saving the response of idempotency key %q: %s", key, err.Error())
+	}
+}
+
+// replayIdempotentRequest sends back the saved response of the request of the idempotency key
+func replayIdempotentRequest(c *gin.Context, saved *model.IdempotencyKeyModel, fingerprint string) {
+	switch {
+	case saved.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, components.ErrorResponse{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "Idempotency key reused",
+			Error:   "the idempotency key was used for another request",
+		})
+	case saved.StatusCode == 0:
+		c.AbortWithStatusJSON(http.StatusConflict, components.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "Request in progress",
+			Error:   "the request of the idempotency key is still in progress",
+		})
+	default:
+		c.Header(idempotentReplayedHeader, "true")
+		c.Data(saved.StatusCode, saved.ContentType, []byte(saved.Body))
+		c.Abort()
+	}
+}
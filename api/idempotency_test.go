@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	qorauth "github.com/qor/auth"
+	"github.com/spf13/viper"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+
+	path := "/api/v1/orgs/1/clusters"
+	fingerprint := requestFingerprint("v1", "POST", path, []byte(`{"name": "dev", "location": "eu-west-1"}`))
+
+	cases := []struct {
+		name     string
+		version  string
+		method   string
+		path     string
+		body     string
+		expected bool
+	}{
+		{name: "same request", version: "v1", method: "POST", path: path, body: `{"name": "dev", "location": "eu-west-1"}`, expected: true},
+		{name: "fields reordered", version: "v1", method: "POST", path: path, body: `{"location":"eu-west-1","name":"dev"}`, expected: true},
+		{name: "other body", version: "v1", method: "POST", path: path, body: `{"name": "prod", "location": "eu-west-1"}`},
+		{name: "other path", version: "v1", method: "POST", path: "/api/v1/orgs/2/clusters", body: `{"name": "dev", "location": "eu-west-1"}`},
+		{name: "other version", version: "v2", method: "POST", path: path, body: `{"name": "dev", "location": "eu-west-1"}`},
+		{name: "other method", version: "v1", method: "PUT", path: path, body: `{"name": "dev", "location": "eu-west-1"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if same := requestFingerprint(tc.version, tc.method, tc.path, []byte(tc.body)) == fingerprint; same != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, same)
+			}
+		})
+	}
+}
+
+var idempotencyUsers = map[string]*auth.User{"alice": {ID: 1, Login: "alice"}, "bob": {ID: 2, Login: "bob"}}
+
+// setUpIdempotency replaces the database with a test one, the handler of the router panics on the first request
+// and counts the requests of the callers
+func setUpIdempotency(t *testing.T) (*gin.Engine, map[string]int, func()) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error during opening database: %s", err.Error())
+	}
+	// every connection of an in-memory database is a new database
+	db.DB().SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.IdempotencyKeyModel{}).Error; err != nil {
+		t.Fatalf("Error during migrating database: %s", err.Error())
+	}
+	model.SetDB(db)
+	ttl := viper.GetDuration("idempotency.ttl")
+	viper.Set("idempotency.ttl", time.Hour)
+
+	runs := map[string]int{}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		defer func() {
+			if recover() != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	})
+	router.POST("/orgs/:orgid/clusters", func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), qorauth.CurrentUser, idempotencyUsers[c.GetHeader("X-User")])
+		ctx = context.WithValue(ctx, auth.CurrentOrganization, &auth.Organization{ID: 1})
+		c.Request = c.Request.WithContext(ctx)
+	}, Idempotent, func(c *gin.Context) {
+		caller := c.GetHeader("X-User")
+		runs[caller]++
+		if c.GetHeader("X-Panic") != "" {
+			panic("handler failed")
+		}
+		c.JSON(http.StatusCreated, gin.H{"caller": caller, "run": runs[caller]})
+	})
+	return router, runs, func() {
+		viper.Set("idempotency.ttl", ttl)
+		db.Close()
+	}
+}
+
+// postIdempotent posts the request of the user with the idempotency key
+func postIdempotent(router *gin.Engine, user, key string, panics bool) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/orgs/1/clusters", strings.NewReader(`{"name": "dev"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(IdempotencyKeyHeader, key)
+	request.Header.Set("X-User", user)
+	if panics {
+		request.Header.Set("X-Panic", "true")
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotentCallers(t *testing.T) {
+
+	router, runs, tearDown := setUpIdempotency(t)
+	defer tearDown()
+
+	first := postIdempotent(router, "alice", "key", false)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected %v, got: %v", http.StatusCreated, first.Code)
+	}
+	// the retry of the caller is replayed, the same key of another caller is another request
+	if retry := postIdempotent(router, "alice", "key", false); retry.Header().Get(idempotentReplayedHeader) != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected %v, got: %v", first.Body.String(), retry.Body.String())
+	}
+	if other := postIdempotent(router, "bob", "key", false); other.Code != http.StatusCreated || other.Header().Get(idempotentReplayedHeader) != "" {
+		t.Errorf("Expected %v, got: %v", http.StatusCreated, other.Code)
+	}
+	if runs["alice"] != 1 || runs["bob"] != 1 {
+		t.Errorf("Expected %v, got: %v", "one run of each caller", runs)
+	}
+}
+
+func TestIdempotentPanic(t *testing.T) {
+
+	router, runs, tearDown := setUpIdempotency(t)
+	defer tearDown()
+
+	if recorder := postIdempotent(router, "alice", "key", true); recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected %v, got: %v", http.StatusInternalServerError, recorder.Code)
+	}
+	// the key of the panicking request isn't left in progress, the retry runs the request again
+	if recorder := postIdempotent(router, "alice", "key", false); recorder.Code != http.StatusCreated {
+		t.Errorf("Expected %v, got: %v", http.StatusCreated, recorder.Code)
+	}
+	if runs["alice"] != 2 {
+		t.Errorf("Expected %v, got: %v", 2, runs["alice"])
+	}
+}
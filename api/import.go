@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// importClusterRequest registers an existing cluster with its base64 encoded kubeconfig,
+// or with a Kubernetes secret holding the kubeconfig
+type importClusterRequest struct {
+	Name               string `json:"name" binding:"required"`
+	Kubeconfig         string `json:"kubeconfig"`
+	SecretId           string `json:"secret_id"`
+	DeletionProtection bool   `json:"deletionProtection,omitempty"`
+}
+
+// ImportCluster registers an existing Kubernetes cluster, its kubeconfig is stored in a Kubernetes secret
+func ImportCluster(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateCluster})
+
+	var request importClusterRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Error(errors.Wrap(err, "Error parsing request"))
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if (request.Kubeconfig == "") == (request.SecretId == "") {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Either kubeconfig or secret_id is required",
+		})
+		return
+	}
+
+	if !authorizePolicies(c, auth.PolicyActionClusterCreate, map[string]string{
+		auth.PolicyAttributeCloud:   cluster.Kubernetes,
+		auth.PolicyAttributeCluster: request.Name,
+	}) {
+		return
+	}
+	if !checkClusterName(c, request.Name) {
+		return
+	}
+
+	organization := auth.GetCurrentOrganization(c.Request)
+	secretID := request.SecretId
+	if request.Kubeconfig != "" {
+		kubeConfig, err := base64.StdEncoding.DecodeString(request.Kubeconfig)
+		if err == nil {
+			_, err = helm.GetK8sClientConfig(&kubeConfig)
+		}
+		if err != nil {
+			log.Errorf("Invalid kubeconfig: %s", err.Error())
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid kubeconfig",
+				Error:   err.Error(),
+			})
+			return
+		}
+		secretID = secret.GenerateSecretID()
+		err = secret.Store.Store(organization.IDString(), secretID, secret.CreateSecretRequest{
+			Name:       request.Name + "-kubeconfig",
+			SecretType: secret.Kubernetes,
+			Values:     map[string]string{secret.K8SConfig: string(kubeConfig)},
+		})
+		if err != nil {
+			log.Errorf("Error during storing kubeconfig: %s", err.Error())
+			c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Error during storing kubeconfig",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	commonCluster := cluster.CreateKubernetesClusterFromRequest(request.Name, secretID, organization.ID)
+	cluster.SetDeletionProtection(commonCluster, request.DeletionProtection)
+
+	// verify the connectivity
+	if err := commonCluster.CreateCluster(); err != nil {
+		log.Errorf("Error during cluster import: %s", err.Error())
+		if request.Kubeconfig != "" {
+			if err := secret.Store.Delete(organization.IDString(), secretID); err != nil {
+				log.Errorf("Error during deleting kubeconfig: %s", err.Error())
+			}
+		}
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	commonCluster.GetModel().Status = cluster.StatusRunning
+	if err := commonCluster.Persist(); err != nil {
+		log.Errorf("Error persisting cluster in database: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// the ingress controller of the imported clusters isn't managed by Pipeline
+	postHookFunctions := []func(commonCluster cluster.CommonCluster){
+		cluster.PersistKubernetesKeys,
+		cluster.UpdatePrometheusPostHook,
+		cluster.InstallHelmPostHook,
+	}
+	go cluster.RunPostHooks(postHookFunctions, commonCluster)
+
+	response, err := commonCluster.GetStatus()
+	if err != nil {
+		log.Errorf("Error during getting cluster status: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during getting cluster status",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(response.Status, response)
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	htype "github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// GetLoggingOutput sends back the output of the logs of the cluster
+func GetLoggingOutput(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetLoggingOutput"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	output, err := cluster.GetLoggingOutput(commonCluster)
+	if err != nil {
+		log.Errorf("Error getting logging output: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting logging output",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if output == nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster has no logging output",
+			Error:   "The cluster has no logging output",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, output)
+}
+
+// SetLoggingOutput sets the output of the logs of the cluster, the logging add-on is installed with the output
+func SetLoggingOutput(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SetLoggingOutput"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var output cluster.LoggingOutput
+	if err := c.BindJSON(&output); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	addon, _ := cluster.GetCatalogAddon(cluster.AddonLogging)
+	attributes := clusterPolicyAttributes(commonCluster)
+	attributes[auth.PolicyAttributeChart] = addon.Chart
+	if !authorizePolicies(c, auth.PolicyActionDeploymentUpdate, attributes) {
+		return
+	}
+	if err := cluster.SetLoggingOutput(commonCluster, output); err != nil {
+		log.Errorf("Error setting logging output: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error setting logging output",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, output)
+}
+
+// DeleteLoggingOutput removes the logging add-on and the output of the logs of the cluster
+func DeleteLoggingOutput(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteLoggingOutput"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionDeploymentDelete, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteLoggingOutput(commonCluster); err != nil {
+		code := http.StatusBadRequest
+		if model.IsErrorGormNotFound(err) {
+			code = http.StatusNotFound
+		}
+		log.Errorf("Error deleting logging output: %s", err.Error())
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: "Error deleting logging output",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// GetDeploymentLogs sends back the recent logs of the containers of a deployment, the container, tailLines,
+// since and previous query parameters select the logs
+func GetDeploymentLogs(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetDeploymentLogs"})
+	kubeConfig, ok := GetK8sConfig(c)
+	if !ok {
+		return
+	}
+	options, ok := parseLogOptions(c)
+	if !ok {
+		return
+	}
+	logs, err := helm.GetReleaseLogs(c.Param("name"), kubeConfig, options)
+	if err != nil {
+		log.Errorf("Error getting deployment logs: %s", err.Error())
+		c.JSON(http.StatusNotFound, htype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Error getting deployment logs",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
+
+// StreamPodLogs streams the logs of a container of a pod of the cluster through Pipeline, the logs are followed
+// until the client disconnects if the follow query parameter is set; the container, tailLines, since, sinceTime
+// and previous query parameters select the logs
+func StreamPodLogs(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "StreamPodLogs"})
+	kubeConfig, ok := GetK8sConfig(c)
+	if !ok {
+		return
+	}
+	options, ok := parseLogOptions(c)
+	if !ok {
+		return
+	}
+	var err error
+	if value := c.Query("follow"); value != "" {
+		if options.Follow, err = strconv.ParseBool(value); err != nil {
+			abortWithLogOptionsError(c, err)
+			return
+		}
+	}
+	stream, err := helm.StreamPodLogs(c.Param("namespace"), c.Param("pod"), kubeConfig, options)
+	if err != nil {
+		log.Errorf("Error getting pod logs: %s", err.Error())
+		code := http.StatusBadRequest
+		if statusErr, ok := errors.Cause(err).(k8sErrors.APIStatus); ok && statusErr.Status().Code != 0 {
+			code = int(statusErr.Status().Code)
+		}
+		c.JSON(code, htype.ErrorResponse{
+			Code:    code,
+			Message: "Error getting pod logs",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer stream.Close()
+	// the followed stream is closed when the client disconnects
+	go func() {
+		<-c.Request.Context().Done()
+		stream.Close()
+	}()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	reader := bufio.NewReader(stream)
+	c.Stream(func(w io.Writer) bool {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			w.Write(line)
+		}
+		return err == nil
+	})
+}
+
+// parseLogOptions parses the log options of the query parameters, the request is aborted if they are invalid
+func parseLogOptions(c *gin.Context) (helm.LogOptions, bool) {
+	options := helm.LogOptions{Container: c.Query("container")}
+	var err error
+	if value := c.Query("tailLines"); value != "" {
+		options.TailLines, err = strconv.ParseInt(value, 10, 64)
+	}
+	if value := c.Query("since"); value != "" && err == nil {
+		options.Since, err = time.ParseDuration(value)
+	}
+	if value := c.Query("sinceTime"); value != "" && err == nil {
+		var sinceTime time.Time
+		sinceTime, err = time.Parse(time.RFC3339, value)
+		options.SinceTime = &sinceTime
+	}
+	if value := c.Query("previous"); value != "" && err == nil {
+		options.Previous, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		abortWithLogOptionsError(c, err)
+		return options, false
+	}
+	return options, true
+}
+
+// abortWithLogOptionsError responds the error of the log options
+func abortWithLogOptionsError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, htype.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: "Invalid log options",
+		Error:   err.Error(),
+	})
+}
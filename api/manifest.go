@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// manifestRequest describes YAML manifests applied with server-side apply, the resources matching the prune
+// selector which are missing from the manifests are deleted
+type manifestRequest struct {
+	Manifests     string `json:"manifests" binding:"required"`
+	FieldManager  string `json:"fieldManager"`
+	Force         bool   `json:"force"`
+	DryRun        bool   `json:"dryRun"`
+	PruneSelector string `json:"pruneSelector"`
+}
+
+// manifestResponse lists the applied and the pruned resources
+type manifestResponse struct {
+	DryRun    bool                  `json:"dryRun"`
+	Resources []helm.ManifestResult `json:"resources"`
+}
+
+// ApplyManifests applies the manifests of the request to the cluster with server-side apply, the manifests of
+// a protected cluster wait for an approval but they can be dry run
+func ApplyManifests(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ApplyManifests"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	var request manifestRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if _, ok := parseManifests(c, &request); !ok {
+		return
+	}
+	attributes := clusterPolicyAttributes(commonCluster)
+	if !authorizePolicies(c, auth.PolicyActionDeploymentCreate, attributes) {
+		return
+	}
+	if request.PruneSelector != "" && !authorizePolicies(c, auth.PolicyActionDeploymentDelete, attributes) {
+		return
+	}
+	if commonCluster.GetModel().Protected && !request.DryRun {
+		requestDeploymentApproval(c, commonCluster, model.ApprovalActionApply, "", "", request)
+		return
+	}
+	if response, ok := applyManifests(c, commonCluster, &request); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// parseManifests parses the manifests and the prune selector of the request, it aborts the request if they're invalid
+func parseManifests(c *gin.Context, request *manifestRequest) ([]*unstructured.Unstructured, bool) {
+	objects, err := helm.ParseManifests([]byte(request.Manifests))
+	if err == nil && request.PruneSelector != "" {
+		_, err = labels.Parse(request.PruneSelector)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid manifests",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return objects, true
+}
+
+// applyManifests applies the manifests of the request, it aborts the request if they can't be applied
+func applyManifests(c *gin.Context, commonCluster cluster.CommonCluster, request *manifestRequest) (*manifestResponse, bool) {
+	log := logger.WithFields(logrus.Fields{"tag": "ApplyManifests"})
+	objects, ok := parseManifests(c, request)
+	if !ok {
+		return nil, false
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		log.Errorf("Error getting kubeconfig: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error getting kubeconfig",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	results, err := helm.ServerSideApply(kubeConfig, objects, helm.ApplyOptions{
+		FieldManager:  request.FieldManager,
+		Force:         request.Force,
+		DryRun:        request.DryRun,
+		PruneSelector: request.PruneSelector,
+	})
+	if err != nil {
+		log.Errorf("Error applying manifests: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error applying manifests",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return &manifestResponse{DryRun: request.DryRun, Resources: results}, true
+}
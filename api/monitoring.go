@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetClusterMonitoring sends back the Prometheus and the Grafana endpoints of the monitoring stack of the cluster
+func GetClusterMonitoring(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetClusterMonitoring"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	monitoring, err := cluster.GetClusterMonitoring(commonCluster)
+	if err != nil {
+		log.Errorf("Error getting cluster monitoring: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting cluster monitoring",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if monitoring == nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster has no monitoring",
+			Error:   "The cluster has no monitoring",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, monitoring)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// teamClusterRoles are the default cluster roles of the roles of the teams in the namespaces
+var teamClusterRoles = map[string]string{
+	auth.RoleAdmin:  "admin",
+	auth.RoleMember: "edit",
+	auth.RoleViewer: "view",
+}
+
+// namespaceRequest describes a namespace, the quota and the limits of the template are overridden by the
+// quota and the limits of the request. The teams of the organization are bound to the cluster role of the
+// team role unless it's set.
+type namespaceRequest struct {
+	Name     string                   `json:"name"`
+	Labels   map[string]string        `json:"labels"`
+	Template string                   `json:"template"`
+	Quota    map[string]string        `json:"quota"`
+	Limits   *cluster.NamespaceLimits `json:"limits"`
+	Teams    []struct {
+		ID          uint   `json:"id" binding:"required"`
+		ClusterRole string `json:"clusterRole"`
+	} `json:"teams"`
+}
+
+// ListNamespaces lists the namespaces of the cluster
+func ListNamespaces(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListNamespaces"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	namespaces, err := cluster.ListNamespaces(commonCluster)
+	if err != nil {
+		log.Errorf("Error listing namespaces: %s", err.Error())
+		namespaceError(c, "Error listing namespaces", err)
+		return
+	}
+	c.JSON(http.StatusOK, namespaces)
+}
+
+// GetNamespace sends back the namespace of the cluster with its quota, limits and team bindings
+func GetNamespace(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetNamespace"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	namespace, err := cluster.GetNamespace(commonCluster, c.Param("namespace"))
+	if err != nil {
+		log.Errorf("Error getting namespace: %s", err.Error())
+		namespaceError(c, "Error getting namespace", err)
+		return
+	}
+	c.JSON(http.StatusOK, namespace)
+}
+
+// CreateNamespace creates a namespace on the cluster with its quota, limits and team bindings
+func CreateNamespace(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "CreateNamespace"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	namespace, ok := bindNamespace(c, "")
+	if !ok {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.CreateNamespace(commonCluster, namespace); err != nil {
+		log.Errorf("Error creating namespace: %s", err.Error())
+		namespaceError(c, "Error creating namespace", err)
+		return
+	}
+	c.JSON(http.StatusCreated, namespace)
+}
+
+// UpdateNamespace replaces the labels, the quota, the limits and the team bindings of a namespace created
+// by Pipeline
+func UpdateNamespace(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "UpdateNamespace"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	namespace, ok := bindNamespace(c, c.Param("namespace"))
+	if !ok {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.UpdateNamespace(commonCluster, namespace); err != nil {
+		log.Errorf("Error updating namespace: %s", err.Error())
+		namespaceError(c, "Error updating namespace", err)
+		return
+	}
+	c.JSON(http.StatusOK, namespace)
+}
+
+// DeleteNamespace deletes a namespace of the cluster unless it has releases deployed by Pipeline
+func DeleteNamespace(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteNamespace"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.DeleteNamespace(commonCluster, c.Param("namespace")); err != nil {
+		log.Errorf("Error deleting namespace: %s", err.Error())
+		namespaceError(c, "Error deleting namespace", err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// bindNamespace parses the namespace of the request with its template and teams, the name of the path
+// overrides the name of the body. It aborts the request if the namespace is invalid.
+func bindNamespace(c *gin.Context, name string) (*cluster.Namespace, bool) {
+	var request namespaceRequest
+	err := c.BindJSON(&request)
+	if err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	if name == "" {
+		name = request.Name
+	}
+	namespace := &cluster.Namespace{Name: name, Labels: request.Labels, Quota: map[string]string{}, Limits: request.Limits}
+	if request.Template != "" {
+		quota, limits, templateErr := cluster.NamespaceTemplate(request.Template)
+		err = templateErr
+		for resource, value := range quota {
+			namespace.Quota[resource] = value
+		}
+		if namespace.Limits == nil {
+			namespace.Limits = limits
+		}
+	}
+	for resource, value := range request.Quota {
+		namespace.Quota[resource] = value
+	}
+
+	organization := auth.GetCurrentOrganization(c.Request)
+	for _, requestTeam := range request.Teams {
+		if err != nil {
+			break
+		}
+		var team auth.Team
+		err = model.GetDB().Where(&auth.Team{ID: requestTeam.ID, OrganizationID: organization.ID}).First(&team).Error
+		if model.IsErrorGormNotFound(err) {
+			err = fmt.Errorf("team %d not found", requestTeam.ID)
+		}
+		clusterRole := requestTeam.ClusterRole
+		if clusterRole == "" {
+			clusterRole = teamClusterRoles[team.Role]
+		}
+		namespace.Teams = append(namespace.Teams, cluster.NamespaceTeam{
+			TeamID:      team.ID,
+			Team:        team.Name,
+			Group:       cluster.TeamGroup(organization.Name, team.Name),
+			ClusterRole: clusterRole,
+		})
+	}
+	if err == nil {
+		err = cluster.ValidateNamespace(*namespace)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return namespace, true
+}
+
+// namespaceError responds with the status of the Kubernetes error, namespaces with Pipeline releases
+// are conflicts
+func namespaceError(c *gin.Context, message string, err error) {
+	code := http.StatusBadRequest
+	if _, ok := err.(*cluster.NamespaceInUseError); ok {
+		code = http.StatusConflict
+	} else if statusErr, ok := errors.Cause(err).(k8sErrors.APIStatus); ok && statusErr.Status().Code != 0 {
+		code = int(statusErr.Status().Code)
+	}
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetNodePools lists the node pools of a cluster
+func GetNodePools(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	c.JSON(http.StatusOK, cluster.GetNodePools(commonCluster))
+}
+
+// AddNodePool adds a node pool to a running cluster, the pool is created in the background
+func AddNodePool(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	var pool cluster.NodePool
+	if err := c.BindJSON(&pool); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.CheckAddNodePool(commonCluster, pool); err != nil {
+		abortWithNodePoolError(c, err)
+		return
+	}
+	if updateClusterInBackground(c, commonCluster, func() error {
+		return cluster.AddNodePool(commonCluster, pool)
+	}) {
+		recordClusterEvent(c, commonCluster, notify.EventNodePoolCreated, pool.Name, fmt.Sprintf("Node pool %s added to cluster %s", pool.Name, commonCluster.GetName()))
+	}
+}
+
+// UpdateNodePool resizes or relabels a node pool of a running cluster, the pools with a new instance type or new spot
+// properties are replaced
+func UpdateNodePool(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	// the name of the pool is optional, the body isn't validated by the binding
+	var pool cluster.NodePool
+	var fields map[string]json.RawMessage
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &pool)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &fields)
+	}
+	if err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	name := c.Param("name")
+	if _, ok := fields["spot"]; !ok {
+		// the spot properties are kept unless the request sets spot
+		for _, current := range cluster.GetNodePools(commonCluster) {
+			if current.Name == name {
+				pool.Spot, pool.SpotPrice, pool.OnDemandPercentage = current.Spot, current.SpotPrice, current.OnDemandPercentage
+			}
+		}
+	}
+	if err := cluster.CheckUpdateNodePool(commonCluster, name, &pool); err != nil {
+		abortWithNodePoolError(c, err)
+		return
+	}
+	if updateClusterInBackground(c, commonCluster, func() error {
+		return cluster.UpdateNodePool(commonCluster, name, pool)
+	}) {
+		recordClusterEvent(c, commonCluster, notify.EventNodePoolUpdated, name, fmt.Sprintf("Node pool %s of cluster %s updated", name, commonCluster.GetName()))
+	}
+}
+
+// DeleteNodePool drains and deletes a node pool of a running cluster
+func DeleteNodePool(c *gin.Context) {
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	name := c.Param("name")
+	if err := cluster.CheckDeleteNodePool(commonCluster, name); err != nil {
+		abortWithNodePoolError(c, err)
+		return
+	}
+	if updateClusterInBackground(c, commonCluster, func() error {
+		return cluster.DeleteNodePool(commonCluster, name)
+	}) {
+		recordClusterEvent(c, commonCluster, notify.EventNodePoolDeleted, name, fmt.Sprintf("Node pool %s of cluster %s deleted", name, commonCluster.GetName()))
+	}
+}
+
+// updateClusterInBackground runs the change of the cluster in the background, the cluster is updating until it's
+// finished; it's false if the change isn't started
+func updateClusterInBackground(c *gin.Context, commonCluster cluster.CommonCluster, update func() error) bool {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	if err := cluster.SetStatus(commonCluster, cluster.StatusUpdating, ""); err != nil {
+		abortWithStatusError(c, err)
+		return false
+	}
+
+	response, err := cluster.GetStatusResponse(commonCluster)
+	if err != nil {
+		log.Errorf("Error during getting cluster status: %s", err.Error())
+	}
+
+	go func() {
+		if err := update(); err != nil {
+			log.Errorf("Cluster update failed: %s", err.Error())
+			setClusterStatus(commonCluster, cluster.StatusError, err.Error())
+			return
+		}
+		setClusterStatus(commonCluster, cluster.StatusRunning, "")
+	}()
+
+	c.JSON(http.StatusAccepted, response)
+	return true
+}
+
+// abortWithNodePoolError responds the validation error of a node pool change
+func abortWithNodePoolError(c *gin.Context, err error) {
+	code := http.StatusBadRequest
+	if _, ok := err.(*cluster.NodePoolNotFoundError); ok {
+		code = http.StatusNotFound
+	}
+	log.Errorf("Node pool validation failed: %s", err.Error())
+	c.JSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: err.Error(),
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,540 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	htype "github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/objectstore"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+)
+
+//Schema is an OpenAPI 3 schema of a JSON value, the request bodies are validated against it
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// partialBody is a request body whose required fields can come from elsewhere (a cluster profile, the updated
+// object), only the types of its fields are validated
+type partialBody struct{ body interface{} }
+
+// optionalBody is a request body which can be left empty
+type optionalBody struct{ body interface{} }
+
+// v2Body is the request body of the v2 requests of a handler, its v1 requests have no body
+type v2Body struct{ body interface{} }
+
+// requestBodies are the types of the JSON request bodies of the handlers, the bodies of these handlers are
+// validated against the schemas of the types before the handlers bind them. The handlers without a JSON body
+// are listed with a nil body, every POST, PUT and PATCH handler has to be listed.
+var requestBodies = []struct {
+	handler gin.HandlerFunc
+	body    interface{}
+}{
+	{CreateCluster, partialBody{createClusterRequest{}}},
+	{UpdateCluster, components.UpdateClusterRequest{}},
+	{SetDeletionProtection, deletionProtectionRequest{}},
+	{ImportCluster, importClusterRequest{}},
+	{UpgradeCluster, cluster.UpgradeRequest{}},
+	{AddNodePool, cluster.NodePool{}},
+	{UpdateNodePool, partialBody{cluster.NodePool{}}},
+	{SuspendCluster, nil},
+	{ResumeCluster, nil},
+	{RotateClusterSSHKey, nil},
+	{UpdateMonitoring, nil},
+	{SetHibernationSchedule, hibernationScheduleRequest{}},
+	{SetMaintenanceWindow, maintenanceWindowRequest{}},
+	{SetEtcdSnapshotPolicy, etcdSnapshotPolicyRequest{}},
+	{CreateEtcdSnapshot, nil},
+	{RestoreEtcdSnapshot, nil},
+	{InitHelmOnCluster, htype.Install{}},
+	{CreateDeployment, createDeploymentRequest{}},
+	{UpgradeDeployment, upgradeDeploymentRequest{}},
+	{RollbackDeployment, rollbackDeploymentRequest{}},
+	{SetDeploymentApprovalSettings, deploymentApprovalSettings{}},
+	{StartCanary, canaryRequest{}},
+	{PromoteCanary, nil},
+	{AbortCanary, nil},
+	{ApproveDeployment, optionalBody{resolveDeploymentRequest{}}},
+	{RejectDeployment, optionalBody{resolveDeploymentRequest{}}},
+	{SetAutoscalingPolicy, cluster.AutoscalingPolicy{}},
+	{AddHelmRepository, addHelmRepositoryRequest{}},
+	{RefreshHelmRepository, nil},
+	{InstallAddon, nil},
+	{UpgradeAddon, nil},
+	{ApplyManifests, manifestRequest{}},
+	{CreateNamespace, namespaceRequest{}},
+	{UpdateNamespace, namespaceRequest{}},
+	{InjectClusterSecret, InjectSecretRequest{}},
+	{RequestCertificate, certificateRequest{}},
+	{CreateDatabase, cluster.DatabaseRequest{}},
+	{SetLoggingOutput, cluster.LoggingOutput{}},
+	{SetBackupBucket, cluster.BackupBucket{}},
+	{CreateBackup, cluster.BackupRequest{}},
+	{RestoreBackup, cluster.RestoreRequest{}},
+	{SetBackupSchedule, cluster.BackupSchedule{}},
+	{CreateGitOpsApp, gitOpsAppRequest{}},
+	{SyncGitOpsApp, nil},
+	{CreateCIRepository, ciRepositoryRequest{}},
+	{AddContainerRegistry, addContainerRegistryRequest{}},
+	{InjectImagePullSecrets, injectImagePullSecretRequest{}},
+	{ScanImage, scanImageRequest{}},
+	{SetImageScanPolicy, imageScanPolicyRequest{}},
+	{AddClusterProfile, components.ClusterProfileRequest{}},
+	{UpdateClusterProfile, components.ClusterProfileRequest{}},
+	{CreateOrganizationClusterProfile, clusterProfileRequest{}},
+	{UpdateOrganizationClusterProfile, partialBody{clusterProfileRequest{}}},
+	{CreateAlertRule, alertRuleRequest{}},
+	{UpdateAlertRule, alertRuleRequest{}},
+	{CreateAlertReceiver, alertReceiverRequest{}},
+	{UpdateAlertReceiver, alertReceiverRequest{}},
+	{CreateWebhook, webhookRequest{}},
+	{UpdateWebhook, webhookRequest{}},
+	{PingWebhook, nil},
+	{RedeliverWebhookDelivery, nil},
+	{AddSecrets, secret.CreateSecretRequest{}},
+	{UpdateSecret, secret.CreateSecretRequest{}},
+	{RollbackSecret, rollbackSecretRequest{}},
+	{VerifySecret, nil},
+	{ShareSecret, shareSecretRequest{}},
+	{RewrapSecrets, nil},
+	{CreateBucket, objectstore.BucketRequest{}},
+	{CreateOrganization, organizationRequest{}},
+	{auth.SetMemberRole, auth.MemberRoleRequest{}},
+	{auth.SetOrganizationTwoFactor, auth.OrganizationTwoFactorRequest{}},
+	{auth.CreateTeam, auth.TeamRequest{}},
+	{auth.UpdateTeam, auth.TeamRequest{}},
+	{auth.AddTeamMember, nil},
+	{auth.CreatePolicy, auth.Policy{}},
+	{auth.UpdatePolicy, auth.Policy{}},
+	{auth.CreateServiceAccount, auth.ServiceAccountRequest{}},
+	{auth.GenerateServiceAccountToken, v2Body{optionalBody{auth.TokenRequest{}}}},
+	{auth.GenerateToken, v2Body{optionalBody{auth.TokenRequest{}}}},
+	{auth.RotateToken, nil},
+	{auth.RefreshToken, auth.RefreshTokenRequest{}},
+	{auth.EnrollTwoFactor, nil},
+	{auth.VerifyTwoFactor, auth.TwoFactorRequest{}},
+	{auth.DisableTwoFactor, auth.TwoFactorRequest{}},
+}
+
+// requestBody is the described request body of a handler, the schema is nil if the handler has no JSON body
+type requestBody struct {
+	schema   *Schema
+	optional bool
+	v2       bool
+}
+
+// newRequestBody returns the request body of the body type of requestBodies
+func newRequestBody(body interface{}) *requestBody {
+	switch body := body.(type) {
+	case nil:
+		return &requestBody{}
+	case partialBody:
+		described := newRequestBody(body.body)
+		withoutRequired(described.schema)
+		return described
+	case optionalBody:
+		described := newRequestBody(body.body)
+		described.optional = true
+		return described
+	case v2Body:
+		described := newRequestBody(body.body)
+		described.v2 = true
+		return described
+	}
+	return &requestBody{schema: schemaOf(reflect.TypeOf(body), map[reflect.Type]bool{})}
+}
+
+// withoutRequired removes the required properties of the schema and of its nested schemas
+func withoutRequired(schema *Schema) {
+	if schema == nil {
+		return
+	}
+	schema.Required = nil
+	for _, property := range schema.Properties {
+		withoutRequired(property)
+	}
+	withoutRequired(schema.Items)
+	withoutRequired(schema.AdditionalProperties)
+}
+
+// requestSchemas are the request bodies by the names of the handlers, the names of the handlers of the routes of gin
+var requestSchemas struct {
+	sync.Once
+	bodies map[string]*requestBody
+}
+
+// handlerName returns the name of the handler like gin does
+func handlerName(handler gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
+
+// describedRequestBody returns the request body of the handler, nil if the body isn't described
+func describedRequestBody(name string) *requestBody {
+	requestSchemas.Do(func() {
+		requestSchemas.bodies = map[string]*requestBody{}
+		for _, request := range requestBodies {
+			requestSchemas.bodies[handlerName(request.handler)] = newRequestBody(request.body)
+		}
+	})
+	return requestSchemas.bodies[name]
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// schemaOf returns the schema of the JSON encoding of the type: the properties of the structs are named by their
+// json tags and they're required by the required binding; the types decoding themselves and the recursive types
+// can be any value
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time", Nullable: nullable}
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) || visiting[t] {
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean", Nullable: nullable}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Nullable: nullable}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Nullable: nullable}
+	case reflect.String:
+		return &Schema{Type: "string", Nullable: nullable}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), visiting), Nullable: true}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), visiting), Nullable: true}
+	case reflect.Struct:
+		visiting[t] = true
+		defer delete(visiting, t)
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}, Nullable: nullable}
+		addProperties(schema, t, visiting)
+		return schema
+	}
+	return &Schema{}
+}
+
+// addProperties adds the fields of the struct to the properties of the schema, the fields of the embedded
+// structs without a json name are properties of the struct
+func addProperties(schema *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if name == "" && field.Anonymous && fieldType.Kind() == reflect.Struct && !reflect.PtrTo(fieldType).Implements(unmarshalerType) {
+			addProperties(schema, fieldType, visiting)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = schemaOf(field.Type, visiting)
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			if rule == "required" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+	sort.Strings(schema.Required)
+}
+
+//FieldError is an invalid field of a request body, the field is the path of the field in the body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// requestValidationResponse is the error response of the invalid request bodies
+type requestValidationResponse struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Error   string       `json:"error"`
+	Fields  []FieldError `json:"fields"`
+}
+
+//ValidateValue validates the JSON value (decoded with numbers) against the schema, it returns the invalid fields
+func ValidateValue(value interface{}, schema *Schema, path string) []FieldError {
+	field := path
+	if field == "" {
+		field = "."
+	}
+	if value == nil {
+		if schema.Type == "" || schema.Nullable {
+			return nil
+		}
+		return []FieldError{{Field: field, Message: "must not be null"}}
+	}
+	invalid := func(expected string) []FieldError {
+		return []FieldError{{Field: field, Message: "must be " + expected}}
+	}
+	switch schema.Type {
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("a boolean")
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if _, err := number.Int64(); !ok || err != nil {
+			return invalid("an integer")
+		}
+	case "number":
+		number, ok := value.(json.Number)
+		if _, err := number.Float64(); !ok || err != nil {
+			return invalid("a number")
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return invalid("a string")
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return invalid("an RFC 3339 date-time")
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return invalid("an array")
+		}
+		errors := []FieldError{}
+		for i, item := range items {
+			errors = append(errors, ValidateValue(item, schema.Items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errors
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("an object")
+		}
+		errors := []FieldError{}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errors = append(errors, FieldError{Field: joinField(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property := schema.Properties[name]
+			if property == nil {
+				property = schema.AdditionalProperties
+			}
+			if property != nil {
+				errors = append(errors, ValidateValue(object[name], property, joinField(path, name))...)
+			}
+		}
+		return errors
+	}
+	return nil
+}
+
+// joinField returns the path of the property of the object at the path
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+//ValidateRequestBody validates the JSON request bodies of the handlers with a described request body against
+//the schema of the body, the invalid requests are aborted with the invalid fields
+func ValidateRequestBody(c *gin.Context) {
+	described := describedRequestBody(c.HandlerName())
+	if described == nil || described.schema == nil || (described.v2 && apiversion.IsV1(c)) ||
+		c.Request.Body == nil || c.Request.Method == http.MethodGet {
+		return
+	}
+	if contentType := c.ContentType(); contentType != "" && contentType != gin.MIMEJSON {
+		return
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error reading request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	response := requestValidationResponse{Code: http.StatusBadRequest, Message: "Invalid request body", Fields: []FieldError{}}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if described.optional {
+			return
+		}
+		response.Error = "the request body is required"
+		c.AbortWithStatusJSON(http.StatusBadRequest, response)
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		response.Error = err.Error()
+		c.AbortWithStatusJSON(http.StatusBadRequest, response)
+		return
+	}
+	if response.Fields = ValidateValue(value, described.schema, ""); len(response.Fields) != 0 {
+		response.Error = fmt.Sprintf("%s %s", response.Fields[0].Field, response.Fields[0].Message)
+		c.AbortWithStatusJSON(http.StatusBadRequest, response)
+	}
+}
+
+// openAPIOperation is an operation of a path of the OpenAPI document
+type openAPIOperation struct {
+	OperationID string                 `json:"operationId"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []openAPIParameter     `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody    `json:"requestBody,omitempty"`
+	Responses   map[string]interface{} `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                   `json:"required"`
+	Content  map[string]interface{} `json:"content"`
+}
+
+// jsonContent is the JSON content of the schema
+func jsonContent(schema *Schema) map[string]interface{} {
+	return map[string]interface{}{gin.MIMEJSON: map[string]interface{}{"schema": schema}}
+}
+
+//OpenAPIDocument returns the OpenAPI 3 document of the routes of the router under the prefix, the request bodies
+//of the handlers are described by the schemas the requests are validated against
+func OpenAPIDocument(router *gin.Engine, prefix, version string) map[string]interface{} {
+	paths := map[string]map[string]*openAPIOperation{}
+	schemas := map[string]*Schema{
+		"ErrorResponse": schemaOf(reflect.TypeOf(requestValidationResponse{}), map[reflect.Type]bool{}),
+	}
+	operationIDs := map[string]int{}
+	routes := router.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, prefix) {
+			continue
+		}
+		name := route.Handler[strings.LastIndex(route.Handler, ".")+1:]
+		operationIDs[name]++
+		operation := &openAPIOperation{
+			OperationID: name,
+			Parameters:  []openAPIParameter{},
+			Responses: map[string]interface{}{
+				"2XX":     map[string]interface{}{"description": "Success"},
+				"default": map[string]interface{}{"description": "Error", "content": jsonContent(&Schema{Ref: "#/components/schemas/ErrorResponse"})},
+			},
+		}
+		if operationIDs[name] > 1 {
+			operation.OperationID = fmt.Sprintf("%s%d", name, operationIDs[name])
+		}
+		segments := strings.Split(strings.TrimPrefix(route.Path, prefix), "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				segments[i] = "{" + segment[1:] + "}"
+				operation.Parameters = append(operation.Parameters, openAPIParameter{
+					Name: segment[1:], In: "path", Required: true, Schema: &Schema{Type: "string"},
+				})
+			} else if segment != "" && segment != "orgs" && len(operation.Tags) == 0 {
+				operation.Tags = []string{segment}
+			}
+		}
+		if route.Method == http.MethodGet {
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name: "fields", In: "query", Schema: &Schema{Type: "string"},
+			})
+		}
+		// the document describes the v1 routes, the v2 bodies aren't v1 request bodies
+		if described := describedRequestBody(route.Handler); described != nil && described.schema != nil && !described.v2 {
+			schemas[name+"Request"] = described.schema
+			operation.RequestBody = &openAPIRequestBody{
+				Required: !described.optional,
+				Content:  jsonContent(&Schema{Ref: "#/components/schemas/" + name + "Request"}),
+			}
+		}
+		path := strings.TrimSuffix(prefix, "/") + strings.Join(segments, "/")
+		if paths[path] == nil {
+			paths[path] = map[string]*openAPIOperation{}
+		}
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info":    map[string]interface{}{"title": "Pipeline API", "version": version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+//OpenAPIHandler sends back the OpenAPI document of the routes of the router under the prefix, the document is
+//generated from the routes set up before the handler
+func OpenAPIHandler(router *gin.Engine, prefix, version string) gin.HandlerFunc {
+	document := OpenAPIDocument(router, prefix, version)
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	}
+}
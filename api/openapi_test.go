@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/api"
+	"github.com/gin-gonic/gin"
+)
+
+// testValidationRouter validates the request bodies of the deployment routes, the requests passing the
+// validation are answered before the handlers
+func testValidationRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(api.ValidateRequestBody)
+	passed := func(c *gin.Context) { c.AbortWithStatus(http.StatusNoContent) }
+	router.POST("/api/v1/orgs/:orgid/clusters/:id/deployments", passed, api.CreateDeployment)
+	router.DELETE("/api/v1/orgs/:orgid/clusters/:id/deployments/:name", passed, api.DeleteDeployment)
+	return router
+}
+
+func TestValidateRequestBody(t *testing.T) {
+
+	cases := []struct {
+		name           string
+		body           string
+		expectedCode   int
+		expectedFields []api.FieldError
+	}{
+		{name: "valid", body: `{"name": "stable/redis", "values": {"replicas": 1}}`, expectedCode: http.StatusNoContent},
+		{name: "missing name", body: `{"version": "1.0.0"}`, expectedCode: http.StatusBadRequest,
+			expectedFields: []api.FieldError{{Field: "name", Message: "is required"}}},
+		{name: "invalid types", body: `{"name": 1, "secrets": [{"secretId": "s1", "name": true}]}`, expectedCode: http.StatusBadRequest,
+			expectedFields: []api.FieldError{{Field: "name", Message: "must be a string"}, {Field: "secrets[0].name", Message: "must be a string"}}},
+		{name: "not an object", body: `[]`, expectedCode: http.StatusBadRequest,
+			expectedFields: []api.FieldError{{Field: ".", Message: "must be an object"}}},
+		{name: "empty body", body: ``, expectedCode: http.StatusBadRequest, expectedFields: []api.FieldError{}},
+	}
+
+	router := testValidationRouter()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/1/clusters/1/deployments", strings.NewReader(tc.body))
+			request.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected %v, got: %v", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedFields == nil {
+				return
+			}
+			var response struct {
+				Fields []api.FieldError `json:"fields"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Error during parsing response: %s", err.Error())
+			}
+			if !reflect.DeepEqual(response.Fields, tc.expectedFields) {
+				t.Errorf("Expected %v, got: %v", tc.expectedFields, response.Fields)
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+
+	document := api.OpenAPIDocument(testValidationRouter(), "/api/v1", "test")
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("Error during marshaling document: %s", err.Error())
+	}
+	var parsed struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+			} `json:"parameters"`
+			RequestBody *json.RawMessage `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]api.Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Error during parsing document: %s", err.Error())
+	}
+
+	create := parsed.Paths["/api/v1/orgs/{orgid}/clusters/{id}/deployments"]["post"]
+	if create.OperationID != "CreateDeployment" || create.RequestBody == nil || len(create.Parameters) != 2 {
+		t.Errorf("Expected the CreateDeployment operation with its body and path parameters, got: %v", create)
+	}
+	if deletion := parsed.Paths["/api/v1/orgs/{orgid}/clusters/{id}/deployments/{name}"]["delete"]; deletion.RequestBody != nil {
+		t.Errorf("Expected no request body of DeleteDeployment, got: %s", *deletion.RequestBody)
+	}
+	schema := parsed.Components.Schemas["CreateDeploymentRequest"]
+	if !reflect.DeepEqual(schema.Required, []string{"name"}) || schema.Properties["secrets"] == nil || schema.Properties["secrets"].Type != "array" {
+		t.Errorf("Expected the schema of the deployment request, got: %v", schema)
+	}
+}
@@ -30,11 +30,20 @@ func OrganizationMiddleware(c *gin.Context) {
 		})
 	}
 
-	user := auth.GetCurrentUser(c.Request)
 	var organization = auth.Organization{ID: uint(orgid)}
 
 	db := model.GetDB()
-	err = db.Model(user).Where(&organization).Related(&organization, "Organizations").Error
+	if sa := auth.GetCurrentServiceAccount(c.Request); sa != nil {
+		// Service accounts can access their own organization only
+		if sa.OrganizationID != organization.ID {
+			err = gorm.ErrRecordNotFound
+		} else {
+			err = db.First(&organization).Error
+		}
+	} else {
+		user := auth.GetCurrentUser(c.Request)
+		err = db.Model(user).Where(&organization).Related(&organization, "Organizations").Error
+	}
 	if err == gorm.ErrRecordNotFound {
 		message := fmt.Sprintf("organization not found: %q", orgidParam)
 		log.Info(message)
@@ -113,6 +122,11 @@ func GetOrganizations(c *gin.Context) {
 	}
 }
 
+// organizationRequest is the name of a new organization
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
 //CreateOrganization creates an organization for the calling user
 func CreateOrganization(c *gin.Context) {
 	log := logger.WithFields(logrus.Fields{"tag": "CreateOrganization"})
@@ -130,10 +144,7 @@ func CreateOrganization(c *gin.Context) {
 		return
 	}
 
-	var name struct {
-		Name string
-	}
-
+	var name organizationRequest
 	if err := c.ShouldBindJSON(&name); err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
 			Code:    http.StatusBadRequest,
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterPolicyAttributes returns the attributes of a cluster matched by the policy conditions
+func clusterPolicyAttributes(commonCluster cluster.CommonCluster) map[string]string {
+	clusterModel := commonCluster.GetModel()
+	return map[string]string{
+		auth.PolicyAttributeCloud:            clusterModel.Cloud,
+		auth.PolicyAttributeLocation:         clusterModel.Location,
+		auth.PolicyAttributeNodeInstanceType: clusterModel.NodeInstanceType,
+		auth.PolicyAttributeCluster:          clusterModel.Name,
+	}
+}
+
+// authorizePolicies aborts the request if the policies of the organization don't allow the action
+func authorizePolicies(c *gin.Context, action string, attributes map[string]string) bool {
+	log := logger.WithFields(logrus.Fields{"tag": "AuthorizePolicies"})
+	allowed, err := auth.AuthorizePolicies(c.Request, action, attributes)
+	if err != nil {
+		log.Errorf("Error evaluating policies: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error evaluating policies",
+			Error:   err.Error(),
+		})
+		return false
+	}
+	if !allowed {
+		message := action + " is not allowed by the policies of the organization"
+		c.AbortWithStatusJSON(http.StatusForbidden, components.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: message,
+			Error:   message,
+		})
+		return false
+	}
+	return true
+}
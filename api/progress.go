@@ -0,0 +1,67 @@
+package api
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// StreamClusterStatus streams the progress of the operations and of the deployments of the cluster as
+// server-sent events: the status of the cluster, the steps of its current operation and the deployments of
+// the cluster are sent when they change. The stream ends after the operations if the untilDone query parameter
+// is set, and after the deletion of the cluster.
+func StreamClusterStatus(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "StreamClusterStatus"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	untilDone, _ := strconv.ParseBool(c.Query("untilDone"))
+	clusterID := commonCluster.GetID()
+
+	// the changes made by this instance are signaled, the changes of the other instances are polled
+	changes, unsubscribe := cluster.SubscribeProgress(clusterID)
+	defer unsubscribe()
+	poll := time.NewTicker(viper.GetDuration("progress.pollInterval"))
+	defer poll.Stop()
+	heartbeat := time.NewTicker(viper.GetDuration("progress.heartbeatInterval"))
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	var last *cluster.ProgressSnapshot
+	first := true
+	c.Stream(func(w io.Writer) bool {
+		if !first {
+			select {
+			case <-changes:
+			case <-poll.C:
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now().UTC().Format(time.RFC3339))
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		}
+		first = false
+		current, err := cluster.GetProgress(clusterID)
+		if err != nil {
+			log.Errorf("Error getting the progress of cluster %d: %s", clusterID, err.Error())
+			c.SSEvent("error", err.Error())
+			return false
+		}
+		for _, event := range cluster.ProgressEvents(last, current) {
+			c.SSEvent(event.Name, event.Data)
+		}
+		if current == nil {
+			return false
+		}
+		last = current
+		return !untilDone || current.InProgress()
+	})
+}
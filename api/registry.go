@@ -0,0 +1,330 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/imagescan"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// addContainerRegistryRequest describes an image registry, the secret is the password secret of its basic auth
+// or the cloud secret of a cloud registry
+type addContainerRegistryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Host     string `json:"host" binding:"required"`
+	SecretID string `json:"secretId" binding:"required"`
+}
+
+// injectImagePullSecretRequest describes the namespaces of the image pull secrets of a registry,
+// the name of the secrets is the name of the registry by default
+type injectImagePullSecretRequest struct {
+	Namespaces []string `json:"namespaces" binding:"required"`
+	Name       string   `json:"name"`
+}
+
+// scanImageRequest describes an image to scan, the cached report is reused unless the scan is forced
+type scanImageRequest struct {
+	Image string `json:"image" binding:"required"`
+	Force bool   `json:"force"`
+}
+
+// scanImageResponse is the report of an image, the violations are the vulnerabilities the scanning policy of the
+// organization doesn't allow
+type scanImageResponse struct {
+	*imagescan.Report
+	Summary    map[string]int            `json:"summary"`
+	Violations []imagescan.Vulnerability `json:"violations,omitempty"`
+}
+
+// imageScanPolicyRequest describes the image scanning policy of an organization
+type imageScanPolicyRequest struct {
+	Enabled       bool     `json:"enabled"`
+	Severity      string   `json:"severity" binding:"required"`
+	IgnoreUnfixed bool     `json:"ignoreUnfixed"`
+	Ignored       []string `json:"ignored"`
+}
+
+// imageScanPolicyResponse is the image scanning policy of an organization
+type imageScanPolicyResponse struct {
+	*model.ImageScanPolicyModel
+	Ignored []string `json:"ignored"`
+}
+
+// ListContainerRegistries lists the image registries of the organization
+func ListContainerRegistries(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListContainerRegistries"})
+	registries, err := model.ListContainerRegistries(auth.GetCurrentOrganization(c.Request).ID)
+	if err != nil {
+		log.Errorf("Error during listing image registries: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing image registries",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, registries)
+}
+
+// AddContainerRegistry registers an image registry of the organization, its credentials are checked before
+// it's saved
+func AddContainerRegistry(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "AddContainerRegistry"})
+	var request addContainerRegistryRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	host := helm.NormalizeRegistryHost(request.Host)
+	var err error
+	if _, getErr := model.GetContainerRegistry(organizationID, request.Name); getErr == nil {
+		err = fmt.Errorf("duplicate image registry name: %s", request.Name)
+	} else if _, getErr := model.GetContainerRegistryByHost(organizationID, host); getErr == nil {
+		err = fmt.Errorf("the organization has a registry on %s", host)
+	}
+	if err == nil {
+		err = checkRegistrySecret(organizationID, request.SecretID)
+	}
+	if err == nil {
+		err = helm.CheckContainerRegistry(organizationID, request.SecretID, host)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	registry := &model.ContainerRegistryModel{
+		OrganizationID: organizationID,
+		Name:           request.Name,
+		Host:           host,
+		SecretID:       request.SecretID,
+	}
+	if err := registry.Save(); err != nil {
+		log.Errorf("Error during saving image registry: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving image registry",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, registry)
+}
+
+// DeleteContainerRegistry removes the image registry of the organization, the injected image pull secrets
+// aren't removed
+func DeleteContainerRegistry(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteContainerRegistry"})
+	registry, ok := getContainerRegistry(c)
+	if !ok {
+		return
+	}
+	if err := registry.Delete(); err != nil {
+		log.Errorf("Error during deleting image registry: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during deleting image registry",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// InjectImagePullSecrets writes the credentials of the image registry into image pull secrets of the namespaces
+// of the cluster, the pods of their default service accounts pull the images of the registry with them
+func InjectImagePullSecrets(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "InjectImagePullSecrets"})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	registry, ok := getContainerRegistry(c)
+	if !ok {
+		return
+	}
+	var request injectImagePullSecretRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if request.Name == "" {
+		request.Name = registry.Name
+	}
+	injections := []model.SecretInjectionModel{}
+	for _, namespace := range request.Namespaces {
+		injection, err := cluster.InjectImagePullSecret(commonCluster, registry, namespace, request.Name)
+		if err != nil {
+			log.Errorf("Error during injecting image pull secret into %s: %s", namespace, err.Error())
+			code := http.StatusBadRequest
+			if err == secret.ErrSecretNotFound {
+				code = http.StatusNotFound
+			}
+			c.JSON(code, components.ErrorResponse{
+				Code:    code,
+				Message: fmt.Sprintf("Error during injecting image pull secret into %s", namespace),
+				Error:   err.Error(),
+			})
+			return
+		}
+		injections = append(injections, *injection)
+	}
+	c.JSON(http.StatusCreated, injections)
+}
+
+// ScanImage scans the vulnerabilities of an image with the credentials of the registries of the organization
+func ScanImage(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ScanImage"})
+	var request scanImageRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	policy, err := cluster.ImageScanPolicy(organizationID)
+	var report *imagescan.Report
+	if err == nil {
+		report, err = cluster.ScanImage(organizationID, request.Image, request.Force)
+	}
+	if err != nil {
+		log.Errorf("Error during scanning image %s: %s", request.Image, err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during scanning image",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := scanImageResponse{Report: report, Summary: report.Summary()}
+	if policy != nil {
+		response.Violations = policy.Violations(report)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetImageScanPolicy returns the image scanning policy of the organization
+func GetImageScanPolicy(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "GetImageScanPolicy"})
+	policy, err := model.GetImageScanPolicy(auth.GetCurrentOrganization(c.Request).ID)
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting image scanning policy"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "The organization has no image scanning policy"
+		} else {
+			log.Errorf("Error during getting image scanning policy: %s", err.Error())
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, imageScanPolicyResponse{ImageScanPolicyModel: policy, Ignored: policy.GetIgnored()})
+}
+
+// SetImageScanPolicy creates or updates the image scanning policy of the organization, the images of its
+// deployments are scanned while it's enabled
+func SetImageScanPolicy(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "SetImageScanPolicy"})
+	var request imageScanPolicyRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !imagescan.ValidSeverity(request.Severity) {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid severity",
+			Error:   fmt.Sprintf("unknown severity %q, it must be UNKNOWN, LOW, MEDIUM, HIGH or CRITICAL", request.Severity),
+		})
+		return
+	}
+	organizationID := auth.GetCurrentOrganization(c.Request).ID
+	policy, err := model.GetImageScanPolicy(organizationID)
+	if model.IsErrorGormNotFound(err) {
+		policy, err = &model.ImageScanPolicyModel{OrganizationID: organizationID}, nil
+	}
+	if err == nil {
+		policy.Enabled = request.Enabled
+		policy.Severity = request.Severity
+		policy.IgnoreUnfixed = request.IgnoreUnfixed
+		policy.SetIgnored(request.Ignored)
+		err = policy.Save()
+	}
+	if err != nil {
+		log.Errorf("Error during saving image scanning policy: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving image scanning policy",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, imageScanPolicyResponse{ImageScanPolicyModel: policy, Ignored: policy.GetIgnored()})
+}
+
+// getContainerRegistry loads the image registry of the request, it aborts the request if it doesn't exist
+func getContainerRegistry(c *gin.Context) (*model.ContainerRegistryModel, bool) {
+	registry, err := model.GetContainerRegistry(auth.GetCurrentOrganization(c.Request).ID, c.Param("name"))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting image registry"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "Image registry not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return registry, true
+}
+
+// checkRegistrySecret checks that the secret of the organization can log in to an image registry
+func checkRegistrySecret(organizationID uint, secretID string) error {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(organizationID), 10), secretID)
+	if err != nil {
+		return err
+	}
+	switch item.SecretType {
+	case secret.Password, secret.Amazon, secret.Google, secret.Azure:
+		return nil
+	}
+	return fmt.Errorf("the secret of an image registry must be a %s, %s, %s or %s, not a %s",
+		secret.Password, secret.Amazon, secret.Google, secret.Azure, item.SecretType)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// TestRouteRequestBodies fails if a POST, PUT or PATCH route of the API set up in main.go has a handler which
+// isn't listed in requestBodies, so its body would be neither validated nor documented
+func TestRouteRequestBodies(t *testing.T) {
+
+	file, err := parser.ParseFile(token.NewFileSet(), "../main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Error during parsing main.go: %s", err.Error())
+	}
+	packages := map[string]string{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		packages[name] = path
+	}
+
+	// the groups of the API routes, the other routes (e.g. the logins of qor auth) have no JSON bodies
+	groups := map[string]bool{}
+	routes := 0
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			if len(node.Lhs) != 1 || len(node.Rhs) != 1 {
+				return true
+			}
+			group, ok := node.Lhs[0].(*ast.Ident)
+			call, isCall := node.Rhs[0].(*ast.CallExpr)
+			if !ok || !isCall || len(call.Args) == 0 {
+				return true
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || selector.Sel.Name != "Group" {
+				return true
+			}
+			parent, _ := selector.X.(*ast.Ident)
+			prefix, _ := call.Args[0].(*ast.BasicLit)
+			if parent != nil && (groups[parent.Name] || prefix != nil && strings.HasPrefix(prefix.Value, `"/api/`)) {
+				groups[group.Name] = true
+			}
+		case *ast.CallExpr:
+			selector, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || len(node.Args) < 2 {
+				return true
+			}
+			group, ok := selector.X.(*ast.Ident)
+			method := selector.Sel.Name
+			if !ok || !groups[group.Name] || (method != "POST" && method != "PUT" && method != "PATCH") {
+				return true
+			}
+			routes++
+			path, _ := node.Args[0].(*ast.BasicLit)
+			handler, ok := node.Args[len(node.Args)-1].(*ast.SelectorExpr)
+			if !ok {
+				t.Errorf("Expected a package level handler of %s %s", method, path.Value)
+				return true
+			}
+			name := packages[handler.X.(*ast.Ident).Name] + "." + handler.Sel.Name
+			if describedRequestBody(name) == nil {
+				t.Errorf("The request body of %s isn't described (%s %s)", name, method, path.Value)
+			}
+		}
+		return true
+	})
+	if routes == 0 {
+		t.Fatal("Expected POST, PUT and PATCH routes in main.go")
+	}
+}
+
+func TestValidateDescribedRequestBodies(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ValidateRequestBody)
+	passed := func(c *gin.Context) { c.AbortWithStatus(http.StatusNoContent) }
+	router.POST("/api/v1/orgs/:orgid/clusters", passed, CreateCluster)
+	router.POST("/api/v1/orgs/:orgid/deployments/:approvalid/approve", passed, ApproveDeployment)
+	router.POST("/api/v1/orgs/:orgid/clusters/:id/suspend", passed, SuspendCluster)
+	router.POST("/api/v1/tokens", passed, auth.GenerateToken)
+	handler := apiversion.Handler(router, apiversion.V2)
+
+	cases := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{name: "partial body", path: "/api/v1/orgs/1/clusters", body: `{"profile": "small"}`, expectedCode: http.StatusNoContent},
+		{name: "partial body types", path: "/api/v1/orgs/1/clusters", body: `{"profile": 1}`, expectedCode: http.StatusBadRequest},
+		{name: "partial body required", path: "/api/v1/orgs/1/clusters", body: ``, expectedCode: http.StatusBadRequest},
+		{name: "optional body", path: "/api/v1/orgs/1/deployments/1/approve", body: ``, expectedCode: http.StatusNoContent},
+		{name: "optional body types", path: "/api/v1/orgs/1/deployments/1/approve", body: `{"comment": 1}`, expectedCode: http.StatusBadRequest},
+		{name: "no body", path: "/api/v1/orgs/1/clusters/1/suspend", body: ``, expectedCode: http.StatusNoContent},
+		{name: "v1 request of a v2 body", path: "/api/v1/tokens", body: `{"name": 1}`, expectedCode: http.StatusNoContent},
+		{name: "v2 body", path: "/api/v2/tokens", body: `{"name": 1}`, expectedCode: http.StatusBadRequest},
+		{name: "empty v2 body", path: "/api/v2/tokens", body: ``, expectedCode: http.StatusNoContent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			request.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("Expected %v, got: %v %v", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/scm"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// The query parameters of the deployments and the upgrades: scheduleAt is the RFC3339 time they're scheduled for,
+// maintenanceWindow=true restricts them to the maintenance window of the cluster
+const (
+	scheduleAtParameter        = "scheduleAt"
+	maintenanceWindowParameter = "maintenanceWindow"
+)
+
+// maintenanceWindowRequest describes when the maintenance windows of a cluster open and how long they last
+type maintenanceWindowRequest struct {
+	Start    string `json:"start" binding:"required"`
+	Duration string `json:"duration" binding:"required"`
+	TimeZone string `json:"timeZone"`
+	Enforced bool   `json:"enforced"`
+}
+
+// maintenanceWindowResponse is the maintenance window of a cluster and whether it's open now
+type maintenanceWindowResponse struct {
+	*model.MaintenanceWindowModel
+	Open bool `json:"open"`
+}
+
+// the deployments are run by the scheduler with the helpers of their handlers
+func init() {
+	cluster.ScheduledOperationRunners[model.ScheduledDeploymentCreate] = runScheduledDeployment
+	cluster.ScheduledOperationRunners[model.ScheduledDeploymentUpgrade] = runScheduledDeployment
+}
+
+// scheduleOperation queues the operation of the request if it's scheduled for later, restricted to the maintenance
+// window of the cluster or requested outside the enforced window of the cluster; it returns false if the operation
+// runs now, otherwise the request is responded with the queued operation or aborted
+func scheduleOperation(c *gin.Context, commonCluster cluster.CommonCluster, kind, releaseName string, request interface{}) bool {
+	log := logger.WithFields(logrus.Fields{"tag": "ScheduleOperation"})
+	now := time.Now().UTC()
+	runAt, scheduled := now, false
+	if value := c.Query(scheduleAtParameter); value != "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid scheduleAt, it's an RFC3339 time",
+				Error:   err.Error(),
+			})
+			return true
+		}
+		if at.After(now) {
+			runAt, scheduled = at.UTC(), true
+		}
+	}
+	inWindow := c.Query(maintenanceWindowParameter) == "true"
+	window, err := model.GetMaintenanceWindow(commonCluster.GetID())
+	if err == nil {
+		inWindow = inWindow || window.Enforced
+	} else if !model.IsErrorGormNotFound(err) {
+		log.Errorf("Error during getting maintenance window: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during getting maintenance window",
+			Error:   err.Error(),
+		})
+		return true
+	} else if inWindow {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "The cluster has no maintenance window",
+			Error:   err.Error(),
+		})
+		return true
+	}
+	if inWindow {
+		next, err := cluster.NextMaintenanceWindow(*window, runAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+				Error:   err.Error(),
+			})
+			return true
+		}
+		if next.After(runAt) {
+			runAt, scheduled = next.UTC(), true
+		}
+	}
+	if !scheduled {
+		return false
+	}
+
+	data, err := json.Marshal(request)
+	operation := &model.ScheduledOperationModel{
+		OrganizationID:    commonCluster.GetOrg(),
+		ClusterID:         commonCluster.GetID(),
+		Kind:              kind,
+		ReleaseName:       releaseName,
+		Request:           string(data),
+		RunAt:             runAt,
+		MaintenanceWindow: inWindow,
+		Status:            model.ScheduledQueued,
+		RequestedBy:       auth.GetCurrentActor(c),
+	}
+	commit := commitFromRequest(c)
+	if commit != nil {
+		operation.CommitRepository, operation.CommitSHA = commit.Repository, commit.SHA
+	}
+	if err == nil {
+		err = operation.Save()
+	}
+	if err != nil {
+		log.Errorf("Error during saving scheduled operation: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving scheduled operation",
+			Error:   err.Error(),
+		})
+		return true
+	}
+	message := fmt.Sprintf("The %s operation %d of cluster %s is scheduled for %s", kind, operation.ID, commonCluster.GetName(), runAt.Format(time.RFC3339))
+	log.Info(message)
+	recordClusterEvent(c, commonCluster, notify.EventOperationScheduled, releaseName, message)
+	go reportCommitStatus(commonCluster, releaseName, commit, scm.StatePending, "Scheduled for "+runAt.Format(time.RFC3339))
+	c.JSON(http.StatusAccepted, operation)
+	return true
+}
+
+// runScheduledDeployment installs or upgrades the release of the scheduled deployment with the helpers of the
+// handlers, the error they respond is the error of the operation
+func runScheduledDeployment(commonCluster cluster.CommonCluster, operation *model.ScheduledOperationModel) error {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	if err != nil {
+		return err
+	}
+	organization := &auth.Organization{ID: operation.OrganizationID}
+	c.Request = request.WithContext(context.WithValue(request.Context(), auth.CurrentOrganization, organization))
+
+	var commit *ciCommit
+	if operation.CommitSHA != "" {
+		commit = &ciCommit{Repository: operation.CommitRepository, SHA: operation.CommitSHA}
+	}
+	switch operation.Kind {
+	case model.ScheduledDeploymentCreate:
+		var deployment createDeploymentRequest
+		if err := json.Unmarshal([]byte(operation.Request), &deployment); err != nil {
+			return err
+		}
+		if response, ok := installDeployment(c, commonCluster, &deployment, commit); ok {
+			operation.ReleaseName = response.ReleaseName
+		}
+	case model.ScheduledDeploymentUpgrade:
+		var upgrade upgradeDeploymentRequest
+		if err := json.Unmarshal([]byte(operation.Request), &upgrade); err != nil {
+			return err
+		}
+		upgradeRelease(c, commonCluster, operation.ReleaseName, &upgrade, commit)
+	default:
+		return fmt.Errorf("unknown operation: %s", operation.Kind)
+	}
+	if c.Writer.Written() {
+		var response components.ErrorResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		if response.Error == "" {
+			response.Error = response.Message
+		}
+		return errors.New(response.Error)
+	}
+	return nil
+}
+
+// ListScheduledOperations lists the scheduled operations of the organization in the order they run, filtered by
+// the status and the clusterId query parameters
+func ListScheduledOperations(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListScheduledOperations"})
+	var clusterID uint64
+	if value := c.Query("clusterId"); value != "" {
+		var err error
+		if clusterID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, components.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid clusterId",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	operations, err := model.ListScheduledOperations(auth.GetCurrentOrganization(c.Request).ID, strings.ToUpper(c.Query("status")), uint(clusterID))
+	if err != nil {
+		log.Errorf("Error during listing scheduled operations: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during listing scheduled operations",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, operations)
+}
+
+// GetScheduledOperation returns a scheduled operation of the organization
+func GetScheduledOperation(c *gin.Context) {
+	if operation, ok := getScheduledOperation(c); ok {
+		c.JSON(http.StatusOK, operation)
+	}
+}
+
+// CancelScheduledOperation cancels a queued operation, the caller needs the policy permission of the operation on
+// its cluster
+func CancelScheduledOperation(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "CancelScheduledOperation"})
+	operation, ok := getScheduledOperation(c)
+	if !ok {
+		return
+	}
+	var modelCluster model.ClusterModel
+	err := model.GetDB().Where(&model.ClusterModel{ID: operation.ClusterID, OrganizationId: operation.OrganizationID}).First(&modelCluster).Error
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting cluster"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "Cluster not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, err := cluster.GetCommonClusterFromModel(&modelCluster)
+	if err != nil {
+		log.Errorf("GetCommonClusterFromModel failed: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	action := auth.PolicyActionDeploymentUpdate
+	if operation.Kind == model.ScheduledClusterUpgrade {
+		action = auth.PolicyActionClusterUpdate
+	}
+	if !authorizePolicies(c, action, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	cancelled, err := operation.Cancel(auth.GetCurrentActor(c))
+	if err != nil {
+		log.Errorf("Error during cancelling scheduled operation: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during cancelling scheduled operation",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, components.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "The operation isn't queued anymore",
+			Error:   "the operation is started or cancelled already",
+		})
+		return
+	}
+	recordClusterEvent(c, commonCluster, notify.EventOperationCancelled, operation.ReleaseName,
+		fmt.Sprintf("The %s operation %d of cluster %s is cancelled", operation.Kind, operation.ID, commonCluster.GetName()))
+	if operation.CommitSHA != "" {
+		commit := &ciCommit{Repository: operation.CommitRepository, SHA: operation.CommitSHA}
+		go reportCommitStatus(commonCluster, operation.ReleaseName, commit, scm.StateFailure, "Deployment cancelled")
+	}
+	c.JSON(http.StatusOK, operation)
+}
+
+// getScheduledOperation loads the scheduled operation of the operationid parameter, it aborts the request if it
+// doesn't exist
+func getScheduledOperation(c *gin.Context) (*model.ScheduledOperationModel, bool) {
+	id, err := strconv.ParseUint(c.Param("operationid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Scheduled operation not found",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	operation, err := model.GetScheduledOperation(auth.GetCurrentOrganization(c.Request).ID, uint(id))
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Error during getting scheduled operation"
+		if model.IsErrorGormNotFound(err) {
+			code, message = http.StatusNotFound, "Scheduled operation not found"
+		}
+		c.JSON(code, components.ErrorResponse{
+			Code:    code,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return operation, true
+}
+
+// GetMaintenanceWindow sends back the maintenance window of the cluster and whether it's open
+func GetMaintenanceWindow(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	window, err := model.GetMaintenanceWindow(commonCluster.GetID())
+	if model.IsErrorGormNotFound(err) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "The cluster has no maintenance window",
+			Error:   err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Errorf("Error during getting maintenance window: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during getting maintenance window",
+			Error:   err.Error(),
+		})
+		return
+	}
+	open, _ := cluster.InMaintenanceWindow(*window, time.Now())
+	c.JSON(http.StatusOK, maintenanceWindowResponse{MaintenanceWindowModel: window, Open: open})
+}
+
+// SetMaintenanceWindow creates or replaces the maintenance window of the cluster
+func SetMaintenanceWindow(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	var request maintenanceWindowRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	window := &model.MaintenanceWindowModel{
+		ClusterModelID: commonCluster.GetID(),
+		Start:          request.Start,
+		Duration:       request.Duration,
+		TimeZone:       request.TimeZone,
+		Enforced:       request.Enforced,
+	}
+	if err := cluster.ValidateMaintenanceWindow(*window); err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := model.SaveMaintenanceWindow(window); err != nil {
+		log.Errorf("Error during saving maintenance window: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during saving maintenance window",
+			Error:   err.Error(),
+		})
+		return
+	}
+	open, _ := cluster.InMaintenanceWindow(*window, time.Now())
+	c.JSON(http.StatusOK, maintenanceWindowResponse{MaintenanceWindowModel: window, Open: open})
+}
+
+// DeleteMaintenanceWindow deletes the maintenance window of the cluster, the operations queued for the window run
+// when they're due
+func DeleteMaintenanceWindow(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := model.DeleteMaintenanceWindow(commonCluster.GetID()); err != nil {
+		log.Errorf("Error during deleting maintenance window: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during deleting maintenance window",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -2,10 +2,14 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/banzaicloud/banzai-types/components"
 	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
 	"github.com/banzaicloud/pipeline/secret"
+	"github.com/banzaicloud/pipeline/vaultclient"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/go-errors/errors"
@@ -63,6 +67,7 @@ func AddSecrets(c *gin.Context) {
 	}
 
 	log.Infof("Secret stored at: %s/%s", organizationID, secretID)
+	secret.VerifyAsync(organizationID, secretID)
 
 	c.JSON(http.StatusCreated, secret.CreateSecretResponse{
 		Name:       createSecretRequest.Name,
@@ -79,7 +84,10 @@ func ListSecrets(c *gin.Context) {
 
 	log.Info("Get organization id and secret type from params")
 	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
-	secretType := c.Param("type")
+	secretType := c.Query("type")
+	if _, ok := secret.DefaultRules[c.Param("secretid")]; ok {
+		secretType = c.Param("secretid")
+	}
 	log.Infof("Organization id: %s", organizationID)
 	log.Infof("Secret type: %s", secretType)
 
@@ -107,6 +115,147 @@ func ListSecrets(c *gin.Context) {
 	}
 }
 
+// GetSecret returns the secret with the given secret id without its values,
+// for compatibility a secret type in place of the id lists the secrets of that type
+func GetSecret(c *gin.Context) {
+	if _, ok := secret.DefaultRules[c.Param("secretid")]; ok {
+		ListSecrets(c)
+		return
+	}
+
+	log = logger.WithFields(logrus.Fields{"tag": "Get Secret"})
+	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
+	secretID := c.Param("secretid")
+	log.Infof("Organization id: %s, secret id: %s", organizationID, secretID)
+
+	item, err := secret.Store.Get(organizationID, secretID)
+	if err == secret.ErrSecretNotFound {
+		c.AbortWithStatusJSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Secret not found",
+			Error:   err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Errorf("Error during getting secret: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during getting secret",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// abortWithSecretError responds 404 for unknown secrets and versions, 403 for writes of shared secrets, 500 otherwise
+func abortWithSecretError(c *gin.Context, message string, err error) {
+	code := http.StatusInternalServerError
+	if err == secret.ErrSecretNotFound || err == secret.ErrSecretVersionNotFound {
+		code = http.StatusNotFound
+	} else if err == secret.ErrSecretReadOnly {
+		code = http.StatusForbidden
+	} else if vaultclient.IsUnavailable(err) {
+		code = http.StatusServiceUnavailable
+	} else {
+		log.Errorf("%s: %s", message, err.Error())
+	}
+	c.AbortWithStatusJSON(code, components.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+// UpdateSecret stores a new version of the secret with the given secret id
+func UpdateSecret(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Update Secret"})
+	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
+	secretID := c.Param("secretid")
+
+	var updateSecretRequest secret.CreateSecretRequest
+	if err := c.ShouldBind(&updateSecretRequest); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during binding",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := updateSecretRequest.Validate(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Validation error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if _, err := secret.Store.Get(organizationID, secretID); err != nil {
+		abortWithSecretError(c, "Error during getting secret", err)
+		return
+	}
+	if err := secret.Store.Store(organizationID, secretID, updateSecretRequest); err != nil {
+		abortWithSecretError(c, "Error during store", err)
+		return
+	}
+	log.Infof("Secret updated at: %s/%s", organizationID, secretID)
+	secret.VerifyAsync(organizationID, secretID)
+	go cluster.SyncInjectedSecrets(secretID)
+	c.JSON(http.StatusOK, secret.CreateSecretResponse{
+		Name:       updateSecretRequest.Name,
+		SecretType: updateSecretRequest.SecretType,
+		SecretID:   secretID,
+	})
+}
+
+// ListSecretVersions returns the kept versions of the secret with the given secret id
+func ListSecretVersions(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "List Secret Versions"})
+	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
+
+	versions, err := secret.Store.Versions(organizationID, c.Param("secretid"))
+	if err != nil {
+		abortWithSecretError(c, "Error during listing secret versions", err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// rollbackSecretRequest is the version a secret is rolled back to
+type rollbackSecretRequest struct {
+	Version int `json:"version" binding:"required"`
+}
+
+// RollbackSecret restores an earlier version of the secret with the given secret id as its new version
+func RollbackSecret(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Rollback Secret"})
+	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
+	secretID := c.Param("secretid")
+
+	var request rollbackSecretRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during binding",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := secret.Store.Rollback(organizationID, secretID, request.Version); err != nil {
+		abortWithSecretError(c, "Error during rolling back secret", err)
+		return
+	}
+	log.Infof("Secret %s/%s rolled back to version %d", organizationID, secretID, request.Version)
+	secret.VerifyAsync(organizationID, secretID)
+	go cluster.SyncInjectedSecrets(secretID)
+	versions, err := secret.Store.Versions(organizationID, secretID)
+	if err != nil {
+		abortWithSecretError(c, "Error during listing secret versions", err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
 // DeleteSecrets delete a secret with the given secret id
 func DeleteSecrets(c *gin.Context) {
 	log = logger.WithFields(logrus.Fields{"tag": "Delete Secrets"})
@@ -119,20 +268,166 @@ func DeleteSecrets(c *gin.Context) {
 	secretID := c.Param("secretid")
 
 	if err := secret.Store.Delete(organizationID, secretID); err != nil {
-		log.Errorf("Error during deleting secrets: %s", err.Error())
-		code := http.StatusInternalServerError
-		resp := components.ErrorResponse{
-			Code:    code,
-			Message: "Error during deleting secrets",
-			Error:   err.Error(),
-		}
-		c.AbortWithStatusJSON(code, resp)
+		abortWithSecretError(c, "Error during deleting secrets", err)
 	} else {
 		log.Info("Delete secrets succeeded")
 		c.Status(http.StatusNoContent)
 	}
 }
 
+// VerifySecret checks the cloud credentials of the secret with the given secret id against their provider
+// and returns the secret with its verification status
+func VerifySecret(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Verify Secret"})
+	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
+	secretID := c.Param("secretid")
+
+	if err := secret.Verify(organizationID, secretID); err != nil {
+		abortWithSecretError(c, "Error during verifying secret", err)
+		return
+	}
+	item, err := secret.Store.Get(organizationID, secretID)
+	if err != nil {
+		abortWithSecretError(c, "Error during getting secret", err)
+		return
+	}
+	item.Values = nil
+	c.JSON(http.StatusOK, item)
+}
+
+// checkClusterSecret refuses to provision clusters with invalid cloud credentials,
+// credentials which haven't been verified yet are verified first
+func checkClusterSecret(c *gin.Context, commonCluster cluster.CommonCluster) bool {
+	item, err := verifyClusterSecret(commonCluster)
+	if err != nil {
+		log.Errorf("Error during getting secret: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during getting secret",
+			Error:   err.Error(),
+		})
+		return false
+	}
+	if item.Status == secret.StatusInvalid {
+		log.Infof("Secret %s has invalid credentials: %s", item.ID, item.StatusMessage)
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid cloud credentials",
+			Error:   item.StatusMessage,
+		})
+		return false
+	}
+	return true
+}
+
+// verifyClusterSecret loads the secret of the cluster, the pending secrets are verified first
+func verifyClusterSecret(commonCluster cluster.CommonCluster) (*secret.SecretsItemResponse, error) {
+	organizationID := strconv.FormatUint(uint64(commonCluster.GetOrg()), 10)
+	item, err := cluster.GetSecret(commonCluster)
+	if err == nil && item.Status == secret.StatusPending {
+		if err = secret.Verify(organizationID, commonCluster.GetSecretID()); err == nil {
+			item, err = cluster.GetSecret(commonCluster)
+		}
+	}
+	return item, err
+}
+
+// getOwnSecret loads a secret of the current organization, shared secrets can't be shared further
+func getOwnSecret(c *gin.Context) (*secret.SecretsItemResponse, bool) {
+	item, err := secret.Store.Get(auth.GetCurrentOrganization(c.Request).IDString(), c.Param("secretid"))
+	if err == nil && item.SharedBy != "" {
+		err = secret.ErrSecretReadOnly
+	}
+	if err != nil {
+		abortWithSecretError(c, "Error during getting secret", err)
+		return nil, false
+	}
+	return item, true
+}
+
+// shareSecretRequest is the organization a secret is shared with
+type shareSecretRequest struct {
+	OrganizationID uint `json:"organizationId" binding:"required"`
+}
+
+// ShareSecret grants read access to the secret with the given secret id to another organization
+func ShareSecret(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Share Secret"})
+	var request shareSecretRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during binding",
+			Error:   err.Error(),
+		})
+		return
+	}
+	organization := auth.GetCurrentOrganization(c.Request)
+	if request.OrganizationID == organization.ID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid organization",
+			Error:   "a secret can't be shared with its own organization",
+		})
+		return
+	}
+	item, ok := getOwnSecret(c)
+	if !ok {
+		return
+	}
+	if err := model.GetDB().First(&auth.Organization{}, request.OrganizationID).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Organization not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	grant := &secret.SecretGrant{
+		OrganizationID:        organization.IDString(),
+		SecretID:              item.ID,
+		GranteeOrganizationID: strconv.FormatUint(uint64(request.OrganizationID), 10),
+	}
+	if err := secret.Grants.Grant(grant); err != nil {
+		abortWithSecretError(c, "Error during sharing secret", err)
+		return
+	}
+	log.Infof("Secret %s/%s shared with organization %s", grant.OrganizationID, grant.SecretID, grant.GranteeOrganizationID)
+	c.JSON(http.StatusCreated, grant)
+}
+
+// ListSecretGrants returns the organizations the secret with the given secret id is shared with
+func ListSecretGrants(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "List Secret Grants"})
+	item, ok := getOwnSecret(c)
+	if !ok {
+		return
+	}
+	grants, err := secret.Grants.List(auth.GetCurrentOrganization(c.Request).IDString(), item.ID)
+	if err != nil {
+		abortWithSecretError(c, "Error during listing secret grants", err)
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// RevokeSecretGrant revokes the read access of an organization to the secret with the given secret id
+func RevokeSecretGrant(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Revoke Secret Grant"})
+	item, ok := getOwnSecret(c)
+	if !ok {
+		return
+	}
+	organizationID := auth.GetCurrentOrganization(c.Request).IDString()
+	if err := secret.Grants.Revoke(organizationID, item.ID, c.Param("granteeid")); err != nil {
+		abortWithSecretError(c, "Error during revoking secret grant", err)
+		return
+	}
+	log.Infof("Secret %s/%s unshared with organization %s", organizationID, item.ID, c.Param("granteeid"))
+	c.Status(http.StatusNoContent)
+}
+
 // ListAllowedSecretTypes returns the allowed secret types and the required keys
 func ListAllowedSecretTypes(c *gin.Context) {
 	log = logger.WithFields(logrus.Fields{"tag": "List allowed types/required keys"})
@@ -184,3 +479,44 @@ func IsValidSecretType(secretType string) error {
 	}
 	return nil
 }
+
+// RewrapSecrets re-encrypts the data keys of the values encrypted in the database with the latest version
+// of the transit key, with ?rotate=true the transit key is rotated first
+func RewrapSecrets(c *gin.Context) {
+	log = logger.WithFields(logrus.Fields{"tag": "Rewrap Secrets"})
+
+	if secret.Encryption == nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Secret encryption is disabled",
+			Error:   "secrets.encryption is none",
+		})
+		return
+	}
+
+	if c.Query("rotate") == "true" {
+		log.Info("Rotating transit key")
+		if err := secret.Encryption.RotateKey(); err != nil {
+			log.Errorf("Error during rotating transit key: %s", err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Error during rotating transit key",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	rewrapped, err := cluster.RewrapK8sConfigs()
+	if err != nil {
+		log.Errorf("Error during rewrapping secrets: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during rewrapping secrets",
+			Error:   err.Error(),
+		})
+		return
+	}
+	log.Infof("Rewrapped %d kubeconfigs", rewrapped)
+	c.JSON(http.StatusOK, gin.H{"rewrapped": rewrapped})
+}
@@ -17,6 +17,7 @@ func TestIsValidSecretType(t *testing.T) {
 		{name: "Amazon secret type", secretType: secret.Amazon, error: nil},
 		{name: "Azure secret type", secretType: secret.Azure, error: nil},
 		{name: "Google secret type", secretType: secret.Google, error: nil},
+		{name: "SSH secret type", secretType: secret.SSH, error: nil},
 		{name: "not supported secret type", secretType: invalidSecretType, error: api.NotSupportedSecretType},
 	}
 
@@ -266,6 +267,8 @@ var (
 			Name:       secretName,
 			SecretType: secret.Amazon,
 			Values:     nil,
+			Version:    1,
+			Status:     secret.StatusPending,
 		},
 	}
 
@@ -275,6 +278,8 @@ var (
 			Name:       secretName,
 			SecretType: secret.Azure,
 			Values:     nil,
+			Version:    1,
+			Status:     secret.StatusPending,
 		},
 	}
 
@@ -284,6 +289,8 @@ var (
 			Name:       secretName,
 			SecretType: secret.Google,
 			Values:     nil,
+			Version:    1,
+			Status:     secret.StatusPending,
 		},
 	}
 
@@ -293,17 +300,23 @@ var (
 			Name:       secretName,
 			SecretType: secret.Amazon,
 			Values:     nil,
+			Version:    1,
+			Status:     secret.StatusPending,
 		},
 		{
 			ID:         secretIdAzure,
 			Name:       secretName,
 			SecretType: secret.Azure,
 			Values:     nil,
+			Version:    1,
+			Status:     secret.StatusPending,
 		}, {
 			ID:         secretIdGoogle,
 			Name:       secretName,
 			SecretType: secret.Google,
 			Values:     nil,
+			Version:    1,
+			Status:     secret.StatusPending,
 		},
 	}
 )
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetClusterSSHKey sends back the SSH key pair of the nodes of the cluster, the private key is plain text by default
+func GetClusterSSHKey(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagGetCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	key, err := cluster.GetSSHKey(commonCluster)
+	if err != nil {
+		log.Errorf("Error during getting SSH key: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error during getting SSH key",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if key == nil {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Cluster has no SSH key",
+			Error:   "Cluster has no SSH key",
+		})
+		return
+	}
+	log.Infof("SSH key of cluster %s downloaded", commonCluster.GetName())
+
+	switch c.NegotiateFormat(gin.MIMEPlain, gin.MIMEJSON) {
+	case gin.MIMEJSON:
+		c.JSON(http.StatusOK, key)
+	default:
+		c.String(http.StatusOK, key.PrivateKey)
+	}
+}
+
+// RotateClusterSSHKey replaces the SSH key pair of the cluster, the public key of the new pair is sent back
+func RotateClusterSSHKey(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	key, err := cluster.RotateSSHKey(commonCluster)
+	if err == nil {
+		err = commonCluster.Persist()
+	}
+	if err != nil {
+		log.Errorf("Error during rotating SSH key: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error during rotating SSH key",
+			Error:   err.Error(),
+		})
+		return
+	}
+	log.Infof("SSH key of cluster %s rotated", commonCluster.GetName())
+	key.PrivateKey = ""
+	c.JSON(http.StatusOK, key)
+}
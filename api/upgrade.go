@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpgradeCluster upgrades the Kubernetes version of a running cluster after the pre-flight checks, the control plane
+// and the node pools are upgraded in the background and their progress is in the steps of the cluster status
+func UpgradeCluster(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": constants.TagUpdateCluster})
+	var request cluster.UpgradeRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	commonCluster, ok := GetCommonClusterFromRequest(c)
+	if ok != true {
+		return
+	}
+	if !authorizePolicies(c, auth.PolicyActionClusterUpdate, clusterPolicyAttributes(commonCluster)) {
+		return
+	}
+	if err := cluster.CheckUpgrade(commonCluster, request); err != nil {
+		log.Errorf("Upgrade check failed: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	if scheduleOperation(c, commonCluster, model.ScheduledClusterUpgrade, "", request) {
+		return
+	}
+	if updateClusterInBackground(c, commonCluster, func() error {
+		return cluster.UpgradeCluster(commonCluster, request)
+	}) {
+		recordClusterEvent(c, commonCluster, notify.EventClusterUpdated, commonCluster.GetName(), fmt.Sprintf("Cluster %s upgrade to Kubernetes %s started", commonCluster.GetName(), request.Version))
+	}
+}
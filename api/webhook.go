@@ -0,0 +1,305 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Length of the delivery log of a webhook
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// webhookRequest describes a webhook subscription of the organization, it receives every event without types
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret"`
+	Types  []string `json:"types"`
+}
+
+// webhookResponse describes a webhook subscription, the secret isn't sent back
+type webhookResponse struct {
+	*model.WebhookModel
+	Types  []string `json:"types"`
+	Signed bool     `json:"signed"`
+}
+
+// newWebhookResponse returns the response of the webhook
+func newWebhookResponse(webhook *model.WebhookModel) webhookResponse {
+	return webhookResponse{WebhookModel: webhook, Types: webhook.GetTypes(), Signed: webhook.Secret != ""}
+}
+
+// ListWebhooks lists the webhook subscriptions of the organization
+func ListWebhooks(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListWebhooks"})
+	webhooks, err := model.ListWebhooks(auth.GetCurrentOrganization(c.Request).ID)
+	if err != nil {
+		log.Errorf("Error listing webhooks: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing webhooks",
+			Error:   err.Error(),
+		})
+		return
+	}
+	response := []webhookResponse{}
+	for i := range webhooks {
+		response = append(response, newWebhookResponse(&webhooks[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWebhook subscribes a webhook to the events of the organization
+func CreateWebhook(c *gin.Context) {
+	saveWebhook(c, &model.WebhookModel{OrganizationID: auth.GetCurrentOrganization(c.Request).ID}, http.StatusCreated)
+}
+
+// GetWebhook sends back a webhook subscription of the organization
+func GetWebhook(c *gin.Context) {
+	webhook, ok := getWebhookFromRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(webhook))
+}
+
+// UpdateWebhook replaces the URL, the secret and the event types of a webhook subscription
+func UpdateWebhook(c *gin.Context) {
+	webhook, ok := getWebhookFromRequest(c)
+	if !ok {
+		return
+	}
+	saveWebhook(c, webhook, http.StatusOK)
+}
+
+// saveWebhook validates and saves the webhook of the request
+func saveWebhook(c *gin.Context, webhook *model.WebhookModel, status int) {
+	log := logger.WithFields(logrus.Fields{"tag": "SaveWebhook"})
+	var request webhookRequest
+	if err := c.BindJSON(&request); err != nil {
+		log.Errorf("Error parsing request: %s", err.Error())
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := validateWebhook(request); err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+		return
+	}
+	webhook.URL, webhook.Secret = request.URL, request.Secret
+	webhook.SetTypes(request.Types)
+	if err := webhook.Save(); err != nil {
+		log.Errorf("Error saving webhook: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error saving webhook",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(status, newWebhookResponse(webhook))
+}
+
+// validateWebhook checks the URL and the event types of the webhook
+func validateWebhook(request webhookRequest) error {
+	webhookURL, err := url.Parse(request.URL)
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+		return fmt.Errorf("invalid webhook URL: %q", request.URL)
+	}
+	if utils.IsInternalHost(webhookURL.Hostname()) {
+		return fmt.Errorf("the webhook URL %q is an internal address", request.URL)
+	}
+	for _, eventType := range request.Types {
+		if !isEventType(eventType) {
+			return fmt.Errorf("unknown event type: %q", eventType)
+		}
+	}
+	return nil
+}
+
+// DeleteWebhook deletes a webhook subscription of the organization with its delivery log
+func DeleteWebhook(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeleteWebhook"})
+	webhook, ok := getWebhookFromRequest(c)
+	if !ok {
+		return
+	}
+	if err := webhook.Delete(); err != nil {
+		log.Errorf("Error deleting webhook: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error deleting webhook",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PingWebhook sends a test event to a webhook, the delivery is sent back
+func PingWebhook(c *gin.Context) {
+	webhook, ok := getWebhookFromRequest(c)
+	if !ok {
+		return
+	}
+	queueDelivery(c, func() (*model.WebhookDeliveryModel, error) {
+		return notify.PingWebhook(webhook)
+	})
+}
+
+// ListWebhookDeliveries lists the latest deliveries of a webhook with the responses of the webhook, filtered by
+// the status query parameter; the limit query parameter is the number of the deliveries
+func ListWebhookDeliveries(c *gin.Context) {
+	log := logger.WithFields(logrus.Fields{"tag": "ListWebhookDeliveries"})
+	webhook, ok := getWebhookFromRequest(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDeliveryLimit)))
+	if err == nil && (limit < 1 || limit > maxDeliveryLimit) {
+		err = fmt.Errorf("the limit must be between 1 and %d", maxDeliveryLimit)
+	}
+	if err == nil && status != "" && status != model.WebhookDeliveryPending && status != model.WebhookDeliveryDelivered && status != model.WebhookDeliveryFailed {
+		err = fmt.Errorf("unknown delivery status: %q", status)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid delivery filter",
+			Error:   err.Error(),
+		})
+		return
+	}
+	deliveries, err := model.ListWebhookDeliveries(webhook.ID, status, limit)
+	if err != nil {
+		log.Errorf("Error listing webhook deliveries: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error listing webhook deliveries",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDelivery sends back a delivery of a webhook
+func GetWebhookDelivery(c *gin.Context) {
+	delivery, _, ok := getWebhookDeliveryFromRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhookDelivery queues a new delivery of the payload of a delivery to its webhook
+func RedeliverWebhookDelivery(c *gin.Context) {
+	delivery, webhook, ok := getWebhookDeliveryFromRequest(c)
+	if !ok {
+		return
+	}
+	queueDelivery(c, func() (*model.WebhookDeliveryModel, error) {
+		return notify.QueueDelivery(webhook, delivery.EventID, delivery.EventType, []byte(delivery.Payload))
+	})
+}
+
+// queueDelivery responds the queued delivery, it's attempted in the background
+func queueDelivery(c *gin.Context, queue func() (*model.WebhookDeliveryModel, error)) {
+	log := logger.WithFields(logrus.Fields{"tag": "QueueDelivery"})
+	delivery, err := queue()
+	if err != nil {
+		log.Errorf("Error queueing webhook delivery: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error queueing webhook delivery",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// getWebhookFromRequest loads the webhook of the webhookid path parameter of the organization of the request
+func getWebhookFromRequest(c *gin.Context) (*model.WebhookModel, bool) {
+	id, err := strconv.ParseUint(c.Param("webhookid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid webhook ID",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	webhook, err := model.GetWebhook(auth.GetCurrentOrganization(c.Request).ID, uint(id))
+	if model.IsErrorGormNotFound(err) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Webhook not found",
+			Error:   err.Error(),
+		})
+		return nil, false
+	} else if err != nil {
+		log.Errorf("Error getting webhook: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting webhook",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return webhook, true
+}
+
+// getWebhookDeliveryFromRequest loads the delivery of the deliveryid path parameter and its webhook
+func getWebhookDeliveryFromRequest(c *gin.Context) (*model.WebhookDeliveryModel, *model.WebhookModel, bool) {
+	webhook, ok := getWebhookFromRequest(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("deliveryid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, components.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid delivery ID",
+			Error:   err.Error(),
+		})
+		return nil, nil, false
+	}
+	delivery, err := model.GetWebhookDelivery(webhook.ID, uint(id))
+	if model.IsErrorGormNotFound(err) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Webhook delivery not found",
+			Error:   err.Error(),
+		})
+		return nil, nil, false
+	} else if err != nil {
+		log.Errorf("Error getting webhook delivery: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, components.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Error getting webhook delivery",
+			Error:   err.Error(),
+		})
+		return nil, nil, false
+	}
+	return delivery, webhook, true
+}
@@ -0,0 +1,75 @@
+// Package apiversion serves the versions of the REST API: the routes of the newer versions are served by the
+// handlers of the v1 routes, the handlers respond with the structs of the version of the request.
+package apiversion
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The versions of the API, v1 is frozen: its requests and responses don't change anymore
+const (
+	V1     = "v1"
+	V2     = "v2"
+	Latest = V2
+)
+
+// routesPrefix is the prefix of the routes of the handlers of every version
+const routesPrefix = "/api/" + V1
+
+type versionKey struct{}
+
+//FromRequest returns the API version of the request, v1 for the requests of the v1 routes
+func FromRequest(request *http.Request) string {
+	if version, ok := request.Context().Value(versionKey{}).(string); ok {
+		return version
+	}
+	return V1
+}
+
+//IsV1 checks whether the request is a request of the deprecated v1 API
+func IsV1(c *gin.Context) bool {
+	return FromRequest(c.Request) == V1
+}
+
+//Handler serves the requests of the /api/<version> routes with the handlers of the v1 routes: their paths are
+//rewritten before the routing, so the middlewares of the handler run once, and the version of the requests is
+//available to the handlers with FromRequest
+func Handler(handler http.Handler, version string) http.Handler {
+	prefix := "/api/" + version
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		path := request.URL.Path
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		request = request.WithContext(context.WithValue(request.Context(), versionKey{}, version))
+		target := *request.URL
+		target.Path = routesPrefix + strings.TrimPrefix(path, prefix)
+		if strings.HasPrefix(target.RawPath, prefix) {
+			target.RawPath = routesPrefix + strings.TrimPrefix(target.RawPath, prefix)
+		}
+		request.URL = &target
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+//Deprecation sets the deprecation headers of the v1 responses: the Link of the successor version of the route
+//and the Sunset of the v1 API if it's set (an HTTP date)
+func Deprecation(sunset string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsV1(c) {
+			return
+		}
+		c.Header("Deprecation", "true")
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+		successor := url.URL{Path: "/api/" + Latest + strings.TrimPrefix(c.Request.URL.Path, routesPrefix)}
+		c.Header("Link", "<"+successor.String()+`>; rel="successor-version"`)
+	}
+}
@@ -0,0 +1,61 @@
+package apiversion_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	middlewareCalls := 0
+	router.Use(func(c *gin.Context) {
+		middlewareCalls++
+	})
+	v1 := router.Group("/api/v1/")
+	v1.Use(apiversion.Deprecation("Wed, 01 Jan 2027 00:00:00 GMT"))
+	v1.GET("/orgs/:orgid/clusters", func(c *gin.Context) {
+		c.String(http.StatusOK, apiversion.FromRequest(c.Request)+" "+c.Param("orgid"))
+	})
+	handler := apiversion.Handler(router, apiversion.V2)
+
+	cases := []struct {
+		name         string
+		path         string
+		expectedBody string
+		deprecated   bool
+	}{
+		{name: "v1", path: "/api/v1/orgs/1/clusters", expectedBody: "v1 1", deprecated: true},
+		{name: "v2", path: "/api/v2/orgs/2/clusters", expectedBody: "v2 2"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			middlewareCalls = 0
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path+"?tag=env", nil))
+			if recorder.Code != http.StatusOK || recorder.Body.String() != tc.expectedBody {
+				t.Fatalf("Expected %v, got: %v %v", tc.expectedBody, recorder.Code, recorder.Body.String())
+			}
+			if middlewareCalls != 1 {
+				t.Errorf("Expected the middleware to run once, got: %v", middlewareCalls)
+			}
+			header := recorder.Header()
+			if tc.deprecated {
+				if header.Get("Deprecation") != "true" || header.Get("Sunset") == "" {
+					t.Errorf("Expected the deprecation headers, got: %v", header)
+				}
+				if link := header.Get("Link"); link != `</api/v2/orgs/1/clusters>; rel="successor-version"` {
+					t.Errorf("Expected the successor version link, got: %v", link)
+				}
+			} else if header.Get("Deprecation") != "" {
+				t.Errorf("Expected no deprecation headers, got: %v", header)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// AllTokensResponse is the paginated API response of GetAllTokens
+type AllTokensResponse struct {
+	Tokens map[string][]*Token `json:"tokens"`
+	Page   int                 `json:"page"`
+	Limit  int                 `json:"limit"`
+	Total  int                 `json:"total"`
+}
+
+// IsAdmin checks whether the user is a Pipeline operator, listed by login in the auth.admins config
+// or a member of an LDAP group with the admin role
+func IsAdmin(user *User) bool {
+	if user.Admin {
+		return true
+	}
+	for _, login := range viper.GetStringSlice("auth.admins") {
+		if user.Login == login {
+			return true
+		}
+	}
+	return false
+}
+
+//AdminMiddleware aborts the request if the current user is not a Pipeline operator
+func AdminMiddleware(c *gin.Context) {
+	user, err := GetCurrentUserFromDB(c.Request)
+	if err != nil || !IsAdmin(user) {
+		log.Info(c.ClientIP(), "Admin privileges required")
+		c.AbortWithStatusJSON(http.StatusForbidden, btype.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Need more privileges",
+			Error:   "admin privileges required",
+		})
+		return
+	}
+	c.Next()
+}
+
+func parsePositiveQuery(c *gin.Context, key string, defaultValue int) (int, bool) {
+	param := c.Query(key)
+	if param == "" {
+		return defaultValue, true
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil || value < 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid " + key + " parameter",
+			Error:   "invalid " + key + ": " + strconv.Quote(param),
+		})
+		return 0, false
+	}
+	return value, true
+}
+
+// usersPage returns the range of the users of the page, the range is empty past the last page
+func usersPage(total, page, limit int) (int, int) {
+	// page-1 is compared before multiplying, so a huge page can't overflow
+	if page-1 >= (total+limit-1)/limit {
+		return total, total
+	}
+	start := (page - 1) * limit
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+//GetAllTokens lists the access tokens of every user, paginated by users (?page=1&limit=50), the limit
+//is at most pagination.maxLimit and only the tokens of the users of the page are read from the token store
+func GetAllTokens(c *gin.Context) {
+	page, ok := parsePositiveQuery(c, "page", 1)
+	if !ok {
+		return
+	}
+	limit, ok := parsePositiveQuery(c, "limit", 50)
+	if !ok {
+		return
+	}
+	if maxLimit := viper.GetInt("pagination.maxLimit"); limit > maxLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid limit parameter",
+			Error:   "the limit must be at most " + strconv.Itoa(maxLimit),
+		})
+		return
+	}
+
+	userIDs, err := tokenStore.ListUsers(c.Request.Context())
+	if err != nil {
+		listTokensFailed(c, err)
+		return
+	}
+
+	response := AllTokensResponse{Tokens: make(map[string][]*Token), Page: page, Limit: limit, Total: len(userIDs)}
+	start, end := usersPage(len(userIDs), page, limit)
+	for _, userID := range userIDs[start:end] {
+		tokens, err := tokenStore.List(c.Request.Context(), userID)
+		if err != nil {
+			listTokensFailed(c, err)
+			return
+		}
+		if len(tokens) > 0 {
+			response.Tokens[userID] = tokens
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func listTokensFailed(c *gin.Context, err error) {
+	message := "Failed to list tokens"
+	log.Info(c.ClientIP(), message+": "+err.Error())
+	c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
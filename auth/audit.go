@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/viper"
+)
+
+// Token audit actions
+const (
+	AuditActionCreate        = "create"
+	AuditActionRevoke        = "revoke"
+	AuditActionRevokeAll     = "revoke_all"
+	AuditActionRotate        = "rotate"
+	AuditActionRefresh       = "refresh"
+	AuditActionLookupFailure = "lookup_failure"
+	AuditActionImpersonate   = "impersonate"
+	AuditActionIPDenied      = "ip_denied"
+)
+
+// TokenAuditEvent is an entry of the token audit log
+type TokenAuditEvent struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	Time      time.Time `gorm:"index" json:"time"`
+	Action    string    `gorm:"size:32;index" json:"action"`
+	UserID    string    `gorm:"size:64;index" json:"userId"`
+	TokenID   string    `gorm:"size:64" json:"tokenId,omitempty"`
+	Actor     string    `gorm:"size:64" json:"actor,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	Resource  string    `json:"resource,omitempty"` // the request of impersonation events
+	Error     string    `json:"error,omitempty"`
+}
+
+// TableName sets TokenAuditEvent's table name
+func (TokenAuditEvent) TableName() string {
+	return "token_audit_events"
+}
+
+// AuditSink is an append-only destination of token audit events
+type AuditSink interface {
+	Write(event *TokenAuditEvent) error
+}
+
+type multiAuditSink []AuditSink
+
+func (sinks multiAuditSink) Write(event *TokenAuditEvent) error {
+	var errs []string
+	for _, sink := range sinks {
+		if err := sink.Write(event); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+type dbAuditSink struct {
+	db *gorm.DB
+}
+
+func (sink dbAuditSink) Write(event *TokenAuditEvent) error {
+	// Copy the event, so the ID assigned on insert is not shared with the other sinks
+	e := *event
+	return sink.db.Create(&e).Error
+}
+
+// fileAuditSink writes the events as JSON lines to a file opened in append mode
+type fileAuditSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditSink{file: file}, nil
+}
+
+func (sink *fileAuditSink) Write(event *TokenAuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	sink.Lock()
+	defer sink.Unlock()
+	_, err = sink.file.Write(append(line, '\n'))
+	return err
+}
+
+// syslogAuditSink sends the events as JSON messages to syslog
+type syslogAuditSink struct {
+	writer *syslog.Writer
+}
+
+func (sink syslogAuditSink) Write(event *TokenAuditEvent) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return sink.writer.Info(string(message))
+}
+
+// newAuditSink creates the sinks configured in auth.audit.sinks ("database", "file", "syslog")
+func newAuditSink(names []string) (AuditSink, error) {
+	var sinks multiAuditSink
+	for _, name := range names {
+		switch name {
+		case "database":
+			sinks = append(sinks, dbAuditSink{db: model.GetDB()})
+		case "file":
+			sink, err := newFileAuditSink(viper.GetString("auth.audit.file.path"))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "syslog":
+			writer, err := syslog.Dial(viper.GetString("auth.audit.syslog.network"), viper.GetString("auth.audit.syslog.address"),
+				syslog.LOG_INFO|syslog.LOG_AUTH, viper.GetString("auth.audit.syslog.tag"))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, syslogAuditSink{writer: writer})
+		default:
+			return nil, fmt.Errorf("unknown audit sink: %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// auditActor returns the ID of the authenticated user or service account of the request
+func auditActor(c *gin.Context) string {
+	if sa := GetCurrentServiceAccount(c.Request); sa != nil {
+		return sa.UserID()
+	}
+	if user := GetCurrentUser(c.Request); user != nil {
+		return strconv.Itoa(int(user.ID))
+	}
+	return ""
+}
+
+//GetCurrentActor returns the ID of the service account or the user of the request, it's the actor of the
+//events of the organizations
+func GetCurrentActor(c *gin.Context) string {
+	return auditActor(c)
+}
+
+// auditToken records a token event of the owner (a user or a service account), the request is not
+// failed if the event can't be written, there is no sink if auth.audit.sinks is empty
+func auditToken(c *gin.Context, action, owner, tokenID, actor string, cause error) {
+	if auditSink == nil {
+		return
+	}
+	event := &TokenAuditEvent{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		UserID:    owner,
+		TokenID:   tokenID,
+		Actor:     actor,
+		IP:        RequestIP(c.Request),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if cause != nil {
+		event.Error = cause.Error()
+	}
+	if err := auditSink.Write(event); err != nil {
+		log.Errorf("Failed to write token audit event: %s", err)
+	}
+}
+
+// recordTokenRevocation records the revocation of the token of the owner in the activity stream of the
+// organizations, every token of the owner is revoked without a token ID
+func recordTokenRevocation(c *gin.Context, owner, tokenID string, organizationIDs ...uint) {
+	message := fmt.Sprintf("Access token %s of %s revoked", tokenID, owner)
+	if tokenID == "" {
+		message = fmt.Sprintf("Every access token of %s revoked", owner)
+	}
+	for _, organizationID := range organizationIDs {
+		notify.RecordEvent(model.EventModel{
+			OrganizationID: organizationID,
+			Type:           notify.EventTokenRevoked,
+			Actor:          auditActor(c),
+			Resource:       tokenID,
+			Message:        message,
+		})
+	}
+}
+
+// userOrganizationIDs returns the organizations of the user, the errors are only logged
+func userOrganizationIDs(user *User) []uint {
+	var organizations []Organization
+	if err := model.GetDB().Model(user).Related(&organizations, "Organizations").Error; err != nil {
+		log.Errorf("Failed to list the organizations of user %d: %s", user.ID, err)
+	}
+	ids := []uint{}
+	for _, organization := range organizations {
+		ids = append(ids, organization.ID)
+	}
+	return ids
+}
+
+//GetTokenAuditEvents lists the token audit events stored in the database, most recent first.
+//Admins can see the events of every user (or filter them with ?user=), others only their own.
+//Further filters: ?action=revoke, ?since=2018-05-01T00:00:00Z, ?limit=100
+func GetTokenAuditEvents(c *gin.Context) {
+	if !auditStoredInDB {
+		c.AbortWithStatusJSON(http.StatusNotFound, btype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Token audit log is not stored in the database",
+			Error:   "the database audit sink is not enabled",
+		})
+		return
+	}
+
+	limit, ok := parsePositiveQuery(c, "limit", 100)
+	if !ok {
+		return
+	}
+
+	query := model.GetDB().Order("time desc, id desc").Limit(limit)
+	if user, err := GetCurrentUserFromDB(c.Request); err == nil && IsAdmin(user) {
+		if userID := c.Query("user"); userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+	} else {
+		userID := auditActor(c)
+		query = query.Where("user_id = ? OR actor = ?", userID, userID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if sinceParam := c.Query("since"); sinceParam != "" {
+		since, err := time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid since parameter",
+				Error:   fmt.Sprintf("invalid since: %q", sinceParam),
+			})
+			return
+		}
+		query = query.Where("time >= ?", since)
+	}
+
+	events := []TokenAuditEvent{}
+	if err := query.Find(&events).Error; err != nil {
+		message := "Failed to list token audit events"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base32"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	jwtRequest "github.com/dgrijalva/jwt-go/request"
@@ -21,8 +23,11 @@ import (
 	"github.com/spf13/viper"
 
 	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/apiversion"
 	"github.com/banzaicloud/pipeline/config"
 	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/pagination"
+	"github.com/banzaicloud/pipeline/vaultclient"
 	"github.com/sirupsen/logrus"
 )
 
@@ -51,6 +56,11 @@ var (
 	authEnabled      bool
 	signingKeyBase32 string
 	tokenStore       TokenStore
+	tokenUsage       *tokenUsageRecorder
+	tokenCreations   *tokenCreationLimiter
+	auditSink        AuditSink
+	tokenManager     *TokenManager
+	auditStoredInDB  bool
 
 	// JwtIssuer ("iss") claim identifies principal that issued the JWT
 	JwtIssuer string
@@ -71,6 +81,8 @@ func init() {
 type ScopedClaims struct {
 	jwt.StandardClaims
 	Scope string `json:"scope,omitempty"`
+	// CIDR is the space separated IP allowlist of the token
+	CIDR string `json:"cidr,omitempty"`
 	// Drone
 	Type string `json:"type,omitempty"`
 	Text string `json:"text,omitempty"`
@@ -83,14 +95,15 @@ type DroneClaims struct {
 	Text string `json:"text,omitempty"`
 }
 
-func lookupAccessToken(userId, token string) (bool, error) {
-	return tokenStore.Lookup(userId, token)
+func lookupAccessToken(ctx context.Context, userId, tokenId string) (bool, error) {
+	token, err := tokenStore.Lookup(ctx, userId, tokenId)
+	return token != nil, err
 }
 
-func validateAccessToken(claims *ScopedClaims) (bool, error) {
+func validateAccessToken(ctx context.Context, claims *ScopedClaims) (bool, error) {
 	userID := claims.Subject
 	tokenID := claims.Id
-	return lookupAccessToken(userID, tokenID)
+	return lookupAccessToken(ctx, userID, tokenID)
 }
 
 //Init initialize the auth
@@ -138,27 +151,209 @@ func Init() {
 		Auth.UserStorer = BanzaiUserStorer{signingKeyBase32: signingKeyBase32, droneDB: nil}
 	}
 
-	githubProvider := github.New(&github.Config{
-		// ClientID and ClientSecret is validated inside github.New()
-		ClientID:     viper.GetString("auth.clientid"),
-		ClientSecret: viper.GetString("auth.clientsecret"),
+	// GitHub login can be disabled if another login provider is configured
+	otherProviders := viper.GetString("auth.provider") == "ldap" || viper.GetString("auth.saml.url") != "" ||
+		viper.GetString("auth.oidc.issuerurl") != "" ||
+		viper.GetString("auth.gitlab.clientid") != "" || viper.GetString("auth.bitbucket.clientid") != ""
+	if viper.GetString("auth.clientid") != "" || !otherProviders {
+		githubProvider := github.New(&github.Config{
+			// ClientID and ClientSecret is validated inside github.New()
+			ClientID:     viper.GetString("auth.clientid"),
+			ClientSecret: viper.GetString("auth.clientsecret"),
 
-		// The same as Drone's scopes
-		Scopes: []string{
-			"repo",
-			"repo:status",
-			"user:email",
-			"read:org",
-		},
-	})
-	githubProvider.AuthorizeHandler = NewGithubAuthorizeHandler(githubProvider)
-	Auth.RegisterProvider(githubProvider)
+			// The same as Drone's scopes
+			Scopes: []string{
+				"repo",
+				"repo:status",
+				"user:email",
+				"read:org",
+			},
+		})
+		githubProvider.AuthorizeHandler = NewGithubAuthorizeHandler(githubProvider)
+		Auth.RegisterProvider(githubProvider)
+	}
+
+	if issuerURL := viper.GetString("auth.oidc.issuerurl"); issuerURL != "" {
+		oidcProvider, err := NewOIDCProvider(&OIDCConfig{
+			IssuerURL:    issuerURL,
+			ClientID:     viper.GetString("auth.oidc.clientid"),
+			ClientSecret: viper.GetString("auth.oidc.clientsecret"),
+			Scopes:       viper.GetStringSlice("auth.oidc.scopes"),
+			UserClaim:    viper.GetString("auth.oidc.claims.user"),
+			LoginClaim:   viper.GetString("auth.oidc.claims.login"),
+			NameClaim:    viper.GetString("auth.oidc.claims.name"),
+			EmailClaim:   viper.GetString("auth.oidc.claims.email"),
+			GroupsClaim:  viper.GetString("auth.oidc.claims.groups"),
+		})
+		if err != nil {
+			panic(err)
+		}
+		Auth.RegisterProvider(oidcProvider)
+	}
+
+	if viper.GetString("auth.provider") == "ldap" {
+		viper.SetDefault("auth.ldap.poolsize", 10)
+		ldapProvider, err := NewLDAPProvider(&LDAPConfig{
+			URL:                viper.GetString("auth.ldap.url"),
+			StartTLS:           viper.GetBool("auth.ldap.starttls"),
+			InsecureSkipVerify: viper.GetBool("auth.ldap.insecureskipverify"),
+			CAFile:             viper.GetString("auth.ldap.cafile"),
+			Timeout:            viper.GetDuration("auth.ldap.timeout"),
+			PoolSize:           viper.GetInt("auth.ldap.poolsize"),
+			BindDN:             viper.GetString("auth.ldap.binddn"),
+			BindPassword:       viper.GetString("auth.ldap.bindpassword"),
+			UserBaseDN:         viper.GetString("auth.ldap.userbasedn"),
+			UserFilter:         viper.GetString("auth.ldap.userfilter"),
+			LoginAttribute:     viper.GetString("auth.ldap.loginattribute"),
+			NameAttribute:      viper.GetString("auth.ldap.nameattribute"),
+			EmailAttribute:     viper.GetString("auth.ldap.emailattribute"),
+			GroupBaseDN:        viper.GetString("auth.ldap.groupbasedn"),
+			GroupFilter:        viper.GetString("auth.ldap.groupfilter"),
+			GroupRoles:         viper.GetStringMapString("auth.ldap.grouproles"),
+		})
+		if err != nil {
+			panic(err)
+		}
+		Auth.RegisterProvider(ldapProvider)
+	}
+
+	if samlURL := viper.GetString("auth.saml.url"); samlURL != "" {
+		cert, err := ParseSAMLCertificate(viper.GetString("auth.saml.idp.certificate"))
+		if err != nil {
+			panic(err)
+		}
+		samlProvider, err := NewSAMLProvider(&SAMLConfig{
+			URL:             samlURL,
+			EntityID:        viper.GetString("auth.saml.entityid"),
+			IdPEntityID:     viper.GetString("auth.saml.idp.entityid"),
+			IdPSSOURL:       viper.GetString("auth.saml.idp.ssourl"),
+			IdPCertificate:  cert,
+			LoginAttribute:  viper.GetString("auth.saml.attributes.login"),
+			NameAttribute:   viper.GetString("auth.saml.attributes.name"),
+			EmailAttribute:  viper.GetString("auth.saml.attributes.email"),
+			GroupsAttribute: viper.GetString("auth.saml.attributes.groups"),
+		})
+		if err != nil {
+			panic(err)
+		}
+		Auth.RegisterProvider(samlProvider)
+	}
+
+	viper.SetDefault("auth.gitlab.url", "https://gitlab.com")
+	oauthSources := map[string]OAuthUserSource{
+		"gitlab":    NewGitlabUserSource(viper.GetString("auth.gitlab.url")),
+		"bitbucket": NewBitbucketUserSource(),
+	}
+	for name, source := range oauthSources {
+		if viper.GetString("auth."+name+".clientid") == "" {
+			continue
+		}
+		provider, err := NewOAuthProvider(source, viper.GetString("auth."+name+".clientid"), viper.GetString("auth."+name+".clientsecret"))
+		if err != nil {
+			panic(err)
+		}
+		Auth.RegisterProvider(provider)
+	}
 
 	Authority = authority.New(&authority.Config{
 		Auth: Auth,
 	})
 
-	tokenStore = NewVaultTokenStore()
+	viper.SetDefault("auth.tokenstore.driver", "vault")
+	var err error
+	tokenStore, err = NewTokenStore(viper.GetString("auth.tokenstore.driver"))
+	if err != nil {
+		panic(err)
+	}
+	viper.SetDefault("auth.tokenquota", 1000)
+	tokenStore = NewQuotaTokenStore(tokenStore, viper.GetInt("auth.tokenquota"))
+	// The cache is below the hashing wrapper, so only hashed token IDs are cached and broadcast
+	if ttl := viper.GetDuration("auth.tokenstore.cache.ttl"); ttl > 0 {
+		var invalidator CacheInvalidator
+		if address := viper.GetString("auth.tokenstore.cache.redis.address"); address != "" {
+			viper.SetDefault("auth.tokenstore.cache.redis.channel", "pipeline:accesstokens:invalidate")
+			invalidator = NewRedisCacheInvalidator(address,
+				viper.GetString("auth.tokenstore.cache.redis.password"),
+				viper.GetString("auth.tokenstore.cache.redis.channel"))
+		}
+		tokenStore = NewCachingTokenStoreWithInvalidator(tokenStore, ttl, invalidator)
+	}
+	if viper.GetBool("auth.hashtokens") {
+		tokenStore = NewHashedTokenStore(tokenStore)
+	}
+
+	proxies, err := ParseCIDRs(viper.GetStringSlice("auth.trustedproxies"))
+	if err != nil {
+		panic(fmt.Errorf("invalid auth.trustedproxies: %s", err))
+	}
+	trustedProxies = proxies
+
+	viper.SetDefault("auth.tokenusageflushinterval", "1m")
+	tokenUsage = newTokenUsageRecorder(tokenStore)
+	go tokenUsage.Run(viper.GetDuration("auth.tokenusageflushinterval"))
+
+	viper.SetDefault("auth.tokenratelimit.limit", 100)
+	viper.SetDefault("auth.tokenratelimit.window", "1h")
+	tokenCreations = newTokenCreationLimiter(viper.GetInt("auth.tokenratelimit.limit"), viper.GetDuration("auth.tokenratelimit.window"))
+
+	if keys := newSigningKeyProvider(viper.GetString("auth.jwt.signer")); keys != nil {
+		tokenManager = NewTokenManager(keys, model.GetDB())
+		if err := tokenManager.denylist.Sync(); err != nil {
+			panic(err)
+		}
+		viper.SetDefault("auth.jwt.denylistsyncinterval", "10s")
+		go tokenManager.denylist.Run(viper.GetDuration("auth.jwt.denylistsyncinterval"))
+	}
+
+	viper.SetDefault("auth.twofactor.store", "vault")
+	viper.SetDefault("auth.twofactor.issuer", "Pipeline")
+	twoFactorStore, err = NewTwoFactorStore(viper.GetString("auth.twofactor.store"))
+	if err != nil {
+		panic(err)
+	}
+
+	viper.SetDefault("auth.session.idletimeout", "1h")
+	viper.SetDefault("auth.session.absolutetimeout", "24h")
+
+	viper.SetDefault("auth.refreshtoken.ttl", "720h")
+	viper.SetDefault("auth.refreshtoken.accesstokenttl", "15m")
+
+	viper.SetDefault("auth.audit.sinks", []string{"database"})
+	viper.SetDefault("auth.audit.syslog.tag", "pipeline")
+	if sinks := viper.GetStringSlice("auth.audit.sinks"); len(sinks) > 0 {
+		auditSink, err = newAuditSink(sinks)
+		if err != nil {
+			panic(err)
+		}
+		for _, sink := range sinks {
+			auditStoredInDB = auditStoredInDB || sink == "database"
+		}
+	}
+}
+
+// newSigningKeyProvider creates the keys of the TokenManager configured in auth.jwt.signer
+// ("local" or "vault"), it returns nil if tokens are signed with the HS256 token signing key
+func newSigningKeyProvider(signer string) SigningKeyProvider {
+	var keys SigningKeyProvider
+	var err error
+	switch signer {
+	case "":
+		return nil
+	case "local":
+		keys, err = NewLocalKeyProvider(viper.GetString("auth.jwt.local.keysdir"), viper.GetString("auth.jwt.local.currentkid"))
+	case "vault":
+		viper.SetDefault("auth.jwt.vault.role", "pipeline")
+		viper.SetDefault("auth.jwt.vault.mountpath", "transit")
+		viper.SetDefault("auth.jwt.vault.key", "pipeline-jwt")
+		keys, err = NewVaultTransitKeyProvider(viper.GetString("auth.jwt.vault.role"),
+			viper.GetString("auth.jwt.vault.mountpath"), viper.GetString("auth.jwt.vault.key"))
+	default:
+		err = fmt.Errorf("unknown token signer: %q", signer)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return keys
 }
 
 //GenerateToken generates token from context
@@ -171,38 +366,435 @@ func GenerateToken(c *gin.Context) {
 		return
 	}
 
-	tokenID := uuid.NewV4().String()
+	userID := strconv.Itoa(int(currentUser.ID))
+	storedToken, ok := newTokenFromRequest(c, userID)
+	if !ok {
+		return
+	}
+
+	// Tokens with a refresh token are short-lived, eg.: ?refresh=true
+	refresh := isRefreshRequested(c)
+	if refresh {
+		capTokenTTL(storedToken, viper.GetDuration("auth.refreshtoken.accesstokenttl"))
+	}
+
+	signedToken, err := signAccessToken(currentUser, storedToken)
+	if err != nil {
+		err = c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("Failed to sign token: %s", err))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	// Admins can create tokens over the quota
+	if user, err := GetCurrentUserFromDB(c.Request); err == nil && IsAdmin(user) {
+		ctx = WithTokenQuotaOverride(ctx)
+	}
+	if !storeNewToken(c, ctx, userID, storedToken) {
+		return
+	}
+	response := gin.H{"id": storedToken.ID, "token": signedToken}
+	if !apiversion.IsV1(c) {
+		response["expiresAt"] = storedToken.ExpiresAt
+		response["scopes"] = storedToken.Scopes
+	}
+	if refresh {
+		response, err = refreshableTokenResponse(userID, storedToken, signedToken)
+		if err != nil {
+			err = c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("Failed to create refresh token: %s", err))
+			log.Info(c.ClientIP(), err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// TokenRequest is the name, the lifetime, the scopes and the IP allowlist of a new token, they're query
+// parameters in v1 and the JSON body of the request from v2
+type TokenRequest struct {
+	Name         string   `json:"name"`
+	TTL          string   `json:"ttl"`
+	Scopes       []string `json:"scopes"`
+	AllowedCIDRs []string `json:"allowedCidrs"`
+}
+
+// bindTokenRequest returns the parameters of the new token of the request, it aborts the request and returns
+// false if the body of a v2 request is invalid
+func bindTokenRequest(c *gin.Context) (*TokenRequest, bool) {
+	if apiversion.IsV1(c) {
+		return &TokenRequest{
+			Name:         c.Query("name"),
+			TTL:          c.Query("ttl"),
+			Scopes:       c.QueryArray("scope"),
+			AllowedCIDRs: c.QueryArray("cidr"),
+		}, true
+	}
+	request := &TokenRequest{}
+	if c.Request.ContentLength == 0 {
+		return request, true
+	}
+	if err := c.ShouldBindJSON(request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Error parsing request",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return request, true
+}
+
+// newTokenFromRequest creates a new token of the owner (a user or a service account) from
+// the ttl, name and scope parameters, it aborts the request and returns false if
+// the parameters are invalid or the owner has created too many tokens lately
+func newTokenFromRequest(c *gin.Context, owner string) (*Token, bool) {
+	if !checkTwoFactorForTokens(c) {
+		return nil, false
+	}
+	request, ok := bindTokenRequest(c)
+	if !ok {
+		return nil, false
+	}
+
+	// Optional token lifetime, eg.: ?ttl=720h
+	var ttl time.Duration
+	if ttlParam := request.TTL; ttlParam != "" {
+		var err error
+		ttl, err = time.ParseDuration(ttlParam)
+		if err != nil || ttl < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid token ttl",
+				Error:   fmt.Sprintf("invalid ttl: %q", ttlParam),
+			})
+			return nil, false
+		}
+	}
+
+	// Optional list of scopes, eg.: ?scope=cluster:read&scope=deployment:write
+	scopes := request.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{ScopeAll}
+	}
+	if err := ValidateScopes(scopes); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid token scope",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+
+	// Optional IP allowlist, eg.: ?cidr=10.0.0.0/8&cidr=203.0.113.7
+	cidrs, err := ParseCIDRs(request.AllowedCIDRs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid token IP allowlist",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+
+	if allowed, retryAfter := tokenCreations.Allow(owner); !allowed {
+		message := "Too many tokens created, try again later"
+		log.Info(c.ClientIP(), message)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, btype.ErrorResponse{
+			Code:    http.StatusTooManyRequests,
+			Message: message,
+			Error:   fmt.Sprintf("token creation limit reached, retry after %s", retryAfter),
+		})
+		return nil, false
+	}
+
+	token := NewToken(uuid.NewV4().String(), request.Name, ttl)
+	token.Scopes = scopes
+	token.AllowedCIDRs = cidrs
+	return token, true
+}
+
+// storeNewToken stores the token of the owner, it aborts the request and returns false if it fails
+func storeNewToken(c *gin.Context, ctx context.Context, owner string, token *Token) bool {
+	err := tokenStore.Store(ctx, owner, token)
+	if quotaErr, ok := err.(TokenQuotaExceededError); ok {
+		log.Info(c.ClientIP(), quotaErr.Error())
+		c.AbortWithStatusJSON(http.StatusConflict, btype.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "Token quota exceeded, revoke unused tokens first",
+			Error:   quotaErr.Error(),
+		})
+	} else if err != nil {
+		err = c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("Failed to store token: %s", err))
+		log.Info(c.ClientIP(), err.Error())
+	} else {
+		auditToken(c, AuditActionCreate, owner, token.ID, auditActor(c), nil)
+		return true
+	}
+	return false
+}
+
+// signAccessToken creates the signed JWT access token of the user for a stored token
+func signAccessToken(user *User, token *Token) (string, error) {
+	return signToken(strconv.Itoa(int(user.ID)), user.Login, token)
+}
+
+// signToken creates the signed JWT access token of the subject for a stored token,
+// the Drone claims are set only if login is not empty
+func signToken(subject, login string, token *Token) (string, error) {
+	var expiresAt int64
+	if token.ExpiresAt != nil {
+		expiresAt = token.ExpiresAt.Unix()
+	}
 
 	// Create the Claims
 	claims := &ScopedClaims{
 		StandardClaims: jwt.StandardClaims{
 			Issuer:    JwtIssuer,
 			Audience:  JwtAudience,
-			IssuedAt:  jwt.TimeFunc().Unix(),
-			ExpiresAt: 0,
-			Subject:   strconv.Itoa(int(currentUser.ID)),
-			Id:        tokenID,
+			IssuedAt:  token.CreatedAt.Unix(),
+			ExpiresAt: expiresAt,
+			Subject:   subject,
+			Id:        token.ID,
 		},
-		Scope: "api:invoke",        // "scope" for Pipeline
-		Type:  DroneUserCookieType, // "type" for Drone
-		Text:  currentUser.Login,   // "text" for Drone
+		Scope: strings.Join(token.Scopes, " "), // "scope" for Pipeline
+		CIDR:  strings.Join(token.AllowedCIDRs, " "),
+	}
+	if login != "" {
+		claims.Type = DroneUserCookieType // "type" for Drone
+		claims.Text = login               // "text" for Drone
+	}
+
+	if tokenManager != nil {
+		return tokenManager.Sign(claims)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signingKeyBase32))
+}
+
+//RotateToken replaces an access token of the current user with a new one having the same
+//name, scopes and lifetime, the old token is revoked and the new one is returned only once
+func RotateToken(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(signingKeyBase32))
+	tokenID := c.Param("id")
+	ctx := c.Request.Context()
+	old, err := lookupListedToken(ctx, strconv.Itoa(int(currentUser.ID)), tokenID)
+	var token *Token
+	if err == nil {
+		token, err = tokenStore.Rotate(ctx, strconv.Itoa(int(currentUser.ID)), tokenID, uuid.NewV4().String())
+	}
+	if err == nil && tokenManager != nil {
+		// The old token is self-contained, so it's valid until it's on the denylist
+		err = tokenManager.Revoke(ctx, tokenID, old.ExpiresAt)
+	}
+	if err == ErrTokenNotFound || err == ErrTokenExpired {
+		message := fmt.Sprintf("%s: %q", err, tokenID)
+		log.Info(c.ClientIP(), message)
+		c.AbortWithStatusJSON(http.StatusNotFound, btype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: message,
+			Error:   message,
+		})
+		return
+	} else if err != nil {
+		message := "Failed to rotate token"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
 
+	signedToken, err := signAccessToken(currentUser, token)
 	if err != nil {
 		err = c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("Failed to sign token: %s", err))
 		log.Info(c.ClientIP(), err.Error())
-	} else {
-		err = tokenStore.Store(strconv.Itoa(int(currentUser.ID)), tokenID)
-		if err != nil {
-			err = c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("Failed to store token: %s", err))
-			log.Info(c.ClientIP(), err.Error())
-		} else {
-			c.JSON(http.StatusOK, gin.H{"token": signedToken})
+		return
+	}
+	auditToken(c, AuditActionRotate, strconv.Itoa(int(currentUser.ID)), tokenID, auditActor(c), nil)
+	c.JSON(http.StatusOK, gin.H{"id": token.ID, "token": signedToken})
+}
+
+//GetTokens lists the access tokens of the current user
+func GetTokens(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	tokens, err := tokenStore.List(c.Request.Context(), strconv.Itoa(int(currentUser.ID)))
+	if err != nil {
+		message := "Failed to list tokens"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondTokens(c, tokens)
+}
+
+// tokenV2 is a token in the v2 API, its last use is grouped
+type tokenV2 struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	ExpiresAt    *time.Time    `json:"expiresAt,omitempty"`
+	Scopes       []string      `json:"scopes"`
+	AllowedCIDRs []string      `json:"allowedCidrs"`
+	LastUsed     *tokenUsageV2 `json:"lastUsed,omitempty"`
+}
+
+type tokenUsageV2 struct {
+	At time.Time `json:"at"`
+	IP string    `json:"ip,omitempty"`
+}
+
+// tokenPagination are the fields the tokens can be sorted and filtered by, the oldest tokens come first by default
+var tokenPagination = pagination.Options{
+	Fields: map[string]pagination.Field{
+		"name":       {Sort: true, Filter: true},
+		"createdAt":  {Sort: true},
+		"expiresAt":  {Sort: true},
+		"lastUsedAt": {Sort: true},
+	},
+	DefaultSort: "createdAt",
+}
+
+// pageTokens returns the page of the tokens of the pagination parameters of the request and the next cursor
+func pageTokens(c *gin.Context, tokens []*Token) ([]*Token, string, bool) {
+	page, err := pagination.Parse(c, tokenPagination)
+	if err != nil {
+		pagination.BadRequest(c, err)
+		return nil, "", false
+	}
+	items := make([]pagination.Item, len(tokens))
+	for i, token := range tokens {
+		items[i] = pagination.Item{ID: token.ID, Fields: map[string]interface{}{
+			"name":       token.Name,
+			"createdAt":  token.CreatedAt,
+			"expiresAt":  token.ExpiresAt,
+			"lastUsedAt": token.LastUsedAt,
+		}}
+	}
+	indexes, next := page.Slice(items)
+	paged := make([]*Token, len(indexes))
+	for i, index := range indexes {
+		paged[i] = tokens[index]
+	}
+	return paged, next, true
+}
+
+// respondTokens sends back the page of the tokens, the list of the tokens in v1 and the tokens object in v2
+func respondTokens(c *gin.Context, tokens []*Token) {
+	tokens, next, ok := pageTokens(c, tokens)
+	if !ok {
+		return
+	}
+	pagination.SetNext(c, next)
+	if apiversion.IsV1(c) {
+		c.JSON(http.StatusOK, tokens)
+		return
+	}
+	response := struct {
+		Tokens     []tokenV2 `json:"tokens"`
+		NextCursor string    `json:"nextCursor,omitempty"`
+	}{Tokens: []tokenV2{}, NextCursor: next}
+	for _, token := range tokens {
+		v2 := tokenV2{
+			ID:           token.ID,
+			Name:         token.Name,
+			CreatedAt:    token.CreatedAt,
+			ExpiresAt:    token.ExpiresAt,
+			Scopes:       token.Scopes,
+			AllowedCIDRs: token.AllowedCIDRs,
 		}
+		if v2.Scopes == nil {
+			v2.Scopes = []string{}
+		}
+		if v2.AllowedCIDRs == nil {
+			v2.AllowedCIDRs = []string{}
+		}
+		if token.LastUsedAt != nil {
+			v2.LastUsed = &tokenUsageV2{At: *token.LastUsedAt, IP: token.LastUsedIP}
+		}
+		response.Tokens = append(response.Tokens, v2)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+//DeleteToken revokes an access token of the current user
+func DeleteToken(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	tokenID := c.Param("id")
+	err := denyToken(c.Request.Context(), strconv.Itoa(int(currentUser.ID)), tokenID)
+	if err == nil {
+		err = tokenStore.Revoke(c.Request.Context(), strconv.Itoa(int(currentUser.ID)), tokenID)
+	}
+	if err == nil {
+		err = revokeRefreshTokens(strconv.Itoa(int(currentUser.ID)), tokenID)
+	}
+	if err != nil {
+		message := "Failed to revoke token"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
 	}
+	auditToken(c, AuditActionRevoke, strconv.Itoa(int(currentUser.ID)), tokenID, auditActor(c), nil)
+	recordTokenRevocation(c, strconv.Itoa(int(currentUser.ID)), tokenID, userOrganizationIDs(currentUser)...)
+	c.Status(http.StatusNoContent)
+}
+
+//DeleteTokens revokes all the access tokens of the current user
+func DeleteTokens(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	err := denyAllTokens(c.Request.Context(), strconv.Itoa(int(currentUser.ID)))
+	if err == nil {
+		err = tokenStore.RevokeAll(c.Request.Context(), strconv.Itoa(int(currentUser.ID)))
+	}
+	if err == nil {
+		err = revokeRefreshTokens(strconv.Itoa(int(currentUser.ID)), "")
+	}
+	if err != nil {
+		message := "Failed to revoke tokens"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	auditToken(c, AuditActionRevokeAll, strconv.Itoa(int(currentUser.ID)), "", auditActor(c), nil)
+	recordTokenRevocation(c, strconv.Itoa(int(currentUser.ID)), "", userOrganizationIDs(currentUser)...)
+	c.Status(http.StatusNoContent)
 }
 
 func hmacKeyFunc(token *jwt.Token) (interface{}, error) {
@@ -213,15 +805,79 @@ func hmacKeyFunc(token *jwt.Token) (interface{}, error) {
 	return []byte(signingKeyBase32), nil
 }
 
+// accessTokenKeyFunc returns the key of tokens signed by the TokenManager or the HMAC key of legacy tokens
+func accessTokenKeyFunc(token *jwt.Token) (interface{}, error) {
+	if tokenManager != nil && tokenManager.issued(token) {
+		return tokenManager.KeyFunc(token)
+	}
+	return hmacKeyFunc(token)
+}
+
+// denyToken puts a token of the owner on the denylist of the TokenManager before it's
+// revoked from the TokenStore (it's looked up for its expiry), it's a no-op without a TokenManager
+func denyToken(ctx context.Context, owner, tokenID string) error {
+	if tokenManager == nil {
+		return nil
+	}
+	var expiresAt *time.Time
+	if token, err := lookupListedToken(ctx, owner, tokenID); err == nil {
+		expiresAt = token.ExpiresAt
+	}
+	return tokenManager.Revoke(ctx, tokenID, expiresAt)
+}
+
+// lookupListedToken looks up a token of the owner by its raw ID or by the ID it's listed with, the listed IDs
+// are hashed if auth.hashtokens is set and Lookup only takes the raw IDs of the access tokens
+func lookupListedToken(ctx context.Context, owner, tokenID string) (*Token, error) {
+	token, err := tokenStore.Lookup(ctx, owner, tokenID)
+	if err != ErrTokenNotFound {
+		return token, err
+	}
+	tokens, listErr := tokenStore.List(ctx, owner)
+	if listErr != nil {
+		return nil, listErr
+	}
+	for _, listed := range tokens {
+		if listed.ID == tokenID {
+			return listed, nil
+		}
+	}
+	return nil, err
+}
+
+// denyAllTokens puts all tokens of the owner on the denylist of the TokenManager
+func denyAllTokens(ctx context.Context, owner string) error {
+	if tokenManager == nil {
+		return nil
+	}
+	tokens, err := tokenStore.List(ctx, owner)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := tokenManager.Revoke(ctx, token.ID, token.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //Handler handles authentication
 func Handler(c *gin.Context) {
 	currentUser := Auth.GetCurrentUser(c.Request)
 	if currentUser != nil {
+		// The server-side session is looked up once per request
+		newContext := context.WithValue(c.Request.Context(), auth.CurrentUser, currentUser)
+		c.Request = c.Request.WithContext(newContext)
+		// Logins waiting for the second factor can only verify the TOTP code
+		if user, ok := currentUser.(*User); ok && user.TwoFactorEnabled && isTwoFactorPending(c.Request) {
+			saveScopesIntoContext(c, []string{})
+		}
 		return
 	}
 
 	claims := ScopedClaims{}
-	accessToken, err := jwtRequest.ParseFromRequestWithClaims(c.Request, jwtRequest.OAuth2Extractor, &claims, hmacKeyFunc)
+	accessToken, err := jwtRequest.ParseFromRequestWithClaims(c.Request, jwtRequest.OAuth2Extractor, &claims, accessTokenKeyFunc)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized,
@@ -234,8 +890,37 @@ func Handler(c *gin.Context) {
 		return
 	}
 
-	isTokenValid, err := validateAccessToken(&claims)
+	var isTokenValid bool
+	if tokenManager != nil && tokenManager.issued(accessToken) {
+		// Self-contained tokens are validated without a TokenStore lookup
+		isTokenValid = !tokenManager.IsRevoked(claims.Id)
+		if !isTokenValid {
+			err = ErrTokenRevoked
+		}
+	} else {
+		isTokenValid, err = validateAccessToken(c.Request.Context(), &claims)
+	}
+	if err != nil && err != ErrTokenNotFound && err != ErrTokenExpired && err != ErrTokenRevoked {
+		code := http.StatusInternalServerError
+		if vaultclient.IsUnavailable(err) {
+			code = http.StatusServiceUnavailable
+		}
+		c.AbortWithStatusJSON(code,
+			btype.ErrorResponse{
+				Code:    code,
+				Message: "Failed to validate token",
+				Error:   err.Error(),
+			})
+		log.Error("Failed to validate token: ", err)
+		return
+	}
 	if err != nil || !accessToken.Valid || !isTokenValid {
+		if !isTokenValid {
+			if err == nil {
+				err = ErrTokenNotFound
+			}
+			auditToken(c, AuditActionLookupFailure, claims.Subject, claims.Id, claims.Subject, err)
+		}
 		resp := btype.ErrorResponse{
 			Code:    http.StatusUnauthorized,
 			Message: "Invalid token",
@@ -251,7 +936,9 @@ func Handler(c *gin.Context) {
 		return
 	}
 
-	hasScope := strings.Contains(claims.Scope, "api:invoke")
+	// Scopes are checked per route group by ScopeMiddleware
+	scopes := strings.Fields(claims.Scope)
+	hasScope := len(scopes) > 0
 
 	// TODO: metadata and group check for later hardening
 	/**
@@ -264,13 +951,43 @@ func Handler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, btype.ErrorResponse{
 			Code:    http.StatusUnauthorized,
 			Message: "Need more privileges",
-			Error:   err.Error(),
+			Error:   "access token has no scopes",
 		})
 		log.Info("Needs more privileges")
 		return
 	}
 
-	saveUserIntoContext(c, &claims)
+	clientIP := RequestIP(c.Request)
+	if !IPAllowed(strings.Fields(claims.CIDR), clientIP) {
+		err := fmt.Errorf("IP address %s is not in the allowlist of the token", clientIP)
+		auditToken(c, AuditActionIPDenied, claims.Subject, claims.Id, claims.Subject, err)
+		c.AbortWithStatusJSON(http.StatusForbidden, btype.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Token can't be used from this IP address",
+			Error:   err.Error(),
+		})
+		log.Info(err.Error())
+		return
+	}
+
+	tokenUsage.Record(claims.Subject, claims.Id, clientIP)
+
+	if saID, ok := serviceAccountIDFromSubject(claims.Subject); ok {
+		var sa ServiceAccount
+		if err := model.GetDB().First(&sa, saID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, btype.ErrorResponse{
+				Code:    http.StatusUnauthorized,
+				Message: "Invalid token",
+				Error:   "service account not found",
+			})
+			log.Info("Invalid service account token: ", err)
+			return
+		}
+		saveServiceAccountIntoContext(c, &sa)
+	} else {
+		saveUserIntoContext(c, &claims)
+	}
+	saveScopesIntoContext(c, scopes)
 
 	c.Next()
 }
@@ -281,7 +998,7 @@ func saveUserIntoContext(c *gin.Context, claims *ScopedClaims) {
 	c.Request = c.Request.WithContext(newContext)
 }
 
-//BanzaiSessionStorer stores the banzai session
+//BanzaiSessionStorer stores the banzai session, the cookie refers to a server-side session in the TokenStore
 type BanzaiSessionStorer struct {
 	auth.SessionStorer
 	SignedStringBytes []byte
@@ -289,6 +1006,10 @@ type BanzaiSessionStorer struct {
 
 //Update updates the BanzaiSessionStorer
 func (sessionStorer *BanzaiSessionStorer) Update(w http.ResponseWriter, req *http.Request, claims *claims.Claims) error {
+	if err := sessionStorer.startSession(req, claims); err != nil {
+		log.Info(req.RemoteAddr, err.Error())
+		return err
+	}
 	token := sessionStorer.SignedToken(claims)
 	err := sessionStorer.SessionManager.Add(w, req, sessionStorer.SessionName, token)
 	if err != nil {
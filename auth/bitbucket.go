@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	bitbucketEndpoint = oauth2.Endpoint{
+		AuthURL:  "https://bitbucket.org/site/oauth2/authorize",
+		TokenURL: "https://bitbucket.org/site/oauth2/access_token",
+	}
+	bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+)
+
+// bitbucketSource is Bitbucket Cloud
+type bitbucketSource struct{}
+
+// NewBitbucketUserSource creates an OAuthUserSource of Bitbucket Cloud
+func NewBitbucketUserSource() OAuthUserSource {
+	return bitbucketSource{}
+}
+
+func (bitbucketSource) GetName() string {
+	return "bitbucket"
+}
+
+func (bitbucketSource) Endpoint() oauth2.Endpoint {
+	return bitbucketEndpoint
+}
+
+// Scopes of Bitbucket are set on the OAuth consumer, Drone needs account, repository:admin, webhook and pullrequest
+func (bitbucketSource) Scopes() []string {
+	return nil
+}
+
+func (bitbucketSource) FetchUser(client *http.Client) (*OAuthUser, error) {
+	var user struct {
+		UUID        string `json:"uuid"`
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+		Links       struct {
+			Avatar struct {
+				Href string `json:"href"`
+			} `json:"avatar"`
+		} `json:"links"`
+	}
+	if err := getOAuthJSON(client, bitbucketAPIURL+"/user", &user); err != nil {
+		return nil, err
+	}
+
+	// The email addresses are listed separately, the primary one is used
+	var emails struct {
+		Values []struct {
+			Email     string `json:"email"`
+			IsPrimary bool   `json:"is_primary"`
+		} `json:"values"`
+	}
+	if err := getOAuthJSON(client, bitbucketAPIURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+	email := ""
+	for _, e := range emails.Values {
+		if e.IsPrimary {
+			email = e.Email
+		}
+	}
+
+	return &OAuthUser{
+		ID:    user.UUID,
+		Login: user.Username,
+		Name:  user.DisplayName,
+		Email: email,
+		Image: user.Links.Avatar.Href,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheInvalidator broadcasts token cache invalidations between Pipeline replicas
+type CacheInvalidator interface {
+	// Publish announces that a token of the user has been revoked, an empty tokenId means all of them
+	Publish(userId, tokenId string) error
+	// Subscribe calls the handler for every invalidation published by any of the replicas
+	Subscribe(handler func(userId, tokenId string))
+}
+
+const (
+	redisTimeout             = 5 * time.Second
+	redisResubscribeInterval = 5 * time.Second
+)
+
+// A CacheInvalidator implementation using Redis pub/sub, it speaks the
+// Redis protocol (RESP) directly since only PUBLISH and SUBSCRIBE are used.
+// Invalidations published while the subscription is down are lost, so
+// tokens can be cached for at most the cache TTL after a revocation.
+type redisCacheInvalidator struct {
+	address  string
+	password string
+	channel  string
+
+	sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisCacheInvalidator creates a CacheInvalidator which publishes to and subscribes on the given Redis channel
+func NewRedisCacheInvalidator(address, password, channel string) CacheInvalidator {
+	return &redisCacheInvalidator{address: address, password: password, channel: channel}
+}
+
+func (invalidator *redisCacheInvalidator) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", invalidator.address, redisTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	if invalidator.password != "" {
+		conn.SetDeadline(time.Now().Add(redisTimeout))
+		err = writeRedisCommand(conn, "AUTH", invalidator.password)
+		if err == nil {
+			_, err = readRedisReply(reader)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn.SetDeadline(time.Time{})
+	}
+	return conn, reader, nil
+}
+
+func (invalidator *redisCacheInvalidator) Publish(userId, tokenId string) error {
+	invalidator.Lock()
+	defer invalidator.Unlock()
+	if invalidator.conn == nil {
+		conn, reader, err := invalidator.dial()
+		if err != nil {
+			return err
+		}
+		invalidator.conn, invalidator.reader = conn, reader
+	}
+
+	invalidator.conn.SetDeadline(time.Now().Add(redisTimeout))
+	err := writeRedisCommand(invalidator.conn, "PUBLISH", invalidator.channel, userId+"/"+tokenId)
+	if err == nil {
+		_, err = readRedisReply(invalidator.reader)
+	}
+	if err != nil {
+		// Reconnect on the next publish
+		invalidator.conn.Close()
+		invalidator.conn, invalidator.reader = nil, nil
+	}
+	return err
+}
+
+func (invalidator *redisCacheInvalidator) Subscribe(handler func(userId, tokenId string)) {
+	go func() {
+		for {
+			if err := invalidator.subscribe(handler); err != nil {
+				log.Warnf("Redis token cache invalidation subscription failed: %s", err)
+			}
+			time.Sleep(redisResubscribeInterval)
+		}
+	}()
+}
+
+func (invalidator *redisCacheInvalidator) subscribe(handler func(userId, tokenId string)) error {
+	conn, reader, err := invalidator.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := writeRedisCommand(conn, "SUBSCRIBE", invalidator.channel); err != nil {
+		return err
+	}
+	for {
+		reply, err := readRedisReply(reader)
+		if err != nil {
+			return err
+		}
+		// Messages are ["message", channel, payload], the subscription confirmation is skipped
+		message, ok := reply.([]interface{})
+		if !ok || len(message) != 3 || message[0] != "message" {
+			continue
+		}
+		payload, _ := message[2].(string)
+		if parts := strings.SplitN(payload, "/", 2); len(parts) == 2 {
+			handler(parts[0], parts[1])
+		}
+	}
+}
+
+func writeRedisCommand(w io.Writer, args ...string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readRedisReply reads a RESP reply, simple and bulk strings are returned
+// as string, integers as int64 and arrays as []interface{}
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.New("redis: " + line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		array := make([]interface{}, n)
+		for i := range array {
+			if array[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
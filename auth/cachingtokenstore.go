@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A write-through TokenStore wrapper which caches looked up tokens in-process for
+// a configurable time, so authenticating a request doesn't hit the backend (eg.: Vault)
+// every time. Tokens revoked through the wrapper are invalidated immediately, with a
+// CacheInvalidator the invalidations are broadcast to the other Pipeline replicas too,
+// otherwise revoked tokens may be accepted by other replicas until the cache TTL passes.
+type cachingTokenStore struct {
+	tokenStore  TokenStore
+	ttl         time.Duration
+	invalidator CacheInvalidator
+
+	sync.RWMutex
+	cache map[string]map[string]cachedToken
+}
+
+type cachedToken struct {
+	token    *Token
+	cachedAt time.Time
+}
+
+// NewCachingTokenStore wraps a TokenStore with an in-process cache of the given TTL
+func NewCachingTokenStore(tokenStore TokenStore, ttl time.Duration) TokenStore {
+	return NewCachingTokenStoreWithInvalidator(tokenStore, ttl, nil)
+}
+
+// NewCachingTokenStoreWithInvalidator wraps a TokenStore with an in-process cache of the
+// given TTL, invalidations are published and received through the invalidator (if not nil)
+func NewCachingTokenStoreWithInvalidator(tokenStore TokenStore, ttl time.Duration, invalidator CacheInvalidator) TokenStore {
+	cachingStore := &cachingTokenStore{
+		tokenStore:  tokenStore,
+		ttl:         ttl,
+		invalidator: invalidator,
+		cache:       make(map[string]map[string]cachedToken),
+	}
+	if invalidator != nil {
+		invalidator.Subscribe(cachingStore.invalidate)
+	}
+	return cachingStore
+}
+
+func (tokenStore *cachingTokenStore) set(userId string, token *Token) {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	userTokens, ok := tokenStore.cache[userId]
+	if !ok {
+		userTokens = make(map[string]cachedToken)
+		tokenStore.cache[userId] = userTokens
+	}
+	userTokens[token.ID] = cachedToken{token: copyToken(token), cachedAt: time.Now()}
+}
+
+// invalidate removes a token from the local cache, an empty tokenId removes all tokens of the user
+func (tokenStore *cachingTokenStore) invalidate(userId, tokenId string) {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	if tokenId == "" {
+		delete(tokenStore.cache, userId)
+		return
+	}
+	if userTokens, ok := tokenStore.cache[userId]; ok {
+		delete(userTokens, tokenId)
+		if len(userTokens) == 0 {
+			delete(tokenStore.cache, userId)
+		}
+	}
+}
+
+// invalidateAll removes the token locally and from the cache of the other replicas
+func (tokenStore *cachingTokenStore) invalidateAll(userId, tokenId string) {
+	tokenStore.invalidate(userId, tokenId)
+	if tokenStore.invalidator != nil {
+		if err := tokenStore.invalidator.Publish(userId, tokenId); err != nil {
+			log.Warnf("Failed to publish token cache invalidation: %s", err)
+		}
+	}
+}
+
+func (tokenStore *cachingTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	if err := tokenStore.tokenStore.Store(ctx, userId, token); err != nil {
+		return err
+	}
+	tokenStore.set(userId, token)
+	return nil
+}
+
+func (tokenStore *cachingTokenStore) Lookup(ctx context.Context, userId, tokenId string) (*Token, error) {
+	tokenStore.RLock()
+	entry, found := tokenStore.cache[userId][tokenId]
+	tokenStore.RUnlock()
+	if found && time.Since(entry.cachedAt) < tokenStore.ttl && !entry.token.IsExpired() {
+		return copyToken(entry.token), nil
+	}
+
+	token, err := tokenStore.tokenStore.Lookup(ctx, userId, tokenId)
+	if err != nil {
+		if found {
+			tokenStore.invalidate(userId, tokenId)
+		}
+		return nil, err
+	}
+	tokenStore.set(userId, token)
+	return token, nil
+}
+
+func (tokenStore *cachingTokenStore) Revoke(ctx context.Context, userId, tokenId string) error {
+	err := tokenStore.tokenStore.Revoke(ctx, userId, tokenId)
+	tokenStore.invalidateAll(userId, tokenId)
+	return err
+}
+
+func (tokenStore *cachingTokenStore) RevokeAll(ctx context.Context, userId string) error {
+	err := tokenStore.tokenStore.RevokeAll(ctx, userId)
+	tokenStore.invalidateAll(userId, "")
+	return err
+}
+
+func (tokenStore *cachingTokenStore) List(ctx context.Context, userId string) ([]*Token, error) {
+	return tokenStore.tokenStore.List(ctx, userId)
+}
+
+func (tokenStore *cachingTokenStore) ListAll(ctx context.Context) (map[string][]*Token, error) {
+	return tokenStore.tokenStore.ListAll(ctx)
+}
+
+func (tokenStore *cachingTokenStore) ListUsers(ctx context.Context) ([]string, error) {
+	return tokenStore.tokenStore.ListUsers(ctx)
+}
+
+func (tokenStore *cachingTokenStore) Touch(ctx context.Context, userId, tokenId, ip string) error {
+	return tokenStore.tokenStore.Touch(ctx, userId, tokenId, ip)
+}
+
+func (tokenStore *cachingTokenStore) Rotate(ctx context.Context, userId, tokenId, newTokenId string) (*Token, error) {
+	token, err := tokenStore.tokenStore.Rotate(ctx, userId, tokenId, newTokenId)
+	tokenStore.invalidateAll(userId, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	tokenStore.set(userId, token)
+	return token, nil
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Database based implementation
+
+// AccessTokenModel is the database representation of an access token
+type AccessTokenModel struct {
+	ID        uint   `gorm:"primary_key"`
+	UserID    string `gorm:"not null;size:64;unique_index:idx_access_tokens_user_token"`
+	TokenID   string `gorm:"not null;size:64;unique_index:idx_access_tokens_user_token"`
+	Name      string
+	CreatedAt time.Time
+	ExpiresAt *time.Time `gorm:"index"`
+	Scopes    string
+	// AllowedCIDRs is the space separated IP allowlist of the token
+	AllowedCIDRs string
+	LastUsedAt   *time.Time
+	LastUsedIP   string
+}
+
+// TableName sets AccessTokenModel's table name
+func (AccessTokenModel) TableName() string {
+	return "access_tokens"
+}
+
+func (m *AccessTokenModel) toToken() *Token {
+	return &Token{
+		ID:           m.TokenID,
+		Name:         m.Name,
+		CreatedAt:    m.CreatedAt,
+		ExpiresAt:    m.ExpiresAt,
+		Scopes:       scopesFromString(m.Scopes),
+		AllowedCIDRs: cidrsFromString(m.AllowedCIDRs),
+		LastUsedAt:   m.LastUsedAt,
+		LastUsedIP:   m.LastUsedIP,
+	}
+}
+
+func scopesFromString(scopes string) []string {
+	if scopes == "" {
+		return nil
+	}
+	return strings.Fields(scopes)
+}
+
+// cidrsFromString splits the space separated IP allowlist of a stored token
+func cidrsFromString(cidrs string) []string {
+	if cidrs == "" {
+		return nil
+	}
+	return strings.Fields(cidrs)
+}
+
+// A TokenStore implementation which stores tokens in the Pipeline database,
+// for installations running without Vault.
+// The access_tokens table is created by the AutoMigrate call in main.
+// Gorm doesn't support contexts, the context is only checked before the queries.
+type dbTokenStore struct {
+	db *gorm.DB
+}
+
+// NewDBTokenStore creates a new database backed token store
+func NewDBTokenStore(db *gorm.DB) TokenStore {
+	return dbTokenStore{db: db}
+}
+
+func (tokenStore dbTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var m AccessTokenModel
+	return tokenStore.db.
+		Where(AccessTokenModel{UserID: userId, TokenID: token.ID}).
+		Assign(AccessTokenModel{
+			Name:         token.Name,
+			CreatedAt:    token.CreatedAt,
+			ExpiresAt:    token.ExpiresAt,
+			Scopes:       strings.Join(token.Scopes, " "),
+			AllowedCIDRs: strings.Join(token.AllowedCIDRs, " "),
+			LastUsedAt:   token.LastUsedAt,
+			LastUsedIP:   token.LastUsedIP,
+		}).
+		FirstOrCreate(&m).Error
+}
+
+func (tokenStore dbTokenStore) Lookup(ctx context.Context, userId, tokenId string) (*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var m AccessTokenModel
+	err := tokenStore.db.Where(AccessTokenModel{UserID: userId, TokenID: tokenId}).First(&m).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	token := m.toToken()
+	if token.IsExpired() {
+		// Garbage-collect the expired token lazily
+		if err := tokenStore.Revoke(ctx, userId, tokenId); err != nil {
+			log.Warnf("Failed to delete expired token: %s", err)
+		}
+		return nil, ErrTokenExpired
+	}
+	return token, nil
+}
+
+func (tokenStore dbTokenStore) Revoke(ctx context.Context, userId, tokenId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx := tokenStore.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	err := tx.Where(AccessTokenModel{UserID: userId, TokenID: tokenId}).Delete(AccessTokenModel{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (tokenStore dbTokenStore) Rotate(ctx context.Context, userId, tokenId, newTokenId string) (*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	old, err := tokenStore.Lookup(ctx, userId, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	token := rotatedToken(old, newTokenId)
+
+	tx := tokenStore.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	err = tx.Create(&AccessTokenModel{
+		UserID:       userId,
+		TokenID:      token.ID,
+		Name:         token.Name,
+		CreatedAt:    token.CreatedAt,
+		ExpiresAt:    token.ExpiresAt,
+		Scopes:       strings.Join(token.Scopes, " "),
+		AllowedCIDRs: strings.Join(token.AllowedCIDRs, " "),
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = tx.Where(AccessTokenModel{UserID: userId, TokenID: tokenId}).Delete(AccessTokenModel{}).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return token, tx.Commit().Error
+}
+
+func (tokenStore dbTokenStore) RevokeAll(ctx context.Context, userId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx := tokenStore.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	err := tx.Where(AccessTokenModel{UserID: userId}).Delete(AccessTokenModel{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (tokenStore dbTokenStore) List(ctx context.Context, userId string) ([]*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var models []AccessTokenModel
+	err := tokenStore.db.Where(AccessTokenModel{UserID: userId}).Order("created_at").Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]*Token, len(models))
+	for i := range models {
+		tokens[i] = models[i].toToken()
+	}
+	return tokens, nil
+}
+
+func (tokenStore dbTokenStore) ListAll(ctx context.Context) (map[string][]*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var models []AccessTokenModel
+	err := tokenStore.db.Order("user_id, created_at").Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	allTokens := make(map[string][]*Token)
+	for i := range models {
+		allTokens[models[i].UserID] = append(allTokens[models[i].UserID], models[i].toToken())
+	}
+	return allTokens, nil
+}
+
+func (tokenStore dbTokenStore) ListUsers(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var userIds []string
+	err := tokenStore.db.Model(&AccessTokenModel{}).Order("user_id").Pluck("DISTINCT user_id", &userIds).Error
+	if err != nil {
+		return nil, err
+	}
+	return userIds, nil
+}
+
+func (tokenStore dbTokenStore) Touch(ctx context.Context, userId, tokenId, ip string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	return tokenStore.db.Model(AccessTokenModel{}).
+		Where(AccessTokenModel{UserID: userId, TokenID: tokenId}).
+		Updates(AccessTokenModel{LastUsedAt: &now, LastUsedIP: ip}).Error
+}
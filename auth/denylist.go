@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RevokedTokenModel is the database representation of a revoked self-contained access token
+type RevokedTokenModel struct {
+	TokenID   string     `gorm:"primary_key;size:64"`
+	RevokedAt time.Time  `gorm:"index"`
+	ExpiresAt *time.Time `gorm:"index"`
+}
+
+// TableName sets RevokedTokenModel's table name
+func (RevokedTokenModel) TableName() string {
+	return "revoked_tokens"
+}
+
+// tokenDenylist keeps the revoked token IDs in memory, so checking a token doesn't need
+// a roundtrip. The revocations are written to the database and the other replicas pick
+// them up on their next Sync, expired entries are dropped since expired tokens are
+// rejected anyway.
+type tokenDenylist struct {
+	db *gorm.DB
+
+	sync.RWMutex
+	revoked  map[string]*time.Time
+	syncedAt time.Time
+}
+
+func newTokenDenylist(db *gorm.DB) *tokenDenylist {
+	return &tokenDenylist{db: db, revoked: make(map[string]*time.Time)}
+}
+
+func (denylist *tokenDenylist) Add(ctx context.Context, tokenID string, expiresAt *time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m := RevokedTokenModel{TokenID: tokenID, RevokedAt: time.Now(), ExpiresAt: expiresAt}
+	if err := denylist.db.Save(&m).Error; err != nil {
+		return err
+	}
+	denylist.Lock()
+	defer denylist.Unlock()
+	denylist.revoked[tokenID] = expiresAt
+	return nil
+}
+
+func (denylist *tokenDenylist) Contains(tokenID string) bool {
+	denylist.RLock()
+	defer denylist.RUnlock()
+	_, found := denylist.revoked[tokenID]
+	return found
+}
+
+// denylistSyncOverlap covers the clock skew between the replicas and in-flight transactions
+const denylistSyncOverlap = time.Minute
+
+// Sync loads the revocations made since the last Sync (all of them the first time)
+// and removes the expired entries from memory and from the database
+func (denylist *tokenDenylist) Sync() error {
+	now := time.Now()
+	denylist.RLock()
+	since := denylist.syncedAt.Add(-denylistSyncOverlap)
+	denylist.RUnlock()
+
+	var models []RevokedTokenModel
+	err := denylist.db.Where("revoked_at >= ? AND (expires_at IS NULL OR expires_at > ?)", since, now).Find(&models).Error
+	if err != nil {
+		return err
+	}
+
+	denylist.Lock()
+	for _, m := range models {
+		denylist.revoked[m.TokenID] = m.ExpiresAt
+	}
+	for tokenID, expiresAt := range denylist.revoked {
+		if expiresAt != nil && now.After(*expiresAt) {
+			delete(denylist.revoked, tokenID)
+		}
+	}
+	denylist.syncedAt = now
+	denylist.Unlock()
+
+	return denylist.db.Where("expires_at < ?", now).Delete(RevokedTokenModel{}).Error
+}
+
+// Run syncs the denylist with the given interval, it never returns
+func (denylist *tokenDenylist) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := denylist.Sync(); err != nil {
+			log.Warnf("Failed to sync the token denylist: %s", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/spf13/viper"
+)
+
+// droneClient calls the Drone API
+var droneClient = &http.Client{Timeout: 30 * time.Second}
+
+//DroneRepo is a repository activated in Drone, its webhook triggers the builds of its .pipeline.yml
+type DroneRepo struct {
+	ID       int64  `json:"id"`
+	Owner    string `json:"owner"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Link     string `json:"link_url"`
+	Active   bool   `json:"active"`
+}
+
+// droneSecret is a secret of a Drone repository passed to the steps of its builds
+type droneSecret struct {
+	Name   string   `json:"name"`
+	Value  string   `json:"value"`
+	Events []string `json:"event"`
+}
+
+//DroneError is an error response of the Drone API
+type DroneError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements error
+func (e *DroneError) Error() string {
+	return fmt.Sprintf("Drone responded %d: %s", e.StatusCode, e.Message)
+}
+
+// droneAPIToken creates a temporary Drone API token of the user
+func droneAPIToken(login string) (string, error) {
+	claims := &DroneClaims{Type: DroneUserCookieType, Text: login}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signingKeyBase32))
+}
+
+// droneRequest calls the Drone API as the user, the JSON response is decoded to the result if it's not nil
+func droneRequest(login, method, path string, body, result interface{}) error {
+	apiToken, err := droneAPIToken(login)
+	if err != nil {
+		return err
+	}
+	data := []byte{}
+	if body != nil {
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(viper.GetString("drone.url"), "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := droneClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &DroneError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+	return nil
+}
+
+// droneRepoPath returns the API path of the repository
+func droneRepoPath(owner, name string) string {
+	return "/api/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+//ActivateDroneRepo activates the repository of the user in Drone, Drone installs its webhook on the SCM
+func ActivateDroneRepo(login, owner, name string) (*DroneRepo, error) {
+	var repo DroneRepo
+	if err := droneRequest(login, http.MethodPost, droneRepoPath(owner, name), nil, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+//DeactivateDroneRepo deactivates the repository in Drone, Drone removes its webhook from the SCM
+func DeactivateDroneRepo(login, owner, name string) error {
+	err := droneRequest(login, http.MethodDelete, droneRepoPath(owner, name), nil, nil)
+	if droneErr, ok := err.(*DroneError); ok && droneErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+//SetDroneSecret creates or updates a secret of the repository passed to the push, tag and deployment builds
+func SetDroneSecret(login, owner, name, secretName, value string) error {
+	secret := droneSecret{Name: secretName, Value: value, Events: []string{"push", "tag", "deployment"}}
+	path := droneRepoPath(owner, name) + "/secrets"
+	err := droneRequest(login, http.MethodPatch, path+"/"+url.PathEscape(secretName), secret, nil)
+	if droneErr, ok := err.(*DroneError); ok && droneErr.StatusCode == http.StatusNotFound {
+		return droneRequest(login, http.MethodPost, path, secret, nil)
+	}
+	return err
+}
+
+//GetSCMToken returns the GitHub or GitLab token of the user stored in the Drone database, Drone keeps it fresh
+func GetSCMToken(userID uint) (string, error) {
+	storer, ok := Auth.UserStorer.(BanzaiUserStorer)
+	if !ok || storer.droneDB == nil {
+		return "", fmt.Errorf("the Drone integration is disabled")
+	}
+	var user User
+	if err := model.GetDB().First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	var droneUser DroneUser
+	if err := storer.droneDB.Where(DroneUser{Login: user.Login}).First(&droneUser).Error; err != nil {
+		return "", err
+	}
+	return droneUser.Token, nil
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// gitlabSource is GitLab.com or a self-hosted GitLab instance
+type gitlabSource struct {
+	url string
+}
+
+// NewGitlabUserSource creates an OAuthUserSource of the GitLab instance at url, eg.: https://gitlab.com
+func NewGitlabUserSource(url string) OAuthUserSource {
+	return gitlabSource{url: strings.TrimSuffix(url, "/")}
+}
+
+func (gitlabSource) GetName() string {
+	return "gitlab"
+}
+
+func (source gitlabSource) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  source.url + "/oauth/authorize",
+		TokenURL: source.url + "/oauth/token",
+	}
+}
+
+// Scopes are the same as Drone's
+func (gitlabSource) Scopes() []string {
+	return []string{"api", "read_user"}
+}
+
+func (source gitlabSource) FetchUser(client *http.Client) (*OAuthUser, error) {
+	var user struct {
+		ID        int    `json:"id"`
+		Username  string `json:"username"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getOAuthJSON(client, source.url+"/api/v4/user", &user); err != nil {
+		return nil, err
+	}
+	return &OAuthUser{
+		ID:    strconv.Itoa(user.ID),
+		Login: user.Username,
+		Name:  user.Name,
+		Email: user.Email,
+		Image: user.AvatarURL,
+	}, nil
+}
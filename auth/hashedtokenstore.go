@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// A TokenStore wrapper which stores only the SHA-256 hash of the token IDs in
+// the underlying store, so a compromised backend (Vault, database or a memory
+// dump) doesn't leak token IDs which could be replayed directly.
+// Listed tokens carry the hashed ID, Revoke and Rotate accept both the raw and the hashed ID.
+type hashedTokenStore struct {
+	tokenStore TokenStore
+}
+
+// NewHashedTokenStore wraps a TokenStore to store hashed token IDs only
+func NewHashedTokenStore(tokenStore TokenStore) TokenStore {
+	return hashedTokenStore{tokenStore: tokenStore}
+}
+
+func hashTokenID(tokenId string) string {
+	sum := sha256.Sum256([]byte(tokenId))
+	return hex.EncodeToString(sum[:])
+}
+
+func (tokenStore hashedTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	hashed := *token
+	hashed.ID = hashTokenID(token.ID)
+	return tokenStore.tokenStore.Store(ctx, userId, &hashed)
+}
+
+func (tokenStore hashedTokenStore) Lookup(ctx context.Context, userId, tokenId string) (*Token, error) {
+	return tokenStore.tokenStore.Lookup(ctx, userId, hashTokenID(tokenId))
+}
+
+func (tokenStore hashedTokenStore) Revoke(ctx context.Context, userId, tokenId string) error {
+	// tokenId is either the hash (as listed) or the raw token ID
+	if err := tokenStore.tokenStore.Revoke(ctx, userId, tokenId); err != nil {
+		return err
+	}
+	return tokenStore.tokenStore.Revoke(ctx, userId, hashTokenID(tokenId))
+}
+
+func (tokenStore hashedTokenStore) Rotate(ctx context.Context, userId, tokenId, newTokenId string) (*Token, error) {
+	// tokenId is either the raw token ID or the hash (as listed)
+	token, err := tokenStore.tokenStore.Rotate(ctx, userId, hashTokenID(tokenId), hashTokenID(newTokenId))
+	if err == ErrTokenNotFound {
+		token, err = tokenStore.tokenStore.Rotate(ctx, userId, tokenId, hashTokenID(newTokenId))
+	}
+	if err != nil {
+		return nil, err
+	}
+	rotated := copyToken(token)
+	rotated.ID = newTokenId
+	return rotated, nil
+}
+
+func (tokenStore hashedTokenStore) RevokeAll(ctx context.Context, userId string) error {
+	return tokenStore.tokenStore.RevokeAll(ctx, userId)
+}
+
+func (tokenStore hashedTokenStore) List(ctx context.Context, userId string) ([]*Token, error) {
+	return tokenStore.tokenStore.List(ctx, userId)
+}
+
+func (tokenStore hashedTokenStore) ListAll(ctx context.Context) (map[string][]*Token, error) {
+	return tokenStore.tokenStore.ListAll(ctx)
+}
+
+func (tokenStore hashedTokenStore) ListUsers(ctx context.Context) ([]string, error) {
+	return tokenStore.tokenStore.ListUsers(ctx)
+}
+
+func (tokenStore hashedTokenStore) Touch(ctx context.Context, userId, tokenId, ip string) error {
+	return tokenStore.tokenStore.Touch(ctx, userId, hashTokenID(tokenId), ip)
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/qor/utils"
+	"github.com/spf13/viper"
+)
+
+// ImpersonateUserHeader is the header of the user (ID or login) an admin acts as
+const ImpersonateUserHeader = "X-Impersonate-User"
+
+// CurrentImpersonator is the context key of the admin impersonating the current user
+const CurrentImpersonator utils.ContextKey = "impersonator"
+
+// GetCurrentImpersonator returns the admin acting as the current user or nil if the request isn't impersonated
+func GetCurrentImpersonator(req *http.Request) *User {
+	if impersonator, ok := req.Context().Value(CurrentImpersonator).(*User); ok {
+		return impersonator
+	}
+	return nil
+}
+
+// impersonationScopes drops the ScopeAll of the admin, so impersonated requests can't manage tokens
+func impersonationScopes(scopes []string) []string {
+	for _, scope := range scopes {
+		if scope == ScopeAll {
+			var resourceScopes []string
+			for _, resource := range scopeResources {
+				resourceScopes = append(resourceScopes, resource+":write")
+			}
+			return resourceScopes
+		}
+	}
+	return scopes
+}
+
+// ImpersonationMiddleware replaces the current user with the one in the X-Impersonate-User header,
+// only admins can impersonate and only non-admin users can be impersonated
+func ImpersonationMiddleware(c *gin.Context) {
+	target := c.GetHeader(ImpersonateUserHeader)
+	if target == "" {
+		c.Next()
+		return
+	}
+
+	abort := func(code int, message string) {
+		log.Info(c.ClientIP(), "Impersonation rejected: "+message)
+		c.AbortWithStatusJSON(code, btype.ErrorResponse{
+			Code:    code,
+			Message: "Impersonation rejected",
+			Error:   message,
+		})
+	}
+	if !viper.GetBool("auth.impersonation") {
+		abort(http.StatusForbidden, "impersonation is disabled")
+		return
+	}
+	if GetCurrentServiceAccount(c.Request) != nil {
+		abort(http.StatusForbidden, "service accounts can't impersonate")
+		return
+	}
+	admin, err := GetCurrentUserFromDB(c.Request)
+	if err != nil || !IsAdmin(admin) {
+		abort(http.StatusForbidden, "admin privileges required")
+		return
+	}
+
+	var user User
+	db := model.GetDB()
+	if id, parseErr := strconv.ParseUint(target, 10, 32); parseErr == nil {
+		err = db.First(&user, id).Error
+	} else {
+		err = db.Where(&User{Login: target}).First(&user).Error
+	}
+	if err == gorm.ErrRecordNotFound {
+		abort(http.StatusNotFound, "user not found: "+target)
+		return
+	} else if err != nil {
+		abort(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if IsAdmin(&user) {
+		abort(http.StatusForbidden, "admins can't be impersonated")
+		return
+	}
+
+	auditImpersonation(c, admin, &user)
+
+	scopes := impersonationScopes(GetCurrentScopes(c.Request))
+	newContext := context.WithValue(c.Request.Context(), auth.CurrentUser, &User{ID: user.ID})
+	newContext = context.WithValue(newContext, CurrentImpersonator, admin)
+	newContext = context.WithValue(newContext, CurrentScopes, scopes)
+	c.Request = c.Request.WithContext(newContext)
+	c.Next()
+}
+
+// auditImpersonation records an impersonated request in the token audit log
+func auditImpersonation(c *gin.Context, admin, user *User) {
+	log.Infof("Admin %d is impersonating user %d: %s %s", admin.ID, user.ID, c.Request.Method, c.Request.URL.Path)
+	if auditSink == nil {
+		return
+	}
+	event := &TokenAuditEvent{
+		Time:      time.Now().UTC(),
+		Action:    AuditActionImpersonate,
+		UserID:    strconv.Itoa(int(user.ID)),
+		Actor:     strconv.Itoa(int(admin.ID)),
+		IP:        RequestIP(c.Request),
+		UserAgent: c.Request.UserAgent(),
+		Resource:  c.Request.Method + " " + c.Request.URL.Path,
+	}
+	if err := auditSink.Write(event); err != nil {
+		log.Errorf("Failed to write token audit event: %s", err)
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseCIDRs validates the IP allowlist of a token and returns it in canonical form,
+// single IP addresses are converted to /32 or /128 ranges
+func ParseCIDRs(cidrs []string) ([]string, error) {
+	var parsed []string
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP range: %q", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range: %q", cidr)
+		}
+		parsed = append(parsed, ipNet.String())
+	}
+	return parsed, nil
+}
+
+// IPAllowed checks whether the IP address is in one of the ranges, an empty allowlist allows every address
+func IPAllowed(cidrs []string, address string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedProxies are the ranges of the reverse proxies whose X-Forwarded-For and X-Real-Ip headers are trusted
+var trustedProxies []string
+
+// ClientIP returns the IP address of the client of the request: the host of the peer address, unless the peer
+// is one of the trusted proxies, then the last address of the X-Forwarded-For header which isn't a trusted proxy
+// (or the X-Real-Ip header). The addresses before it can be set by anyone, so they are never used.
+func ClientIP(request *http.Request, proxies []string) string {
+	remote := strings.TrimSpace(request.RemoteAddr)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if len(proxies) == 0 || !ipInRanges(proxies, remote) {
+		return remote
+	}
+	var forwarded []string
+	for _, header := range request.Header[http.CanonicalHeaderKey("X-Forwarded-For")] {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		// an invalid address is returned as it is, it isn't allowed by any allowlist
+		if net.ParseIP(address) == nil || !ipInRanges(proxies, address) {
+			return address
+		}
+	}
+	if address := strings.TrimSpace(request.Header.Get("X-Real-Ip")); net.ParseIP(address) != nil {
+		return address
+	}
+	return remote
+}
+
+// RequestIP returns the IP address of the client of the request behind the configured trusted proxies
+func RequestIP(request *http.Request) string {
+	return ClientIP(request, trustedProxies)
+}
+
+// ipInRanges checks whether the IP address is in one of the ranges
+func ipInRanges(cidrs []string, address string) bool {
+	return len(cidrs) > 0 && IPAllowed(cidrs, address)
+}
@@ -0,0 +1,93 @@
+package auth_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/auth"
+)
+
+func TestParseCIDRs(t *testing.T) {
+
+	cidrs, err := auth.ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.10", "2001:db8::1", "172.16.5.4/12"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []string{"10.0.0.0/8", "192.168.1.10/32", "2001:db8::1/128", "172.16.0.0/12"}
+	if !reflect.DeepEqual(cidrs, expected) {
+		t.Errorf("Expected: %v, but got: %v", expected, cidrs)
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "example.com", ""} {
+		if _, err := auth.ParseCIDRs([]string{invalid}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		cidrs    []string
+		address  string
+		expected bool
+	}{
+		{name: "no allowlist", cidrs: nil, address: "203.0.113.1", expected: true},
+		{name: "in range", cidrs: []string{"10.0.0.0/8"}, address: "10.1.2.3", expected: true},
+		{name: "out of range", cidrs: []string{"10.0.0.0/8"}, address: "11.1.2.3", expected: false},
+		{name: "second range", cidrs: []string{"10.0.0.0/8", "192.168.0.0/16"}, address: "192.168.1.1", expected: true},
+		{name: "ipv6", cidrs: []string{"2001:db8::/32"}, address: "2001:db8::5", expected: true},
+		{name: "invalid address", cidrs: []string{"10.0.0.0/8"}, address: "unknown", expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := auth.IPAllowed(tc.cidrs, tc.address); actual != tc.expected {
+				t.Errorf("Expected: %t, but got: %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+
+	proxies := []string{"10.0.0.0/8"}
+	cases := []struct {
+		name         string
+		proxies      []string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		expected     string
+	}{
+		{name: "direct", proxies: proxies, remoteAddr: "203.0.113.1:4711", expected: "203.0.113.1"},
+		{name: "spoofed forwarded for", proxies: proxies, remoteAddr: "203.0.113.1:4711", forwardedFor: []string{"10.1.2.3"}, expected: "203.0.113.1"},
+		{name: "spoofed real ip", proxies: proxies, remoteAddr: "203.0.113.1:4711", realIP: "10.1.2.3", expected: "203.0.113.1"},
+		{name: "no trusted proxies", proxies: nil, remoteAddr: "10.0.0.5:4711", forwardedFor: []string{"198.51.100.7"}, expected: "10.0.0.5"},
+		{name: "trusted proxy", proxies: proxies, remoteAddr: "10.0.0.5:4711", forwardedFor: []string{"198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "spoofed first entry", proxies: proxies, remoteAddr: "10.0.0.5:4711", forwardedFor: []string{"10.1.2.3, 198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "proxy chain", proxies: proxies, remoteAddr: "10.0.0.5:4711", forwardedFor: []string{"198.51.100.7, 10.0.0.6", "10.0.0.7"}, expected: "198.51.100.7"},
+		{name: "invalid entry", proxies: proxies, remoteAddr: "10.0.0.5:4711", forwardedFor: []string{"10.1.2.3, unknown"}, expected: "unknown"},
+		{name: "real ip", proxies: proxies, remoteAddr: "10.0.0.5:4711", realIP: "198.51.100.7", expected: "198.51.100.7"},
+		{name: "only proxies", proxies: proxies, remoteAddr: "10.0.0.5:4711", forwardedFor: []string{"10.0.0.6"}, expected: "10.0.0.5"},
+		{name: "ipv6", proxies: proxies, remoteAddr: "[2001:db8::5]:4711", forwardedFor: []string{"10.1.2.3"}, expected: "2001:db8::5"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/api/v1/token", nil)
+			request.RemoteAddr = tc.remoteAddr
+			for _, header := range tc.forwardedFor {
+				request.Header.Add("X-Forwarded-For", header)
+			}
+			if tc.realIP != "" {
+				request.Header.Set("X-Real-Ip", tc.realIP)
+			}
+			if actual := auth.ClientIP(request, tc.proxies); actual != tc.expected {
+				t.Errorf("Expected: %s, but got: %s", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+// LDAPRoleAdmin is the role of the LDAP groups whose members are Pipeline admins
+const LDAPRoleAdmin = "admin"
+
+// LDAPExtraInfo struct for LDAP user info
+type LDAPExtraInfo struct {
+	Login  string
+	Groups []string
+	Admin  bool
+}
+
+// LDAPConfig is the configuration of an LDAP or Active Directory server
+type LDAPConfig struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	CAFile             string
+	Timeout            time.Duration
+	PoolSize           int
+
+	// The service account searching the users and their groups, empty means anonymous searches
+	BindDN       string
+	BindPassword string
+
+	UserBaseDN     string
+	UserFilter     string // "(uid=%s)" by default, "(sAMAccountName=%s)" for Active Directory
+	LoginAttribute string // "uid" by default, "sAMAccountName" for Active Directory
+	NameAttribute  string // "cn" by default
+	EmailAttribute string // "mail" by default
+
+	// Groups are searched in GroupBaseDN with GroupFilter ("(member=%s)" by default, %s is the user DN),
+	// or read from the memberOf attribute of the user if GroupBaseDN is empty
+	GroupBaseDN string
+	GroupFilter string
+
+	// GroupRoles maps group DNs or CNs to roles, the members of "admin" groups are Pipeline admins.
+	// If it's not empty, only the members of the listed groups can log in.
+	GroupRoles map[string]string
+}
+
+// LDAPProvider provides login with the username and password of an LDAP user,
+// the credentials are posted to /auth/ldap/login as the login and password form fields
+type LDAPProvider struct {
+	*LDAPConfig
+	connConfig ldapConfig
+	pool       *ldapPool
+}
+
+// NewLDAPProvider creates an LDAPProvider, the connection to the server is checked
+func NewLDAPProvider(config *LDAPConfig) (*LDAPProvider, error) {
+	if config.URL == "" || config.UserBaseDN == "" {
+		return nil, errors.New("LDAP URL and user base DN can't be blank")
+	}
+	defaults := map[*string]string{
+		&config.UserFilter:     "(uid=%s)",
+		&config.LoginAttribute: "uid",
+		&config.NameAttribute:  "cn",
+		&config.EmailAttribute: "mail",
+		&config.GroupFilter:    "(member=%s)",
+	}
+	for attribute, value := range defaults {
+		if *attribute == "" {
+			*attribute = value
+		}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+	}
+
+	provider := &LDAPProvider{
+		LDAPConfig: config,
+		connConfig: ldapConfig{URL: config.URL, StartTLS: config.StartTLS, TLSConfig: tlsConfig, Timeout: config.Timeout},
+	}
+	provider.pool = newLDAPPool(config.PoolSize, provider.dialServiceConn)
+
+	conn, err := provider.pool.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP: %s", err)
+	}
+	provider.pool.Put(conn, nil)
+	return provider, nil
+}
+
+// dialServiceConn opens a connection bound with the service account
+func (provider *LDAPProvider) dialServiceConn() (*ldapConn, error) {
+	conn, err := dialLDAP(provider.connConfig)
+	if err != nil {
+		return nil, err
+	}
+	if provider.BindDN != "" {
+		if err := conn.Bind(provider.BindDN, provider.BindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// search runs a search with a pooled service connection
+func (provider *LDAPProvider) search(baseDN, filter string, attributes []string, sizeLimit int) ([]*ldapEntry, error) {
+	conn, err := provider.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := conn.Search(baseDN, filter, attributes, sizeLimit)
+	provider.pool.Put(conn, err)
+	return entries, err
+}
+
+// authenticate finds the user and checks the password with a bind as the user,
+// it returns auth.ErrUnauthorized for unknown users and invalid passwords
+func (provider *LDAPProvider) authenticate(username, password string) (*ldapEntry, []string, error) {
+	if username == "" || password == "" {
+		return nil, nil, auth.ErrUnauthorized
+	}
+	attributes := []string{provider.LoginAttribute, provider.NameAttribute, provider.EmailAttribute, "memberOf"}
+	entries, err := provider.search(provider.UserBaseDN, fmt.Sprintf(provider.UserFilter, ldapEscapeFilterValue(username)), attributes, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) != 1 {
+		return nil, nil, auth.ErrUnauthorized
+	}
+	user := entries[0]
+
+	// The user's bind goes through a new connection, so the pooled ones stay bound with the service account
+	conn, err := dialLDAP(provider.connConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	if err := conn.Bind(user.DN, password); err != nil {
+		if ldapErr, ok := err.(ldapError); ok && ldapErr.Code == ldapResultInvalidCredentials {
+			return nil, nil, auth.ErrUnauthorized
+		}
+		return nil, nil, err
+	}
+
+	groups := user.GetAll("memberOf")
+	if provider.GroupBaseDN != "" {
+		groupEntries, err := provider.search(provider.GroupBaseDN, fmt.Sprintf(provider.GroupFilter, ldapEscapeFilterValue(user.DN)), []string{"cn"}, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		groups = nil
+		for _, group := range groupEntries {
+			groups = append(groups, group.DN)
+		}
+	}
+	return user, groups, nil
+}
+
+// ldapGroupCN returns the value of the first RDN of a group DN, eg.: "developers" of "cn=developers,ou=groups,dc=example,dc=org"
+func ldapGroupCN(dn string) string {
+	rdn := strings.SplitN(dn, ",", 2)[0]
+	if eq := strings.IndexByte(rdn, '='); eq >= 0 {
+		return strings.TrimSpace(rdn[eq+1:])
+	}
+	return rdn
+}
+
+// roles returns the roles of the groups mapped in GroupRoles
+func (provider *LDAPProvider) roles(groups []string) []string {
+	var roles []string
+	for _, group := range groups {
+		for mapped, role := range provider.GroupRoles {
+			if strings.EqualFold(mapped, group) || strings.EqualFold(mapped, ldapGroupCN(group)) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// GetName return provider name
+func (*LDAPProvider) GetName() string {
+	return "ldap"
+}
+
+// ConfigAuth config auth
+func (provider *LDAPProvider) ConfigAuth(*auth.Auth) {
+}
+
+// Login implemented login with the LDAP provider
+func (provider *LDAPProvider) Login(context *auth.Context) {
+	if context.Request.Method != http.MethodPost {
+		http.Error(context.Writer, "the login and password must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+	context.Auth.LoginHandler(context, provider.authorize)
+}
+
+// Logout implemented logout with the LDAP provider
+func (*LDAPProvider) Logout(context *auth.Context) {
+}
+
+// Register implemented register with the LDAP provider, users are registered at their first login
+func (provider *LDAPProvider) Register(context *auth.Context) {
+	provider.Login(context)
+}
+
+// Callback implement Callback with the LDAP provider
+func (*LDAPProvider) Callback(context *auth.Context) {
+}
+
+// ServeHTTP implement ServeHTTP with the LDAP provider
+func (*LDAPProvider) ServeHTTP(*auth.Context) {
+}
+
+func (provider *LDAPProvider) authorize(context *auth.Context) (*claims.Claims, error) {
+	var (
+		schema       auth.Schema
+		authInfo     auth_identity.Basic
+		authIdentity = reflect.New(utils.ModelType(context.Auth.Config.AuthIdentityModel)).Interface()
+		req          = context.Request
+		tx           = context.Auth.GetDB(req)
+	)
+
+	user, groups, err := provider.authenticate(req.FormValue("login"), req.FormValue("password"))
+	if err != nil {
+		log.Info(context.Request.RemoteAddr, err.Error())
+		return nil, err
+	}
+
+	roles := provider.roles(groups)
+	if len(provider.GroupRoles) > 0 && len(roles) == 0 {
+		log.Info(context.Request.RemoteAddr, fmt.Sprintf("LDAP user %q is not a member of any mapped group", user.DN))
+		return nil, auth.ErrUnauthorized
+	}
+	admin := false
+	for _, role := range roles {
+		admin = admin || role == LDAPRoleAdmin
+	}
+	groupCNs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupCNs = append(groupCNs, ldapGroupCN(group))
+	}
+
+	login := user.Get(provider.LoginAttribute)
+	authInfo.Provider = provider.GetName()
+	authInfo.UID = login
+
+	if !tx.Model(authIdentity).Where(authInfo).Scan(&authInfo).RecordNotFound() {
+		// The group memberships may have changed since the last login
+		if err := updateLDAPUser(tx, authInfo.UserID, groupCNs, admin); err != nil {
+			log.Info(context.Request.RemoteAddr, err.Error())
+			return nil, err
+		}
+		return authInfo.ToClaims(), nil
+	}
+
+	{
+		schema.Provider = provider.GetName()
+		schema.UID = login
+		schema.Name = user.Get(provider.NameAttribute)
+		schema.Email = user.Get(provider.EmailAttribute)
+		schema.RawInfo = &LDAPExtraInfo{Login: login, Groups: groupCNs, Admin: admin}
+	}
+	if _, userID, err := context.Auth.UserStorer.Save(&schema, context); err == nil {
+		if userID != "" {
+			authInfo.UserID = userID
+		}
+	} else {
+		return nil, err
+	}
+
+	if err = tx.Where(authInfo).FirstOrCreate(authIdentity).Error; err == nil {
+		return authInfo.ToClaims(), nil
+	}
+
+	log.Info(context.Request.RemoteAddr, err.Error())
+	return nil, err
+}
+
+// updateLDAPUser syncs the admin flag and the organizations of a returning LDAP user
+func updateLDAPUser(db *gorm.DB, userID string, groups []string, admin bool) error {
+	if err := db.Model(&User{}).Where("id = ?", userID).Update("admin", admin).Error; err != nil {
+		return err
+	}
+	return joinGroupOrganizations(db, userID, groups)
+}
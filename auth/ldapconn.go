@@ -0,0 +1,537 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal LDAPv3 client (RFC 4511) covering what the LDAP authenticator needs:
+// simple bind, subtree search, StartTLS and unbind. The messages are BER encoded,
+// the encoder writes DER (a subset of BER) and the decoder accepts any definite length.
+
+// BER identifiers used by LDAP
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+	berSet         = 0x31
+
+	ldapBindRequest         = 0x60
+	ldapBindResponse        = 0x61
+	ldapUnbindRequest       = 0x42
+	ldapSearchRequest       = 0x63
+	ldapSearchResultEntry   = 0x64
+	ldapSearchResultDone    = 0x65
+	ldapSearchResultRef     = 0x73
+	ldapExtendedRequest     = 0x77
+	ldapExtendedResponse    = 0x78
+	ldapSimpleAuth          = 0x80
+	ldapExtendedRequestName = 0x80
+
+	ldapFilterAnd      = 0xa0
+	ldapFilterOr       = 0xa1
+	ldapFilterNot      = 0xa2
+	ldapFilterEquality = 0xa3
+	ldapFilterPresent  = 0x87
+
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+)
+
+// ldapError is a non-successful LDAPResult
+type ldapError struct {
+	Code    int
+	Message string
+}
+
+func (e ldapError) Error() string {
+	return fmt.Sprintf("LDAP result code %d: %s", e.Code, e.Message)
+}
+
+// berTLV is a decoded BER element
+type berTLV struct {
+	tag     byte
+	content []byte
+}
+
+func berEncode(tag byte, content []byte) []byte {
+	length := len(content)
+	var header []byte
+	if length < 0x80 {
+		header = []byte{tag, byte(length)}
+	} else {
+		var lengthBytes []byte
+		for l := length; l > 0; l >>= 8 {
+			lengthBytes = append([]byte{byte(l)}, lengthBytes...)
+		}
+		header = append([]byte{tag, 0x80 | byte(len(lengthBytes))}, lengthBytes...)
+	}
+	return append(header, content...)
+}
+
+func berConstructed(tag byte, children ...[]byte) []byte {
+	var content []byte
+	for _, child := range children {
+		content = append(content, child...)
+	}
+	return berEncode(tag, content)
+}
+
+func berInt(tag byte, value int) []byte {
+	// Minimal two's complement encoding of non-negative values
+	content := []byte{byte(value)}
+	for value >>= 8; value > 0; value >>= 8 {
+		content = append([]byte{byte(value)}, content...)
+	}
+	if content[0]&0x80 != 0 {
+		content = append([]byte{0}, content...)
+	}
+	return berEncode(tag, content)
+}
+
+func berString(tag byte, value string) []byte {
+	return berEncode(tag, []byte(value))
+}
+
+// berReadLength reads a definite length from the reader
+func berReadLength(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+	n := int(b & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, errors.New("unsupported BER length")
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+func berRead(r *bufio.Reader) (berTLV, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return berTLV{}, err
+	}
+	length, err := berReadLength(r)
+	if err != nil {
+		return berTLV{}, err
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return berTLV{}, err
+	}
+	return berTLV{tag: tag, content: content}, nil
+}
+
+// berChildren decodes the elements of a constructed element
+func berChildren(content []byte) ([]berTLV, error) {
+	var children []berTLV
+	r := bufio.NewReader(bytes.NewReader(content))
+	for {
+		child, err := berRead(r)
+		if err == io.EOF {
+			return children, nil
+		} else if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+}
+
+func berParseInt(content []byte) int {
+	value := 0
+	for _, b := range content {
+		value = value<<8 | int(b)
+	}
+	return value
+}
+
+// ldapParseResult checks the LDAPResult fields of a response
+func ldapParseResult(content []byte) error {
+	fields, err := berChildren(content)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 3 {
+		return errors.New("invalid LDAPResult")
+	}
+	if code := berParseInt(fields[0].content); code != ldapResultSuccess {
+		return ldapError{Code: code, Message: string(fields[2].content)}
+	}
+	return nil
+}
+
+// ldapEscapeFilterValue escapes a value of an LDAP search filter (RFC 4515)
+func ldapEscapeFilterValue(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&escaped, "\\%02x", c)
+		default:
+			escaped.WriteByte(c)
+		}
+	}
+	return escaped.String()
+}
+
+// ldapEncodeFilter encodes the supported subset of the string filters:
+// &, |, !, equality (attr=value) and presence (attr=*)
+func ldapEncodeFilter(filter string) ([]byte, error) {
+	encoded, rest, err := ldapEncodeFilterPart(strings.TrimSpace(filter))
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid LDAP filter %q", filter)
+	}
+	return encoded, nil
+}
+
+func ldapEncodeFilterPart(filter string) ([]byte, string, error) {
+	if !strings.HasPrefix(filter, "(") {
+		return nil, "", fmt.Errorf("invalid LDAP filter %q", filter)
+	}
+	filter = filter[1:]
+	switch {
+	case strings.HasPrefix(filter, "&"), strings.HasPrefix(filter, "|"), strings.HasPrefix(filter, "!"):
+		tag := map[byte]byte{'&': ldapFilterAnd, '|': ldapFilterOr, '!': ldapFilterNot}[filter[0]]
+		rest := filter[1:]
+		var children [][]byte
+		for strings.HasPrefix(rest, "(") {
+			child, r, err := ldapEncodeFilterPart(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			children = append(children, child)
+			rest = r
+		}
+		if !strings.HasPrefix(rest, ")") || len(children) == 0 || (tag == ldapFilterNot && len(children) != 1) {
+			return nil, "", fmt.Errorf("invalid LDAP filter near %q", filter)
+		}
+		return berConstructed(tag, children...), rest[1:], nil
+	default:
+		end := strings.IndexByte(filter, ')')
+		eq := strings.IndexByte(filter, '=')
+		if end < 0 || eq < 1 || eq > end {
+			return nil, "", fmt.Errorf("invalid LDAP filter near %q", filter)
+		}
+		attr, value := filter[:eq], filter[eq+1:end]
+		if value == "*" {
+			return berString(ldapFilterPresent, attr), filter[end+1:], nil
+		}
+		unescaped, err := ldapUnescapeFilterValue(value)
+		if err != nil {
+			return nil, "", err
+		}
+		return berConstructed(ldapFilterEquality, berString(berOctetString, attr), berString(berOctetString, unescaped)), filter[end+1:], nil
+	}
+}
+
+func ldapUnescapeFilterValue(value string) (string, error) {
+	var unescaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			unescaped.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("invalid escape in LDAP filter value %q", value)
+		}
+		c, err := strconv.ParseUint(value[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in LDAP filter value %q", value)
+		}
+		unescaped.WriteByte(byte(c))
+		i += 2
+	}
+	return unescaped.String(), nil
+}
+
+// ldapEntry is a search result
+type ldapEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get returns the first value of an attribute, the attribute names are case insensitive
+func (entry *ldapEntry) Get(attribute string) string {
+	if values := entry.GetAll(attribute); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// GetAll returns the values of an attribute
+func (entry *ldapEntry) GetAll(attribute string) []string {
+	for name, values := range entry.Attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+// ldapConfig is the connection configuration of the LDAP server
+type ldapConfig struct {
+	URL       string // ldap://host:389 or ldaps://host:636
+	StartTLS  bool
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+// ldapConn is a connection to the LDAP server, requests are sent one at a time
+type ldapConn struct {
+	sync.Mutex
+	conn      net.Conn
+	reader    *bufio.Reader
+	timeout   time.Duration
+	messageID int
+}
+
+func dialLDAP(config ldapConfig) (*ldapConn, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "389")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "636")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, ldapTLSConfig(config.TLSConfig, u.Hostname()))
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL scheme: %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ldapConn{conn: conn, reader: bufio.NewReader(conn), timeout: config.Timeout}
+	if config.StartTLS && u.Scheme == "ldap" {
+		if err := c.startTLS(ldapTLSConfig(config.TLSConfig, u.Hostname())); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func ldapTLSConfig(config *tls.Config, serverName string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+	return config
+}
+
+// roundTrip sends a request and reads the responses until one with a tag in done arrives
+func (c *ldapConn) roundTrip(request []byte, done byte, handle func(berTLV) error) (berTLV, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.messageID++
+	messageID := c.messageID
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if _, err := c.conn.Write(berConstructed(berSequence, berInt(berInteger, messageID), request)); err != nil {
+		return berTLV{}, err
+	}
+	for {
+		message, err := berRead(c.reader)
+		if err != nil {
+			return berTLV{}, err
+		}
+		fields, err := berChildren(message.content)
+		if err != nil {
+			return berTLV{}, err
+		}
+		if message.tag != berSequence || len(fields) < 2 || berParseInt(fields[0].content) != messageID {
+			return berTLV{}, errors.New("unexpected LDAP message")
+		}
+		if fields[1].tag == done {
+			return fields[1], nil
+		}
+		if handle != nil {
+			if err := handle(fields[1]); err != nil {
+				return berTLV{}, err
+			}
+		}
+	}
+}
+
+func (c *ldapConn) startTLS(config *tls.Config) error {
+	response, err := c.roundTrip(berConstructed(ldapExtendedRequest, berString(ldapExtendedRequestName, ldapStartTLSOID)), ldapExtendedResponse, nil)
+	if err != nil {
+		return err
+	}
+	if err := ldapParseResult(response.content); err != nil {
+		return fmt.Errorf("StartTLS failed: %s", err)
+	}
+	tlsConn := tls.Client(c.conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind, the password must not be empty,
+// otherwise the bind would be an unauthenticated one which always succeeds
+func (c *ldapConn) Bind(dn, password string) error {
+	if password == "" {
+		return ldapError{Code: ldapResultInvalidCredentials, Message: "empty password"}
+	}
+	response, err := c.roundTrip(berConstructed(ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(berOctetString, dn),
+		berString(ldapSimpleAuth, password),
+	), ldapBindResponse, nil)
+	if err != nil {
+		return err
+	}
+	return ldapParseResult(response.content)
+}
+
+// Search runs a subtree search returning at most sizeLimit entries
+func (c *ldapConn) Search(baseDN, filter string, attributes []string, sizeLimit int) ([]*ldapEntry, error) {
+	encodedFilter, err := ldapEncodeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var encodedAttributes [][]byte
+	for _, attribute := range attributes {
+		encodedAttributes = append(encodedAttributes, berString(berOctetString, attribute))
+	}
+	request := berConstructed(ldapSearchRequest,
+		berString(berOctetString, baseDN),
+		berInt(berEnumerated, 2), // wholeSubtree
+		berInt(berEnumerated, 0), // neverDerefAliases
+		berInt(berInteger, sizeLimit),
+		berInt(berInteger, int(c.timeout.Seconds())),
+		berEncode(berBoolean, []byte{0}),
+		encodedFilter,
+		berConstructed(berSequence, encodedAttributes...),
+	)
+
+	var entries []*ldapEntry
+	response, err := c.roundTrip(request, ldapSearchResultDone, func(op berTLV) error {
+		if op.tag == ldapSearchResultRef {
+			return nil // referrals are not followed
+		}
+		if op.tag != ldapSearchResultEntry {
+			return fmt.Errorf("unexpected LDAP search response: %#x", op.tag)
+		}
+		fields, err := berChildren(op.content)
+		if err != nil || len(fields) < 2 {
+			return errors.New("invalid LDAP search result entry")
+		}
+		entry := &ldapEntry{DN: string(fields[0].content), Attributes: make(map[string][]string)}
+		attributeList, err := berChildren(fields[1].content)
+		if err != nil {
+			return err
+		}
+		for _, attribute := range attributeList {
+			parts, err := berChildren(attribute.content)
+			if err != nil || len(parts) < 2 {
+				return errors.New("invalid LDAP attribute")
+			}
+			values, err := berChildren(parts[1].content)
+			if err != nil {
+				return err
+			}
+			for _, value := range values {
+				entry.Attributes[string(parts[0].content)] = append(entry.Attributes[string(parts[0].content)], string(value.content))
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ldapParseResult(response.content); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Close unbinds and closes the connection
+func (c *ldapConn) Close() error {
+	c.Lock()
+	c.messageID++
+	c.conn.Write(berConstructed(berSequence, berInt(berInteger, c.messageID), berEncode(ldapUnbindRequest, nil)))
+	c.Unlock()
+	return c.conn.Close()
+}
+
+// ldapPool keeps idle connections bound with the service account of Pipeline for the searches
+type ldapPool struct {
+	dial  func() (*ldapConn, error)
+	conns chan *ldapConn
+}
+
+func newLDAPPool(size int, dial func() (*ldapConn, error)) *ldapPool {
+	return &ldapPool{dial: dial, conns: make(chan *ldapConn, size)}
+}
+
+// Get returns an idle connection or a new one if there is none
+func (pool *ldapPool) Get() (*ldapConn, error) {
+	select {
+	case conn := <-pool.conns:
+		return conn, nil
+	default:
+		return pool.dial()
+	}
+}
+
+// Put returns a connection to the pool, broken connections (err != nil) are closed
+func (pool *ldapPool) Put(conn *ldapConn, err error) {
+	if _, isResult := err.(ldapError); err != nil && !isResult {
+		conn.Close()
+		return
+	}
+	select {
+	case pool.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The messages below are in the form OpenLDAP sends and expects them, the responses of slapd use 4 byte lengths
+const (
+	// bind of cn=admin,dc=example,dc=org with the password secret, message ID 1
+	ldapCapturedBindRequest = "302c0201016027020103041a636e3d61646d696e2c64633d6578616d706c652c64633d6f72678006736563726574"
+	ldapCapturedBindSuccess = "3084000000100201016184000000070a010004000400"
+	// invalidCredentials (49) with the diagnostic message "invalid"
+	ldapCapturedBindInvalid = "30840000001702010161840000000e0a013104000407696e76616c6964"
+	// uid=jdoe,ou=people,dc=example,dc=org with uid: jdoe and cn: John Doe, jdoe, message ID 2
+	ldapCapturedSearchEntry = "3054020102644f04247569643d6a646f652c6f753d70656f706c652c64633d6578616d706c652c64633d6f72673027" +
+		"300d0403756964310604046a646f6530160402636e311004084a6f686e20446f6504046a646f65"
+	ldapCapturedSearchReference = "3031020102732c042a6c6461703a2f2f6f746865722e6578616d706c652e6f72672f64633d6578616d706c652c64633d6f7267"
+	ldapCapturedSearchDone      = "300c02010265070a010004000400"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Error during decoding %q: %s", s, err.Error())
+	}
+	return data
+}
+
+// ldapTestConn returns a connection to a server answering the requests with the responses in order
+// and sending the received requests to the channel
+func ldapTestConn(t *testing.T, responses ...string) (*ldapConn, <-chan []byte) {
+	client, server := net.Pipe()
+	requests := make(chan []byte, len(responses))
+	var messages [][]byte
+	for _, response := range responses {
+		messages = append(messages, decodeHex(t, response))
+	}
+	go func() {
+		defer server.Close()
+		reader := bufio.NewReader(server)
+		for _, message := range messages {
+			request, err := berRead(reader)
+			if err != nil {
+				return
+			}
+			requests <- berEncode(request.tag, request.content)
+			server.Write(message)
+		}
+	}()
+	return &ldapConn{conn: client, reader: bufio.NewReader(client)}, requests
+}
+
+func TestBEREncode(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		encoded  []byte
+		expected string
+	}{
+		{name: "short length", encoded: berString(berOctetString, "uid"), expected: "0403756964"},
+		{name: "long length", encoded: berString(berOctetString, strings.Repeat("a", 200))[:3], expected: "0481c8"},
+		{name: "two byte length", encoded: berString(berOctetString, strings.Repeat("a", 300))[:4], expected: "0482012c"},
+		{name: "zero", encoded: berInt(berInteger, 0), expected: "020100"},
+		{name: "positive sign", encoded: berInt(berInteger, 128), expected: "02020080"},
+		{name: "two byte integer", encoded: berInt(berInteger, 1000), expected: "020203e8"},
+		{name: "empty", encoded: berEncode(ldapUnbindRequest, nil), expected: "4200"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if encoded := hex.EncodeToString(tc.encoded); encoded != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, encoded)
+			}
+		})
+	}
+}
+
+func TestBERRead(t *testing.T) {
+
+	cases := []struct {
+		name            string
+		message         string
+		expectedTag     byte
+		expectedContent string
+		isError         bool
+	}{
+		{name: "short length", message: "0403756964", expectedTag: berOctetString, expectedContent: "756964"},
+		{name: "long form of a short length", message: "048400000003756964", expectedTag: berOctetString, expectedContent: "756964"},
+		{name: "indefinite length", message: "308004037569640000", isError: true},
+		{name: "too long length", message: "04850000000003756964", isError: true},
+		{name: "truncated content", message: "04037569", isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			element, err := berRead(bufio.NewReader(bytes.NewReader(decodeHex(t, tc.message))))
+			if tc.isError {
+				if err == nil {
+					t.Error("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during reading BER element: %s", err.Error())
+			}
+			if element.tag != tc.expectedTag {
+				t.Errorf("Expected %v, got: %v", tc.expectedTag, element.tag)
+			}
+			if content := hex.EncodeToString(element.content); content != tc.expectedContent {
+				t.Errorf("Expected %v, got: %v", tc.expectedContent, content)
+			}
+		})
+	}
+}
+
+func TestLDAPEncodeFilter(t *testing.T) {
+
+	cases := []struct {
+		filter   string
+		expected string
+		isError  bool
+	}{
+		{
+			filter:   `(&(objectClass=person)(uid=j\2adoe)(mail=*))`,
+			expected: "a02ba315040b6f626a656374436c6173730406706572736f6ea30c040375696404056a2a646f6587046d61696c",
+		},
+		{filter: "(|(uid=a)(uid=b))", expected: "a114a3080403756964040161a3080403756964040162"},
+		{filter: "(!(uid=a))", expected: "a20aa3080403756964040161"},
+		{filter: "uid=a", isError: true},
+		{filter: "(uid=a", isError: true},
+		{filter: "(!(uid=a)(uid=b))", isError: true},
+		{filter: "(&)", isError: true},
+		{filter: "(uid=a)(uid=b)", isError: true},
+		{filter: `(uid=\2)`, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.filter, func(t *testing.T) {
+			encoded, err := ldapEncodeFilter(tc.filter)
+			if tc.isError {
+				if err == nil {
+					t.Error("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during encoding filter: %s", err.Error())
+			}
+			if encoded := hex.EncodeToString(encoded); encoded != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, encoded)
+			}
+		})
+	}
+}
+
+func TestLDAPEscapeFilterValue(t *testing.T) {
+
+	value := "a*(b)\\c\x00"
+	escaped := ldapEscapeFilterValue(value)
+	if expected := `a\2a\28b\29\5cc\00`; escaped != expected {
+		t.Errorf("Expected %v, got: %v", expected, escaped)
+	}
+	if unescaped, err := ldapUnescapeFilterValue(escaped); err != nil || unescaped != value {
+		t.Errorf("Expected %q, got: %q %v", value, unescaped, err)
+	}
+}
+
+func TestLDAPBind(t *testing.T) {
+
+	conn, requests := ldapTestConn(t, ldapCapturedBindSuccess)
+	defer conn.conn.Close()
+	if err := conn.Bind("cn=admin,dc=example,dc=org", "secret"); err != nil {
+		t.Fatalf("Error during binding: %s", err.Error())
+	}
+	if request := hex.EncodeToString(<-requests); request != ldapCapturedBindRequest {
+		t.Errorf("Expected %v, got: %v", ldapCapturedBindRequest, request)
+	}
+
+	conn, _ = ldapTestConn(t, ldapCapturedBindInvalid)
+	defer conn.conn.Close()
+	err := conn.Bind("cn=admin,dc=example,dc=org", "wrong")
+	if expected := (ldapError{Code: ldapResultInvalidCredentials, Message: "invalid"}); err != expected {
+		t.Errorf("Expected %v, got: %v", expected, err)
+	}
+
+	// an empty password would be an unauthenticated bind, it's not sent
+	if err := conn.Bind("cn=admin,dc=example,dc=org", ""); err == nil {
+		t.Error("Expected error, but not got error!")
+	}
+}
+
+func TestLDAPSearch(t *testing.T) {
+
+	conn, requests := ldapTestConn(t,
+		ldapCapturedBindSuccess,
+		ldapCapturedSearchEntry+ldapCapturedSearchReference+ldapCapturedSearchDone,
+	)
+	defer conn.conn.Close()
+	if err := conn.Bind("cn=admin,dc=example,dc=org", "secret"); err != nil {
+		t.Fatalf("Error during binding: %s", err.Error())
+	}
+	<-requests
+
+	entries, err := conn.Search("dc=example,dc=org", "(uid=jdoe)", []string{"uid", "cn"}, 2)
+	if err != nil {
+		t.Fatalf("Error during searching: %s", err.Error())
+	}
+	expected := []*ldapEntry{{
+		DN:         "uid=jdoe,ou=people,dc=example,dc=org",
+		Attributes: map[string][]string{"uid": {"jdoe"}, "cn": {"John Doe", "jdoe"}},
+	}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got: %v", expected[0], entries)
+	}
+	if cn := entries[0].Get("CN"); cn != "John Doe" {
+		t.Errorf("Expected %v, got: %v", "John Doe", cn)
+	}
+
+	// message ID 2, the search request of the base DN, subtree scope, size limit 2 and the attributes
+	expectedRequest := "303f020102" + "633a" + "0411" + hex.EncodeToString([]byte("dc=example,dc=org")) +
+		"0a0102" + "0a0100" + "020102" + "020100" + "010100" +
+		"a30b04037569640404" + hex.EncodeToString([]byte("jdoe")) +
+		"300904037569640402636e"
+	if request := hex.EncodeToString(<-requests); request != expectedRequest {
+		t.Errorf("Expected %v, got: %v", expectedRequest, request)
+	}
+}
+
+func TestLDAPUnexpectedMessageID(t *testing.T) {
+
+	// the response of message ID 2 to the request of message ID 1
+	conn, _ := ldapTestConn(t, ldapCapturedSearchDone)
+	defer conn.conn.Close()
+	if err := conn.Bind("cn=admin,dc=example,dc=org", "secret"); err == nil {
+		t.Error("Expected error, but not got error!")
+	}
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// In-memory implementation
+
+// InMemoryTokenStoreOptions configures the in-memory TokenStore
+type InMemoryTokenStoreOptions struct {
+	// MaxEntries caps the number of stored tokens, the least recently used token
+	// is evicted when it's reached. Zero means no limit.
+	MaxEntries int
+	// SweepInterval is the period of removing expired tokens in the background.
+	// Zero means expired tokens are only removed lazily on lookup.
+	SweepInterval time.Duration
+}
+
+// NewInMemoryTokenStore is a basic in-memory TokenStore implementation (thread-safe)
+func NewInMemoryTokenStore() TokenStore {
+	return NewInMemoryTokenStoreWithOptions(InMemoryTokenStoreOptions{})
+}
+
+// NewInMemoryTokenStoreWithOptions is an in-memory TokenStore with optional LRU eviction and expiry sweeping
+func NewInMemoryTokenStoreWithOptions(options InMemoryTokenStoreOptions) TokenStore {
+	tokenStore := &inMemoryTokenStore{
+		store:      make(map[string]map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: options.MaxEntries,
+	}
+	if options.SweepInterval > 0 {
+		go tokenStore.sweep(options.SweepInterval)
+	}
+	return tokenStore
+}
+
+type inMemoryTokenEntry struct {
+	userId string
+	token  *Token
+}
+
+type inMemoryTokenStore struct {
+	sync.RWMutex
+	store map[string]map[string]*list.Element
+	// lru holds *inMemoryTokenEntry values, the most recently used at the front
+	lru        *list.List
+	maxEntries int
+}
+
+func (tokenStore *inMemoryTokenStore) get(userId, tokenId string) (*list.Element, bool) {
+	if userTokens, ok := tokenStore.store[userId]; ok {
+		element, found := userTokens[tokenId]
+		return element, found
+	}
+	return nil, false
+}
+
+func (tokenStore *inMemoryTokenStore) add(userId string, token *Token) {
+	if element, found := tokenStore.get(userId, token.ID); found {
+		element.Value.(*inMemoryTokenEntry).token = token
+		tokenStore.lru.MoveToFront(element)
+		return
+	}
+	userTokens, ok := tokenStore.store[userId]
+	if !ok {
+		userTokens = make(map[string]*list.Element)
+		tokenStore.store[userId] = userTokens
+	}
+	userTokens[token.ID] = tokenStore.lru.PushFront(&inMemoryTokenEntry{userId: userId, token: token})
+
+	for tokenStore.maxEntries > 0 && tokenStore.lru.Len() > tokenStore.maxEntries {
+		tokenStore.remove(tokenStore.lru.Back())
+		inMemoryTokenEvictions.WithLabelValues("capacity").Inc()
+	}
+}
+
+func (tokenStore *inMemoryTokenStore) remove(element *list.Element) {
+	entry := tokenStore.lru.Remove(element).(*inMemoryTokenEntry)
+	userTokens := tokenStore.store[entry.userId]
+	delete(userTokens, entry.token.ID)
+	if len(userTokens) == 0 {
+		delete(tokenStore.store, entry.userId)
+	}
+}
+
+func (tokenStore *inMemoryTokenStore) userTokens(userId string) []*Token {
+	userTokens, ok := tokenStore.store[userId]
+	if !ok {
+		return nil
+	}
+	tokens := make([]*Token, 0, len(userTokens))
+	for _, element := range userTokens {
+		tokens = append(tokens, copyToken(element.Value.(*inMemoryTokenEntry).token))
+	}
+	sortTokens(tokens)
+	return tokens
+}
+
+// sweep removes the expired tokens periodically, it never returns
+func (tokenStore *inMemoryTokenStore) sweep(interval time.Duration) {
+	for range time.Tick(interval) {
+		tokenStore.Lock()
+		for element := tokenStore.lru.Front(); element != nil; {
+			next := element.Next()
+			if element.Value.(*inMemoryTokenEntry).token.IsExpired() {
+				tokenStore.remove(element)
+				inMemoryTokenEvictions.WithLabelValues("expired").Inc()
+			}
+			element = next
+		}
+		tokenStore.Unlock()
+	}
+}
+
+func (tokenStore *inMemoryTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	tokenStore.add(userId, copyToken(token))
+	return nil
+}
+
+func (tokenStore *inMemoryTokenStore) Lookup(ctx context.Context, userId, tokenId string) (*Token, error) {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	if element, found := tokenStore.get(userId, tokenId); found {
+		token := element.Value.(*inMemoryTokenEntry).token
+		if token.IsExpired() {
+			tokenStore.remove(element)
+			return nil, ErrTokenExpired
+		}
+		tokenStore.lru.MoveToFront(element)
+		return copyToken(token), nil
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (tokenStore *inMemoryTokenStore) Revoke(ctx context.Context, userId, tokenId string) error {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	if element, found := tokenStore.get(userId, tokenId); found {
+		tokenStore.remove(element)
+	}
+	return nil
+}
+
+func (tokenStore *inMemoryTokenStore) RevokeAll(ctx context.Context, userId string) error {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	for _, element := range tokenStore.store[userId] {
+		tokenStore.remove(element)
+	}
+	return nil
+}
+
+func (tokenStore *inMemoryTokenStore) List(ctx context.Context, userId string) ([]*Token, error) {
+	tokenStore.RLock()
+	defer tokenStore.RUnlock()
+	return tokenStore.userTokens(userId), nil
+}
+
+func (tokenStore *inMemoryTokenStore) ListAll(ctx context.Context) (map[string][]*Token, error) {
+	tokenStore.RLock()
+	defer tokenStore.RUnlock()
+	allTokens := make(map[string][]*Token, len(tokenStore.store))
+	for userId := range tokenStore.store {
+		allTokens[userId] = tokenStore.userTokens(userId)
+	}
+	return allTokens, nil
+}
+
+func (tokenStore *inMemoryTokenStore) ListUsers(ctx context.Context) ([]string, error) {
+	tokenStore.RLock()
+	defer tokenStore.RUnlock()
+	userIds := make([]string, 0, len(tokenStore.store))
+	for userId := range tokenStore.store {
+		userIds = append(userIds, userId)
+	}
+	sort.Strings(userIds)
+	return userIds, nil
+}
+
+func (tokenStore *inMemoryTokenStore) Touch(ctx context.Context, userId, tokenId, ip string) error {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	if element, found := tokenStore.get(userId, tokenId); found {
+		token := element.Value.(*inMemoryTokenEntry).token
+		now := time.Now()
+		token.LastUsedAt = &now
+		token.LastUsedIP = ip
+		tokenStore.lru.MoveToFront(element)
+	}
+	return nil
+}
+
+func (tokenStore *inMemoryTokenStore) Rotate(ctx context.Context, userId, tokenId, newTokenId string) (*Token, error) {
+	tokenStore.Lock()
+	defer tokenStore.Unlock()
+	element, found := tokenStore.get(userId, tokenId)
+	if !found {
+		return nil, ErrTokenNotFound
+	}
+	old := element.Value.(*inMemoryTokenEntry).token
+	tokenStore.remove(element)
+	if old.IsExpired() {
+		return nil, ErrTokenExpired
+	}
+	token := rotatedToken(old, newTokenId)
+	tokenStore.add(userId, copyToken(token))
+	return token, nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var inMemoryTokenEvictions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "pipeline",
+		Subsystem: "tokenstore",
+		Name:      "memory_evictions_total",
+		Help:      "Number of tokens evicted from the in-memory token store by reason (capacity, expired).",
+	},
+	[]string{"reason"},
+)
+
+var tokenStoreOperations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "pipeline",
+		Subsystem: "tokenstore",
+		Name:      "operations_total",
+		Help:      "Number of token store operations by backend, operation and result (success, error).",
+	},
+	[]string{"backend", "operation", "result"},
+)
+
+var tokenStoreOperationDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "pipeline",
+		Subsystem: "tokenstore",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of token store operations by backend and operation.",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"backend", "operation"},
+)
+
+func init() {
+	prometheus.MustRegister(inMemoryTokenEvictions)
+	prometheus.MustRegister(tokenStoreOperations)
+	prometheus.MustRegister(tokenStoreOperationDuration)
+}
+
+// A TokenStore wrapper which records the count, result and latency of the operations,
+// NewTokenStore wraps every driver with it using the driver name as the backend label
+type instrumentedTokenStore struct {
+	tokenStore TokenStore
+	backend    string
+}
+
+func newInstrumentedTokenStore(backend string, tokenStore TokenStore) TokenStore {
+	return instrumentedTokenStore{tokenStore: tokenStore, backend: backend}
+}
+
+// observe records an operation started at start, unknown and expired tokens
+// are regular outcomes of a lookup, so they are not counted as errors
+func (tokenStore instrumentedTokenStore) observe(operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil && err != ErrTokenNotFound && err != ErrTokenExpired {
+		result = "error"
+	}
+	tokenStoreOperations.WithLabelValues(tokenStore.backend, operation, result).Inc()
+	tokenStoreOperationDuration.WithLabelValues(tokenStore.backend, operation).Observe(time.Since(start).Seconds())
+}
+
+func (tokenStore instrumentedTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	start := time.Now()
+	err := tokenStore.tokenStore.Store(ctx, userId, token)
+	tokenStore.observe("store", start, err)
+	return err
+}
+
+func (tokenStore instrumentedTokenStore) Lookup(ctx context.Context, userId, tokenId string) (*Token, error) {
+	start := time.Now()
+	token, err := tokenStore.tokenStore.Lookup(ctx, userId, tokenId)
+	tokenStore.observe("lookup", start, err)
+	return token, err
+}
+
+func (tokenStore instrumentedTokenStore) Revoke(ctx context.Context, userId, tokenId string) error {
+	start := time.Now()
+	err := tokenStore.tokenStore.Revoke(ctx, userId, tokenId)
+	tokenStore.observe("revoke", start, err)
+	return err
+}
+
+func (tokenStore instrumentedTokenStore) RevokeAll(ctx context.Context, userId string) error {
+	start := time.Now()
+	err := tokenStore.tokenStore.RevokeAll(ctx, userId)
+	tokenStore.observe("revoke_all", start, err)
+	return err
+}
+
+func (tokenStore instrumentedTokenStore) List(ctx context.Context, userId string) ([]*Token, error) {
+	start := time.Now()
+	tokens, err := tokenStore.tokenStore.List(ctx, userId)
+	tokenStore.observe("list", start, err)
+	return tokens, err
+}
+
+func (tokenStore instrumentedTokenStore) ListAll(ctx context.Context) (map[string][]*Token, error) {
+	start := time.Now()
+	allTokens, err := tokenStore.tokenStore.ListAll(ctx)
+	tokenStore.observe("list_all", start, err)
+	return allTokens, err
+}
+
+func (tokenStore instrumentedTokenStore) ListUsers(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	userIds, err := tokenStore.tokenStore.ListUsers(ctx)
+	tokenStore.observe("list_users", start, err)
+	return userIds, err
+}
+
+func (tokenStore instrumentedTokenStore) Touch(ctx context.Context, userId, tokenId, ip string) error {
+	start := time.Now()
+	err := tokenStore.tokenStore.Touch(ctx, userId, tokenId, ip)
+	tokenStore.observe("touch", start, err)
+	return err
+}
+
+func (tokenStore instrumentedTokenStore) Rotate(ctx context.Context, userId, tokenId, newTokenId string) (*Token, error) {
+	start := time.Now()
+	token, err := tokenStore.tokenStore.Rotate(ctx, userId, tokenId, newTokenId)
+	tokenStore.observe("rotate", start, err)
+	return token, err
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+	"golang.org/x/oauth2"
+)
+
+// OAuthExtraInfo struct for the credentials of the OAuth login providers besides GitHub,
+// the tokens are passed to Drone to access the repositories of the user
+type OAuthExtraInfo struct {
+	Login        string
+	Token        string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// OAuthUser is a user of a source code hosting service
+type OAuthUser struct {
+	ID    string
+	Login string
+	Name  string
+	Email string
+	Image string
+}
+
+// OAuthUserSource is a source code hosting service users can log in with using OAuth2
+type OAuthUserSource interface {
+	// GetName is the name of the provider in the login URLs, eg.: /auth/gitlab/login
+	GetName() string
+	Endpoint() oauth2.Endpoint
+	// Scopes are the permissions requested from the user, Drone needs access to the repositories and their hooks
+	Scopes() []string
+	// FetchUser returns the logged in user, the client sends the access token of the user
+	FetchUser(client *http.Client) (*OAuthUser, error)
+}
+
+// OAuthProvider provides login with an OAuthUserSource using the authorization code flow
+type OAuthProvider struct {
+	source       OAuthUserSource
+	clientID     string
+	clientSecret string
+}
+
+// NewOAuthProvider creates an OAuthProvider of the source with the client credentials of Pipeline
+func NewOAuthProvider(source OAuthUserSource, clientID, clientSecret string) (*OAuthProvider, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("%s client ID and secret can't be blank", source.GetName())
+	}
+	return &OAuthProvider{source: source, clientID: clientID, clientSecret: clientSecret}, nil
+}
+
+// GetName return provider name
+func (provider *OAuthProvider) GetName() string {
+	return provider.source.GetName()
+}
+
+// ConfigAuth config auth
+func (provider *OAuthProvider) ConfigAuth(*auth.Auth) {
+}
+
+// OAuthConfig return oauth config based on configuration
+func (provider *OAuthProvider) OAuthConfig(context *auth.Context) *oauth2.Config {
+	var (
+		req    = context.Request
+		scheme = req.URL.Scheme
+	)
+
+	if scheme == "" {
+		scheme = "http://"
+	}
+
+	return &oauth2.Config{
+		ClientID:     provider.clientID,
+		ClientSecret: provider.clientSecret,
+		Endpoint:     provider.source.Endpoint(),
+		RedirectURL:  scheme + req.Host + context.Auth.AuthURL(provider.GetName()+"/callback"),
+		Scopes:       provider.source.Scopes(),
+	}
+}
+
+// Login implemented login with the OAuth provider
+func (provider *OAuthProvider) Login(context *auth.Context) {
+	claims := claims.Claims{}
+	claims.Subject = "state"
+	signedToken := context.Auth.SessionStorer.SignedToken(&claims)
+
+	url := provider.OAuthConfig(context).AuthCodeURL(signedToken)
+	http.Redirect(context.Writer, context.Request, url, http.StatusFound)
+}
+
+// Logout implemented logout with the OAuth provider
+func (*OAuthProvider) Logout(context *auth.Context) {
+}
+
+// Register implemented register with the OAuth provider
+func (provider *OAuthProvider) Register(context *auth.Context) {
+	provider.Login(context)
+}
+
+// Callback implement Callback with the OAuth provider
+func (provider *OAuthProvider) Callback(context *auth.Context) {
+	context.Auth.LoginHandler(context, provider.authorize)
+}
+
+// ServeHTTP implement ServeHTTP with the OAuth provider
+func (*OAuthProvider) ServeHTTP(*auth.Context) {
+}
+
+func (provider *OAuthProvider) authorize(context *auth.Context) (*claims.Claims, error) {
+	var (
+		schema       auth.Schema
+		authInfo     auth_identity.Basic
+		authIdentity = reflect.New(utils.ModelType(context.Auth.Config.AuthIdentityModel)).Interface()
+		req          = context.Request
+		tx           = context.Auth.GetDB(req)
+	)
+
+	state := req.URL.Query().Get("state")
+	stateClaims, err := context.Auth.SessionStorer.ValidateClaims(state)
+	if err != nil || stateClaims.Valid() != nil || stateClaims.Subject != "state" {
+		log.Info(context.Request.RemoteAddr, auth.ErrUnauthorized.Error())
+		return nil, auth.ErrUnauthorized
+	}
+
+	oauthCfg := provider.OAuthConfig(context)
+	tkn, err := oauthCfg.Exchange(oauth2.NoContext, req.URL.Query().Get("code"))
+	if err != nil {
+		log.Info(context.Request.RemoteAddr, err.Error())
+		return nil, err
+	}
+
+	user, err := provider.source.FetchUser(oauthCfg.Client(oauth2.NoContext, tkn))
+	if err != nil {
+		log.Info(context.Request.RemoteAddr, err.Error())
+		return nil, err
+	}
+
+	authInfo.Provider = provider.GetName()
+	authInfo.UID = user.ID
+
+	if !tx.Model(authIdentity).Where(authInfo).Scan(&authInfo).RecordNotFound() {
+		return authInfo.ToClaims(), nil
+	}
+
+	{
+		schema.Provider = provider.GetName()
+		schema.UID = user.ID
+		schema.Name = user.Name
+		schema.Email = user.Email
+		schema.Image = user.Image
+		schema.RawInfo = &OAuthExtraInfo{Login: user.Login, Token: tkn.AccessToken, RefreshToken: tkn.RefreshToken, Expiry: tkn.Expiry}
+	}
+	if _, userID, err := context.Auth.UserStorer.Save(&schema, context); err == nil {
+		if userID != "" {
+			authInfo.UserID = userID
+		}
+	} else {
+		return nil, err
+	}
+
+	if err = tx.Where(authInfo).FirstOrCreate(authIdentity).Error; err == nil {
+		return authInfo.ToClaims(), nil
+	}
+
+	log.Info(context.Request.RemoteAddr, err.Error())
+	return nil, err
+}
+
+// getOAuthJSON fetches a JSON document of an OAuth provider's API
+func getOAuthJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+	"golang.org/x/oauth2"
+)
+
+// OIDCExtraInfo struct for OpenID Connect user info
+type OIDCExtraInfo struct {
+	Login  string
+	Groups []string
+}
+
+// OIDCConfig is the configuration of an OpenID Connect provider (Okta, Keycloak, Azure AD, Dex, ...)
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested in addition to "openid"
+	Scopes []string
+
+	// Claims of the ID token mapped to the Pipeline user
+	UserClaim   string // unique ID of the user, "sub" by default
+	LoginClaim  string // "preferred_username" by default, the email is used if it's missing
+	NameClaim   string // "name" by default
+	EmailClaim  string // "email" by default
+	GroupsClaim string // "groups" by default, the user joins the organizations named after the groups
+}
+
+// oidcDiscovery is the relevant part of the /.well-known/openid-configuration document
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider provides login with an OpenID Connect provider using the authorization code flow
+type OIDCProvider struct {
+	*OIDCConfig
+	discovery oidcDiscovery
+	client    *http.Client
+
+	sync.RWMutex
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+// oidcKeysMinRefreshInterval limits the JWKS fetches caused by ID tokens with unknown key IDs
+const oidcKeysMinRefreshInterval = time.Minute
+
+// NewOIDCProvider creates an OIDCProvider, the provider endpoints are discovered from the issuer URL
+func NewOIDCProvider(config *OIDCConfig) (*OIDCProvider, error) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, errors.New("OIDC client ID and secret can't be blank")
+	}
+	defaults := map[*string]string{
+		&config.UserClaim:   "sub",
+		&config.LoginClaim:  "preferred_username",
+		&config.NameClaim:   "name",
+		&config.EmailClaim:  "email",
+		&config.GroupsClaim: "groups",
+	}
+	for claim, value := range defaults {
+		if *claim == "" {
+			*claim = value
+		}
+	}
+
+	provider := &OIDCProvider{OIDCConfig: config, client: &http.Client{Timeout: 10 * time.Second}}
+	discoveryURL := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := provider.getJSON(discoveryURL, &provider.discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %s", err)
+	}
+	if provider.discovery.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("OIDC issuer mismatch: %q != %q", provider.discovery.Issuer, config.IssuerURL)
+	}
+	if err := provider.fetchKeys(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func (provider *OIDCProvider) getJSON(url string, v interface{}) error {
+	resp, err := provider.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetchKeys loads the RSA signing keys of the provider from its JWKS endpoint
+func (provider *OIDCProvider) fetchKeys() error {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := provider.getJSON(provider.discovery.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("failed to fetch OIDC signing keys: %s", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return fmt.Errorf("invalid OIDC signing key %q: %s", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return fmt.Errorf("invalid OIDC signing key %q: %s", key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	provider.Lock()
+	defer provider.Unlock()
+	provider.keys = keys
+	provider.keysFetchedAt = time.Now()
+	return nil
+}
+
+func (provider *OIDCProvider) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	provider.RLock()
+	key, ok := provider.keys[kid]
+	stale := time.Since(provider.keysFetchedAt) > oidcKeysMinRefreshInterval
+	provider.RUnlock()
+	if !ok && stale {
+		// The provider may have rotated its keys
+		if err := provider.fetchKeys(); err != nil {
+			return nil, err
+		}
+		provider.RLock()
+		key, ok = provider.keys[kid]
+		provider.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown OIDC signing key: %q", kid)
+	}
+	return key, nil
+}
+
+// verifyIDToken validates the signature, issuer, audience, expiry and nonce of an ID token
+func (provider *OIDCProvider) verifyIDToken(rawIDToken, nonce string) (jwt.MapClaims, error) {
+	idTokenClaims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(rawIDToken, idTokenClaims, provider.keyFunc); err != nil {
+		return nil, err
+	}
+	if !idTokenClaims.VerifyIssuer(provider.discovery.Issuer, true) {
+		return nil, errors.New("invalid ID token issuer")
+	}
+	// The audience is either a string or an array of strings
+	audienceValid := idTokenClaims.VerifyAudience(provider.ClientID, true)
+	if audiences, ok := idTokenClaims["aud"].([]interface{}); ok {
+		for _, audience := range audiences {
+			audienceValid = audienceValid || audience == provider.ClientID
+		}
+	}
+	if !audienceValid {
+		return nil, errors.New("invalid ID token audience")
+	}
+	if idTokenClaims["nonce"] != nonce {
+		return nil, errors.New("invalid ID token nonce")
+	}
+	return idTokenClaims, nil
+}
+
+// stringClaim returns a string claim of the ID token or an empty string
+func stringClaim(idTokenClaims jwt.MapClaims, name string) string {
+	value, _ := idTokenClaims[name].(string)
+	return value
+}
+
+// groupsClaim returns the groups of the user, the claim is either an array or a space separated string
+func groupsClaim(idTokenClaims jwt.MapClaims, name string) []string {
+	switch groups := idTokenClaims[name].(type) {
+	case []interface{}:
+		result := make([]string, 0, len(groups))
+		for _, group := range groups {
+			if group, ok := group.(string); ok {
+				result = append(result, group)
+			}
+		}
+		return result
+	case string:
+		return strings.Fields(groups)
+	}
+	return nil
+}
+
+// GetName return provider name
+func (*OIDCProvider) GetName() string {
+	return "oidc"
+}
+
+// ConfigAuth config auth
+func (provider *OIDCProvider) ConfigAuth(*auth.Auth) {
+}
+
+// OAuthConfig return oauth config based on configuration
+func (provider *OIDCProvider) OAuthConfig(context *auth.Context) *oauth2.Config {
+	var (
+		req    = context.Request
+		scheme = req.URL.Scheme
+	)
+
+	if scheme == "" {
+		scheme = "http://"
+	}
+
+	return &oauth2.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.discovery.AuthorizationEndpoint,
+			TokenURL: provider.discovery.TokenEndpoint,
+		},
+		RedirectURL: scheme + req.Host + context.Auth.AuthURL("oidc/callback"),
+		Scopes:      append([]string{"openid"}, provider.Scopes...),
+	}
+}
+
+// Login implemented login with the OIDC provider, the signed state is the nonce of the ID token as well
+func (provider *OIDCProvider) Login(context *auth.Context) {
+	claims := claims.Claims{}
+	claims.Subject = "state"
+	signedToken := context.Auth.SessionStorer.SignedToken(&claims)
+
+	url := provider.OAuthConfig(context).AuthCodeURL(signedToken, oauth2.SetAuthURLParam("nonce", signedToken))
+	http.Redirect(context.Writer, context.Request, url, http.StatusFound)
+}
+
+// Logout implemented logout with the OIDC provider
+func (*OIDCProvider) Logout(context *auth.Context) {
+}
+
+// Register implemented register with the OIDC provider
+func (provider *OIDCProvider) Register(context *auth.Context) {
+	provider.Login(context)
+}
+
+// Callback implement Callback with the OIDC provider
+func (provider *OIDCProvider) Callback(context *auth.Context) {
+	context.Auth.LoginHandler(context, provider.authorize)
+}
+
+// ServeHTTP implement ServeHTTP with the OIDC provider
+func (*OIDCProvider) ServeHTTP(*auth.Context) {
+}
+
+func (provider *OIDCProvider) authorize(context *auth.Context) (*claims.Claims, error) {
+	var (
+		schema       auth.Schema
+		authInfo     auth_identity.Basic
+		authIdentity = reflect.New(utils.ModelType(context.Auth.Config.AuthIdentityModel)).Interface()
+		req          = context.Request
+		tx           = context.Auth.GetDB(req)
+	)
+
+	state := req.URL.Query().Get("state")
+	stateClaims, err := context.Auth.SessionStorer.ValidateClaims(state)
+	if err != nil || stateClaims.Valid() != nil || stateClaims.Subject != "state" {
+		log.Info(context.Request.RemoteAddr, auth.ErrUnauthorized.Error())
+		return nil, auth.ErrUnauthorized
+	}
+
+	tkn, err := provider.OAuthConfig(context).Exchange(oauth2.NoContext, req.URL.Query().Get("code"))
+	if err != nil {
+		log.Info(context.Request.RemoteAddr, err.Error())
+		return nil, err
+	}
+	rawIDToken, ok := tkn.Extra("id_token").(string)
+	if !ok {
+		log.Info(context.Request.RemoteAddr, "no ID token in the token response")
+		return nil, auth.ErrUnauthorized
+	}
+	idTokenClaims, err := provider.verifyIDToken(rawIDToken, state)
+	if err != nil {
+		log.Info(context.Request.RemoteAddr, err.Error())
+		return nil, auth.ErrUnauthorized
+	}
+
+	uid := stringClaim(idTokenClaims, provider.UserClaim)
+	if uid == "" {
+		log.Info(context.Request.RemoteAddr, fmt.Sprintf("missing %q claim in the ID token", provider.UserClaim))
+		return nil, auth.ErrUnauthorized
+	}
+	login := stringClaim(idTokenClaims, provider.LoginClaim)
+	if login == "" {
+		login = stringClaim(idTokenClaims, provider.EmailClaim)
+	}
+	groups := groupsClaim(idTokenClaims, provider.GroupsClaim)
+
+	authInfo.Provider = provider.GetName()
+	authInfo.UID = uid
+
+	if !tx.Model(authIdentity).Where(authInfo).Scan(&authInfo).RecordNotFound() {
+		// Group memberships may have changed since the last login
+		if err := joinGroupOrganizations(tx, authInfo.UserID, groups); err != nil {
+			log.Info(context.Request.RemoteAddr, err.Error())
+			return nil, err
+		}
+		return authInfo.ToClaims(), nil
+	}
+
+	{
+		schema.Provider = provider.GetName()
+		schema.UID = uid
+		schema.Name = stringClaim(idTokenClaims, provider.NameClaim)
+		schema.Email = stringClaim(idTokenClaims, provider.EmailClaim)
+		schema.Image = stringClaim(idTokenClaims, "picture")
+		schema.RawInfo = &OIDCExtraInfo{Login: login, Groups: groups}
+	}
+	if _, userID, err := context.Auth.UserStorer.Save(&schema, context); err == nil {
+		if userID != "" {
+			authInfo.UserID = userID
+		}
+	} else {
+		return nil, err
+	}
+
+	if err = tx.Where(authInfo).FirstOrCreate(authIdentity).Error; err == nil {
+		return authInfo.ToClaims(), nil
+	}
+
+	log.Info(context.Request.RemoteAddr, err.Error())
+	return nil, err
+}
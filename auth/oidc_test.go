@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	oidcClientID = "pipeline"
+	oidcNonce    = "nonce"
+)
+
+// newTestOIDCProvider creates an OIDCProvider of a test issuer signing with the key
+func newTestOIDCProvider(t *testing.T, key *rsa.PrivateKey) (*OIDCProvider, func()) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{Issuer: server.URL, JWKSURI: server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "current",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	provider, err := NewOIDCProvider(&OIDCConfig{IssuerURL: server.URL, ClientID: oidcClientID, ClientSecret: "secret"})
+	if err != nil {
+		server.Close()
+		t.Fatalf("Error during creating OIDC provider: %s", err.Error())
+	}
+	return provider, server.Close
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error during generating key: %s", err.Error())
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error during generating key: %s", err.Error())
+	}
+	provider, tearDown := newTestOIDCProvider(t, key)
+	defer tearDown()
+	// the unknown key IDs don't refetch the keys in the test
+	provider.keysFetchedAt = time.Now()
+
+	idToken := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss":   provider.discovery.Issuer,
+			"sub":   "user",
+			"aud":   oidcClientID,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": oidcNonce,
+		}
+		if change != nil {
+			change(claims)
+		}
+		return claims
+	}
+
+	cases := []struct {
+		name    string
+		method  jwt.SigningMethod
+		kid     string
+		key     interface{}
+		claims  jwt.MapClaims
+		isError bool
+	}{
+		{name: "valid", claims: idToken(nil)},
+		{name: "audiences", claims: idToken(func(claims jwt.MapClaims) { claims["aud"] = []string{"other", oidcClientID} })},
+		{name: "wrong issuer", claims: idToken(func(claims jwt.MapClaims) { claims["iss"] = "https://issuer.example.com" }), isError: true},
+		{name: "wrong audience", claims: idToken(func(claims jwt.MapClaims) { claims["aud"] = "other" }), isError: true},
+		{name: "wrong audiences", claims: idToken(func(claims jwt.MapClaims) { claims["aud"] = []string{"other"} }), isError: true},
+		{name: "expired", claims: idToken(func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }), isError: true},
+		{name: "wrong nonce", claims: idToken(func(claims jwt.MapClaims) { claims["nonce"] = "other" }), isError: true},
+		{name: "bad signature", key: otherKey, claims: idToken(nil), isError: true},
+		{name: "unknown key", kid: "other", claims: idToken(nil), isError: true},
+		{name: "hmac signature", method: jwt.SigningMethodHS256, key: []byte("secret"), claims: idToken(nil), isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method, kid, signingKey := tc.method, tc.kid, tc.key
+			if method == nil {
+				method = jwt.SigningMethodRS256
+			}
+			if kid == "" {
+				kid = "current"
+			}
+			if signingKey == nil {
+				signingKey = key
+			}
+			token := jwt.NewWithClaims(method, tc.claims)
+			token.Header["kid"] = kid
+			rawIDToken, err := token.SignedString(signingKey)
+			if err != nil {
+				t.Fatalf("Error during signing ID token: %s", err.Error())
+			}
+
+			claims, err := provider.verifyIDToken(rawIDToken, oidcNonce)
+			if tc.isError {
+				if err == nil {
+					t.Error("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during verifying ID token: %s", err.Error())
+			}
+			if subject := stringClaim(claims, "sub"); subject != "user" {
+				t.Errorf("Expected %v, got: %v", "user", subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// Actions which can be restricted by policies
+const (
+	PolicyActionAll              = "*"
+	PolicyActionClusterCreate    = "cluster:create"
+	PolicyActionClusterUpdate    = "cluster:update"
+	PolicyActionClusterDelete    = "cluster:delete"
+	PolicyActionClusterExec      = "cluster:exec"
+	PolicyActionDeploymentCreate = "deployment:create"
+	PolicyActionDeploymentUpdate = "deployment:update"
+	PolicyActionDeploymentDelete = "deployment:delete"
+)
+
+var policyActions = []string{
+	PolicyActionAll,
+	PolicyActionClusterCreate,
+	PolicyActionClusterUpdate,
+	PolicyActionClusterDelete,
+	PolicyActionClusterExec,
+	PolicyActionDeploymentCreate,
+	PolicyActionDeploymentUpdate,
+	PolicyActionDeploymentDelete,
+}
+
+// Attributes of the requests which can be matched in the policy conditions
+const (
+	PolicyAttributeCloud            = "cloud"
+	PolicyAttributeLocation         = "location"
+	PolicyAttributeNodeInstanceType = "nodeInstanceType"
+	PolicyAttributeCluster          = "cluster"
+	PolicyAttributeChart            = "chart"
+	PolicyAttributeNamespace        = "namespace"
+)
+
+// Effects of the policies
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// PolicyConditions maps request attributes to glob patterns (eg.: "location": "eu-west-*"),
+// a policy applies to a request only if all of its conditions match
+type PolicyConditions map[string]string
+
+// Value implements driver.Valuer, the conditions are stored as JSON
+func (conditions PolicyConditions) Value() (driver.Value, error) {
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	value, err := json.Marshal(conditions)
+	return string(value), err
+}
+
+// Scan implements sql.Scanner
+func (conditions *PolicyConditions) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported policy conditions type: %T", value)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, conditions)
+}
+
+// Policy restricts the actions of the members of an organization beyond their role,
+// eg.: a team can create clusters only on Amazon in eu-west-1.
+// Deny policies take precedence; if there are allow policies for an action,
+// requests have to match one of them.
+type Policy struct {
+	ID             uint             `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+	OrganizationID uint             `gorm:"not null;index" json:"organizationId"`
+	TeamID         uint             `json:"teamId,omitempty"` // 0 means every member of the organization
+	Effect         string           `gorm:"not null" json:"effect"`
+	Action         string           `gorm:"not null" json:"action"`
+	Conditions     PolicyConditions `gorm:"type:text" json:"conditions,omitempty"`
+	Description    string           `json:"description,omitempty"`
+}
+
+// TableName sets Policy's table name
+func (Policy) TableName() string {
+	return "policies"
+}
+
+// Validate checks the effect, the action and the condition patterns of the policy
+func (policy *Policy) Validate() error {
+	if policy.Effect != PolicyEffectAllow && policy.Effect != PolicyEffectDeny {
+		return fmt.Errorf("invalid policy effect: %q", policy.Effect)
+	}
+	validAction := false
+	for _, action := range policyActions {
+		validAction = validAction || action == policy.Action
+	}
+	if !validAction {
+		return fmt.Errorf("invalid policy action: %q", policy.Action)
+	}
+	for attribute, pattern := range policy.Conditions {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern of %q: %q", attribute, pattern)
+		}
+	}
+	return nil
+}
+
+func (policy *Policy) appliesTo(action string) bool {
+	return policy.Action == PolicyActionAll || policy.Action == action
+}
+
+func (policy *Policy) matches(attributes map[string]string) bool {
+	for attribute, pattern := range policy.Conditions {
+		value, ok := attributes[attribute]
+		if !ok {
+			return false
+		}
+		if matched, _ := path.Match(pattern, value); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// EvaluatePolicies decides whether the action with the given attributes is allowed by the policies of the caller
+func EvaluatePolicies(policies []Policy, action string, attributes map[string]string) error {
+	restricted := false
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.appliesTo(action) {
+			continue
+		}
+		switch policy.Effect {
+		case PolicyEffectDeny:
+			if policy.matches(attributes) {
+				return fmt.Errorf("%s is denied by policy %d", action, policy.ID)
+			}
+		case PolicyEffectAllow:
+			restricted = true
+		}
+	}
+	if !restricted {
+		return nil
+	}
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Effect == PolicyEffectAllow && policy.appliesTo(action) && policy.matches(attributes) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not allowed by the policies of the organization", action)
+}
+
+// AuthorizePolicies evaluates the policies of the current organization which apply to the caller,
+// service accounts are subject to the organization wide policies only
+func AuthorizePolicies(req *http.Request, action string, attributes map[string]string) (bool, error) {
+	db := model.GetDB()
+	query := db.Where("organization_id = ?", GetCurrentOrganization(req).ID)
+	if user := GetCurrentUser(req); user != nil {
+		teams := db.Table("team_users").Select("team_id").Where("user_id = ?", user.ID).QueryExpr()
+		query = query.Where("team_id = 0 OR team_id IN (?)", teams)
+	} else {
+		query = query.Where("team_id = 0")
+	}
+	var policies []Policy
+	if err := query.Find(&policies).Error; err != nil {
+		return false, err
+	}
+	if err := EvaluatePolicies(policies, action, attributes); err != nil {
+		log.Info(req.RemoteAddr, err.Error())
+		return false, nil
+	}
+	return true, nil
+}
+
+// getPolicy loads the policy of the :policyid parameter in the current organization
+func getPolicy(c *gin.Context) (*Policy, bool) {
+	var policy Policy
+	policyID, err := strconv.ParseUint(c.Param("policyid"), 10, 32)
+	if err == nil {
+		err = model.GetDB().Where(&Policy{ID: uint(policyID), OrganizationID: GetCurrentOrganization(c.Request).ID}).First(&policy).Error
+	} else {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == gorm.ErrRecordNotFound {
+		message := fmt.Sprintf("policy not found: %q", c.Param("policyid"))
+		log.Info(c.ClientIP(), message)
+		c.AbortWithStatusJSON(http.StatusNotFound, btype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: message,
+			Error:   message,
+		})
+		return nil, false
+	} else if err != nil {
+		abortWithInternalError(c, "Failed to fetch policy", err)
+		return nil, false
+	}
+	return &policy, true
+}
+
+// bindPolicy parses and validates a policy of the current organization from the request
+func bindPolicy(c *gin.Context, policy *Policy) bool {
+	err := c.ShouldBindJSON(policy)
+	if err == nil {
+		policy.OrganizationID = GetCurrentOrganization(c.Request).ID
+		err = policy.Validate()
+	}
+	if err == nil && policy.TeamID != 0 {
+		err = model.GetDB().Where(&Team{ID: policy.TeamID, OrganizationID: policy.OrganizationID}).First(&Team{}).Error
+		if err == gorm.ErrRecordNotFound {
+			err = fmt.Errorf("team not found: %d", policy.TeamID)
+		}
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid policy",
+			Error:   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+//CreatePolicy creates a policy in the current organization
+func CreatePolicy(c *gin.Context) {
+	var policy Policy
+	if !bindPolicy(c, &policy) {
+		return
+	}
+	policy.ID = 0
+	if err := model.GetDB().Create(&policy).Error; err != nil {
+		abortWithInternalError(c, "Failed to create policy", err)
+		return
+	}
+	c.JSON(http.StatusCreated, policy)
+}
+
+//GetPolicies lists the policies of the current organization
+func GetPolicies(c *gin.Context) {
+	policies := []Policy{}
+	err := model.GetDB().Where(&Policy{OrganizationID: GetCurrentOrganization(c.Request).ID}).Find(&policies).Error
+	if err != nil {
+		abortWithInternalError(c, "Failed to list policies", err)
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+//GetPolicy returns a policy of the current organization
+func GetPolicy(c *gin.Context) {
+	if policy, ok := getPolicy(c); ok {
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+//UpdatePolicy replaces a policy of the current organization
+func UpdatePolicy(c *gin.Context) {
+	existing, ok := getPolicy(c)
+	if !ok {
+		return
+	}
+	var policy Policy
+	if !bindPolicy(c, &policy) {
+		return
+	}
+	policy.ID = existing.ID
+	policy.CreatedAt = existing.CreatedAt
+	if err := model.GetDB().Save(&policy).Error; err != nil {
+		abortWithInternalError(c, "Failed to update policy", err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+//DeletePolicy deletes a policy of the current organization
+func DeletePolicy(c *gin.Context) {
+	policy, ok := getPolicy(c)
+	if !ok {
+		return
+	}
+	if err := model.GetDB().Delete(policy).Error; err != nil {
+		abortWithInternalError(c, "Failed to delete policy", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,71 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/auth"
+)
+
+func TestEvaluatePolicies(t *testing.T) {
+
+	awsOnly := auth.Policy{
+		ID:         1,
+		Effect:     auth.PolicyEffectAllow,
+		Action:     auth.PolicyActionClusterCreate,
+		Conditions: auth.PolicyConditions{"cloud": "amazon", "location": "eu-west-1"},
+	}
+	noLargeNodes := auth.Policy{
+		ID:         2,
+		Effect:     auth.PolicyEffectDeny,
+		Action:     auth.PolicyActionAll,
+		Conditions: auth.PolicyConditions{"nodeInstanceType": "*.16xlarge"},
+	}
+	policies := []auth.Policy{awsOnly, noLargeNodes}
+
+	cases := []struct {
+		name       string
+		policies   []auth.Policy
+		action     string
+		attributes map[string]string
+		allowed    bool
+	}{
+		{name: "no policies", action: auth.PolicyActionClusterCreate, attributes: map[string]string{"cloud": "azure"}, allowed: true},
+		{name: "allowed", policies: policies, action: auth.PolicyActionClusterCreate, attributes: map[string]string{"cloud": "amazon", "location": "eu-west-1", "nodeInstanceType": "m4.xlarge"}, allowed: true},
+		{name: "other location", policies: policies, action: auth.PolicyActionClusterCreate, attributes: map[string]string{"cloud": "amazon", "location": "us-east-1", "nodeInstanceType": "m4.xlarge"}, allowed: false},
+		{name: "missing attribute", policies: policies, action: auth.PolicyActionClusterCreate, attributes: map[string]string{"cloud": "amazon"}, allowed: false},
+		{name: "deny takes precedence", policies: policies, action: auth.PolicyActionClusterCreate, attributes: map[string]string{"cloud": "amazon", "location": "eu-west-1", "nodeInstanceType": "m4.16xlarge"}, allowed: false},
+		{name: "unrestricted action", policies: policies, action: auth.PolicyActionDeploymentCreate, attributes: map[string]string{"cloud": "azure", "nodeInstanceType": "m4.xlarge"}, allowed: true},
+		{name: "wildcard deny", policies: policies, action: auth.PolicyActionClusterDelete, attributes: map[string]string{"nodeInstanceType": "m4.16xlarge"}, allowed: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := auth.EvaluatePolicies(tc.policies, tc.action, tc.attributes)
+			if allowed := err == nil; allowed != tc.allowed {
+				t.Errorf("Expected: %t, but got: %t (%v)", tc.allowed, allowed, err)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		policy  auth.Policy
+		isError bool
+	}{
+		{name: "valid", policy: auth.Policy{Effect: auth.PolicyEffectAllow, Action: auth.PolicyActionClusterCreate, Conditions: auth.PolicyConditions{"location": "eu-*"}}, isError: false},
+		{name: "invalid effect", policy: auth.Policy{Effect: "permit", Action: auth.PolicyActionClusterCreate}, isError: true},
+		{name: "invalid action", policy: auth.Policy{Effect: auth.PolicyEffectDeny, Action: "cluster:explode"}, isError: true},
+		{name: "invalid pattern", policy: auth.Policy{Effect: auth.PolicyEffectDeny, Action: auth.PolicyActionAll, Conditions: auth.PolicyConditions{"location": "eu-["}}, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.policy.Validate(); (err != nil) != tc.isError {
+				t.Errorf("Expected error: %t, but got: %v", tc.isError, err)
+			}
+		})
+	}
+}
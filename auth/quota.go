@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// TokenQuotaExceededError is returned by TokenStore.Store if the user already has the maximum number of tokens
+type TokenQuotaExceededError struct {
+	Quota int
+}
+
+func (e TokenQuotaExceededError) Error() string {
+	return fmt.Sprintf("token quota exceeded: a user can have at most %d tokens", e.Quota)
+}
+
+type tokenQuotaOverrideKey struct{}
+
+// WithTokenQuotaOverride returns a context in which TokenStore.Store doesn't enforce the token quota,
+// it's used when an admin creates a token
+func WithTokenQuotaOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tokenQuotaOverrideKey{}, true)
+}
+
+func isTokenQuotaOverridden(ctx context.Context) bool {
+	overridden, _ := ctx.Value(tokenQuotaOverrideKey{}).(bool)
+	return overridden
+}
+
+// A TokenStore wrapper which limits the number of (not expired) tokens per user in Store.
+// The check and the write aren't atomic, so concurrent requests can exceed the quota slightly.
+type quotaTokenStore struct {
+	TokenStore
+	quota int
+}
+
+// NewQuotaTokenStore wraps a TokenStore to allow at most quota tokens per user, zero means no limit
+func NewQuotaTokenStore(tokenStore TokenStore, quota int) TokenStore {
+	return quotaTokenStore{TokenStore: tokenStore, quota: quota}
+}
+
+func (tokenStore quotaTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	if tokenStore.quota > 0 && !isTokenQuotaOverridden(ctx) {
+		tokens, err := tokenStore.TokenStore.List(ctx, userId)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for _, t := range tokens {
+			// Overwriting a token doesn't count
+			if t.ID != token.ID && !t.IsExpired() {
+				count++
+			}
+		}
+		if count >= tokenStore.quota {
+			return TokenQuotaExceededError{Quota: tokenStore.quota}
+		}
+	}
+	return tokenStore.TokenStore.Store(ctx, userId, token)
+}
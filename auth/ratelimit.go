@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenCreationLimiter allows at most limit token creations per user in a sliding
+// time window. The state is kept in memory, so the limit applies per Pipeline replica.
+type tokenCreationLimiter struct {
+	sync.Mutex
+	limit     int
+	window    time.Duration
+	creations map[string][]time.Time
+}
+
+func newTokenCreationLimiter(limit int, window time.Duration) *tokenCreationLimiter {
+	return &tokenCreationLimiter{limit: limit, window: window, creations: make(map[string][]time.Time)}
+}
+
+// Allow records a token creation of the user if the limit isn't reached yet,
+// otherwise it returns false and the time after which the next creation is allowed.
+// A limit of zero (or less) disables the limiter.
+func (limiter *tokenCreationLimiter) Allow(userId string) (bool, time.Duration) {
+	if limiter.limit <= 0 {
+		return true, 0
+	}
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	now := time.Now()
+	creations := limiter.creations[userId]
+	// Drop the creations which are out of the window, they are ordered by time
+	i := 0
+	for i < len(creations) && now.Sub(creations[i]) >= limiter.window {
+		i++
+	}
+	creations = creations[i:]
+
+	if len(creations) >= limiter.limit {
+		limiter.creations[userId] = creations
+		return false, creations[0].Add(limiter.window).Sub(now)
+	}
+	limiter.creations[userId] = append(creations, now)
+	return true, 0
+}
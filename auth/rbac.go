@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/qor/qor/utils"
+)
+
+// CurrentOrganizationRole is the context key of the role of the caller in the current organization
+const CurrentOrganizationRole utils.ContextKey = "orgrole"
+
+// Roles of the organization members, each role has the privileges of the ones below it
+const (
+	RoleAdmin  = "admin"  // manages the members, teams and service accounts of the organization
+	RoleMember = "member" // creates and modifies clusters, deployments, profiles and secrets
+	RoleViewer = "viewer" // reads the resources of the organization
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+}
+
+// ValidateRole checks that the role is known to Pipeline
+func ValidateRole(role string) error {
+	if _, ok := roleRanks[role]; !ok {
+		return fmt.Errorf("invalid role: %q", role)
+	}
+	return nil
+}
+
+// HasRole checks whether the granted role has the privileges of the required one
+func HasRole(granted, required string) bool {
+	return roleRanks[granted] > 0 && roleRanks[granted] >= roleRanks[required]
+}
+
+// higherRole returns the role with more privileges
+func higherRole(a, b string) string {
+	if roleRanks[b] > roleRanks[a] {
+		return b
+	}
+	return a
+}
+
+// GetOrganizationRole returns the highest of the user's membership role and the roles of the user's teams
+// in the organization, it returns gorm.ErrRecordNotFound if the user is not a member of the organization
+func GetOrganizationRole(db *gorm.DB, userID, organizationID uint) (string, error) {
+	var membership UserOrganization
+	err := db.Where(&UserOrganization{UserID: userID, OrganizationID: organizationID}).First(&membership).Error
+	if err != nil {
+		return "", err
+	}
+	role := membership.Role
+
+	var teams []Team
+	err = db.Joins("JOIN team_users ON team_users.team_id = teams.id").
+		Where("team_users.user_id = ? AND teams.organization_id = ?", userID, organizationID).
+		Find(&teams).Error
+	if err != nil {
+		return "", err
+	}
+	for _, team := range teams {
+		role = higherRole(role, team.Role)
+	}
+	return role, nil
+}
+
+// GetCurrentOrganizationRole returns the role of the caller in the current organization
+func GetCurrentOrganizationRole(req *http.Request) string {
+	if role, ok := req.Context().Value(CurrentOrganizationRole).(string); ok {
+		return role
+	}
+	return ""
+}
+
+// OrganizationRoleMiddleware saves the role of the caller in the current organization into the context,
+// and requires the viewer role for GET and HEAD requests and the member role for every other method.
+// Service accounts have the member role in their own organization.
+func OrganizationRoleMiddleware(c *gin.Context) {
+	organization := GetCurrentOrganization(c.Request)
+	role := RoleMember
+	if user := GetCurrentUser(c.Request); user != nil {
+		var err error
+		role, err = GetOrganizationRole(model.GetDB(), user.ID, organization.ID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			message := "Failed to fetch organization role"
+			log.Info(c.ClientIP(), message+": "+err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: message,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	newContext := context.WithValue(c.Request.Context(), CurrentOrganizationRole, role)
+	c.Request = c.Request.WithContext(newContext)
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		RequireOrganizationRole(RoleViewer)(c)
+	default:
+		RequireOrganizationRole(RoleMember)(c)
+	}
+}
+
+// RequireOrganizationRole returns a middleware which aborts the request if the caller
+// doesn't have the required role in the current organization
+func RequireOrganizationRole(required string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(GetCurrentOrganizationRole(c.Request), required) {
+			message := fmt.Sprintf("%q role required in the organization", required)
+			log.Info(c.ClientIP(), message)
+			c.AbortWithStatusJSON(http.StatusForbidden, btype.ErrorResponse{
+				Code:    http.StatusForbidden,
+				Message: "Need more privileges",
+				Error:   message,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/auth"
+)
+
+func TestHasRole(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		granted  string
+		required string
+		expected bool
+	}{
+		{name: "same role", granted: auth.RoleMember, required: auth.RoleMember, expected: true},
+		{name: "admin covers member", granted: auth.RoleAdmin, required: auth.RoleMember, expected: true},
+		{name: "member covers viewer", granted: auth.RoleMember, required: auth.RoleViewer, expected: true},
+		{name: "viewer doesn't cover member", granted: auth.RoleViewer, required: auth.RoleMember, expected: false},
+		{name: "member doesn't cover admin", granted: auth.RoleMember, required: auth.RoleAdmin, expected: false},
+		{name: "no role", granted: "", required: auth.RoleViewer, expected: false},
+		{name: "unknown role", granted: "owner", required: auth.RoleViewer, expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := auth.HasRole(tc.granted, tc.required); actual != tc.expected {
+				t.Errorf("Expected: %t, but got: %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateRole(t *testing.T) {
+
+	for _, role := range []string{auth.RoleAdmin, auth.RoleMember, auth.RoleViewer} {
+		if err := auth.ValidateRole(role); err != nil {
+			t.Errorf("Unexpected error for %q: %s", role, err)
+		}
+	}
+	if err := auth.ValidateRole("owner"); err == nil {
+		t.Error("Expected an error for an unknown role")
+	}
+}
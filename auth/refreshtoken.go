@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+)
+
+// RefreshTokenModel is the database representation of a refresh token, only the hash of the refresh token is stored.
+// Every refresh token belongs to a family: refreshing replaces the used token with a new one of the same family,
+// presenting an already used token again revokes the whole family, since either the client or an attacker holds
+// a stolen copy of it.
+type RefreshTokenModel struct {
+	ID            string `gorm:"primary_key;size:64"`
+	Family        string `gorm:"size:36;index"`
+	UserID        string `gorm:"size:64;index"`
+	AccessTokenID string `gorm:"size:64;index"`
+	Name          string `gorm:"size:255"`
+	Scopes        string `gorm:"type:text"`
+	AllowedCIDRs  string `gorm:"type:text"`
+	CreatedAt     time.Time
+	ExpiresAt     time.Time `gorm:"index"`
+	UsedAt        *time.Time
+}
+
+// TableName sets RefreshTokenModel's table name
+func (RefreshTokenModel) TableName() string {
+	return "refresh_tokens"
+}
+
+// listedTokenID returns the ID of an access token as it's listed by the TokenStore
+func listedTokenID(tokenID string) string {
+	if viper.GetBool("auth.hashtokens") {
+		return hashTokenID(tokenID)
+	}
+	return tokenID
+}
+
+// capTokenTTL shortens the lifetime of a new token to at most ttl
+func capTokenTTL(token *Token, ttl time.Duration) {
+	if maxExpiresAt := token.CreatedAt.Add(ttl); token.ExpiresAt == nil || token.ExpiresAt.After(maxExpiresAt) {
+		token.ExpiresAt = &maxExpiresAt
+	}
+}
+
+// issueRefreshToken creates a refresh token of the family for the access token of the owner
+func issueRefreshToken(db *gorm.DB, owner, family string, accessToken *Token) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	m := RefreshTokenModel{
+		ID:            hashTokenID(refreshToken),
+		Family:        family,
+		UserID:        owner,
+		AccessTokenID: listedTokenID(accessToken.ID),
+		Name:          accessToken.Name,
+		Scopes:        strings.Join(accessToken.Scopes, " "),
+		AllowedCIDRs:  strings.Join(accessToken.AllowedCIDRs, " "),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(viper.GetDuration("auth.refreshtoken.ttl")),
+	}
+	if err := db.Create(&m).Error; err != nil {
+		return "", err
+	}
+	// Expired refresh tokens are useless, they are cleaned up when new ones are issued
+	if err := db.Where("expires_at < ?", now).Delete(RefreshTokenModel{}).Error; err != nil {
+		log.Warnf("Failed to delete expired refresh tokens: %s", err)
+	}
+	return refreshToken, nil
+}
+
+// revokeRefreshTokens deletes the refresh tokens of the owner issued for the access token (all of them if tokenID is empty)
+func revokeRefreshTokens(owner, tokenID string) error {
+	query := model.GetDB().Where("user_id = ?", owner)
+	if tokenID != "" {
+		query = query.Where("access_token_id IN (?)", []string{tokenID, hashTokenID(tokenID)})
+	}
+	return query.Delete(RefreshTokenModel{}).Error
+}
+
+// revokeRefreshTokenFamily deletes the refresh tokens of the family and revokes the access tokens issued with them
+func revokeRefreshTokenFamily(c *gin.Context, family string) error {
+	db := model.GetDB()
+	var models []RefreshTokenModel
+	if err := db.Where("family = ?", family).Find(&models).Error; err != nil {
+		return err
+	}
+	for _, m := range models {
+		if err := denyToken(c.Request.Context(), m.UserID, m.AccessTokenID); err != nil {
+			return err
+		}
+		if err := tokenStore.Revoke(c.Request.Context(), m.UserID, m.AccessTokenID); err != nil {
+			return err
+		}
+		auditToken(c, AuditActionRevoke, m.UserID, m.AccessTokenID, m.UserID, fmt.Errorf("refresh token reused"))
+	}
+	return db.Where("family = ?", family).Delete(RefreshTokenModel{}).Error
+}
+
+// RefreshTokenRequest is the refresh token exchanged for a new access token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new short-lived access token and a new refresh token,
+// the presented refresh token can't be used again
+func RefreshToken(c *gin.Context) {
+	var request RefreshTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid refresh request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	invalid := func(reason string) {
+		log.Info(c.ClientIP(), "Invalid refresh token: "+reason)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, btype.ErrorResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "Invalid refresh token",
+			Error:   reason,
+		})
+	}
+	failed := func(err error) {
+		message := "Failed to refresh token"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+	}
+
+	db := model.GetDB()
+	var used RefreshTokenModel
+	err := db.Where("id = ?", hashTokenID(request.RefreshToken)).First(&used).Error
+	if err == gorm.ErrRecordNotFound {
+		invalid("refresh token not found")
+		return
+	} else if err != nil {
+		failed(err)
+		return
+	}
+	if time.Now().After(used.ExpiresAt) {
+		invalid("refresh token expired")
+		return
+	}
+
+	// Marking the token used is atomic, so concurrent refreshes with the same token can't both succeed
+	now := time.Now()
+	result := db.Model(&RefreshTokenModel{}).Where("id = ? AND used_at IS NULL", used.ID).Update("used_at", &now)
+	if result.Error != nil {
+		failed(result.Error)
+		return
+	}
+	if result.RowsAffected != 1 {
+		if err := revokeRefreshTokenFamily(c, used.Family); err != nil {
+			failed(err)
+			return
+		}
+		invalid("refresh token already used, all tokens issued with it are revoked")
+		return
+	}
+
+	login := ""
+	if _, isServiceAccount := serviceAccountIDFromSubject(used.UserID); !isServiceAccount {
+		var user User
+		if err := db.Where("id = ?", used.UserID).First(&user).Error; err == gorm.ErrRecordNotFound {
+			invalid("user not found")
+			return
+		} else if err != nil {
+			failed(err)
+			return
+		}
+		login = user.Login
+	}
+
+	token := NewToken(uuid.NewV4().String(), used.Name, viper.GetDuration("auth.refreshtoken.accesstokenttl"))
+	if used.Scopes != "" {
+		token.Scopes = strings.Split(used.Scopes, " ")
+	}
+	token.AllowedCIDRs = cidrsFromString(used.AllowedCIDRs)
+	signedToken, err := signToken(used.UserID, login, token)
+	if err != nil {
+		failed(err)
+		return
+	}
+	// The refreshed token replaces a short-lived one, so refreshing is not limited by the quota
+	if err := tokenStore.Store(WithTokenQuotaOverride(c.Request.Context()), used.UserID, token); err != nil {
+		failed(err)
+		return
+	}
+	refreshToken, err := issueRefreshToken(db, used.UserID, used.Family, token)
+	if err != nil {
+		failed(err)
+		return
+	}
+	auditToken(c, AuditActionRefresh, used.UserID, token.ID, used.UserID, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"id":           token.ID,
+		"token":        signedToken,
+		"expiresAt":    token.ExpiresAt,
+		"refreshToken": refreshToken,
+	})
+}
+
+// refreshableTokenResponse issues the refresh token of a new access token of the owner
+func refreshableTokenResponse(owner string, token *Token, signedToken string) (gin.H, error) {
+	refreshToken, err := issueRefreshToken(model.GetDB(), owner, uuid.NewV4().String(), token)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"id":           token.ID,
+		"token":        signedToken,
+		"expiresAt":    token.ExpiresAt,
+		"refreshToken": refreshToken,
+	}, nil
+}
+
+// isRefreshRequested checks the ?refresh=true parameter of a token creation request
+func isRefreshRequested(c *gin.Context) bool {
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	return refresh
+}
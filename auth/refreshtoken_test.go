@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/spf13/viper"
+)
+
+const refreshTokenOwner = serviceAccountPrefix + "1"
+
+type refreshedToken struct {
+	ID           string `json:"id"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// setUpRefreshTokens replaces the database, the token store and the token manager with test ones
+func setUpRefreshTokens(t *testing.T) (*gorm.DB, func()) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error during opening database: %s", err.Error())
+	}
+	// every connection of an in-memory database is a new database
+	db.DB().SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&RefreshTokenModel{}, &RevokedTokenModel{}).Error; err != nil {
+		t.Fatalf("Error during migrating database: %s", err.Error())
+	}
+
+	keysDir, err := ioutil.TempDir("", "signingkeys")
+	if err != nil {
+		t.Fatalf("Error during creating keys directory: %s", err.Error())
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error during generating key: %s", err.Error())
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(keysDir, "current.pem"), data, 0600); err != nil {
+		t.Fatalf("Error during writing key: %s", err.Error())
+	}
+	keys, err := NewLocalKeyProvider(keysDir, "")
+	if err != nil {
+		t.Fatalf("Error during loading keys: %s", err.Error())
+	}
+
+	model.SetDB(db)
+	tokenStore = NewInMemoryTokenStore()
+	tokenManager = NewTokenManager(keys, db)
+	viper.Set("auth.refreshtoken.ttl", time.Hour)
+	viper.Set("auth.refreshtoken.accesstokenttl", time.Minute)
+	return db, func() {
+		tokenStore = nil
+		tokenManager = nil
+		db.Close()
+		os.RemoveAll(keysDir)
+	}
+}
+
+// refresh posts the refresh token to the RefreshToken handler
+func refresh(t *testing.T, refreshToken string) (int, refreshedToken) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/tokens/refresh", RefreshToken)
+
+	body, _ := json.Marshal(RefreshTokenRequest{RefreshToken: refreshToken})
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/auth/tokens/refresh", strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+
+	var response refreshedToken
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Error during parsing response: %s", err.Error())
+		}
+	}
+	return recorder.Code, response
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+
+	db, tearDown := setUpRefreshTokens(t)
+	defer tearDown()
+
+	ctx := context.Background()
+	token := NewToken("first", "ci", time.Minute)
+	if err := tokenStore.Store(ctx, refreshTokenOwner, token); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+	first, err := issueRefreshToken(db, refreshTokenOwner, "family", token)
+	if err != nil {
+		t.Fatalf("Error during issuing refresh token: %s", err.Error())
+	}
+	other, err := issueRefreshToken(db, refreshTokenOwner, "other", token)
+	if err != nil {
+		t.Fatalf("Error during issuing refresh token: %s", err.Error())
+	}
+
+	code, second := refresh(t, first)
+	if code != http.StatusOK {
+		t.Fatalf("Expected %v, got: %v", http.StatusOK, code)
+	}
+	code, third := refresh(t, second.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("Expected %v, got: %v", http.StatusOK, code)
+	}
+
+	// the first refresh token is replayed, so the tokens issued with all the refresh tokens of its family are revoked
+	if code, _ := refresh(t, first); code != http.StatusUnauthorized {
+		t.Errorf("Expected %v, got: %v", http.StatusUnauthorized, code)
+	}
+	if code, _ := refresh(t, third.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("Expected %v, got: %v", http.StatusUnauthorized, code)
+	}
+
+	var count int
+	if err := db.Model(&RefreshTokenModel{}).Where("family = ?", "family").Count(&count).Error; err != nil {
+		t.Fatalf("Error during counting refresh tokens: %s", err.Error())
+	}
+	if count != 0 {
+		t.Errorf("Expected %v, got: %v", 0, count)
+	}
+	for _, tokenID := range []string{token.ID, second.ID, third.ID} {
+		if _, err := tokenStore.Lookup(ctx, refreshTokenOwner, tokenID); err != ErrTokenNotFound {
+			t.Errorf("Expected %v, got: %v", ErrTokenNotFound, err)
+		}
+		if !tokenManager.IsRevoked(tokenID) {
+			t.Errorf("Expected token %s to be revoked", tokenID)
+		}
+	}
+
+	// the refresh tokens of the other families are still valid
+	if code, _ := refresh(t, other); code != http.StatusOK {
+		t.Errorf("Expected %v, got: %v", http.StatusOK, code)
+	}
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+
+	db, tearDown := setUpRefreshTokens(t)
+	defer tearDown()
+
+	refreshToken, err := issueRefreshToken(db, refreshTokenOwner, "family", NewToken("first", "ci", time.Minute))
+	if err != nil {
+		t.Fatalf("Error during issuing refresh token: %s", err.Error())
+	}
+	expiredAt := time.Now().Add(-time.Second)
+	if err := db.Model(&RefreshTokenModel{}).Where("id = ?", hashTokenID(refreshToken)).Update("expires_at", expiredAt).Error; err != nil {
+		t.Fatalf("Error during expiring refresh token: %s", err.Error())
+	}
+	if code, _ := refresh(t, refreshToken); code != http.StatusUnauthorized {
+		t.Errorf("Expected %v, got: %v", http.StatusUnauthorized, code)
+	}
+}
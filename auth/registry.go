@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/spf13/viper"
+)
+
+// TokenStoreFactory creates a TokenStore instance, it's called once during Init
+type TokenStoreFactory func() (TokenStore, error)
+
+var (
+	tokenStoreFactoriesMu sync.RWMutex
+	tokenStoreFactories   = make(map[string]TokenStoreFactory)
+)
+
+// Built-in TokenStore drivers
+func init() {
+	RegisterTokenStore("vault", func() (TokenStore, error) {
+		return NewVaultTokenStoreWithOptions(VaultTokenStoreOptions{
+			Role:      viper.GetString("auth.tokenstore.vault.role"),
+			MountPath: viper.GetString("auth.tokenstore.vault.mountpath"),
+			Prefix:    viper.GetString("auth.tokenstore.vault.prefix"),
+			KVVersion: viper.GetInt("auth.tokenstore.vault.kvversion"),
+			Destroy:   viper.GetBool("auth.tokenstore.vault.destroy"),
+		}), nil
+	})
+	RegisterTokenStore("database", func() (TokenStore, error) {
+		return NewDBTokenStore(model.GetDB()), nil
+	})
+	RegisterTokenStore("memory", func() (TokenStore, error) {
+		return NewInMemoryTokenStoreWithOptions(InMemoryTokenStoreOptions{
+			MaxEntries:    viper.GetInt("auth.tokenstore.memory.maxentries"),
+			SweepInterval: viper.GetDuration("auth.tokenstore.memory.sweepinterval"),
+		}), nil
+	})
+}
+
+// RegisterTokenStore makes a TokenStore implementation available by the given driver name,
+// which can be selected with the auth.tokenstore.driver config key.
+// Third party drivers should call it from an init function before auth.Init is called,
+// registering the same name twice panics.
+func RegisterTokenStore(driver string, factory TokenStoreFactory) {
+	tokenStoreFactoriesMu.Lock()
+	defer tokenStoreFactoriesMu.Unlock()
+	if factory == nil {
+		panic("auth: RegisterTokenStore factory is nil")
+	}
+	if _, dup := tokenStoreFactories[driver]; dup {
+		panic("auth: RegisterTokenStore called twice for driver " + driver)
+	}
+	tokenStoreFactories[driver] = factory
+}
+
+// TokenStoreDrivers returns the sorted list of the registered TokenStore driver names
+func TokenStoreDrivers() []string {
+	tokenStoreFactoriesMu.RLock()
+	defer tokenStoreFactoriesMu.RUnlock()
+	drivers := make([]string, 0, len(tokenStoreFactories))
+	for driver := range tokenStoreFactories {
+		drivers = append(drivers, driver)
+	}
+	sort.Strings(drivers)
+	return drivers
+}
+
+// NewTokenStore creates a TokenStore with the registered driver, instrumented with Prometheus metrics
+func NewTokenStore(driver string) (TokenStore, error) {
+	tokenStoreFactoriesMu.RLock()
+	factory, ok := tokenStoreFactories[driver]
+	tokenStoreFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown token store driver %q (registered: %v)", driver, TokenStoreDrivers())
+	}
+	tokenStore, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedTokenStore(driver, tokenStore), nil
+}
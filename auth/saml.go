@@ -0,0 +1,401 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+const (
+	samlnsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlnsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlnsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	samlBindingPOST = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlStatusOK    = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer      = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// samlClockSkew is tolerated in the validity periods of the assertions
+	samlClockSkew = 3 * time.Minute
+	// samlRequestTTL is how long a login can take at the identity provider
+	samlRequestTTL = 10 * time.Minute
+	// samlMaxResponseSize limits the size of the posted responses
+	samlMaxResponseSize = 1 << 20
+)
+
+// SAMLExtraInfo struct for SAML user info
+type SAMLExtraInfo struct {
+	Login  string
+	Groups []string
+}
+
+// SAMLConfig is the configuration of the SAML service provider and its identity provider (ADFS, Ping, Okta, ...)
+type SAMLConfig struct {
+	// URL is the public URL of Pipeline, the ACS is <URL>/auth/saml/callback
+	URL string
+	// EntityID of Pipeline, <URL>/auth/saml/metadata by default
+	EntityID string
+
+	IdPEntityID    string
+	IdPSSOURL      string
+	IdPCertificate *x509.Certificate
+
+	// Attributes of the assertions mapped to the Pipeline user, the NameID is the login if LoginAttribute is missing
+	LoginAttribute  string
+	NameAttribute   string
+	EmailAttribute  string
+	GroupsAttribute string // the user joins the organizations named after the groups
+}
+
+// SAMLProvider is a SAML 2.0 service provider using the HTTP-Redirect binding for the authentication
+// requests and the HTTP-POST binding for the responses, the responses or assertions must be signed
+type SAMLProvider struct {
+	*SAMLConfig
+
+	sync.Mutex
+	// seenAssertions prevents replaying assertions until they expire
+	seenAssertions map[string]time.Time
+}
+
+// ParseSAMLCertificate reads a PEM encoded certificate of an identity provider
+func ParseSAMLCertificate(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// NewSAMLProvider creates a SAMLProvider
+func NewSAMLProvider(config *SAMLConfig) (*SAMLProvider, error) {
+	if config.URL == "" || config.IdPSSOURL == "" || config.IdPEntityID == "" || config.IdPCertificate == nil {
+		return nil, errors.New("SAML URL, IdP entity ID, SSO URL and certificate can't be blank")
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	if config.EntityID == "" {
+		config.EntityID = config.URL + "/auth/saml/metadata"
+	}
+	return &SAMLProvider{SAMLConfig: config, seenAssertions: make(map[string]time.Time)}, nil
+}
+
+func (provider *SAMLProvider) acsURL() string {
+	return provider.URL + "/auth/saml/callback"
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// GetName return provider name
+func (*SAMLProvider) GetName() string {
+	return "saml"
+}
+
+// ConfigAuth config auth
+func (provider *SAMLProvider) ConfigAuth(*auth.Auth) {
+}
+
+// Login redirects to the identity provider with an authentication request,
+// its ID is carried in the signed RelayState to check the InResponseTo of the response
+func (provider *SAMLProvider) Login(context *auth.Context) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(context.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	requestID := "id-" + hex.EncodeToString(id)
+	now := time.Now().UTC()
+
+	request := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s"><saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy AllowCreate="true"/></samlp:AuthnRequest>`,
+		samlnsProtocol, samlnsAssertion, requestID, now.Format(time.RFC3339), xmlEscape(provider.IdPSSOURL),
+		xmlEscape(provider.acsURL()), samlBindingPOST, xmlEscape(provider.EntityID))
+	var deflated bytes.Buffer
+	writer, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	writer.Write([]byte(request))
+	writer.Close()
+
+	state := claims.Claims{}
+	state.Subject = "state"
+	state.Id = requestID
+	state.ExpiresAt = now.Add(samlRequestTTL).Unix()
+	relayState := context.Auth.SessionStorer.SignedToken(&state)
+
+	redirectURL, err := url.Parse(provider.IdPSSOURL)
+	if err != nil {
+		http.Error(context.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	query := redirectURL.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	query.Set("RelayState", relayState)
+	redirectURL.RawQuery = query.Encode()
+	http.Redirect(context.Writer, context.Request, redirectURL.String(), http.StatusFound)
+}
+
+// Logout implemented logout with the SAML provider
+func (*SAMLProvider) Logout(context *auth.Context) {
+}
+
+// Register implemented register with the SAML provider
+func (provider *SAMLProvider) Register(context *auth.Context) {
+	provider.Login(context)
+}
+
+// Callback is the assertion consumer service (ACS)
+func (provider *SAMLProvider) Callback(context *auth.Context) {
+	context.Auth.LoginHandler(context, provider.authorize)
+}
+
+// ServeHTTP serves the service provider metadata at /auth/saml/metadata
+func (provider *SAMLProvider) ServeHTTP(context *auth.Context) {
+	if !strings.HasSuffix(context.Request.URL.Path, "/metadata") {
+		http.NotFound(context.Writer, context.Request)
+		return
+	}
+	metadata := fmt.Sprintf(`<md:EntityDescriptor xmlns:md="%s" entityID="%s"><md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="%s"><md:NameIDFormat>urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified</md:NameIDFormat><md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/></md:SPSSODescriptor></md:EntityDescriptor>`,
+		samlnsMetadata, xmlEscape(provider.EntityID), samlnsProtocol, samlBindingPOST, xmlEscape(provider.acsURL()))
+	context.Writer.Header().Set("Content-Type", "application/samlmetadata+xml")
+	context.Writer.Write([]byte(xml.Header + metadata))
+}
+
+// samlUser is the user of a validated assertion
+type samlUser struct {
+	NameID     string
+	Attributes map[string][]string
+}
+
+func (user *samlUser) attribute(name string) string {
+	if values := user.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func parseSAMLTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// checkSAMLPeriod checks the NotBefore and NotOnOrAfter attributes of an element if present
+func checkSAMLPeriod(n *xmlNode, now time.Time) error {
+	if notBefore := n.Attr("NotBefore"); notBefore != "" {
+		t, err := parseSAMLTime(notBefore)
+		if err != nil || now.Add(samlClockSkew).Before(t) {
+			return errors.New("assertion not yet valid")
+		}
+	}
+	if notOnOrAfter := n.Attr("NotOnOrAfter"); notOnOrAfter != "" {
+		t, err := parseSAMLTime(notOnOrAfter)
+		if err != nil || !now.Add(-samlClockSkew).Before(t) {
+			return errors.New("assertion expired")
+		}
+	}
+	return nil
+}
+
+// validateResponse checks a posted SAMLResponse of the request, only the signed elements are trusted
+func (provider *SAMLProvider) validateResponse(encoded, requestID string) (*samlUser, error) {
+	if len(encoded) > samlMaxResponseSize {
+		return nil, errors.New("SAML response too large")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	response, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Is(samlnsProtocol, "Response") {
+		return nil, errors.New("not a SAML response")
+	}
+	if inResponseTo := response.Attr("InResponseTo"); inResponseTo != "" && inResponseTo != requestID {
+		return nil, errors.New("SAML response to another request")
+	}
+	status := response.Child(samlnsProtocol, "Status")
+	if status == nil || status.Child(samlnsProtocol, "StatusCode") == nil ||
+		status.Child(samlnsProtocol, "StatusCode").Attr("Value") != samlStatusOK {
+		return nil, errors.New("SAML authentication failed")
+	}
+	if issuer := response.Child(samlnsAssertion, "Issuer"); issuer != nil && issuer.Text() != provider.IdPEntityID {
+		return nil, errors.New("SAML response of an unknown issuer")
+	}
+	if response.Child(samlnsAssertion, "EncryptedAssertion") != nil {
+		return nil, errors.New("encrypted SAML assertions are not supported")
+	}
+	assertions := response.Elements(samlnsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("exactly one SAML assertion is required")
+	}
+	assertion := assertions[0]
+
+	// Either the whole response or the assertion must be signed
+	responseSigned := response.Child(xmlnsDSig, "Signature") != nil
+	if responseSigned {
+		if err := verifyEnvelopedSignature(response, provider.IdPCertificate); err != nil {
+			return nil, fmt.Errorf("invalid SAML response signature: %s", err)
+		}
+	}
+	if !responseSigned || assertion.Child(xmlnsDSig, "Signature") != nil {
+		if err := verifyEnvelopedSignature(assertion, provider.IdPCertificate); err != nil {
+			return nil, fmt.Errorf("invalid SAML assertion signature: %s", err)
+		}
+	}
+
+	now := time.Now()
+	if issuer := assertion.Child(samlnsAssertion, "Issuer"); issuer == nil || issuer.Text() != provider.IdPEntityID {
+		return nil, errors.New("SAML assertion of an unknown issuer")
+	}
+	subject := assertion.Child(samlnsAssertion, "Subject")
+	if subject == nil || subject.Child(samlnsAssertion, "NameID") == nil {
+		return nil, errors.New("missing SAML subject")
+	}
+	confirmed := false
+	for _, confirmation := range subject.Elements(samlnsAssertion, "SubjectConfirmation") {
+		data := confirmation.Child(samlnsAssertion, "SubjectConfirmationData")
+		if confirmation.Attr("Method") != samlBearer || data == nil {
+			continue
+		}
+		if data.Attr("Recipient") == provider.acsURL() && data.Attr("InResponseTo") == requestID && checkSAMLPeriod(data, now) == nil {
+			confirmed = true
+		}
+	}
+	if !confirmed {
+		return nil, errors.New("SAML subject confirmation failed")
+	}
+	conditions := assertion.Child(samlnsAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("missing SAML conditions")
+	}
+	if err := checkSAMLPeriod(conditions, now); err != nil {
+		return nil, err
+	}
+	for _, restriction := range conditions.Elements(samlnsAssertion, "AudienceRestriction") {
+		audienceValid := false
+		for _, audience := range restriction.Elements(samlnsAssertion, "Audience") {
+			audienceValid = audienceValid || audience.Text() == provider.EntityID
+		}
+		if !audienceValid {
+			return nil, errors.New("SAML assertion for another audience")
+		}
+	}
+
+	if err := provider.markSeen(assertion.Attr("ID"), conditions.Attr("NotOnOrAfter"), now); err != nil {
+		return nil, err
+	}
+
+	user := &samlUser{NameID: subject.Child(samlnsAssertion, "NameID").Text(), Attributes: make(map[string][]string)}
+	for _, statement := range assertion.Elements(samlnsAssertion, "AttributeStatement") {
+		for _, attribute := range statement.Elements(samlnsAssertion, "Attribute") {
+			for _, value := range attribute.Elements(samlnsAssertion, "AttributeValue") {
+				user.Attributes[attribute.Attr("Name")] = append(user.Attributes[attribute.Attr("Name")], value.Text())
+			}
+		}
+	}
+	return user, nil
+}
+
+// markSeen records an assertion ID until the assertion expires, a seen ID is a replay
+func (provider *SAMLProvider) markSeen(id, notOnOrAfter string, now time.Time) error {
+	expiresAt, err := parseSAMLTime(notOnOrAfter)
+	if err != nil {
+		expiresAt = now.Add(samlRequestTTL)
+	}
+	provider.Lock()
+	defer provider.Unlock()
+	for seenID, seenExpiresAt := range provider.seenAssertions {
+		if now.After(seenExpiresAt.Add(samlClockSkew)) {
+			delete(provider.seenAssertions, seenID)
+		}
+	}
+	if _, seen := provider.seenAssertions[id]; seen || id == "" {
+		return errors.New("SAML assertion replayed")
+	}
+	provider.seenAssertions[id] = expiresAt
+	return nil
+}
+
+func (provider *SAMLProvider) authorize(context *auth.Context) (*claims.Claims, error) {
+	var (
+		schema       auth.Schema
+		authInfo     auth_identity.Basic
+		authIdentity = reflect.New(utils.ModelType(context.Auth.Config.AuthIdentityModel)).Interface()
+		req          = context.Request
+		tx           = context.Auth.GetDB(req)
+	)
+
+	state, err := context.Auth.SessionStorer.ValidateClaims(req.FormValue("RelayState"))
+	if err != nil || state.Valid() != nil || state.Subject != "state" || state.Id == "" {
+		log.Info(context.Request.RemoteAddr, auth.ErrUnauthorized.Error())
+		return nil, auth.ErrUnauthorized
+	}
+
+	user, err := provider.validateResponse(req.FormValue("SAMLResponse"), state.Id)
+	if err != nil {
+		log.Info(context.Request.RemoteAddr, err.Error())
+		return nil, auth.ErrUnauthorized
+	}
+
+	login := user.attribute(provider.LoginAttribute)
+	if login == "" {
+		login = user.NameID
+	}
+	groups := user.Attributes[provider.GroupsAttribute]
+
+	authInfo.Provider = provider.GetName()
+	authInfo.UID = user.NameID
+
+	if !tx.Model(authIdentity).Where(authInfo).Scan(&authInfo).RecordNotFound() {
+		// Group memberships may have changed since the last login
+		if err := joinGroupOrganizations(tx, authInfo.UserID, groups); err != nil {
+			log.Info(context.Request.RemoteAddr, err.Error())
+			return nil, err
+		}
+		return authInfo.ToClaims(), nil
+	}
+
+	{
+		schema.Provider = provider.GetName()
+		schema.UID = user.NameID
+		schema.Name = user.attribute(provider.NameAttribute)
+		schema.Email = user.attribute(provider.EmailAttribute)
+		schema.RawInfo = &SAMLExtraInfo{Login: login, Groups: groups}
+	}
+	if _, userID, err := context.Auth.UserStorer.Save(&schema, context); err == nil {
+		if userID != "" {
+			authInfo.UserID = userID
+		}
+	} else {
+		return nil, err
+	}
+
+	if err = tx.Where(authInfo).FirstOrCreate(authIdentity).Error; err == nil {
+		return authInfo.ToClaims(), nil
+	}
+
+	log.Info(context.Request.RemoteAddr, err.Error())
+	return nil, err
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The responses in testdata/saml are signed with the key of idp.crt by another XML DSig implementation (goxmldsig),
+// response.xml has a signed assertion, response-signed.xml is signed as a whole and the assertion of
+// response-expired.xml expired in 2018. They are responses to the request id-request of the service provider
+// https://pipeline.example.com.
+const samlRequestID = "id-request"
+
+func readSAMLResponse(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile("testdata/saml/" + name)
+	if err != nil {
+		t.Fatalf("Error during reading %s: %s", name, err.Error())
+	}
+	return string(data)
+}
+
+func newTestSAMLProvider(t *testing.T, url, entityID string) *SAMLProvider {
+	cert, err := ParseSAMLCertificate("testdata/saml/idp.crt")
+	if err != nil {
+		t.Fatalf("Error during reading certificate: %s", err.Error())
+	}
+	provider, err := NewSAMLProvider(&SAMLConfig{
+		URL:            url,
+		EntityID:       entityID,
+		IdPEntityID:    "https://idp.example.com",
+		IdPSSOURL:      "https://idp.example.com/sso",
+		IdPCertificate: cert,
+	})
+	if err != nil {
+		t.Fatalf("Error during creating SAML provider: %s", err.Error())
+	}
+	return provider
+}
+
+// samlElement returns the first element of the document starting with start and ending with end
+func samlElement(document, start, end string) string {
+	from := strings.Index(document, start)
+	return document[from : from+strings.Index(document[from:], end)+len(end)]
+}
+
+func TestSAMLValidateResponse(t *testing.T) {
+
+	response := readSAMLResponse(t, "response.xml")
+	assertion := samlElement(response, "<saml:Assertion ", "</saml:Assertion>")
+	signature := samlElement(assertion, "<ds:Signature ", "</ds:Signature>")
+	forged := strings.Replace(assertion, "jdoe@example.com", "admin@example.com", 1)
+	digest := samlElement(signature, "<ds:DigestValue>", "</ds:DigestValue>")
+	signatureValue := samlElement(signature, "<ds:SignatureValue>", "</ds:SignatureValue>")
+	zeros := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	cases := []struct {
+		name          string
+		response      string
+		url           string
+		entityID      string
+		requestID     string
+		expectedError string
+	}{
+		{name: "signed assertion", response: response},
+		{name: "signed response", response: readSAMLResponse(t, "response-signed.xml")},
+		{
+			name:          "tampered assertion",
+			response:      strings.Replace(response, "jdoe@example.com", "admin@example.com", 1),
+			expectedError: "digest mismatch",
+		},
+		{
+			name:          "tampered digest",
+			response:      strings.Replace(response, digest, "<ds:DigestValue>"+zeros+"</ds:DigestValue>", 1),
+			expectedError: "digest mismatch",
+		},
+		{
+			name:          "tampered signature",
+			response:      strings.Replace(response, signatureValue, "<ds:SignatureValue>"+zeros+"</ds:SignatureValue>", 1),
+			expectedError: "invalid SAML assertion signature",
+		},
+		{
+			name:          "tampered signed response",
+			response:      strings.Replace(readSAMLResponse(t, "response-signed.xml"), "jdoe@example.com", "admin@example.com", 1),
+			expectedError: "invalid SAML response signature",
+		},
+		{
+			name: "wrapped unsigned assertion",
+			response: strings.Replace(response, assertion,
+				"<samlp:Extensions>"+assertion+"</samlp:Extensions>"+
+					strings.Replace(strings.Replace(forged, signature, "", 1), `ID="_assertion"`, `ID="_forged"`, 1), 1),
+			expectedError: "missing signature",
+		},
+		{
+			name: "wrapped signature",
+			response: strings.Replace(response, assertion,
+				"<samlp:Extensions>"+assertion+"</samlp:Extensions>"+strings.Replace(forged, `ID="_assertion"`, `ID="_forged"`, 1), 1),
+			expectedError: "the signature doesn't reference the signed element",
+		},
+		{
+			name: "signed assertion in the signature",
+			response: strings.Replace(response, assertion,
+				strings.Replace(forged, "</ds:Signature>", "<ds:Object>"+assertion+"</ds:Object></ds:Signature>", 1), 1),
+			expectedError: "digest mismatch",
+		},
+		{
+			name:          "additional assertion",
+			response:      strings.Replace(response, assertion, assertion+strings.Replace(forged, `ID="_assertion"`, `ID="_forged"`, 1), 1),
+			expectedError: "exactly one SAML assertion is required",
+		},
+		{
+			name:          "wrong audience",
+			response:      response,
+			entityID:      "https://pipeline.example.com/other",
+			expectedError: "SAML assertion for another audience",
+		},
+		{
+			name:          "wrong recipient",
+			response:      response,
+			url:           "https://other.example.com",
+			entityID:      "https://pipeline.example.com/auth/saml/metadata",
+			expectedError: "SAML subject confirmation failed",
+		},
+		{
+			name:          "wrong request",
+			response:      response,
+			requestID:     "id-other",
+			expectedError: "SAML response to another request",
+		},
+		{
+			name:          "wrong request of the assertion",
+			response:      strings.Replace(response, ` InResponseTo="id-request">`, ">", 1),
+			requestID:     "id-other",
+			expectedError: "SAML subject confirmation failed",
+		},
+		{name: "expired", response: readSAMLResponse(t, "response-expired.xml"), expectedError: "SAML subject confirmation failed"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			url, requestID := tc.url, tc.requestID
+			if url == "" {
+				url = "https://pipeline.example.com"
+			}
+			if requestID == "" {
+				requestID = samlRequestID
+			}
+			provider := newTestSAMLProvider(t, url, tc.entityID)
+
+			user, err := provider.validateResponse(base64.StdEncoding.EncodeToString([]byte(tc.response)), requestID)
+			if tc.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+					t.Errorf("Expected %v, got: %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during validating response: %s", err.Error())
+			}
+			expected := &samlUser{
+				NameID:     "jdoe@example.com",
+				Attributes: map[string][]string{"login": {"jdoe"}, "groups": {"developers", "admins"}},
+			}
+			if !reflect.DeepEqual(user, expected) {
+				t.Errorf("Expected %v, got: %v", expected, user)
+			}
+		})
+	}
+}
+
+func TestSAMLReplayedAssertion(t *testing.T) {
+
+	provider := newTestSAMLProvider(t, "https://pipeline.example.com", "")
+	encoded := base64.StdEncoding.EncodeToString([]byte(readSAMLResponse(t, "response.xml")))
+	if _, err := provider.validateResponse(encoded, samlRequestID); err != nil {
+		t.Fatalf("Error during validating response: %s", err.Error())
+	}
+	if _, err := provider.validateResponse(encoded, samlRequestID); err == nil || err.Error() != "SAML assertion replayed" {
+		t.Errorf("Expected %v, got: %v", "SAML assertion replayed", err)
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/gin-gonic/gin"
+	"github.com/qor/qor/utils"
+)
+
+// ScopeAll grants access to every API endpoint, personal access tokens have this scope
+const ScopeAll = "api:invoke"
+
+// CurrentScopes is the context key of the scopes granted by the access token of the request
+const CurrentScopes utils.ContextKey = "scopes"
+
+// Resources which can be granted in a scope as "<resource>:read" or "<resource>:write"
+const (
+	ScopeResourceCluster      = "cluster"
+	ScopeResourceDeployment   = "deployment"
+	ScopeResourceProfile      = "profile"
+	ScopeResourceSecret       = "secret"
+	ScopeResourceOrganization = "organization"
+)
+
+var scopeResources = []string{
+	ScopeResourceCluster,
+	ScopeResourceDeployment,
+	ScopeResourceProfile,
+	ScopeResourceSecret,
+	ScopeResourceOrganization,
+}
+
+// ValidateScopes checks that all the given scopes are known to Pipeline
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if scope == ScopeAll {
+			continue
+		}
+		parts := strings.Split(scope, ":")
+		if len(parts) != 2 || (parts[1] != "read" && parts[1] != "write") || !isScopeResource(parts[0]) {
+			return fmt.Errorf("invalid scope: %q", scope)
+		}
+	}
+	return nil
+}
+
+func isScopeResource(resource string) bool {
+	for _, r := range scopeResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks whether the granted scopes cover the required one,
+// ScopeAll covers everything and "<resource>:write" covers "<resource>:read" as well
+func HasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == ScopeAll || scope == required {
+			return true
+		}
+		if strings.HasSuffix(required, ":read") && scope == strings.TrimSuffix(required, ":read")+":write" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCurrentScopes returns the scopes granted by the access token of the request,
+// browser sessions have full access
+func GetCurrentScopes(req *http.Request) []string {
+	if scopes, ok := req.Context().Value(CurrentScopes).([]string); ok {
+		return scopes
+	}
+	return []string{ScopeAll}
+}
+
+func saveScopesIntoContext(c *gin.Context, scopes []string) {
+	newContext := context.WithValue(c.Request.Context(), CurrentScopes, scopes)
+	c.Request = c.Request.WithContext(newContext)
+}
+
+// RequireScope returns a middleware which aborts the request if the required scope is not granted
+func RequireScope(required string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(GetCurrentScopes(c.Request), required) {
+			message := fmt.Sprintf("access token has no %q scope", required)
+			log.Info(c.ClientIP(), message)
+			c.AbortWithStatusJSON(http.StatusForbidden, btype.ErrorResponse{
+				Code:    http.StatusForbidden,
+				Message: "Need more privileges",
+				Error:   message,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScopeMiddleware returns a middleware which requires the "<resource>:read" scope
+// for GET and HEAD requests and "<resource>:write" for every other method
+func ScopeMiddleware(resource string) gin.HandlerFunc {
+	read := RequireScope(resource + ":read")
+	write := RequireScope(resource + ":write")
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			read(c)
+		default:
+			write(c)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/auth"
+)
+
+func TestHasScope(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		granted  []string
+		required string
+		expected bool
+	}{
+		{name: "full access", granted: []string{auth.ScopeAll}, required: "cluster:write", expected: true},
+		{name: "exact scope", granted: []string{"cluster:read"}, required: "cluster:read", expected: true},
+		{name: "write covers read", granted: []string{"cluster:write"}, required: "cluster:read", expected: true},
+		{name: "read doesn't cover write", granted: []string{"cluster:read"}, required: "cluster:write", expected: false},
+		{name: "other resource", granted: []string{"deployment:write"}, required: "cluster:read", expected: false},
+		{name: "no scopes", granted: nil, required: "cluster:read", expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := auth.HasScope(tc.granted, tc.required); actual != tc.expected {
+				t.Errorf("Expected: %t, but got: %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		scopes  []string
+		isError bool
+	}{
+		{name: "full access", scopes: []string{auth.ScopeAll}, isError: false},
+		{name: "resource scopes", scopes: []string{"cluster:read", "deployment:write"}, isError: false},
+		{name: "unknown resource", scopes: []string{"spaceship:read"}, isError: true},
+		{name: "unknown access", scopes: []string{"cluster:admin"}, isError: true},
+		{name: "malformed scope", scopes: []string{"cluster"}, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := auth.ValidateScopes(tc.scopes); (err != nil) != tc.isError {
+				t.Errorf("Expected error: %t, but got: %v", tc.isError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/qor/qor/utils"
+)
+
+// CurrentServiceAccount is the context key of the service account authenticated by the access token of the request
+const CurrentServiceAccount utils.ContextKey = "serviceaccount"
+
+// serviceAccountPrefix namespaces the service accounts from the users in the TokenStore and the token subjects
+const serviceAccountPrefix = "sa:"
+
+// ServiceAccount is a non-personal identity owned by an organization, its tokens
+// remain valid regardless of the users of the organization (eg.: for CI systems)
+type ServiceAccount struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	Name           string    `gorm:"not null;unique_index:idx_service_accounts_org_name" json:"name"`
+	OrganizationID uint      `gorm:"not null;unique_index:idx_service_accounts_org_name" json:"organizationId"`
+}
+
+// TableName sets ServiceAccount's table name
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+// UserID returns the ID of the service account in the TokenStore and in the token subjects, eg.: "sa:1"
+func (sa *ServiceAccount) UserID() string {
+	return serviceAccountPrefix + strconv.FormatUint(uint64(sa.ID), 10)
+}
+
+func serviceAccountIDFromSubject(subject string) (uint, bool) {
+	if !strings.HasPrefix(subject, serviceAccountPrefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(subject, serviceAccountPrefix), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetCurrentServiceAccount returns the service account of the request or nil if a user is authenticated
+func GetCurrentServiceAccount(req *http.Request) *ServiceAccount {
+	if sa, ok := req.Context().Value(CurrentServiceAccount).(*ServiceAccount); ok {
+		return sa
+	}
+	return nil
+}
+
+func saveServiceAccountIntoContext(c *gin.Context, sa *ServiceAccount) {
+	newContext := context.WithValue(c.Request.Context(), CurrentServiceAccount, sa)
+	c.Request = c.Request.WithContext(newContext)
+}
+
+//UserMiddleware aborts the request if it's authenticated by a service account token
+func UserMiddleware(c *gin.Context) {
+	if GetCurrentUser(c.Request) == nil {
+		log.Info(c.ClientIP(), "User required")
+		c.AbortWithStatusJSON(http.StatusForbidden, btype.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Need more privileges",
+			Error:   "service accounts can't access this endpoint",
+		})
+		return
+	}
+	c.Next()
+}
+
+// getServiceAccount loads the service account of the :said parameter in the current organization
+func getServiceAccount(c *gin.Context) (*ServiceAccount, bool) {
+	var sa ServiceAccount
+	saID, err := strconv.ParseUint(c.Param("said"), 10, 32)
+	if err == nil {
+		err = model.GetDB().Where(&ServiceAccount{ID: uint(saID), OrganizationID: GetCurrentOrganization(c.Request).ID}).First(&sa).Error
+	} else {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == gorm.ErrRecordNotFound {
+		message := fmt.Sprintf("service account not found: %q", c.Param("said"))
+		log.Info(c.ClientIP(), message)
+		c.AbortWithStatusJSON(http.StatusNotFound, btype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: message,
+			Error:   message,
+		})
+		return nil, false
+	} else if err != nil {
+		message := "Failed to fetch service account"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return &sa, true
+}
+
+// ServiceAccountRequest is the name of a new service account
+type ServiceAccountRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+//CreateServiceAccount creates a service account in the current organization
+func CreateServiceAccount(c *gin.Context) {
+	var request ServiceAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid service account",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	sa := ServiceAccount{Name: request.Name, OrganizationID: GetCurrentOrganization(c.Request).ID}
+	if err := model.GetDB().Create(&sa).Error; err != nil {
+		message := "Failed to create service account"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, sa)
+}
+
+//GetServiceAccounts lists the service accounts of the current organization
+func GetServiceAccounts(c *gin.Context) {
+	serviceAccounts := []ServiceAccount{}
+	err := model.GetDB().Where(&ServiceAccount{OrganizationID: GetCurrentOrganization(c.Request).ID}).Find(&serviceAccounts).Error
+	if err != nil {
+		message := "Failed to list service accounts"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, serviceAccounts)
+}
+
+//DeleteServiceAccount revokes all tokens of a service account and deletes it
+func DeleteServiceAccount(c *gin.Context) {
+	sa, ok := getServiceAccount(c)
+	if !ok {
+		return
+	}
+	err := denyAllTokens(c.Request.Context(), sa.UserID())
+	if err == nil {
+		err = tokenStore.RevokeAll(c.Request.Context(), sa.UserID())
+	}
+	if err == nil {
+		auditToken(c, AuditActionRevokeAll, sa.UserID(), "", auditActor(c), nil)
+		err = model.GetDB().Delete(sa).Error
+	}
+	if err != nil {
+		message := "Failed to delete service account"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+//GenerateServiceAccountToken creates an access token for a service account,
+//it accepts the same ttl, name and scope parameters as GenerateToken
+func GenerateServiceAccountToken(c *gin.Context) {
+	sa, ok := getServiceAccount(c)
+	if !ok {
+		return
+	}
+	token, ok := newTokenFromRequest(c, sa.UserID())
+	if !ok {
+		return
+	}
+	signedToken, err := signToken(sa.UserID(), "", token)
+	if err != nil {
+		err = c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("Failed to sign token: %s", err))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+	if storeNewToken(c, c.Request.Context(), sa.UserID(), token) {
+		c.JSON(http.StatusOK, gin.H{"id": token.ID, "token": signedToken})
+	}
+}
+
+//GetServiceAccountTokens lists the access tokens of a service account
+func GetServiceAccountTokens(c *gin.Context) {
+	sa, ok := getServiceAccount(c)
+	if !ok {
+		return
+	}
+	tokens, err := tokenStore.List(c.Request.Context(), sa.UserID())
+	if err != nil {
+		message := "Failed to list tokens"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondTokens(c, tokens)
+}
+
+//DeleteServiceAccountToken revokes an access token of a service account
+func DeleteServiceAccountToken(c *gin.Context) {
+	sa, ok := getServiceAccount(c)
+	if !ok {
+		return
+	}
+	err := denyToken(c.Request.Context(), sa.UserID(), c.Param("id"))
+	if err == nil {
+		err = tokenStore.Revoke(c.Request.Context(), sa.UserID(), c.Param("id"))
+	}
+	if err != nil {
+		message := "Failed to revoke token"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	auditToken(c, AuditActionRevoke, sa.UserID(), c.Param("id"), auditActor(c), nil)
+	recordTokenRevocation(c, sa.UserID(), c.Param("id"), sa.OrganizationID)
+	c.Status(http.StatusNoContent)
+}
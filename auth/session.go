@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/qor/auth/claims"
+	"github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+)
+
+// sessionOwnerPrefix namespaces the browser sessions of the users from their access tokens in the TokenStore
+const sessionOwnerPrefix = "session:"
+
+var (
+	// ErrSessionNotFound is returned for session cookies without a server-side session
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionIdle is returned for sessions unused for longer than the idle timeout
+	ErrSessionIdle = errors.New("session idle timeout")
+)
+
+// sessionOwner returns the owner of the sessions of a user in the TokenStore, eg.: "session:1"
+func sessionOwner(userID string) string {
+	return sessionOwnerPrefix + userID
+}
+
+// startSession stores a new server-side session for a login,
+// the session ID is put into the claims of the session cookie
+func (sessionStorer *BanzaiSessionStorer) startSession(req *http.Request, claims *claims.Claims) error {
+	if claims.Id != "" {
+		// The session is already started
+		return nil
+	}
+	session := NewToken(uuid.NewV4().String(), req.UserAgent(), viper.GetDuration("auth.session.absolutetimeout"))
+	session.LastUsedIP = req.RemoteAddr
+	// Users with two-factor authentication have to verify the login with a TOTP code
+	var user User
+	if err := model.GetDB().Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		return err
+	}
+	if user.TwoFactorEnabled {
+		session.Scopes = []string{sessionScopeTwoFactorPending}
+	}
+	if err := tokenStore.Store(req.Context(), sessionOwner(claims.UserID), session); err != nil {
+		return err
+	}
+	claims.Id = session.ID
+	if session.ExpiresAt != nil {
+		claims.ExpiresAt = session.ExpiresAt.Unix()
+	}
+	return nil
+}
+
+// verifySession replaces the two-factor pending session of the request with a verified one
+func (sessionStorer *BanzaiSessionStorer) verifySession(w http.ResponseWriter, req *http.Request) error {
+	claims, pending, err := sessionStorer.currentSession(req)
+	if err != nil {
+		return err
+	}
+	owner := sessionOwner(claims.UserID)
+	session := NewToken(uuid.NewV4().String(), pending.Name, 0)
+	session.ExpiresAt = pending.ExpiresAt
+	session.LastUsedIP = req.RemoteAddr
+	if err := tokenStore.Store(req.Context(), owner, session); err != nil {
+		return err
+	}
+	if err := tokenStore.Revoke(req.Context(), owner, pending.ID); err != nil {
+		return err
+	}
+	claims.Id = session.ID
+	return sessionStorer.SessionManager.Add(w, req, sessionStorer.SessionName, sessionStorer.SignedToken(claims))
+}
+
+// currentSession returns the claims of the session cookie and its server-side session
+func (sessionStorer *BanzaiSessionStorer) currentSession(req *http.Request) (*claims.Claims, *Token, error) {
+	claims, err := sessionStorer.SessionStorer.Get(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.Id == "" {
+		return nil, nil, ErrSessionNotFound
+	}
+	session, err := tokenStore.Lookup(req.Context(), sessionOwner(claims.UserID), claims.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, session, nil
+}
+
+// Get returns the claims of the session cookie if its server-side session is valid,
+// sessions are revoked once their idle timeout passes
+func (sessionStorer *BanzaiSessionStorer) Get(req *http.Request) (*claims.Claims, error) {
+	claims, session, err := sessionStorer.currentSession(req)
+	if err != nil {
+		return nil, err
+	}
+	owner := sessionOwner(claims.UserID)
+	lastUsedAt := session.CreatedAt
+	if session.LastUsedAt != nil {
+		lastUsedAt = *session.LastUsedAt
+	}
+	if idle := viper.GetDuration("auth.session.idletimeout"); idle > 0 && time.Since(lastUsedAt) > idle {
+		if err := tokenStore.Revoke(req.Context(), owner, claims.Id); err != nil {
+			log.Errorf("Failed to revoke idle session: %s", err)
+		}
+		return nil, ErrSessionIdle
+	}
+	tokenUsage.Record(owner, claims.Id, req.RemoteAddr)
+	return claims, nil
+}
+
+// Delete revokes the server-side session and clears the session cookie
+func (sessionStorer *BanzaiSessionStorer) Delete(w http.ResponseWriter, req *http.Request) error {
+	if claims, err := sessionStorer.SessionStorer.Get(req); err == nil && claims.Id != "" {
+		if err := tokenStore.Revoke(req.Context(), sessionOwner(claims.UserID), claims.Id); err != nil {
+			log.Errorf("Failed to revoke session: %s", err)
+		}
+	}
+	return sessionStorer.SessionStorer.Delete(w, req)
+}
+
+// sessionResponse is a session of the current user, Current marks the session of the request
+type sessionResponse struct {
+	*Token
+	Current bool `json:"current,omitempty"`
+}
+
+//GetSessions lists the browser sessions of the current user, the name of a session is the user agent of its login
+func GetSessions(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	sessions, err := tokenStore.List(c.Request.Context(), sessionOwner(strconv.Itoa(int(currentUser.ID))))
+	if err != nil {
+		message := "Failed to list sessions"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	var currentSessionID string
+	if claims, err := Auth.SessionStorer.Get(c.Request); err == nil {
+		currentSessionID = claims.Id
+	}
+	response := []sessionResponse{}
+	for _, session := range sessions {
+		response = append(response, sessionResponse{Token: session, Current: session.ID == currentSessionID})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+//DeleteSession revokes a browser session of the current user
+func DeleteSession(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	err := tokenStore.Revoke(c.Request.Context(), sessionOwner(strconv.Itoa(int(currentUser.ID))), c.Param("id"))
+	if err != nil {
+		message := "Failed to revoke session"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+//DeleteSessions revokes all browser sessions of the current user (including the current one)
+func DeleteSessions(c *gin.Context) {
+	currentUser := GetCurrentUser(c.Request)
+	if currentUser == nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+
+	err := tokenStore.RevokeAll(c.Request.Context(), sessionOwner(strconv.Itoa(int(currentUser.ID))))
+	if err != nil {
+		message := "Failed to revoke sessions"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// Team is a group of organization members, its members get the role of the team in the organization
+type Team struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	Name           string    `gorm:"not null;unique_index:idx_teams_org_name" json:"name"`
+	OrganizationID uint      `gorm:"not null;unique_index:idx_teams_org_name" json:"organizationId"`
+	Role           string    `gorm:"not null" json:"role"`
+	Users          []User    `gorm:"many2many:team_users" json:"users,omitempty"`
+}
+
+// TableName sets Team's table name
+func (Team) TableName() string {
+	return "teams"
+}
+
+// TeamRequest is the name and the role of a new or an updated team
+type TeamRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+func abortWithRoleError(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: "Invalid role",
+		Error:   err.Error(),
+	})
+}
+
+func abortWithInternalError(c *gin.Context, message string, err error) {
+	log.Info(c.ClientIP(), message+": "+err.Error())
+	c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+// getTeam loads the team of the :teamid parameter in the current organization
+func getTeam(c *gin.Context) (*Team, bool) {
+	var team Team
+	teamID, err := strconv.ParseUint(c.Param("teamid"), 10, 32)
+	if err == nil {
+		err = model.GetDB().Where(&Team{ID: uint(teamID), OrganizationID: GetCurrentOrganization(c.Request).ID}).Preload("Users").First(&team).Error
+	} else {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == gorm.ErrRecordNotFound {
+		message := fmt.Sprintf("team not found: %q", c.Param("teamid"))
+		log.Info(c.ClientIP(), message)
+		c.AbortWithStatusJSON(http.StatusNotFound, btype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: message,
+			Error:   message,
+		})
+		return nil, false
+	} else if err != nil {
+		abortWithInternalError(c, "Failed to fetch team", err)
+		return nil, false
+	}
+	return &team, true
+}
+
+// getMembership loads the membership of the user of the given parameter in the current organization
+func getMembership(c *gin.Context, param string) (*UserOrganization, bool) {
+	var membership UserOrganization
+	userID, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err == nil {
+		err = model.GetDB().Where(&UserOrganization{UserID: uint(userID), OrganizationID: GetCurrentOrganization(c.Request).ID}).First(&membership).Error
+	} else {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == gorm.ErrRecordNotFound {
+		message := fmt.Sprintf("user not found in the organization: %q", c.Param(param))
+		log.Info(c.ClientIP(), message)
+		c.AbortWithStatusJSON(http.StatusNotFound, btype.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: message,
+			Error:   message,
+		})
+		return nil, false
+	} else if err != nil {
+		abortWithInternalError(c, "Failed to fetch organization member", err)
+		return nil, false
+	}
+	return &membership, true
+}
+
+//CreateTeam creates a team in the current organization
+func CreateTeam(c *gin.Context) {
+	var request TeamRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Name == "" {
+		if err == nil {
+			err = fmt.Errorf("name can't be blank")
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid team",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if request.Role == "" {
+		request.Role = RoleMember
+	}
+	if err := ValidateRole(request.Role); err != nil {
+		abortWithRoleError(c, err)
+		return
+	}
+
+	team := Team{Name: request.Name, Role: request.Role, OrganizationID: GetCurrentOrganization(c.Request).ID}
+	if err := model.GetDB().Create(&team).Error; err != nil {
+		message := "Failed to create team"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, team)
+}
+
+//GetTeams lists the teams of the current organization with their members
+func GetTeams(c *gin.Context) {
+	teams := []Team{}
+	err := model.GetDB().Where(&Team{OrganizationID: GetCurrentOrganization(c.Request).ID}).Preload("Users").Find(&teams).Error
+	if err != nil {
+		abortWithInternalError(c, "Failed to list teams", err)
+		return
+	}
+	c.JSON(http.StatusOK, teams)
+}
+
+//GetTeam returns a team of the current organization with its members
+func GetTeam(c *gin.Context) {
+	if team, ok := getTeam(c); ok {
+		c.JSON(http.StatusOK, team)
+	}
+}
+
+//UpdateTeam renames a team or changes its role
+func UpdateTeam(c *gin.Context) {
+	team, ok := getTeam(c)
+	if !ok {
+		return
+	}
+	var request TeamRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid team",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if request.Role != "" {
+		if err := ValidateRole(request.Role); err != nil {
+			abortWithRoleError(c, err)
+			return
+		}
+		team.Role = request.Role
+	}
+	if request.Name != "" {
+		team.Name = request.Name
+	}
+	if err := model.GetDB().Model(&Team{ID: team.ID}).Updates(map[string]interface{}{"name": team.Name, "role": team.Role}).Error; err != nil {
+		abortWithInternalError(c, "Failed to update team", err)
+		return
+	}
+	c.JSON(http.StatusOK, team)
+}
+
+//DeleteTeam deletes a team with its policies, its members keep their own membership role
+func DeleteTeam(c *gin.Context) {
+	team, ok := getTeam(c)
+	if !ok {
+		return
+	}
+	db := model.GetDB()
+	err := db.Model(team).Association("Users").Clear().Error
+	if err == nil {
+		err = db.Where(&Policy{TeamID: team.ID, OrganizationID: team.OrganizationID}).Delete(&Policy{}).Error
+	}
+	if err == nil {
+		err = db.Delete(team).Error
+	}
+	if err != nil {
+		abortWithInternalError(c, "Failed to delete team", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+//AddTeamMember adds a member of the current organization to a team
+func AddTeamMember(c *gin.Context) {
+	team, ok := getTeam(c)
+	if !ok {
+		return
+	}
+	membership, ok := getMembership(c, "userid")
+	if !ok {
+		return
+	}
+	var user User
+	db := model.GetDB()
+	err := db.First(&user, membership.UserID).Error
+	if err == nil {
+		err = db.Model(team).Association("Users").Append(&user).Error
+	}
+	if err != nil {
+		abortWithInternalError(c, "Failed to add team member", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+//RemoveTeamMember removes a user from a team
+func RemoveTeamMember(c *gin.Context) {
+	team, ok := getTeam(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userid"), 10, 32)
+	if err == nil {
+		err = model.GetDB().Model(team).Association("Users").Delete(&User{ID: uint(userID)}).Error
+	}
+	if err != nil {
+		abortWithInternalError(c, "Failed to remove team member", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MemberRoleRequest is the new role of a member of an organization
+type MemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+//SetMemberRole changes the membership role of a user in the current organization,
+//the last admin of the organization can't be demoted
+func SetMemberRole(c *gin.Context) {
+	membership, ok := getMembership(c, "id")
+	if !ok {
+		return
+	}
+	var request MemberRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithRoleError(c, err)
+		return
+	}
+	if err := ValidateRole(request.Role); err != nil {
+		abortWithRoleError(c, err)
+		return
+	}
+
+	db := model.GetDB()
+	if membership.Role == RoleAdmin && request.Role != RoleAdmin {
+		var admins int
+		err := db.Model(&UserOrganization{}).Where(&UserOrganization{OrganizationID: membership.OrganizationID, Role: RoleAdmin}).Count(&admins).Error
+		if err != nil {
+			abortWithInternalError(c, "Failed to count organization admins", err)
+			return
+		}
+		if admins <= 1 {
+			message := "the last admin of the organization can't be demoted"
+			log.Info(c.ClientIP(), message)
+			c.AbortWithStatusJSON(http.StatusConflict, btype.ErrorResponse{
+				Code:    http.StatusConflict,
+				Message: message,
+				Error:   message,
+			})
+			return
+		}
+	}
+
+	err := db.Model(&UserOrganization{}).
+		Where(&UserOrganization{UserID: membership.UserID, OrganizationID: membership.OrganizationID}).
+		Update("role", request.Role).Error
+	if err != nil {
+		abortWithInternalError(c, "Failed to update organization role", err)
+		return
+	}
+	membership.Role = request.Role
+	c.JSON(http.StatusOK, membership)
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/pipeline/vaultclient"
+	jwt "github.com/dgrijalva/jwt-go"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrTokenRevoked is returned for self-contained tokens on the denylist
+var ErrTokenRevoked = errors.New("token revoked")
+
+// SigningKeyProvider signs access tokens with rotatable keys identified by the kid JWT header
+type SigningKeyProvider interface {
+	// Method is the JWT signing method of the keys
+	Method() jwt.SigningMethod
+	// KeyID returns the kid of the key new tokens are signed with
+	KeyID() (string, error)
+	// Sign returns the encoded JWT signature of signingString made with the key kid
+	Sign(kid, signingString string) (string, error)
+	// VerificationKey returns the public key of kid
+	VerificationKey(kid string) (interface{}, error)
+}
+
+// TokenManager creates self-contained access tokens: their signature, expiry, scopes
+// and subject are validated without a TokenStore lookup, revoked tokens are rejected
+// by a denylist which is shared between the Pipeline replicas through the database.
+// Tokens signed by the TokenManager carry a kid header, tokens without it are legacy
+// HS256 tokens validated with the token signing key and a TokenStore lookup.
+type TokenManager struct {
+	keys     SigningKeyProvider
+	denylist *tokenDenylist
+}
+
+// NewTokenManager creates a TokenManager signing with the keys, the denylist is stored in db
+func NewTokenManager(keys SigningKeyProvider, db *gorm.DB) *TokenManager {
+	return &TokenManager{keys: keys, denylist: newTokenDenylist(db)}
+}
+
+// Sign creates a signed JWT from the claims with the current key
+func (manager *TokenManager) Sign(claims jwt.Claims) (string, error) {
+	kid, err := manager.keys.KeyID()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(manager.keys.Method(), claims)
+	token.Header["kid"] = kid
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+	signature, err := manager.keys.Sign(kid, signingString)
+	if err != nil {
+		return "", err
+	}
+	return signingString + "." + signature, nil
+}
+
+// KeyFunc returns the verification key of a token signed by the TokenManager
+func (manager *TokenManager) KeyFunc(token *jwt.Token) (interface{}, error) {
+	// The alg header must match the keys, otherwise a public key could be used as a HMAC secret
+	if token.Method.Alg() != manager.keys.Method().Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Method.Alg())
+	}
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing kid header")
+	}
+	return manager.keys.VerificationKey(kid)
+}
+
+// Revoke adds a token to the denylist until its expiry (forever if expiresAt is nil)
+func (manager *TokenManager) Revoke(ctx context.Context, tokenID string, expiresAt *time.Time) error {
+	return manager.denylist.Add(ctx, tokenID, expiresAt)
+}
+
+// issued checks whether the token was signed by the TokenManager
+func (manager *TokenManager) issued(token *jwt.Token) bool {
+	_, ok := token.Header["kid"]
+	return ok
+}
+
+// IsRevoked checks whether the token is on the denylist
+func (manager *TokenManager) IsRevoked(tokenID string) bool {
+	// Listed token IDs are hashed if auth.hashtokens is set, so both forms can be revoked
+	return manager.denylist.Contains(tokenID) || manager.denylist.Contains(hashTokenID(tokenID))
+}
+
+// Local keypair implementation
+
+type localKeyProvider struct {
+	current string
+	keys    map[string]*rsa.PrivateKey
+}
+
+// NewLocalKeyProvider loads the RSA private keys from the <kid>.pem files of keysDir, new tokens
+// are signed with currentKid (the last kid in lexical order if empty). To rotate the key, add a
+// new key file and make it current, the old keys remain valid for the tokens signed with them.
+func NewLocalKeyProvider(keysDir, currentKid string) (SigningKeyProvider, error) {
+	files, err := filepath.Glob(filepath.Join(keysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	provider := localKeyProvider{current: currentKid, keys: make(map[string]*rsa.PrivateKey)}
+	kids := make([]string, 0, len(files))
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key %s: %s", file, err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		provider.keys[kid] = key
+		kids = append(kids, kid)
+	}
+	if len(kids) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", keysDir)
+	}
+	if provider.current == "" {
+		sort.Strings(kids)
+		provider.current = kids[len(kids)-1]
+	}
+	if _, ok := provider.keys[provider.current]; !ok {
+		return nil, fmt.Errorf("signing key %q not found in %s", provider.current, keysDir)
+	}
+	return provider, nil
+}
+
+func (provider localKeyProvider) Method() jwt.SigningMethod {
+	return jwt.SigningMethodRS256
+}
+
+func (provider localKeyProvider) KeyID() (string, error) {
+	return provider.current, nil
+}
+
+func (provider localKeyProvider) Sign(kid, signingString string) (string, error) {
+	key, ok := provider.keys[kid]
+	if !ok {
+		return "", fmt.Errorf("unknown signing key: %q", kid)
+	}
+	return jwt.SigningMethodRS256.Sign(signingString, key)
+}
+
+func (provider localKeyProvider) VerificationKey(kid string) (interface{}, error) {
+	key, ok := provider.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	return &key.PublicKey, nil
+}
+
+// Vault transit implementation
+
+const (
+	// vaultKeyRefreshInterval is how often the latest version and the public keys of the transit key are read
+	vaultKeyRefreshInterval = time.Minute
+	// vaultKeyMinRefreshInterval limits the refreshes caused by tokens with unknown key versions
+	vaultKeyMinRefreshInterval = 10 * time.Second
+)
+
+// A SigningKeyProvider which signs with a Vault transit RSA key, so the private key never leaves Vault,
+// eg.: vault write transit/keys/pipeline-jwt type=rsa-2048
+// The kid is "<key>:v<version>", rotating the key in Vault (vault write -f transit/keys/pipeline-jwt/rotate)
+// makes new tokens signed with the new version, the public keys of all versions are used for verification.
+type vaultTransitKeyProvider struct {
+	logical   *vaultapi.Logical
+	mountPath string
+	key       string
+
+	sync.RWMutex
+	latestVersion int
+	publicKeys    map[int]*rsa.PublicKey
+	refreshedAt   time.Time
+}
+
+// NewVaultTransitKeyProvider creates a SigningKeyProvider using the transit key of the given mount
+func NewVaultTransitKeyProvider(role, mountPath, key string) (SigningKeyProvider, error) {
+	client, err := vaultclient.NewClient(role)
+	if err != nil {
+		return nil, err
+	}
+	provider := &vaultTransitKeyProvider{
+		logical:   client.Vault().Logical(),
+		mountPath: strings.Trim(mountPath, "/"),
+		key:       key,
+	}
+	if err := provider.refresh(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func (provider *vaultTransitKeyProvider) refresh() error {
+	secret, err := provider.logical.Read(fmt.Sprintf("%s/keys/%s", provider.mountPath, provider.key))
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return fmt.Errorf("transit key %s/%s not found", provider.mountPath, provider.key)
+	}
+	latestVersion, err := strconv.Atoi(fmt.Sprint(secret.Data["latest_version"]))
+	if err != nil {
+		return fmt.Errorf("invalid latest_version of transit key %s: %s", provider.key, err)
+	}
+	keys, _ := secret.Data["keys"].(map[string]interface{})
+	publicKeys := make(map[int]*rsa.PublicKey, len(keys))
+	for versionKey, value := range keys {
+		version, err := strconv.Atoi(versionKey)
+		if err != nil {
+			continue
+		}
+		// Only asymmetric keys have public keys
+		keyInfo, _ := value.(map[string]interface{})
+		pem, _ := keyInfo["public_key"].(string)
+		if pem == "" {
+			return fmt.Errorf("transit key %s is not an RSA key", provider.key)
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pem))
+		if err != nil {
+			return fmt.Errorf("failed to parse public key v%d of transit key %s: %s", version, provider.key, err)
+		}
+		publicKeys[version] = publicKey
+	}
+
+	provider.Lock()
+	defer provider.Unlock()
+	provider.latestVersion = latestVersion
+	provider.publicKeys = publicKeys
+	provider.refreshedAt = time.Now()
+	return nil
+}
+
+// Method is PS256, the default signature algorithm of Vault for RSA keys
+func (provider *vaultTransitKeyProvider) Method() jwt.SigningMethod {
+	return jwt.SigningMethodPS256
+}
+
+func (provider *vaultTransitKeyProvider) KeyID() (string, error) {
+	provider.RLock()
+	stale := time.Since(provider.refreshedAt) > vaultKeyRefreshInterval
+	provider.RUnlock()
+	if stale {
+		if err := provider.refresh(); err != nil {
+			log.Warnf("Failed to refresh transit key %s, using the cached version: %s", provider.key, err)
+		}
+	}
+	provider.RLock()
+	defer provider.RUnlock()
+	return fmt.Sprintf("%s:v%d", provider.key, provider.latestVersion), nil
+}
+
+func (provider *vaultTransitKeyProvider) version(kid string) (int, error) {
+	version, err := strconv.Atoi(strings.TrimPrefix(kid, provider.key+":v"))
+	if err != nil || !strings.HasPrefix(kid, provider.key+":v") {
+		return 0, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	return version, nil
+}
+
+func (provider *vaultTransitKeyProvider) Sign(kid, signingString string) (string, error) {
+	version, err := provider.version(kid)
+	if err != nil {
+		return "", err
+	}
+	secret, err := provider.logical.Write(fmt.Sprintf("%s/sign/%s/sha2-256", provider.mountPath, provider.key), map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString([]byte(signingString)),
+		"key_version": version,
+	})
+	if err != nil {
+		return "", err
+	}
+	// The signature is in the "vault:v<version>:<base64>" format
+	signature, _ := secret.Data["signature"].(string)
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid signature returned by Vault")
+	}
+	raw, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	return jwt.EncodeSegment(raw), nil
+}
+
+func (provider *vaultTransitKeyProvider) VerificationKey(kid string) (interface{}, error) {
+	version, err := provider.version(kid)
+	if err != nil {
+		return nil, err
+	}
+	provider.RLock()
+	publicKey, ok := provider.publicKeys[version]
+	stale := time.Since(provider.refreshedAt) > vaultKeyMinRefreshInterval
+	provider.RUnlock()
+	if !ok && stale {
+		// The key may have been rotated by another replica since the last refresh
+		if err := provider.refresh(); err != nil {
+			return nil, err
+		}
+		provider.RLock()
+		publicKey, ok = provider.publicKeys[version]
+		provider.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,232 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/auth"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// testDB opens an in-memory SQLite database with the tables of the models
+func testDB(t *testing.T, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error during opening database: %s", err.Error())
+	}
+	// every connection of an in-memory database is a new database
+	db.DB().SetMaxOpenConns(1)
+	if err := db.AutoMigrate(models...).Error; err != nil {
+		t.Fatalf("Error during migrating database: %s", err.Error())
+	}
+	return db
+}
+
+func generateSigningKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error during generating key: %s", err.Error())
+	}
+	return key
+}
+
+// writeSigningKey writes a new RSA private key to the <kid>.pem file of the directory
+func writeSigningKey(t *testing.T, dir, kid string) {
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(generateSigningKey(t))})
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatalf("Error during writing key: %s", err.Error())
+	}
+}
+
+// verifyToken validates a token of the TokenManager like auth.Handler does
+func verifyToken(manager *auth.TokenManager, signed string) (*auth.ScopedClaims, error) {
+	claims := &auth.ScopedClaims{}
+	if _, err := jwt.ParseWithClaims(signed, claims, manager.KeyFunc); err != nil {
+		return nil, err
+	}
+	if manager.IsRevoked(claims.Id) {
+		return nil, auth.ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+func testClaims(tokenID string) *auth.ScopedClaims {
+	return &auth.ScopedClaims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   tokenStoreUserID,
+			Id:        tokenID,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Scope: "cluster:read",
+	}
+}
+
+func TestTokenManagerKeyRotation(t *testing.T) {
+
+	keysDir, err := ioutil.TempDir("", "signingkeys")
+	if err != nil {
+		t.Fatalf("Error during creating keys directory: %s", err.Error())
+	}
+	defer os.RemoveAll(keysDir)
+	writeSigningKey(t, keysDir, "2018-01")
+
+	db := testDB(t, &auth.RevokedTokenModel{})
+	keys, err := auth.NewLocalKeyProvider(keysDir, "")
+	if err != nil {
+		t.Fatalf("Error during loading keys: %s", err.Error())
+	}
+	oldToken, err := auth.NewTokenManager(keys, db).Sign(testClaims("old"))
+	if err != nil {
+		t.Fatalf("Error during signing token: %s", err.Error())
+	}
+
+	// the new key is current in lexical order, the old one still verifies its tokens
+	writeSigningKey(t, keysDir, "2018-06")
+	keys, err = auth.NewLocalKeyProvider(keysDir, "")
+	if err != nil {
+		t.Fatalf("Error during loading keys: %s", err.Error())
+	}
+	manager := auth.NewTokenManager(keys, db)
+	newToken, err := manager.Sign(testClaims("new"))
+	if err != nil {
+		t.Fatalf("Error during signing token: %s", err.Error())
+	}
+
+	cases := []struct {
+		name        string
+		token       string
+		expectedKid string
+	}{
+		{name: "token of the old key", token: oldToken, expectedKid: "2018-01"},
+		{name: "token of the new key", token: newToken, expectedKid: "2018-06"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, _, err := new(jwt.Parser).ParseUnverified(tc.token, &auth.ScopedClaims{})
+			if err != nil {
+				t.Fatalf("Error during parsing token: %s", err.Error())
+			}
+			if kid := parsed.Header["kid"]; kid != tc.expectedKid {
+				t.Errorf("Expected %v, got: %v", tc.expectedKid, kid)
+			}
+			if _, err := verifyToken(manager, tc.token); err != nil {
+				t.Errorf("Error during verifying token: %s", err.Error())
+			}
+		})
+	}
+
+	// without the old key its tokens are rejected
+	if err := os.Remove(filepath.Join(keysDir, "2018-01.pem")); err != nil {
+		t.Fatalf("Error during removing key: %s", err.Error())
+	}
+	keys, err = auth.NewLocalKeyProvider(keysDir, "")
+	if err != nil {
+		t.Fatalf("Error during loading keys: %s", err.Error())
+	}
+	if _, err := verifyToken(auth.NewTokenManager(keys, db), oldToken); err == nil {
+		t.Error("Expected error, but not got error!")
+	}
+}
+
+func TestTokenManagerRejectsForgedTokens(t *testing.T) {
+
+	keysDir, err := ioutil.TempDir("", "signingkeys")
+	if err != nil {
+		t.Fatalf("Error during creating keys directory: %s", err.Error())
+	}
+	defer os.RemoveAll(keysDir)
+	writeSigningKey(t, keysDir, "current")
+	keys, err := auth.NewLocalKeyProvider(keysDir, "")
+	if err != nil {
+		t.Fatalf("Error during loading keys: %s", err.Error())
+	}
+	manager := auth.NewTokenManager(keys, testDB(t, &auth.RevokedTokenModel{}))
+
+	publicKey, err := keys.VerificationKey("current")
+	if err != nil {
+		t.Fatalf("Error during getting verification key: %s", err.Error())
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Error during marshaling public key: %s", err.Error())
+	}
+	// the public key used as an HMAC secret
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims("hmac"))
+	hmacToken.Header["kid"] = "current"
+	hmacSigned, err := hmacToken.SignedString(publicKeyDER)
+	if err != nil {
+		t.Fatalf("Error during signing token: %s", err.Error())
+	}
+
+	// a valid signature of a key the manager doesn't know
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims("unknown"))
+	unknownKid.Header["kid"] = "other"
+	unknownSigned, err := unknownKid.SignedString(generateSigningKey(t))
+	if err != nil {
+		t.Fatalf("Error during signing token: %s", err.Error())
+	}
+
+	for name, token := range map[string]string{"hmac with the public key": hmacSigned, "unknown kid": unknownSigned} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := verifyToken(manager, token); err == nil {
+				t.Error("Expected error, but not got error!")
+			}
+		})
+	}
+}
+
+func TestTokenManagerDenylist(t *testing.T) {
+
+	keysDir, err := ioutil.TempDir("", "signingkeys")
+	if err != nil {
+		t.Fatalf("Error during creating keys directory: %s", err.Error())
+	}
+	defer os.RemoveAll(keysDir)
+	writeSigningKey(t, keysDir, "current")
+	keys, err := auth.NewLocalKeyProvider(keysDir, "")
+	if err != nil {
+		t.Fatalf("Error during loading keys: %s", err.Error())
+	}
+	db := testDB(t, &auth.RevokedTokenModel{})
+	manager := auth.NewTokenManager(keys, db)
+
+	revoked, err := manager.Sign(testClaims(tokenStoreToken))
+	if err != nil {
+		t.Fatalf("Error during signing token: %s", err.Error())
+	}
+	valid, err := manager.Sign(testClaims("valid"))
+	if err != nil {
+		t.Fatalf("Error during signing token: %s", err.Error())
+	}
+	if _, err := verifyToken(manager, revoked); err != nil {
+		t.Fatalf("Error during verifying token: %s", err.Error())
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if err := manager.Revoke(ctx, tokenStoreToken, &expiresAt); err != nil {
+		t.Fatalf("Error during revoking token: %s", err.Error())
+	}
+	if _, err := verifyToken(manager, revoked); err != auth.ErrTokenRevoked {
+		t.Errorf("Expected %v, got: %v", auth.ErrTokenRevoked, err)
+	}
+	if _, err := verifyToken(manager, valid); err != nil {
+		t.Errorf("Error during verifying token: %s", err.Error())
+	}
+
+	var saved auth.RevokedTokenModel
+	if err := db.Where("token_id = ?", tokenStoreToken).First(&saved).Error; err != nil {
+		t.Fatalf("Error during loading revocation: %s", err.Error())
+	}
+	if saved.ExpiresAt == nil || !saved.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected %v, got: %v", expiresAt, saved.ExpiresAt)
+	}
+}
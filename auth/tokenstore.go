@@ -1,78 +1,96 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/banzaicloud/bank-vaults/vault"
+	"github.com/banzaicloud/pipeline/vaultclient"
+	"github.com/hashicorp/go-multierror"
 	vaultapi "github.com/hashicorp/vault/api"
 )
 
-// TokenStore is general interface for storing access tokens
-type TokenStore interface {
-	Store(string, string) error
-	Lookup(string, string) (bool, error)
-	Revoke(string, string) error
-	List(string) ([]string, error)
-}
+var (
+	// ErrTokenNotFound is returned by TokenStore.Lookup and Rotate if the token doesn't exist
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenExpired is returned by TokenStore.Lookup if the token exists but its expiry time has passed
+	ErrTokenExpired = errors.New("token expired")
+)
 
-// In-memory implementation
+// Token represents an access token
+type Token struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	// AllowedCIDRs restricts the IP addresses the token can be used from
+	AllowedCIDRs []string `json:"allowedCidrs,omitempty"`
 
-// NewInMemoryTokenStore is a basic in-memory TokenStore implementation (thread-safe)
-func NewInMemoryTokenStore() TokenStore {
-	return &inMemoryTokenStore{store: make(map[string]map[string]bool)}
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIp,omitempty"`
 }
 
-type inMemoryTokenStore struct {
-	sync.RWMutex
-	store map[string]map[string]bool
+// NewToken creates a new Token instance, a zero ttl means the token never expires
+func NewToken(id, name string, ttl time.Duration) *Token {
+	token := &Token{ID: id, Name: name, CreatedAt: time.Now()}
+	if ttl > 0 {
+		expiresAt := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+	return token
 }
 
-func (tokenStore *inMemoryTokenStore) Store(userId, token string) error {
-	tokenStore.Lock()
-	defer tokenStore.Unlock()
-	var userTokens map[string]bool
-	var ok bool
-	if userTokens, ok = tokenStore.store[userId]; !ok {
-		userTokens = make(map[string]bool)
-	}
-	userTokens[token] = true
-	tokenStore.store[userId] = userTokens
-	return nil
+// IsExpired checks if the token's expiry time has passed
+func (t *Token) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
 }
 
-func (tokenStore *inMemoryTokenStore) Lookup(userId, token string) (bool, error) {
-	tokenStore.RLock()
-	defer tokenStore.RUnlock()
-	if userTokens, ok := tokenStore.store[userId]; ok {
-		_, found := userTokens[token]
-		return found, nil
-	}
-	return false, nil
+// TokenStore is general interface for storing access tokens,
+// Lookup and Rotate return ErrTokenNotFound for unknown tokens,
+// ListUsers returns the sorted IDs of the users with tokens.
+// The context can be used to cancel or time out the backend calls.
+type TokenStore interface {
+	Store(context.Context, string, *Token) error
+	Lookup(context.Context, string, string) (*Token, error)
+	Revoke(context.Context, string, string) error
+	RevokeAll(context.Context, string) error
+	List(context.Context, string) ([]*Token, error)
+	ListAll(context.Context) (map[string][]*Token, error)
+	ListUsers(context.Context) ([]string, error)
+	Touch(context.Context, string, string, string) error
+	Rotate(context.Context, string, string, string) (*Token, error)
 }
 
-func (tokenStore *inMemoryTokenStore) Revoke(userId, token string) error {
-	tokenStore.Lock()
-	defer tokenStore.Unlock()
-	if userTokens, ok := tokenStore.store[userId]; ok {
-		delete(userTokens, token)
-	}
-	return nil
+func copyToken(token *Token) *Token {
+	t := *token
+	t.Scopes = append([]string(nil), token.Scopes...)
+	t.AllowedCIDRs = append([]string(nil), token.AllowedCIDRs...)
+	return &t
 }
 
-func (tokenStore *inMemoryTokenStore) List(userId string) ([]string, error) {
-	tokenStore.Lock()
-	defer tokenStore.Unlock()
-	if userTokens, ok := tokenStore.store[userId]; ok {
-		tokens := make([]string, len(userTokens))
-		i := 0
-		for k := range userTokens {
-			tokens[i] = k
-			i++
-		}
-		return tokens, nil
+// rotatedToken creates the successor of a token with the same name, scopes and lifetime
+func rotatedToken(old *Token, newTokenId string) *Token {
+	var ttl time.Duration
+	if old.ExpiresAt != nil {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
 	}
-	return nil, nil
+	token := NewToken(newTokenId, old.Name, ttl)
+	token.Scopes = append([]string(nil), old.Scopes...)
+	token.AllowedCIDRs = append([]string(nil), old.AllowedCIDRs...)
+	return token
+}
+
+// sortTokens orders tokens by creation time, oldest first
+func sortTokens(tokens []*Token) {
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
 }
 
 // Vault based implementation
@@ -82,54 +100,433 @@ func (tokenStore *inMemoryTokenStore) List(userId string) ([]string, error) {
 // $ vault server -dev &
 // $ export VAULT_ADDR='http://127.0.0.1:8200'
 type vaultTokenStore struct {
-	client  *vault.Client
-	logical *vaultapi.Logical
+	client    *vault.Client
+	logical   *vaultapi.Logical
+	mountPath string
+	prefix    string
+	// kvVersion is the version of the KV secret engine mounted at mountPath (1 or 2)
+	kvVersion int
+	// destroy makes Revoke permanently delete the token on KV v2, instead of a soft-delete
+	destroy bool
+}
+
+// VaultTokenStoreOptions configures the Vault TokenStore, multiple Pipeline
+// instances can share a Vault by using different prefixes (or mounts and roles)
+type VaultTokenStoreOptions struct {
+	// Role is the Vault Kubernetes auth role used to log in, defaults to "pipeline"
+	Role string
+	// MountPath of the KV secret engine, defaults to "secret"
+	MountPath string
+	// Prefix of the tokens in the KV secret engine, defaults to "accesstokens"
+	Prefix string
+	// KVVersion of the KV secret engine (1 or 2), it's autodetected if 0
+	KVVersion int
+	// Destroy revoked tokens with all their versions on KV v2, otherwise only
+	// the latest version is soft-deleted and can be undeleted in Vault
+	Destroy bool
 }
 
 //NewVaultTokenStore creates a new Vault backed token store
 func NewVaultTokenStore() TokenStore {
-	role := "pipeline"
-	client, err := vault.NewClient(role)
+	return NewVaultTokenStoreWithOptions(VaultTokenStoreOptions{})
+}
+
+//NewVaultTokenStoreWithOptions creates a new Vault backed token store, empty options are defaulted
+func NewVaultTokenStoreWithOptions(options VaultTokenStoreOptions) TokenStore {
+	if options.Role == "" {
+		options.Role = "pipeline"
+	}
+	if options.MountPath == "" {
+		options.MountPath = "secret"
+	}
+	if options.Prefix == "" {
+		options.Prefix = "accesstokens"
+	}
+	client, err := vaultclient.NewClient(options.Role)
 	if err != nil {
 		panic(err)
 	}
 	logical := client.Vault().Logical()
-	return vaultTokenStore{client: client, logical: logical}
+	mountPath := strings.Trim(options.MountPath, "/")
+	kvVersion := options.KVVersion
+	if kvVersion == 0 {
+		kvVersion = detectKVVersion(logical, mountPath)
+	}
+	log.Infof("Using KV v%d secret engine at %s for access tokens", kvVersion, mountPath)
+	return vaultTokenStore{
+		client:    client,
+		logical:   logical,
+		mountPath: mountPath,
+		prefix:    strings.Trim(options.Prefix, "/"),
+		kvVersion: kvVersion,
+		destroy:   options.Destroy,
+	}
 }
 
-func tokenPath(userId, token string) string {
-	return fmt.Sprintf("secret/accesstokens/%s/%s", userId, token)
+// detectKVVersion reads the mount options of the KV secret engine, if they can't
+// be read (eg.: Vault versions before 0.10 don't have this endpoint) v1 is assumed
+func detectKVVersion(logical *vaultapi.Logical, mountPath string) int {
+	secret, err := logical.Read("sys/internal/ui/mounts/" + mountPath)
+	if err != nil {
+		log.Debugf("Failed to read the options of mount %s, assuming KV v1: %s", mountPath, err)
+		return 1
+	}
+	if secret == nil {
+		return 1
+	}
+	if options, ok := secret.Data["options"].(map[string]interface{}); ok && options["version"] == "2" {
+		return 2
+	}
+	return 1
+}
+
+func (tokenStore vaultTokenStore) tokenPath(userId, tokenId string) string {
+	return fmt.Sprintf("%s/%s/%s", tokenStore.prefix, userId, tokenId)
+}
+
+func (tokenStore vaultTokenStore) userTokensPath(userId string) string {
+	return fmt.Sprintf("%s/%s", tokenStore.prefix, userId)
+}
+
+// usagePath is the path of the last usage of a token, it's kept outside of the tokens, so writing it
+// can't recreate a revoked token, and a usage without its token is ignored
+func (tokenStore vaultTokenStore) usagePath(userId, tokenId string) string {
+	return fmt.Sprintf("%s-usage/%s/%s", tokenStore.prefix, userId, tokenId)
+}
+
+// apiPath returns the Vault API path of a path relative to the mount, KV v2
+// serves the secrets under "data/" and their keys and versions under "metadata/"
+func (tokenStore vaultTokenStore) apiPath(kind, path string) string {
+	if tokenStore.kvVersion == 2 {
+		return fmt.Sprintf("%s/%s/%s", tokenStore.mountPath, kind, path)
+	}
+	return fmt.Sprintf("%s/%s", tokenStore.mountPath, path)
 }
 
-func (tokenStore vaultTokenStore) Store(userId, token string) error {
-	data := map[string]interface{}{"token": token}
-	_, err := tokenStore.logical.Write(tokenPath(userId, token), data)
+// vaultCall runs a Vault request and returns early when the context is done, the Vault
+// client doesn't support contexts, so the abandoned request itself runs to completion
+func vaultCall(ctx context.Context, call func() (*vaultapi.Secret, error)) (*vaultapi.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		secret *vaultapi.Secret
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		secret, err := call()
+		done <- result{secret: secret, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.secret, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// read returns the data of a KV entry, or nil if it doesn't exist (or has been soft-deleted)
+func (tokenStore vaultTokenStore) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	secret, err := vaultCall(ctx, func() (*vaultapi.Secret, error) {
+		return tokenStore.logical.Read(tokenStore.apiPath("data", path))
+	})
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	if tokenStore.kvVersion == 2 {
+		data, _ := secret.Data["data"].(map[string]interface{})
+		return data, nil
+	}
+	return secret.Data, nil
+}
+
+func (tokenStore vaultTokenStore) write(ctx context.Context, path string, data map[string]interface{}) error {
+	if tokenStore.kvVersion == 2 {
+		data = map[string]interface{}{"data": data}
+	}
+	_, err := vaultCall(ctx, func() (*vaultapi.Secret, error) {
+		return tokenStore.logical.Write(tokenStore.apiPath("data", path), data)
+	})
 	return err
 }
 
-func (tokenStore vaultTokenStore) Lookup(userId, token string) (bool, error) {
-	secret, err := tokenStore.logical.Read(tokenPath(userId, token))
-	if err != nil {
-		return false, err
+// list returns the keys under path, "folders" have a trailing "/"
+func (tokenStore vaultTokenStore) list(ctx context.Context, path string) ([]interface{}, error) {
+	secret, err := vaultCall(ctx, func() (*vaultapi.Secret, error) {
+		return tokenStore.logical.List(tokenStore.apiPath("metadata", path))
+	})
+	if err != nil || secret == nil {
+		return nil, err
 	}
-	return secret != nil, nil
+	keys, _ := secret.Data["keys"].([]interface{})
+	return keys, nil
 }
 
-func (tokenStore vaultTokenStore) Revoke(userId, token string) error {
-	_, err := tokenStore.logical.Delete(tokenPath(userId, token))
+// delete removes a KV entry, on KV v2 the latest version is soft-deleted
+// unless destroy is set, in which case all versions and the metadata are removed
+func (tokenStore vaultTokenStore) delete(ctx context.Context, path string) error {
+	kind := "data"
+	if tokenStore.destroy {
+		kind = "metadata"
+	}
+	_, err := vaultCall(ctx, func() (*vaultapi.Secret, error) {
+		return tokenStore.logical.Delete(tokenStore.apiPath(kind, path))
+	})
 	return err
 }
 
-func (tokenStore vaultTokenStore) List(userId string) ([]string, error) {
-	secret, err := tokenStore.logical.List(fmt.Sprintf("secret/accesstokens/%s", userId))
+func tokenToData(token *Token) map[string]interface{} {
+	data := map[string]interface{}{
+		"token":     token.ID,
+		"name":      token.Name,
+		"createdAt": token.CreatedAt.Format(time.RFC3339),
+	}
+	if token.ExpiresAt != nil {
+		data["expiresAt"] = token.ExpiresAt.Format(time.RFC3339)
+	}
+	if len(token.Scopes) > 0 {
+		data["scopes"] = token.Scopes
+	}
+	if len(token.AllowedCIDRs) > 0 {
+		data["allowedCidrs"] = token.AllowedCIDRs
+	}
+	if token.LastUsedAt != nil {
+		data["lastUsedAt"] = token.LastUsedAt.Format(time.RFC3339)
+		data["lastUsedIp"] = token.LastUsedIP
+	}
+	return data
+}
+
+func tokenFromData(tokenId string, data map[string]interface{}) (*Token, error) {
+	token := &Token{ID: tokenId}
+	token.Name, _ = data["name"].(string)
+	if createdAt, ok := data["createdAt"].(string); ok {
+		t, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		token.CreatedAt = t
+	}
+	if expiresAt, ok := data["expiresAt"].(string); ok {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		token.ExpiresAt = &t
+	}
+	if scopes, ok := data["scopes"].([]interface{}); ok {
+		for _, scope := range scopes {
+			token.Scopes = append(token.Scopes, scope.(string))
+		}
+	}
+	if cidrs, ok := data["allowedCidrs"].([]interface{}); ok {
+		for _, cidr := range cidrs {
+			token.AllowedCIDRs = append(token.AllowedCIDRs, cidr.(string))
+		}
+	}
+	if lastUsedAt, ok := data["lastUsedAt"].(string); ok {
+		t, err := time.Parse(time.RFC3339, lastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		token.LastUsedAt = &t
+	}
+	token.LastUsedIP, _ = data["lastUsedIp"].(string)
+	return token, nil
+}
+
+func (tokenStore vaultTokenStore) Store(ctx context.Context, userId string, token *Token) error {
+	return tokenStore.write(ctx, tokenStore.tokenPath(userId, token.ID), tokenToData(token))
+}
+
+func (tokenStore vaultTokenStore) Lookup(ctx context.Context, userId, tokenId string) (*Token, error) {
+	data, err := tokenStore.read(ctx, tokenStore.tokenPath(userId, tokenId))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrTokenNotFound
+	}
+	token, err := tokenStore.tokenFromData(ctx, userId, tokenId, data)
+	if err != nil {
+		return nil, err
+	}
+	if token.IsExpired() {
+		// Garbage-collect the expired token lazily
+		if err := tokenStore.Revoke(ctx, userId, tokenId); err != nil {
+			log.Warnf("Failed to delete expired token: %s", err)
+		}
+		return nil, ErrTokenExpired
+	}
+	return token, nil
+}
+
+// tokenFromData returns the token of the KV entry with its last usage
+func (tokenStore vaultTokenStore) tokenFromData(ctx context.Context, userId, tokenId string, data map[string]interface{}) (*Token, error) {
+	token, err := tokenFromData(tokenId, data)
 	if err != nil {
 		return nil, err
 	}
+	usage, err := tokenStore.read(ctx, tokenStore.usagePath(userId, tokenId))
+	if err != nil || usage == nil {
+		return token, err
+	}
+	if lastUsedAt, ok := usage["lastUsedAt"].(string); ok {
+		t, err := time.Parse(time.RFC3339, lastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		token.LastUsedAt = &t
+		token.LastUsedIP, _ = usage["lastUsedIp"].(string)
+	}
+	return token, nil
+}
 
-	keys := secret.Data["keys"].([]interface{})
-	tokens := make([]string, len(keys))
-	for i, key := range keys {
-		tokens[i] = key.(string)
+// Revoke deletes the token first, so the token is revoked even if its usage can't be deleted
+func (tokenStore vaultTokenStore) Revoke(ctx context.Context, userId, tokenId string) error {
+	if err := tokenStore.delete(ctx, tokenStore.tokenPath(userId, tokenId)); err != nil {
+		return err
+	}
+	if err := tokenStore.delete(ctx, tokenStore.usagePath(userId, tokenId)); err != nil {
+		log.Warnf("Failed to delete the usage of token %s: %s", tokenId, err)
+	}
+	return nil
+}
+
+func (tokenStore vaultTokenStore) ListAll(ctx context.Context) (map[string][]*Token, error) {
+	userIds, err := tokenStore.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allTokens := make(map[string][]*Token)
+	for _, userId := range userIds {
+		tokens, err := tokenStore.List(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) > 0 {
+			allTokens[userId] = tokens
+		}
+	}
+	return allTokens, nil
+}
+
+// ListUsers lists the users with a single Vault request, on KV v2 a user whose tokens are all soft-deleted is listed too
+func (tokenStore vaultTokenStore) ListUsers(ctx context.Context) ([]string, error) {
+	keys, err := tokenStore.list(ctx, tokenStore.prefix)
+	if err != nil {
+		return nil, err
+	}
+	// The keys are the user IDs as "folders", eg.: "1/"
+	userIds := make([]string, 0, len(keys))
+	for _, key := range keys {
+		userIds = append(userIds, strings.TrimSuffix(key.(string), "/"))
+	}
+	sort.Strings(userIds)
+	return userIds, nil
+}
+
+// Touch writes the last usage of the token to its own path and never to the token itself, so a token revoked
+// concurrently stays revoked, at worst with an orphaned usage which Lookup and List ignore
+func (tokenStore vaultTokenStore) Touch(ctx context.Context, userId, tokenId, ip string) error {
+	data, err := tokenStore.read(ctx, tokenStore.tokenPath(userId, tokenId))
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		// The token has been revoked in the meantime
+		return nil
+	}
+	return tokenStore.write(ctx, tokenStore.usagePath(userId, tokenId), map[string]interface{}{
+		"lastUsedAt": time.Now().Format(time.RFC3339),
+		"lastUsedIp": ip,
+	})
+}
+
+// Rotate stores the new token first and revokes the old one after, if the revocation
+// fails the new token is revoked as well, so either the old or the new token remains valid
+func (tokenStore vaultTokenStore) Rotate(ctx context.Context, userId, tokenId, newTokenId string) (*Token, error) {
+	old, err := tokenStore.Lookup(ctx, userId, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	token := rotatedToken(old, newTokenId)
+	if err := tokenStore.Store(ctx, userId, token); err != nil {
+		return nil, err
+	}
+	if err := tokenStore.Revoke(ctx, userId, tokenId); err != nil {
+		if err := tokenStore.Revoke(ctx, userId, newTokenId); err != nil {
+			log.Warnf("Failed to revoke token %s after failed rotation: %s", newTokenId, err)
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// vaultRevokeBatchSize is the number of concurrent Vault delete requests in RevokeAll
+const vaultRevokeBatchSize = 10
+
+// RevokeAll lists the tokens of the user and deletes them in concurrent batches,
+// all tokens are tried to be deleted, failed ones are reported in a multierror
+func (tokenStore vaultTokenStore) RevokeAll(ctx context.Context, userId string) error {
+	keys, err := tokenStore.list(ctx, tokenStore.userTokensPath(userId))
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(keys))
+	for start := 0; start < len(keys); start += vaultRevokeBatchSize {
+		end := start + vaultRevokeBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				tokenId := keys[i].(string)
+				if err := tokenStore.Revoke(ctx, userId, tokenId); err != nil {
+					errs[i] = fmt.Errorf("failed to revoke token %s: %s", tokenId, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	var result *multierror.Error
+	for _, err := range errs {
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+func (tokenStore vaultTokenStore) List(ctx context.Context, userId string) ([]*Token, error) {
+	keys, err := tokenStore.list(ctx, tokenStore.userTokensPath(userId))
+	if err != nil || keys == nil {
+		return nil, err
+	}
+
+	tokens := make([]*Token, 0, len(keys))
+	for _, key := range keys {
+		tokenId := key.(string)
+		data, err := tokenStore.read(ctx, tokenStore.tokenPath(userId, tokenId))
+		if err != nil {
+			return nil, err
+		}
+		// Soft-deleted tokens are still listed on KV v2
+		if data == nil {
+			continue
+		}
+		token, err := tokenStore.tokenFromData(ctx, userId, tokenId, data)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
 	}
+	sortTokens(tokens)
 	return tokens, nil
 }
@@ -0,0 +1,265 @@
+package auth_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/auth"
+)
+
+const (
+	tokenStoreUserID = "1"
+	tokenStoreToken  = "d2c1b2e5-0e9b-4a3b-9d2f-3c6f1f0f5a11"
+	tokenStoreName   = "ci"
+)
+
+var ctx = context.Background()
+
+func TestInMemoryTokenStoreTTL(t *testing.T) {
+
+	cases := []struct {
+		name          string
+		ttl           time.Duration
+		wait          time.Duration
+		expectedFound bool
+		expectedError error
+	}{
+		{name: "token without ttl", ttl: 0, wait: 0, expectedFound: true, expectedError: nil},
+		{name: "token with long ttl", ttl: time.Hour, wait: 0, expectedFound: true, expectedError: nil},
+		{name: "expired token", ttl: time.Millisecond, wait: 10 * time.Millisecond, expectedFound: false, expectedError: auth.ErrTokenExpired},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenStore := auth.NewInMemoryTokenStore()
+			if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken(tokenStoreToken, tokenStoreName, tc.ttl)); err != nil {
+				t.Fatalf("Error during storing token: %s", err.Error())
+			}
+
+			time.Sleep(tc.wait)
+
+			token, err := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken)
+			if err != tc.expectedError {
+				t.Errorf("Expected error: %v, but got: %v", tc.expectedError, err)
+			}
+			if found := token != nil; found != tc.expectedFound {
+				t.Errorf("Expected found: %t, but got: %t", tc.expectedFound, found)
+			}
+
+			if tc.expectedError == auth.ErrTokenExpired {
+				// expired tokens are garbage-collected on lookup
+				if tokens, _ := tokenStore.List(ctx, tokenStoreUserID); len(tokens) != 0 {
+					t.Errorf("Expected expired token to be removed, but got: %v", tokens)
+				}
+			}
+		})
+	}
+}
+
+func TestInMemoryTokenStoreMetadata(t *testing.T) {
+	tokenStore := auth.NewInMemoryTokenStore()
+	token := auth.NewToken(tokenStoreToken, tokenStoreName, 0)
+	if err := tokenStore.Store(ctx, tokenStoreUserID, token); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+
+	found, err := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken)
+	if err != nil {
+		t.Fatalf("Error during token lookup: %s", err.Error())
+	}
+	if !reflect.DeepEqual(token, found) {
+		t.Errorf("Expected token: %#v, but got: %#v", token, found)
+	}
+
+	tokens, err := tokenStore.List(ctx, tokenStoreUserID)
+	if err != nil {
+		t.Fatalf("Error during listing tokens: %s", err.Error())
+	}
+	if len(tokens) != 1 || !reflect.DeepEqual(token, tokens[0]) {
+		t.Errorf("Expected tokens: [%#v], but got: %v", token, tokens)
+	}
+}
+
+func TestHashedTokenStore(t *testing.T) {
+	inner := auth.NewInMemoryTokenStore()
+	tokenStore := auth.NewHashedTokenStore(inner)
+	if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken(tokenStoreToken, tokenStoreName, 0)); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+
+	if token, _ := inner.Lookup(ctx, tokenStoreUserID, tokenStoreToken); token != nil {
+		t.Errorf("Expected raw token id not to be stored, but found: %#v", token)
+	}
+
+	if token, err := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken); err != nil || token == nil {
+		t.Errorf("Expected token to be found, but got: %v, %v", token, err)
+	}
+
+	if err := tokenStore.Revoke(ctx, tokenStoreUserID, tokenStoreToken); err != nil {
+		t.Fatalf("Error during revoking token: %s", err.Error())
+	}
+	if token, err := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken); err != auth.ErrTokenNotFound {
+		t.Errorf("Expected error: %v, but got: %v (token: %#v)", auth.ErrTokenNotFound, err, token)
+	}
+}
+
+func TestHashedTokenStoreRotate(t *testing.T) {
+
+	cases := []struct {
+		name   string
+		listed bool
+	}{
+		{name: "raw id", listed: false},
+		{name: "listed id", listed: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenStore := auth.NewHashedTokenStore(auth.NewInMemoryTokenStore())
+			if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken(tokenStoreToken, tokenStoreName, time.Hour)); err != nil {
+				t.Fatalf("Error during storing token: %s", err.Error())
+			}
+			tokenID := tokenStoreToken
+			if tc.listed {
+				tokens, err := tokenStore.List(ctx, tokenStoreUserID)
+				if err != nil || len(tokens) != 1 {
+					t.Fatalf("Expected the listed token, got: %v, %v", tokens, err)
+				}
+				tokenID = tokens[0].ID
+			}
+
+			const newTokenID = "8f3b6a4e-6d0c-4a4f-a2a5-0b8f7c1d2e3f"
+			token, err := tokenStore.Rotate(ctx, tokenStoreUserID, tokenID, newTokenID)
+			if err != nil {
+				t.Fatalf("Error during rotating token: %s", err.Error())
+			}
+			if token.ID != newTokenID || token.Name != tokenStoreName {
+				t.Errorf("Expected the new token, got: %#v", token)
+			}
+			if found, _ := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken); found != nil {
+				t.Errorf("Expected old token to be revoked, but found: %#v", found)
+			}
+			if found, _ := tokenStore.Lookup(ctx, tokenStoreUserID, newTokenID); found == nil {
+				t.Error("Expected new token to be stored")
+			}
+		})
+	}
+
+	tokenStore := auth.NewHashedTokenStore(auth.NewInMemoryTokenStore())
+	if _, err := tokenStore.Rotate(ctx, tokenStoreUserID, "missing", "new"); err != auth.ErrTokenNotFound {
+		t.Errorf("Expected error: %v, but got: %v", auth.ErrTokenNotFound, err)
+	}
+}
+
+func TestCachingTokenStore(t *testing.T) {
+	inner := auth.NewInMemoryTokenStore()
+	tokenStore := auth.NewCachingTokenStore(inner, time.Hour)
+	if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken(tokenStoreToken, tokenStoreName, 0)); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+
+	// Revoking through the inner store bypasses the cache
+	if err := inner.Revoke(ctx, tokenStoreUserID, tokenStoreToken); err != nil {
+		t.Fatalf("Error during revoking token: %s", err.Error())
+	}
+	if token, err := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken); err != nil || token == nil {
+		t.Errorf("Expected token to be served from the cache, but got: %v, %v", token, err)
+	}
+
+	if err := tokenStore.Revoke(ctx, tokenStoreUserID, tokenStoreToken); err != nil {
+		t.Fatalf("Error during revoking token: %s", err.Error())
+	}
+	if token, err := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken); err != auth.ErrTokenNotFound {
+		t.Errorf("Expected error: %v, but got: %v (token: %#v)", auth.ErrTokenNotFound, err, token)
+	}
+}
+
+func TestQuotaTokenStore(t *testing.T) {
+	tokenStore := auth.NewQuotaTokenStore(auth.NewInMemoryTokenStore(), 1)
+	if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken("first", tokenStoreName, 0)); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+
+	err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken("second", tokenStoreName, 0))
+	if _, ok := err.(auth.TokenQuotaExceededError); !ok {
+		t.Errorf("Expected quota exceeded error, but got: %v", err)
+	}
+
+	if err := tokenStore.Store(auth.WithTokenQuotaOverride(ctx), tokenStoreUserID, auth.NewToken("second", tokenStoreName, 0)); err != nil {
+		t.Errorf("Expected quota to be overridden, but got: %v", err)
+	}
+}
+
+func TestInMemoryTokenStoreRotate(t *testing.T) {
+	tokenStore := auth.NewInMemoryTokenStore()
+	old := auth.NewToken(tokenStoreToken, tokenStoreName, time.Hour)
+	old.Scopes = []string{"cluster:read"}
+	if err := tokenStore.Store(ctx, tokenStoreUserID, old); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+
+	const newTokenID = "8f3b6a4e-6d0c-4a4f-a2a5-0b8f7c1d2e3f"
+	token, err := tokenStore.Rotate(ctx, tokenStoreUserID, tokenStoreToken, newTokenID)
+	if err != nil {
+		t.Fatalf("Error during rotating token: %s", err.Error())
+	}
+	if token.ID != newTokenID || token.Name != old.Name || !reflect.DeepEqual(token.Scopes, old.Scopes) || token.ExpiresAt == nil {
+		t.Errorf("Expected metadata of %#v to be copied, but got: %#v", old, token)
+	}
+
+	if found, _ := tokenStore.Lookup(ctx, tokenStoreUserID, tokenStoreToken); found != nil {
+		t.Errorf("Expected old token to be revoked, but found: %#v", found)
+	}
+	if found, _ := tokenStore.Lookup(ctx, tokenStoreUserID, newTokenID); found == nil {
+		t.Error("Expected new token to be stored")
+	}
+}
+
+func TestInMemoryTokenStoreEviction(t *testing.T) {
+	tokenStore := auth.NewInMemoryTokenStoreWithOptions(auth.InMemoryTokenStoreOptions{MaxEntries: 2})
+	for _, tokenID := range []string{"first", "second"} {
+		if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken(tokenID, tokenStoreName, 0)); err != nil {
+			t.Fatalf("Error during storing token: %s", err.Error())
+		}
+	}
+
+	// "first" becomes the most recently used one
+	if token, _ := tokenStore.Lookup(ctx, tokenStoreUserID, "first"); token == nil {
+		t.Fatal("Expected first token to be found")
+	}
+
+	if err := tokenStore.Store(ctx, tokenStoreUserID, auth.NewToken("third", tokenStoreName, 0)); err != nil {
+		t.Fatalf("Error during storing token: %s", err.Error())
+	}
+
+	if token, _ := tokenStore.Lookup(ctx, tokenStoreUserID, "second"); token != nil {
+		t.Errorf("Expected least recently used token to be evicted, but found: %#v", token)
+	}
+	for _, tokenID := range []string{"first", "third"} {
+		if token, _ := tokenStore.Lookup(ctx, tokenStoreUserID, tokenID); token == nil {
+			t.Errorf("Expected token %q to be kept", tokenID)
+		}
+	}
+}
+
+func TestInMemoryTokenStoreListUsers(t *testing.T) {
+	tokenStore := auth.NewInMemoryTokenStore()
+	for _, userID := range []string{"2", "10", "1"} {
+		if err := tokenStore.Store(ctx, userID, auth.NewToken(tokenStoreToken, tokenStoreName, 0)); err != nil {
+			t.Fatalf("Error during storing token: %s", err.Error())
+		}
+	}
+	if err := tokenStore.Revoke(ctx, "2", tokenStoreToken); err != nil {
+		t.Fatalf("Error during revoking token: %s", err.Error())
+	}
+
+	userIDs, err := tokenStore.ListUsers(ctx)
+	if err != nil {
+		t.Fatalf("Error during listing users: %s", err.Error())
+	}
+	if expected := []string{"1", "10"}; !reflect.DeepEqual(userIDs, expected) {
+		t.Errorf("Expected %v, got: %v", expected, userIDs)
+	}
+}
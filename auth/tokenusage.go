@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type tokenUsageKey struct {
+	userID  string
+	tokenID string
+}
+
+// tokenUsageRecorder collects the last usage of access tokens in memory and
+// writes them to the TokenStore periodically, so not every authenticated
+// request results in a write to the backend (e.g. Vault).
+// The precision of the recorded last used timestamps is the flush interval.
+type tokenUsageRecorder struct {
+	sync.Mutex
+	tokenStore TokenStore
+	pending    map[tokenUsageKey]string
+}
+
+func newTokenUsageRecorder(tokenStore TokenStore) *tokenUsageRecorder {
+	return &tokenUsageRecorder{tokenStore: tokenStore, pending: make(map[tokenUsageKey]string)}
+}
+
+// Record registers a token usage from the given IP address, only the last one is kept per token
+func (recorder *tokenUsageRecorder) Record(userID, tokenID, ip string) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.pending[tokenUsageKey{userID: userID, tokenID: tokenID}] = ip
+}
+
+// Flush writes all pending token usages to the TokenStore
+func (recorder *tokenUsageRecorder) Flush(ctx context.Context) {
+	recorder.Lock()
+	pending := recorder.pending
+	recorder.pending = make(map[tokenUsageKey]string)
+	recorder.Unlock()
+
+	for key, ip := range pending {
+		if err := recorder.tokenStore.Touch(ctx, key.userID, key.tokenID, ip); err != nil {
+			log.Warnf("Failed to update last usage of token %s: %s", key.tokenID, err)
+		}
+	}
+}
+
+// Run flushes the pending token usages with the given interval, it never returns
+func (recorder *tokenUsageRecorder) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		recorder.Flush(context.Background())
+	}
+}
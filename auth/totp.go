@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// TOTP parameters (RFC 6238), the ones supported by every authenticator app
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	// totpSkew is the number of periods accepted before and after the current one (clock drift)
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generates a random 160 bit TOTP secret, base32 encoded
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+func hotp(key []byte, counter int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	return totpEncoding.DecodeString(strings.TrimRight(strings.ToUpper(secret), "="))
+}
+
+// GenerateTOTP returns the TOTP code of the base32 encoded secret at the given time
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(t)), nil
+}
+
+// ValidateTOTP checks a code against the secret and returns the time step it belongs to,
+// codes of steps up to lastStep are rejected so a code can't be replayed
+func ValidateTOTP(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step > lastStep && subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpURL returns the otpauth:// URL of the secret, authenticator apps enroll it from a QR code
+func totpURL(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("period", strconv.Itoa(int(totpPeriod/time.Second)))
+	values.Set("digits", strconv.Itoa(totpDigits))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + values.Encode()
+}
+
+// newRecoveryCodes generates single-use recovery codes, eg.: "3f9c-68a0-b1d2"
+func newRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		random := make([]byte, 6)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(random)
+		codes = append(codes, code[0:4]+"-"+code[4:8]+"-"+code[8:12])
+	}
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// TwoFactorSecret is the TOTP enrollment of a user, only the hashes of the recovery codes are stored
+type TwoFactorSecret struct {
+	Secret        string
+	Enabled       bool
+	RecoveryCodes []string
+	LastStep      int64
+}
+
+// useRecoveryCode removes the recovery code if it's valid
+func (secret *TwoFactorSecret) useRecoveryCode(code string) bool {
+	hash := hashRecoveryCode(code)
+	for i, recoveryCode := range secret.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(recoveryCode), []byte(hash)) == 1 {
+			secret.RecoveryCodes = append(secret.RecoveryCodes[:i], secret.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// TwoFactorStore stores the TOTP secrets and recovery codes of the users,
+// Get returns nil for users who haven't enrolled
+type TwoFactorStore interface {
+	Get(ctx context.Context, userID string) (*TwoFactorSecret, error)
+	Put(ctx context.Context, userID string, secret *TwoFactorSecret) error
+	Delete(ctx context.Context, userID string) error
+}
+
+// NewTwoFactorStore creates the TwoFactorStore of the auth.twofactor.store driver ("vault" or "memory")
+func NewTwoFactorStore(driver string) (TwoFactorStore, error) {
+	switch driver {
+	case "vault":
+		return NewVaultTwoFactorStore(VaultTokenStoreOptions{
+			Role:      viper.GetString("auth.tokenstore.vault.role"),
+			MountPath: viper.GetString("auth.tokenstore.vault.mountpath"),
+			Prefix:    viper.GetString("auth.twofactor.vault.prefix"),
+			KVVersion: viper.GetInt("auth.tokenstore.vault.kvversion"),
+			Destroy:   true,
+		}), nil
+	case "memory":
+		return NewInMemoryTwoFactorStore(), nil
+	}
+	return nil, fmt.Errorf("unknown two-factor store driver %q", driver)
+}
+
+// vaultTwoFactorStore stores the TOTP secrets in the KV secret engine, next to the access tokens
+type vaultTwoFactorStore struct {
+	kv vaultTokenStore
+}
+
+// NewVaultTwoFactorStore creates a Vault backed TwoFactorStore, the secrets are stored under the prefix of the options
+func NewVaultTwoFactorStore(options VaultTokenStoreOptions) TwoFactorStore {
+	if options.Prefix == "" {
+		options.Prefix = "twofactor"
+	}
+	return vaultTwoFactorStore{kv: NewVaultTokenStoreWithOptions(options).(vaultTokenStore)}
+}
+
+func (store vaultTwoFactorStore) Get(ctx context.Context, userID string) (*TwoFactorSecret, error) {
+	data, err := store.kv.read(ctx, store.kv.userTokensPath(userID))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	secret := &TwoFactorSecret{}
+	secret.Secret, _ = data["secret"].(string)
+	secret.Enabled, _ = data["enabled"].(bool)
+	if codes, ok := data["recoveryCodes"].([]interface{}); ok {
+		for _, code := range codes {
+			secret.RecoveryCodes = append(secret.RecoveryCodes, code.(string))
+		}
+	}
+	if lastStep, ok := data["lastStep"].(string); ok {
+		secret.LastStep, _ = strconv.ParseInt(lastStep, 10, 64)
+	}
+	return secret, nil
+}
+
+func (store vaultTwoFactorStore) Put(ctx context.Context, userID string, secret *TwoFactorSecret) error {
+	return store.kv.write(ctx, store.kv.userTokensPath(userID), map[string]interface{}{
+		"secret":        secret.Secret,
+		"enabled":       secret.Enabled,
+		"recoveryCodes": secret.RecoveryCodes,
+		"lastStep":      strconv.FormatInt(secret.LastStep, 10),
+	})
+}
+
+func (store vaultTwoFactorStore) Delete(ctx context.Context, userID string) error {
+	return store.kv.delete(ctx, store.kv.userTokensPath(userID))
+}
+
+// inMemoryTwoFactorStore is a TwoFactorStore for development, the enrollments are lost on restart
+type inMemoryTwoFactorStore struct {
+	sync.Mutex
+	secrets map[string]TwoFactorSecret
+}
+
+// NewInMemoryTwoFactorStore creates an in-memory TwoFactorStore
+func NewInMemoryTwoFactorStore() TwoFactorStore {
+	return &inMemoryTwoFactorStore{secrets: make(map[string]TwoFactorSecret)}
+}
+
+func (store *inMemoryTwoFactorStore) Get(ctx context.Context, userID string) (*TwoFactorSecret, error) {
+	store.Lock()
+	defer store.Unlock()
+	secret, ok := store.secrets[userID]
+	if !ok {
+		return nil, nil
+	}
+	secret.RecoveryCodes = append([]string(nil), secret.RecoveryCodes...)
+	return &secret, nil
+}
+
+func (store *inMemoryTwoFactorStore) Put(ctx context.Context, userID string, secret *TwoFactorSecret) error {
+	store.Lock()
+	defer store.Unlock()
+	stored := *secret
+	stored.RecoveryCodes = append([]string(nil), secret.RecoveryCodes...)
+	store.secrets[userID] = stored
+	return nil
+}
+
+func (store *inMemoryTwoFactorStore) Delete(ctx context.Context, userID string) error {
+	store.Lock()
+	defer store.Unlock()
+	delete(store.secrets, userID)
+	return nil
+}
@@ -0,0 +1,55 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/auth"
+)
+
+// The SHA-1 secret of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTP(t *testing.T) {
+
+	cases := []struct {
+		time     int64
+		expected string
+	}{
+		{time: 59, expected: "287082"},
+		{time: 1111111109, expected: "081804"},
+		{time: 1111111111, expected: "050471"},
+		{time: 1234567890, expected: "005924"},
+		{time: 2000000000, expected: "279037"},
+	}
+
+	for _, tc := range cases {
+		code, err := auth.GenerateTOTP(rfc6238Secret, time.Unix(tc.time, 0))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if code != tc.expected {
+			t.Errorf("Expected %s at %d, but got: %s", tc.expected, tc.time, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+
+	now := time.Unix(1111111111, 0)
+	code, _ := auth.GenerateTOTP(rfc6238Secret, now)
+
+	step, ok := auth.ValidateTOTP(rfc6238Secret, code, now.Add(30*time.Second), 0)
+	if !ok {
+		t.Fatal("Expected the code of the previous period to be valid")
+	}
+	if _, ok := auth.ValidateTOTP(rfc6238Secret, code, now, step); ok {
+		t.Error("Expected a used code to be rejected")
+	}
+	if _, ok := auth.ValidateTOTP(rfc6238Secret, code, now.Add(2*time.Minute), 0); ok {
+		t.Error("Expected an old code to be rejected")
+	}
+	if _, ok := auth.ValidateTOTP(rfc6238Secret, "000000", now, 0); ok {
+		t.Error("Expected an invalid code to be rejected")
+	}
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	btype "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// sessionScopeTwoFactorPending marks the sessions of logins waiting for the TOTP code of the user,
+// these sessions can't access anything but the verification endpoint
+const sessionScopeTwoFactorPending = "2fa:pending"
+
+const recoveryCodeCount = 10
+
+var twoFactorStore TwoFactorStore
+
+// TwoFactorRequest is the TOTP or recovery code confirming a two-factor change
+type TwoFactorRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func abortWithInvalidCode(c *gin.Context) {
+	log.Info(c.ClientIP(), "Invalid two-factor code")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, btype.ErrorResponse{
+		Code:    http.StatusUnauthorized,
+		Message: "Invalid two-factor code",
+		Error:   "the TOTP or recovery code is invalid",
+	})
+}
+
+// isTwoFactorPending checks whether the request is authenticated by a session waiting for the second factor
+func isTwoFactorPending(req *http.Request) bool {
+	sessionStorer, ok := Auth.SessionStorer.(*BanzaiSessionStorer)
+	if !ok {
+		return false
+	}
+	_, session, err := sessionStorer.currentSession(req)
+	if err != nil {
+		return false
+	}
+	for _, scope := range session.Scopes {
+		if scope == sessionScopeTwoFactorPending {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTwoFactorCode validates a TOTP or a recovery code of an enabled enrollment and saves the used step or code
+func checkTwoFactorCode(c *gin.Context, userID string, secret *TwoFactorSecret, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if step, ok := ValidateTOTP(secret.Secret, code, time.Now(), secret.LastStep); ok {
+		secret.LastStep = step
+	} else if !secret.Enabled || !secret.useRecoveryCode(code) {
+		return false, nil
+	}
+	return true, twoFactorStore.Put(c.Request.Context(), userID, secret)
+}
+
+// checkTwoFactorForTokens aborts the token creation if an organization of the current user requires
+// two-factor authentication and the user hasn't enabled it
+func checkTwoFactorForTokens(c *gin.Context) bool {
+	user, err := GetCurrentUserFromDB(c.Request)
+	if err != nil {
+		// Service accounts can't create tokens
+		return true
+	}
+	if user.TwoFactorEnabled {
+		return true
+	}
+	var organizations []Organization
+	err = model.GetDB().Model(user).Where("require_two_factor = ?", true).Related(&organizations, "Organizations").Error
+	if err != nil {
+		message := "Failed to check two-factor requirements"
+		log.Info(c.ClientIP(), message+": "+err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, btype.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return false
+	}
+	if len(organizations) > 0 {
+		message := fmt.Sprintf("organization %q requires two-factor authentication", organizations[0].Name)
+		log.Info(c.ClientIP(), message)
+		c.AbortWithStatusJSON(http.StatusForbidden, btype.ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: "Two-factor authentication required",
+			Error:   message,
+		})
+		return false
+	}
+	return true
+}
+
+//EnrollTwoFactor generates a new TOTP secret for the current user, it has to be confirmed with VerifyTwoFactor
+func EnrollTwoFactor(c *gin.Context) {
+	user, err := GetCurrentUserFromDB(c.Request)
+	if err != nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.AbortWithStatusJSON(http.StatusConflict, btype.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "Two-factor authentication is already enabled",
+			Error:   "disable two-factor authentication before enrolling a new secret",
+		})
+		return
+	}
+
+	secret, err := NewTOTPSecret()
+	if err == nil {
+		err = twoFactorStore.Put(c.Request.Context(), strconv.Itoa(int(user.ID)), &TwoFactorSecret{Secret: secret})
+	}
+	if err != nil {
+		abortWithInternalError(c, "Failed to enroll two-factor authentication", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"url":    totpURL(viper.GetString("auth.twofactor.issuer"), user.Login, secret),
+	})
+}
+
+//VerifyTwoFactor checks a TOTP code of the current user: the first code confirms the enrollment
+//and returns the recovery codes, later codes (or recovery codes) complete the interactive logins
+func VerifyTwoFactor(c *gin.Context) {
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid two-factor code",
+			Error:   err.Error(),
+		})
+		return
+	}
+	user, err := GetCurrentUserFromDB(c.Request)
+	if err != nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+	userID := strconv.Itoa(int(user.ID))
+	secret, err := twoFactorStore.Get(c.Request.Context(), userID)
+	if err != nil {
+		abortWithInternalError(c, "Failed to read two-factor secret", err)
+		return
+	}
+	if secret == nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Two-factor authentication is not enrolled",
+			Error:   "enroll with POST /api/v1/2fa/enroll first",
+		})
+		return
+	}
+	ok, err := checkTwoFactorCode(c, userID, secret, request.Code)
+	if err != nil {
+		abortWithInternalError(c, "Failed to save two-factor secret", err)
+		return
+	}
+	if !ok {
+		abortWithInvalidCode(c)
+		return
+	}
+
+	if !secret.Enabled {
+		recoveryCodes, err := newRecoveryCodes(recoveryCodeCount)
+		if err == nil {
+			secret.Enabled = true
+			for _, code := range recoveryCodes {
+				secret.RecoveryCodes = append(secret.RecoveryCodes, hashRecoveryCode(code))
+			}
+			err = twoFactorStore.Put(c.Request.Context(), userID, secret)
+		}
+		if err == nil {
+			err = model.GetDB().Model(&User{}).Where("id = ?", user.ID).Update("two_factor_enabled", true).Error
+		}
+		if err != nil {
+			abortWithInternalError(c, "Failed to enable two-factor authentication", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+		return
+	}
+
+	if sessionStorer, ok := Auth.SessionStorer.(*BanzaiSessionStorer); ok && isTwoFactorPending(c.Request) {
+		if err := sessionStorer.verifySession(c.Writer, c.Request); err != nil {
+			abortWithInternalError(c, "Failed to verify session", err)
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+//DisableTwoFactor removes the TOTP secret and the recovery codes of the current user, it needs a valid code
+func DisableTwoFactor(c *gin.Context) {
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid two-factor code",
+			Error:   err.Error(),
+		})
+		return
+	}
+	user, err := GetCurrentUserFromDB(c.Request)
+	if err != nil {
+		err := c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("Invalid session"))
+		log.Info(c.ClientIP(), err.Error())
+		return
+	}
+	userID := strconv.Itoa(int(user.ID))
+	secret, err := twoFactorStore.Get(c.Request.Context(), userID)
+	if err != nil {
+		abortWithInternalError(c, "Failed to read two-factor secret", err)
+		return
+	}
+	if secret == nil || !secret.Enabled {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	ok, err := checkTwoFactorCode(c, userID, secret, request.Code)
+	if err != nil {
+		abortWithInternalError(c, "Failed to save two-factor secret", err)
+		return
+	}
+	if !ok {
+		abortWithInvalidCode(c)
+		return
+	}
+
+	err = model.GetDB().Model(&User{}).Where("id = ?", user.ID).Update("two_factor_enabled", false).Error
+	if err == nil {
+		err = twoFactorStore.Delete(c.Request.Context(), userID)
+	}
+	if err != nil {
+		abortWithInternalError(c, "Failed to disable two-factor authentication", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// OrganizationTwoFactorRequest sets whether the members of an organization need two-factor authentication
+type OrganizationTwoFactorRequest struct {
+	Required bool `json:"required"`
+}
+
+//SetOrganizationTwoFactor sets whether the members of the current organization need two-factor authentication to create tokens
+func SetOrganizationTwoFactor(c *gin.Context) {
+	var request OrganizationTwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, btype.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid two-factor requirement",
+			Error:   err.Error(),
+		})
+		return
+	}
+	organization := GetCurrentOrganization(c.Request)
+	err := model.GetDB().Model(&Organization{}).Where("id = ?", organization.ID).Update("require_two_factor", request.Required).Error
+	if err != nil {
+		abortWithInternalError(c, "Failed to update organization", err)
+		return
+	}
+	organization.RequireTwoFactor = request.Required
+	c.JSON(http.StatusOK, organization)
+}
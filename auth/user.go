@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/banzaicloud/pipeline/model"
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/go-errors/errors"
 	"github.com/jinzhu/copier"
 	"github.com/jinzhu/gorm"
@@ -26,15 +25,17 @@ const (
 
 //User struct
 type User struct {
-	ID            uint           `gorm:"primary_key" json:"id"`
-	CreatedAt     time.Time      `json:"createdAt"`
-	UpdatedAt     time.Time      `json:"updatedAt"`
-	DeletedAt     *time.Time     `sql:"index" json:"deletedAt,omitempty"`
-	Name          string         `form:"name" json:"name,omitempty"`
-	Email         string         `form:"email" json:"email,omitempty"`
-	Login         string         `gorm:"unique;not null" form:"login" json:"login"`
-	Image         string         `form:"image" json:"image,omitempty"`
-	Organizations []Organization `gorm:"many2many:user_organizations" json:"organizations,omitempty"`
+	ID               uint           `gorm:"primary_key" json:"id"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	UpdatedAt        time.Time      `json:"updatedAt"`
+	DeletedAt        *time.Time     `sql:"index" json:"deletedAt,omitempty"`
+	Name             string         `form:"name" json:"name,omitempty"`
+	Email            string         `form:"email" json:"email,omitempty"`
+	Login            string         `gorm:"unique;not null" form:"login" json:"login"`
+	Image            string         `form:"image" json:"image,omitempty"`
+	Admin            bool           `json:"admin,omitempty"` // set by the LDAP group roles
+	TwoFactorEnabled bool           `json:"twoFactorEnabled,omitempty"`
+	Organizations    []Organization `gorm:"many2many:user_organizations" json:"organizations,omitempty"`
 }
 
 //DroneUser struct
@@ -52,8 +53,11 @@ type DroneUser struct {
 	Synced int64  `gorm:"column:user_synced"`
 }
 
+// UserOrganization is the membership of a user in an organization, Role is one of the organization roles
 type UserOrganization struct {
-	Role string `gorm:"DEFAULT:\"admin\""`
+	UserID         uint   `json:"userId"`
+	OrganizationID uint   `json:"organizationId"`
+	Role           string `gorm:"DEFAULT:\"admin\"" json:"role"`
 }
 
 //Organization struct
@@ -65,6 +69,8 @@ type Organization struct {
 	Name      string               `gorm:"unique;not null" json:"name"`
 	Users     []User               `gorm:"many2many:user_organizations" json:"users,omitempty"`
 	Clusters  []model.ClusterModel `gorm:"foreignkey:organization_id" json:"clusters,omitempty"`
+	// RequireTwoFactor makes the members enable two-factor authentication before creating tokens
+	RequireTwoFactor bool `json:"requireTwoFactor,omitempty"`
 }
 
 func (org *Organization) IDString() string {
@@ -90,6 +96,25 @@ func GetCurrentOrganization(req *http.Request) *Organization {
 	return nil
 }
 
+//GetUsersByLogin loads the users with the logins, unknown logins are skipped
+func GetUsersByLogin(logins []string) ([]User, error) {
+	users := []User{}
+	if len(logins) == 0 {
+		return users, nil
+	}
+	err := model.GetDB().Where("login IN (?)", logins).Find(&users).Error
+	return users, err
+}
+
+//GetOrganizationAdmins loads the members of the organization with the admin role
+func GetOrganizationAdmins(organizationID uint) ([]User, error) {
+	users := []User{}
+	err := model.GetDB().Joins("JOIN user_organizations ON user_organizations.user_id = users.id").
+		Where("user_organizations.organization_id = ? AND user_organizations.role = ?", organizationID, RoleAdmin).
+		Find(&users).Error
+	return users, err
+}
+
 func GetCurrentUserFromDB(req *http.Request) (*User, error) {
 	if currentUser, ok := Auth.GetCurrentUser(req).(*User); ok {
 		claims := &claims.Claims{UserID: strconv.Itoa(int(currentUser.ID))}
@@ -120,16 +145,46 @@ func (bus BanzaiUserStorer) Save(schema *auth.Schema, context *auth.Context) (us
 		currentUser := &User{}
 		copier.Copy(currentUser, schema)
 
-		// This assumes GitHub auth only right now
-		githubExtraInfo := schema.RawInfo.(*GithubExtraInfo)
-		currentUser.Login = githubExtraInfo.Login
-		if viper.GetBool("drone.enabled") {
-			err = bus.createUserInDroneDB(currentUser, githubExtraInfo.Token)
-			if err != nil {
-				log.Info(context.Request.RemoteAddr, err.Error())
-				return nil, "", err
+		var groups []string
+		switch extraInfo := schema.RawInfo.(type) {
+		case *GithubExtraInfo:
+			currentUser.Login = extraInfo.Login
+			// Drone needs the GitHub token of the user
+			if viper.GetBool("drone.enabled") {
+				err = bus.createUserInDroneDB(currentUser, extraInfo.Token, "", 0)
+				if err != nil {
+					log.Info(context.Request.RemoteAddr, err.Error())
+					return nil, "", err
+				}
+				bus.synchronizeDroneRepos(currentUser.Login)
+			}
+		case *OAuthExtraInfo:
+			currentUser.Login = extraInfo.Login
+			// Drone works with a single SCM, it has to be configured for the same provider
+			if viper.GetBool("drone.enabled") {
+				var expiry int64
+				if !extraInfo.Expiry.IsZero() {
+					expiry = extraInfo.Expiry.Unix()
+				}
+				err = bus.createUserInDroneDB(currentUser, extraInfo.Token, extraInfo.RefreshToken, expiry)
+				if err != nil {
+					log.Info(context.Request.RemoteAddr, err.Error())
+					return nil, "", err
+				}
+				bus.synchronizeDroneRepos(currentUser.Login)
 			}
-			bus.synchronizeDroneRepos(currentUser.Login)
+		case *LDAPExtraInfo:
+			currentUser.Login = extraInfo.Login
+			currentUser.Admin = extraInfo.Admin
+			groups = extraInfo.Groups
+		case *SAMLExtraInfo:
+			currentUser.Login = extraInfo.Login
+			groups = extraInfo.Groups
+		case *OIDCExtraInfo:
+			currentUser.Login = extraInfo.Login
+			groups = extraInfo.Groups
+		default:
+			return nil, "", fmt.Errorf("unknown user info of provider %q", schema.Provider)
 		}
 
 		// When a user registers a default organization is created in which he/she is admin
@@ -139,18 +194,51 @@ func (bus BanzaiUserStorer) Save(schema *auth.Schema, context *auth.Context) (us
 		currentUser.Organizations = []Organization{userOrg}
 
 		err = tx.Create(currentUser).Error
-		return currentUser, fmt.Sprint(tx.NewScope(currentUser).PrimaryKeyValue()), err
+		userID := fmt.Sprint(tx.NewScope(currentUser).PrimaryKeyValue())
+		if err == nil {
+			err = joinGroupOrganizations(tx, userID, groups)
+		}
+		return currentUser, userID, err
 	}
 	return nil, "", nil
 }
 
+// joinGroupOrganizations adds the user with the member role to the existing organizations named after the groups of an identity provider
+func joinGroupOrganizations(db *gorm.DB, userID string, groups []string) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	var organizations []Organization
+	if err := db.Where("name IN (?)", groups).Find(&organizations).Error; err != nil {
+		return err
+	}
+	if len(organizations) == 0 {
+		return nil
+	}
+	var user User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	for _, organization := range organizations {
+		membership := UserOrganization{UserID: user.ID, OrganizationID: organization.ID}
+		if err := db.Where(&membership).Attrs(UserOrganization{Role: RoleMember}).FirstOrCreate(&membership).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //http://127.0.0.1:8000/
 
-func (bus BanzaiUserStorer) createUserInDroneDB(user *User, githubAccessToken string) error {
+// createUserInDroneDB creates the Drone user with the OAuth tokens of its SCM provider,
+// the refresh token and the expiry are used by the GitLab and Bitbucket integrations only
+func (bus BanzaiUserStorer) createUserInDroneDB(user *User, accessToken, refreshToken string, expiry int64) error {
 	droneUser := DroneUser{
 		Login:  user.Login,
 		Email:  user.Email,
-		Token:  githubAccessToken,
+		Token:  accessToken,
+		Secret: refreshToken,
+		Expiry: expiry,
 		Hash:   bus.signingKeyBase32,
 		Image:  user.Image,
 		Active: true,
@@ -173,9 +261,7 @@ func (bus BanzaiUserStorer) synchronizeDroneRepos(login string) {
 	}
 
 	// Create a temporary Drone API token
-	claims := &DroneClaims{Type: DroneUserCookieType, Text: login}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	apiToken, err := token.SignedString([]byte(bus.signingKeyBase32))
+	apiToken, err := droneAPIToken(login)
 	if err != nil {
 		log.Info("synchronizeDroneRepos: failed to create temporary token for Drone GET request", err.Error())
 		return
@@ -0,0 +1,347 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	// The hash implementations are linked in for crypto.Hash.New
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Verification of enveloped XML signatures (XML DSig) made with RSA keys and exclusive
+// canonicalization, the way SAML identity providers sign their responses and assertions.
+
+const (
+	xmlnsDSig         = "http://www.w3.org/2000/09/xmldsig#"
+	xmlExcC14N        = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	xmlEnvelopedSig   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	xmlDSigRSASHA1    = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	xmlDSigRSASHA256  = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	xmlDSigSHA1       = "http://www.w3.org/2000/09/xmldsig#sha1"
+	xmlDSigSHA256     = "http://www.w3.org/2001/04/xmlenc#sha256"
+	xmlnsXML          = "http://www.w3.org/XML/1998/namespace"
+	xmlnsAttrPrefix   = "xmlns"
+	xmlPrefixReserved = "xml"
+)
+
+// xmlNode is an element of a parsed XML document, names keep their raw prefixes
+type xmlNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Children []interface{} // *xmlNode or xml.CharData
+	Parent   *xmlNode
+}
+
+// parseXML parses a document into an xmlNode tree, comments and processing instructions are dropped
+func parseXML(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *xmlNode
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{Name: t.Name, Attrs: t.Copy().Attr, Parent: current}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("multiple root elements")
+				}
+				root = node
+			} else {
+				current.Children = append(current.Children, node)
+			}
+			current = node
+		case xml.EndElement:
+			if current == nil {
+				return nil, errors.New("unexpected end element")
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, t.Copy())
+			}
+		case xml.Directive:
+			// DTDs are not allowed, they are a common vector of XML attacks
+			return nil, errors.New("XML directives are not allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("incomplete XML document")
+	}
+	return root, nil
+}
+
+// namespace resolves a prefix ("" is the default namespace) in the scope of the node
+func (n *xmlNode) namespace(prefix string) string {
+	if prefix == xmlPrefixReserved {
+		return xmlnsXML
+	}
+	for node := n; node != nil; node = node.Parent {
+		for _, attr := range node.Attrs {
+			if (prefix == "" && attr.Name.Space == "" && attr.Name.Local == xmlnsAttrPrefix) ||
+				(prefix != "" && attr.Name.Space == xmlnsAttrPrefix && attr.Name.Local == prefix) {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Is checks the namespace and the local name of the element
+func (n *xmlNode) Is(space, local string) bool {
+	return n.Name.Local == local && n.namespace(n.Name.Space) == space
+}
+
+// Attr returns the value of an unprefixed attribute
+func (n *xmlNode) Attr(name string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Child returns the first child element with the name or nil
+func (n *xmlNode) Child(space, local string) *xmlNode {
+	for _, child := range n.Elements(space, local) {
+		return child
+	}
+	return nil
+}
+
+// Elements returns the child elements with the name
+func (n *xmlNode) Elements(space, local string) []*xmlNode {
+	var elements []*xmlNode
+	for _, child := range n.Children {
+		if node, ok := child.(*xmlNode); ok && node.Is(space, local) {
+			elements = append(elements, node)
+		}
+	}
+	return elements
+}
+
+// Text returns the concatenated character data of the element
+func (n *xmlNode) Text() string {
+	var text strings.Builder
+	for _, child := range n.Children {
+		if data, ok := child.(xml.CharData); ok {
+			text.Write(data)
+		}
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// canonicalize serializes the element with Exclusive XML Canonicalization (without comments),
+// the exclude element (the enveloped signature) is left out
+func canonicalize(n *xmlNode, inclusivePrefixes []string, exclude *xmlNode) []byte {
+	var buf bytes.Buffer
+	c14nElement(&buf, n, map[string]string{"": ""}, inclusivePrefixes, exclude)
+	return buf.Bytes()
+}
+
+func c14nElement(buf *bytes.Buffer, n *xmlNode, rendered map[string]string, inclusivePrefixes []string, exclude *xmlNode) {
+	// The namespaces visibly utilized by the element and its attributes, and the inclusive ones
+	prefixes := map[string]bool{n.Name.Space: true}
+	var attrs []xml.Attr
+	for _, attr := range n.Attrs {
+		if attr.Name.Space == xmlnsAttrPrefix || (attr.Name.Space == "" && attr.Name.Local == xmlnsAttrPrefix) {
+			continue
+		}
+		if attr.Name.Space != "" && attr.Name.Space != xmlPrefixReserved {
+			prefixes[attr.Name.Space] = true
+		}
+		attrs = append(attrs, attr)
+	}
+	for _, prefix := range inclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if prefix == "" || n.namespace(prefix) != "" {
+			prefixes[prefix] = true
+		}
+	}
+
+	scope := make(map[string]string, len(rendered))
+	for prefix, uri := range rendered {
+		scope[prefix] = uri
+	}
+	var declared []string
+	for prefix := range prefixes {
+		uri := n.namespace(prefix)
+		if rendered[prefix] != uri {
+			declared = append(declared, prefix)
+			scope[prefix] = uri
+		}
+	}
+	sort.Strings(declared)
+	sort.Slice(attrs, func(i, j int) bool {
+		si, sj := n.namespace(attrs[i].Name.Space), n.namespace(attrs[j].Name.Space)
+		if attrs[i].Name.Space == "" {
+			si = ""
+		}
+		if attrs[j].Name.Space == "" {
+			sj = ""
+		}
+		if si != sj {
+			return si < sj
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+
+	name := n.Name.Local
+	if n.Name.Space != "" {
+		name = n.Name.Space + ":" + name
+	}
+	buf.WriteString("<" + name)
+	for _, prefix := range declared {
+		if prefix == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(" xmlns:" + prefix + `="`)
+		}
+		c14nEscapeAttr(buf, scope[prefix])
+		buf.WriteString(`"`)
+	}
+	for _, attr := range attrs {
+		attrName := attr.Name.Local
+		if attr.Name.Space != "" {
+			attrName = attr.Name.Space + ":" + attrName
+		}
+		buf.WriteString(" " + attrName + `="`)
+		c14nEscapeAttr(buf, attr.Value)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+	for _, child := range n.Children {
+		switch c := child.(type) {
+		case *xmlNode:
+			if c != exclude {
+				c14nElement(buf, c, scope, inclusivePrefixes, exclude)
+			}
+		case xml.CharData:
+			c14nEscapeText(buf, string(c))
+		}
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+func c14nEscapeText(buf *bytes.Buffer, s string) {
+	strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;").WriteString(buf, s)
+}
+
+func c14nEscapeAttr(buf *bytes.Buffer, s string) {
+	strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;").WriteString(buf, s)
+}
+
+// verifyEnvelopedSignature checks the enveloped signature of the element made with the certificate,
+// the signature must reference the element itself by its ID attribute
+func verifyEnvelopedSignature(n *xmlNode, cert *x509.Certificate) error {
+	signature := n.Child(xmlnsDSig, "Signature")
+	if signature == nil {
+		return errors.New("missing signature")
+	}
+	signedInfo := signature.Child(xmlnsDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("missing SignedInfo")
+	}
+	if method := signedInfo.Child(xmlnsDSig, "CanonicalizationMethod"); method == nil || method.Attr("Algorithm") != xmlExcC14N {
+		return errors.New("unsupported canonicalization method")
+	}
+	references := signedInfo.Elements(xmlnsDSig, "Reference")
+	if len(references) != 1 {
+		return errors.New("exactly one signature reference is required")
+	}
+	reference := references[0]
+	if id := n.Attr("ID"); id == "" || reference.Attr("URI") != "#"+id {
+		return errors.New("the signature doesn't reference the signed element")
+	}
+
+	var inclusivePrefixes []string
+	if transforms := reference.Child(xmlnsDSig, "Transforms"); transforms != nil {
+		for _, transform := range transforms.Elements(xmlnsDSig, "Transform") {
+			switch transform.Attr("Algorithm") {
+			case xmlEnvelopedSig:
+			case xmlExcC14N:
+				for _, child := range transform.Children {
+					if node, ok := child.(*xmlNode); ok && node.Is(xmlExcC14N, "InclusiveNamespaces") {
+						inclusivePrefixes = strings.Fields(node.Attr("PrefixList"))
+					}
+				}
+			default:
+				return fmt.Errorf("unsupported transform: %s", transform.Attr("Algorithm"))
+			}
+		}
+	}
+
+	digestHash, err := xmlDSigHash(reference.Child(xmlnsDSig, "DigestMethod"), xmlDSigSHA1, xmlDSigSHA256)
+	if err != nil {
+		return err
+	}
+	digestValue := reference.Child(xmlnsDSig, "DigestValue")
+	if digestValue == nil {
+		return errors.New("missing digest value")
+	}
+	expectedDigest, err := base64.StdEncoding.DecodeString(digestValue.Text())
+	if err != nil {
+		return err
+	}
+	h := digestHash.New()
+	h.Write(canonicalize(n, inclusivePrefixes, signature))
+	if !bytes.Equal(h.Sum(nil), expectedDigest) {
+		return errors.New("digest mismatch")
+	}
+
+	signatureHash, err := xmlDSigHash(signedInfo.Child(xmlnsDSig, "SignatureMethod"), xmlDSigRSASHA1, xmlDSigRSASHA256)
+	if err != nil {
+		return err
+	}
+	signatureValue := signature.Child(xmlnsDSig, "SignatureValue")
+	if signatureValue == nil {
+		return errors.New("missing signature value")
+	}
+	rawSignature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(signatureValue.Text()), ""))
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("the signing certificate doesn't have an RSA key")
+	}
+	var signedInfoInclusivePrefixes []string
+	if method := signedInfo.Child(xmlnsDSig, "CanonicalizationMethod"); method != nil {
+		if inclusive := method.Child(xmlExcC14N, "InclusiveNamespaces"); inclusive != nil {
+			signedInfoInclusivePrefixes = strings.Fields(inclusive.Attr("PrefixList"))
+		}
+	}
+	h = signatureHash.New()
+	h.Write(canonicalize(signedInfo, signedInfoInclusivePrefixes, nil))
+	return rsa.VerifyPKCS1v15(publicKey, signatureHash, h.Sum(nil), rawSignature)
+}
+
+// xmlDSigHash returns the hash of a DigestMethod or SignatureMethod, sha1Alg and sha256Alg are the accepted algorithms
+func xmlDSigHash(method *xmlNode, sha1Alg, sha256Alg string) (crypto.Hash, error) {
+	if method == nil {
+		return 0, errors.New("missing digest or signature method")
+	}
+	switch method.Attr("Algorithm") {
+	case sha1Alg:
+		return crypto.SHA1, nil
+	case sha256Alg:
+		return crypto.SHA256, nil
+	}
+	return 0, fmt.Errorf("unsupported algorithm: %s", method.Attr("Algorithm"))
+}
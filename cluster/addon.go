@@ -0,0 +1,380 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/ghodss/yaml"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Add-ons of the catalog
+const (
+	AddonIngress     = "ingress"
+	AddonMonitoring  = "monitoring"
+	AddonLogging     = "logging"
+	AddonCertManager = "cert-manager"
+	AddonAutoscaler  = "autoscaler"
+)
+
+//Addon describes an add-on of the catalog: the chart and the version of the chart managed by Pipeline, and the
+//add-ons it depends on. The charts and the versions are configured in the addons section of the config.
+type Addon struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Chart        string   `json:"chart"`
+	Version      string   `json:"version"`
+	ReleaseName  string   `json:"releaseName"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	// prepare enables the add-on on the cluster before its chart is installed
+	prepare func(CommonCluster) error
+	// values returns the values of the chart for the cluster
+	values func(CommonCluster) (map[string]interface{}, error)
+	// configure sets up the add-on after its chart is installed or upgraded
+	configure func(CommonCluster) error
+	// disable reverts prepare after the add-on is removed
+	disable func(CommonCluster) error
+}
+
+//AddonStatus describes an add-on of the catalog on a cluster, the add-on can be upgraded if the version of
+//the installed chart isn't the version of the catalog
+type AddonStatus struct {
+	Addon
+	Installed        *model.AddonModel `json:"installed,omitempty"`
+	UpgradeAvailable bool              `json:"upgradeAvailable"`
+}
+
+//AddonCatalog returns the add-ons installable on the clusters
+func AddonCatalog() []Addon {
+	return []Addon{
+		{
+			Name:        AddonIngress,
+			Description: "Ingress controller of the deployments",
+			Chart:       viper.GetString("addons.ingress.chart"),
+			Version:     viper.GetString("addons.ingress.version"),
+			ReleaseName: "pipeline",
+		},
+		{
+			Name:        AddonMonitoring,
+			Description: "Prometheus monitoring stack",
+			Chart:       viper.GetString("addons.monitoring.chart"),
+			Version:     viper.GetString("addons.monitoring.version"),
+			ReleaseName: "monitoring",
+			values:      monitoringValues,
+			configure:   configureMonitoring,
+			disable:     disableMonitoring,
+		},
+		{
+			Name:         AddonGrafana,
+			Description:  "Grafana dashboards of the Prometheus monitoring stack",
+			Chart:        viper.GetString("addons.grafana.chart"),
+			Version:      viper.GetString("addons.grafana.version"),
+			ReleaseName:  "grafana",
+			Dependencies: []string{AddonMonitoring},
+			prepare:      prepareGrafana,
+			values:       grafanaValues,
+			disable:      disableGrafana,
+		},
+		{
+			Name:        AddonLogging,
+			Description: "Fluent Bit log collector on every node",
+			Chart:       viper.GetString("addons.logging.chart"),
+			Version:     viper.GetString("addons.logging.version"),
+			ReleaseName: "logging",
+			prepare:     prepareLogging,
+			values:      loggingValues,
+		},
+		{
+			Name:         AddonCertManager,
+			Description:  "Certificates of the ingresses from ACME issuers",
+			Chart:        viper.GetString("addons.cert-manager.chart"),
+			Version:      viper.GetString("addons.cert-manager.version"),
+			ReleaseName:  "cert-manager",
+			Dependencies: []string{AddonIngress},
+			values: func(CommonCluster) (map[string]interface{}, error) {
+				return map[string]interface{}{"rbac": map[string]interface{}{"create": true}}, nil
+			},
+			configure: configureCertManager,
+		},
+		{
+			Name:         AddonExternalDNS,
+			Description:  "DNS records of the ingress hosts in the DNS zone of Pipeline",
+			Chart:        viper.GetString("addons.external-dns.chart"),
+			Version:      viper.GetString("addons.external-dns.version"),
+			ReleaseName:  "external-dns",
+			Dependencies: []string{AddonIngress},
+			prepare:      prepareExternalDNS,
+			values:       externalDNSValues,
+		},
+		{
+			Name:        AddonAutoscaler,
+			Description: "Cluster autoscaler of the autoscaled node pools",
+			Chart:       viper.GetString("autoscaler.chart"),
+			Version:     viper.GetString("addons.autoscaler.version"),
+			ReleaseName: viper.GetString("autoscaler.release"),
+			prepare:     prepareClusterAutoscaler,
+			values:      autoscalerValues,
+			disable: func(commonCluster CommonCluster) error {
+				SetClusterAutoscaler(commonCluster, false)
+				return commonCluster.Persist()
+			},
+		},
+		{
+			Name:        AddonBackup,
+			Description: "Velero backups of the workloads to the backup bucket of the cluster",
+			Chart:       viper.GetString("addons.backup.chart"),
+			Version:     viper.GetString("addons.backup.version"),
+			ReleaseName: "velero",
+			prepare:     prepareBackup,
+			values:      backupValues,
+		},
+		{
+			Name:         AddonMetricsAdapter,
+			Description:  "Custom and external metrics of the autoscaling policies from Prometheus",
+			Chart:        viper.GetString("addons.prometheus-adapter.chart"),
+			Version:      viper.GetString("addons.prometheus-adapter.version"),
+			ReleaseName:  "prometheus-adapter",
+			Dependencies: []string{AddonMonitoring},
+			values:       metricsAdapterValues,
+		},
+		{
+			Name:        AddonVPA,
+			Description: "Vertical pod autoscaler recommending the resource requests of the deployments",
+			Chart:       viper.GetString("addons.vpa.chart"),
+			Version:     viper.GetString("addons.vpa.version"),
+			ReleaseName: "vpa",
+			values: func(CommonCluster) (map[string]interface{}, error) {
+				return map[string]interface{}{
+					"recommender":         map[string]interface{}{"enabled": true},
+					"updater":             map[string]interface{}{"enabled": false},
+					"admissionController": map[string]interface{}{"enabled": false},
+				}, nil
+			},
+		},
+	}
+}
+
+//GetCatalogAddon returns the add-on of the catalog, ok is false if there's no add-on with the name
+func GetCatalogAddon(name string) (addon Addon, ok bool) {
+	for _, addon := range AddonCatalog() {
+		if addon.Name == name {
+			return addon, true
+		}
+	}
+	return Addon{}, false
+}
+
+//ResolveAddon returns the add-on with its dependencies, every add-on is listed after its dependencies
+func ResolveAddon(name string) ([]Addon, error) {
+	resolved := []Addon{}
+	visited := map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		for _, p := range path {
+			if p == name {
+				return fmt.Errorf("circular add-on dependency: %v", append(path, name))
+			}
+		}
+		if visited[name] {
+			return nil
+		}
+		addon, ok := GetCatalogAddon(name)
+		if !ok {
+			return fmt.Errorf("unknown add-on: %s", name)
+		}
+		for _, dependency := range addon.Dependencies {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		visited[name] = true
+		resolved = append(resolved, addon)
+		return nil
+	}
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+//ListAddons returns the add-ons of the catalog with their installed versions on the cluster
+func ListAddons(commonCluster CommonCluster) ([]AddonStatus, error) {
+	installed, err := model.ListAddons(commonCluster.GetID())
+	if err != nil {
+		return nil, err
+	}
+	statuses := []AddonStatus{}
+	for _, addon := range AddonCatalog() {
+		status := AddonStatus{Addon: addon}
+		for i := range installed {
+			if installed[i].Name == addon.Name {
+				status.Installed = &installed[i]
+				status.UpgradeAvailable = addon.Version != "" && installed[i].Version != addon.Version
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+//InstallAddon installs the add-on and the add-ons it depends on if they aren't installed yet,
+//it returns the add-ons installed by the call
+func InstallAddon(commonCluster CommonCluster, name string) ([]model.AddonModel, error) {
+	log := logger.WithFields(logrus.Fields{"action": "InstallAddon"})
+	addons, err := ResolveAddon(name)
+	if err != nil {
+		return nil, err
+	}
+	installed := []model.AddonModel{}
+	for _, addon := range addons {
+		if _, err := model.GetAddon(commonCluster.GetID(), addon.Name); err == nil {
+			continue
+		} else if !model.IsErrorGormNotFound(err) {
+			return installed, err
+		}
+		log.Infof("Installing add-on %s (%s %s)", addon.Name, addon.Chart, addon.Version)
+		installedAddon, err := installAddon(commonCluster, addon)
+		if err != nil {
+			return installed, fmt.Errorf("error installing add-on %s: %s", addon.Name, err.Error())
+		}
+		installed = append(installed, *installedAddon)
+	}
+	return installed, nil
+}
+
+// installAddon installs the chart of the add-on and records its version
+func installAddon(commonCluster CommonCluster, addon Addon) (*model.AddonModel, error) {
+	if addon.prepare != nil {
+		if err := addon.prepare(commonCluster); err != nil {
+			return nil, err
+		}
+	}
+	values, err := addonValues(commonCluster, addon)
+	if err != nil {
+		return nil, err
+	}
+	valueOverrides, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	release, err := helm.CreateDeploymentVersion(addon.Chart, addon.Version, addon.ReleaseName, valueOverrides, kubeConfig, commonCluster.GetName())
+	if err != nil {
+		return nil, err
+	}
+	installed := &model.AddonModel{
+		ClusterModelID: commonCluster.GetID(),
+		Name:           addon.Name,
+		ReleaseName:    addon.ReleaseName,
+		Chart:          addon.Chart,
+		Version:        release.GetRelease().GetChart().GetMetadata().GetVersion(),
+	}
+	if err := installed.Save(); err != nil {
+		return nil, err
+	}
+	if addon.configure != nil {
+		return installed, addon.configure(commonCluster)
+	}
+	return installed, nil
+}
+
+//UpgradeAddon upgrades the installed add-on to the version of the catalog
+func UpgradeAddon(commonCluster CommonCluster, name string) (*model.AddonModel, error) {
+	addon, ok := GetCatalogAddon(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown add-on: %s", name)
+	}
+	installed, err := model.GetAddon(commonCluster.GetID(), name)
+	if err != nil {
+		return nil, err
+	}
+	if addon.Version != "" && installed.Version == addon.Version {
+		return installed, nil
+	}
+	return upgradeAddon(commonCluster, addon, installed, addon.Chart, addon.Version)
+}
+
+//ReconfigureAddon upgrades the release of the installed add-on with the current values of the add-on for the
+//cluster, the chart and its version aren't changed
+func ReconfigureAddon(commonCluster CommonCluster, name string) (*model.AddonModel, error) {
+	addon, ok := GetCatalogAddon(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown add-on: %s", name)
+	}
+	installed, err := model.GetAddon(commonCluster.GetID(), name)
+	if err != nil {
+		return nil, err
+	}
+	return upgradeAddon(commonCluster, addon, installed, installed.Chart, installed.Version)
+}
+
+// upgradeAddon upgrades the release of the installed add-on to the version of the chart
+func upgradeAddon(commonCluster CommonCluster, addon Addon, installed *model.AddonModel, chart, version string) (*model.AddonModel, error) {
+	values, err := addonValues(commonCluster, addon)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	upgrade, err := helm.UpgradeDeploymentVersion(installed.ReleaseName, chart, version, values, kubeConfig, commonCluster.GetName())
+	if err != nil {
+		return nil, err
+	}
+	installed.Chart = chart
+	installed.Version = upgrade.GetRelease().GetChart().GetMetadata().GetVersion()
+	if err := installed.Save(); err != nil {
+		return nil, err
+	}
+	if addon.configure != nil {
+		return installed, addon.configure(commonCluster)
+	}
+	return installed, nil
+}
+
+//DeleteAddon removes the installed add-on, the add-ons depending on it must be removed first
+func DeleteAddon(commonCluster CommonCluster, name string) error {
+	installed, err := model.GetAddon(commonCluster.GetID(), name)
+	if err != nil {
+		return err
+	}
+	all, err := model.ListAddons(commonCluster.GetID())
+	if err != nil {
+		return err
+	}
+	for _, other := range all {
+		dependent, _ := GetCatalogAddon(other.Name)
+		for _, dependency := range dependent.Dependencies {
+			if dependency == name {
+				return fmt.Errorf("add-on %s depends on %s", other.Name, name)
+			}
+		}
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	if err := helm.DeleteDeployment(installed.ReleaseName, kubeConfig); err != nil {
+		return err
+	}
+	if addon, ok := GetCatalogAddon(name); ok && addon.disable != nil {
+		if err := addon.disable(commonCluster); err != nil {
+			return err
+		}
+	}
+	return installed.Delete()
+}
+
+// addonValues returns the chart values of the add-on for the cluster
+func addonValues(commonCluster CommonCluster, addon Addon) (map[string]interface{}, error) {
+	if addon.values == nil {
+		return map[string]interface{}{}, nil
+	}
+	return addon.values(commonCluster)
+}
@@ -0,0 +1,45 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestResolveAddon(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		addon       string
+		expected    []string
+		expectError bool
+	}{
+		{name: "no dependencies", addon: cluster.AddonMonitoring, expected: []string{cluster.AddonMonitoring}},
+		{name: "dependencies first", addon: cluster.AddonCertManager, expected: []string{cluster.AddonIngress, cluster.AddonCertManager}},
+		{name: "monitoring stack", addon: cluster.AddonGrafana, expected: []string{cluster.AddonMonitoring, cluster.AddonGrafana}},
+		{name: "unknown add-on", addon: "service-mesh", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			addons, err := cluster.ResolveAddon(tc.addon)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during ResolveAddon: %s", err.Error())
+			}
+			if len(addons) != len(tc.expected) {
+				t.Fatalf("Expected add-ons: %v, got: %v", tc.expected, addons)
+			}
+			for i, addon := range addons {
+				if addon.Name != tc.expected[i] {
+					t.Errorf("Expected add-on: %s, got: %s", tc.expected[i], addon.Name)
+				}
+			}
+		})
+	}
+}
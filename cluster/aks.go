@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	azureClient "github.com/banzaicloud/azure-aks-client/client"
 	azureCluster "github.com/banzaicloud/azure-aks-client/cluster"
 	"github.com/banzaicloud/banzai-types/components"
@@ -89,7 +90,8 @@ func (c *AKSCluster) GetAPIEndpoint() (string, error) {
 	return c.APIEndpoint, nil
 }
 
-//CreateCluster creates a new cluster
+//CreateCluster creates a new cluster, it returns when Azure accepted the cluster,
+//WaitForCluster waits for the provisioning
 func (c *AKSCluster) CreateCluster() error {
 
 	log := logger.WithFields(logrus.Fields{"action": constants.TagCreateCluster})
@@ -103,42 +105,164 @@ func (c *AKSCluster) CreateCluster() error {
 		AgentName:         c.modelCluster.Azure.AgentName,
 		KubernetesVersion: c.modelCluster.Azure.KubernetesVersion,
 	}
-	client, err := c.GetAKSClient()
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return err
+	}
+	management, err := newAKSManagement(clusterSecret)
 	if err != nil {
 		return err
 	}
 
-	client.With(log.Logger)
+	// the node pools of the request, or the single pool of the agent properties
+	if len(c.modelCluster.NodePools) == 0 {
+		c.modelCluster.NodePools = []model.NodePoolModel{{
+			Name:         r.AgentName,
+			InstanceType: r.VMSize,
+			Count:        r.AgentCount,
+		}}
+	}
 
-	// call creation
-	createdCluster, err := client.CreateUpdateCluster(r)
+	versions, err := management.kubernetesVersions(r.Location)
+	if err != nil {
+		log.Warnf("Kubernetes version %s isn't validated: %s", r.KubernetesVersion, err.Error())
+	} else if r.KubernetesVersion == "" {
+		r.KubernetesVersion = versions.Default
+		c.modelCluster.Azure.KubernetesVersion = versions.Default
+	} else if err := versions.supports(r.KubernetesVersion); err != nil {
+		return err
+	}
+
+	created, err := management.ensureResourceGroup(r.ResourceGroup, r.Location)
 	if err != nil {
-		// creation failed
-		// todo status code!??
 		return err
 	}
-	// creation success
-	log.Info("Cluster created successfully!")
+	if created {
+		log.Infof("Resource group %s created", r.ResourceGroup)
+	}
+	c.modelCluster.Azure.ResourceGroupCreated = created
+
+	managedCluster := azureCluster.GetManagedCluster(r, clusterSecret.Values[azureCluster.AzureClientId], clusterSecret.Values[azureCluster.AzureClientSecret])
+	managedCluster.Properties.AgentPoolProfiles = c.agentPoolProfiles()
 
-	c.azureCluster = &createdCluster.Value
+	// call creation
+	var body map[string]interface{}
+	err = c.setLinuxProfile(managedCluster)
+	if err == nil {
+		body, err = c.managedClusterBody(managedCluster)
+	}
+	if err == nil {
+		err = management.createOrUpdateManagedCluster(r.Name, r.ResourceGroup, body)
+	}
+	if err != nil {
+		if created {
+			management.deleteResourceGroup(r.ResourceGroup)
+		}
+		return err
+	}
+	log.Info("Cluster creation accepted")
 
 	// save to database
 	if err := c.Persist(); err != nil {
 		log.Errorf("Cluster save failed! %s", err.Error())
 	}
+	return nil
+}
+
+//WaitForCluster polls the cluster until it's provisioned
+func (c *AKSCluster) WaitForCluster() error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagCreateCluster})
+	client, err := c.GetAKSClient()
+	if err != nil {
+		return err
+	}
+
+	client.With(log.Logger)
 
-	// polling cluster
-	pollingResult, err := client.PollingCluster(r.Name, r.ResourceGroup)
+	pollingResult, err := client.PollingCluster(c.modelCluster.Name, c.modelCluster.Azure.ResourceGroup)
 	if err != nil {
-		// polling error
-		// todo status code!??
 		return err
 	}
 	log.Info("Cluster is ready...")
 	c.azureCluster = &pollingResult.Value
+
+	// the agent pools don't have labels, the nodes are labelled through Kubernetes
+	for _, pool := range c.modelCluster.NodePools {
+		labels := c.modelCluster.NodeLabels(pool)
+		if len(labels) == 0 {
+			continue
+		}
+		if err := labelNodes(c, c.NodePoolSelector(pool.Name), nil, labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setLinuxProfile authorizes the SSH key of the cluster on the agents, the clusters without a key have the default profile
+func (c *AKSCluster) setLinuxProfile(managedCluster *azureCluster.ManagedCluster) error {
+	key, err := GetSSHKey(c)
+	if err != nil || key == nil {
+		return err
+	}
+	managedCluster.Properties.LinuxProfile = azureCluster.LinuxProfile{
+		AdminUsername: key.User,
+		SSH: azureCluster.SSH{
+			PublicKeys: &[]azureCluster.SSHPublicKey{{KeyData: &key.PublicKey}},
+		},
+	}
 	return nil
 }
 
+// managedClusterBody returns the body of the managed cluster with the network and the tags of the cluster, the client
+// type doesn't have the subnet of the agent pools, the private cluster and the tags fields
+func (c *AKSCluster) managedClusterBody(managedCluster *azureCluster.ManagedCluster) (map[string]interface{}, error) {
+	data, err := json.Marshal(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	properties, _ := body["properties"].(map[string]interface{})
+	if properties == nil {
+		return body, nil
+	}
+	if tags := c.modelCluster.GetTags(); len(tags) != 0 {
+		body["tags"] = tags
+	}
+	if c.modelCluster.PrivateEndpoint {
+		properties["apiServerAccessProfile"] = map[string]interface{}{"enablePrivateCluster": true}
+	}
+	if c.modelCluster.Subnets != "" {
+		profiles, _ := properties["agentPoolProfiles"].([]interface{})
+		for _, profile := range profiles {
+			if profile, ok := profile.(map[string]interface{}); ok {
+				profile["vnetSubnetID"] = c.modelCluster.Subnets
+			}
+		}
+	}
+	return body, nil
+}
+
+// agentPoolProfiles returns the agent pools of the node pools
+func (c *AKSCluster) agentPoolProfiles() []azureCluster.AgentPoolProfiles {
+	profiles := make([]azureCluster.AgentPoolProfiles, 0, len(c.modelCluster.NodePools))
+	for _, pool := range c.modelCluster.NodePools {
+		profiles = append(profiles, azureCluster.AgentPoolProfiles{
+			Name:   pool.Name,
+			Count:  pool.Count,
+			VMSize: pool.InstanceType,
+		})
+	}
+	return profiles
+}
+
 //Persist save the cluster model
 func (c *AKSCluster) Persist() error {
 	return c.modelCluster.Save()
@@ -149,6 +273,10 @@ func (c *AKSCluster) GetK8sConfig() (*[]byte, error) {
 	if c.k8sConfig != nil {
 		return c.k8sConfig, nil
 	}
+	if config := loadK8sConfig(c.modelCluster); config != nil {
+		c.k8sConfig = config
+		return c.k8sConfig, nil
+	}
 	client, err := c.GetAKSClient()
 	if err != nil {
 		return nil, err
@@ -169,6 +297,7 @@ func (c *AKSCluster) GetK8sConfig() (*[]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	storeK8sConfig(c.modelCluster, decodedConfig)
 	c.k8sConfig = &decodedConfig
 	return &decodedConfig, nil
 }
@@ -200,19 +329,24 @@ func (c *AKSCluster) GetStatus() (*bTypes.GetClusterStatusResponse, error) {
 	log.Info("Get cluster success")
 	stage := resp.Value.Properties.ProvisioningState
 	log.Info("Cluster stage is", stage)
-	if stage == "Succeeded" {
-		response := &components.GetClusterStatusResponse{
-			Status:           http.StatusOK,
-			Name:             c.modelCluster.Name,
-			Location:         c.modelCluster.Location,
-			Cloud:            c.modelCluster.Cloud,
-			NodeInstanceType: c.modelCluster.NodeInstanceType,
-			ResourceID:       c.modelCluster.ID,
-		}
-
+	response := &components.GetClusterStatusResponse{
+		Status:           http.StatusOK,
+		Name:             c.modelCluster.Name,
+		Location:         c.modelCluster.Location,
+		Cloud:            c.modelCluster.Cloud,
+		NodeInstanceType: c.modelCluster.NodeInstanceType,
+		ResourceID:       c.modelCluster.ID,
+	}
+	switch stage {
+	case "Succeeded":
+		return response, nil
+	case "Failed":
+		return nil, constants.ErrorAzureCLusterStageFailed
+	default:
+		// the cluster is being provisioned
+		response.Status = http.StatusAccepted
 		return response, nil
 	}
-	return nil, constants.ErrorClusterNotReady
 }
 
 // DeleteCluster deletes cluster from aks
@@ -231,11 +365,23 @@ func (c *AKSCluster) DeleteCluster() error {
 
 	err = client.DeleteCluster(c.modelCluster.Name, c.modelCluster.Azure.ResourceGroup)
 	if err != nil {
-		log.Info("Delete succeeded")
-		return nil
+		return err
+	}
+	log.Info("Delete succeeded")
+
+	if c.modelCluster.Azure.ResourceGroupCreated {
+		clusterSecret, err := GetSecret(c)
+		if err != nil {
+			return err
+		}
+		management, err := newAKSManagement(clusterSecret)
+		if err != nil {
+			return err
+		}
+		log.Infof("Deleting resource group %s", c.modelCluster.Azure.ResourceGroup)
+		management.deleteResourceGroup(c.modelCluster.Azure.ResourceGroup)
 	}
-	// todo status code !?
-	return err
+	return nil
 }
 
 // UpdateCluster updates AKS cluster in cloud
@@ -248,19 +394,20 @@ func (c *AKSCluster) UpdateCluster(request *bTypes.UpdateClusterRequest) error {
 
 	client.With(log.Logger)
 
-	ccr := azureCluster.CreateClusterRequest{
-		Name:              c.modelCluster.Name,
-		Location:          c.modelCluster.Location,
-		VMSize:            c.modelCluster.NodeInstanceType,
-		ResourceGroup:     c.modelCluster.Azure.ResourceGroup,
-		AgentCount:        request.UpdateClusterAzure.AgentCount,
-		AgentName:         c.modelCluster.Azure.AgentName,
-		KubernetesVersion: c.modelCluster.Azure.KubernetesVersion,
-	}
+	ccr := c.managedClusterRequest(request.UpdateClusterAzure.AgentCount)
 
-	updatedCluster, err := client.CreateUpdateCluster(ccr)
-	if err != nil {
-		return err
+	if len(c.modelCluster.NodePools) == 0 {
+		updatedCluster, err := client.CreateUpdateCluster(ccr)
+		if err != nil {
+			return err
+		}
+		c.azureCluster = &updatedCluster.Value
+	} else {
+		// the agent count is the size of the first node pool
+		c.modelCluster.NodePools[0].Count = request.UpdateClusterAzure.AgentCount
+		if err := c.updateNodePools(ccr); err != nil {
+			return err
+		}
 	}
 	log.Info("Cluster update succeeded")
 	//Update AWS model
@@ -272,17 +419,53 @@ func (c *AKSCluster) UpdateCluster(request *bTypes.UpdateClusterRequest) error {
 		NodeInstanceType: c.modelCluster.NodeInstanceType,
 		Cloud:            c.modelCluster.Cloud,
 		Azure: model.AzureClusterModel{
-			ResourceGroup:     c.modelCluster.Azure.ResourceGroup,
-			AgentCount:        request.UpdateClusterAzure.AgentCount,
-			AgentName:         c.modelCluster.Azure.AgentName,
-			KubernetesVersion: c.modelCluster.Azure.KubernetesVersion,
+			ResourceGroup:        c.modelCluster.Azure.ResourceGroup,
+			AgentCount:           request.UpdateClusterAzure.AgentCount,
+			AgentName:            c.modelCluster.Azure.AgentName,
+			KubernetesVersion:    c.modelCluster.Azure.KubernetesVersion,
+			ResourceGroupCreated: c.modelCluster.Azure.ResourceGroupCreated,
 		},
+		NodePools: c.modelCluster.NodePools,
 	}
 	c.modelCluster = updateCluster
-	c.azureCluster = &updatedCluster.Value
 	return nil
 }
 
+// managedClusterRequest returns the request of the managed cluster with the stored properties
+func (c *AKSCluster) managedClusterRequest(agentCount int) azureCluster.CreateClusterRequest {
+	return azureCluster.CreateClusterRequest{
+		Name:              c.modelCluster.Name,
+		Location:          c.modelCluster.Location,
+		VMSize:            c.modelCluster.NodeInstanceType,
+		ResourceGroup:     c.modelCluster.Azure.ResourceGroup,
+		AgentCount:        agentCount,
+		AgentName:         c.modelCluster.Azure.AgentName,
+		KubernetesVersion: c.modelCluster.Azure.KubernetesVersion,
+	}
+}
+
+// updateNodePools sends the managed cluster with every node pool to Azure
+func (c *AKSCluster) updateNodePools(r azureCluster.CreateClusterRequest) error {
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return err
+	}
+	management, err := newAKSManagement(clusterSecret)
+	if err != nil {
+		return err
+	}
+	managedCluster := azureCluster.GetManagedCluster(r, clusterSecret.Values[azureCluster.AzureClientId], clusterSecret.Values[azureCluster.AzureClientSecret])
+	managedCluster.Properties.AgentPoolProfiles = c.agentPoolProfiles()
+	if err := c.setLinuxProfile(managedCluster); err != nil {
+		return err
+	}
+	body, err := c.managedClusterBody(managedCluster)
+	if err != nil {
+		return err
+	}
+	return management.createOrUpdateManagedCluster(r.Name, r.ResourceGroup, body)
+}
+
 //GetID returns the specified cluster id
 func (c *AKSCluster) GetID() uint {
 	return c.modelCluster.ID
@@ -365,3 +548,143 @@ func (c *AKSCluster) DeleteFromDatabase() error {
 	c.modelCluster = nil
 	return nil
 }
+
+// applyNodePools sends every node pool of the model to Azure and waits for the provisioning of the cluster
+func (c *AKSCluster) applyNodePools() error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	if err := c.updateNodePools(c.managedClusterRequest(c.modelCluster.Azure.AgentCount)); err != nil {
+		return err
+	}
+	client, err := c.GetAKSClient()
+	if err != nil {
+		return err
+	}
+	client.With(log.Logger)
+	pollingResult, err := client.PollingCluster(c.modelCluster.Name, c.modelCluster.Azure.ResourceGroup)
+	if err != nil {
+		return err
+	}
+	c.azureCluster = &pollingResult.Value
+	return nil
+}
+
+//CreateNodePool adds the agent pool to the managed cluster and labels its nodes
+func (c *AKSCluster) CreateNodePool(pool model.NodePoolModel) error {
+	if err := c.applyNodePools(); err != nil {
+		return err
+	}
+	return labelNodes(c, c.NodePoolSelector(pool.Name), nil, c.modelCluster.NodeLabels(pool))
+}
+
+//UpdateNodePool resizes the agent pool and relabels its nodes
+func (c *AKSCluster) UpdateNodePool(previous, pool model.NodePoolModel) error {
+	if previous.Count != pool.Count {
+		if err := c.applyNodePools(); err != nil {
+			return err
+		}
+	}
+	return labelNodes(c, c.NodePoolSelector(pool.Name), c.modelCluster.NodeLabels(previous), c.modelCluster.NodeLabels(pool))
+}
+
+//DeleteNodePool removes the agent pool from the managed cluster
+func (c *AKSCluster) DeleteNodePool(pool model.NodePoolModel) error {
+	return c.applyNodePools()
+}
+
+//Suspend stops the managed cluster, the control plane and the nodes are stopped
+func (c *AKSCluster) Suspend() error {
+	return c.powerCluster("stop")
+}
+
+//Resume starts the stopped managed cluster
+func (c *AKSCluster) Resume() error {
+	return c.powerCluster("start")
+}
+
+// powerCluster stops or starts the managed cluster and waits for the provisioning of the cluster
+func (c *AKSCluster) powerCluster(action string) error {
+	log := logger.WithFields(logrus.Fields{"action": "Hibernation"})
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return err
+	}
+	management, err := newAKSManagement(clusterSecret)
+	if err != nil {
+		return err
+	}
+	if err := management.powerManagedCluster(c.modelCluster.Name, c.modelCluster.Azure.ResourceGroup, action); err != nil {
+		return err
+	}
+	client, err := c.GetAKSClient()
+	if err != nil {
+		return err
+	}
+	client.With(log.Logger)
+	pollingResult, err := client.PollingCluster(c.modelCluster.Name, c.modelCluster.Azure.ResourceGroup)
+	if err != nil {
+		return err
+	}
+	c.azureCluster = &pollingResult.Value
+	return nil
+}
+
+//NodePoolSelector returns the label selector of the nodes of the agent pool
+func (c *AKSCluster) NodePoolSelector(name string) string {
+	return "agentpool=" + name
+}
+
+//CheckNodeQuota checks the regional vCPU quota of the subscription
+func (c *AKSCluster) CheckNodeQuota(instanceType string, nodes int) error {
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return err
+	}
+	management, err := newAKSManagement(clusterSecret)
+	if err != nil {
+		return err
+	}
+	return management.checkCoreQuota(c.modelCluster.Location, instanceType, nodes)
+}
+
+//InstancePrice returns the hourly price of the virtual machine size in the location of the cluster from the Azure
+//Retail Prices API, the price of Spot virtual machines for spot pools
+func (c *AKSCluster) InstancePrice(instanceType string, spot bool) (float64, error) {
+	return azureRetailPrice(c.modelCluster.Location, instanceType, spot)
+}
+
+//KubernetesVersion returns the Kubernetes version of the managed cluster
+func (c *AKSCluster) KubernetesVersion() (string, error) {
+	if c.modelCluster.Azure.KubernetesVersion == "" {
+		return "", errors.Errorf("cluster %s has no Kubernetes version", c.modelCluster.Name)
+	}
+	return c.modelCluster.Azure.KubernetesVersion, nil
+}
+
+//UpgradeControlPlane upgrades the managed cluster, AKS upgrades the agent pools with the control plane
+func (c *AKSCluster) UpgradeControlPlane(version string) error {
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return err
+	}
+	management, err := newAKSManagement(clusterSecret)
+	if err != nil {
+		return err
+	}
+	if versions, err := management.kubernetesVersions(c.modelCluster.Location); err != nil {
+		log.Warnf("Kubernetes version %s isn't validated: %s", version, err.Error())
+	} else if err := versions.supports(version); err != nil {
+		return err
+	}
+	previous := c.modelCluster.Azure.KubernetesVersion
+	c.modelCluster.Azure.KubernetesVersion = version
+	if err := c.applyNodePools(); err != nil {
+		c.modelCluster.Azure.KubernetesVersion = previous
+		return err
+	}
+	return nil
+}
+
+//UpgradeNodePool has nothing to do, the agent pools are upgraded by UpgradeControlPlane
+func (c *AKSCluster) UpgradeNodePool(pool model.NodePoolModel, version string, maxSurge, maxUnavailable int) error {
+	return nil
+}
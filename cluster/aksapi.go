@@ -0,0 +1,259 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/arm/resources/resources"
+	"github.com/Azure/go-autorest/autorest"
+	azureCluster "github.com/banzaicloud/azure-aks-client/cluster"
+	"github.com/banzaicloud/azure-aks-client/utils"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+)
+
+const (
+	azureManagementURL       = "https://management.azure.com"
+	aksAPIVersion            = "2020-06-01"
+	aksPowerAPIVersion       = "2020-09-01"
+	aksOrchestratorsVersion  = "2017-09-30"
+	aksManagedClustersPath   = "/subscriptions/{subscription-id}/resourceGroups/{resourceGroup}/providers/Microsoft.ContainerService/managedClusters/{resourceName}"
+	aksOrchestratorsPath     = "/subscriptions/{subscription-id}/providers/Microsoft.ContainerService/locations/{location}/orchestrators"
+	aksOrchestratorsResource = "managedClusters"
+	computeAPIVersion        = "2017-12-01"
+	computeUsagesPath        = "/subscriptions/{subscription-id}/providers/Microsoft.Compute/locations/{location}/usages"
+	computeVMSizesPath       = "/subscriptions/{subscription-id}/providers/Microsoft.Compute/locations/{location}/vmSizes"
+)
+
+// aksManagement calls the parts of the Azure Resource Manager API the AKS client doesn't cover:
+// resource groups, managed clusters with several agent pools and the supported Kubernetes versions
+type aksManagement struct {
+	sdk *azureCluster.Sdk
+}
+
+func newAKSManagement(clusterSecret *secret.SecretsItemResponse) (*aksManagement, error) {
+	if clusterSecret.SecretType != secret.Azure {
+		return nil, errors.Errorf("missmatch secret type %s versus %s", clusterSecret.SecretType, secret.Azure)
+	}
+	sdk, err := azureCluster.Authenticate(&azureCluster.AKSCredential{
+		ClientId:       clusterSecret.Values[azureCluster.AzureClientId],
+		ClientSecret:   clusterSecret.Values[azureCluster.AzureClientSecret],
+		SubscriptionId: clusterSecret.Values[azureCluster.AzureSubscriptionId],
+		TenantId:       clusterSecret.Values[azureCluster.AzureTenantId],
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &aksManagement{sdk: sdk}, nil
+}
+
+// send sends the request to the Resource Manager and decodes the response into result (if it isn't nil)
+func (m *aksManagement) send(result interface{}, decorators ...autorest.PrepareDecorator) error {
+	groupClient := *m.sdk.ResourceGroup
+	decorators = append([]autorest.PrepareDecorator{groupClient.WithAuthorization(), autorest.WithBaseURL(azureManagementURL)}, decorators...)
+	req, err := autorest.Prepare(&http.Request{}, decorators...)
+	if err != nil {
+		return err
+	}
+	resp, err := autorest.SendWithSender(groupClient.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		return utils.CreateErrorFromValue(resp.StatusCode, value)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(value, result)
+}
+
+// ensureResourceGroup creates the resource group in the location if it doesn't exist,
+// it returns whether the group was created
+func (m *aksManagement) ensureResourceGroup(name, location string) (bool, error) {
+	resp, err := m.sdk.ResourceGroup.CheckExistence(name)
+	if err != nil {
+		return false, errors.Wrapf(err, "error checking resource group %s", name)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return false, nil
+	}
+	if _, err := m.sdk.ResourceGroup.CreateOrUpdate(name, resources.Group{Location: &location}); err != nil {
+		return false, errors.Wrapf(err, "error creating resource group %s", name)
+	}
+	return true, nil
+}
+
+// deleteResourceGroup deletes the resource group and everything in it, it doesn't wait for the deletion
+func (m *aksManagement) deleteResourceGroup(name string) {
+	_, errChan := m.sdk.ResourceGroup.Delete(name, nil)
+	go func() {
+		if err := <-errChan; err != nil {
+			log.Errorf("Error deleting resource group %s: %s", name, err.Error())
+		}
+	}()
+}
+
+// createOrUpdateManagedCluster sends the managed cluster body to Azure, the provisioning continues in the background
+func (m *aksManagement) createOrUpdateManagedCluster(name, resourceGroup string, managedCluster interface{}) error {
+	return m.send(nil,
+		autorest.AsPut(),
+		autorest.WithPathParameters(aksManagedClustersPath, map[string]interface{}{
+			"subscription-id": m.sdk.ServicePrincipal.SubscriptionID,
+			"resourceGroup":   resourceGroup,
+			"resourceName":    name,
+		}),
+		autorest.WithQueryParameters(map[string]interface{}{"api-version": aksAPIVersion}),
+		autorest.WithJSON(managedCluster),
+		autorest.AsContentType("application/json"),
+	)
+}
+
+// powerManagedCluster stops or starts the managed cluster (the action is stop or start), the nodes
+// are deallocated while the cluster is stopped
+func (m *aksManagement) powerManagedCluster(name, resourceGroup, action string) error {
+	return m.send(nil,
+		autorest.AsPost(),
+		autorest.WithPathParameters(aksManagedClustersPath+"/"+action, map[string]interface{}{
+			"subscription-id": m.sdk.ServicePrincipal.SubscriptionID,
+			"resourceGroup":   resourceGroup,
+			"resourceName":    name,
+		}),
+		autorest.WithQueryParameters(map[string]interface{}{"api-version": aksPowerAPIVersion}),
+	)
+}
+
+// computeRequest returns the decorators of a request of the compute resources of the location
+func (m *aksManagement) computeRequest(path, location string) []autorest.PrepareDecorator {
+	return []autorest.PrepareDecorator{
+		autorest.AsGet(),
+		autorest.WithPathParameters(path, map[string]interface{}{
+			"subscription-id": m.sdk.ServicePrincipal.SubscriptionID,
+			"location":        location,
+		}),
+		autorest.WithQueryParameters(map[string]interface{}{"api-version": computeAPIVersion}),
+	}
+}
+
+// checkCoreQuota checks whether the regional vCPU quota of the subscription allows the new virtual machines
+func (m *aksManagement) checkCoreQuota(location, vmSize string, count int) error {
+	var sizes struct {
+		Value []struct {
+			Name          string `json:"name"`
+			NumberOfCores int    `json:"numberOfCores"`
+		} `json:"value"`
+	}
+	if err := m.send(&sizes, m.computeRequest(computeVMSizesPath, location)...); err != nil {
+		return errors.Wrapf(err, "error listing virtual machine sizes in %s", location)
+	}
+	cores := 0
+	for _, size := range sizes.Value {
+		if strings.EqualFold(size.Name, vmSize) {
+			cores = size.NumberOfCores
+		}
+	}
+	if cores == 0 {
+		return fmt.Errorf("virtual machine size %s is not available in %s", vmSize, location)
+	}
+
+	var usages struct {
+		Value []struct {
+			Name struct {
+				Value string `json:"value"`
+			} `json:"name"`
+			CurrentValue int `json:"currentValue"`
+			Limit        int `json:"limit"`
+		} `json:"value"`
+	}
+	if err := m.send(&usages, m.computeRequest(computeUsagesPath, location)...); err != nil {
+		return errors.Wrapf(err, "error listing compute usages in %s", location)
+	}
+	for _, usage := range usages.Value {
+		if usage.Name.Value == "cores" && usage.CurrentValue+cores*count > usage.Limit {
+			return fmt.Errorf("%d %s virtual machines exceed the vCPU quota of %s, %d of %d vCPUs are used",
+				count, vmSize, location, usage.CurrentValue, usage.Limit)
+		}
+	}
+	return nil
+}
+
+// AKSVersions are the Kubernetes versions AKS supports in a location
+type AKSVersions struct {
+	Location string   `json:"location"`
+	Default  string   `json:"default"`
+	Versions []string `json:"versions"`
+}
+
+// kubernetesVersions lists the Kubernetes versions of the managed clusters in the location
+func (m *aksManagement) kubernetesVersions(location string) (*AKSVersions, error) {
+	var response struct {
+		Properties struct {
+			Orchestrators []struct {
+				OrchestratorType    string `json:"orchestratorType"`
+				OrchestratorVersion string `json:"orchestratorVersion"`
+				Default             bool   `json:"default"`
+			} `json:"orchestrators"`
+		} `json:"properties"`
+	}
+	err := m.send(&response,
+		autorest.AsGet(),
+		autorest.WithPathParameters(aksOrchestratorsPath, map[string]interface{}{
+			"subscription-id": m.sdk.ServicePrincipal.SubscriptionID,
+			"location":        location,
+		}),
+		autorest.WithQueryParameters(map[string]interface{}{
+			"api-version":   aksOrchestratorsVersion,
+			"resource-type": aksOrchestratorsResource,
+		}),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing Kubernetes versions in %s", location)
+	}
+	versions := &AKSVersions{Location: location, Versions: []string{}}
+	for _, orchestrator := range response.Properties.Orchestrators {
+		if orchestrator.OrchestratorType != "Kubernetes" {
+			continue
+		}
+		versions.Versions = append(versions.Versions, orchestrator.OrchestratorVersion)
+		if orchestrator.Default {
+			versions.Default = orchestrator.OrchestratorVersion
+		}
+	}
+	sort.Strings(versions.Versions)
+	return versions, nil
+}
+
+// supports checks whether the version is supported, any version is if the list is empty
+func (versions *AKSVersions) supports(version string) error {
+	if len(versions.Versions) == 0 {
+		return nil
+	}
+	for _, supported := range versions.Versions {
+		if supported == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("Kubernetes version %s is not supported in %s, supported versions: %v", version, versions.Location, versions.Versions)
+}
+
+// GetAKSVersions returns the Kubernetes versions AKS supports in the location with the Azure secret of the organization
+func GetAKSVersions(organizationID, secretID, location string) (*AKSVersions, error) {
+	clusterSecret, err := secret.Store.Get(organizationID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	management, err := newAKSManagement(clusterSecret)
+	if err != nil {
+		return nil, err
+	}
+	return management.kubernetesVersions(location)
+}
@@ -0,0 +1,240 @@
+package cluster
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	promModel "github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Types of the Alertmanager receivers
+const (
+	ReceiverSlack     = "slack"
+	ReceiverPagerDuty = "pagerduty"
+	ReceiverEmail     = "email"
+	ReceiverWebhook   = "webhook"
+)
+
+// Severities of the alerting rules
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// defaultReceiver gets the alerts without a matching receiver
+const defaultReceiver = "default-receiver"
+
+// receiverSettings are the settings of the receiver types, the required settings are true
+var receiverSettings = map[string]map[string]bool{
+	ReceiverSlack:     {"webhookURL": true, "channel": false},
+	ReceiverPagerDuty: {"serviceKey": true},
+	ReceiverEmail:     {"to": true},
+	ReceiverWebhook:   {"url": true},
+}
+
+// alertNamePattern is the pattern of the names of the Prometheus alerts and the names of the labels
+var alertNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+//ValidateAlertRule checks the name, the duration and the severity of the alerting rule
+func ValidateAlertRule(rule model.AlertRuleModel) error {
+	if !alertNamePattern.MatchString(rule.Name) {
+		return fmt.Errorf("invalid alert name %q, it must match %s", rule.Name, alertNamePattern)
+	}
+	if strings.TrimSpace(rule.Expr) == "" {
+		return errors.New("the expression of the alert is empty")
+	}
+	if rule.For != "" {
+		if _, err := promModel.ParseDuration(rule.For); err != nil {
+			return fmt.Errorf("invalid duration %q: %s", rule.For, err.Error())
+		}
+	}
+	switch rule.Severity {
+	case SeverityCritical, SeverityWarning, SeverityInfo:
+	default:
+		return fmt.Errorf("the severity must be %s, %s or %s", SeverityCritical, SeverityWarning, SeverityInfo)
+	}
+	for name := range rule.GetLabels() {
+		if !alertNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+//ValidateAlertReceiver checks the settings of the type of the receiver and the labels it matches
+func ValidateAlertReceiver(receiver model.AlertReceiverModel) error {
+	if receiver.Name == "" || receiver.Name == defaultReceiver {
+		return fmt.Errorf("invalid receiver name %q", receiver.Name)
+	}
+	known, ok := receiverSettings[receiver.Type]
+	if !ok {
+		return fmt.Errorf("the type of the receiver must be %s, %s, %s or %s", ReceiverSlack, ReceiverPagerDuty, ReceiverEmail, ReceiverWebhook)
+	}
+	settings := receiver.GetSettings()
+	for name, required := range known {
+		if required && settings[name] == "" {
+			return fmt.Errorf("the %s receiver requires the %s setting", receiver.Type, name)
+		}
+	}
+	for name, value := range settings {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown setting of the %s receiver: %s", receiver.Type, name)
+		}
+		if name == "webhookURL" || name == "url" {
+			if u, err := url.ParseRequestURI(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("invalid %s: %s", name, value)
+			}
+		}
+	}
+	if receiver.Type == ReceiverEmail && viper.GetString("alerting.smtp.smarthost") == "" {
+		return errors.New("the SMTP server of the email receivers isn't configured")
+	}
+	for name := range receiver.GetMatchers() {
+		if !alertNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+//DeployAlerting updates the alerting rules and the receivers of the monitoring add-on of the cluster, or of
+//every cluster of the organization with the monitoring add-on if the cluster ID is 0
+func DeployAlerting(organizationID, clusterID uint) error {
+	log := logger.WithFields(logrus.Fields{"action": "DeployAlerting"})
+	var clusters []model.ClusterModel
+	query := model.ClusterModel{OrganizationId: organizationID}
+	query.ID = clusterID
+	if err := model.GetDB().Where(query).Find(&clusters).Error; err != nil {
+		return err
+	}
+	failed := []string{}
+	for i := range clusters {
+		if _, err := model.GetAddon(clusters[i].ID, AddonMonitoring); err != nil {
+			if !model.IsErrorGormNotFound(err) {
+				failed = append(failed, fmt.Sprintf("%s: %s", clusters[i].Name, err.Error()))
+			}
+			continue
+		}
+		commonCluster, err := GetCommonClusterFromModel(&clusters[i])
+		if err == nil {
+			_, err = ReconfigureAddon(commonCluster, AddonMonitoring)
+		}
+		if err != nil {
+			log.Errorf("Error deploying the alerting of cluster %s: %s", clusters[i].Name, err.Error())
+			failed = append(failed, fmt.Sprintf("%s: %s", clusters[i].Name, err.Error()))
+			continue
+		}
+		log.Infof("Alerting of cluster %s deployed", clusters[i].Name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error deploying the alerting of clusters: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// alertingValues returns the values of the Prometheus chart of the alerting rules and the receivers of the cluster
+func alertingValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	rules, err := model.ListAlertRules(commonCluster.GetOrg(), commonCluster.GetID())
+	if err != nil {
+		return nil, err
+	}
+	receivers, err := model.ListAlertReceivers(commonCluster.GetOrg(), commonCluster.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"serverFiles": map[string]interface{}{
+			"alerts": AlertingRules(rules),
+		},
+		"alertmanagerFiles": map[string]interface{}{
+			"alertmanager.yml": AlertmanagerConfig(receivers),
+		},
+	}, nil
+}
+
+//AlertingRules returns the Prometheus rule file of the alerting rules
+func AlertingRules(rules []model.AlertRuleModel) map[string]interface{} {
+	items := []interface{}{}
+	for _, rule := range rules {
+		labels := map[string]interface{}{}
+		for name, value := range rule.GetLabels() {
+			labels[name] = value
+		}
+		labels["severity"] = rule.Severity
+		item := map[string]interface{}{
+			"alert":  rule.Name,
+			"expr":   rule.Expr,
+			"labels": labels,
+			"annotations": map[string]interface{}{
+				"summary":     rule.Summary,
+				"description": rule.Description,
+			},
+		}
+		if rule.For != "" {
+			item["for"] = rule.For
+		}
+		items = append(items, item)
+	}
+	return map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{"name": "pipeline", "rules": items},
+		},
+	}
+}
+
+//AlertmanagerConfig returns the Alertmanager config routing the alerts matching the labels of the receivers
+//to the receivers, an alert can be routed to more receivers
+func AlertmanagerConfig(receivers []model.AlertReceiverModel) map[string]interface{} {
+	global := map[string]interface{}{}
+	if smarthost := viper.GetString("alerting.smtp.smarthost"); smarthost != "" {
+		global["smtp_smarthost"] = smarthost
+		global["smtp_from"] = viper.GetString("alerting.smtp.from")
+		global["smtp_auth_username"] = viper.GetString("alerting.smtp.username")
+		global["smtp_auth_password"] = viper.GetString("alerting.smtp.password")
+	}
+	routes := []interface{}{}
+	items := []interface{}{map[string]interface{}{"name": defaultReceiver}}
+	for _, receiver := range receivers {
+		settings := receiver.GetSettings()
+		item := map[string]interface{}{"name": receiver.Name}
+		switch receiver.Type {
+		case ReceiverSlack:
+			config := map[string]interface{}{"api_url": settings["webhookURL"], "send_resolved": true}
+			if settings["channel"] != "" {
+				config["channel"] = settings["channel"]
+			}
+			item["slack_configs"] = []interface{}{config}
+		case ReceiverPagerDuty:
+			item["pagerduty_configs"] = []interface{}{map[string]interface{}{"service_key": settings["serviceKey"]}}
+		case ReceiverEmail:
+			item["email_configs"] = []interface{}{map[string]interface{}{"to": settings["to"], "send_resolved": true}}
+		case ReceiverWebhook:
+			item["webhook_configs"] = []interface{}{map[string]interface{}{"url": settings["url"], "send_resolved": true}}
+		}
+		items = append(items, item)
+		match := map[string]interface{}{}
+		for name, value := range receiver.GetMatchers() {
+			match[name] = value
+		}
+		routes = append(routes, map[string]interface{}{"receiver": receiver.Name, "match": match, "continue": true})
+	}
+	return map[string]interface{}{
+		"global": global,
+		"route": map[string]interface{}{
+			"receiver":        defaultReceiver,
+			"group_by":        []interface{}{"alertname"},
+			"group_wait":      "10s",
+			"group_interval":  "5m",
+			"repeat_interval": "3h",
+			"routes":          routes,
+		},
+		"receivers": items,
+	}
+}
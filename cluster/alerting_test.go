@@ -0,0 +1,74 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestValidateAlertRule(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		rule    model.AlertRuleModel
+		labels  map[string]string
+		isError bool
+	}{
+		{name: "valid", rule: model.AlertRuleModel{Name: "HighErrorRate", Expr: "rate(errors[5m]) > 1", For: "5m", Severity: cluster.SeverityCritical}, isError: false},
+		{name: "labels", rule: model.AlertRuleModel{Name: "HighErrorRate", Expr: "up == 0", Severity: cluster.SeverityInfo}, labels: map[string]string{"team": "web"}, isError: false},
+		{name: "invalid name", rule: model.AlertRuleModel{Name: "high-error-rate", Expr: "up == 0", Severity: cluster.SeverityWarning}, isError: true},
+		{name: "no expression", rule: model.AlertRuleModel{Name: "Down", Expr: " ", Severity: cluster.SeverityWarning}, isError: true},
+		{name: "invalid duration", rule: model.AlertRuleModel{Name: "Down", Expr: "up == 0", For: "5 minutes", Severity: cluster.SeverityWarning}, isError: true},
+		{name: "unknown severity", rule: model.AlertRuleModel{Name: "Down", Expr: "up == 0", Severity: "fatal"}, isError: true},
+		{name: "invalid label", rule: model.AlertRuleModel{Name: "Down", Expr: "up == 0", Severity: cluster.SeverityInfo}, labels: map[string]string{"team-name": "web"}, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.rule.SetLabels(tc.labels)
+			err := cluster.ValidateAlertRule(tc.rule)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+			} else if err != nil {
+				t.Errorf("Error during ValidateAlertRule: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateAlertReceiver(t *testing.T) {
+
+	cases := []struct {
+		name         string
+		receiverType string
+		settings     map[string]string
+		isError      bool
+	}{
+		{name: "slack", receiverType: cluster.ReceiverSlack, settings: map[string]string{"webhookURL": "https://hooks.slack.com/services/T0/B0/X", "channel": "#alerts"}, isError: false},
+		{name: "pagerduty", receiverType: cluster.ReceiverPagerDuty, settings: map[string]string{"serviceKey": "key"}, isError: false},
+		{name: "webhook", receiverType: cluster.ReceiverWebhook, settings: map[string]string{"url": "http://alerts.example.com/hook"}, isError: false},
+		{name: "missing setting", receiverType: cluster.ReceiverSlack, settings: map[string]string{"channel": "#alerts"}, isError: true},
+		{name: "unknown setting", receiverType: cluster.ReceiverPagerDuty, settings: map[string]string{"serviceKey": "key", "url": "http://example.com"}, isError: true},
+		{name: "invalid url", receiverType: cluster.ReceiverWebhook, settings: map[string]string{"url": "ftp://example.com"}, isError: true},
+		{name: "email without SMTP", receiverType: cluster.ReceiverEmail, settings: map[string]string{"to": "ops@example.com"}, isError: true},
+		{name: "unknown type", receiverType: "sms", settings: map[string]string{}, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := model.AlertReceiverModel{Name: "ops", Type: tc.receiverType}
+			receiver.SetSettings(tc.settings)
+			err := cluster.ValidateAlertReceiver(receiver)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+			} else if err != nil {
+				t.Errorf("Error during ValidateAlertReceiver: %s", err.Error())
+			}
+		})
+	}
+}
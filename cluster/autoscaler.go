@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"fmt"
+
+	azureCluster "github.com/banzaicloud/azure-aks-client/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// autoscalerPolicy allows the cluster-autoscaler on the nodes to resize the auto scaling groups
+const autoscalerPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["autoscaling:DescribeAutoScalingGroups","autoscaling:DescribeAutoScalingInstances","autoscaling:DescribeLaunchConfigurations","autoscaling:DescribeTags","autoscaling:SetDesiredCapacity","autoscaling:TerminateInstanceInAutoScalingGroup"],"Resource":"*"}]}`
+
+// autoscalerPolicyName is the name of the inline policy of the autoscaler on the node roles
+const autoscalerPolicyName = "ClusterAutoscaler"
+
+// autoscalingGroup is a node group of the cluster-autoscaler chart with its size bounds
+type autoscalingGroup struct {
+	Name    string `json:"name"`
+	MinSize int    `json:"minSize"`
+	MaxSize int    `json:"maxSize"`
+}
+
+//SetClusterAutoscaler enables the cluster-autoscaler of the cluster, it's installed by InstallClusterAutoscalerPostHook.
+//The autoscaled node pools of AKS clusters are resized by the autoscaler, GKE autoscales the node pools itself.
+func SetClusterAutoscaler(commonCluster CommonCluster, enabled bool) error {
+	if !enabled {
+		commonCluster.GetModel().Autoscaler = false
+		return nil
+	}
+	switch commonCluster.(type) {
+	case *AWSCluster, *AKSCluster, *GKECluster, *EKSCluster:
+		commonCluster.GetModel().Autoscaler = true
+		return nil
+	}
+	return fmt.Errorf("cluster autoscaler is not supported on %s", commonCluster.GetType())
+}
+
+// autoscalingGroups returns the autoscaled node pools of the cluster
+func autoscalingGroups(commonCluster CommonCluster) []autoscalingGroup {
+	groups := []autoscalingGroup{}
+	for _, pool := range commonCluster.GetModel().NodePools {
+		if !pool.Autoscaling {
+			continue
+		}
+		groups = append(groups, autoscalingGroup{Name: pool.Name, MinSize: pool.MinCount, MaxSize: pool.MaxCount})
+	}
+	return groups
+}
+
+// autoscalerValues returns the values of the cluster-autoscaler chart for the provider of the cluster, the
+// node groups are discovered by their tags on EKS and listed with their bounds on the other providers
+func autoscalerValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	modelCluster := commonCluster.GetModel()
+	values := map[string]interface{}{
+		"rbac": map[string]interface{}{"create": true},
+	}
+	switch commonCluster.(type) {
+	case *AWSCluster:
+		values["cloudProvider"] = "aws"
+		values["awsRegion"] = modelCluster.Location
+		values["autoscalingGroups"] = []autoscalingGroup{{
+			Name:    fmt.Sprintf("%s.node", modelCluster.Name),
+			MinSize: modelCluster.Amazon.NodeMinCount,
+			MaxSize: modelCluster.Amazon.NodeMaxCount,
+		}}
+	case *EKSCluster:
+		// the auto scaling groups of the managed node groups have the discovery tags
+		values["cloudProvider"] = "aws"
+		values["awsRegion"] = modelCluster.Location
+		values["autoDiscovery"] = map[string]interface{}{"clusterName": modelCluster.Name}
+	case *AKSCluster:
+		clusterSecret, err := GetSecret(commonCluster)
+		if err != nil {
+			return nil, err
+		}
+		values["cloudProvider"] = "azure"
+		values["azureClientID"] = clusterSecret.Values[azureCluster.AzureClientId]
+		values["azureClientSecret"] = clusterSecret.Values[azureCluster.AzureClientSecret]
+		values["azureSubscriptionID"] = clusterSecret.Values[azureCluster.AzureSubscriptionId]
+		values["azureTenantID"] = clusterSecret.Values[azureCluster.AzureTenantId]
+		values["azureResourceGroup"] = modelCluster.Azure.ResourceGroup
+		values["azureVMType"] = "AKS"
+		values["azureClusterName"] = modelCluster.Name
+		values["azureNodeResourceGroup"] = fmt.Sprintf("MC_%s_%s_%s", modelCluster.Azure.ResourceGroup, modelCluster.Name, modelCluster.Location)
+		values["autoscalingGroups"] = autoscalingGroups(commonCluster)
+	default:
+		return nil, fmt.Errorf("cluster autoscaler is not supported on %s", commonCluster.GetType())
+	}
+	return values, nil
+}
+
+//InstallClusterAutoscalerPostHook installs the cluster-autoscaler add-on if it's enabled for the cluster
+func InstallClusterAutoscalerPostHook(cluster CommonCluster) {
+	log = logger.WithFields(logrus.Fields{"action": "InstallClusterAutoscaler"})
+	if !cluster.GetModel().Autoscaler {
+		return
+	}
+	if _, ok := cluster.(*GKECluster); ok {
+		log.Info("The node pools of GKE clusters are autoscaled by GKE")
+		return
+	}
+	if _, err := InstallAddon(cluster, AddonAutoscaler); err != nil {
+		log.Errorf("Deploying the cluster autoscaler failed due to: %s", err.Error())
+		return
+	}
+	log.Info("Cluster autoscaler installed")
+}
+
+// prepareClusterAutoscaler enables the cluster-autoscaler of the cluster before the add-on is installed,
+// the nodes of EKS clusters are allowed to resize their auto scaling groups
+func prepareClusterAutoscaler(commonCluster CommonCluster) error {
+	if _, ok := commonCluster.(*GKECluster); ok {
+		return fmt.Errorf("the node pools of GKE clusters are autoscaled by GKE")
+	}
+	if err := SetClusterAutoscaler(commonCluster, true); err != nil {
+		return err
+	}
+	if eksCluster, ok := commonCluster.(*EKSCluster); ok {
+		if err := eksCluster.allowAutoscaler(); err != nil {
+			return fmt.Errorf("error allowing the autoscaler on the nodes: %s", err.Error())
+		}
+	}
+	return commonCluster.Persist()
+}
+
+//ConfigureClusterAutoscaler updates the node groups of the cluster-autoscaler after a change of the node pools,
+//it's a step of the change
+func ConfigureClusterAutoscaler(commonCluster CommonCluster) error {
+	switch commonCluster.(type) {
+	case *GKECluster, *EKSCluster:
+		// GKE autoscales the node pools, the EKS node groups are discovered
+		return nil
+	}
+	if !commonCluster.GetModel().Autoscaler {
+		return nil
+	}
+	return RunStep(commonCluster, "ConfigureAutoscaler", func() error {
+		values, err := autoscalerValues(commonCluster)
+		if err != nil {
+			return err
+		}
+		kubeConfig, err := commonCluster.GetK8sConfig()
+		if err != nil {
+			return err
+		}
+		// the installed version of the add-on is kept
+		version := ""
+		if addon, err := model.GetAddon(commonCluster.GetID(), AddonAutoscaler); err == nil {
+			version = addon.Version
+		}
+		_, err = helm.UpgradeDeploymentVersion(viper.GetString("autoscaler.release"), viper.GetString("autoscaler.chart"), version, values, kubeConfig, commonCluster.GetName())
+		return err
+	})
+}
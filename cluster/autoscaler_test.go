@@ -0,0 +1,46 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestSetClusterAutoscaler(t *testing.T) {
+
+	pools := []cluster.NodePool{{Name: "pool1", Count: 2, Autoscaling: true, MinCount: 1, MaxCount: 5}}
+	cases := []struct {
+		name        string
+		autoscaler  bool
+		expectError bool
+	}{
+		{name: "autoscaled pool with autoscaler", autoscaler: true},
+		{name: "autoscaled pool without autoscaler", autoscaler: false, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+			if err := cluster.SetClusterAutoscaler(commonCluster, tc.autoscaler); err != nil {
+				t.Fatalf("Error during SetClusterAutoscaler: %s", err.Error())
+			}
+			if commonCluster.GetModel().Autoscaler != tc.autoscaler {
+				t.Errorf("Expected autoscaler: %v, got: %v", tc.autoscaler, commonCluster.GetModel().Autoscaler)
+			}
+
+			err = cluster.SetNodePools(commonCluster, pools)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during SetNodePools: %s", err.Error())
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -25,6 +26,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -231,7 +233,7 @@ func getStateStoreForCluster(clusterType *model.ClusterModel) (stateStore state.
 // GetKubicornProfile creates *cluster.Cluster from ClusterModel struct
 func GetKubicornProfile(cs *model.ClusterModel) *kcluster.Cluster {
 	uuidSuffix := uuid.TimeOrderedUUID()
-	return &kcluster.Cluster{
+	profile := &kcluster.Cluster{
 		Name:     cs.Name,
 		Cloud:    kcluster.CloudAmazon,
 		Location: cs.Location,
@@ -413,6 +415,12 @@ func GetKubicornProfile(cs *model.ClusterModel) *kcluster.Cluster {
 			},
 		},
 	}
+	if cs.Autoscaler {
+		// the cluster-autoscaler on the nodes resizes the auto scaling group of the nodes
+		role := profile.ServerPools[1].InstanceProfile.Role
+		role.Policies = append(role.Policies, &kcluster.IAMPolicy{Name: autoscalerPolicyName, Document: autoscalerPolicy})
+	}
+	return profile
 }
 
 //GetStatus gets cluster status
@@ -613,6 +621,10 @@ func (c *AWSCluster) GetK8sConfig() (*[]byte, error) {
 	if c.k8sConfig != nil {
 		return c.k8sConfig, nil
 	}
+	if config := loadK8sConfig(c.modelCluster); config != nil {
+		c.k8sConfig = config
+		return c.k8sConfig, nil
+	}
 	kubicornCluster, err := c.GetKubicornCluster()
 	if err != nil {
 		err = errors.Wrap(err, "error getting kubicorn cluster")
@@ -623,6 +635,7 @@ func (c *AWSCluster) GetK8sConfig() (*[]byte, error) {
 		err = errors.Wrap(err, "error downloading kubernetes config")
 		return nil, err
 	}
+	storeK8sConfig(c.modelCluster, *kubeConfig)
 	c.k8sConfig = kubeConfig
 	return c.k8sConfig, nil
 }
@@ -632,20 +645,46 @@ func (c *AWSCluster) GetK8sConfig() (*[]byte, error) {
 func DownloadK8sConfig(kubicornCluster *kcluster.Cluster) (*[]byte, error) {
 
 	user := kubicornCluster.SSH.User
+	remotePath := ""
+	if user == "root" {
+		remotePath = "/root/.kube/config"
+	} else {
+		remotePath = fmt.Sprintf("/home/%s/.kube/config", user)
+	}
+
+	connection, err := dialMaster(kubicornCluster)
+	if err != nil {
+		return nil, err
+	}
+	defer connection.Close()
+	sftpClient, err := sftp.NewClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	defer sftpClient.Close()
+	sftpConnection, err := sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	defer sftpConnection.Close()
+	config, err := ioutil.ReadAll(sftpConnection)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// dialMaster opens an SSH connection to the master of the cluster with the SSH key of kubicorn
+func dialMaster(kubicornCluster *kcluster.Cluster) (*ssh.Client, error) {
+
 	pubKeyPath := expand(kubicornCluster.SSH.PublicKeyPath)
 	privKeyPath := strings.Replace(pubKeyPath, ".pub", "", 1)
 	address := fmt.Sprintf("%s:%s", kubicornCluster.KubernetesAPI.Endpoint, "22")
 
 	sshConfig := &ssh.ClientConfig{
-		User:            user,
+		User:            kubicornCluster.SSH.User,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
-	remotePath := ""
-	if user == "root" {
-		remotePath = "/root/.kube/config"
-	} else {
-		remotePath = fmt.Sprintf("/home/%s/.kube/config", user)
-	}
 
 	pemBytes, err := ioutil.ReadFile(privKeyPath)
 	if err != nil {
@@ -665,26 +704,42 @@ func DownloadK8sConfig(kubicornCluster *kcluster.Cluster) (*[]byte, error) {
 
 	sshConfig.SetDefaults()
 
-	connection, err := ssh.Dial("tcp", address, sshConfig)
+	return ssh.Dial("tcp", address, sshConfig)
+}
+
+// runOnMaster runs the script on the master of the cluster, the error contains the standard error of the script
+func (c *AWSCluster) runOnMaster(script string, stdin io.Reader, stdout io.Writer) error {
+	kubicornCluster, err := c.GetKubicornCluster()
 	if err != nil {
-		return nil, err
+		return errors.Wrap(err, "error getting kubicorn cluster")
 	}
-	defer connection.Close()
-	sftpClient, err := sftp.NewClient(connection)
+	connection, err := dialMaster(kubicornCluster)
 	if err != nil {
-		return nil, err
+		return errors.Wrap(err, "error connecting to the master")
 	}
-	defer sftpClient.Close()
-	sftpConnection, err := sftpClient.Open(remotePath)
+	defer connection.Close()
+	session, err := connection.NewSession()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer sftpConnection.Close()
-	config, err := ioutil.ReadAll(sftpConnection)
-	if err != nil {
-		return nil, err
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stdin, session.Stdout, session.Stderr = stdin, stdout, &stderr
+	if err := session.Run(script); err != nil {
+		return errors.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
 	}
-	return &config, nil
+	return nil
+}
+
+//SaveEtcdSnapshot takes a snapshot of the etcd of the master and writes it to the writer
+func (c *AWSCluster) SaveEtcdSnapshot(w io.Writer) error {
+	return c.runOnMaster(etcdSnapshotSaveScript(), nil, w)
+}
+
+//RestoreEtcdSnapshot replaces the data of the etcd of the master with the snapshot, the control plane is
+//stopped during the restore
+func (c *AWSCluster) RestoreEtcdSnapshot(r io.Reader) error {
+	return c.runOnMaster(etcdSnapshotRestoreScript(), r, ioutil.Discard)
 }
 
 // const's of BootstrapScript values
@@ -0,0 +1,780 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/objectstore"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AddonBackup is the Velero add-on backing up the workloads of the cluster to its backup bucket
+const AddonBackup = "backup"
+
+// Providers of the backup buckets
+const (
+	BackupProviderAmazon = "amazon"
+	BackupProviderGoogle = "google"
+	BackupProviderAzure  = "azure"
+)
+
+const (
+	veleroAPIVersion = "velero.io/v1"
+	// veleroScheduleLabel is the label of the schedule on the backups created by the schedule
+	veleroScheduleLabel = "velero.io/schedule-name"
+	veleroLocation      = "default"
+	backupCredentials   = "pipeline-backup-credentials"
+	backupSyncRetries   = 24
+	backupSyncInterval  = 5 * time.Second
+)
+
+// backupProviderSettings are the settings of the providers of the buckets, the required settings are true
+var backupProviderSettings = map[string]map[string]bool{
+	BackupProviderAmazon: {"region": true, "s3Url": false},
+	BackupProviderGoogle: {},
+	BackupProviderAzure:  {"resourceGroup": true, "storageAccount": true},
+}
+
+// backupSecretTypes are the secret types of the credentials of the providers
+var backupSecretTypes = map[string]string{
+	BackupProviderAmazon: secret.Amazon,
+	BackupProviderGoogle: secret.Google,
+	BackupProviderAzure:  secret.Azure,
+}
+
+// veleroProviders are the names of the providers in Velero and its plugins
+var veleroProviders = map[string]string{
+	BackupProviderAmazon: "aws",
+	BackupProviderGoogle: "gcp",
+	BackupProviderAzure:  "azure",
+}
+
+//BackupBucket describes the object store bucket of the backups of the cluster and the secret of its credentials,
+//the Amazon buckets need the region, the Azure ones the resource group and the storage account
+type BackupBucket struct {
+	Provider string            `json:"provider" binding:"required"`
+	Bucket   string            `json:"bucket" binding:"required"`
+	Prefix   string            `json:"prefix,omitempty"`
+	SecretID string            `json:"secretId" binding:"required"`
+	ReadOnly bool              `json:"readOnly,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+	// Create creates the bucket as a managed bucket of the organization if it isn't managed yet
+	Create bool `json:"create,omitempty"`
+}
+
+//BackupSpec selects the resources of a backup and how long the backup is kept (TTL, 30 days by default in Velero)
+type BackupSpec struct {
+	IncludedNamespaces []string          `json:"includedNamespaces,omitempty"`
+	ExcludedNamespaces []string          `json:"excludedNamespaces,omitempty"`
+	LabelSelector      map[string]string `json:"labelSelector,omitempty"`
+	TTL                string            `json:"ttl,omitempty"`
+	SnapshotVolumes    *bool             `json:"snapshotVolumes,omitempty"`
+}
+
+//BackupRequest is an on-demand backup, the name is generated if it's empty
+type BackupRequest struct {
+	Name string `json:"name,omitempty"`
+	BackupSpec
+}
+
+//BackupSchedule creates the backups of its spec by its cron expression
+type BackupSchedule struct {
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule" binding:"required"`
+	Backup     BackupSpec `json:"backup"`
+	Phase      string     `json:"phase,omitempty"`
+	LastBackup *time.Time `json:"lastBackup,omitempty"`
+}
+
+//Backup is a Velero backup in the backup bucket of the cluster and its progress
+type Backup struct {
+	Name               string     `json:"name"`
+	Schedule           string     `json:"schedule,omitempty"`
+	Phase              string     `json:"phase"`
+	IncludedNamespaces []string   `json:"includedNamespaces,omitempty"`
+	StartedAt          *time.Time `json:"startedAt,omitempty"`
+	CompletedAt        *time.Time `json:"completedAt,omitempty"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"`
+	Errors             int64      `json:"errors"`
+	Warnings           int64      `json:"warnings"`
+}
+
+//RestoreRequest restores a backup to the target cluster (the cluster of the backup if it's zero), the namespaces
+//select the restored resources and the mapping renames the namespaces
+type RestoreRequest struct {
+	TargetClusterID    uint              `json:"targetClusterId,omitempty"`
+	IncludedNamespaces []string          `json:"includedNamespaces,omitempty"`
+	ExcludedNamespaces []string          `json:"excludedNamespaces,omitempty"`
+	NamespaceMapping   map[string]string `json:"namespaceMapping,omitempty"`
+	RestorePVs         *bool             `json:"restorePVs,omitempty"`
+}
+
+//Restore is a Velero restore of a backup to the cluster and its progress
+type Restore struct {
+	Name        string     `json:"name"`
+	Backup      string     `json:"backup"`
+	Phase       string     `json:"phase"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Errors      int64      `json:"errors"`
+	Warnings    int64      `json:"warnings"`
+}
+
+//ValidateBackupBucket checks the provider, the bucket and the settings of the provider
+func ValidateBackupBucket(bucket BackupBucket) error {
+	known, ok := backupProviderSettings[bucket.Provider]
+	if !ok {
+		return fmt.Errorf("the provider of the bucket must be %s, %s or %s", BackupProviderAmazon, BackupProviderGoogle, BackupProviderAzure)
+	}
+	if bucket.Bucket == "" || strings.Contains(bucket.Bucket, "/") {
+		return fmt.Errorf("invalid bucket: %q", bucket.Bucket)
+	}
+	for name, required := range known {
+		if required && bucket.Settings[name] == "" {
+			return fmt.Errorf("the %s bucket requires the %s setting", bucket.Provider, name)
+		}
+	}
+	for name := range bucket.Settings {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown setting of the %s bucket: %s", bucket.Provider, name)
+		}
+	}
+	if bucket.SecretID == "" {
+		return fmt.Errorf("the %s bucket requires a %s", bucket.Provider, backupSecretTypes[bucket.Provider])
+	}
+	return nil
+}
+
+// validateBackupSpec checks the namespaces and the TTL of the spec
+func validateBackupSpec(spec BackupSpec) error {
+	for _, namespace := range append(append([]string{}, spec.IncludedNamespaces...), spec.ExcludedNamespaces...) {
+		if namespace == "*" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+	}
+	if spec.TTL != "" {
+		if ttl, err := time.ParseDuration(spec.TTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl: %q", spec.TTL)
+		}
+	}
+	return nil
+}
+
+//ValidateBackupSchedule checks the name, the cron expression and the backup spec of the schedule
+func ValidateBackupSchedule(schedule BackupSchedule) error {
+	if errs := validation.IsDNS1123Subdomain(schedule.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid schedule name %q: %s", schedule.Name, strings.Join(errs, ", "))
+	}
+	if _, err := parseCron(schedule.Schedule); err != nil {
+		return err
+	}
+	return validateBackupSpec(schedule.Backup)
+}
+
+//VeleroCredentials returns the credentials file of the Velero plugin of the provider from the values of the
+//secret; the Azure plugin reads the resource group of the bucket from it too
+func VeleroCredentials(provider string, values, settings map[string]string) ([]byte, error) {
+	switch provider {
+	case BackupProviderAmazon:
+		return []byte(fmt.Sprintf("[default]\naws_access_key_id=%s\naws_secret_access_key=%s\n",
+			values["AWS_ACCESS_KEY_ID"], values["AWS_SECRET_ACCESS_KEY"])), nil
+	case BackupProviderGoogle:
+		return json.Marshal(values)
+	case BackupProviderAzure:
+		lines := []string{
+			"AZURE_SUBSCRIPTION_ID=" + values["AZURE_SUBSCRIPTION_ID"],
+			"AZURE_TENANT_ID=" + values["AZURE_TENANT_ID"],
+			"AZURE_CLIENT_ID=" + values["AZURE_CLIENT_ID"],
+			"AZURE_CLIENT_SECRET=" + values["AZURE_CLIENT_SECRET"],
+			"AZURE_RESOURCE_GROUP=" + settings["resourceGroup"],
+			"AZURE_CLOUD_NAME=AzurePublicCloud",
+		}
+		return []byte(strings.Join(lines, "\n") + "\n"), nil
+	}
+	return nil, fmt.Errorf("unknown provider: %s", provider)
+}
+
+//GetBackupBucket returns the backup bucket of the cluster, it's nil if the cluster has no bucket
+func GetBackupBucket(commonCluster CommonCluster) (*BackupBucket, error) {
+	bucket, err := model.GetBackupBucket(commonCluster.GetID())
+	if model.IsErrorGormNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &BackupBucket{
+		Provider: bucket.Provider,
+		Bucket:   bucket.Bucket,
+		Prefix:   bucket.Prefix,
+		SecretID: bucket.SecretID,
+		ReadOnly: bucket.ReadOnly,
+		Settings: bucket.GetSettings(),
+	}, nil
+}
+
+//EnableBackups saves the backup bucket of the cluster and installs the backup add-on with the bucket,
+//the installed add-on is reconfigured
+func EnableBackups(commonCluster CommonCluster, bucket BackupBucket) error {
+	log := logger.WithFields(logrus.Fields{"action": "EnableBackups"})
+	bucket.Prefix = strings.Trim(bucket.Prefix, "/")
+	if err := ValidateBackupBucket(bucket); err != nil {
+		return err
+	}
+	if _, err := backupSecret(commonCluster, bucket.Provider, bucket.SecretID); err != nil {
+		return err
+	}
+	if bucket.Create {
+		if bucket.Settings["s3Url"] != "" {
+			return fmt.Errorf("the buckets of S3 compatible stores can't be created")
+		}
+		request := objectstore.BucketRequest{Name: bucket.Bucket, SecretID: bucket.SecretID, Settings: bucket.Settings}
+		if _, _, err := objectstore.EnsureManagedBucket(commonCluster.GetOrg(), "", request); err != nil {
+			return err
+		}
+	}
+	saved, err := model.GetBackupBucket(commonCluster.GetID())
+	if model.IsErrorGormNotFound(err) {
+		saved = &model.BackupBucketModel{ClusterModelID: commonCluster.GetID()}
+	} else if err != nil {
+		return err
+	}
+	saved.Provider, saved.Bucket, saved.Prefix, saved.SecretID, saved.ReadOnly = bucket.Provider, bucket.Bucket, bucket.Prefix, bucket.SecretID, bucket.ReadOnly
+	saved.SetSettings(bucket.Settings)
+	if err := saved.Save(); err != nil {
+		return err
+	}
+
+	if _, err := model.GetAddon(commonCluster.GetID(), AddonBackup); model.IsErrorGormNotFound(err) {
+		_, err = InstallAddon(commonCluster, AddonBackup)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if err := prepareBackup(commonCluster); err != nil {
+		return err
+	}
+	if _, err := ReconfigureAddon(commonCluster, AddonBackup); err != nil {
+		return err
+	}
+	log.Infof("Backups of cluster %s stored in %s bucket %s", commonCluster.GetName(), bucket.Provider, bucket.Bucket)
+	return nil
+}
+
+//DisableBackups removes the backup add-on and the backup bucket of the cluster, the backups are kept in the bucket
+func DisableBackups(commonCluster CommonCluster) error {
+	bucket, err := model.GetBackupBucket(commonCluster.GetID())
+	if err != nil {
+		return err
+	}
+	if err := DeleteAddon(commonCluster, AddonBackup); err != nil && !model.IsErrorGormNotFound(err) {
+		return err
+	}
+	return bucket.Delete()
+}
+
+// backupSecret returns the secret of the credentials of the bucket, its type must be the secret type of the provider
+func backupSecret(commonCluster CommonCluster, provider, secretID string) (*secret.SecretsItemResponse, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), secretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the secret of the backup bucket")
+	}
+	if item.SecretType != backupSecretTypes[provider] {
+		return nil, fmt.Errorf("the secret of the %s bucket must be a %s, not a %s", provider, backupSecretTypes[provider], item.SecretType)
+	}
+	return item, nil
+}
+
+// prepareBackup creates the secret of the credentials of the bucket in the namespace of the add-on
+func prepareBackup(commonCluster CommonCluster) error {
+	bucket, err := GetBackupBucket(commonCluster)
+	if err != nil {
+		return err
+	}
+	if bucket == nil {
+		return fmt.Errorf("cluster %s has no backup bucket", commonCluster.GetName())
+	}
+	item, err := backupSecret(commonCluster, bucket.Provider, bucket.SecretID)
+	if err != nil {
+		return err
+	}
+	cloud, err := VeleroCredentials(bucket.Provider, item.Values, bucket.Settings)
+	if err != nil {
+		return err
+	}
+	credentials := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: backupCredentials, Namespace: helm.DefaultNamespace},
+		Data:       map[string][]byte{"cloud": cloud},
+	}
+	return errors.Wrap(applySecret(commonCluster, credentials), "error applying the credentials of the backup bucket")
+}
+
+// backupValues returns the values of the Velero chart, the backup storage location is the bucket of the cluster
+// and the plugin of its provider is installed
+func backupValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	bucket, err := GetBackupBucket(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		return nil, fmt.Errorf("cluster %s has no backup bucket", commonCluster.GetName())
+	}
+	config, snapshotConfig := map[string]interface{}{}, map[string]interface{}{}
+	switch bucket.Provider {
+	case BackupProviderAmazon:
+		config["region"], snapshotConfig["region"] = bucket.Settings["region"], bucket.Settings["region"]
+		if bucket.Settings["s3Url"] != "" {
+			config["s3Url"], config["s3ForcePathStyle"] = bucket.Settings["s3Url"], true
+		}
+	case BackupProviderAzure:
+		config["resourceGroup"], config["storageAccount"] = bucket.Settings["resourceGroup"], bucket.Settings["storageAccount"]
+	}
+	location := map[string]interface{}{
+		"name":   veleroLocation,
+		"bucket": bucket.Bucket,
+		"config": config,
+	}
+	if bucket.Prefix != "" {
+		location["prefix"] = bucket.Prefix
+	}
+	if bucket.ReadOnly {
+		location["accessMode"] = "ReadOnly"
+	}
+	provider := veleroProviders[bucket.Provider]
+	return map[string]interface{}{
+		"configuration": map[string]interface{}{
+			"provider":               provider,
+			"backupStorageLocation":  location,
+			"volumeSnapshotLocation": map[string]interface{}{"name": veleroLocation, "config": snapshotConfig},
+			"backupSyncPeriod":       "1m",
+		},
+		"credentials": map[string]interface{}{"existingSecret": backupCredentials},
+		"initContainers": []interface{}{
+			map[string]interface{}{
+				"name":         "velero-plugin-for-" + provider,
+				"image":        viper.GetString("addons.backup.plugins." + bucket.Provider),
+				"volumeMounts": []interface{}{map[string]interface{}{"mountPath": "/target", "name": "plugins"}},
+			},
+		},
+		"snapshotsEnabled": true,
+		"deployRestic":     false,
+	}, nil
+}
+
+// checkBackups returns the kubeconfig of the cluster if its backup add-on is installed, the clusters restoring
+// the backups of a read only bucket can't back up
+func checkBackups(commonCluster CommonCluster, write bool) (*[]byte, error) {
+	bucket, err := GetBackupBucket(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		return nil, fmt.Errorf("cluster %s has no backup bucket", commonCluster.GetName())
+	}
+	if write && bucket.ReadOnly {
+		return nil, fmt.Errorf("the backup bucket of cluster %s is read only", commonCluster.GetName())
+	}
+	if _, err := model.GetAddon(commonCluster.GetID(), AddonBackup); err != nil {
+		if model.IsErrorGormNotFound(err) {
+			return nil, fmt.Errorf("the %s add-on isn't installed", AddonBackup)
+		}
+		return nil, err
+	}
+	return commonCluster.GetK8sConfig()
+}
+
+// veleroResource returns the Velero resource of the kind in the namespace of the add-on
+func veleroResource(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": veleroAPIVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": helm.DefaultNamespace},
+	}}
+	if spec != nil {
+		object.Object["spec"] = spec
+	}
+	return object
+}
+
+// backupSpecFields returns the Velero spec of the backups of the spec, every namespace is backed up by default
+func backupSpecFields(spec BackupSpec) map[string]interface{} {
+	fields := map[string]interface{}{
+		"includedNamespaces": stringList(spec.IncludedNamespaces, "*"),
+		"storageLocation":    veleroLocation,
+	}
+	if len(spec.ExcludedNamespaces) > 0 {
+		fields["excludedNamespaces"] = stringList(spec.ExcludedNamespaces)
+	}
+	if len(spec.LabelSelector) > 0 {
+		labels := map[string]interface{}{}
+		for key, value := range spec.LabelSelector {
+			labels[key] = value
+		}
+		fields["labelSelector"] = map[string]interface{}{"matchLabels": labels}
+	}
+	if spec.TTL != "" {
+		ttl, _ := time.ParseDuration(spec.TTL)
+		fields["ttl"] = ttl.String()
+	}
+	if spec.SnapshotVolumes != nil {
+		fields["snapshotVolumes"] = *spec.SnapshotVolumes
+	}
+	return fields
+}
+
+// stringList returns the values as an unstructured list, the defaults if there are no values
+func stringList(values []string, defaults ...string) []interface{} {
+	if len(values) == 0 {
+		values = defaults
+	}
+	list := []interface{}{}
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
+
+// backupStatus returns the status of the Velero resource: its phase, start, completion and expiry, and the number
+// of its errors and warnings
+func backupStatus(object map[string]interface{}) (phase string, started, completed, expires *time.Time, errs, warnings int64) {
+	status, _ := object["status"].(map[string]interface{})
+	phase, _ = status["phase"].(string)
+	if phase == "" {
+		phase = "New"
+	}
+	timestamp := func(field string) *time.Time {
+		value, _ := status[field].(string)
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return &t
+		}
+		return nil
+	}
+	count := func(field string) int64 {
+		switch value := status[field].(type) {
+		case int64:
+			return value
+		case float64:
+			return int64(value)
+		}
+		return 0
+	}
+	return phase, timestamp("startTimestamp"), timestamp("completionTimestamp"), timestamp("expiration"), count("errors"), count("warnings")
+}
+
+// backupFromResource returns the backup of the Velero resource
+func backupFromResource(object *unstructured.Unstructured) Backup {
+	backup := Backup{Name: object.GetName(), Schedule: object.GetLabels()[veleroScheduleLabel]}
+	backup.Phase, backup.StartedAt, backup.CompletedAt, backup.ExpiresAt, backup.Errors, backup.Warnings = backupStatus(object.Object)
+	spec, _ := object.Object["spec"].(map[string]interface{})
+	namespaces, _ := spec["includedNamespaces"].([]interface{})
+	for _, namespace := range namespaces {
+		if name, ok := namespace.(string); ok {
+			backup.IncludedNamespaces = append(backup.IncludedNamespaces, name)
+		}
+	}
+	return backup
+}
+
+//CreateBackup starts an on-demand backup of the cluster to its backup bucket
+func CreateBackup(commonCluster CommonCluster, request BackupRequest) (*Backup, error) {
+	kubeConfig, err := checkBackups(commonCluster, true)
+	if err != nil {
+		return nil, err
+	}
+	if request.Name == "" {
+		request.Name = fmt.Sprintf("%s-%s", commonCluster.GetName(), time.Now().UTC().Format("20060102150405"))
+	}
+	if errs := validation.IsDNS1123Subdomain(request.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid backup name %q: %s", request.Name, strings.Join(errs, ", "))
+	}
+	if err := validateBackupSpec(request.BackupSpec); err != nil {
+		return nil, err
+	}
+	object := veleroResource("Backup", request.Name, backupSpecFields(request.BackupSpec))
+	if _, err := helm.GetManifest(kubeConfig, object); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", request.Name)
+	}
+	if _, err := helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{object}, nil); err != nil {
+		return nil, err
+	}
+	backup := backupFromResource(object)
+	return &backup, nil
+}
+
+//ListBackups returns the backups in the backup bucket of the cluster, the newest first
+func ListBackups(commonCluster CommonCluster) ([]Backup, error) {
+	kubeConfig, err := checkBackups(commonCluster, false)
+	if err != nil {
+		return nil, err
+	}
+	items, err := helm.ListManifests(kubeConfig, veleroResource("Backup", "", nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].GetCreationTimestamp().Time.After(items[j].GetCreationTimestamp().Time)
+	})
+	backups := []Backup{}
+	for i := range items {
+		backups = append(backups, backupFromResource(&items[i]))
+	}
+	return backups, nil
+}
+
+//GetBackup returns a backup of the cluster, the error is a not found error if it doesn't exist
+func GetBackup(commonCluster CommonCluster, name string) (*Backup, error) {
+	kubeConfig, err := checkBackups(commonCluster, false)
+	if err != nil {
+		return nil, err
+	}
+	live, err := helm.GetManifest(kubeConfig, veleroResource("Backup", name, nil))
+	if err != nil {
+		return nil, err
+	}
+	backup := backupFromResource(live)
+	return &backup, nil
+}
+
+//DeleteBackup requests Velero to delete the backup from the bucket with its volume snapshots
+func DeleteBackup(commonCluster CommonCluster, name string) error {
+	if _, err := GetBackup(commonCluster, name); err != nil {
+		return err
+	}
+	kubeConfig, err := checkBackups(commonCluster, true)
+	if err != nil {
+		return err
+	}
+	request := veleroResource("DeleteBackupRequest", fmt.Sprintf("%s-%d", name, time.Now().Unix()), map[string]interface{}{"backupName": name})
+	_, err = helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{request}, nil)
+	return err
+}
+
+// scheduleFromResource returns the backup schedule of the Velero resource
+func scheduleFromResource(object *unstructured.Unstructured) BackupSchedule {
+	spec, _ := object.Object["spec"].(map[string]interface{})
+	status, _ := object.Object["status"].(map[string]interface{})
+	schedule := BackupSchedule{Name: object.GetName()}
+	schedule.Schedule, _ = spec["schedule"].(string)
+	schedule.Phase, _ = status["phase"].(string)
+	if value, _ := status["lastBackup"].(string); value != "" {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			schedule.LastBackup = &t
+		}
+	}
+	template, _ := spec["template"].(map[string]interface{})
+	data, _ := json.Marshal(template)
+	var fields struct {
+		IncludedNamespaces []string `json:"includedNamespaces"`
+		ExcludedNamespaces []string `json:"excludedNamespaces"`
+		LabelSelector      struct {
+			MatchLabels map[string]string `json:"matchLabels"`
+		} `json:"labelSelector"`
+		TTL             string `json:"ttl"`
+		SnapshotVolumes *bool  `json:"snapshotVolumes"`
+	}
+	json.Unmarshal(data, &fields)
+	schedule.Backup = BackupSpec{
+		IncludedNamespaces: fields.IncludedNamespaces,
+		ExcludedNamespaces: fields.ExcludedNamespaces,
+		LabelSelector:      fields.LabelSelector.MatchLabels,
+		TTL:                fields.TTL,
+		SnapshotVolumes:    fields.SnapshotVolumes,
+	}
+	return schedule
+}
+
+//ListBackupSchedules returns the backup schedules of the cluster
+func ListBackupSchedules(commonCluster CommonCluster) ([]BackupSchedule, error) {
+	kubeConfig, err := checkBackups(commonCluster, false)
+	if err != nil {
+		return nil, err
+	}
+	items, err := helm.ListManifests(kubeConfig, veleroResource("Schedule", "", nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	schedules := []BackupSchedule{}
+	for i := range items {
+		schedules = append(schedules, scheduleFromResource(&items[i]))
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+	return schedules, nil
+}
+
+//SetBackupSchedule creates or replaces the backup schedule of the cluster, Velero runs the schedule in the time
+//zone of the cluster (UTC)
+func SetBackupSchedule(commonCluster CommonCluster, schedule BackupSchedule) (*BackupSchedule, error) {
+	kubeConfig, err := checkBackups(commonCluster, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateBackupSchedule(schedule); err != nil {
+		return nil, err
+	}
+	object := veleroResource("Schedule", schedule.Name, map[string]interface{}{
+		"schedule": schedule.Schedule,
+		"template": backupSpecFields(schedule.Backup),
+	})
+	if _, err := helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{object}, nil); err != nil {
+		return nil, err
+	}
+	applied := scheduleFromResource(object)
+	return &applied, nil
+}
+
+//DeleteBackupSchedule deletes the backup schedule of the cluster, the backups of the schedule are kept
+func DeleteBackupSchedule(commonCluster CommonCluster, name string) error {
+	kubeConfig, err := checkBackups(commonCluster, false)
+	if err != nil {
+		return err
+	}
+	object := veleroResource("Schedule", name, nil)
+	if _, err := helm.GetManifest(kubeConfig, object); err != nil {
+		return err
+	}
+	return helm.DeleteManifests(kubeConfig, []*unstructured.Unstructured{object})
+}
+
+// restoreFromResource returns the restore of the Velero resource
+func restoreFromResource(object *unstructured.Unstructured) Restore {
+	spec, _ := object.Object["spec"].(map[string]interface{})
+	restore := Restore{Name: object.GetName()}
+	restore.Backup, _ = spec["backupName"].(string)
+	restore.Phase, restore.StartedAt, restore.CompletedAt, _, restore.Errors, restore.Warnings = backupStatus(object.Object)
+	return restore
+}
+
+//RestoreBackup restores the completed backup of the cluster to the target: a target cluster without a backup
+//bucket gets the bucket of the backup read only, Velero syncs the backups of the bucket to the target before
+//its restore is created
+func RestoreBackup(commonCluster CommonCluster, name string, target CommonCluster, request RestoreRequest) (*Restore, error) {
+	log := logger.WithFields(logrus.Fields{"action": "RestoreBackup"})
+	backup, err := GetBackup(commonCluster, name)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Phase != "Completed" && backup.Phase != "PartiallyFailed" {
+		return nil, fmt.Errorf("backup %s can't be restored in the %s phase", name, backup.Phase)
+	}
+	for _, namespace := range append(append([]string{}, request.IncludedNamespaces...), request.ExcludedNamespaces...) {
+		if errs := validation.IsDNS1123Label(namespace); namespace != "*" && len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+	}
+	if target.GetID() != commonCluster.GetID() {
+		if err := shareBackupBucket(commonCluster, target); err != nil {
+			return nil, err
+		}
+	}
+	kubeConfig, err := checkBackups(target, false)
+	if err != nil {
+		return nil, err
+	}
+	// the backups of the bucket are synced to the target by Velero every minute
+	for i := 0; ; i++ {
+		_, err = helm.GetManifest(kubeConfig, veleroResource("Backup", name, nil))
+		if err == nil || !k8sErrors.IsNotFound(err) || i == backupSyncRetries {
+			break
+		}
+		time.Sleep(backupSyncInterval)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "backup %s isn't synced to cluster %s", name, target.GetName())
+	}
+
+	spec := map[string]interface{}{
+		"backupName":         name,
+		"includedNamespaces": stringList(request.IncludedNamespaces, "*"),
+	}
+	if len(request.ExcludedNamespaces) > 0 {
+		spec["excludedNamespaces"] = stringList(request.ExcludedNamespaces)
+	}
+	if len(request.NamespaceMapping) > 0 {
+		mapping := map[string]interface{}{}
+		for from, to := range request.NamespaceMapping {
+			mapping[from] = to
+		}
+		spec["namespaceMapping"] = mapping
+	}
+	if request.RestorePVs != nil {
+		spec["restorePVs"] = *request.RestorePVs
+	}
+	object := veleroResource("Restore", fmt.Sprintf("%s-%s", name, time.Now().UTC().Format("20060102150405")), spec)
+	if _, err := helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{object}, nil); err != nil {
+		return nil, err
+	}
+	log.Infof("Backup %s of cluster %s is restoring to cluster %s", name, commonCluster.GetName(), target.GetName())
+	restore := restoreFromResource(object)
+	return &restore, nil
+}
+
+// shareBackupBucket enables the backups of the target with the bucket of the cluster read only, the target must
+// not back up to another bucket
+func shareBackupBucket(commonCluster, target CommonCluster) error {
+	bucket, err := GetBackupBucket(commonCluster)
+	if err != nil {
+		return err
+	}
+	current, err := GetBackupBucket(target)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		if current.Provider != bucket.Provider || current.Bucket != bucket.Bucket || current.Prefix != bucket.Prefix {
+			return fmt.Errorf("cluster %s backs up to another bucket", target.GetName())
+		}
+		return nil
+	}
+	bucket.ReadOnly = true
+	return EnableBackups(target, *bucket)
+}
+
+//ListRestores returns the restores of the cluster, the newest first
+func ListRestores(commonCluster CommonCluster) ([]Restore, error) {
+	kubeConfig, err := checkBackups(commonCluster, false)
+	if err != nil {
+		return nil, err
+	}
+	items, err := helm.ListManifests(kubeConfig, veleroResource("Restore", "", nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].GetCreationTimestamp().Time.After(items[j].GetCreationTimestamp().Time)
+	})
+	restores := []Restore{}
+	for i := range items {
+		restores = append(restores, restoreFromResource(&items[i]))
+	}
+	return restores, nil
+}
+
+//GetRestore returns a restore of the cluster, the error is a not found error if it doesn't exist
+func GetRestore(commonCluster CommonCluster, name string) (*Restore, error) {
+	kubeConfig, err := checkBackups(commonCluster, false)
+	if err != nil {
+		return nil, err
+	}
+	live, err := helm.GetManifest(kubeConfig, veleroResource("Restore", name, nil))
+	if err != nil {
+		return nil, err
+	}
+	restore := restoreFromResource(live)
+	return &restore, nil
+}
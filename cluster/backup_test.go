@@ -0,0 +1,74 @@
+package cluster_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestValidateBackupBucket(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		bucket      cluster.BackupBucket
+		expectError bool
+	}{
+		{name: "amazon", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderAmazon, Bucket: "backups", SecretID: "s", Settings: map[string]string{"region": "eu-west-1"}}},
+		{name: "google", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderGoogle, Bucket: "backups", SecretID: "s"}},
+		{name: "amazon without region", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderAmazon, Bucket: "backups", SecretID: "s"}, expectError: true},
+		{name: "unknown setting", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderGoogle, Bucket: "backups", SecretID: "s", Settings: map[string]string{"region": "eu"}}, expectError: true},
+		{name: "azure without account", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderAzure, Bucket: "backups", SecretID: "s", Settings: map[string]string{"resourceGroup": "rg"}}, expectError: true},
+		{name: "bucket path", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderGoogle, Bucket: "backups/prod", SecretID: "s"}, expectError: true},
+		{name: "no secret", bucket: cluster.BackupBucket{Provider: cluster.BackupProviderGoogle, Bucket: "backups"}, expectError: true},
+		{name: "unknown provider", bucket: cluster.BackupBucket{Provider: "minio", Bucket: "backups", SecretID: "s"}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateBackupBucket(tc.bucket)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateBackupBucket: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestVeleroCredentials(t *testing.T) {
+
+	amazon, err := cluster.VeleroCredentials(cluster.BackupProviderAmazon, map[string]string{"AWS_ACCESS_KEY_ID": "id", "AWS_SECRET_ACCESS_KEY": "key"}, nil)
+	if err != nil {
+		t.Fatalf("Error during VeleroCredentials: %s", err.Error())
+	}
+	if expected := "[default]\naws_access_key_id=id\naws_secret_access_key=key\n"; string(amazon) != expected {
+		t.Errorf("Expected %q, got: %q", expected, amazon)
+	}
+
+	google, err := cluster.VeleroCredentials(cluster.BackupProviderGoogle, map[string]string{"type": "service_account", "project_id": "acme"}, nil)
+	if err != nil {
+		t.Fatalf("Error during VeleroCredentials: %s", err.Error())
+	}
+	var account map[string]string
+	if err := json.Unmarshal(google, &account); err != nil || account["project_id"] != "acme" {
+		t.Errorf("Expected the service account JSON, got: %s", google)
+	}
+
+	azure, err := cluster.VeleroCredentials(cluster.BackupProviderAzure, map[string]string{"AZURE_CLIENT_ID": "client"}, map[string]string{"resourceGroup": "rg"})
+	if err != nil {
+		t.Fatalf("Error during VeleroCredentials: %s", err.Error())
+	}
+	if !strings.Contains(string(azure), "AZURE_CLIENT_ID=client\n") || !strings.Contains(string(azure), "AZURE_RESOURCE_GROUP=rg\n") {
+		t.Errorf("Expected the client and the resource group, got: %q", azure)
+	}
+
+	if _, err := cluster.VeleroCredentials("minio", nil, nil); err == nil {
+		t.Errorf("Expected error, but not got error!")
+	}
+}
@@ -0,0 +1,295 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/ghodss/yaml"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	rls "k8s.io/helm/pkg/proto/hapi/services"
+)
+
+// Strategies of the canaries: a canary release gets a share of the traffic of the ingress, a blue-green
+// release gets no traffic until it's promoted
+const (
+	StrategyCanary    = "canary"
+	StrategyBlueGreen = "blueGreen"
+)
+
+// Statuses of the canaries
+const (
+	CanaryProgressing = "progressing"
+	CanaryPromoted    = "promoted"
+	CanaryAborted     = "aborted"
+	CanaryRolledBack  = "rolledBack"
+)
+
+// serviceWeightsAnnotation splits the traffic of the paths of the Traefik ingress between the services
+const serviceWeightsAnnotation = "traefik.ingress.kubernetes.io/service-weights"
+
+//ValidateCanary checks the strategy, the traffic weight, the ingress and the SLO of the canary
+func ValidateCanary(canary model.CanaryModel) error {
+	switch canary.Strategy {
+	case StrategyCanary:
+		if canary.Weight < 1 || canary.Weight > 99 {
+			return fmt.Errorf("the weight of a canary must be between 1 and 99, not %d", canary.Weight)
+		}
+	case StrategyBlueGreen:
+		if canary.Weight != 0 {
+			return fmt.Errorf("a blue-green release gets no traffic until it's promoted")
+		}
+	default:
+		return fmt.Errorf("invalid strategy %q, it must be %s or %s", canary.Strategy, StrategyCanary, StrategyBlueGreen)
+	}
+	if canary.Ingress == "" || canary.StableService == "" || canary.CanaryService == "" {
+		return fmt.Errorf("the ingress, the stable and the canary services are required")
+	}
+	if canary.StableService == canary.CanaryService {
+		return fmt.Errorf("the canary service must differ from the stable service")
+	}
+	if canary.Threshold != 0 && canary.Query == "" {
+		return fmt.Errorf("the SLO threshold requires a query")
+	}
+	return nil
+}
+
+//StartCanary installs the canary release alongside the stable release and routes the weight of the traffic
+//of the ingress to the canary service, the canary is saved as progressing
+func StartCanary(commonCluster CommonCluster, canary *model.CanaryModel) error {
+	if current, err := model.GetCanary(commonCluster.GetID(), canary.ReleaseName); err == nil && current.Status == CanaryProgressing {
+		return fmt.Errorf("release %s already has a progressing canary", canary.ReleaseName)
+	} else if err != nil && !model.IsErrorGormNotFound(err) {
+		return err
+	}
+	if canary.Namespace == "" {
+		canary.Namespace = helm.DefaultNamespace
+	}
+	canary.CanaryRelease = canary.ReleaseName + "-canary"
+	valueOverrides, err := yaml.Marshal(canary.GetValues())
+	if err != nil {
+		return err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	if _, err := helm.CreateDeployment(canary.Chart, canary.CanaryRelease, valueOverrides, kubeConfig, commonCluster.GetName()); err != nil {
+		return err
+	}
+	if err := setTrafficWeight(client, *canary, canary.Weight); err != nil {
+		if deleteErr := helm.DeleteDeployment(canary.CanaryRelease, kubeConfig); deleteErr != nil {
+			log.Warnf("Error deleting canary release %s: %s", canary.CanaryRelease, deleteErr.Error())
+		}
+		return err
+	}
+	canary.Status, canary.Message = CanaryProgressing, ""
+	return canary.Save()
+}
+
+//PromoteCanary upgrades the stable release with the chart and the values of the canary, then routes the
+//traffic back to the stable service and removes the canary release
+func PromoteCanary(commonCluster CommonCluster, canary *model.CanaryModel) (*rls.UpdateReleaseResponse, error) {
+	if canary.Status != CanaryProgressing {
+		return nil, fmt.Errorf("the canary of release %s is %s", canary.ReleaseName, canary.Status)
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	upgrade, err := helm.UpgradeDeploymentFromRepo(canary.ReleaseName, canary.Chart, canary.GetValues(), kubeConfig, commonCluster.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return upgrade, finishCanary(commonCluster, canary, CanaryPromoted, "")
+}
+
+//AbortCanary routes the traffic back to the stable service and removes the canary release,
+//the status is aborted or rolled back
+func AbortCanary(commonCluster CommonCluster, canary *model.CanaryModel, status, message string) error {
+	if canary.Status != CanaryProgressing {
+		return fmt.Errorf("the canary of release %s is %s", canary.ReleaseName, canary.Status)
+	}
+	return finishCanary(commonCluster, canary, status, message)
+}
+
+// finishCanary removes the canary release and its traffic and saves the final status of the canary
+func finishCanary(commonCluster CommonCluster, canary *model.CanaryModel, status, message string) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	if err := setTrafficWeight(client, *canary, 0); err != nil {
+		return err
+	}
+	if err := helm.DeleteDeployment(canary.CanaryRelease, kubeConfig); err != nil {
+		return err
+	}
+	canary.Status, canary.Message = status, message
+	return canary.Save()
+}
+
+// setTrafficWeight routes the weight percent of the traffic of the stable service of the ingress to the canary
+// service: the paths of the stable service get a path of the canary service and the weights are annotated.
+// The canary paths and the annotation are removed if the weight is 0.
+func setTrafficWeight(client *kubernetes.Clientset, canary model.CanaryModel, weight int) error {
+	ingresses := client.ExtensionsV1beta1().Ingresses(canary.Namespace)
+	ingress, err := ingresses.Get(canary.Ingress, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for i, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		paths := []v1beta1.HTTPIngressPath{}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.ServiceName == canary.CanaryService {
+				continue
+			}
+			paths = append(paths, path)
+			if path.Backend.ServiceName == canary.StableService && weight > 0 {
+				canaryPath := path
+				canaryPath.Backend.ServiceName = canary.CanaryService
+				paths = append(paths, canaryPath)
+			}
+		}
+		ingress.Spec.Rules[i].HTTP.Paths = paths
+	}
+	if weight > 0 {
+		if ingress.Annotations == nil {
+			ingress.Annotations = map[string]string{}
+		}
+		ingress.Annotations[serviceWeightsAnnotation] = fmt.Sprintf("%s: %d%%\n%s: %d%%\n", canary.StableService, 100-weight, canary.CanaryService, weight)
+	} else {
+		delete(ingress.Annotations, serviceWeightsAnnotation)
+	}
+	_, err = ingresses.Update(ingress)
+	return err
+}
+
+//RunCanaryAnalysis checks the SLOs of the progressing canaries with the given interval and rolls back the
+//canaries breaching them, it never returns
+func RunCanaryAnalysis(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"action": "CanaryAnalysis"})
+	for range time.Tick(interval) {
+		canaries, err := model.ListCanariesByStatus(CanaryProgressing)
+		if err != nil {
+			log.Errorf("Error listing the canaries: %s", err.Error())
+			continue
+		}
+		for i := range canaries {
+			if canaries[i].Query == "" {
+				continue
+			}
+			if err := analyzeCanary(&canaries[i]); err != nil {
+				log.Warnf("Error analyzing the canary of release %s: %s", canaries[i].ReleaseName, err.Error())
+			}
+		}
+	}
+}
+
+// analyzeCanary queries the SLO of the canary from the Prometheus of the cluster, the canary is rolled back
+// if the value is above the threshold
+func analyzeCanary(canary *model.CanaryModel) error {
+	var modelCluster model.ClusterModel
+	if err := model.GetDB().First(&modelCluster, canary.ClusterModelID).Error; err != nil {
+		return err
+	}
+	commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+	if err != nil {
+		return err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	value, ok, err := queryPrometheus(client, canary.Query)
+	if err != nil || !ok || value <= canary.Threshold {
+		return err
+	}
+	logger.WithFields(logrus.Fields{"action": "CanaryAnalysis"}).Infof("Rolling back the canary of release %s", canary.ReleaseName)
+	return AbortCanary(commonCluster, canary, CanaryRolledBack, fmt.Sprintf("SLO breached: %v is above %v", value, canary.Threshold))
+}
+
+// queryPrometheus returns the value of the instant query from the Prometheus service of the cluster,
+// ok is false if the query has no result
+func queryPrometheus(client *kubernetes.Clientset, query string) (float64, bool, error) {
+	data, err := client.CoreV1().Services(viper.GetString("canary.prometheusNamespace")).ProxyGet("http",
+		viper.GetString("canary.prometheusService"), viper.GetString("canary.prometheusPort"),
+		"api/v1/query", map[string]string{"query": query}).DoRaw()
+	if err != nil {
+		return 0, false, err
+	}
+	return parsePrometheusResponse(data)
+}
+
+// parsePrometheusResponse returns the first value of the vector or the scalar of the response of a query
+func parsePrometheusResponse(data []byte) (float64, bool, error) {
+	var response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return 0, false, err
+	}
+	if response.Status != "success" {
+		return 0, false, fmt.Errorf("prometheus query failed: %s", response.Error)
+	}
+	var sample []interface{}
+	switch response.Data.ResultType {
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(response.Data.Result, &vector); err != nil {
+			return 0, false, err
+		}
+		if len(vector) == 0 {
+			return 0, false, nil
+		}
+		sample = vector[0].Value
+	case "scalar":
+		if err := json.Unmarshal(response.Data.Result, &sample); err != nil {
+			return 0, false, err
+		}
+	default:
+		return 0, false, fmt.Errorf("the SLO query must return a vector or a scalar, not a %s", response.Data.ResultType)
+	}
+	if len(sample) != 2 {
+		return 0, false, fmt.Errorf("invalid prometheus sample: %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid prometheus sample: %v", sample)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return f, true, nil
+}
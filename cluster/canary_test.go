@@ -0,0 +1,50 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestValidateCanary(t *testing.T) {
+
+	canary := func(strategy string, weight int) model.CanaryModel {
+		return model.CanaryModel{Strategy: strategy, Weight: weight, Ingress: "web", StableService: "web", CanaryService: "web-canary"}
+	}
+	sameService := canary(cluster.StrategyCanary, 10)
+	sameService.CanaryService = "web"
+	thresholdOnly := canary(cluster.StrategyCanary, 10)
+	thresholdOnly.Threshold = 0.5
+
+	cases := []struct {
+		name        string
+		canary      model.CanaryModel
+		expectError bool
+	}{
+		{name: "canary", canary: canary(cluster.StrategyCanary, 10)},
+		{name: "blue-green", canary: canary(cluster.StrategyBlueGreen, 0)},
+		{name: "canary without traffic", canary: canary(cluster.StrategyCanary, 0), expectError: true},
+		{name: "canary with every request", canary: canary(cluster.StrategyCanary, 100), expectError: true},
+		{name: "blue-green with traffic", canary: canary(cluster.StrategyBlueGreen, 10), expectError: true},
+		{name: "unknown strategy", canary: canary("rolling", 10), expectError: true},
+		{name: "missing ingress", canary: model.CanaryModel{Strategy: cluster.StrategyCanary, Weight: 10}, expectError: true},
+		{name: "same services", canary: sameService, expectError: true},
+		{name: "threshold without query", canary: thresholdOnly, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateCanary(tc.canary)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateCanary: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package cluster
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Statuses of the certificates
+const (
+	CertificatePending  = "Pending"
+	CertificateReady    = "Ready"
+	CertificateExpiring = "Expiring"
+	CertificateExpired  = "Expired"
+	CertificateFailed   = "Failed"
+)
+
+const (
+	certManagerAPIVersion = "certmanager.k8s.io/v1alpha1"
+	// ClusterIssuerName is the ACME issuer of the certificates of the clusters with DNS
+	ClusterIssuerName      = "pipeline-acme"
+	clusterIssuerProvider  = "pipeline-dns"
+	clusterIssuerAccount   = "pipeline-acme-account"
+	clusterIssuerSecret    = "pipeline-dns-credentials"
+	certManagerCRDRetries  = 12
+	certManagerCRDInterval = 5 * time.Second
+)
+
+//ValidateCertificate checks the names of the certificate, the DNS names must be in the domain of the cluster
+func ValidateCertificate(certificate model.CertificateModel, domain string) error {
+	if errs := validation.IsDNS1123Subdomain(certificate.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid certificate name %q: %s", certificate.Name, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Label(certificate.Namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %q: %s", certificate.Namespace, strings.Join(errs, ", "))
+	}
+	names := certificate.GetDNSNames()
+	if len(names) == 0 {
+		return errors.New("the certificate has no DNS names")
+	}
+	for _, name := range names {
+		host := strings.TrimPrefix(name, "*.")
+		if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+			return fmt.Errorf("invalid DNS name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return fmt.Errorf("DNS name %s isn't in the domain %s of the cluster", name, domain)
+		}
+	}
+	return nil
+}
+
+//RequestCertificate creates the certificate resource issued by the ACME issuer of the cluster, cert-manager
+//stores the certificate in the secret of the certificate
+func RequestCertificate(commonCluster CommonCluster, certificate *model.CertificateModel) error {
+	log := logger.WithFields(logrus.Fields{"action": "RequestCertificate"})
+	if _, err := model.GetAddon(commonCluster.GetID(), AddonCertManager); err != nil {
+		if model.IsErrorGormNotFound(err) {
+			return fmt.Errorf("the %s add-on isn't installed", AddonCertManager)
+		}
+		return err
+	}
+	domain := commonCluster.GetModel().DNSDomain
+	if domain == "" {
+		return fmt.Errorf("cluster %s has no DNS", commonCluster.GetName())
+	}
+	if err := ValidateCertificate(*certificate, domain); err != nil {
+		return err
+	}
+	if certificate.SecretName == "" {
+		certificate.SecretName = certificate.Name + "-tls"
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{certificateResource(certificate)}, nil); err != nil {
+		return err
+	}
+	certificate.ClusterModelID = commonCluster.GetID()
+	certificate.Status = CertificatePending
+	certificate.Message = ""
+	if err := certificate.Save(); err != nil {
+		return err
+	}
+	log.Infof("Certificate %s/%s of cluster %s requested", certificate.Namespace, certificate.Name, commonCluster.GetName())
+	return nil
+}
+
+//CheckCertificate updates the status and the expiry of the certificate from the cluster, the certificates
+//expiring within the expiry warning of the config are Expiring
+func CheckCertificate(commonCluster CommonCluster, certificate *model.CertificateModel) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	certificate.CheckedAt = &now
+	live, err := helm.GetManifest(kubeConfig, certificateResource(certificate))
+	if k8sErrors.IsNotFound(err) {
+		certificate.Status, certificate.Message = CertificateFailed, "the certificate resource is missing from the cluster"
+		return certificate.Save()
+	} else if err != nil {
+		return err
+	}
+	ready, message := certificateCondition(live.Object)
+	certificate.Message = message
+
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return err
+	}
+	// cert-manager retries the failed orders, the issued certificate is kept until it's renewed
+	tlsSecret, err := client.CoreV1().Secrets(certificate.Namespace).Get(certificate.SecretName, metav1.GetOptions{})
+	if err == nil {
+		expiresAt, parseErr := certificateExpiry(tlsSecret.Data[v1.TLSCertKey])
+		if parseErr != nil {
+			return parseErr
+		}
+		certificate.ExpiresAt = &expiresAt
+	} else if !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	certificate.Status = certificateStatus(ready, certificate.ExpiresAt, now, viper.GetDuration("certificates.expiryWarning"))
+	return certificate.Save()
+}
+
+//DeleteCertificate deletes the certificate resource and its secret from the cluster
+func DeleteCertificate(commonCluster CommonCluster, certificate *model.CertificateModel) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	if err := helm.DeleteManifests(kubeConfig, []*unstructured.Unstructured{certificateResource(certificate)}); err != nil {
+		return err
+	}
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return err
+	}
+	err = client.CoreV1().Secrets(certificate.Namespace).Delete(certificate.SecretName, &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	return certificate.Delete()
+}
+
+//RunCertificateMonitor checks the certificates of the running clusters every interval, the certificates
+//expiring soon are logged
+func RunCertificateMonitor(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"action": "CertificateMonitor"})
+	for range time.Tick(interval) {
+		certificates, err := model.ListCertificates(0)
+		if err != nil {
+			log.Errorf("Error listing the certificates: %s", err.Error())
+			continue
+		}
+		for i := range certificates {
+			certificate := &certificates[i]
+			var modelCluster model.ClusterModel
+			if err := model.GetDB().First(&modelCluster, certificate.ClusterModelID).Error; err != nil {
+				if model.IsErrorGormNotFound(err) {
+					err = certificate.Delete()
+				}
+				if err != nil {
+					log.Warnf("Error loading cluster %d: %s", certificate.ClusterModelID, err.Error())
+				}
+				continue
+			}
+			commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+			if err != nil {
+				log.Warnf("Error loading cluster %s: %s", modelCluster.Name, err.Error())
+				continue
+			}
+			if ClusterStatus(commonCluster) != StatusRunning {
+				continue
+			}
+			if err := CheckCertificate(commonCluster, certificate); err != nil {
+				log.Warnf("Error checking certificate %s/%s of cluster %s: %s", certificate.Namespace, certificate.Name, modelCluster.Name, err.Error())
+				continue
+			}
+			switch certificate.Status {
+			case CertificateExpiring, CertificateExpired:
+				log.Warnf("Certificate %s/%s of cluster %s expires at %s", certificate.Namespace, certificate.Name, modelCluster.Name, certificate.ExpiresAt)
+			case CertificateFailed:
+				log.Warnf("Certificate %s/%s of cluster %s failed: %s", certificate.Namespace, certificate.Name, modelCluster.Name, certificate.Message)
+			}
+		}
+	}
+}
+
+//InstallCertManagerPostHook installs cert-manager with the ACME issuer on the clusters created with DNS,
+//if the ACME account email is configured
+func InstallCertManagerPostHook(commonCluster CommonCluster) {
+	log := logger.WithFields(logrus.Fields{"action": "InstallCertManager"})
+	if commonCluster.GetModel().DNSSecretID == "" || viper.GetString("certificates.acmeEmail") == "" {
+		return
+	}
+	if _, err := InstallAddon(commonCluster, AddonCertManager); err != nil {
+		log.Errorf("Deploying cert-manager failed due to: %s", err.Error())
+		return
+	}
+	log.Info("Cert-manager installed")
+}
+
+// configureCertManager creates the ACME cluster issuer solving the DNS-01 challenges in the zone of the
+// cluster with its DNS secret, the clusters without DNS have no issuer
+func configureCertManager(commonCluster CommonCluster) error {
+	if commonCluster.GetModel().DNSSecretID == "" {
+		return nil
+	}
+	if viper.GetString("certificates.acmeEmail") == "" {
+		return errors.New("the ACME account email of the certificates isn't configured")
+	}
+	issuer, credentials, err := clusterIssuer(commonCluster)
+	if err != nil {
+		return err
+	}
+	if err := applySecret(commonCluster, credentials); err != nil {
+		return errors.Wrap(err, "error applying the DNS credentials of the issuer")
+	}
+
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	// the custom resource definitions of the chart are registered after the install
+	for i := 0; ; i++ {
+		_, err = helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{issuer}, nil)
+		if err == nil || i == certManagerCRDRetries {
+			break
+		}
+		time.Sleep(certManagerCRDInterval)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error applying the cluster issuer")
+	}
+	return nil
+}
+
+// clusterIssuer returns the ACME cluster issuer of the cluster and the secret of its DNS credentials
+func clusterIssuer(commonCluster CommonCluster) (*unstructured.Unstructured, *v1.Secret, error) {
+	modelCluster := commonCluster.GetModel()
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), modelCluster.DNSSecretID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error getting the DNS secret")
+	}
+	provider := map[string]interface{}{"name": clusterIssuerProvider}
+	credentials := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterIssuerSecret, Namespace: helm.DefaultNamespace},
+		Data:       map[string][]byte{},
+	}
+	secretRef := func(key string) map[string]interface{} {
+		return map[string]interface{}{"name": clusterIssuerSecret, "key": key}
+	}
+	switch item.SecretType {
+	case secret.Amazon:
+		credentials.Data["secret-access-key"] = []byte(item.Values["AWS_SECRET_ACCESS_KEY"])
+		provider["route53"] = map[string]interface{}{
+			"region":                   viper.GetString("dns.awsRegion"),
+			"accessKeyID":              item.Values["AWS_ACCESS_KEY_ID"],
+			"secretAccessKeySecretRef": secretRef("secret-access-key"),
+		}
+	case secret.Google:
+		key, err := json.Marshal(item.Values)
+		if err != nil {
+			return nil, nil, err
+		}
+		credentials.Data["service-account.json"] = key
+		provider["clouddns"] = map[string]interface{}{
+			"project":                 item.Values["project_id"],
+			"serviceAccountSecretRef": secretRef("service-account.json"),
+		}
+	case secret.Azure:
+		credentials.Data["client-secret"] = []byte(item.Values["AZURE_CLIENT_SECRET"])
+		provider["azuredns"] = map[string]interface{}{
+			"clientID":              item.Values["AZURE_CLIENT_ID"],
+			"clientSecretSecretRef": secretRef("client-secret"),
+			"subscriptionID":        item.Values["AZURE_SUBSCRIPTION_ID"],
+			"tenantID":              item.Values["AZURE_TENANT_ID"],
+			"resourceGroupName":     viper.GetString("dns.azureResourceGroup"),
+			"hostedZoneName":        viper.GetString("dns.zone"),
+		}
+	default:
+		return nil, nil, fmt.Errorf("the DNS secret must be a %s, %s or %s, not a %s", secret.Amazon, secret.Google, secret.Azure, item.SecretType)
+	}
+
+	issuer := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": certManagerAPIVersion,
+		"kind":       "ClusterIssuer",
+		"metadata":   map[string]interface{}{"name": ClusterIssuerName},
+		"spec": map[string]interface{}{
+			"acme": map[string]interface{}{
+				"server":              viper.GetString("certificates.acmeServer"),
+				"email":               viper.GetString("certificates.acmeEmail"),
+				"privateKeySecretRef": map[string]interface{}{"name": clusterIssuerAccount},
+				"dns01":               map[string]interface{}{"providers": []interface{}{provider}},
+			},
+		},
+	}}
+	return issuer, credentials, nil
+}
+
+// certificateResource returns the cert-manager certificate of the certificate issued by the cluster issuer
+func certificateResource(certificate *model.CertificateModel) *unstructured.Unstructured {
+	names := []interface{}{}
+	for _, name := range certificate.GetDNSNames() {
+		names = append(names, name)
+	}
+	commonName := ""
+	if len(names) > 0 {
+		commonName = names[0].(string)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": certManagerAPIVersion,
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": certificate.Name, "namespace": certificate.Namespace},
+		"spec": map[string]interface{}{
+			"secretName": certificate.SecretName,
+			"issuerRef":  map[string]interface{}{"name": ClusterIssuerName, "kind": "ClusterIssuer"},
+			"commonName": commonName,
+			"dnsNames":   names,
+			"acme": map[string]interface{}{
+				"config": []interface{}{
+					map[string]interface{}{
+						"dns01":   map[string]interface{}{"provider": clusterIssuerProvider},
+						"domains": names,
+					},
+				},
+			},
+		},
+	}}
+}
+
+// certificateCondition returns the Ready condition of the status of the certificate resource
+func certificateCondition(object map[string]interface{}) (bool, string) {
+	status, _ := object["status"].(map[string]interface{})
+	conditions, _ := status["conditions"].([]interface{})
+	for _, item := range conditions {
+		condition, _ := item.(map[string]interface{})
+		if condition["type"] == "Ready" {
+			message, _ := condition["message"].(string)
+			return condition["status"] == "True", message
+		}
+	}
+	return false, "the certificate isn't issued yet"
+}
+
+// certificateExpiry returns the expiry of the first PEM certificate of the data
+func certificateExpiry(data []byte) (time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, errors.New("the secret of the certificate has no PEM certificate")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "error parsing the certificate")
+	}
+	return certificate.NotAfter, nil
+}
+
+// certificateStatus returns the status of the certificate by its Ready condition and its expiry, issued
+// certificates expiring within the warning are Expiring
+func certificateStatus(ready bool, expiresAt *time.Time, now time.Time, warning time.Duration) string {
+	switch {
+	case expiresAt == nil && ready:
+		return CertificateReady
+	case expiresAt == nil:
+		return CertificatePending
+	case !now.Before(*expiresAt):
+		return CertificateExpired
+	case expiresAt.Sub(now) <= warning:
+		return CertificateExpiring
+	case ready:
+		return CertificateReady
+	}
+	return CertificatePending
+}
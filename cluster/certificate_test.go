@@ -0,0 +1,42 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestValidateCertificate(t *testing.T) {
+
+	cases := []struct {
+		name      string
+		namespace string
+		dnsNames  []string
+		isError   bool
+	}{
+		{name: "web", namespace: "default", dnsNames: []string{"www.acme.pipeline.example.com"}, isError: false},
+		{name: "domain", namespace: "default", dnsNames: []string{"acme.pipeline.example.com"}, isError: false},
+		{name: "wildcard", namespace: "default", dnsNames: []string{"*.acme.pipeline.example.com"}, isError: false},
+		{name: "other domain", namespace: "default", dnsNames: []string{"www.example.com"}, isError: true},
+		{name: "suffix", namespace: "default", dnsNames: []string{"notacme.pipeline.example.com"}, isError: true},
+		{name: "no names", namespace: "default", dnsNames: nil, isError: true},
+		{name: "Invalid_Name", namespace: "default", dnsNames: []string{"www.acme.pipeline.example.com"}, isError: true},
+		{name: "namespace", namespace: "kube.system", dnsNames: []string{"www.acme.pipeline.example.com"}, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			certificate := model.CertificateModel{Name: tc.name, Namespace: tc.namespace}
+			certificate.SetDNSNames(tc.dnsNames)
+			err := cluster.ValidateCertificate(certificate, "acme.pipeline.example.com")
+			if tc.isError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+			} else if err != nil {
+				t.Errorf("Error during ValidateCertificate: %s", err.Error())
+			}
+		})
+	}
+}
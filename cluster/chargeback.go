@@ -0,0 +1,212 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// costDateFormat is the format of the days of the cluster costs
+const costDateFormat = "2006-01-02"
+
+// the costs of the managed control plane have no node pool
+const controlPlaneNodePool = ""
+
+//RunCostTracking records the spend of the clusters with the given interval, it never returns
+func RunCostTracking(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"action": "CostTracking"})
+	for range time.Tick(interval) {
+		if err := RecordClusterCosts(interval); err != nil {
+			log.Errorf("Error recording the cluster costs: %s", err.Error())
+		}
+	}
+}
+
+//RecordClusterCosts adds the spend of the running clusters in the last interval to their daily costs. The spend of a
+//node pool is its instance hours (the nodes of the pool in Kubernetes, the stored node count if the nodes can't be
+//listed) with the current price of its instances, the fee of the managed control plane is added too.
+func RecordClusterCosts(interval time.Duration) error {
+	log := logger.WithFields(logrus.Fields{"action": "CostTracking"})
+	var clusters []model.ClusterModel
+	err := model.GetDB().Where("status IN (?)", []string{StatusRunning, StatusUpdating}).Find(&clusters).Error
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC().Format(costDateFormat)
+	hours := interval.Hours()
+	for i := range clusters {
+		commonCluster, err := GetCommonClusterFromModel(&clusters[i])
+		if err != nil {
+			log.Warnf("Error loading cluster %s: %s", clusters[i].Name, err.Error())
+			continue
+		}
+		costs, err := clusterCosts(commonCluster, hours)
+		if err != nil {
+			log.Warnf("The costs of cluster %s aren't recorded: %s", commonCluster.GetName(), err.Error())
+			continue
+		}
+		for _, cost := range costs {
+			cost.Date = date
+			if err := model.AddClusterCost(cost); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clusterCosts returns the spend of the node pools and the control plane of the cluster in the hours
+func clusterCosts(commonCluster CommonCluster, hours float64) ([]model.ClusterCostModel, error) {
+	log := logger.WithFields(logrus.Fields{"action": "CostTracking"})
+	modelCluster := commonCluster.GetModel()
+	newCost := func(pool, instanceType string, instanceHours, cost float64) model.ClusterCostModel {
+		return model.ClusterCostModel{
+			OrganizationID: modelCluster.OrganizationId,
+			ClusterModelID: modelCluster.ID,
+			NodePool:       pool,
+			ClusterName:    modelCluster.Name,
+			Cloud:          modelCluster.Cloud,
+			InstanceType:   instanceType,
+			InstanceHours:  instanceHours,
+			Cost:           cost,
+		}
+	}
+	estimate, err := EstimateCost(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	costs := []model.ClusterCostModel{newCost(controlPlaneNodePool, "", 0, estimate.ControlPlane/hoursPerMonth*hours)}
+	nodes, err := nodePoolNodes(commonCluster)
+	if err != nil {
+		log.Warnf("The stored node counts of cluster %s are used: %s", commonCluster.GetName(), err.Error())
+	}
+	for _, pool := range estimate.NodePools {
+		count := pool.Nodes
+		if nodes != nil {
+			count = nodes[pool.Name]
+		}
+		instanceHours := float64(count) * hours
+		costs = append(costs, newCost(pool.Name, pool.InstanceType, instanceHours, instanceHours*pool.HourlyPrice))
+	}
+	return costs, nil
+}
+
+// nodePoolNodes counts the nodes of the node pools of the cluster in Kubernetes
+func nodePoolNodes(commonCluster CommonCluster) (map[string]int, error) {
+	manager, ok := commonCluster.(NodePoolManager)
+	if !ok {
+		return nil, nil
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	pools := commonCluster.GetModel().NodePools
+	if len(pools) == 0 {
+		pools = defaultNodePools(commonCluster)
+	}
+	nodes := make(map[string]int, len(pools))
+	for _, pool := range pools {
+		list, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: manager.NodePoolSelector(pool.Name)})
+		if err != nil {
+			return nil, err
+		}
+		nodes[pool.Name] = len(list.Items)
+	}
+	return nodes, nil
+}
+
+//NodePoolSpend is the spend of a node pool in a cost report
+type NodePoolSpend struct {
+	Name          string  `json:"name"`
+	InstanceHours float64 `json:"instanceHours"`
+	Cost          float64 `json:"cost"`
+}
+
+//ClusterSpend is the spend of a cluster in a cost report, the control plane has no node pool
+type ClusterSpend struct {
+	ClusterID uint            `json:"clusterId"`
+	Name      string          `json:"name"`
+	Cloud     string          `json:"cloud"`
+	Cost      float64         `json:"cost"`
+	NodePools []NodePoolSpend `json:"nodePools"`
+}
+
+//CostReport is the spend of the clusters of an organization between two days for chargeback
+type CostReport struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Currency string         `json:"currency"`
+	Cost     float64        `json:"cost"`
+	Clusters []ClusterSpend `json:"clusters"`
+}
+
+//NewCostReport sums the daily costs per cluster and node pool, the clusters are ordered by their spend
+func NewCostReport(from, to string, costs []model.ClusterCostModel) *CostReport {
+	report := &CostReport{From: from, To: to, Currency: pricingCurrency, Clusters: []ClusterSpend{}}
+	clusters := map[uint]*ClusterSpend{}
+	pools := map[uint]map[string]*NodePoolSpend{}
+	for _, cost := range costs {
+		spend, ok := clusters[cost.ClusterModelID]
+		if !ok {
+			spend = &ClusterSpend{ClusterID: cost.ClusterModelID, Name: cost.ClusterName, Cloud: cost.Cloud}
+			clusters[cost.ClusterModelID] = spend
+			pools[cost.ClusterModelID] = map[string]*NodePoolSpend{}
+		}
+		pool, ok := pools[cost.ClusterModelID][cost.NodePool]
+		if !ok {
+			pool = &NodePoolSpend{Name: cost.NodePool}
+			pools[cost.ClusterModelID][cost.NodePool] = pool
+		}
+		pool.InstanceHours += cost.InstanceHours
+		pool.Cost += cost.Cost
+		spend.Cost += cost.Cost
+		report.Cost += cost.Cost
+	}
+	for id, spend := range clusters {
+		for _, pool := range pools[id] {
+			spend.NodePools = append(spend.NodePools, *pool)
+		}
+		sort.Slice(spend.NodePools, func(i, j int) bool { return spend.NodePools[i].Name < spend.NodePools[j].Name })
+		report.Clusters = append(report.Clusters, *spend)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		if report.Clusters[i].Cost != report.Clusters[j].Cost {
+			return report.Clusters[i].Cost > report.Clusters[j].Cost
+		}
+		return report.Clusters[i].ClusterID < report.Clusters[j].ClusterID
+	})
+	return report
+}
+
+//CostReportDays parses the days of a cost report, the report is the current month by default
+func CostReportDays(from, to string) (string, string, error) {
+	now := time.Now().UTC()
+	if from == "" {
+		from = now.AddDate(0, 0, 1-now.Day()).Format(costDateFormat)
+	}
+	if to == "" {
+		to = now.Format(costDateFormat)
+	}
+	fromDay, err := time.Parse(costDateFormat, from)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid day %s, the days are formatted like %s", from, costDateFormat)
+	}
+	toDay, err := time.Parse(costDateFormat, to)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid day %s, the days are formatted like %s", to, costDateFormat)
+	}
+	if toDay.Before(fromDay) {
+		return "", "", fmt.Errorf("the report ends on %s before its first day %s", to, from)
+	}
+	return from, to, nil
+}
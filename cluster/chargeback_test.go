@@ -0,0 +1,67 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestNewCostReport(t *testing.T) {
+
+	costs := []model.ClusterCostModel{
+		{ClusterModelID: 1, ClusterName: "small", NodePool: "", Date: "2026-10-01", Cost: 2.4},
+		{ClusterModelID: 1, ClusterName: "small", NodePool: "pool1", Date: "2026-10-01", InstanceHours: 24, Cost: 4.8},
+		{ClusterModelID: 2, ClusterName: "large", NodePool: "pool1", Date: "2026-10-01", InstanceHours: 48, Cost: 10},
+		{ClusterModelID: 1, ClusterName: "small", NodePool: "pool1", Date: "2026-10-02", InstanceHours: 12, Cost: 2.4},
+	}
+	report := cluster.NewCostReport("2026-10-01", "2026-10-02", costs)
+
+	if len(report.Clusters) != 2 {
+		t.Fatalf("Expected 2 clusters, got: %v", report.Clusters)
+	}
+	if report.Clusters[0].Name != "large" {
+		t.Errorf("Expected the most expensive cluster first, got: %s", report.Clusters[0].Name)
+	}
+	small := report.Clusters[1]
+	if len(small.NodePools) != 2 || small.NodePools[1].InstanceHours != 36 {
+		t.Errorf("Expected the control plane and the 36 instance hours of pool1, got: %v", small.NodePools)
+	}
+	if diff := report.Cost - 19.6; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected total cost 19.6, got: %f", report.Cost)
+	}
+}
+
+func TestCostReportDays(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		from        string
+		to          string
+		expectError bool
+	}{
+		{name: "current month", from: "", to: ""},
+		{name: "range", from: "2026-09-01", to: "2026-09-30"},
+		{name: "single day", from: "2026-09-01", to: "2026-09-01"},
+		{name: "reversed", from: "2026-09-30", to: "2026-09-01", expectError: true},
+		{name: "invalid day", from: "09/01/2026", to: "2026-09-30", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to, err := cluster.CostReportDays(tc.from, tc.to)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during CostReportDays: %s", err.Error())
+			}
+			if from > to {
+				t.Errorf("Expected %s before %s", from, to)
+			}
+		})
+	}
+}
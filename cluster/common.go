@@ -11,6 +11,7 @@ import (
 	"github.com/banzaicloud/banzai-types/constants"
 	"github.com/banzaicloud/pipeline/model"
 	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/terminal"
@@ -40,11 +41,68 @@ type CommonCluster interface {
 	GetOrg() uint
 }
 
+//AsyncCluster is implemented by the clusters whose CreateCluster returns before the cluster is provisioned
+type AsyncCluster interface {
+	WaitForCluster() error
+}
+
 func GetSecret(cluster CommonCluster) (*secret.SecretsItemResponse, error) {
 	org := strconv.FormatUint(uint64(cluster.GetOrg()), 10)
 	return secret.Store.Get(org, cluster.GetSecretID())
 }
 
+// loadK8sConfig decrypts the Kubernetes config of the cluster stored in the database, nil if it isn't stored
+func loadK8sConfig(modelCluster *model.ClusterModel) *[]byte {
+	if secret.Encryption == nil || modelCluster.KubeConfig == "" {
+		return nil
+	}
+	config, err := secret.Encryption.Decrypt(modelCluster.KubeConfig)
+	if err != nil {
+		log.Warnf("Failed to decrypt the stored kubeconfig of cluster %d: %s", modelCluster.ID, err.Error())
+		return nil
+	}
+	return &config
+}
+
+// storeK8sConfig encrypts the Kubernetes config of the cluster and stores it in the database,
+// the config isn't stored if encryption is disabled
+func storeK8sConfig(modelCluster *model.ClusterModel, config []byte) {
+	if secret.Encryption == nil || modelCluster.ID == 0 {
+		return
+	}
+	encrypted, err := secret.Encryption.Encrypt(config)
+	if err == nil {
+		err = model.GetDB().Model(modelCluster).UpdateColumn("kube_config", encrypted).Error
+	}
+	if err != nil {
+		log.Warnf("Failed to store the kubeconfig of cluster %d: %s", modelCluster.ID, err.Error())
+	}
+}
+
+// RewrapK8sConfigs re-encrypts the data keys of the stored Kubernetes configs with the latest version of the transit key
+func RewrapK8sConfigs() (int, error) {
+	if secret.Encryption == nil {
+		return 0, nil
+	}
+	var clusters []model.ClusterModel
+	database := model.GetDB()
+	if err := database.Where("kube_config <> ''").Find(&clusters).Error; err != nil {
+		return 0, err
+	}
+	rewrapped := 0
+	for i := range clusters {
+		kubeConfig, err := secret.Encryption.Rewrap(clusters[i].KubeConfig)
+		if err == nil {
+			err = database.Model(&clusters[i]).UpdateColumn("kube_config", kubeConfig).Error
+		}
+		if err != nil {
+			return rewrapped, errors.Wrapf(err, "error rewrapping the kubeconfig of cluster %d", clusters[i].ID)
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
 //GetCommonClusterFromModel extracts CommonCluster from a ClusterModel
 func GetCommonClusterFromModel(modelCluster *model.ClusterModel) (CommonCluster, error) {
 
@@ -74,6 +132,9 @@ func GetCommonClusterFromModel(modelCluster *model.ClusterModel) (CommonCluster,
 
 		log.Info("Load Azure props from database")
 		database.Where(model.AzureClusterModel{ClusterModelId: aksCluster.modelCluster.ID}).First(&aksCluster.modelCluster.Azure)
+		if err := aksCluster.modelCluster.LoadNodePools(); err != nil {
+			return nil, err
+		}
 
 		return aksCluster, nil
 
@@ -86,8 +147,28 @@ func GetCommonClusterFromModel(modelCluster *model.ClusterModel) (CommonCluster,
 
 		log.Info("Load Google props from database")
 		database.Where(model.AzureClusterModel{ClusterModelId: gkeCluster.modelCluster.ID}).First(&gkeCluster.modelCluster.Google)
+		if err := gkeCluster.modelCluster.LoadNodePools(); err != nil {
+			return nil, err
+		}
 
 		return gkeCluster, nil
+
+	case EKS:
+		eksCluster, err := CreateEKSClusterFromModel(modelCluster)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info("Load EKS props from database")
+		database.Where(model.EKSClusterModel{ClusterModelId: eksCluster.modelCluster.ID}).First(&eksCluster.modelCluster.EKS)
+		if err := eksCluster.modelCluster.LoadNodePools(); err != nil {
+			return nil, err
+		}
+
+		return eksCluster, nil
+
+	case Kubernetes:
+		return CreateKubernetesClusterFromModel(modelCluster)
 	}
 	return nil, constants.ErrorNotSupportedCloudType
 }
@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the range of a field of a cron expression
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+// the fields of the cron expressions: minute, hour, day of month, month and day of week (0 and 7 are Sunday)
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// cronSchedule is a parsed cron expression, the bits of a field are the values it matches
+type cronSchedule struct {
+	fields [5]uint64
+	// the days match the day of month or the day of week if both are restricted
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// parseCron parses a standard five field cron expression, the fields are lists of values, ranges (1-5),
+// steps (*/15, 0-30/10) or *
+func parseCron(expression string) (*cronSchedule, error) {
+	parts := strings.Fields(expression)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: %d fields instead of %d", expression, len(parts), len(cronFields))
+	}
+	schedule := &cronSchedule{anyDayOfMonth: parts[2] == "*", anyDayOfWeek: parts[4] == "*"}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s", expression, err.Error())
+		}
+		schedule.fields[i] = bits
+	}
+	// Sunday is both 0 and 7
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1
+	}
+	return schedule, nil
+}
+
+// parseCronField returns the bits of the values of the comma separated list of the field
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step of the %s: %s", field.name, item)
+			}
+			step = n
+			item = item[:i]
+		}
+		from, to := field.min, field.max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid %s: %s", field.name, bounds[0])
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid %s: %s", field.name, bounds[1])
+				}
+			} else if step > 1 {
+				to = field.max
+			}
+		}
+		if from < field.min || to > field.max || from > to {
+			return 0, fmt.Errorf("the %s must be between %d and %d: %s", field.name, field.min, field.max, value)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matches checks whether the schedule runs in the minute of the time
+func (s *cronSchedule) matches(t time.Time) bool {
+	has := func(field int, value int) bool {
+		return s.fields[field]&(1<<uint(value)) != 0
+	}
+	if !has(0, t.Minute()) || !has(1, t.Hour()) || !has(3, int(t.Month())) {
+		return false
+	}
+	dayOfMonth, dayOfWeek := has(2, t.Day()), has(4, int(t.Weekday()))
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
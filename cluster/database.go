@@ -0,0 +1,472 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Engines of the managed databases
+const (
+	DatabaseEnginePostgreSQL = "postgresql"
+	DatabaseEngineMySQL      = "mysql"
+)
+
+// Providers of the managed databases, the cluster provider installs the database chart of the engine into the
+// cluster
+const (
+	DatabaseProviderAmazon  = "amazon"
+	DatabaseProviderGoogle  = "google"
+	DatabaseProviderAzure   = "azure"
+	DatabaseProviderCluster = "cluster"
+)
+
+// Statuses of the managed databases
+const (
+	DatabaseStatusCreating = "CREATING"
+	DatabaseStatusReady    = "READY"
+	DatabaseStatusError    = "ERROR"
+)
+
+// databaseUsername is the user the applications log in with
+const databaseUsername = "app"
+
+// databaseSecretTypes are the types of the cloud secrets of the providers
+var databaseSecretTypes = map[string]string{
+	DatabaseProviderAmazon: secret.Amazon,
+	DatabaseProviderGoogle: secret.Google,
+	DatabaseProviderAzure:  secret.Azure,
+}
+
+// databaseSettings are the known settings of the providers, the required ones are true: the region of RDS and
+// Cloud SQL, the resource group and the location of Azure; the optional subnet group and comma separated
+// security groups of RDS and the VPC network of the private IP on Cloud SQL
+var databaseSettings = map[string]map[string]bool{
+	DatabaseProviderAmazon:  {"region": true, "subnetGroup": false, "securityGroupIds": false},
+	DatabaseProviderGoogle:  {"region": true, "project": false, "network": false},
+	DatabaseProviderAzure:   {"resourceGroup": true, "location": true},
+	DatabaseProviderCluster: {},
+}
+
+// databaseInstanceTypes are the default instance types of the providers
+var databaseInstanceTypes = map[string]string{
+	DatabaseProviderAmazon: "db.t2.micro",
+	DatabaseProviderGoogle: "db-f1-micro",
+	DatabaseProviderAzure:  "B_Gen5_1",
+}
+
+// databaseVersions are the default versions of the engines on the providers, the version is the image tag of
+// the chart of the cluster provider which has its own default
+var databaseVersions = map[string]map[string]string{
+	DatabaseProviderGoogle: {DatabaseEnginePostgreSQL: "9.6", DatabaseEngineMySQL: "5.7"},
+	DatabaseProviderAzure:  {DatabaseEnginePostgreSQL: "10", DatabaseEngineMySQL: "5.7"},
+}
+
+// databasePorts are the ports of the engines
+var databasePorts = map[string]int{
+	DatabaseEnginePostgreSQL: 5432,
+	DatabaseEngineMySQL:      3306,
+}
+
+// the database names are DNS labels, every provider accepts them as instance names
+var databaseNamePattern = regexp.MustCompile(`^[a-z]([a-z0-9]|-[a-z0-9])*$`)
+
+// DatabaseRequest describes a database of the applications of a cluster, the connection secret is injected
+// into the namespace (default) once the database is ready
+type DatabaseRequest struct {
+	Name         string            `json:"name" binding:"required"`
+	Engine       string            `json:"engine" binding:"required"`
+	Provider     string            `json:"provider" binding:"required"`
+	Version      string            `json:"version,omitempty"`
+	InstanceType string            `json:"instanceType,omitempty"`
+	StorageGB    int               `json:"storageGb,omitempty"`
+	SecretID     string            `json:"secretId,omitempty"`
+	Settings     map[string]string `json:"settings,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	// SecretName is the name of the connection secret in the namespace, <name>-database by default
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// DatabaseNotFoundError is returned for the unknown databases of a cluster
+type DatabaseNotFoundError struct {
+	Name string
+}
+
+func (e DatabaseNotFoundError) Error() string {
+	return fmt.Sprintf("database %s not found", e.Name)
+}
+
+// ValidateDatabaseRequest checks the request and sets its defaults
+func ValidateDatabaseRequest(request *DatabaseRequest) error {
+	if len(request.Name) > 40 || !databaseNamePattern.MatchString(request.Name) {
+		return fmt.Errorf("invalid database name: %q", request.Name)
+	}
+	if _, ok := databasePorts[request.Engine]; !ok {
+		return fmt.Errorf("the engine must be %s or %s", DatabaseEnginePostgreSQL, DatabaseEngineMySQL)
+	}
+	known, ok := databaseSettings[request.Provider]
+	if !ok {
+		return fmt.Errorf("the provider must be %s, %s, %s or %s", DatabaseProviderAmazon, DatabaseProviderGoogle,
+			DatabaseProviderAzure, DatabaseProviderCluster)
+	}
+	for name, required := range known {
+		if required && request.Settings[name] == "" {
+			return fmt.Errorf("the %s databases require the %s setting", request.Provider, name)
+		}
+	}
+	for name := range request.Settings {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown setting of the %s databases: %s", request.Provider, name)
+		}
+	}
+	if request.Provider == DatabaseProviderCluster {
+		if request.SecretID != "" {
+			return errors.New("the databases in the cluster don't need a cloud secret")
+		}
+	} else if request.SecretID == "" {
+		return fmt.Errorf("the %s databases require a cloud secret", request.Provider)
+	}
+	if request.StorageGB < 0 {
+		return errors.New("the storage must be positive")
+	} else if request.StorageGB == 0 {
+		request.StorageGB = viper.GetInt("databases.storageGb")
+	}
+	if request.InstanceType == "" {
+		request.InstanceType = databaseInstanceTypes[request.Provider]
+	}
+	if request.Version == "" {
+		request.Version = databaseVersions[request.Provider][request.Engine]
+	}
+	if request.Namespace == "" {
+		request.Namespace = helm.DefaultNamespace
+	}
+	if request.SecretName == "" {
+		request.SecretName = request.Name + "-database"
+	}
+	return nil
+}
+
+// databaseIdentifier returns the name of the instance of the database: the cluster name and the database name
+// at the providers, the database name with a db suffix as the release name in the cluster
+func databaseIdentifier(clusterName string, request *DatabaseRequest) string {
+	if request.Provider == DatabaseProviderCluster {
+		return request.Name + "-db"
+	}
+	identifier := strings.Trim(strings.ToLower(clusterName+"-"+request.Name), "-")
+	if len(identifier) > 63 {
+		identifier = strings.TrimRight(identifier[:63], "-")
+	}
+	return identifier
+}
+
+// databaseName returns the name of the database in the instance
+func databaseName(database *model.ManagedDatabaseModel) string {
+	return strings.Replace(database.Name, "-", "_", -1)
+}
+
+// DatabaseURL returns the connection URL of the database
+func DatabaseURL(engine, username, password, host string, port int, name string) string {
+	scheme := "postgresql"
+	if engine == DatabaseEngineMySQL {
+		scheme = "mysql"
+	}
+	u := url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(username, password),
+		Host:   fmt.Sprintf("%s:%d", host, port),
+		Path:   "/" + name,
+	}
+	return u.String()
+}
+
+// ListDatabases lists the databases of the cluster
+func ListDatabases(commonCluster CommonCluster) ([]model.ManagedDatabaseModel, error) {
+	return model.ListManagedDatabases(commonCluster.GetID())
+}
+
+// GetDatabase returns the database of the cluster
+func GetDatabase(commonCluster CommonCluster, name string) (*model.ManagedDatabaseModel, error) {
+	database, err := model.GetManagedDatabase(commonCluster.GetID(), name)
+	if model.IsErrorGormNotFound(err) {
+		return nil, DatabaseNotFoundError{Name: name}
+	}
+	return database, err
+}
+
+// EnsureDatabase starts the creation of the database of the request for the cluster, the database is returned
+// unchanged if it already exists; created is false then. The connection secret is injected by the database
+// provisioner once the database is ready.
+func EnsureDatabase(commonCluster CommonCluster, createdBy string, request DatabaseRequest) (database *model.ManagedDatabaseModel, created bool, err error) {
+	if err := ValidateDatabaseRequest(&request); err != nil {
+		return nil, false, err
+	}
+	database, err = model.GetManagedDatabase(commonCluster.GetID(), request.Name)
+	if err == nil {
+		return database, false, nil
+	} else if !model.IsErrorGormNotFound(err) {
+		return nil, false, err
+	}
+	database = &model.ManagedDatabaseModel{
+		OrganizationID: commonCluster.GetOrg(),
+		ClusterID:      commonCluster.GetID(),
+		Name:           request.Name,
+		Identifier:     databaseIdentifier(commonCluster.GetName(), &request),
+		Engine:         request.Engine,
+		Provider:       request.Provider,
+		Version:        request.Version,
+		InstanceType:   request.InstanceType,
+		StorageGB:      request.StorageGB,
+		CloudSecretID:  request.SecretID,
+		Namespace:      request.Namespace,
+		SecretName:     request.SecretName,
+		Status:         DatabaseStatusCreating,
+		CreatedBy:      createdBy,
+	}
+	database.SetSettings(request.Settings)
+	provider, err := newDatabaseProvider(commonCluster, database)
+	if err != nil {
+		return nil, false, err
+	}
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return nil, false, err
+	}
+	password := hex.EncodeToString(data)
+	database.SecretID = secret.GenerateSecretID()
+	err = secret.Store.Store(strconv.FormatUint(uint64(database.OrganizationID), 10), database.SecretID, secret.CreateSecretRequest{
+		Name:       fmt.Sprintf("cluster-%s-database-%s", commonCluster.GetName(), database.Name),
+		SecretType: secret.Password,
+		Values: map[string]string{
+			"username": databaseUsername,
+			"password": password,
+			"engine":   database.Engine,
+			"database": databaseName(database),
+		},
+	})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error storing the database password")
+	}
+	if err := database.Save(); err != nil {
+		return nil, false, err
+	}
+	if err := provider.createDatabase(database, databaseUsername, password); err != nil {
+		database.Status = DatabaseStatusError
+		database.StatusMessage = err.Error()
+		if err := database.Save(); err != nil {
+			logger.Errorf("Error saving the status of database %s: %s", database.Name, err.Error())
+		}
+		return nil, false, errors.Wrapf(err, "error creating the %s database %s", database.Provider, database.Name)
+	}
+	return database, true, nil
+}
+
+// DeleteDatabase deletes the instance, the connection secret and the password of the database of the cluster;
+// the RDS instances keep a final snapshot, the volumes of the databases in the cluster are kept
+func DeleteDatabase(commonCluster CommonCluster, name string) error {
+	database, err := GetDatabase(commonCluster, name)
+	if err != nil {
+		return err
+	}
+	provider, err := newDatabaseProvider(commonCluster, database)
+	if err != nil {
+		return err
+	}
+	if err := provider.deleteDatabase(database); err != nil {
+		return errors.Wrapf(err, "error deleting the %s database %s", database.Provider, database.Name)
+	}
+	if err := RemoveInjectedSecret(commonCluster, database.Namespace, database.SecretName); err != nil && !model.IsErrorGormNotFound(err) {
+		return err
+	}
+	err = secret.Store.Delete(strconv.FormatUint(uint64(database.OrganizationID), 10), database.SecretID)
+	if err != nil && err != secret.ErrSecretNotFound {
+		return err
+	}
+	return database.Delete()
+}
+
+// checkDatabase finishes the setup of the database once its instance is ready: the address is added to the
+// password secret, the secret is injected into the namespace of the database and the database becomes ready
+func checkDatabase(commonCluster CommonCluster, database *model.ManagedDatabaseModel) (bool, error) {
+	provider, err := newDatabaseProvider(commonCluster, database)
+	if err != nil {
+		return false, err
+	}
+	organizationID := strconv.FormatUint(uint64(database.OrganizationID), 10)
+	item, err := secret.Store.Get(organizationID, database.SecretID)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting the database password")
+	}
+	values := item.Values
+	address, err := provider.databaseAddress(database, values["username"], values["password"])
+	if err != nil || address == nil {
+		return false, err
+	}
+	values["username"] = address.Username
+	values["host"] = address.Host
+	values["port"] = strconv.Itoa(address.Port)
+	values["url"] = DatabaseURL(database.Engine, address.Username, values["password"], address.Host, address.Port, values["database"])
+	err = secret.Store.Store(organizationID, database.SecretID, secret.CreateSecretRequest{
+		Name:       item.Name,
+		SecretType: item.SecretType,
+		Values:     values,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "error storing the database address")
+	}
+	if _, err := InjectSecret(commonCluster, database.SecretID, database.Namespace, database.SecretName); err != nil {
+		return false, errors.Wrap(err, "error injecting the database secret")
+	}
+	return true, nil
+}
+
+// RunDatabaseProvisioner checks the databases being created in every interval, the databases which aren't
+// ready in the databases.createTimeout fail
+func RunDatabaseProvisioner(interval time.Duration) {
+	for range time.Tick(interval) {
+		checkDatabases()
+	}
+}
+
+// checkDatabases checks the databases being created once
+func checkDatabases() {
+	log := logger.WithFields(logrus.Fields{"action": "DatabaseProvisioner"})
+	databases, err := model.ListManagedDatabasesInStatus(DatabaseStatusCreating)
+	if err != nil {
+		log.Errorf("Error listing the databases: %s", err.Error())
+		return
+	}
+	for i := range databases {
+		database := &databases[i]
+		var modelCluster model.ClusterModel
+		if err := model.GetDB().First(&modelCluster, database.ClusterID).Error; err != nil {
+			log.Warnf("Error loading cluster %d of database %s: %s", database.ClusterID, database.Name, err.Error())
+			continue
+		}
+		commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+		if err != nil {
+			log.Warnf("Error loading cluster %s: %s", modelCluster.Name, err.Error())
+			continue
+		}
+		ready, err := checkDatabase(commonCluster, database)
+		if err == nil && !ready {
+			if time.Since(database.CreatedAt) < viper.GetDuration("databases.createTimeout") {
+				continue
+			}
+			err = errors.New("the database isn't ready in time")
+		}
+		if err != nil {
+			log.Errorf("Error provisioning database %s of cluster %s: %s", database.Name, modelCluster.Name, err.Error())
+			database.Status = DatabaseStatusError
+			database.StatusMessage = err.Error()
+		} else {
+			log.Infof("Database %s of cluster %s is ready", database.Name, modelCluster.Name)
+			database.Status = DatabaseStatusReady
+			database.StatusMessage = ""
+		}
+		if err := database.Save(); err != nil {
+			log.Errorf("Error saving database %s: %s", database.Name, err.Error())
+		}
+	}
+}
+
+// clusterDatabases installs the database charts of the engines into the cluster
+type clusterDatabases struct {
+	commonCluster CommonCluster
+}
+
+// databaseChartValues returns the values of the chart of the engine with the user, the database, the size of
+// the volume and the image tag of the version
+func databaseChartValues(database *model.ManagedDatabaseModel, username, password string) ([]byte, error) {
+	values := map[string]interface{}{
+		"persistence": map[string]interface{}{"size": fmt.Sprintf("%dGi", database.StorageGB)},
+	}
+	if database.Version != "" {
+		values["imageTag"] = database.Version
+	}
+	if database.Engine == DatabaseEngineMySQL {
+		data := make([]byte, 16)
+		if _, err := rand.Read(data); err != nil {
+			return nil, err
+		}
+		values["mysqlUser"] = username
+		values["mysqlPassword"] = password
+		values["mysqlRootPassword"] = hex.EncodeToString(data)
+		values["mysqlDatabase"] = databaseName(database)
+	} else {
+		values["postgresUser"] = username
+		values["postgresPassword"] = password
+		values["postgresDatabase"] = databaseName(database)
+	}
+	return yaml.Marshal(values)
+}
+
+// serviceName returns the name of the service of the release of the chart
+func (d *clusterDatabases) serviceName(database *model.ManagedDatabaseModel) string {
+	return database.Identifier + "-" + database.Engine
+}
+
+// createDatabase implements databaseProvider
+func (d *clusterDatabases) createDatabase(database *model.ManagedDatabaseModel, username, password string) error {
+	kubeConfig, err := d.commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	values, err := databaseChartValues(database, username, password)
+	if err != nil {
+		return err
+	}
+	_, err = helm.CreateDeployment(viper.GetString("databases.charts."+database.Engine), database.Identifier, values,
+		kubeConfig, d.commonCluster.GetName())
+	return err
+}
+
+// databaseAddress implements databaseProvider, the database is ready once its service has a ready endpoint
+func (d *clusterDatabases) databaseAddress(database *model.ManagedDatabaseModel, username, password string) (*databaseAddress, error) {
+	kubeConfig, err := d.commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	name := d.serviceName(database)
+	endpoints, err := client.CoreV1().Endpoints(helm.DefaultNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			host := fmt.Sprintf("%s.%s.svc.cluster.local", name, helm.DefaultNamespace)
+			return &databaseAddress{Host: host, Port: databasePorts[database.Engine], Username: username}, nil
+		}
+	}
+	return nil, nil
+}
+
+// deleteDatabase implements databaseProvider, the persistent volume claims of the release are kept
+func (d *clusterDatabases) deleteDatabase(database *model.ManagedDatabaseModel) error {
+	kubeConfig, err := d.commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	err = helm.DeleteDeployment(database.Identifier, kubeConfig)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return nil
+	}
+	return err
+}
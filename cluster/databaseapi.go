@@ -0,0 +1,479 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	rdsAPIVersion        = "2014-10-31"
+	cloudSQLBasePath     = "https://sqladmin.googleapis.com/sql/v1beta4"
+	azureDBAPIVersion    = "2017-12-01"
+	databaseHTTPTimeout  = 30 * time.Second
+	rdsStatusAvailable   = "available"
+	cloudSQLStateRunning = "RUNNABLE"
+	azureDBStateReady    = "Ready"
+)
+
+// rdsFailedStatuses are the statuses of the RDS instances which won't become available
+var rdsFailedStatuses = map[string]bool{
+	"failed":                              true,
+	"incompatible-network":                true,
+	"incompatible-parameters":             true,
+	"incompatible-restore":                true,
+	"inaccessible-encryption-credentials": true,
+	"storage-full":                        true,
+}
+
+// databaseAddress is the address of a ready database and the user the applications log in with
+type databaseAddress struct {
+	Host     string
+	Port     int
+	Username string
+}
+
+// databaseProvider provisions the databases of a provider
+type databaseProvider interface {
+	// createDatabase starts the creation of the instance with the user and the database
+	createDatabase(database *model.ManagedDatabaseModel, username, password string) error
+	// databaseAddress finishes the setup of the created instance and returns its address, it's nil while the
+	// instance is being created
+	databaseAddress(database *model.ManagedDatabaseModel, username, password string) (*databaseAddress, error)
+	// deleteDatabase deletes the instance, the missing instances are already deleted
+	deleteDatabase(database *model.ManagedDatabaseModel) error
+}
+
+// databaseAPIError is the error response of the database API of a provider
+type databaseAPIError struct {
+	Provider   string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *databaseAPIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s %s (%d): %s", e.Provider, e.Code, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s responded %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// isDatabaseAPIStatus checks whether the error is a response of the status
+func isDatabaseAPIStatus(err error, status int) bool {
+	e, ok := err.(*databaseAPIError)
+	return ok && e.StatusCode == status
+}
+
+// databaseRequest sends a JSON request of the Google or Azure database API and decodes the response into
+// result (if it isn't nil)
+func databaseRequest(provider string, client *http.Client, method, url string, body, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &databaseAPIError{Provider: provider, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(value))}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(value, result)
+}
+
+// newDatabaseProvider returns the provider of the database with its cloud secret
+func newDatabaseProvider(commonCluster CommonCluster, database *model.ManagedDatabaseModel) (databaseProvider, error) {
+	if database.Provider == DatabaseProviderCluster {
+		return &clusterDatabases{commonCluster: commonCluster}, nil
+	}
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(database.OrganizationID), 10), database.CloudSecretID)
+	if err != nil {
+		return nil, err
+	}
+	if expected := databaseSecretTypes[database.Provider]; item.SecretType != expected {
+		return nil, fmt.Errorf("the secret of the %s databases must be a %s, not a %s", database.Provider, expected, item.SecretType)
+	}
+	settings := database.GetSettings()
+	switch database.Provider {
+	case DatabaseProviderAmazon:
+		creds := credentials.NewStaticCredentials(item.Values["AWS_ACCESS_KEY_ID"], item.Values["AWS_SECRET_ACCESS_KEY"], "")
+		return &rdsService{credentials: creds, region: settings["region"], client: &http.Client{Timeout: databaseHTTPTimeout}}, nil
+	case DatabaseProviderGoogle:
+		return newCloudSQLService(item.Values, settings)
+	default:
+		return newAzureDatabaseService(item.Values)
+	}
+}
+
+// rdsService calls the Query API of RDS, the vendored AWS SDK doesn't have the RDS client
+type rdsService struct {
+	credentials *credentials.Credentials
+	region      string
+	client      *http.Client
+}
+
+// rdsEngines are the RDS engines of the database engines
+var rdsEngines = map[string]string{
+	DatabaseEnginePostgreSQL: "postgres",
+	DatabaseEngineMySQL:      "mysql",
+}
+
+// do sends the signed action to RDS and decodes the XML response into result (if it isn't nil)
+func (s *rdsService) do(action string, params url.Values, result interface{}) error {
+	params.Set("Action", action)
+	params.Set("Version", rdsAPIVersion)
+	data := []byte(params.Encode())
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://rds.%s.amazonaws.com/", s.region), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if _, err := v4.NewSigner(s.credentials).Sign(req, bytes.NewReader(data), "rds", s.region, time.Now()); err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var response struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(value, &response)
+		return &databaseAPIError{Provider: "RDS", StatusCode: resp.StatusCode, Code: response.Code, Message: response.Message}
+	}
+	if result == nil {
+		return nil
+	}
+	return xml.Unmarshal(value, result)
+}
+
+// createDatabase implements databaseProvider, the instance is private and encrypted
+func (s *rdsService) createDatabase(database *model.ManagedDatabaseModel, username, password string) error {
+	settings := database.GetSettings()
+	params := url.Values{
+		"DBInstanceIdentifier": {database.Identifier},
+		"DBInstanceClass":      {database.InstanceType},
+		"Engine":               {rdsEngines[database.Engine]},
+		"MasterUsername":       {username},
+		"MasterUserPassword":   {password},
+		"DBName":               {databaseName(database)},
+		"AllocatedStorage":     {strconv.Itoa(database.StorageGB)},
+		"StorageEncrypted":     {"true"},
+		"PubliclyAccessible":   {"false"},
+		"Tags.member.1.Key":    {"pipeline-cluster-id"},
+		"Tags.member.1.Value":  {strconv.FormatUint(uint64(database.ClusterID), 10)},
+	}
+	if database.Version != "" {
+		params.Set("EngineVersion", database.Version)
+	}
+	if settings["subnetGroup"] != "" {
+		params.Set("DBSubnetGroupName", settings["subnetGroup"])
+	}
+	for i, group := range strings.Split(settings["securityGroupIds"], ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			params.Set(fmt.Sprintf("VpcSecurityGroupIds.VpcSecurityGroupId.%d", i+1), group)
+		}
+	}
+	return s.do("CreateDBInstance", params, nil)
+}
+
+// databaseAddress implements databaseProvider
+func (s *rdsService) databaseAddress(database *model.ManagedDatabaseModel, username, password string) (*databaseAddress, error) {
+	var response struct {
+		Instances []struct {
+			Status  string `xml:"DBInstanceStatus"`
+			Address string `xml:"Endpoint>Address"`
+			Port    int    `xml:"Endpoint>Port"`
+		} `xml:"DescribeDBInstancesResult>DBInstances>DBInstance"`
+	}
+	if err := s.do("DescribeDBInstances", url.Values{"DBInstanceIdentifier": {database.Identifier}}, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Instances) == 0 {
+		return nil, fmt.Errorf("RDS instance %s not found", database.Identifier)
+	}
+	instance := response.Instances[0]
+	if rdsFailedStatuses[instance.Status] {
+		return nil, fmt.Errorf("RDS instance %s is %s", database.Identifier, instance.Status)
+	}
+	if instance.Status != rdsStatusAvailable || instance.Address == "" {
+		return nil, nil
+	}
+	return &databaseAddress{Host: instance.Address, Port: instance.Port, Username: username}, nil
+}
+
+// deleteDatabase implements databaseProvider, a final snapshot of the instance is kept
+func (s *rdsService) deleteDatabase(database *model.ManagedDatabaseModel) error {
+	err := s.do("DeleteDBInstance", url.Values{
+		"DBInstanceIdentifier":      {database.Identifier},
+		"FinalDBSnapshotIdentifier": {fmt.Sprintf("%s-final-%d", database.Identifier, time.Now().Unix())},
+	}, nil)
+	if e, ok := err.(*databaseAPIError); ok && e.Code == "DBInstanceNotFound" {
+		return nil
+	}
+	return err
+}
+
+// cloudSQLService calls the Cloud SQL Admin API, the vendored Google API client doesn't have it
+type cloudSQLService struct {
+	client  *http.Client
+	project string
+	region  string
+	network string
+}
+
+// newCloudSQLService returns the client authenticated with the service account of the Google secret, the
+// instances are created in the project of the service account by default
+func newCloudSQLService(values map[string]string, settings map[string]string) (*cloudSQLService, error) {
+	serviceAccount, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	config, err := google.JWTConfigFromJSON(serviceAccount, "https://www.googleapis.com/auth/sqlservice.admin")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: databaseHTTPTimeout})
+	service := &cloudSQLService{client: config.Client(ctx), project: settings["project"], region: settings["region"], network: settings["network"]}
+	if service.project == "" {
+		service.project = values["project_id"]
+	}
+	return service, nil
+}
+
+// instanceURL returns the URL of the instance, or of the instances if the name is empty
+func (s *cloudSQLService) instanceURL(name string) string {
+	path := fmt.Sprintf("%s/projects/%s/instances", cloudSQLBasePath, url.PathEscape(s.project))
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+// cloudSQLVersion returns the Cloud SQL database version of the engine, e.g. POSTGRES_11 or MYSQL_5_7
+func cloudSQLVersion(engine, version string) string {
+	if engine == DatabaseEngineMySQL {
+		return "MYSQL_" + strings.Replace(version, ".", "_", -1)
+	}
+	return "POSTGRES_" + strings.Replace(version, ".", "_", -1)
+}
+
+// createDatabase implements databaseProvider, the instance has a private IP in the network setting if it's set
+func (s *cloudSQLService) createDatabase(database *model.ManagedDatabaseModel, username, password string) error {
+	ipConfiguration := map[string]interface{}{"ipv4Enabled": s.network == "", "requireSsl": true}
+	if s.network != "" {
+		ipConfiguration["privateNetwork"] = s.network
+	}
+	instance := map[string]interface{}{
+		"name":            database.Identifier,
+		"databaseVersion": cloudSQLVersion(database.Engine, database.Version),
+		"region":          s.region,
+		"rootPassword":    password,
+		"settings": map[string]interface{}{
+			"tier":            database.InstanceType,
+			"dataDiskSizeGb":  strconv.Itoa(database.StorageGB),
+			"ipConfiguration": ipConfiguration,
+			"userLabels":      map[string]string{"pipeline-cluster-id": strconv.FormatUint(uint64(database.ClusterID), 10)},
+		},
+	}
+	return databaseRequest("Cloud SQL", s.client, http.MethodPost, s.instanceURL(""), instance, nil)
+}
+
+// databaseAddress implements databaseProvider, the user and the database are created once the instance runs
+func (s *cloudSQLService) databaseAddress(database *model.ManagedDatabaseModel, username, password string) (*databaseAddress, error) {
+	var instance struct {
+		State       string `json:"state"`
+		IPAddresses []struct {
+			Type      string `json:"type"`
+			IPAddress string `json:"ipAddress"`
+		} `json:"ipAddresses"`
+	}
+	if err := databaseRequest("Cloud SQL", s.client, http.MethodGet, s.instanceURL(database.Identifier), nil, &instance); err != nil {
+		return nil, err
+	}
+	if instance.State != cloudSQLStateRunning {
+		return nil, nil
+	}
+	host := ""
+	for _, address := range instance.IPAddresses {
+		if host == "" || address.Type == "PRIVATE" {
+			host = address.IPAddress
+		}
+	}
+	if host == "" {
+		return nil, nil
+	}
+	user := map[string]string{"name": username, "password": password}
+	if err := databaseRequest("Cloud SQL", s.client, http.MethodPost, s.instanceURL(database.Identifier)+"/users", user, nil); err != nil && !isDatabaseAPIStatus(err, http.StatusConflict) {
+		return nil, err
+	}
+	db := map[string]string{"name": databaseName(database)}
+	if err := databaseRequest("Cloud SQL", s.client, http.MethodPost, s.instanceURL(database.Identifier)+"/databases", db, nil); err != nil && !isDatabaseAPIStatus(err, http.StatusConflict) {
+		return nil, err
+	}
+	return &databaseAddress{Host: host, Port: databasePorts[database.Engine], Username: username}, nil
+}
+
+// deleteDatabase implements databaseProvider
+func (s *cloudSQLService) deleteDatabase(database *model.ManagedDatabaseModel) error {
+	err := databaseRequest("Cloud SQL", s.client, http.MethodDelete, s.instanceURL(database.Identifier), nil, nil)
+	if isDatabaseAPIStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+// azureTokenTransport authorizes the requests with the token of the service principal
+type azureTokenTransport struct {
+	token *adal.ServicePrincipalToken
+}
+
+// RoundTrip implements http.RoundTripper, the token is refreshed before it expires
+func (t *azureTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.token.EnsureFresh(); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token.OAuthToken())
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// azureDatabaseService manages the single servers of Azure Database for PostgreSQL and MySQL through the
+// Resource Manager
+type azureDatabaseService struct {
+	client       *http.Client
+	subscription string
+}
+
+// newAzureDatabaseService returns the client authenticated with the service principal of the Azure secret
+func newAzureDatabaseService(values map[string]string) (*azureDatabaseService, error) {
+	oauthConfig, err := adal.NewOAuthConfig(azure.PublicCloud.ActiveDirectoryEndpoint, values["AZURE_TENANT_ID"])
+	if err != nil {
+		return nil, err
+	}
+	token, err := adal.NewServicePrincipalToken(*oauthConfig, values["AZURE_CLIENT_ID"], values["AZURE_CLIENT_SECRET"],
+		azure.PublicCloud.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	token.SetSender(&http.Client{Timeout: databaseHTTPTimeout})
+	return &azureDatabaseService{
+		client:       &http.Client{Timeout: databaseHTTPTimeout, Transport: &azureTokenTransport{token: token}},
+		subscription: values["AZURE_SUBSCRIPTION_ID"],
+	}, nil
+}
+
+// serverURL returns the Resource Manager URL of the server of the database, or of a child resource of the server
+func (s *azureDatabaseService) serverURL(database *model.ManagedDatabaseModel, child string) string {
+	namespace := "Microsoft.DBforPostgreSQL"
+	if database.Engine == DatabaseEngineMySQL {
+		namespace = "Microsoft.DBforMySQL"
+	}
+	path := fmt.Sprintf("%s/subscriptions/%s/resourceGroups/%s/providers/%s/servers/%s", azureManagementURL,
+		url.PathEscape(s.subscription), url.PathEscape(database.GetSettings()["resourceGroup"]), namespace, url.PathEscape(database.Identifier))
+	if child != "" {
+		path += "/" + child
+	}
+	return path + "?api-version=" + azureDBAPIVersion
+}
+
+// createDatabase implements databaseProvider, the server enforces SSL
+func (s *azureDatabaseService) createDatabase(database *model.ManagedDatabaseModel, username, password string) error {
+	server := map[string]interface{}{
+		"location": database.GetSettings()["location"],
+		"sku":      map[string]string{"name": database.InstanceType},
+		"tags":     map[string]string{"pipeline-cluster-id": strconv.FormatUint(uint64(database.ClusterID), 10)},
+		"properties": map[string]interface{}{
+			"createMode":                 "Default",
+			"version":                    database.Version,
+			"administratorLogin":         username,
+			"administratorLoginPassword": password,
+			"sslEnforcement":             "Enabled",
+			"storageProfile":             map[string]int{"storageMB": database.StorageGB * 1024},
+		},
+	}
+	return databaseRequest("Azure", s.client, http.MethodPut, s.serverURL(database, ""), server, nil)
+}
+
+// databaseAddress implements databaseProvider, the Azure services are allowed through the firewall and the
+// database is created once the server is ready; the users of the single servers log in as user@server
+func (s *azureDatabaseService) databaseAddress(database *model.ManagedDatabaseModel, username, password string) (*databaseAddress, error) {
+	var server struct {
+		Properties struct {
+			State string `json:"userVisibleState"`
+			FQDN  string `json:"fullyQualifiedDomainName"`
+		} `json:"properties"`
+	}
+	if err := databaseRequest("Azure", s.client, http.MethodGet, s.serverURL(database, ""), nil, &server); err != nil {
+		if isDatabaseAPIStatus(err, http.StatusNotFound) {
+			// the server appears after the deployment of the Resource Manager has started
+			return nil, nil
+		}
+		return nil, err
+	}
+	if server.Properties.State != azureDBStateReady {
+		return nil, nil
+	}
+	rule := map[string]interface{}{"properties": map[string]string{"startIpAddress": "0.0.0.0", "endIpAddress": "0.0.0.0"}}
+	if err := databaseRequest("Azure", s.client, http.MethodPut, s.serverURL(database, "firewallRules/AllowAllAzureIps"), rule, nil); err != nil {
+		return nil, err
+	}
+	charset := "UTF8"
+	if database.Engine == DatabaseEngineMySQL {
+		charset = "utf8"
+	}
+	db := map[string]interface{}{"properties": map[string]string{"charset": charset}}
+	if err := databaseRequest("Azure", s.client, http.MethodPut, s.serverURL(database, "databases/"+databaseName(database)), db, nil); err != nil {
+		return nil, err
+	}
+	return &databaseAddress{Host: server.Properties.FQDN, Port: databasePorts[database.Engine], Username: username + "@" + database.Identifier}, nil
+}
+
+// deleteDatabase implements databaseProvider
+func (s *azureDatabaseService) deleteDatabase(database *model.ManagedDatabaseModel) error {
+	err := databaseRequest("Azure", s.client, http.MethodDelete, s.serverURL(database, ""), nil, nil)
+	if isDatabaseAPIStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
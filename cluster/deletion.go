@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ErrDeletionProtected is returned when a protected cluster is deleted
+var ErrDeletionProtected = errors.New("deletion protection is enabled, disable it before deleting the cluster")
+
+// ErrDeletionNotConfirmed is returned when the confirmation token of a deletion is invalid or expired
+var ErrDeletionNotConfirmed = errors.New("invalid or expired deletion confirmation token")
+
+// SetDeletionProtection enables or disables the deletion protection of the cluster
+func SetDeletionProtection(commonCluster CommonCluster, enabled bool) {
+	commonCluster.GetModel().DeletionProtection = enabled
+}
+
+// RequestDeletion starts the deletion of the cluster, it returns the token confirming the deletion
+// and its expiry; only the hash of the token is kept in the model
+func RequestDeletion(commonCluster CommonCluster) (string, time.Time, error) {
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.DeletionProtection {
+		return "", time.Time{}, ErrDeletionProtected
+	}
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(data)
+	expiry := time.Now().Add(viper.GetDuration("cloud.deletionConfirmTimeout"))
+	modelCluster.DeletionToken = deletionTokenHash(token)
+	modelCluster.DeletionTokenExpiry = &expiry
+	return token, expiry, nil
+}
+
+// ConfirmDeletion checks the confirmation token of the deletion, the token can be used once
+func ConfirmDeletion(commonCluster CommonCluster, token string) error {
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.DeletionProtection {
+		return ErrDeletionProtected
+	}
+	if modelCluster.DeletionToken == "" || modelCluster.DeletionTokenExpiry == nil ||
+		time.Now().After(*modelCluster.DeletionTokenExpiry) ||
+		subtle.ConstantTimeCompare([]byte(modelCluster.DeletionToken), []byte(deletionTokenHash(token))) != 1 {
+		return ErrDeletionNotConfirmed
+	}
+	modelCluster.DeletionToken = ""
+	modelCluster.DeletionTokenExpiry = nil
+	return nil
+}
+
+func deletionTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,48 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/spf13/viper"
+)
+
+func TestDeletionConfirmation(t *testing.T) {
+
+	viper.Set("cloud.deletionConfirmTimeout", "10m")
+
+	cases := []struct {
+		name        string
+		protected   bool
+		token       func(string) string
+		expectError error
+	}{
+		{name: "confirmed", token: func(token string) string { return token }},
+		{name: "wrong token", token: func(string) string { return "wrong" }, expectError: cluster.ErrDeletionNotConfirmed},
+		{name: "protected", protected: true, expectError: cluster.ErrDeletionProtected},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+			cluster.SetDeletionProtection(commonCluster, tc.protected)
+
+			token, _, err := cluster.RequestDeletion(commonCluster)
+			if err == nil {
+				err = cluster.ConfirmDeletion(commonCluster, tc.token(token))
+			}
+			if err != tc.expectError {
+				t.Fatalf("Expected error: %v, got: %v", tc.expectError, err)
+			}
+			if err != nil {
+				return
+			}
+			if err := cluster.ConfirmDeletion(commonCluster, token); err != cluster.ErrDeletionNotConfirmed {
+				t.Errorf("Expected error, the token can be used once")
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// AddonExternalDNS is the add-on of the DNS records of the ingresses of the clusters with DNS
+const AddonExternalDNS = "external-dns"
+
+// DNS providers of the zone managed by Pipeline
+const (
+	DNSProviderRoute53  = "aws"
+	DNSProviderCloudDNS = "google"
+	DNSProviderAzureDNS = "azure"
+)
+
+// invalidDomainCharacters are replaced in the organization names of the domains
+var invalidDomainCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+//DNS enables the DNS automation of a cluster: external-dns creates the records of the ingress hosts of the
+//organization domain in the zone managed by Pipeline with the credentials of the cloud secret
+type DNS struct {
+	SecretID string `json:"secretId" binding:"required"`
+}
+
+//ClusterDNS describes the DNS automation of a cluster, the hosts of the ingresses have to be in the domain
+type ClusterDNS struct {
+	Zone     string `json:"zone"`
+	Domain   string `json:"domain"`
+	Provider string `json:"provider"`
+	SecretID string `json:"secretId"`
+}
+
+//DNSDomain returns the domain of the organization in the DNS zone managed by Pipeline
+func DNSDomain(organization string) string {
+	label := invalidDomainCharacters.ReplaceAllString(strings.ToLower(organization), "-")
+	return strings.Trim(label, "-") + "." + viper.GetString("dns.zone")
+}
+
+//SetDNS enables the DNS automation of the cluster with the cloud secret, it's disabled without DNS
+func SetDNS(commonCluster CommonCluster, dns *DNS, organization string) error {
+	modelCluster := commonCluster.GetModel()
+	if dns == nil {
+		modelCluster.DNSSecretID, modelCluster.DNSDomain = "", ""
+		return nil
+	}
+	if viper.GetString("dns.zone") == "" {
+		return errors.New("the DNS zone of Pipeline isn't configured")
+	}
+	if _, err := dnsProvider(commonCluster.GetOrg(), dns.SecretID); err != nil {
+		return err
+	}
+	modelCluster.DNSSecretID = dns.SecretID
+	modelCluster.DNSDomain = DNSDomain(organization)
+	return nil
+}
+
+//GetClusterDNS returns the DNS automation of the cluster, it's nil if the cluster has no DNS
+func GetClusterDNS(commonCluster CommonCluster) (*ClusterDNS, error) {
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.DNSSecretID == "" {
+		return nil, nil
+	}
+	provider, err := dnsProvider(commonCluster.GetOrg(), modelCluster.DNSSecretID)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterDNS{
+		Zone:     viper.GetString("dns.zone"),
+		Domain:   modelCluster.DNSDomain,
+		Provider: provider,
+		SecretID: modelCluster.DNSSecretID,
+	}, nil
+}
+
+//InstallExternalDNSPostHook installs external-dns on the clusters created with DNS
+func InstallExternalDNSPostHook(commonCluster CommonCluster) {
+	log := logger.WithFields(logrus.Fields{"action": "InstallExternalDNS"})
+	if commonCluster.GetModel().DNSSecretID == "" {
+		return
+	}
+	if _, err := InstallAddon(commonCluster, AddonExternalDNS); err != nil {
+		log.Errorf("Deploying external-dns failed due to: %s", err.Error())
+		return
+	}
+	log.Infof("External DNS of domain %s installed", commonCluster.GetModel().DNSDomain)
+}
+
+// dnsProvider returns the DNS provider of the cloud secret of the organization
+func dnsProvider(organizationID uint, secretID string) (string, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(organizationID), 10), secretID)
+	if err != nil {
+		return "", errors.Wrap(err, "error getting the DNS secret")
+	}
+	switch item.SecretType {
+	case secret.Amazon:
+		return DNSProviderRoute53, nil
+	case secret.Google:
+		return DNSProviderCloudDNS, nil
+	case secret.Azure:
+		return DNSProviderAzureDNS, nil
+	}
+	return "", fmt.Errorf("the DNS secret must be a %s, %s or %s, not a %s", secret.Amazon, secret.Google, secret.Azure, item.SecretType)
+}
+
+// prepareExternalDNS checks that the cluster was created with DNS
+func prepareExternalDNS(commonCluster CommonCluster) error {
+	if commonCluster.GetModel().DNSSecretID == "" {
+		return fmt.Errorf("cluster %s has no DNS, it must be created with a DNS secret", commonCluster.GetName())
+	}
+	return nil
+}
+
+// externalDNSValues returns the values of the external-dns chart: the records of the ingresses and the
+// services of the cluster are synced to the zone with the credentials of the DNS secret, the TXT records
+// of the owner ID mark the records of the cluster
+func externalDNSValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	modelCluster := commonCluster.GetModel()
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), modelCluster.DNSSecretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the DNS secret")
+	}
+	values := map[string]interface{}{
+		"rbac":          map[string]interface{}{"create": true},
+		"sources":       []string{"ingress", "service"},
+		"domainFilters": []string{viper.GetString("dns.zone")},
+		"txtOwnerId":    fmt.Sprintf("pipeline-%d", commonCluster.GetID()),
+		"policy":        "sync",
+	}
+	switch item.SecretType {
+	case secret.Amazon:
+		values["provider"] = DNSProviderRoute53
+		values["aws"] = map[string]interface{}{
+			"region": viper.GetString("dns.awsRegion"),
+			"credentials": map[string]interface{}{
+				"accessKey": item.Values["AWS_ACCESS_KEY_ID"],
+				"secretKey": item.Values["AWS_SECRET_ACCESS_KEY"],
+			},
+		}
+	case secret.Google:
+		key, err := json.Marshal(item.Values)
+		if err != nil {
+			return nil, err
+		}
+		values["provider"] = DNSProviderCloudDNS
+		values["google"] = map[string]interface{}{
+			"project":           item.Values["project_id"],
+			"serviceAccountKey": string(key),
+		}
+	case secret.Azure:
+		values["provider"] = DNSProviderAzureDNS
+		values["azure"] = map[string]interface{}{
+			"resourceGroup":   viper.GetString("dns.azureResourceGroup"),
+			"tenantId":        item.Values["AZURE_TENANT_ID"],
+			"subscriptionId":  item.Values["AZURE_SUBSCRIPTION_ID"],
+			"aadClientId":     item.Values["AZURE_CLIENT_ID"],
+			"aadClientSecret": item.Values["AZURE_CLIENT_SECRET"],
+		}
+	default:
+		return nil, fmt.Errorf("the DNS secret must be a %s, %s or %s, not a %s", secret.Amazon, secret.Google, secret.Azure, item.SecretType)
+	}
+	return values, nil
+}
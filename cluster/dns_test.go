@@ -0,0 +1,34 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/spf13/viper"
+)
+
+func TestDNSDomain(t *testing.T) {
+
+	zone := viper.GetString("dns.zone")
+	viper.Set("dns.zone", "pipeline.example.com")
+	defer viper.Set("dns.zone", zone)
+
+	cases := []struct {
+		name         string
+		organization string
+		expected     string
+	}{
+		{name: "lowercase", organization: "acme", expected: "acme.pipeline.example.com"},
+		{name: "uppercase", organization: "ACME", expected: "acme.pipeline.example.com"},
+		{name: "invalid characters", organization: "Acme Corp_EU", expected: "acme-corp-eu.pipeline.example.com"},
+		{name: "trimmed hyphens", organization: "_acme_", expected: "acme.pipeline.example.com"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if domain := cluster.DNSDomain(tc.organization); domain != tc.expected {
+				t.Errorf("Expected domain %s, got %s", tc.expected, domain)
+			}
+		})
+	}
+}
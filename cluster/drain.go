@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	drainPollInterval   = 5 * time.Second
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// DrainCluster cordons the nodes of the cluster and evicts the workload pods, so they are terminated gracefully
+// and their disruption budgets are respected before the cluster is deleted. The pods of kube-system, the daemon
+// sets and the static pods are kept.
+func DrainCluster(commonCluster CommonCluster) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagDeleteCluster})
+	return drainNodes(commonCluster, "", log)
+}
+
+// drainNodes cordons the nodes of the label selector (every node if it's empty) and evicts their workload pods
+func drainNodes(commonCluster CommonCluster, selector string, log *logrus.Entry) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	drained := make(map[string]bool, len(nodes.Items))
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		drained[node.Name] = true
+		if node.Spec.Unschedulable {
+			continue
+		}
+		node.Spec.Unschedulable = true
+		if _, err := client.CoreV1().Nodes().Update(node); err != nil {
+			return fmt.Errorf("error cordoning node %s: %v", node.Name, err)
+		}
+		log.Infof("Node %s cordoned", node.Name)
+	}
+
+	deadline := time.Now().Add(viper.GetDuration("cloud.drainTimeout"))
+	for {
+		pods, err := drainedPods(client, drained)
+		if err != nil {
+			return err
+		}
+		if len(pods) == 0 {
+			log.Infof("%d nodes drained", len(drained))
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("drain timed out, %d pods are running", len(pods))
+		}
+		for _, pod := range pods {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			err := client.CoreV1().Pods(pod.Namespace).Evict(&policy.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			})
+			// the disruption budget doesn't allow the eviction yet
+			if k8sErrors.IsTooManyRequests(err) || k8sErrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error evicting pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			}
+			log.Infof("Pod %s/%s evicted", pod.Namespace, pod.Name)
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// drainedPods returns the running pods of the nodes evicted by the drain
+func drainedPods(client *kubernetes.Clientset, nodes map[string]bool) ([]v1.Pod, error) {
+	podList, err := client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var pods []v1.Pod
+	for _, pod := range podList.Items {
+		if !nodes[pod.Spec.NodeName] || !isDrainedPod(pod) {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+func isDrainedPod(pod v1.Pod) bool {
+	if pod.Namespace == metav1.NamespaceSystem || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
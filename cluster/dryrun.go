@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+//ValidationError is a failed check of a cluster creation, Field is the field of the create cluster request
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//NetworkValidator is implemented by the clusters whose network settings can be checked in the cloud before the creation
+type NetworkValidator interface {
+	// ValidateNetwork checks the networks of the new cluster and their CIDR ranges
+	ValidateNetwork() error
+}
+
+//ValidateCreation runs the checks of the cloud for a new cluster without creating anything: the instance types
+//of the node pools must be available in the location, the quotas must allow their nodes and the networks of the
+//cluster must not conflict. Every failed check is returned.
+func ValidateCreation(commonCluster CommonCluster) []ValidationError {
+	validationErrors := []ValidationError{}
+	if manager, ok := commonCluster.(NodePoolManager); ok {
+		pools := commonCluster.GetModel().NodePools
+		requested := len(pools) != 0
+		if !requested {
+			pools = defaultNodePools(commonCluster)
+		}
+		for i, pool := range pools {
+			field := "nodeInstanceType"
+			if requested {
+				field = fmt.Sprintf("nodePools[%d]", i)
+			}
+			if err := manager.CheckNodeQuota(pool.InstanceType, maxNodes(pool)); err != nil {
+				validationErrors = append(validationErrors, ValidationError{Field: field, Message: err.Error()})
+			}
+		}
+	}
+	if validator, ok := commonCluster.(NetworkValidator); ok {
+		if err := validator.ValidateNetwork(); err != nil {
+			validationErrors = append(validationErrors, ValidationError{Field: "network", Message: err.Error()})
+		}
+	}
+	return validationErrors
+}
+
+// defaultNodePools returns the single node pool the cluster is created with when the request has no node pools
+func defaultNodePools(commonCluster CommonCluster) []model.NodePoolModel {
+	modelCluster := commonCluster.GetModel()
+	pool := model.NodePoolModel{InstanceType: modelCluster.NodeInstanceType}
+	switch commonCluster.(type) {
+	case *EKSCluster:
+		pool.Name, pool.Count = eksDefaultNodePool, constants.AmazonDefaultNodeMinCount
+	case *GKECluster:
+		pool.Name, pool.Count = gkeDefaultNodePool, modelCluster.Google.NodeCount
+	case *AKSCluster:
+		pool.Name, pool.Count = modelCluster.Azure.AgentName, modelCluster.Azure.AgentCount
+	}
+	return []model.NodePoolModel{pool}
+}
+
+//CheckCIDRConflicts checks that the CIDR ranges don't overlap, the keys of the map are the names of the ranges
+func CheckCIDRConflicts(ranges map[string]string) error {
+	names := make([]string, 0, len(ranges))
+	networks := map[string]*net.IPNet{}
+	for name, cidr := range ranges {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %s of %s", cidr, name)
+		}
+		names = append(names, name)
+		networks[name] = network
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		for _, other := range names[i+1:] {
+			if networks[name].Contains(networks[other].IP) || networks[other].Contains(networks[name].IP) {
+				return fmt.Errorf("CIDR %s of %s conflicts with CIDR %s of %s", ranges[name], name, ranges[other], other)
+			}
+		}
+	}
+	return nil
+}
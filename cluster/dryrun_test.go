@@ -0,0 +1,39 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestCheckCIDRConflicts(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		ranges      map[string]string
+		expectError bool
+	}{
+		{name: "disjoint", ranges: map[string]string{"vpc": "10.0.0.0/16", "docker": "172.17.0.0/16"}},
+		{name: "adjacent", ranges: map[string]string{"pods": "10.0.0.0/16", "services": "10.1.0.0/16"}},
+		{name: "single", ranges: map[string]string{"vpc": "192.168.0.0/16"}},
+		{name: "equal", ranges: map[string]string{"vpc": "172.17.0.0/16", "docker": "172.17.0.0/16"}, expectError: true},
+		{name: "contained", ranges: map[string]string{"vpc": "172.16.0.0/12", "docker": "172.17.0.0/16"}, expectError: true},
+		{name: "containing", ranges: map[string]string{"pods": "10.4.0.0/24", "vpc": "10.0.0.0/8"}, expectError: true},
+		{name: "invalid", ranges: map[string]string{"vpc": "10.0.0.0/33"}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.CheckCIDRConflicts(tc.ranges)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during CheckCIDRConflicts: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,1186 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/amazon"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/banzaicloud/pipeline/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EKS is the cloud type of the Amazon EKS clusters
+const EKS = "eks"
+
+const (
+	eksDefaultNodePool = "pool1"
+	awsAuthConfigMap   = "aws-auth"
+	awsAuthNamespace   = "kube-system"
+	// the Docker bridge network of the nodes, the pods can't reach the VPC addresses in it
+	eksDockerBridgeCIDR = "172.17.0.0/16"
+)
+
+//CreateClusterEKS describes the EKS properties of the create cluster request, the IAM roles are created
+//if they aren't given and the subnets of the default VPC are used without subnets
+type CreateClusterEKS struct {
+	Version        string           `json:"version,omitempty"`
+	RoleArn        string           `json:"roleArn,omitempty"`
+	NodeRoleArn    string           `json:"nodeRoleArn,omitempty"`
+	Subnets        []string         `json:"subnets,omitempty"`
+	SecurityGroups []string         `json:"securityGroups,omitempty"`
+	MapRoles       []EKSAuthMapping `json:"mapRoles,omitempty"`
+	MapUsers       []EKSAuthMapping `json:"mapUsers,omitempty"`
+}
+
+//EKSAuthMapping maps an IAM role or user to a Kubernetes user and groups in the aws-auth ConfigMap
+type EKSAuthMapping struct {
+	ARN      string   `json:"arn" binding:"required"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
+
+// eksAuth is the stored aws-auth mappings of the create cluster request
+type eksAuth struct {
+	MapRoles []EKSAuthMapping `json:"mapRoles,omitempty"`
+	MapUsers []EKSAuthMapping `json:"mapUsers,omitempty"`
+}
+
+// awsAuthEntry is an entry of the aws-auth ConfigMap
+type awsAuthEntry struct {
+	RoleARN  string   `yaml:"rolearn,omitempty"`
+	UserARN  string   `yaml:"userarn,omitempty"`
+	Username string   `yaml:"username"`
+	Groups   []string `yaml:"groups"`
+}
+
+//CreateEKSClusterFromRequest creates ClusterModel struct from the request
+func CreateEKSClusterFromRequest(request *components.CreateClusterRequest, eks *CreateClusterEKS, orgId uint) (*EKSCluster, error) {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagCreateCluster})
+	log.Debug("Create ClusterModel struct from the request")
+	if eks == nil {
+		return nil, errors.New("Required field 'eks' is empty.")
+	}
+	for _, mapping := range append(eks.MapRoles, eks.MapUsers...) {
+		if mapping.ARN == "" || len(mapping.Groups) == 0 {
+			return nil, errors.New("aws-auth mappings need an arn and groups")
+		}
+	}
+	auth, err := json.Marshal(eksAuth{MapRoles: eks.MapRoles, MapUsers: eks.MapUsers})
+	if err != nil {
+		return nil, err
+	}
+
+	var cluster EKSCluster
+	cluster.modelCluster = &model.ClusterModel{
+		Name:             request.Name,
+		Location:         request.Location,
+		NodeInstanceType: request.NodeInstanceType,
+		Cloud:            request.Cloud,
+		OrganizationId:   orgId,
+		SecretId:         request.SecretId,
+		EKS: model.EKSClusterModel{
+			Version:        eks.Version,
+			RoleArn:        eks.RoleArn,
+			NodeRoleArn:    eks.NodeRoleArn,
+			Subnets:        strings.Join(eks.Subnets, ","),
+			SecurityGroups: strings.Join(eks.SecurityGroups, ","),
+			AWSAuth:        string(auth),
+		},
+	}
+	return &cluster, nil
+}
+
+//CreateEKSClusterFromModel creates ClusterModel struct from model
+func CreateEKSClusterFromModel(clusterModel *model.ClusterModel) (*EKSCluster, error) {
+	return &EKSCluster{modelCluster: clusterModel}, nil
+}
+
+//EKSCluster struct for EKS cluster
+type EKSCluster struct {
+	modelCluster    *model.ClusterModel
+	k8sConfig       *[]byte
+	k8sConfigExpiry time.Time
+	APIEndpoint     string
+}
+
+func (c *EKSCluster) GetOrg() uint {
+	return c.modelCluster.OrganizationId
+}
+
+func (c *EKSCluster) GetSecretID() string {
+	return c.modelCluster.SecretId
+}
+
+//GetID returns the specified cluster id
+func (c *EKSCluster) GetID() uint {
+	return c.modelCluster.ID
+}
+
+//GetName returns the name of the cluster
+func (c *EKSCluster) GetName() string {
+	return c.modelCluster.Name
+}
+
+//GetType returns the cloud type of the cluster
+func (c *EKSCluster) GetType() string {
+	return c.modelCluster.Cloud
+}
+
+//GetModel returns the whole clusterModel
+func (c *EKSCluster) GetModel() *model.ClusterModel {
+	return c.modelCluster
+}
+
+//Persist save the cluster model
+func (c *EKSCluster) Persist() error {
+	return c.modelCluster.Save()
+}
+
+//DeleteFromDatabase deletes model from the database
+func (c *EKSCluster) DeleteFromDatabase() error {
+	err := c.modelCluster.Delete()
+	if err != nil {
+		return err
+	}
+	c.modelCluster = nil
+	return nil
+}
+
+// session returns the AWS session of the region of the cluster with the credentials of the secret
+func (c *EKSCluster) session() (*session.Session, error) {
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return nil, err
+	}
+	if clusterSecret.SecretType != secret.Amazon {
+		return nil, errors.Errorf("missmatch secret type %s versus %s", clusterSecret.SecretType, secret.Amazon)
+	}
+	creds := credentials.NewStaticCredentials(
+		clusterSecret.Values["AWS_ACCESS_KEY_ID"],
+		clusterSecret.Values["AWS_SECRET_ACCESS_KEY"],
+		"",
+	)
+	return session.NewSession(aws.NewConfig().WithRegion(c.modelCluster.Location).WithCredentials(creds))
+}
+
+func (c *EKSCluster) eksService() (*eksService, *session.Session, error) {
+	sess, err := c.session()
+	if err != nil {
+		return nil, nil, err
+	}
+	return newEKSService(sess.Config.Credentials, c.modelCluster.Location), sess, nil
+}
+
+// roleName returns the name of the IAM role of the cluster created by Pipeline
+func (c *EKSCluster) roleName(kind string) string {
+	return fmt.Sprintf("%s-eks-%s", c.modelCluster.Name, kind)
+}
+
+//CreateCluster creates the IAM roles and the EKS control plane, WaitForCluster waits for the control plane
+//and creates the node groups
+func (c *EKSCluster) CreateCluster() error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagCreateCluster})
+
+	svc, sess, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	eks := &c.modelCluster.EKS
+
+	// the node pools of the request, or a single pool
+	if len(c.modelCluster.NodePools) == 0 {
+		c.modelCluster.NodePools = []model.NodePoolModel{{
+			Name:         eksDefaultNodePool,
+			InstanceType: c.modelCluster.NodeInstanceType,
+			Count:        constants.AmazonDefaultNodeMinCount,
+		}}
+	}
+
+	if eks.Subnets == "" {
+		subnets, err := defaultSubnets(ec2.New(sess))
+		if err != nil {
+			return err
+		}
+		log.Infof("Using the subnets of the default VPC: %v", subnets)
+		eks.Subnets = strings.Join(subnets, ",")
+	}
+	if key, err := GetSSHKey(c); err != nil {
+		return err
+	} else if key != nil {
+		if err := c.importSSHKey(key); err != nil {
+			return err
+		}
+	}
+
+	iamSvc := iam.New(sess)
+	if eks.RoleArn == "" {
+		eks.RolesCreated = true
+		if eks.RoleArn, err = createEKSRole(iamSvc, c.roleName("cluster"), eksClusterTrustPolicy, eksClusterPolicies); err != nil {
+			c.deleteRoles(iamSvc)
+			return err
+		}
+	}
+	if eks.NodeRoleArn == "" {
+		eks.RolesCreated = true
+		if eks.NodeRoleArn, err = createEKSRole(iamSvc, c.roleName("node"), eksNodeTrustPolicy, eksNodePolicies); err != nil {
+			c.deleteRoles(iamSvc)
+			return err
+		}
+	}
+
+	request := &eksCluster{
+		Name:    c.modelCluster.Name,
+		Version: eks.Version,
+		RoleArn: eks.RoleArn,
+		Tags:    c.modelCluster.GetTags(),
+	}
+	request.ResourcesVpcConfig.SubnetIds = splitList(eks.Subnets)
+	request.ResourcesVpcConfig.SecurityGroupIds = splitList(eks.SecurityGroups)
+	if c.modelCluster.PrivateEndpoint {
+		request.ResourcesVpcConfig.EndpointPrivateAccess = aws.Bool(true)
+		request.ResourcesVpcConfig.EndpointPublicAccess = aws.Bool(false)
+	}
+	created, err := svc.createCluster(request)
+	if err != nil {
+		c.deleteRoles(iamSvc)
+		return err
+	}
+	log.Info("Cluster creation accepted")
+	eks.Version = created.Version
+
+	// save to database
+	if err := c.Persist(); err != nil {
+		log.Errorf("Cluster save failed! %s", err.Error())
+	}
+	return nil
+}
+
+//WaitForCluster waits for the control plane, creates the OIDC provider of the IAM roles of service accounts,
+//the managed node groups and the aws-auth ConfigMap
+func (c *EKSCluster) WaitForCluster() error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagCreateCluster})
+
+	svc, sess, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	name := c.modelCluster.Name
+
+	err = waitForEKS("cluster "+name, false, func() (string, error) {
+		cluster, err := svc.describeCluster(name)
+		if err != nil {
+			return "", err
+		}
+		return cluster.Status, nil
+	})
+	if err != nil {
+		return err
+	}
+	cluster, err := svc.describeCluster(name)
+	if err != nil {
+		return err
+	}
+
+	if cluster.Identity != nil && cluster.Identity.OIDC.Issuer != "" {
+		arn, err := createEKSOIDCProvider(iam.New(sess), cluster.Identity.OIDC.Issuer)
+		if err != nil {
+			return err
+		}
+		log.Infof("OIDC provider %s created", arn)
+		c.modelCluster.EKS.OIDCProviderArn = arn
+	}
+
+	for _, pool := range c.modelCluster.NodePools {
+		if err := c.checkSpotPrice(ec2.New(sess), pool); err != nil {
+			return err
+		}
+		for _, nodegroup := range c.nodegroups(pool) {
+			if err := svc.createNodegroup(name, nodegroup); err != nil {
+				return errors.Wrapf(err, "error creating node group %s", nodegroup.NodegroupName)
+			}
+		}
+	}
+	for _, pool := range c.modelCluster.NodePools {
+		for _, nodegroup := range c.nodegroups(pool) {
+			if err := waitForNodegroup(svc, name, nodegroup.NodegroupName, false); err != nil {
+				return err
+			}
+			if err := c.tagNodegroupInstances(svc, sess, nodegroup.NodegroupName); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.updateAWSAuth(); err != nil {
+		return err
+	}
+	log.Info("Cluster is ready...")
+
+	return c.Persist()
+}
+
+// nodegroup returns the managed node group of the node pool, the size of the pools without autoscaling is fixed
+func (c *EKSCluster) nodegroup(pool model.NodePoolModel) *eksNodegroup {
+	nodegroup := &eksNodegroup{
+		NodegroupName: pool.Name,
+		ScalingConfig: eksScaling(pool),
+		Subnets:       splitList(c.modelCluster.EKS.Subnets),
+		InstanceTypes: []string{pool.InstanceType},
+		NodeRole:      c.modelCluster.EKS.NodeRoleArn,
+		Labels:        c.modelCluster.NodeLabels(pool),
+		Tags:          c.modelCluster.GetTags(),
+	}
+	// EKS opens the SSH port to the internet without source security groups
+	if c.modelCluster.SSHSecretID != "" && c.modelCluster.EKS.SecurityGroups != "" {
+		nodegroup.RemoteAccess = &eksRemoteAccess{
+			EC2SSHKey:            c.sshKeyName(),
+			SourceSecurityGroups: splitList(c.modelCluster.EKS.SecurityGroups),
+		}
+	}
+	return nodegroup
+}
+
+// sshKeyName returns the name of the EC2 key pair of the SSH key of the cluster
+func (c *EKSCluster) sshKeyName() string {
+	return c.modelCluster.Name + "-pipeline-ssh"
+}
+
+// importSSHKey imports the public key as the EC2 key pair of the node groups, the previous key pair is
+// replaced, the instances keep the key they were launched with
+func (c *EKSCluster) importSSHKey(key *SSHKey) error {
+	sess, err := c.session()
+	if err != nil {
+		return err
+	}
+	svc := ec2.New(sess)
+	if _, err := svc.DeleteKeyPair(&ec2.DeleteKeyPairInput{KeyName: aws.String(c.sshKeyName())}); err != nil {
+		return errors.Wrap(err, "error deleting the SSH key pair")
+	}
+	_, err = svc.ImportKeyPair(&ec2.ImportKeyPairInput{
+		KeyName:           aws.String(c.sshKeyName()),
+		PublicKeyMaterial: []byte(key.PublicKey),
+	})
+	return errors.Wrap(err, "error importing the SSH key pair")
+}
+
+// nodegroups returns the managed node groups of the node pool, the on-demand share of a spot pool
+// is in a second, on-demand node group
+func (c *EKSCluster) nodegroups(pool model.NodePoolModel) []*eksNodegroup {
+	nodegroup := c.nodegroup(pool)
+	if !pool.Spot {
+		return []*eksNodegroup{nodegroup}
+	}
+	nodegroup.CapacityType = eksCapacitySpot
+	if pool.OnDemandPercentage == 0 {
+		return []*eksNodegroup{nodegroup}
+	}
+	onDemand := c.nodegroup(pool)
+	onDemand.NodegroupName = eksOnDemandNodegroup(pool.Name)
+	nodegroup.ScalingConfig, onDemand.ScalingConfig = splitEKSScaling(nodegroup.ScalingConfig, pool.OnDemandPercentage)
+	return []*eksNodegroup{nodegroup, onDemand}
+}
+
+// eksOnDemandNodegroup returns the name of the on-demand node group of the spot node pool
+func eksOnDemandNodegroup(name string) string {
+	return name + "-od"
+}
+
+// splitEKSScaling splits the scaling of a node pool into the spot and the on-demand scaling, the on-demand
+// group has percent of the nodes rounded up and the spot group can always scale to a node
+func splitEKSScaling(scaling eksScalingConfig, percent int) (spot, onDemand eksScalingConfig) {
+	share := func(nodes int) int {
+		return (nodes*percent + 99) / 100
+	}
+	onDemand = eksScalingConfig{MinSize: share(scaling.MinSize), MaxSize: share(scaling.MaxSize), DesiredSize: share(scaling.DesiredSize)}
+	spot = eksScalingConfig{
+		MinSize:     scaling.MinSize - onDemand.MinSize,
+		MaxSize:     scaling.MaxSize - onDemand.MaxSize,
+		DesiredSize: scaling.DesiredSize - onDemand.DesiredSize,
+	}
+	if spot.MaxSize < 1 {
+		spot.MaxSize = 1
+	}
+	return spot, onDemand
+}
+
+// checkSpotPrice checks that the current spot price of the instance type of a spot node pool in the region
+// isn't above the max price of the pool, the managed node groups pay the current price
+func (c *EKSCluster) checkSpotPrice(svc *ec2.EC2, pool model.NodePoolModel) error {
+	if !pool.Spot || pool.SpotPrice == "" {
+		return nil
+	}
+	maxPrice, err := strconv.ParseFloat(pool.SpotPrice, 64)
+	if err != nil {
+		return err
+	}
+	history, err := svc.DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       aws.StringSlice([]string{pool.InstanceType}),
+		ProductDescriptions: aws.StringSlice([]string{"Linux/UNIX"}),
+		StartTime:           aws.Time(time.Now()),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error getting the spot price of %s", pool.InstanceType)
+	}
+	for _, price := range history.SpotPriceHistory {
+		if current, err := strconv.ParseFloat(aws.StringValue(price.SpotPrice), 64); err == nil && current <= maxPrice {
+			return nil
+		}
+	}
+	return fmt.Errorf("the spot price of %s is above the max price %s of node pool %s", pool.InstanceType, pool.SpotPrice, pool.Name)
+}
+
+//InstancePrice returns the hourly On-Demand price of the instance type in the region of the cluster from the AWS
+//Price List API, or the highest current spot price of the availability zones of the region
+func (c *EKSCluster) InstancePrice(instanceType string, spot bool) (float64, error) {
+	sess, err := c.session()
+	if err != nil {
+		return 0, err
+	}
+	if !spot {
+		return awsOnDemandPrice(sess.Config.Credentials, c.modelCluster.Location, instanceType)
+	}
+	history, err := ec2.New(sess).DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       aws.StringSlice([]string{instanceType}),
+		ProductDescriptions: aws.StringSlice([]string{"Linux/UNIX"}),
+		StartTime:           aws.Time(time.Now()),
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "error getting the spot price of %s", instanceType)
+	}
+	price := 0.0
+	for _, spotPrice := range history.SpotPriceHistory {
+		if current, err := strconv.ParseFloat(aws.StringValue(spotPrice.SpotPrice), 64); err == nil && current > price {
+			price = current
+		}
+	}
+	if price == 0 {
+		return 0, fmt.Errorf("no spot price of %s in %s", instanceType, c.modelCluster.Location)
+	}
+	return price, nil
+}
+
+func eksScaling(pool model.NodePoolModel) eksScalingConfig {
+	if !pool.Autoscaling && pool.Count == 0 {
+		// the maximum size of a node group is at least one, the hibernated node groups have no nodes
+		return eksScalingConfig{MinSize: 0, MaxSize: 1, DesiredSize: 0}
+	}
+	if !pool.Autoscaling {
+		return eksScalingConfig{MinSize: pool.Count, MaxSize: pool.Count, DesiredSize: pool.Count}
+	}
+	return eksScalingConfig{MinSize: pool.MinCount, MaxSize: pool.MaxCount, DesiredSize: pool.Count}
+}
+
+// updateAWSAuth adds the node role and the mappings of the request to the aws-auth ConfigMap,
+// the existing entries (like the entries of the managed node groups) are kept
+func (c *EKSCluster) updateAWSAuth() error {
+	var auth eksAuth
+	if c.modelCluster.EKS.AWSAuth != "" {
+		if err := json.Unmarshal([]byte(c.modelCluster.EKS.AWSAuth), &auth); err != nil {
+			return err
+		}
+	}
+	roles := []awsAuthEntry{{
+		RoleARN:  c.modelCluster.EKS.NodeRoleArn,
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	}}
+	for _, mapping := range auth.MapRoles {
+		roles = append(roles, awsAuthEntry{RoleARN: mapping.ARN, Username: mapping.Username, Groups: mapping.Groups})
+	}
+	var users []awsAuthEntry
+	for _, mapping := range auth.MapUsers {
+		users = append(users, awsAuthEntry{UserARN: mapping.ARN, Username: mapping.Username, Groups: mapping.Groups})
+	}
+
+	kubeConfig, err := c.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	configMaps := client.CoreV1().ConfigMaps(awsAuthNamespace)
+	configMap, err := configMaps.Get(awsAuthConfigMap, metav1.GetOptions{})
+	exists := err == nil
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	if !exists {
+		configMap = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: awsAuthConfigMap, Namespace: awsAuthNamespace}}
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	for key, entries := range map[string][]awsAuthEntry{"mapRoles": roles, "mapUsers": users} {
+		merged, err := mergeAWSAuth(configMap.Data[key], entries)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing %s of the aws-auth ConfigMap", key)
+		}
+		if merged != "" {
+			configMap.Data[key] = merged
+		}
+	}
+	if exists {
+		_, err = configMaps.Update(configMap)
+	} else {
+		_, err = configMaps.Create(configMap)
+	}
+	return err
+}
+
+// mergeAWSAuth adds the entries to the YAML list of the aws-auth ConfigMap if their ARN isn't in the list
+func mergeAWSAuth(data string, entries []awsAuthEntry) (string, error) {
+	var existing []awsAuthEntry
+	if err := yaml.Unmarshal([]byte(data), &existing); err != nil {
+		return "", err
+	}
+	arns := map[string]bool{}
+	for _, entry := range existing {
+		arns[entry.RoleARN+entry.UserARN] = true
+	}
+	for _, entry := range entries {
+		if !arns[entry.RoleARN+entry.UserARN] {
+			existing = append(existing, entry)
+		}
+	}
+	if len(existing) == 0 {
+		return "", nil
+	}
+	merged, err := yaml.Marshal(existing)
+	return string(merged), err
+}
+
+//GetAPIEndpoint returns the Kubernetes Api endpoint
+func (c *EKSCluster) GetAPIEndpoint() (string, error) {
+	if c.APIEndpoint != "" {
+		return c.APIEndpoint, nil
+	}
+	svc, _, err := c.eksService()
+	if err != nil {
+		return "", err
+	}
+	cluster, err := svc.describeCluster(c.modelCluster.Name)
+	if err != nil {
+		return "", err
+	}
+	// the endpoint is a URL on EKS, the other clouds return the host
+	c.APIEndpoint = strings.TrimPrefix(cluster.Endpoint, "https://")
+	return c.APIEndpoint, nil
+}
+
+//GetK8sConfig returns the Kubernetes config with a token of the IAM user of the secret like aws-iam-authenticator,
+//the token expires in 15 minutes so the config isn't stored
+func (c *EKSCluster) GetK8sConfig() (*[]byte, error) {
+	if c.k8sConfig != nil && time.Now().Before(c.k8sConfigExpiry) {
+		return c.k8sConfig, nil
+	}
+	svc, sess, err := c.eksService()
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := svc.describeCluster(c.modelCluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.CertificateAuthority == nil {
+		return nil, constants.ErrorClusterNotReady
+	}
+	token, expiry, err := eksToken(sess, c.modelCluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	config, err := storeConfig(&kubernetesCluster{
+		Name:                c.modelCluster.Name,
+		Endpoint:            cluster.Endpoint,
+		RootCACert:          cluster.CertificateAuthority.Data,
+		ServiceAccountToken: token,
+	}, c.modelCluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	c.k8sConfig = &config
+	c.k8sConfigExpiry = expiry.Add(-time.Minute)
+	return c.k8sConfig, nil
+}
+
+//GetStatus gets cluster status, it's 202 until the control plane and the node groups are active
+func (c *EKSCluster) GetStatus() (*components.GetClusterStatusResponse, error) {
+	svc, _, err := c.eksService()
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := svc.describeCluster(c.modelCluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	response := &components.GetClusterStatusResponse{
+		Status:           http.StatusOK,
+		Name:             c.modelCluster.Name,
+		Location:         c.modelCluster.Location,
+		Cloud:            c.modelCluster.Cloud,
+		NodeInstanceType: c.modelCluster.NodeInstanceType,
+		ResourceID:       c.modelCluster.ID,
+	}
+	statuses := []string{cluster.Status}
+	for _, pool := range c.modelCluster.NodePools {
+		for _, group := range c.nodegroups(pool) {
+			nodegroup, err := svc.describeNodegroup(c.modelCluster.Name, group.NodegroupName)
+			if isEKSNotFound(err) {
+				// the node groups are created after the control plane
+				statuses = append(statuses, "")
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			statuses = append(statuses, nodegroup.Status)
+		}
+	}
+	for _, status := range statuses {
+		switch status {
+		case eksStatusActive:
+		case eksStatusFailed, eksStatusCreateFailed:
+			return nil, fmt.Errorf("EKS cluster %s failed", c.modelCluster.Name)
+		default:
+			response.Status = http.StatusAccepted
+		}
+	}
+	return response, nil
+}
+
+// DeleteCluster deletes the node groups, the control plane, the OIDC provider and the IAM roles created by Pipeline
+func (c *EKSCluster) DeleteCluster() error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagDeleteCluster})
+	svc, sess, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	name := c.modelCluster.Name
+
+	for _, pool := range c.modelCluster.NodePools {
+		for _, nodegroup := range c.nodegroups(pool) {
+			if err := svc.deleteNodegroup(name, nodegroup.NodegroupName); err != nil && !isEKSNotFound(err) {
+				return err
+			}
+		}
+	}
+	for _, pool := range c.modelCluster.NodePools {
+		for _, nodegroup := range c.nodegroups(pool) {
+			if err := waitForNodegroup(svc, name, nodegroup.NodegroupName, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := svc.deleteCluster(name); err != nil && !isEKSNotFound(err) {
+		return err
+	}
+	err = waitForEKS("cluster "+name, true, func() (string, error) {
+		cluster, err := svc.describeCluster(name)
+		if err != nil {
+			return "", err
+		}
+		return cluster.Status, nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Delete succeeded")
+
+	if c.modelCluster.SSHSecretID != "" {
+		_, err := ec2.New(sess).DeleteKeyPair(&ec2.DeleteKeyPairInput{KeyName: aws.String(c.sshKeyName())})
+		if err != nil {
+			return errors.Wrap(err, "error deleting the SSH key pair")
+		}
+	}
+
+	iamSvc := iam.New(sess)
+	if arn := c.modelCluster.EKS.OIDCProviderArn; arn != "" {
+		_, err := iamSvc.DeleteOpenIDConnectProvider(&iam.DeleteOpenIDConnectProviderInput{OpenIDConnectProviderArn: aws.String(arn)})
+		if err != nil && !isIAMNotFound(err) {
+			return err
+		}
+	}
+	return c.deleteRoles(iamSvc)
+}
+
+// deleteRoles deletes the IAM roles created by Pipeline, the autoscaler policy is removed from the given node role
+func (c *EKSCluster) deleteRoles(iamSvc *iam.IAM) error {
+	if !c.modelCluster.EKS.RolesCreated {
+		if c.modelCluster.Autoscaler {
+			return deleteEKSRolePolicy(iamSvc, eksRoleName(c.modelCluster.EKS.NodeRoleArn), autoscalerPolicyName)
+		}
+		return nil
+	}
+	if err := deleteEKSRole(iamSvc, c.roleName("cluster"), eksClusterPolicies); err != nil {
+		log.Errorf("Error deleting the cluster role: %s", err.Error())
+		return err
+	}
+	if err := deleteEKSRole(iamSvc, c.roleName("node"), eksNodePolicies); err != nil {
+		log.Errorf("Error deleting the node role: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// UpdateCluster updates the scaling of the first node group with the node counts of the amazon properties
+func (c *EKSCluster) UpdateCluster(request *components.UpdateClusterRequest) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	if len(c.modelCluster.NodePools) == 0 {
+		return errors.New("the cluster has no node groups")
+	}
+	svc, _, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	pool := c.modelCluster.NodePools[0]
+	pool.MinCount = request.UpdateAmazonNode.MinCount
+	pool.MaxCount = request.UpdateAmazonNode.MaxCount
+	pool.Autoscaling = pool.MinCount != pool.MaxCount
+	if pool.Count < pool.MinCount {
+		pool.Count = pool.MinCount
+	}
+	if pool.Count > pool.MaxCount {
+		pool.Count = pool.MaxCount
+	}
+	for _, nodegroup := range c.nodegroups(pool) {
+		if err := svc.updateNodegroupConfig(c.modelCluster.Name, nodegroup.NodegroupName, nodegroup.ScalingConfig, nil); err != nil {
+			return err
+		}
+	}
+	log.Infof("Node group %s update succeeded", pool.Name)
+	c.modelCluster.NodePools[0] = pool
+	return nil
+}
+
+// nodeScaling returns the node counts of the first node group as amazon update properties
+func (c *EKSCluster) nodeScaling() *amazon.UpdateAmazonNode {
+	if len(c.modelCluster.NodePools) == 0 {
+		return &amazon.UpdateAmazonNode{}
+	}
+	scaling := eksScaling(c.modelCluster.NodePools[0])
+	return &amazon.UpdateAmazonNode{MinCount: scaling.MinSize, MaxCount: scaling.MaxSize}
+}
+
+//AddDefaultsToUpdate adds defaults to update request
+func (c *EKSCluster) AddDefaultsToUpdate(r *components.UpdateClusterRequest) {
+	stored := c.nodeScaling()
+	if r.UpdateClusterAmazon == nil {
+		r.UpdateClusterAmazon = &amazon.UpdateClusterAmazon{}
+	}
+	if r.UpdateAmazonNode == nil {
+		log.Info("'node' field is empty. Fill from stored data")
+		r.UpdateAmazonNode = stored
+	}
+	if r.UpdateAmazonNode.MinCount == 0 {
+		r.UpdateAmazonNode.MinCount = stored.MinCount
+	}
+	if r.UpdateAmazonNode.MaxCount == 0 {
+		r.UpdateAmazonNode.MaxCount = stored.MaxCount
+	}
+}
+
+//CheckEqualityToUpdate validates the update request
+func (c *EKSCluster) CheckEqualityToUpdate(r *components.UpdateClusterRequest) error {
+	preCl := &amazon.UpdateClusterAmazon{UpdateAmazonNode: c.nodeScaling()}
+	log.Info("Check stored & updated cluster equals")
+	return utils.IsDifferent(r.UpdateClusterAmazon, preCl)
+}
+
+// defaultSubnets returns the default subnets of the availability zones in the default VPC
+func defaultSubnets(svc *ec2.EC2) ([]string, error) {
+	result, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("default-for-az"), Values: aws.StringSlice([]string{"true"})}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the subnets of the default VPC")
+	}
+	var subnets []string
+	for _, subnet := range result.Subnets {
+		subnets = append(subnets, aws.StringValue(subnet.SubnetId))
+	}
+	if len(subnets) < 2 {
+		return nil, errors.New("EKS needs subnets in two availability zones, the default VPC doesn't have them")
+	}
+	return subnets, nil
+}
+
+// subnets returns the subnets of the cluster, the default subnets of the region without them
+func (c *EKSCluster) subnets(svc *ec2.EC2) ([]*ec2.Subnet, error) {
+	ids := splitList(c.modelCluster.EKS.Subnets)
+	if len(ids) == 0 {
+		var err error
+		if ids, err = defaultSubnets(svc); err != nil {
+			return nil, err
+		}
+	}
+	result, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice(ids)})
+	if err != nil {
+		return nil, errors.Wrap(err, "error describing the subnets")
+	}
+	return result.Subnets, nil
+}
+
+// checkInstanceType checks that the instance type is offered in the availability zones of the subnets, the
+// instance types offered in a zone have reserved instance offerings
+func checkInstanceType(svc *ec2.EC2, instanceType string, subnets []*ec2.Subnet) error {
+	checked := map[string]bool{}
+	for _, subnet := range subnets {
+		zone := aws.StringValue(subnet.AvailabilityZone)
+		if checked[zone] {
+			continue
+		}
+		checked[zone] = true
+		offerings, err := svc.DescribeReservedInstancesOfferings(&ec2.DescribeReservedInstancesOfferingsInput{
+			AvailabilityZone:   aws.String(zone),
+			InstanceType:       aws.String(instanceType),
+			ProductDescription: aws.String("Linux/UNIX"),
+			IncludeMarketplace: aws.Bool(false),
+			MaxResults:         aws.Int64(5),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error checking instance type %s in %s", instanceType, zone)
+		}
+		if len(offerings.ReservedInstancesOfferings) == 0 {
+			return fmt.Errorf("instance type %s is not available in %s", instanceType, zone)
+		}
+	}
+	return nil
+}
+
+//ValidateNetwork checks that the subnets of the cluster are in two availability zones of the VPC of the network
+//and the CIDR of the VPC doesn't conflict with the Docker bridge network of the nodes
+func (c *EKSCluster) ValidateNetwork() error {
+	sess, err := c.session()
+	if err != nil {
+		return err
+	}
+	svc := ec2.New(sess)
+	subnets, err := c.subnets(svc)
+	if err != nil {
+		return err
+	}
+	zones := map[string]bool{}
+	vpcID := ""
+	for _, subnet := range subnets {
+		if vpcID != "" && aws.StringValue(subnet.VpcId) != vpcID {
+			return fmt.Errorf("subnets %s and %s are in different VPCs", aws.StringValue(subnets[0].SubnetId), aws.StringValue(subnet.SubnetId))
+		}
+		vpcID = aws.StringValue(subnet.VpcId)
+		zones[aws.StringValue(subnet.AvailabilityZone)] = true
+	}
+	if len(zones) < 2 {
+		return errors.New("EKS needs subnets in two availability zones")
+	}
+	if network := c.modelCluster.Network; network != "" && network != vpcID {
+		return fmt.Errorf("the subnets are in VPC %s instead of VPC %s of the network", vpcID, network)
+	}
+	vpcs, err := svc.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{vpcID})})
+	if err != nil {
+		return errors.Wrapf(err, "error describing VPC %s", vpcID)
+	}
+	ranges := map[string]string{"the Docker bridge network": eksDockerBridgeCIDR}
+	for _, vpc := range vpcs.Vpcs {
+		ranges["VPC "+vpcID] = aws.StringValue(vpc.CidrBlock)
+	}
+	return CheckCIDRConflicts(ranges)
+}
+
+// splitList splits the comma separated list, the empty list has no items
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// waitForNodegroup waits until the node group is active, or until it's deleted if deleted is set
+func waitForNodegroup(svc *eksService, cluster, name string, deleted bool) error {
+	return waitForEKS("node group "+name, deleted, func() (string, error) {
+		nodegroup, err := svc.describeNodegroup(cluster, name)
+		if err != nil {
+			return "", err
+		}
+		return nodegroup.Status, nil
+	})
+}
+
+//CreateNodePool creates the managed node groups of the node pool
+func (c *EKSCluster) CreateNodePool(pool model.NodePoolModel) error {
+	svc, sess, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	if err := c.checkSpotPrice(ec2.New(sess), pool); err != nil {
+		return err
+	}
+	nodegroups := c.nodegroups(pool)
+	for _, nodegroup := range nodegroups {
+		if err := svc.createNodegroup(c.modelCluster.Name, nodegroup); err != nil {
+			return errors.Wrapf(err, "error creating node group %s", nodegroup.NodegroupName)
+		}
+	}
+	for _, nodegroup := range nodegroups {
+		if err := waitForNodegroup(svc, c.modelCluster.Name, nodegroup.NodegroupName, false); err != nil {
+			return err
+		}
+		if err := c.tagNodegroupInstances(svc, sess, nodegroup.NodegroupName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagNodegroupInstances tags the EC2 instances of the node group with the tags of the cluster, the node group tags
+// aren't propagated to the instances: the tags of its autoscaling group are propagated to the new instances and the
+// instances already launched are tagged
+func (c *EKSCluster) tagNodegroupInstances(svc *eksService, sess *session.Session, name string) error {
+	tags := c.modelCluster.GetTags()
+	if len(tags) == 0 {
+		return nil
+	}
+	nodegroup, err := svc.describeNodegroup(c.modelCluster.Name, name)
+	if err != nil || nodegroup.Resources == nil {
+		return err
+	}
+	asgSvc := autoscaling.New(sess)
+	ec2Tags := make([]*ec2.Tag, 0, len(tags))
+	for key, value := range tags {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	for _, group := range nodegroup.Resources.AutoScalingGroups {
+		asgTags := make([]*autoscaling.Tag, 0, len(tags))
+		for key, value := range tags {
+			asgTags = append(asgTags, &autoscaling.Tag{
+				Key:               aws.String(key),
+				Value:             aws.String(value),
+				PropagateAtLaunch: aws.Bool(true),
+				ResourceId:        aws.String(group.Name),
+				ResourceType:      aws.String("auto-scaling-group"),
+			})
+		}
+		if _, err := asgSvc.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{Tags: asgTags}); err != nil {
+			return errors.Wrapf(err, "error tagging autoscaling group %s", group.Name)
+		}
+		groups, err := asgSvc.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: aws.StringSlice([]string{group.Name}),
+		})
+		if err != nil {
+			return err
+		}
+		instances := []*string{}
+		for _, asg := range groups.AutoScalingGroups {
+			for _, instance := range asg.Instances {
+				instances = append(instances, instance.InstanceId)
+			}
+		}
+		if len(instances) == 0 {
+			continue
+		}
+		if _, err := ec2.New(sess).CreateTags(&ec2.CreateTagsInput{Resources: instances, Tags: ec2Tags}); err != nil {
+			return errors.Wrapf(err, "error tagging the instances of node group %s", name)
+		}
+	}
+	return nil
+}
+
+//UpdateNodePool updates the scaling and the labels of the managed node groups
+func (c *EKSCluster) UpdateNodePool(previous, pool model.NodePoolModel) error {
+	svc, _, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	var labels *eksLabelsUpdate
+	if previous.Labels != pool.Labels {
+		labels = &eksLabelsUpdate{AddOrUpdateLabels: c.modelCluster.NodeLabels(pool)}
+		for key := range c.modelCluster.NodeLabels(previous) {
+			if _, ok := labels.AddOrUpdateLabels[key]; !ok {
+				labels.RemoveLabels = append(labels.RemoveLabels, key)
+			}
+		}
+	}
+	nodegroups := c.nodegroups(pool)
+	for _, nodegroup := range nodegroups {
+		if err := svc.updateNodegroupConfig(c.modelCluster.Name, nodegroup.NodegroupName, nodegroup.ScalingConfig, labels); err != nil {
+			return err
+		}
+	}
+	for _, nodegroup := range nodegroups {
+		if err := waitForNodegroup(svc, c.modelCluster.Name, nodegroup.NodegroupName, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//DeleteNodePool deletes the managed node groups and waits until they're removed
+func (c *EKSCluster) DeleteNodePool(pool model.NodePoolModel) error {
+	svc, _, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	nodegroups := c.nodegroups(pool)
+	for _, nodegroup := range nodegroups {
+		if err := svc.deleteNodegroup(c.modelCluster.Name, nodegroup.NodegroupName); err != nil && !isEKSNotFound(err) {
+			return err
+		}
+	}
+	for _, nodegroup := range nodegroups {
+		if err := waitForNodegroup(svc, c.modelCluster.Name, nodegroup.NodegroupName, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//NodePoolSelector returns the label selector of the nodes of the managed node groups
+func (c *EKSCluster) NodePoolSelector(name string) string {
+	if i, err := findNodePool(c, name); err == nil {
+		if nodegroups := c.nodegroups(c.modelCluster.NodePools[i]); len(nodegroups) > 1 {
+			return fmt.Sprintf("eks.amazonaws.com/nodegroup in (%s,%s)", nodegroups[0].NodegroupName, nodegroups[1].NodegroupName)
+		}
+	}
+	return "eks.amazonaws.com/nodegroup=" + name
+}
+
+//CheckNodeQuota checks the instance type in the availability zones of the subnets and the On-Demand
+//instance limit of the account in the region
+func (c *EKSCluster) CheckNodeQuota(instanceType string, nodes int) error {
+	sess, err := c.session()
+	if err != nil {
+		return err
+	}
+	svc := ec2.New(sess)
+	subnets, err := c.subnets(svc)
+	if err != nil {
+		return err
+	}
+	if err := checkInstanceType(svc, instanceType, subnets); err != nil {
+		return err
+	}
+	attributes, err := svc.DescribeAccountAttributes(&ec2.DescribeAccountAttributesInput{
+		AttributeNames: []*string{aws.String("max-instances")},
+	})
+	if err != nil {
+		return err
+	}
+	limit := 0
+	for _, attribute := range attributes.AccountAttributes {
+		for _, value := range attribute.AttributeValues {
+			fmt.Sscan(aws.StringValue(value.AttributeValue), &limit)
+		}
+	}
+	if limit == 0 {
+		return nil
+	}
+	running := 0
+	err = svc.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{"pending", "running"})}},
+	}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			running += len(reservation.Instances)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if running+nodes > limit {
+		return fmt.Errorf("%d %s instances exceed the instance limit of %s, %d of %d instances are running",
+			nodes, instanceType, c.modelCluster.Location, running, limit)
+	}
+	return nil
+}
+
+// allowAutoscaler adds the policy of the cluster-autoscaler to the node role
+func (c *EKSCluster) allowAutoscaler() error {
+	sess, err := c.session()
+	if err != nil {
+		return err
+	}
+	name := eksRoleName(c.modelCluster.EKS.NodeRoleArn)
+	_, err = iam.New(sess).PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String(name),
+		PolicyName:     aws.String(autoscalerPolicyName),
+		PolicyDocument: aws.String(autoscalerPolicy),
+	})
+	return errors.Wrapf(err, "error adding the autoscaler policy to IAM role %s", name)
+}
+
+//KubernetesVersion returns the Kubernetes version of the control plane
+func (c *EKSCluster) KubernetesVersion() (string, error) {
+	svc, _, err := c.eksService()
+	if err != nil {
+		return "", err
+	}
+	cluster, err := svc.describeCluster(c.modelCluster.Name)
+	if err != nil {
+		return "", err
+	}
+	return cluster.Version, nil
+}
+
+//UpgradeControlPlane upgrades the control plane and waits for the update
+func (c *EKSCluster) UpgradeControlPlane(version string) error {
+	svc, _, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	update, err := svc.updateClusterVersion(c.modelCluster.Name, version)
+	if err != nil {
+		return err
+	}
+	if err := svc.waitForEKSUpdate(c.modelCluster.Name, "", update); err != nil {
+		return err
+	}
+	c.modelCluster.EKS.Version = version
+	return nil
+}
+
+//UpgradeNodePool upgrades the managed node groups of the node pool, maxUnavailable nodes are replaced at once
+func (c *EKSCluster) UpgradeNodePool(pool model.NodePoolModel, version string, maxSurge, maxUnavailable int) error {
+	svc, _, err := c.eksService()
+	if err != nil {
+		return err
+	}
+	for _, nodegroup := range c.nodegroups(pool) {
+		if maxUnavailable != 0 {
+			update, err := svc.updateNodegroupMaxUnavailable(c.modelCluster.Name, nodegroup.NodegroupName, maxUnavailable)
+			if err != nil {
+				return err
+			}
+			if err := svc.waitForEKSUpdate(c.modelCluster.Name, nodegroup.NodegroupName, update); err != nil {
+				return err
+			}
+		}
+		update, err := svc.updateNodegroupVersion(c.modelCluster.Name, nodegroup.NodegroupName, version)
+		if err != nil {
+			return err
+		}
+		if err := svc.waitForEKSUpdate(c.modelCluster.Name, nodegroup.NodegroupName, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
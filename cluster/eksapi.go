@@ -0,0 +1,306 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// EKS cluster and node group statuses
+const (
+	eksStatusActive       = "ACTIVE"
+	eksStatusFailed       = "FAILED"
+	eksStatusCreateFailed = "CREATE_FAILED"
+)
+
+// eksCapacitySpot is the capacity type of the node groups of spot instances
+const eksCapacitySpot = "SPOT"
+
+const eksPollInterval = 15 * time.Second
+
+// eksService calls the EKS API, the vendored AWS SDK doesn't have the EKS client
+type eksService struct {
+	credentials *credentials.Credentials
+	region      string
+	client      *http.Client
+}
+
+func newEKSService(creds *credentials.Credentials, region string) *eksService {
+	return &eksService{credentials: creds, region: region, client: http.DefaultClient}
+}
+
+// eksError is the error response of the EKS API
+type eksError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *eksError) Error() string {
+	return fmt.Sprintf("EKS %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// isEKSNotFound checks whether the EKS resource of the request doesn't exist
+func isEKSNotFound(err error) bool {
+	e, ok := err.(*eksError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// do sends the signed request to EKS and decodes the response into result (if it isn't nil)
+func (s *eksService) do(method, path string, body interface{}, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("https://eks.%s.amazonaws.com%s", s.region, path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := v4.NewSigner(s.credentials).Sign(req, bytes.NewReader(data), "eks", s.region, time.Now()); err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &eksError{StatusCode: resp.StatusCode, Code: strings.Split(resp.Header.Get("X-Amzn-ErrorType"), ":")[0]}
+		var message struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(value, &message)
+		e.Message = message.Message
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(value, result)
+}
+
+// eksCluster is the cluster of the EKS API
+type eksCluster struct {
+	Name                 string `json:"name"`
+	Arn                  string `json:"arn,omitempty"`
+	Version              string `json:"version,omitempty"`
+	Status               string `json:"status,omitempty"`
+	Endpoint             string `json:"endpoint,omitempty"`
+	RoleArn              string `json:"roleArn"`
+	CertificateAuthority *struct {
+		Data string `json:"data"`
+	} `json:"certificateAuthority,omitempty"`
+	Identity *struct {
+		OIDC struct {
+			Issuer string `json:"issuer"`
+		} `json:"oidc"`
+	} `json:"identity,omitempty"`
+	ResourcesVpcConfig struct {
+		SubnetIds        []string `json:"subnetIds"`
+		SecurityGroupIds []string `json:"securityGroupIds,omitempty"`
+		// the endpoint is public without the access flags
+		EndpointPrivateAccess *bool `json:"endpointPrivateAccess,omitempty"`
+		EndpointPublicAccess  *bool `json:"endpointPublicAccess,omitempty"`
+	} `json:"resourcesVpcConfig"`
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// eksScalingConfig is the size of a managed node group
+type eksScalingConfig struct {
+	MinSize     int `json:"minSize"`
+	MaxSize     int `json:"maxSize"`
+	DesiredSize int `json:"desiredSize"`
+}
+
+// eksNodegroup is the managed node group of the EKS API
+type eksNodegroup struct {
+	NodegroupName string            `json:"nodegroupName"`
+	Status        string            `json:"status,omitempty"`
+	ScalingConfig eksScalingConfig  `json:"scalingConfig"`
+	Subnets       []string          `json:"subnets"`
+	InstanceTypes []string          `json:"instanceTypes"`
+	NodeRole      string            `json:"nodeRole"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	CapacityType  string            `json:"capacityType,omitempty"`
+	RemoteAccess  *eksRemoteAccess  `json:"remoteAccess,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	// Resources are the resources of the node group created by EKS
+	Resources *struct {
+		AutoScalingGroups []struct {
+			Name string `json:"name"`
+		} `json:"autoScalingGroups"`
+	} `json:"resources,omitempty"`
+}
+
+// eksRemoteAccess is the SSH access of the nodes of a managed node group
+type eksRemoteAccess struct {
+	EC2SSHKey            string   `json:"ec2SshKey"`
+	SourceSecurityGroups []string `json:"sourceSecurityGroups,omitempty"`
+}
+
+// eksLabelsUpdate is the change of the labels of a managed node group
+type eksLabelsUpdate struct {
+	AddOrUpdateLabels map[string]string `json:"addOrUpdateLabels,omitempty"`
+	RemoveLabels      []string          `json:"removeLabels,omitempty"`
+}
+
+func (s *eksService) createCluster(cluster *eksCluster) (*eksCluster, error) {
+	var response struct {
+		Cluster eksCluster `json:"cluster"`
+	}
+	if err := s.do(http.MethodPost, "/clusters", cluster, &response); err != nil {
+		return nil, err
+	}
+	return &response.Cluster, nil
+}
+
+func (s *eksService) describeCluster(name string) (*eksCluster, error) {
+	var response struct {
+		Cluster eksCluster `json:"cluster"`
+	}
+	if err := s.do(http.MethodGet, "/clusters/"+name, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response.Cluster, nil
+}
+
+func (s *eksService) deleteCluster(name string) error {
+	return s.do(http.MethodDelete, "/clusters/"+name, nil, nil)
+}
+
+func (s *eksService) createNodegroup(cluster string, nodegroup *eksNodegroup) error {
+	return s.do(http.MethodPost, fmt.Sprintf("/clusters/%s/node-groups", cluster), nodegroup, nil)
+}
+
+func (s *eksService) describeNodegroup(cluster, name string) (*eksNodegroup, error) {
+	var response struct {
+		Nodegroup eksNodegroup `json:"nodegroup"`
+	}
+	if err := s.do(http.MethodGet, fmt.Sprintf("/clusters/%s/node-groups/%s", cluster, name), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response.Nodegroup, nil
+}
+
+func (s *eksService) deleteNodegroup(cluster, name string) error {
+	return s.do(http.MethodDelete, fmt.Sprintf("/clusters/%s/node-groups/%s", cluster, name), nil, nil)
+}
+
+// updateNodegroupConfig updates the scaling and the labels of the node group, the labels are optional
+func (s *eksService) updateNodegroupConfig(cluster, name string, scaling eksScalingConfig, labels *eksLabelsUpdate) error {
+	body := map[string]interface{}{"scalingConfig": scaling}
+	if labels != nil {
+		body["labels"] = labels
+	}
+	return s.do(http.MethodPost, fmt.Sprintf("/clusters/%s/node-groups/%s/update-config", cluster, name), body, nil)
+}
+
+// eksUpdate is an update of an EKS cluster or node group
+type eksUpdate struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Errors []struct {
+		ErrorMessage string `json:"errorMessage"`
+	} `json:"errors,omitempty"`
+}
+
+// EKS update statuses
+const (
+	eksUpdateSuccessful = "Successful"
+	eksUpdateFailed     = "Failed"
+	eksUpdateCancelled  = "Cancelled"
+)
+
+// startUpdate sends an update request and returns the started update
+func (s *eksService) startUpdate(path string, body interface{}) (*eksUpdate, error) {
+	var response struct {
+		Update eksUpdate `json:"update"`
+	}
+	if err := s.do(http.MethodPost, path, body, &response); err != nil {
+		return nil, err
+	}
+	return &response.Update, nil
+}
+
+// updateClusterVersion starts the upgrade of the control plane to the Kubernetes version
+func (s *eksService) updateClusterVersion(cluster, version string) (*eksUpdate, error) {
+	return s.startUpdate(fmt.Sprintf("/clusters/%s/updates", cluster), map[string]interface{}{"version": version})
+}
+
+// updateNodegroupVersion starts the upgrade of the nodes of the node group to the Kubernetes version
+func (s *eksService) updateNodegroupVersion(cluster, name, version string) (*eksUpdate, error) {
+	path := fmt.Sprintf("/clusters/%s/node-groups/%s/update-version", cluster, name)
+	return s.startUpdate(path, map[string]interface{}{"version": version})
+}
+
+// updateNodegroupMaxUnavailable sets the number of nodes of the node group upgraded at once
+func (s *eksService) updateNodegroupMaxUnavailable(cluster, name string, maxUnavailable int) (*eksUpdate, error) {
+	path := fmt.Sprintf("/clusters/%s/node-groups/%s/update-config", cluster, name)
+	return s.startUpdate(path, map[string]interface{}{"updateConfig": map[string]interface{}{"maxUnavailable": maxUnavailable}})
+}
+
+// waitForEKSUpdate polls the update of the cluster, or of the node group if it isn't empty, until it's finished
+func (s *eksService) waitForEKSUpdate(cluster, nodegroup string, update *eksUpdate) error {
+	path := fmt.Sprintf("/clusters/%s/updates/%s", cluster, update.ID)
+	if nodegroup != "" {
+		path += "?nodegroupName=" + nodegroup
+	}
+	for {
+		var response struct {
+			Update eksUpdate `json:"update"`
+		}
+		if err := s.do(http.MethodGet, path, nil, &response); err != nil {
+			return err
+		}
+		log.Infof("EKS update %s of cluster %s status: %s", update.ID, cluster, response.Update.Status)
+		switch response.Update.Status {
+		case eksUpdateSuccessful:
+			return nil
+		case eksUpdateFailed, eksUpdateCancelled:
+			var messages []string
+			for _, e := range response.Update.Errors {
+				messages = append(messages, e.ErrorMessage)
+			}
+			return fmt.Errorf("EKS update %s of cluster %s failed: %s", update.ID, cluster, strings.Join(messages, ", "))
+		}
+		time.Sleep(eksPollInterval)
+	}
+}
+
+// waitForEKS polls the status of an EKS resource until it's active, or until it's deleted if deleted is set
+func waitForEKS(resource string, deleted bool, status func() (string, error)) error {
+	for {
+		current, err := status()
+		if deleted && isEKSNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Infof("EKS %s status: %s", resource, current)
+		switch current {
+		case eksStatusActive:
+			if !deleted {
+				return nil
+			}
+		case eksStatusFailed, eksStatusCreateFailed:
+			return fmt.Errorf("EKS %s failed", resource)
+		}
+		time.Sleep(eksPollInterval)
+	}
+}
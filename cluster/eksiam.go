@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+)
+
+const (
+	eksClusterTrustPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"eks.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+	eksNodeTrustPolicy    = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+	// the audience of the service account tokens of IRSA
+	eksOIDCClientID = "sts.amazonaws.com"
+	// https://github.com/kubernetes-sigs/aws-iam-authenticator#api-authorization-from-outside-a-cluster
+	eksTokenPrefix   = "k8s-aws-v1."
+	eksClusterHeader = "x-k8s-aws-id"
+	// the authenticator accepts the presigned URLs for 15 minutes
+	eksTokenLifetime = 15 * time.Minute
+)
+
+var eksClusterPolicies = []string{
+	"arn:aws:iam::aws:policy/AmazonEKSClusterPolicy",
+	"arn:aws:iam::aws:policy/AmazonEKSServicePolicy",
+}
+
+var eksNodePolicies = []string{
+	"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+	"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
+	"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+}
+
+// createEKSRole creates the role with the trust policy and attaches the policies, it returns the ARN of the role
+func createEKSRole(svc *iam.IAM, name, trustPolicy string, policies []string) (string, error) {
+	role, err := svc.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 aws.String(name),
+		AssumeRolePolicyDocument: aws.String(trustPolicy),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating IAM role %s", name)
+	}
+	for _, policy := range policies {
+		if _, err := svc.AttachRolePolicy(&iam.AttachRolePolicyInput{RoleName: aws.String(name), PolicyArn: aws.String(policy)}); err != nil {
+			return "", errors.Wrapf(err, "error attaching %s to IAM role %s", policy, name)
+		}
+	}
+	return aws.StringValue(role.Role.Arn), nil
+}
+
+// eksRoleName returns the name of the IAM role of the ARN
+func eksRoleName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// deleteEKSRolePolicy deletes the inline policy of the role, it doesn't fail if the policy doesn't exist
+func deleteEKSRolePolicy(svc *iam.IAM, name, policy string) error {
+	_, err := svc.DeleteRolePolicy(&iam.DeleteRolePolicyInput{RoleName: aws.String(name), PolicyName: aws.String(policy)})
+	if err != nil && !isIAMNotFound(err) {
+		return errors.Wrapf(err, "error deleting %s from IAM role %s", policy, name)
+	}
+	return nil
+}
+
+// deleteEKSRole detaches the policies of the role and deletes it with its autoscaler policy,
+// it doesn't fail if the role doesn't exist
+func deleteEKSRole(svc *iam.IAM, name string, policies []string) error {
+	if err := deleteEKSRolePolicy(svc, name, autoscalerPolicyName); err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		_, err := svc.DetachRolePolicy(&iam.DetachRolePolicyInput{RoleName: aws.String(name), PolicyArn: aws.String(policy)})
+		if err != nil && !isIAMNotFound(err) {
+			return errors.Wrapf(err, "error detaching %s from IAM role %s", policy, name)
+		}
+	}
+	if _, err := svc.DeleteRole(&iam.DeleteRoleInput{RoleName: aws.String(name)}); err != nil && !isIAMNotFound(err) {
+		return errors.Wrapf(err, "error deleting IAM role %s", name)
+	}
+	return nil
+}
+
+func isIAMNotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException
+}
+
+// createEKSOIDCProvider creates the IAM OIDC identity provider of the issuer of the cluster for the IAM roles
+// of service accounts (IRSA), it returns the ARN of the provider
+func createEKSOIDCProvider(svc *iam.IAM, issuer string) (string, error) {
+	thumbprint, err := oidcThumbprint(issuer)
+	if err != nil {
+		return "", err
+	}
+	provider, err := svc.CreateOpenIDConnectProvider(&iam.CreateOpenIDConnectProviderInput{
+		Url:            aws.String(issuer),
+		ClientIDList:   aws.StringSlice([]string{eksOIDCClientID}),
+		ThumbprintList: aws.StringSlice([]string{thumbprint}),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating OIDC provider %s", issuer)
+	}
+	return aws.StringValue(provider.OpenIDConnectProviderArn), nil
+}
+
+// oidcThumbprint returns the SHA-1 thumbprint of the root CA certificate of the issuer
+func oidcThumbprint(issuer string) (string, error) {
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return "", err
+	}
+	conn, err := tls.Dial("tcp", issuerURL.Host+":443", &tls.Config{})
+	if err != nil {
+		return "", errors.Wrapf(err, "error connecting to OIDC issuer %s", issuer)
+	}
+	defer conn.Close()
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return "", fmt.Errorf("OIDC issuer %s has no certificates", issuer)
+	}
+	sum := sha1.Sum(certificates[len(certificates)-1].Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// eksToken returns a token of the IAM user of the session for the cluster like aws-iam-authenticator does,
+// it's a presigned STS GetCallerIdentity request and expires in 15 minutes
+func eksToken(sess *session.Session, clusterName string) (string, time.Time, error) {
+	req, _ := sts.New(sess).GetCallerIdentityRequest(&sts.GetCallerIdentityInput{})
+	req.HTTPRequest.Header.Add(eksClusterHeader, clusterName)
+	presigned, err := req.Presign(60 * time.Second)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token := eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(presigned))
+	return token, time.Now().Add(eksTokenLifetime), nil
+}
@@ -0,0 +1,494 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// the layout of the names of the etcd snapshots, they're sorted by their time
+const etcdSnapshotNameLayout = "20060102T150405Z"
+
+// the directory of the snapshots on the master, the etcd data and the kubeadm static pods of the control plane
+const (
+	etcdSnapshotDir      = "/var/lib/pipeline-etcd"
+	etcdDataDir          = "/var/lib/etcd"
+	etcdManifestDir      = "/etc/kubernetes/manifests"
+	etcdStoppedManifests = "/etc/kubernetes/manifests.pipeline"
+	etcdPKIDir           = "/etc/kubernetes/pki/etcd"
+)
+
+//EtcdManager is implemented by the clusters whose control plane is run by Pipeline, the control plane of the
+//other providers is managed and backed up by the cloud
+type EtcdManager interface {
+	// SaveEtcdSnapshot takes a snapshot of the etcd of the master and writes it to the writer
+	SaveEtcdSnapshot(w io.Writer) error
+	// RestoreEtcdSnapshot replaces the data of the etcd of the master with the snapshot
+	RestoreEtcdSnapshot(r io.Reader) error
+}
+
+//EtcdSnapshot is a snapshot of the etcd of a cluster in the bucket of its snapshot policy
+type EtcdSnapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//EtcdSnapshotNotFoundError is returned if the bucket has no snapshot of the name
+type EtcdSnapshotNotFoundError struct {
+	Name string
+}
+
+// Error implements error
+func (e *EtcdSnapshotNotFoundError) Error() string {
+	return fmt.Sprintf("etcd snapshot not found: %s", e.Name)
+}
+
+// etcdSnapshotsRunning are the clusters with a running snapshot or restore
+var etcdSnapshotsRunning = struct {
+	sync.Mutex
+	clusters map[uint]bool
+}{clusters: map[uint]bool{}}
+
+// lockEtcdSnapshots marks the snapshot or the restore of the cluster running, it fails if another one runs
+func lockEtcdSnapshots(clusterID uint) error {
+	etcdSnapshotsRunning.Lock()
+	defer etcdSnapshotsRunning.Unlock()
+	if etcdSnapshotsRunning.clusters[clusterID] {
+		return fmt.Errorf("another etcd snapshot or restore of the cluster is running")
+	}
+	etcdSnapshotsRunning.clusters[clusterID] = true
+	return nil
+}
+
+// unlockEtcdSnapshots marks the snapshot or the restore of the cluster finished
+func unlockEtcdSnapshots(clusterID uint) {
+	etcdSnapshotsRunning.Lock()
+	defer etcdSnapshotsRunning.Unlock()
+	delete(etcdSnapshotsRunning.clusters, clusterID)
+}
+
+// etcdctlCommand runs etcdctl of the etcd image on the master, the PKI of etcd and the data directories are mounted
+func etcdctlCommand(args string) string {
+	return fmt.Sprintf("sudo docker run --rm --network host -e ETCDCTL_API=3 -v %s:%s:ro -v /var/lib:/var/lib %s etcdctl %s",
+		etcdPKIDir, etcdPKIDir, viper.GetString("etcdsnapshots.image"), args)
+}
+
+// etcdSnapshotSaveScript saves a snapshot of etcd with the health check client certificate of kubeadm and
+// writes it to the standard output
+func etcdSnapshotSaveScript() string {
+	file := etcdSnapshotDir + "/snapshot.db"
+	return strings.Join([]string{
+		"set -e",
+		"sudo mkdir -p " + etcdSnapshotDir,
+		"sudo rm -f " + file,
+		etcdctlCommand(fmt.Sprintf("--endpoints https://127.0.0.1:2379 --cacert %[1]s/ca.crt --cert %[1]s/healthcheck-client.crt --key %[1]s/healthcheck-client.key snapshot save %[2]s >&2", etcdPKIDir, file)),
+		"sudo cat " + file,
+		"sudo rm -f " + file,
+	}, "\n")
+}
+
+// etcdSnapshotRestoreScript restores the snapshot of the standard input to a new data directory, stops the etcd
+// and the API server static pods, swaps the data directories and starts the control plane again. The previous
+// data directory is kept next to the new one.
+func etcdSnapshotRestoreScript() string {
+	file := etcdSnapshotDir + "/restore.db"
+	restored := etcdDataDir + ".restored"
+	manifests := "etcd.yaml kube-apiserver.yaml"
+	return strings.Join([]string{
+		"set -e",
+		"sudo mkdir -p " + etcdSnapshotDir + " " + etcdStoppedManifests,
+		"sudo tee " + file + " >/dev/null",
+		"sudo rm -rf " + restored,
+		etcdctlCommand(fmt.Sprintf("snapshot restore %s --data-dir %s --name $(hostname) --initial-cluster $(hostname)=https://127.0.0.1:2380 --initial-advertise-peer-urls https://127.0.0.1:2380 >&2", file, restored)),
+		fmt.Sprintf("cd %s && sudo mv %s %s/", etcdManifestDir, manifests, etcdStoppedManifests),
+		`timeout 300 sh -c 'until [ -z "$(sudo docker ps -q -f name=k8s_etcd -f name=k8s_kube-apiserver)" ]; do sleep 5; done'`,
+		fmt.Sprintf("sudo mv %s %s.$(date +%%s)", etcdDataDir, etcdDataDir),
+		fmt.Sprintf("sudo mv %s %s", restored, etcdDataDir),
+		fmt.Sprintf("cd %s && sudo mv %s %s/", etcdStoppedManifests, manifests, etcdManifestDir),
+		"sudo rm -f " + file,
+		"timeout 300 sh -c 'until curl -sfk https://127.0.0.1:6443/healthz >/dev/null; do sleep 5; done'",
+	}, "\n")
+}
+
+//CheckEtcdSnapshots checks whether the etcd of the cluster can be saved and restored by Pipeline
+func CheckEtcdSnapshots(commonCluster CommonCluster) (EtcdManager, error) {
+	if manager, ok := commonCluster.(EtcdManager); ok {
+		return manager, nil
+	}
+	return nil, fmt.Errorf("etcd snapshots are not supported on %s, its control plane is managed by the provider", commonCluster.GetType())
+}
+
+//ValidateEtcdSnapshotPolicy checks the schedule, the time zone, the bucket and the retention of the policy
+func ValidateEtcdSnapshotPolicy(policy model.EtcdSnapshotPolicyModel) error {
+	if _, err := parseCron(policy.Schedule); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(policy.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone: %s", policy.TimeZone)
+	}
+	if policy.Bucket == "" || strings.Contains(policy.Bucket, "/") {
+		return fmt.Errorf("invalid bucket: %q", policy.Bucket)
+	}
+	if policy.Region == "" {
+		return fmt.Errorf("the region of the bucket is required")
+	}
+	if policy.SecretID == "" {
+		return fmt.Errorf("the bucket requires a %s secret", secret.Amazon)
+	}
+	if policy.Retention < 0 || policy.MaxAgeDays < 0 {
+		return fmt.Errorf("the retention and the maximum age can't be negative")
+	}
+	return nil
+}
+
+//SetEtcdSnapshotPolicy creates or replaces the etcd snapshot policy of the cluster
+func SetEtcdSnapshotPolicy(commonCluster CommonCluster, policy *model.EtcdSnapshotPolicyModel) error {
+	if _, err := CheckEtcdSnapshots(commonCluster); err != nil {
+		return err
+	}
+	policy.ClusterModelID = commonCluster.GetID()
+	if err := ValidateEtcdSnapshotPolicy(*policy); err != nil {
+		return err
+	}
+	if _, err := etcdSnapshotBucket(commonCluster, policy); err != nil {
+		return err
+	}
+	return model.SaveEtcdSnapshotPolicy(policy)
+}
+
+// etcdSnapshotBucket returns the S3 client of the bucket of the policy with its Amazon secret
+func etcdSnapshotBucket(commonCluster CommonCluster, policy *model.EtcdSnapshotPolicyModel) (*s3.S3, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), policy.SecretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the secret of the etcd snapshot bucket")
+	}
+	if item.SecretType != secret.Amazon {
+		return nil, fmt.Errorf("the secret of the etcd snapshot bucket must be a %s, not a %s", secret.Amazon, item.SecretType)
+	}
+	creds := credentials.NewStaticCredentials(item.Values["AWS_ACCESS_KEY_ID"], item.Values["AWS_SECRET_ACCESS_KEY"], "")
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(policy.Region).WithCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(sess), nil
+}
+
+// etcdSnapshotPrefix returns the key prefix of the snapshots of the cluster in the bucket
+func etcdSnapshotPrefix(commonCluster CommonCluster, policy *model.EtcdSnapshotPolicyModel) string {
+	if policy.Prefix != "" {
+		return strings.Trim(policy.Prefix, "/") + "/"
+	}
+	return "etcd-snapshots/" + commonCluster.GetName() + "/"
+}
+
+// etcdSnapshotPolicy loads the snapshot policy of the cluster and the client of its bucket
+func etcdSnapshotPolicy(commonCluster CommonCluster) (*model.EtcdSnapshotPolicyModel, *s3.S3, error) {
+	policy, err := model.GetEtcdSnapshotPolicy(commonCluster.GetID())
+	if err != nil {
+		return nil, nil, err
+	}
+	bucket, err := etcdSnapshotBucket(commonCluster, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	return policy, bucket, nil
+}
+
+//ListEtcdSnapshots lists the etcd snapshots of the cluster in the bucket of its policy, the newest is the first
+func ListEtcdSnapshots(commonCluster CommonCluster) ([]EtcdSnapshot, error) {
+	policy, bucket, err := etcdSnapshotPolicy(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	return listEtcdSnapshots(bucket, policy.Bucket, etcdSnapshotPrefix(commonCluster, policy))
+}
+
+// listEtcdSnapshots lists the snapshots under the prefix, the other objects are skipped
+func listEtcdSnapshots(bucket *s3.S3, name, prefix string) ([]EtcdSnapshot, error) {
+	snapshots := []EtcdSnapshot{}
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(name), Prefix: aws.String(prefix)}
+	err := bucket.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, object := range page.Contents {
+			snapshotName := strings.TrimSuffix(path.Base(aws.StringValue(object.Key)), ".db")
+			createdAt, err := time.Parse(etcdSnapshotNameLayout, snapshotName)
+			if err != nil || aws.StringValue(object.Key) != prefix+snapshotName+".db" {
+				continue
+			}
+			snapshots = append(snapshots, EtcdSnapshot{Name: snapshotName, Size: aws.Int64Value(object.Size), CreatedAt: createdAt})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the etcd snapshots")
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+//GetEtcdSnapshot returns the etcd snapshot of the cluster, the error is an EtcdSnapshotNotFoundError if the
+//bucket has no snapshot of the name
+func GetEtcdSnapshot(commonCluster CommonCluster, name string) (*EtcdSnapshot, error) {
+	policy, bucket, err := etcdSnapshotPolicy(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := time.Parse(etcdSnapshotNameLayout, name)
+	if err != nil {
+		return nil, &EtcdSnapshotNotFoundError{Name: name}
+	}
+	head, err := bucket.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(policy.Bucket),
+		Key:    aws.String(etcdSnapshotPrefix(commonCluster, policy) + name + ".db"),
+	})
+	if err != nil {
+		return nil, etcdSnapshotError(name, err)
+	}
+	return &EtcdSnapshot{Name: name, Size: aws.Int64Value(head.ContentLength), CreatedAt: createdAt}, nil
+}
+
+// etcdSnapshotError returns an EtcdSnapshotNotFoundError for the missing objects
+func etcdSnapshotError(name string, err error) error {
+	if awsErr, ok := err.(awserr.Error); ok && (awsErr.Code() == s3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound") {
+		return &EtcdSnapshotNotFoundError{Name: name}
+	}
+	return err
+}
+
+//TakeEtcdSnapshot saves a snapshot of the etcd of the cluster to the bucket of its policy and deletes the
+//snapshots expired by the retention of the policy, the result is recorded on the policy
+func TakeEtcdSnapshot(commonCluster CommonCluster) (*EtcdSnapshot, error) {
+	manager, err := CheckEtcdSnapshots(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	policy, bucket, err := etcdSnapshotPolicy(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockEtcdSnapshots(commonCluster.GetID()); err != nil {
+		return nil, err
+	}
+	defer unlockEtcdSnapshots(commonCluster.GetID())
+
+	now := time.Now().UTC()
+	snapshot, err := saveEtcdSnapshot(manager, bucket, policy.Bucket, etcdSnapshotPrefix(commonCluster, policy), now)
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	if err := model.SetEtcdSnapshotResult(commonCluster.GetID(), now, message); err != nil {
+		logger.Errorf("Error saving the etcd snapshot result of cluster %s: %s", commonCluster.GetName(), err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := pruneEtcdSnapshots(bucket, policy, etcdSnapshotPrefix(commonCluster, policy), now); err != nil {
+		logger.Warnf("Error deleting the expired etcd snapshots of cluster %s: %s", commonCluster.GetName(), err.Error())
+	}
+	return snapshot, nil
+}
+
+// saveEtcdSnapshot saves the snapshot to a temporary file and uploads it, S3 needs the size of the object
+func saveEtcdSnapshot(manager EtcdManager, bucket *s3.S3, name, prefix string, now time.Time) (*EtcdSnapshot, error) {
+	file, err := ioutil.TempFile("", "etcd-snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if err := manager.SaveEtcdSnapshot(file); err != nil {
+		return nil, errors.Wrap(err, "error saving the etcd snapshot")
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("the etcd snapshot is empty")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	snapshot := &EtcdSnapshot{Name: now.Format(etcdSnapshotNameLayout), Size: size, CreatedAt: now.Truncate(time.Second)}
+	_, err = bucket.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(name),
+		Key:                  aws.String(prefix + snapshot.Name + ".db"),
+		Body:                 file,
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error uploading the etcd snapshot")
+	}
+	return snapshot, nil
+}
+
+// pruneEtcdSnapshots deletes the snapshots expired by the retention of the policy
+func pruneEtcdSnapshots(bucket *s3.S3, policy *model.EtcdSnapshotPolicyModel, prefix string, now time.Time) error {
+	snapshots, err := listEtcdSnapshots(bucket, policy.Bucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range ExpiredEtcdSnapshots(snapshots, policy.Retention, policy.MaxAgeDays, now) {
+		_, err := bucket.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(policy.Bucket),
+			Key:    aws.String(prefix + snapshot.Name + ".db"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ExpiredEtcdSnapshots returns the snapshots beyond the retention count or older than the maximum age at the
+//time, zero is no limit; the newest snapshot is never expired so a cluster always has one to restore
+func ExpiredEtcdSnapshots(snapshots []EtcdSnapshot, retention, maxAgeDays int, now time.Time) []EtcdSnapshot {
+	sorted := append([]EtcdSnapshot{}, snapshots...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+	maxAge := time.Duration(maxAgeDays) * 24 * time.Hour
+	var expired []EtcdSnapshot
+	for i, snapshot := range sorted {
+		if i == 0 {
+			continue
+		}
+		if retention > 0 && i >= retention || maxAge > 0 && now.Sub(snapshot.CreatedAt) > maxAge {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
+
+//DeleteEtcdSnapshot deletes the etcd snapshot of the cluster from the bucket of its policy
+func DeleteEtcdSnapshot(commonCluster CommonCluster, name string) error {
+	if _, err := GetEtcdSnapshot(commonCluster, name); err != nil {
+		return err
+	}
+	policy, bucket, err := etcdSnapshotPolicy(commonCluster)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(policy.Bucket),
+		Key:    aws.String(etcdSnapshotPrefix(commonCluster, policy) + name + ".db"),
+	})
+	return err
+}
+
+//RestoreEtcdSnapshot downloads the etcd snapshot of the cluster and restores it on the master, the control
+//plane is down during the restore and the objects created after the snapshot are lost
+func RestoreEtcdSnapshot(commonCluster CommonCluster, name string) error {
+	log := logger.WithFields(logrus.Fields{"action": "RestoreEtcdSnapshot"})
+	manager, err := CheckEtcdSnapshots(commonCluster)
+	if err != nil {
+		return err
+	}
+	policy, bucket, err := etcdSnapshotPolicy(commonCluster)
+	if err != nil {
+		return err
+	}
+	if err := lockEtcdSnapshots(commonCluster.GetID()); err != nil {
+		return err
+	}
+	defer unlockEtcdSnapshots(commonCluster.GetID())
+
+	object, err := bucket.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(policy.Bucket),
+		Key:    aws.String(etcdSnapshotPrefix(commonCluster, policy) + name + ".db"),
+	})
+	if err != nil {
+		return etcdSnapshotError(name, err)
+	}
+	defer object.Body.Close()
+	log.Infof("Restoring etcd snapshot %s of cluster %s", name, commonCluster.GetName())
+	if err := manager.RestoreEtcdSnapshot(object.Body); err != nil {
+		return errors.Wrap(err, "error restoring the etcd snapshot")
+	}
+	log.Infof("Etcd snapshot %s of cluster %s restored", name, commonCluster.GetName())
+	return nil
+}
+
+//RunEtcdSnapshotScheduler takes the etcd snapshots of the clusters by the schedules of their policies every
+//minute, it never returns
+func RunEtcdSnapshotScheduler() {
+	log := logger.WithFields(logrus.Fields{"action": "EtcdSnapshotScheduler"})
+	last := time.Now().Truncate(time.Minute)
+	for range time.Tick(time.Minute) {
+		now := time.Now().Truncate(time.Minute)
+		// the minutes are checked one by one so a late tick doesn't skip a schedule
+		for t := last.Add(time.Minute); !t.After(now); t = t.Add(time.Minute) {
+			if err := runEtcdSnapshotSchedules(t); err != nil {
+				log.Errorf("Error running the etcd snapshot schedules: %s", err.Error())
+			}
+		}
+		last = now
+	}
+}
+
+// runEtcdSnapshotSchedules starts the snapshots of the policies scheduled in the minute, the clusters which
+// aren't running are skipped
+func runEtcdSnapshotSchedules(t time.Time) error {
+	log := logger.WithFields(logrus.Fields{"action": "EtcdSnapshotScheduler"})
+	policies, err := model.ListEtcdSnapshotPolicies()
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		schedule, err := parseCron(policy.Schedule)
+		if err != nil {
+			log.Warnf("Invalid etcd snapshot schedule of cluster %d: %s", policy.ClusterModelID, err.Error())
+			continue
+		}
+		location, _ := time.LoadLocation(policy.TimeZone)
+		if !schedule.matches(t.In(location)) {
+			continue
+		}
+		var modelCluster model.ClusterModel
+		if err := model.GetDB().First(&modelCluster, policy.ClusterModelID).Error; err != nil {
+			if model.IsErrorGormNotFound(err) {
+				err = model.DeleteEtcdSnapshotPolicy(policy.ClusterModelID)
+			}
+			if err != nil {
+				log.Warnf("Error loading cluster %d: %s", policy.ClusterModelID, err.Error())
+			}
+			continue
+		}
+		commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+		if err != nil {
+			log.Warnf("Error loading cluster %s: %s", modelCluster.Name, err.Error())
+			continue
+		}
+		if status := ClusterStatus(commonCluster); status != StatusRunning {
+			log.Infof("The etcd snapshot of cluster %s is skipped in the %s status", modelCluster.Name, status)
+			continue
+		}
+		go func() {
+			if _, err := TakeEtcdSnapshot(commonCluster); err != nil {
+				log.Errorf("Error taking the etcd snapshot of cluster %s: %s", commonCluster.GetName(), err.Error())
+			}
+		}()
+	}
+	return nil
+}
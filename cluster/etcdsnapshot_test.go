@@ -0,0 +1,83 @@
+package cluster_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestValidateEtcdSnapshotPolicy(t *testing.T) {
+
+	valid := model.EtcdSnapshotPolicyModel{Schedule: "0 */6 * * *", Bucket: "etcd", Region: "eu-west-1", SecretID: "s", Retention: 28}
+	withChange := func(change func(*model.EtcdSnapshotPolicyModel)) model.EtcdSnapshotPolicyModel {
+		policy := valid
+		change(&policy)
+		return policy
+	}
+
+	cases := []struct {
+		name        string
+		policy      model.EtcdSnapshotPolicyModel
+		expectError bool
+	}{
+		{name: "valid", policy: valid},
+		{name: "time zone", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.TimeZone = "Europe/Budapest" })},
+		{name: "invalid schedule", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.Schedule = "every day" }), expectError: true},
+		{name: "invalid time zone", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.TimeZone = "Mars/Olympus" }), expectError: true},
+		{name: "bucket path", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.Bucket = "etcd/prod" }), expectError: true},
+		{name: "no region", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.Region = "" }), expectError: true},
+		{name: "no secret", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.SecretID = "" }), expectError: true},
+		{name: "negative retention", policy: withChange(func(p *model.EtcdSnapshotPolicyModel) { p.Retention = -1 }), expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateEtcdSnapshotPolicy(tc.policy)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateEtcdSnapshotPolicy: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestExpiredEtcdSnapshots(t *testing.T) {
+
+	now := time.Date(2018, 6, 10, 12, 0, 0, 0, time.UTC)
+	snapshot := func(days int) cluster.EtcdSnapshot {
+		createdAt := now.Add(-time.Duration(days) * 24 * time.Hour)
+		return cluster.EtcdSnapshot{Name: createdAt.Format("20060102T150405Z"), CreatedAt: createdAt}
+	}
+	snapshots := []cluster.EtcdSnapshot{snapshot(3), snapshot(0), snapshot(10), snapshot(1)}
+
+	cases := []struct {
+		name       string
+		snapshots  []cluster.EtcdSnapshot
+		retention  int
+		maxAgeDays int
+		expected   []cluster.EtcdSnapshot
+	}{
+		{name: "no limit", snapshots: snapshots},
+		{name: "retention", snapshots: snapshots, retention: 2, expected: []cluster.EtcdSnapshot{snapshot(3), snapshot(10)}},
+		{name: "max age", snapshots: snapshots, maxAgeDays: 2, expected: []cluster.EtcdSnapshot{snapshot(3), snapshot(10)}},
+		{name: "both", snapshots: snapshots, retention: 3, maxAgeDays: 5, expected: []cluster.EtcdSnapshot{snapshot(10)}},
+		{name: "newest kept", snapshots: []cluster.EtcdSnapshot{snapshot(10), snapshot(20)}, maxAgeDays: 5, expected: []cluster.EtcdSnapshot{snapshot(20)}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expired := cluster.ExpiredEtcdSnapshots(tc.snapshots, tc.retention, tc.maxAgeDays, now)
+			if !reflect.DeepEqual(expired, tc.expected) {
+				t.Errorf("Expected %v, got: %v", tc.expected, expired)
+			}
+		})
+	}
+}
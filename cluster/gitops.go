@@ -0,0 +1,454 @@
+package cluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/banzaicloud/pipeline/utils"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Kinds of the GitOps applications: the manifests of the path are applied, or the values file of the path
+// is deployed with the chart of the application
+const (
+	GitOpsManifests = "manifests"
+	GitOpsHelm      = "helm"
+)
+
+// Sync statuses of the GitOps applications
+const (
+	GitOpsUnknown   = "Unknown"
+	GitOpsSynced    = "Synced"
+	GitOpsOutOfSync = "OutOfSync"
+	GitOpsFailed    = "Failed"
+)
+
+// gitOpsAppLabel is the label of the resources applied from the manifests of an application
+const gitOpsAppLabel = "pipeline.banzaicloud.com/gitops-app"
+
+// gitOpsAppNamePattern is the pattern of the names of the applications, they're the release names of the charts
+var gitOpsAppNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+//ValidateGitOpsApp checks the name, the repository, the branch, the path and the kind of the application
+func ValidateGitOpsApp(app model.GitOpsAppModel) error {
+	if !gitOpsAppNamePattern.MatchString(app.Name) {
+		return fmt.Errorf("invalid application name %q, it must consist of lower case alphanumeric characters or '-'", app.Name)
+	}
+	u, err := url.Parse(app.RepositoryURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid repository URL %q, it must be an http or https URL", app.RepositoryURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("the credentials of the repository must be stored in a secret")
+	}
+	if utils.IsInternalHost(u.Hostname()) {
+		return fmt.Errorf("the repository URL %q is an internal address", app.RepositoryURL)
+	}
+	if !isValidBranchName(app.Branch) {
+		return fmt.Errorf("invalid branch name %q", app.Branch)
+	}
+	if filepath.IsAbs(app.Path) || strings.Contains(app.Path, "..") {
+		return fmt.Errorf("invalid path %q, it must be relative to the root of the repository", app.Path)
+	}
+	switch app.Kind {
+	case GitOpsManifests:
+	case GitOpsHelm:
+		if app.Chart == "" {
+			return fmt.Errorf("the chart of a %s application is required", GitOpsHelm)
+		}
+	default:
+		return fmt.Errorf("invalid kind %q, it must be %s or %s", app.Kind, GitOpsManifests, GitOpsHelm)
+	}
+	return nil
+}
+
+// isValidBranchName checks the branch name like git check-ref-format --branch, the names starting with '-'
+// would be options of git
+func isValidBranchName(branch string) bool {
+	if branch == "" || branch == "@" || branch == "HEAD" || strings.HasPrefix(branch, "-") ||
+		strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".") ||
+		strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.Contains(branch, "@{") {
+		return false
+	}
+	if strings.ContainsAny(branch, " ~^:?*[\\") {
+		return false
+	}
+	for _, r := range branch {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	for _, component := range strings.Split(branch, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// gitOpsCheckoutDir returns the directory of the checkout of the repository of the application
+func gitOpsCheckoutDir(app model.GitOpsAppModel) string {
+	const stateStorePath = "./statestore/_gitops/"
+	return filepath.Join(stateStorePath, strconv.FormatUint(uint64(app.ClusterModelID), 10), app.Name)
+}
+
+// gitOpsRepositoryURL returns the URL of the repository with the basic auth credentials of its secret
+func gitOpsRepositoryURL(commonCluster CommonCluster, app model.GitOpsAppModel) (string, string, error) {
+	if app.SecretID == "" {
+		return app.RepositoryURL, "", nil
+	}
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), app.SecretID)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "error getting the secret of application %s", app.Name)
+	}
+	if item.SecretType != secret.Password {
+		return "", "", errors.Errorf("the secret of application %s must be a %s", app.Name, secret.Password)
+	}
+	u, err := url.Parse(app.RepositoryURL)
+	if err != nil {
+		return "", "", err
+	}
+	u.User = url.UserPassword(item.Values["username"], item.Values["password"])
+	return u.String(), item.Values["password"], nil
+}
+
+// fetchGitOpsRepository checks out the head of the branch of the application, it returns the directory
+// of the checkout and the commit
+func fetchGitOpsRepository(commonCluster CommonCluster, app model.GitOpsAppModel) (string, string, error) {
+	repositoryURL, password, err := gitOpsRepositoryURL(commonCluster, app)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := filepath.Abs(gitOpsCheckoutDir(app))
+	if err != nil {
+		return "", "", err
+	}
+	// the remote URL holds the credentials, it's set on every fetch instead of being kept in the checkout
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", "", err
+		}
+		if _, err := runGit(dir, password, "init"); err != nil {
+			return "", "", err
+		}
+	}
+	// the host is resolved to a public address and git connects to that one, so it can't be rebound to an internal
+	// address after the check, the redirects aren't followed either
+	resolve, err := gitOpsRepositoryResolve(app.RepositoryURL)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := runGit(dir, "", "config", "http.followRedirects", "false"); err != nil {
+		return "", "", err
+	}
+	if _, err := runGit(dir, "", "config", "http.curloptResolve", resolve); err != nil {
+		return "", "", err
+	}
+	if _, err := runGit(dir, password, "fetch", "--depth", "1", "--", repositoryURL, app.Branch); err != nil {
+		return "", "", err
+	}
+	if _, err := runGit(dir, password, "reset", "--hard", "FETCH_HEAD"); err != nil {
+		return "", "", err
+	}
+	revision, err := runGit(dir, password, "rev-parse", "HEAD")
+	return dir, revision, err
+}
+
+// gitOpsRepositoryResolve resolves the host of the repository to a public address, it returns the host:port:address
+// entry of the address for the http.curloptResolve option of git
+func gitOpsRepositoryResolve(repositoryURL string) (string, error) {
+	u, err := url.Parse(repositoryURL)
+	if err != nil {
+		return "", err
+	}
+	ip, err := utils.ResolvePublicHost(u.Hostname())
+	if err != nil {
+		return "", errors.Wrap(err, "error resolving the host of the repository")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	address := ip.String()
+	if ip.To4() == nil {
+		address = "[" + address + "]"
+	}
+	return u.Hostname() + ":" + port + ":" + address, nil
+}
+
+// runGit runs the git command in the directory, the password is masked in the errors
+func runGit(dir, password string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if password != "" {
+			message = strings.Replace(message, password, "***", -1)
+			message = strings.Replace(message, url.QueryEscape(password), "***", -1)
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], message)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitOpsManifests parses the manifests of the path of the checkout, the path is a manifest file or a directory
+// of .yaml, .yml and .json files
+func gitOpsManifests(dir string, app model.GitOpsAppModel) ([]*unstructured.Unstructured, error) {
+	root := filepath.Join(dir, app.Path)
+	files := []string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	objects := []*unstructured.Unstructured{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fileObjects, err := helm.ParseManifests(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", strings.TrimPrefix(file, dir+"/"))
+		}
+		objects = append(objects, fileObjects...)
+	}
+	return objects, nil
+}
+
+// gitOpsValues reads the Helm values of the path of the checkout, the path is a values file or a directory
+// with a values.yaml (the chart defaults are used without it)
+func gitOpsValues(dir string, app model.GitOpsAppModel) (map[string]interface{}, error) {
+	file := filepath.Join(dir, app.Path)
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		file = filepath.Join(file, "values.yaml")
+	}
+	values := map[string]interface{}{}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", app.Path)
+	}
+	return values, nil
+}
+
+//SyncGitOpsApp applies the head of the branch of the application to the cluster: the manifests are created or
+//updated, the chart is installed or upgraded with the values. The synced commit or the error is saved.
+func SyncGitOpsApp(commonCluster CommonCluster, app *model.GitOpsAppModel) error {
+	err := syncGitOpsApp(commonCluster, app)
+	now := time.Now()
+	app.CheckedAt = &now
+	if err == nil {
+		app.SyncStatus, app.Message, app.SyncedAt = GitOpsSynced, "", &now
+		app.SetDrift(nil)
+	} else {
+		app.SyncStatus, app.Message = GitOpsFailed, err.Error()
+	}
+	if saveErr := app.Save(); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func syncGitOpsApp(commonCluster CommonCluster, app *model.GitOpsAppModel) error {
+	dir, revision, err := fetchGitOpsRepository(commonCluster, *app)
+	if err != nil {
+		return err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	if app.Kind == GitOpsManifests {
+		objects, err := gitOpsManifests(dir, *app)
+		if err != nil {
+			return err
+		}
+		if _, err := helm.ApplyManifests(kubeConfig, objects, map[string]string{gitOpsAppLabel: app.Name}); err != nil {
+			return err
+		}
+		app.Revision = revision
+		return nil
+	}
+
+	values, err := gitOpsValues(dir, *app)
+	if err != nil {
+		return err
+	}
+	if err := helm.AddOrganizationRepositories(commonCluster.GetOrg(), commonCluster.GetName()); err != nil {
+		return err
+	}
+	deployment := &model.DeploymentModel{ClusterModelID: commonCluster.GetID(), ReleaseName: app.Name, Chart: app.Chart}
+	deployment.SetValues(values)
+	if _, err := helm.GetDeploymentStatus(app.Name, kubeConfig); err != nil {
+		valueOverrides, err := yaml.Marshal(values)
+		if err != nil {
+			return err
+		}
+		release, err := helm.CreateDeployment(app.Chart, app.Name, valueOverrides, kubeConfig, commonCluster.GetName())
+		if err != nil {
+			return err
+		}
+		deployment.Revision, deployment.Status = release.Release.Version, release.Release.Info.Status.Code.String()
+	} else {
+		upgrade, err := helm.UpgradeDeploymentFromRepo(app.Name, app.Chart, values, kubeConfig, commonCluster.GetName())
+		if err != nil {
+			return err
+		}
+		deployment.Revision, deployment.Status = upgrade.Release.Version, upgrade.Release.Info.Status.Code.String()
+	}
+	if err := deployment.Save(); err != nil {
+		log.Warnf("Error saving the state of deployment %s: %s", app.Name, err.Error())
+	}
+	app.Revision = revision
+	return nil
+}
+
+//CheckGitOpsApp compares the head of the branch of the application with the cluster: the application is out of
+//sync if there's a new commit or the resources or the values of the release were changed on the cluster.
+//Applications with auto sync are synced, except on protected clusters whose deployments need an approval.
+func CheckGitOpsApp(commonCluster CommonCluster, app *model.GitOpsAppModel) error {
+	drift, err := gitOpsDrift(commonCluster, *app)
+	now := time.Now()
+	app.CheckedAt = &now
+	protected := commonCluster.GetModel().Protected
+	switch {
+	case err != nil:
+		app.SyncStatus, app.Message = GitOpsFailed, err.Error()
+	case len(drift) > 0 && app.AutoSync && !protected:
+		return SyncGitOpsApp(commonCluster, app)
+	case len(drift) > 0 && app.AutoSync:
+		app.SyncStatus, app.Message = GitOpsOutOfSync, "auto sync is disabled on protected clusters"
+	case len(drift) > 0:
+		app.SyncStatus, app.Message = GitOpsOutOfSync, ""
+	default:
+		app.SyncStatus, app.Message = GitOpsSynced, ""
+	}
+	app.SetDrift(drift)
+	if saveErr := app.Save(); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// gitOpsDrift returns the differences of the cluster from the head of the branch of the application
+func gitOpsDrift(commonCluster CommonCluster, app model.GitOpsAppModel) ([]string, error) {
+	dir, revision, err := fetchGitOpsRepository(commonCluster, app)
+	if err != nil {
+		return nil, err
+	}
+	drift := []string{}
+	if revision != app.Revision {
+		drift = append(drift, fmt.Sprintf("revision %s isn't synced", revision))
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	if app.Kind == GitOpsManifests {
+		objects, err := gitOpsManifests(dir, app)
+		if err != nil {
+			return nil, err
+		}
+		resourceDrift, err := helm.ManifestDrift(kubeConfig, objects)
+		if err != nil {
+			return nil, err
+		}
+		return append(drift, resourceDrift...), nil
+	}
+
+	values, err := gitOpsValues(dir, app)
+	if err != nil {
+		return nil, err
+	}
+	_, live, err := helm.GetDeploymentValues(app.Name, kubeConfig)
+	if err != nil {
+		return append(drift, fmt.Sprintf("release %s is missing", app.Name)), nil
+	}
+	for _, change := range helm.DiffValues(live, values) {
+		drift = append(drift, fmt.Sprintf("value %s is changed", change.Path))
+	}
+	return drift, nil
+}
+
+//DeleteGitOpsApp stops syncing the application, the resources of the application are kept on the cluster
+func DeleteGitOpsApp(app *model.GitOpsAppModel) error {
+	if err := os.RemoveAll(gitOpsCheckoutDir(*app)); err != nil {
+		return err
+	}
+	return app.Delete()
+}
+
+//RunGitOpsReconciler checks the GitOps applications of every cluster with the given interval, it never returns
+func RunGitOpsReconciler(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"action": "GitOpsReconciler"})
+	for range time.Tick(interval) {
+		apps, err := model.ListGitOpsApps(0)
+		if err != nil {
+			log.Errorf("Error listing the GitOps applications: %s", err.Error())
+			continue
+		}
+		for i := range apps {
+			app := &apps[i]
+			var modelCluster model.ClusterModel
+			if err := model.GetDB().First(&modelCluster, app.ClusterModelID).Error; err != nil {
+				if model.IsErrorGormNotFound(err) {
+					err = DeleteGitOpsApp(app)
+				}
+				if err != nil {
+					log.Warnf("Error loading cluster %d: %s", app.ClusterModelID, err.Error())
+				}
+				continue
+			}
+			commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+			if err != nil {
+				log.Warnf("Error loading cluster %s: %s", modelCluster.Name, err.Error())
+				continue
+			}
+			if ClusterStatus(commonCluster) != StatusRunning {
+				continue
+			}
+			if err := CheckGitOpsApp(commonCluster, app); err != nil {
+				log.Warnf("Error checking application %s of cluster %s: %s", app.Name, modelCluster.Name, err.Error())
+			}
+		}
+	}
+}
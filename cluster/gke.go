@@ -1,7 +1,6 @@
 package cluster
 
 import (
-	"encoding/base64"
 	"fmt"
 	"github.com/banzaicloud/banzai-types/components"
 	bGoogle "github.com/banzaicloud/banzai-types/components/google"
@@ -13,18 +12,14 @@ import (
 	"github.com/gin-gonic/gin/json"
 	"github.com/go-errors/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	gke "google.golang.org/api/container/v1"
 	"google.golang.org/api/googleapi"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
-	"k8s.io/api/core/v1"
-	"k8s.io/api/rbac/v1beta1"
-	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	"net/http"
 	"os"
 	"strings"
@@ -36,9 +31,11 @@ const (
 )
 
 const (
-	defaultNamespace = "default"
-	clusterAdmin     = "cluster-admin"
-	netesDefault     = "netes-default"
+	gkeDefaultNodePool = "default-pool"
+	// the nodes of the regional clusters are in three zones of the region by default
+	gkeRegionalZones = 3
+	// the config is renewed before the token expires
+	gkeTokenExpiryDelta = time.Minute
 )
 
 type ServiceAccount struct {
@@ -80,10 +77,11 @@ func CreateGKEClusterFromRequest(request *components.CreateClusterRequest, orgId
 
 //GKECluster struct for GKE cluster
 type GKECluster struct {
-	googleCluster *gke.Cluster //Don't use this directly
-	modelCluster  *model.ClusterModel
-	k8sConfig     *[]byte
-	APIEndpoint   string
+	googleCluster   *gke.Cluster //Don't use this directly
+	modelCluster    *model.ClusterModel
+	k8sConfig       *[]byte
+	k8sConfigExpiry time.Time
+	APIEndpoint     string
 }
 
 func (g *GKECluster) GetOrg() uint {
@@ -105,7 +103,7 @@ func (g *GKECluster) GetGoogleCluster() (*gke.Cluster, error) {
 	cc := googleCluster{
 		Name:      g.modelCluster.Name,
 		ProjectID: g.modelCluster.Google.Project,
-		Zone:      g.modelCluster.Location,
+		Location:  g.modelCluster.Location,
 	}
 	cluster, err := getClusterGoogle(svc, cc)
 	if err != nil {
@@ -153,18 +151,38 @@ func (g *GKECluster) CreateCluster() error {
 				"https://www.googleapis.com/auth/devstorage.read_write",
 			},
 		},
-		ProjectID:     g.modelCluster.Google.Project,
-		Zone:          g.modelCluster.Location,
-		Name:          g.modelCluster.Name,
-		NodeCount:     int64(g.modelCluster.Google.NodeCount),
-		MasterVersion: g.modelCluster.Google.MasterVersion,
-		NodeVersion:   g.modelCluster.Google.NodeVersion,
-	}
+		ProjectID:      g.modelCluster.Google.Project,
+		Location:       g.modelCluster.Location,
+		Name:           g.modelCluster.Name,
+		NodeCount:      int64(g.modelCluster.Google.NodeCount),
+		MasterVersion:  g.modelCluster.Google.MasterVersion,
+		NodeVersion:    g.modelCluster.Google.NodeVersion,
+		ReleaseChannel: g.modelCluster.Google.ReleaseChannel,
+		// the network of the cluster, an empty network is the default network of the project
+		Network:         g.modelCluster.Network,
+		SubNetwork:      g.modelCluster.Subnets,
+		PrivateEndpoint: g.modelCluster.PrivateEndpoint,
+		MasterCIDR:      g.modelCluster.MasterCIDR,
+		Tags:            g.modelCluster.GetTags(),
+	}
+
+	// the node pools of the request, or the single pool of the node properties
+	if len(g.modelCluster.NodePools) == 0 {
+		g.modelCluster.NodePools = []model.NodePoolModel{{
+			Name:         gkeDefaultNodePool,
+			InstanceType: g.modelCluster.NodeInstanceType,
+			Count:        g.modelCluster.Google.NodeCount,
+		}}
+	}
+	cc.NodePools = g.nodePools(cc.NodeConfig)
 
 	ccr := generateClusterCreateRequest(cc)
 
+	if isGKERegion(cc.Location) {
+		log.Infof("Creating regional cluster, the node counts are per zone of %s", cc.Location)
+	}
 	log.Infof("Cluster request: %v", ccr)
-	createCall, err := svc.Projects.Zones.Clusters.Create(cc.ProjectID, cc.Zone, ccr).Context(context.Background()).Do()
+	createCall, err := svc.createCluster(cc.ProjectID, cc.Location, ccr.Cluster, clusterFields(cc))
 
 	log.Infof("Cluster request submitted: %v", ccr)
 
@@ -173,7 +191,9 @@ func (g *GKECluster) CreateCluster() error {
 		// TODO status code !?
 		return errors.New(be.Message)
 	}
-	log.Infof("Cluster %s create is called for project %s and zone %s. Status Code %v", cc.Name, cc.ProjectID, cc.Zone, createCall.HTTPStatusCode)
+	if createCall != nil {
+		log.Infof("Cluster %s create is called for project %s and location %s. Operation %v", cc.Name, cc.ProjectID, cc.Location, createCall.Name)
+	}
 
 	// save to database before polling
 	if err := g.Persist(); err != nil {
@@ -194,6 +214,52 @@ func (g *GKECluster) CreateCluster() error {
 
 }
 
+// nodePools returns the GKE node pools of the node pools, the nodes have the properties of the node config
+// with the instance type of their pool
+func (g *GKECluster) nodePools(nodeConfig *gke.NodeConfig) []*gke.NodePool {
+	pools := make([]*gke.NodePool, 0, len(g.modelCluster.NodePools))
+	for _, pool := range g.modelCluster.NodePools {
+		pools = append(pools, g.nodePool(pool, nodeConfig))
+	}
+	return pools
+}
+
+// nodePool returns the GKE node pool of the node pool with the instance type and the labels of the pool,
+// the nodes of spot pools are preemptible
+func (g *GKECluster) nodePool(pool model.NodePoolModel, nodeConfig *gke.NodeConfig) *gke.NodePool {
+	config := *nodeConfig
+	config.MachineType = pool.InstanceType
+	config.Labels = g.modelCluster.NodeLabels(pool)
+	config.Preemptible = pool.Spot
+	if key, err := GetSSHKey(g); err != nil {
+		log.Errorf("The SSH key isn't added to node pool %s: %s", pool.Name, err.Error())
+	} else if key != nil {
+		// the metadata of the existing pools may have the previous key
+		config.Metadata = map[string]string{}
+		for name, value := range nodeConfig.Metadata {
+			config.Metadata[name] = value
+		}
+		config.Metadata["ssh-keys"] = key.User + ":" + key.PublicKey + " " + key.User
+	}
+	nodePool := &gke.NodePool{
+		Name:             pool.Name,
+		InitialNodeCount: int64(pool.Count),
+		Config:           &config,
+	}
+	if pool.Autoscaling {
+		nodePool.Autoscaling = &gke.NodePoolAutoscaling{
+			Enabled:      true,
+			MinNodeCount: int64(pool.MinCount),
+			MaxNodeCount: int64(pool.MaxCount),
+		}
+	}
+	if g.modelCluster.Google.ReleaseChannel != "" {
+		// the clusters of a release channel are upgraded by GKE
+		nodePool.Management = &gke.NodeManagement{AutoUpgrade: true, AutoRepair: true}
+	}
+	return nodePool
+}
+
 //Persist save the cluster model
 func (g *GKECluster) Persist() error {
 	log.Infof("Model before save: %v", g.modelCluster)
@@ -203,12 +269,13 @@ func (g *GKECluster) Persist() error {
 //GetK8sConfig returns the Kubernetes config
 func (g *GKECluster) GetK8sConfig() (*[]byte, error) {
 
-	if g.k8sConfig != nil {
+	// the config has a short-lived token, it isn't stored in the database
+	if g.k8sConfig != nil && time.Now().Before(g.k8sConfigExpiry) {
 		return g.k8sConfig, nil
 	}
 	log := logger.WithFields(logrus.Fields{"action": constants.TagFetchClusterConfig})
 
-	config, err := g.getGoogleKubernetesConfig()
+	config, expiry, err := g.getGoogleKubernetesConfig()
 	if err != nil {
 		// something went wrong
 		be := getBanzaiErrorFromError(err)
@@ -219,6 +286,7 @@ func (g *GKECluster) GetK8sConfig() (*[]byte, error) {
 	log.Info("Get k8s config succeeded")
 
 	g.k8sConfig = &config
+	g.k8sConfigExpiry = expiry.Add(-gkeTokenExpiryDelta)
 
 	return &config, nil
 
@@ -249,7 +317,7 @@ func (g *GKECluster) GetStatus() (*components.GetClusterStatusResponse, error) {
 	log.Info("Get Google Service Client success")
 
 	log.Infof("Get google cluster with name %s", g.modelCluster.Name)
-	cl, err := svc.Projects.Zones.Clusters.Get(g.modelCluster.Google.Project, g.modelCluster.Location, g.modelCluster.Name).Context(context.Background()).Do()
+	cl, err := svc.getCluster(g.modelCluster.Google.Project, g.modelCluster.Location, g.modelCluster.Name)
 	if err != nil {
 		apiError := getBanzaiErrorFromError(err)
 		// TODO status code !?
@@ -286,7 +354,7 @@ func (g *GKECluster) DeleteCluster() error {
 	gkec := googleCluster{
 		ProjectID: g.modelCluster.Google.Project,
 		Name:      g.modelCluster.Name,
-		Zone:      g.modelCluster.Location,
+		Location:  g.modelCluster.Location,
 	}
 
 	if err := g.callDeleteCluster(&gkec); err != nil {
@@ -313,7 +381,7 @@ func (g *GKECluster) UpdateCluster(updateRequest *components.UpdateClusterReques
 	cc := googleCluster{
 		Name:          g.modelCluster.Name,
 		ProjectID:     g.modelCluster.Google.Project,
-		Zone:          g.modelCluster.Location,
+		Location:      g.modelCluster.Location,
 		MasterVersion: updateRequest.GoogleMaster.Version,
 		NodeVersion:   updateRequest.GoogleNode.Version,
 		NodeCount:     int64(updateRequest.GoogleNode.Count),
@@ -330,6 +398,14 @@ func (g *GKECluster) UpdateCluster(updateRequest *components.UpdateClusterReques
 
 	// update model to save
 	g.updateModel(res)
+	// the node count is the size of the first node pool
+	if len(res.NodePools) != 0 {
+		for i := range g.modelCluster.NodePools {
+			if g.modelCluster.NodePools[i].Name == res.NodePools[0].Name {
+				g.modelCluster.NodePools[i].Count = updateRequest.GoogleNode.Count
+			}
+		}
+	}
 
 	return nil
 
@@ -354,7 +430,7 @@ func (g *GKECluster) GetModel() *model.ClusterModel {
 	return g.modelCluster
 }
 
-func (g *GKECluster) getGoogleServiceClient() (*gke.Service, error) {
+func (g *GKECluster) getGoogleServiceClient() (*gkeService, error) {
 
 	// Get Secret from Vault
 	clusterSecret, err := GetSecret(g)
@@ -392,25 +468,20 @@ func (g *GKECluster) getGoogleServiceClient() (*gke.Service, error) {
 		return nil, err
 	}
 
-	// Create oauth2 client with credential
-	//fmt.Printf("%#v", config.)
-	client := config.Client(context.TODO())
-	//client := oauth2.NewClient(context.Background(), googleCredentials.TokenSource)
-
-	//New client from credentials
-	service, err := gke.New(client)
-	if err != nil {
-		return nil, err
-	}
-	return service, nil
+	// Create oauth2 client with credential, the tokens of the client are in the kubeconfig as well
+	tokenSource := config.TokenSource(context.Background())
+	return &gkeService{
+		client:      oauth2.NewClient(context.Background(), tokenSource),
+		tokenSource: tokenSource,
+	}, nil
 }
 
 // GKE cluster to google calls
 type googleCluster struct {
 	// ProjectID is the ID of your project to use when creating a cluster
 	ProjectID string `json:"projectId,omitempty"`
-	// The zone to launch the cluster
-	Zone string
+	// The zone or region to launch the cluster, the clusters in a region are regional
+	Location string
 	// The IP address range of the container pods
 	ClusterIpv4Cidr string
 	// An optional description of this cluster
@@ -453,8 +524,18 @@ type googleCluster struct {
 	LegacyAbac bool
 	// NodePool id
 	NodePoolID string
+	// The node pools of the cluster
+	NodePools []*gke.NodePool
+	// The release channel of the cluster, empty if the cluster isn't enrolled
+	ReleaseChannel string
 	// Image Type
 	ImageType string
+	// The API server of the private clusters is only reachable from the network
+	PrivateEndpoint bool
+	// The range of the control plane of the private cluster
+	MasterCIDR string
+	// The labels of the cluster resources
+	Tags map[string]string
 }
 
 func generateClusterCreateRequest(cc googleCluster) *gke.CreateClusterRequest {
@@ -462,9 +543,7 @@ func generateClusterCreateRequest(cc googleCluster) *gke.CreateClusterRequest {
 		Cluster: &gke.Cluster{},
 	}
 	request.Cluster.Name = cc.Name
-	request.Cluster.Zone = cc.Zone
 	request.Cluster.InitialClusterVersion = cc.MasterVersion
-	request.Cluster.InitialNodeCount = cc.NodeCount
 	request.Cluster.ClusterIpv4Cidr = cc.ClusterIpv4Cidr
 	request.Cluster.Description = cc.Description
 	request.Cluster.EnableKubernetesAlpha = cc.EnableAlphaFeature
@@ -476,14 +555,54 @@ func generateClusterCreateRequest(cc googleCluster) *gke.CreateClusterRequest {
 	}
 	request.Cluster.Network = cc.Network
 	request.Cluster.Subnetwork = cc.SubNetwork
+	request.Cluster.ResourceLabels = cc.Tags
 	request.Cluster.LegacyAbac = &gke.LegacyAbac{
 		Enabled: true,
 	}
-	request.Cluster.MasterAuth = &gke.MasterAuth{}
-	request.Cluster.NodeConfig = cc.NodeConfig
+	// no basic auth and client certificate, the kubeconfig has short-lived tokens
+	request.Cluster.MasterAuth = &gke.MasterAuth{
+		ClientCertificateConfig: &gke.ClientCertificateConfig{
+			IssueClientCertificate: false,
+			ForceSendFields:        []string{"IssueClientCertificate"},
+		},
+		ForceSendFields: []string{"Username"},
+	}
+	// the private clusters need alias IPs, Pipeline is an authorized network of their control plane
+	if cc.PrivateEndpoint {
+		request.Cluster.IpAllocationPolicy = &gke.IPAllocationPolicy{UseIpAliases: true}
+		request.Cluster.MasterAuthorizedNetworksConfig = &gke.MasterAuthorizedNetworksConfig{Enabled: true}
+		for _, cidr := range viper.GetStringSlice("network.pipelineCIDRs") {
+			request.Cluster.MasterAuthorizedNetworksConfig.CidrBlocks = append(request.Cluster.MasterAuthorizedNetworksConfig.CidrBlocks, &gke.CidrBlock{
+				CidrBlock:   cidr,
+				DisplayName: "pipeline",
+			})
+		}
+	}
+	if len(cc.NodePools) != 0 {
+		request.Cluster.NodePools = cc.NodePools
+	} else {
+		request.Cluster.InitialNodeCount = cc.NodeCount
+		request.Cluster.NodeConfig = cc.NodeConfig
+	}
 	return &request
 }
 
+// clusterFields returns the fields of the cluster which the client type doesn't have
+func clusterFields(cc googleCluster) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if cc.ReleaseChannel != "" {
+		fields["releaseChannel"] = map[string]string{"channel": cc.ReleaseChannel}
+	}
+	if cc.PrivateEndpoint {
+		fields["privateClusterConfig"] = map[string]interface{}{
+			"enablePrivateNodes":    true,
+			"enablePrivateEndpoint": true,
+			"masterIpv4CidrBlock":   cc.MasterCIDR,
+		}
+	}
+	return fields
+}
+
 func getBanzaiErrorFromError(err error) *components.BanzaiResponse {
 
 	if err == nil {
@@ -508,7 +627,7 @@ func getBanzaiErrorFromError(err error) *components.BanzaiResponse {
 		Message:    err.Error(),
 	}
 }
-func waitForCluster(svc *gke.Service, cc googleCluster) (*gke.Cluster, error) {
+func waitForCluster(svc *gkeService, cc googleCluster) (*gke.Cluster, error) {
 
 	var message string
 	for {
@@ -534,8 +653,8 @@ func waitForCluster(svc *gke.Service, cc googleCluster) (*gke.Cluster, error) {
 	}
 }
 
-func getClusterGoogle(svc *gke.Service, cc googleCluster) (*gke.Cluster, error) {
-	return svc.Projects.Zones.Clusters.Get(cc.ProjectID, cc.Zone, cc.Name).Context(context.TODO()).Do()
+func getClusterGoogle(svc *gkeService, cc googleCluster) (*gke.Cluster, error) {
+	return svc.getCluster(cc.ProjectID, cc.Location, cc.Name)
 }
 
 func (g *GKECluster) callDeleteCluster(cc *googleCluster) error {
@@ -545,12 +664,12 @@ func (g *GKECluster) callDeleteCluster(cc *googleCluster) error {
 	}
 	log.Info("Get Google Service Client succeeded")
 
-	log.Infof("Removing cluster %v from project %v, zone %v", cc.Name, cc.ProjectID, cc.Zone)
-	deleteCall, err := svc.Projects.Zones.Clusters.Delete(cc.ProjectID, cc.Zone, cc.Name).Context(context.Background()).Do()
+	log.Infof("Removing cluster %v from project %v, location %v", cc.Name, cc.ProjectID, cc.Location)
+	deleteCall, err := svc.deleteCluster(cc.ProjectID, cc.Location, cc.Name)
 	if err != nil && !strings.Contains(err.Error(), "notFound") {
 		return err
 	} else if err == nil {
-		log.Infof("Cluster %v delete is called. Status Code %v", cc.Name, deleteCall.HTTPStatusCode)
+		log.Infof("Cluster %v delete is called. Operation %v", cc.Name, deleteCall.Name)
 	} else {
 		log.Errorf("Cluster %s doesn't exist", cc.Name)
 		return err
@@ -559,7 +678,7 @@ func (g *GKECluster) callDeleteCluster(cc *googleCluster) error {
 	return nil
 }
 
-func callUpdateClusterGoogle(svc *gke.Service, cc googleCluster) (*gke.Cluster, error) {
+func callUpdateClusterGoogle(svc *gkeService, cc googleCluster) (*gke.Cluster, error) {
 
 	var updatedCluster *gke.Cluster
 
@@ -576,15 +695,13 @@ func callUpdateClusterGoogle(svc *gke.Service, cc googleCluster) (*gke.Cluster,
 
 	if cc.MasterVersion != "" {
 		log.Infof("Updating master to %v version", cc.MasterVersion)
-		updateCall, err := svc.Projects.Zones.Clusters.Update(cc.ProjectID, cc.Zone, cc.Name, &gke.UpdateClusterRequest{
-			Update: &gke.ClusterUpdate{
-				DesiredMasterVersion: cc.MasterVersion,
-			},
-		}).Context(context.Background()).Do()
+		updateCall, err := svc.updateCluster(cc.ProjectID, cc.Location, cc.Name, &gke.ClusterUpdate{
+			DesiredMasterVersion: cc.MasterVersion,
+		})
 		if err != nil {
 			return nil, err
 		}
-		log.Infof("Cluster %s update is called for project %s and zone %s. Status Code %v", cc.Name, cc.ProjectID, cc.Zone, updateCall.HTTPStatusCode)
+		log.Infof("Cluster %s update is called for project %s and location %s. Operation %v", cc.Name, cc.ProjectID, cc.Location, updateCall.Name)
 		if updatedCluster, err = waitForCluster(svc, cc); err != nil {
 			return nil, err
 		}
@@ -592,13 +709,13 @@ func callUpdateClusterGoogle(svc *gke.Service, cc googleCluster) (*gke.Cluster,
 
 	if cc.NodeVersion != "" {
 		log.Infof("Updating node to %v version", cc.NodeVersion)
-		updateCall, err := svc.Projects.Zones.Clusters.NodePools.Update(cc.ProjectID, cc.Zone, cc.Name, cc.NodePoolID, &gke.UpdateNodePoolRequest{
+		updateCall, err := svc.updateNodePool(cc.ProjectID, cc.Location, cc.Name, cc.NodePoolID, &gke.UpdateNodePoolRequest{
 			NodeVersion: cc.NodeVersion,
-		}).Context(context.Background()).Do()
+		})
 		if err != nil {
 			return nil, err
 		}
-		log.Infof("Nodepool %s update is called for project %s, zone %s and cluster %s. Status Code %v", cc.NodePoolID, cc.ProjectID, cc.Zone, cc.Name, updateCall.HTTPStatusCode)
+		log.Infof("Nodepool %s update is called for project %s, location %s and cluster %s. Operation %v", cc.NodePoolID, cc.ProjectID, cc.Location, cc.Name, updateCall.Name)
 		if err := waitForNodePool(svc, &cc); err != nil {
 			return nil, err
 		}
@@ -606,13 +723,13 @@ func callUpdateClusterGoogle(svc *gke.Service, cc googleCluster) (*gke.Cluster,
 
 	if cc.NodeCount != 0 {
 		log.Infof("Updating node size to %v", cc.NodeCount)
-		updateCall, err := svc.Projects.Zones.Clusters.NodePools.SetSize(cc.ProjectID, cc.Zone, cc.Name, cc.NodePoolID, &gke.SetNodePoolSizeRequest{
+		updateCall, err := svc.setNodePoolSize(cc.ProjectID, cc.Location, cc.Name, cc.NodePoolID, &gke.SetNodePoolSizeRequest{
 			NodeCount: cc.NodeCount,
-		}).Context(context.Background()).Do()
+		})
 		if err != nil {
 			return nil, err
 		}
-		log.Infof("Nodepool %s size change is called for project %s, zone %s and cluster %s. Status Code %v", cc.NodePoolID, cc.ProjectID, cc.Zone, cc.Name, updateCall.HTTPStatusCode)
+		log.Infof("Nodepool %s size change is called for project %s, location %s and cluster %s. Operation %v", cc.NodePoolID, cc.ProjectID, cc.Location, cc.Name, updateCall.Name)
 		if updatedCluster, err = waitForCluster(svc, cc); err != nil {
 			return nil, err
 		}
@@ -620,10 +737,10 @@ func callUpdateClusterGoogle(svc *gke.Service, cc googleCluster) (*gke.Cluster,
 	return updatedCluster, nil
 }
 
-func waitForNodePool(svc *gke.Service, cc *googleCluster) error {
+func waitForNodePool(svc *gkeService, cc *googleCluster) error {
 	var message string
 	for {
-		nodepool, err := svc.Projects.Zones.Clusters.NodePools.Get(cc.ProjectID, cc.Zone, cc.Name, cc.NodePoolID).Context(context.TODO()).Do()
+		nodepool, err := svc.getNodePool(cc.ProjectID, cc.Location, cc.Name, cc.NodePoolID)
 		if err != nil {
 			return err
 		}
@@ -639,12 +756,14 @@ func waitForNodePool(svc *gke.Service, cc *googleCluster) error {
 	}
 }
 
-func (g *GKECluster) getGoogleKubernetesConfig() ([]byte, error) {
+// getGoogleKubernetesConfig returns the kubeconfig of the cluster with a short-lived OAuth access token
+// of the service account (instead of client certificates and service account tokens) and the expiry of the token
+func (g *GKECluster) getGoogleKubernetesConfig() ([]byte, time.Time, error) {
 
 	log.Info("Get Google Service Client")
 	svc, err := g.getGoogleServiceClient()
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 	log.Info("Get Google Service Client succeeded")
 
@@ -652,36 +771,33 @@ func (g *GKECluster) getGoogleKubernetesConfig() ([]byte, error) {
 	cl, err := getClusterGoogle(svc, googleCluster{
 		Name:      g.modelCluster.Name,
 		ProjectID: g.modelCluster.Google.Project,
-		Zone:      g.modelCluster.Location,
+		Location:  g.modelCluster.Location,
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
-	log.Info("Generate Service Account token")
-	serviceAccountToken, err := generateServiceAccountTokenForGke(cl)
+	log.Info("Get access token")
+	token, err := svc.token()
 	if err != nil {
-		be := getBanzaiErrorFromError(err)
-		// TODO status code !?
-		return nil, errors.New(be.Message)
+		return nil, time.Time{}, err
 	}
 
 	finalCl := kubernetesCluster{
-		ClientCertificate:   cl.MasterAuth.ClientCertificate,
-		ClientKey:           cl.MasterAuth.ClientKey,
+		Name:                g.modelCluster.Name,
 		RootCACert:          cl.MasterAuth.ClusterCaCertificate,
-		Username:            cl.MasterAuth.Username,
-		Password:            cl.MasterAuth.Password,
 		Version:             cl.CurrentMasterVersion,
 		Endpoint:            cl.Endpoint,
 		NodeCount:           cl.CurrentNodeCount,
 		Metadata:            map[string]string{},
-		ServiceAccountToken: serviceAccountToken,
+		ServiceAccountToken: token.AccessToken,
 		Status:              cl.Status,
 	}
 
-	finalCl.Metadata["nodePool"] = cl.NodePools[0].Name
+	if len(cl.NodePools) != 0 {
+		finalCl.Metadata["nodePool"] = cl.NodePools[0].Name
+	}
 
 	// TODO if the final solution is NOT SAVE CONFIG TO FILE than rename the method and change log message
 	log.Info("Start save config file")
@@ -689,119 +805,9 @@ func (g *GKECluster) getGoogleKubernetesConfig() ([]byte, error) {
 	if err != nil {
 		be := getBanzaiErrorFromError(err)
 		// TODO status code !?
-		return nil, errors.New(be.Message)
+		return nil, time.Time{}, errors.New(be.Message)
 	}
-	return config, nil
-}
-
-func generateServiceAccountTokenForGke(cluster *gke.Cluster) (string, error) {
-	capem, err := base64.StdEncoding.DecodeString(cluster.MasterAuth.ClusterCaCertificate)
-	if err != nil {
-		return "", err
-	}
-	certData, err := base64.StdEncoding.DecodeString(cluster.MasterAuth.ClientCertificate)
-	if err != nil {
-		return "", err
-	}
-	keyData, err := base64.StdEncoding.DecodeString(cluster.MasterAuth.ClientKey)
-	if err != nil {
-		return "", err
-	}
-	host := cluster.Endpoint
-	if !strings.HasPrefix(host, "https://") {
-		host = fmt.Sprintf("https://%s", host)
-	}
-
-	// in here we have to use http basic auth otherwise we can't get the permission to create cluster role
-	config := &rest.Config{
-		Host: host,
-		TLSClientConfig: rest.TLSClientConfig{
-			CAData:   capem,
-			CertData: certData,
-			KeyData:  keyData,
-		},
-	}
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return "", err
-	}
-
-	return generateServiceAccountToken(clientset)
-}
-
-func generateServiceAccountToken(clientset *kubernetes.Clientset) (string, error) {
-	serviceAccount := &v1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: netesDefault,
-		},
-	}
-
-	_, err := clientset.CoreV1().ServiceAccounts(defaultNamespace).Create(serviceAccount)
-	if err != nil && !k8sErrors.IsAlreadyExists(err) {
-		return "", err
-	}
-
-	adminRole := &v1beta1.ClusterRole{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: clusterAdmin,
-		},
-		Rules: []v1beta1.PolicyRule{
-			{
-				APIGroups: []string{"*"},
-				Resources: []string{"*"},
-				Verbs:     []string{"*"},
-			},
-			{
-				NonResourceURLs: []string{"*"},
-				Verbs:           []string{"*"},
-			},
-		},
-	}
-	clusterAdminRole, err := clientset.RbacV1beta1().ClusterRoles().Get(clusterAdmin, metav1.GetOptions{})
-	if err != nil {
-		clusterAdminRole, err = clientset.RbacV1beta1().ClusterRoles().Create(adminRole)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	clusterRoleBinding := &v1beta1.ClusterRoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "netes-default-clusterRoleBinding",
-		},
-		Subjects: []v1beta1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				Name:      serviceAccount.Name,
-				Namespace: "default",
-				APIGroup:  v1.GroupName,
-			},
-		},
-		RoleRef: v1beta1.RoleRef{
-			Kind:     "ClusterRole",
-			Name:     clusterAdminRole.Name,
-			APIGroup: v1beta1.GroupName,
-		},
-	}
-	if _, err = clientset.RbacV1beta1().ClusterRoleBindings().Create(clusterRoleBinding); err != nil && !k8sErrors.IsAlreadyExists(err) {
-		return "", err
-	}
-
-	if serviceAccount, err = clientset.CoreV1().ServiceAccounts(defaultNamespace).Get(serviceAccount.Name, metav1.GetOptions{}); err != nil {
-		return "", err
-	}
-
-	if len(serviceAccount.Secrets) > 0 {
-		secret := serviceAccount.Secrets[0]
-		secretObj, err := clientset.CoreV1().Secrets(defaultNamespace).Get(secret.Name, metav1.GetOptions{})
-		if err != nil {
-			return "", err
-		}
-		if token, ok := secretObj.Data["token"]; ok {
-			return string(token), nil
-		}
-	}
-	return "", fmt.Errorf("failed to configure serviceAccountToken")
+	return config, token.Expiry, nil
 }
 
 // storeConfig saves config file
@@ -1105,7 +1111,7 @@ func (g *GKECluster) GetGkeServerConfig(c *gin.Context) {
 			Error:   apiErr.Message,
 		})
 	} else {
-		if serverConfig, err := svc.Projects.Zones.GetServerconfig(projectId, zone).Context(context.Background()).Do(); err != nil {
+		if serverConfig, err := svc.getServerConfig(projectId, zone); err != nil {
 			apiErr := getBanzaiErrorFromError(err)
 			log.Errorf("Error during getting server config: %s", apiErr.Message)
 			c.JSON(apiErr.StatusCode, components.ErrorResponse{
@@ -1145,3 +1151,209 @@ func convertServerConfig(config *gke.ServerConfig) *GetServerConfigResponse {
 		ValidNodeVersions:     config.ValidNodeVersions,
 	}
 }
+
+// nodePoolCluster returns the GKE call properties of the node pool
+func (g *GKECluster) nodePoolCluster(name string) *googleCluster {
+	return &googleCluster{
+		Name:       g.modelCluster.Name,
+		ProjectID:  g.modelCluster.Google.Project,
+		Location:   g.modelCluster.Location,
+		NodePoolID: name,
+	}
+}
+
+//CreateNodePool creates the node pool with the node config of the first node pool of the cluster
+func (g *GKECluster) CreateNodePool(pool model.NodePoolModel) error {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return err
+	}
+	cc := g.nodePoolCluster(pool.Name)
+	cluster, err := getClusterGoogle(svc, *cc)
+	if err != nil {
+		return err
+	}
+	if len(cluster.NodePools) == 0 || cluster.NodePools[0].Config == nil {
+		return fmt.Errorf("cluster %s has no node config", cc.Name)
+	}
+	operation, err := svc.createNodePool(cc.ProjectID, cc.Location, cc.Name, g.nodePool(pool, cluster.NodePools[0].Config))
+	if err != nil {
+		return err
+	}
+	log.Infof("Nodepool %s create is called for cluster %s. Operation %v", pool.Name, cc.Name, operation.Name)
+	return waitForNodePool(svc, cc)
+}
+
+//UpdateNodePool sets the autoscaling, the size and the labels of the node pool
+func (g *GKECluster) UpdateNodePool(previous, pool model.NodePoolModel) error {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return err
+	}
+	cc := g.nodePoolCluster(pool.Name)
+	if previous.Autoscaling != pool.Autoscaling || previous.MinCount != pool.MinCount || previous.MaxCount != pool.MaxCount {
+		autoscaling := &gke.NodePoolAutoscaling{
+			Enabled:      pool.Autoscaling,
+			MinNodeCount: int64(pool.MinCount),
+			MaxNodeCount: int64(pool.MaxCount),
+		}
+		if _, err := svc.setNodePoolAutoscaling(cc.ProjectID, cc.Location, cc.Name, pool.Name, autoscaling); err != nil {
+			return err
+		}
+		if err := waitForNodePool(svc, cc); err != nil {
+			return err
+		}
+	}
+	if previous.Count != pool.Count {
+		_, err := svc.setNodePoolSize(cc.ProjectID, cc.Location, cc.Name, pool.Name, &gke.SetNodePoolSizeRequest{
+			NodeCount: int64(pool.Count),
+		})
+		if err != nil {
+			return err
+		}
+		if err := waitForNodePool(svc, cc); err != nil {
+			return err
+		}
+	}
+	if previous.Labels != pool.Labels {
+		nodePool, err := svc.getNodePool(cc.ProjectID, cc.Location, cc.Name, pool.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := svc.setNodePoolLabels(cc.ProjectID, cc.Location, cc.Name, nodePool, g.modelCluster.NodeLabels(pool)); err != nil {
+			return err
+		}
+		if err := waitForNodePool(svc, cc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//DeleteNodePool deletes the node pool and waits until it's removed
+func (g *GKECluster) DeleteNodePool(pool model.NodePoolModel) error {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return err
+	}
+	cc := g.nodePoolCluster(pool.Name)
+	operation, err := svc.deleteNodePool(cc.ProjectID, cc.Location, cc.Name, pool.Name)
+	if err != nil {
+		return err
+	}
+	log.Infof("Nodepool %s delete is called for cluster %s. Operation %v", pool.Name, cc.Name, operation.Name)
+	for {
+		_, err := svc.getNodePool(cc.ProjectID, cc.Location, cc.Name, pool.Name)
+		if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		time.Sleep(time.Second * 5)
+	}
+}
+
+//NodePoolSelector returns the label selector of the nodes of the node pool
+func (g *GKECluster) NodePoolSelector(name string) string {
+	return "cloud.google.com/gke-nodepool=" + name
+}
+
+//CheckNodeQuota checks the regional CPU quota of the project and the machine type in a zone of the cluster, the nodes
+//of regional clusters are in every zone of the cluster
+func (g *GKECluster) CheckNodeQuota(instanceType string, nodes int) error {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return err
+	}
+	cc := g.nodePoolCluster("")
+	region, zone := cc.Location, cc.Location
+	if isGKERegion(cc.Location) && g.modelCluster.ID == 0 {
+		// the cluster isn't created yet, its nodes will be in the default zones of the region
+		nodes *= gkeRegionalZones
+		zone = ""
+	} else if isGKERegion(cc.Location) {
+		cluster, err := getClusterGoogle(svc, *cc)
+		if err != nil {
+			return err
+		}
+		if len(cluster.Locations) != 0 {
+			nodes *= len(cluster.Locations)
+			zone = cluster.Locations[0]
+		}
+	} else {
+		region = cc.Location[:strings.LastIndex(cc.Location, "-")]
+	}
+	return svc.checkCPUQuota(cc.ProjectID, region, zone, instanceType, nodes)
+}
+
+//InstancePrice returns the hourly price of the machine type in the region of the cluster from the Cloud Billing
+//Catalog API, the price of preemptible instances for spot pools
+func (g *GKECluster) InstancePrice(instanceType string, spot bool) (float64, error) {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return 0, err
+	}
+	location := g.modelCluster.Location
+	region, zone := location, ""
+	if !isGKERegion(location) {
+		region, zone = location[:strings.LastIndex(location, "-")], location
+	}
+	return svc.gcpInstancePrice(g.modelCluster.Google.Project, region, zone, instanceType, spot)
+}
+
+//KubernetesVersion returns the current version of the master
+func (g *GKECluster) KubernetesVersion() (string, error) {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return "", err
+	}
+	cluster, err := getClusterGoogle(svc, *g.nodePoolCluster(""))
+	if err != nil {
+		return "", err
+	}
+	return cluster.CurrentMasterVersion, nil
+}
+
+//UpgradeControlPlane upgrades the master of the cluster
+func (g *GKECluster) UpgradeControlPlane(version string) error {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return err
+	}
+	cc := g.nodePoolCluster("")
+	operation, err := svc.updateCluster(cc.ProjectID, cc.Location, cc.Name, &gke.ClusterUpdate{DesiredMasterVersion: version})
+	if err != nil {
+		return err
+	}
+	log.Infof("Master upgrade is called for cluster %s. Operation %v", cc.Name, operation.Name)
+	cluster, err := waitForCluster(svc, *cc)
+	if err != nil {
+		return err
+	}
+	g.modelCluster.Google.MasterVersion = cluster.CurrentMasterVersion
+	return nil
+}
+
+//UpgradeNodePool upgrades the nodes of the node pool, GKE creates maxSurge extra nodes during the upgrade
+func (g *GKECluster) UpgradeNodePool(pool model.NodePoolModel, version string, maxSurge, maxUnavailable int) error {
+	svc, err := g.getGoogleServiceClient()
+	if err != nil {
+		return err
+	}
+	cc := g.nodePoolCluster(pool.Name)
+	nodePool, err := svc.getNodePool(cc.ProjectID, cc.Location, cc.Name, pool.Name)
+	if err != nil {
+		return err
+	}
+	operation, err := svc.upgradeNodePool(cc.ProjectID, cc.Location, cc.Name, nodePool, version, maxSurge, maxUnavailable)
+	if err != nil {
+		return err
+	}
+	log.Infof("Nodepool %s upgrade is called for cluster %s. Operation %v", pool.Name, cc.Name, operation.Name)
+	if err := waitForNodePool(svc, cc); err != nil {
+		return err
+	}
+	g.modelCluster.Google.NodeVersion = version
+	return nil
+}
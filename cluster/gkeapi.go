@@ -0,0 +1,252 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	compute "google.golang.org/api/compute/v1"
+	gke "google.golang.org/api/container/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const gkeBasePath = "https://container.googleapis.com/v1"
+
+// GKE release channels
+const (
+	gkeReleaseChannelRapid   = "RAPID"
+	gkeReleaseChannelRegular = "REGULAR"
+	gkeReleaseChannelStable  = "STABLE"
+)
+
+// gkeRegion is the format of the GCP regions, zones have a suffix like europe-west1-b
+var gkeRegion = regexp.MustCompile("^[a-z]+-[a-z]+[0-9]+$")
+
+// isGKERegion checks whether the location is a region, the clusters in a region are regional clusters
+func isGKERegion(location string) bool {
+	return gkeRegion.MatchString(location)
+}
+
+// gkeService calls the locations API of GKE, the zones API of the vendored client knows neither
+// regional clusters nor release channels; the requests and responses are the types of the client
+type gkeService struct {
+	client      *http.Client
+	tokenSource oauth2.TokenSource
+}
+
+// do sends the request to GKE and decodes the response into result (if it isn't nil),
+// the errors are *googleapi.Error like the errors of the client
+func (s *gkeService) do(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, gkeBasePath+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req.WithContext(context.Background()))
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(resp)
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func gkeClusterPath(projectID, location, name string) string {
+	return fmt.Sprintf("/projects/%s/locations/%s/clusters/%s", projectID, location, name)
+}
+
+func gkeNodePoolPath(projectID, location, name, nodePool string) string {
+	return fmt.Sprintf("%s/nodePools/%s", gkeClusterPath(projectID, location, name), nodePool)
+}
+
+// createCluster creates the cluster in the zone or region, the fields are added to the fields of the client type
+func (s *gkeService) createCluster(projectID, location string, cluster *gke.Cluster, fields map[string]interface{}) (*gke.Operation, error) {
+	// the client type doesn't have the release channel and the private cluster fields
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		body[name] = value
+	}
+	var operation gke.Operation
+	path := fmt.Sprintf("/projects/%s/locations/%s/clusters", projectID, location)
+	if err := s.do(http.MethodPost, path, map[string]interface{}{"cluster": body}, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) getCluster(projectID, location, name string) (*gke.Cluster, error) {
+	var cluster gke.Cluster
+	if err := s.do(http.MethodGet, gkeClusterPath(projectID, location, name), nil, &cluster); err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+func (s *gkeService) deleteCluster(projectID, location, name string) (*gke.Operation, error) {
+	var operation gke.Operation
+	if err := s.do(http.MethodDelete, gkeClusterPath(projectID, location, name), nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) updateCluster(projectID, location, name string, update *gke.ClusterUpdate) (*gke.Operation, error) {
+	var operation gke.Operation
+	if err := s.do(http.MethodPut, gkeClusterPath(projectID, location, name), &gke.UpdateClusterRequest{Update: update}, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) getNodePool(projectID, location, name, nodePool string) (*gke.NodePool, error) {
+	var pool gke.NodePool
+	if err := s.do(http.MethodGet, gkeNodePoolPath(projectID, location, name, nodePool), nil, &pool); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func (s *gkeService) updateNodePool(projectID, location, name, nodePool string, request *gke.UpdateNodePoolRequest) (*gke.Operation, error) {
+	var operation gke.Operation
+	if err := s.do(http.MethodPut, gkeNodePoolPath(projectID, location, name, nodePool), request, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) setNodePoolSize(projectID, location, name, nodePool string, request *gke.SetNodePoolSizeRequest) (*gke.Operation, error) {
+	var operation gke.Operation
+	if err := s.do(http.MethodPost, gkeNodePoolPath(projectID, location, name, nodePool)+":setSize", request, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) createNodePool(projectID, location, name string, nodePool *gke.NodePool) (*gke.Operation, error) {
+	var operation gke.Operation
+	path := gkeClusterPath(projectID, location, name) + "/nodePools"
+	if err := s.do(http.MethodPost, path, &gke.CreateNodePoolRequest{NodePool: nodePool}, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) deleteNodePool(projectID, location, name, nodePool string) (*gke.Operation, error) {
+	var operation gke.Operation
+	if err := s.do(http.MethodDelete, gkeNodePoolPath(projectID, location, name, nodePool), nil, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+func (s *gkeService) setNodePoolAutoscaling(projectID, location, name, nodePool string, autoscaling *gke.NodePoolAutoscaling) (*gke.Operation, error) {
+	var operation gke.Operation
+	body := &gke.SetNodePoolAutoscalingRequest{Autoscaling: autoscaling}
+	if err := s.do(http.MethodPost, gkeNodePoolPath(projectID, location, name, nodePool)+":setAutoscaling", body, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// setNodePoolLabels replaces the labels of the nodes of the node pool, the update request of the
+// client doesn't have the labels and GKE needs the current version and image type of the nodes
+func (s *gkeService) setNodePoolLabels(projectID, location, name string, nodePool *gke.NodePool, labels map[string]string) (*gke.Operation, error) {
+	body := map[string]interface{}{
+		"nodeVersion": nodePool.Version,
+		"labels":      map[string]interface{}{"labels": labels},
+	}
+	if nodePool.Config != nil {
+		body["imageType"] = nodePool.Config.ImageType
+	}
+	var operation gke.Operation
+	if err := s.do(http.MethodPut, gkeNodePoolPath(projectID, location, name, nodePool.Name), body, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// upgradeNodePool upgrades the nodes of the node pool to the version, the update request of the client
+// doesn't have the surge settings
+func (s *gkeService) upgradeNodePool(projectID, location, name string, nodePool *gke.NodePool, version string, maxSurge, maxUnavailable int) (*gke.Operation, error) {
+	body := map[string]interface{}{"nodeVersion": version}
+	if nodePool.Config != nil {
+		body["imageType"] = nodePool.Config.ImageType
+	}
+	if maxSurge != 0 || maxUnavailable != 0 {
+		body["upgradeSettings"] = map[string]interface{}{"maxSurge": maxSurge, "maxUnavailable": maxUnavailable}
+	}
+	var operation gke.Operation
+	if err := s.do(http.MethodPut, gkeNodePoolPath(projectID, location, name, nodePool.Name), body, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// checkCPUQuota checks whether the CPU quota of the region allows the new nodes of the machine type of the zone,
+// the first zone of the region is checked without a zone
+func (s *gkeService) checkCPUQuota(projectID, region, zone, machineType string, nodes int) error {
+	computeService, err := compute.New(s.client)
+	if err != nil {
+		return err
+	}
+	computeRegion, err := computeService.Regions.Get(projectID, region).Do()
+	if err != nil {
+		return err
+	}
+	// the zones of the region are URLs
+	if zone == "" && len(computeRegion.Zones) != 0 {
+		zone = path.Base(computeRegion.Zones[0])
+	}
+	machine, err := computeService.MachineTypes.Get(projectID, zone, machineType).Do()
+	if err != nil {
+		return fmt.Errorf("machine type %s is not available in %s: %s", machineType, zone, err.Error())
+	}
+	cpus := float64(machine.GuestCpus * int64(nodes))
+	for _, quota := range computeRegion.Quotas {
+		if quota.Metric == "CPUS" && quota.Usage+cpus > quota.Limit {
+			return fmt.Errorf("%d %s nodes exceed the CPU quota of %s, %.0f of %.0f CPUs are used",
+				nodes, machineType, region, quota.Usage, quota.Limit)
+		}
+	}
+	return nil
+}
+
+func (s *gkeService) getServerConfig(projectID, location string) (*gke.ServerConfig, error) {
+	var config gke.ServerConfig
+	if err := s.do(http.MethodGet, fmt.Sprintf("/projects/%s/locations/%s/serverConfig", projectID, location), nil, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// token returns a short-lived OAuth access token of the service account, GKE accepts it from the users
+// with the permissions of the service account
+func (s *gkeService) token() (*oauth2.Token, error) {
+	return s.tokenSource.Token()
+}
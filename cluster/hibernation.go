@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Hibernation actions of the schedules
+const (
+	HibernationSuspend = "suspend"
+	HibernationResume  = "resume"
+)
+
+//Hibernator is implemented by the clusters which are stopped in the cloud when they're suspended,
+//the node pools of the other clusters are scaled to zero
+type Hibernator interface {
+	// Suspend stops the cluster and waits until it's stopped
+	Suspend() error
+	// Resume starts the stopped cluster and waits for its nodes
+	Resume() error
+}
+
+//ValidateHibernationSchedule checks the cron expressions and the time zone of the schedule
+func ValidateHibernationSchedule(schedule model.HibernationScheduleModel) error {
+	if _, err := parseCron(schedule.Suspend); err != nil {
+		return fmt.Errorf("suspend: %s", err.Error())
+	}
+	if _, err := parseCron(schedule.Resume); err != nil {
+		return fmt.Errorf("resume: %s", err.Error())
+	}
+	if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone: %s", schedule.TimeZone)
+	}
+	return nil
+}
+
+//HibernationAction returns the action of the schedule in the minute of the time, it's empty if the schedule
+//doesn't run then; the cluster is resumed if both expressions match
+func HibernationAction(schedule model.HibernationScheduleModel, t time.Time) (string, error) {
+	if err := ValidateHibernationSchedule(schedule); err != nil {
+		return "", err
+	}
+	location, _ := time.LoadLocation(schedule.TimeZone)
+	t = t.In(location)
+	resume, _ := parseCron(schedule.Resume)
+	if resume.matches(t) {
+		return HibernationResume, nil
+	}
+	suspend, _ := parseCron(schedule.Suspend)
+	if suspend.matches(t) {
+		return HibernationSuspend, nil
+	}
+	return "", nil
+}
+
+// hibernatedNodePool returns the node pool scaled to zero without autoscaling
+func hibernatedNodePool(pool model.NodePoolModel) model.NodePoolModel {
+	pool.Count = 0
+	pool.Autoscaling = false
+	pool.MinCount = 0
+	pool.MaxCount = 0
+	return pool
+}
+
+//SuspendCluster stops the cluster or scales its node pools to zero, the node pools of the model are kept
+//so the cluster can be resumed with the same nodes
+func SuspendCluster(commonCluster CommonCluster) error {
+	log := logger.WithFields(logrus.Fields{"action": "SuspendCluster"})
+	if hibernator, ok := commonCluster.(Hibernator); ok {
+		if err := RunStep(commonCluster, "SuspendCluster", hibernator.Suspend); err != nil {
+			return err
+		}
+		log.Infof("Cluster %s stopped", commonCluster.GetName())
+		return nil
+	}
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	for _, pool := range commonCluster.GetModel().NodePools {
+		pool := pool
+		err := RunStep(commonCluster, "SuspendNodePool", func() error {
+			return manager.UpdateNodePool(pool, hibernatedNodePool(pool))
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("Node pool %s of cluster %s scaled to zero", pool.Name, commonCluster.GetName())
+	}
+	return nil
+}
+
+//ResumeCluster starts the stopped cluster or scales its node pools back to their node counts
+func ResumeCluster(commonCluster CommonCluster) error {
+	log := logger.WithFields(logrus.Fields{"action": "ResumeCluster"})
+	if hibernator, ok := commonCluster.(Hibernator); ok {
+		if err := RunStep(commonCluster, "ResumeCluster", hibernator.Resume); err != nil {
+			return err
+		}
+		log.Infof("Cluster %s started", commonCluster.GetName())
+		return nil
+	}
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	for _, pool := range commonCluster.GetModel().NodePools {
+		pool := pool
+		err := RunStep(commonCluster, "ResumeNodePool", func() error {
+			return manager.UpdateNodePool(hibernatedNodePool(pool), pool)
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("Node pool %s of cluster %s resumed", pool.Name, commonCluster.GetName())
+	}
+	return nil
+}
+
+//CheckHibernation checks whether the cluster can be suspended and resumed
+func CheckHibernation(commonCluster CommonCluster) error {
+	if _, ok := commonCluster.(Hibernator); ok {
+		return nil
+	}
+	_, err := nodePoolManager(commonCluster)
+	return err
+}
+
+//RunHibernationScheduler suspends and resumes the clusters by their schedules every minute, it never returns
+func RunHibernationScheduler() {
+	log := logger.WithFields(logrus.Fields{"action": "HibernationScheduler"})
+	last := time.Now().Truncate(time.Minute)
+	for range time.Tick(time.Minute) {
+		now := time.Now().Truncate(time.Minute)
+		// the minutes are checked one by one so a late tick doesn't skip a schedule
+		for t := last.Add(time.Minute); !t.After(now); t = t.Add(time.Minute) {
+			if err := runHibernationSchedules(t); err != nil {
+				log.Errorf("Error running the hibernation schedules: %s", err.Error())
+			}
+		}
+		last = now
+	}
+}
+
+// runHibernationSchedules starts the actions of the schedules in the minute, the clusters busy with
+// another operation are skipped
+func runHibernationSchedules(t time.Time) error {
+	log := logger.WithFields(logrus.Fields{"action": "HibernationScheduler"})
+	schedules, err := model.ListHibernationSchedules()
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		action, err := HibernationAction(schedule, t)
+		if err != nil {
+			log.Warnf("Invalid hibernation schedule of cluster %d: %s", schedule.ClusterModelID, err.Error())
+			continue
+		}
+		if action == "" {
+			continue
+		}
+		var modelCluster model.ClusterModel
+		if err := model.GetDB().First(&modelCluster, schedule.ClusterModelID).Error; err != nil {
+			if model.IsErrorGormNotFound(err) {
+				err = model.DeleteHibernationSchedule(schedule.ClusterModelID)
+			}
+			if err != nil {
+				log.Warnf("Error loading cluster %d: %s", schedule.ClusterModelID, err.Error())
+			}
+			continue
+		}
+		commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+		if err != nil {
+			log.Warnf("Error loading cluster %s: %s", modelCluster.Name, err.Error())
+			continue
+		}
+		status := ClusterStatus(commonCluster)
+		if action == HibernationSuspend && status == StatusHibernated || action == HibernationResume && status == StatusRunning {
+			continue
+		}
+		if err := StartHibernationAction(commonCluster, action); err != nil {
+			log.Warnf("The %s of cluster %s isn't started: %s", action, modelCluster.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+//StartHibernationAction moves the cluster into the suspending or resuming status and runs the action in the
+//background, the error is a StatusTransitionError if the cluster is busy or already in the status of the action
+func StartHibernationAction(commonCluster CommonCluster, action string) error {
+	log := logger.WithFields(logrus.Fields{"action": "Hibernation"})
+	if err := CheckHibernation(commonCluster); err != nil {
+		return err
+	}
+	status, finished, run := StatusSuspending, StatusHibernated, SuspendCluster
+	if action == HibernationResume {
+		status, finished, run = StatusResuming, StatusRunning, ResumeCluster
+	}
+	if err := SetStatus(commonCluster, status, ""); err != nil {
+		return err
+	}
+	go func() {
+		status, message := finished, ""
+		if err := run(commonCluster); err != nil {
+			log.Errorf("Error during the %s of cluster %s: %s", action, commonCluster.GetName(), err.Error())
+			status, message = StatusError, err.Error()
+		}
+		if err := SetStatus(commonCluster, status, message); err != nil {
+			log.Errorf("Error during setting cluster status: %s", err.Error())
+		}
+	}()
+	return nil
+}
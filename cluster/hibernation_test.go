@@ -0,0 +1,54 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestHibernationAction(t *testing.T) {
+
+	weekdays := model.HibernationScheduleModel{Suspend: "0 20 * * 1-5", Resume: "0 7 * * 1-5", TimeZone: "Europe/Budapest"}
+
+	cases := []struct {
+		name        string
+		schedule    model.HibernationScheduleModel
+		time        string
+		action      string
+		expectError bool
+	}{
+		{name: "suspend in the evening", schedule: weekdays, time: "2026-10-16T18:00:00Z", action: cluster.HibernationSuspend},
+		{name: "resume in the morning", schedule: weekdays, time: "2026-10-19T05:00:00Z", action: cluster.HibernationResume},
+		{name: "weekend", schedule: weekdays, time: "2026-10-17T05:00:00Z"},
+		{name: "other minute", schedule: weekdays, time: "2026-10-16T18:01:00Z"},
+		{name: "utc step", schedule: model.HibernationScheduleModel{Suspend: "*/15 22-23 * * *", Resume: "0 6 1,15 * 0"}, time: "2026-10-14T23:45:00Z", action: cluster.HibernationSuspend},
+		{name: "day of month or sunday", schedule: model.HibernationScheduleModel{Suspend: "0 0 1 1 *", Resume: "0 6 1,15 * 7"}, time: "2026-10-18T06:00:00Z", action: cluster.HibernationResume},
+		{name: "missing field", schedule: model.HibernationScheduleModel{Suspend: "0 20 * *", Resume: "0 7 * * *"}, time: "2026-10-16T18:00:00Z", expectError: true},
+		{name: "out of range", schedule: model.HibernationScheduleModel{Suspend: "0 24 * * *", Resume: "0 7 * * *"}, time: "2026-10-16T18:00:00Z", expectError: true},
+		{name: "invalid time zone", schedule: model.HibernationScheduleModel{Suspend: "0 20 * * *", Resume: "0 7 * * *", TimeZone: "Mars/Olympus"}, time: "2026-10-16T18:00:00Z", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tc.time)
+			if err != nil {
+				t.Fatalf("Error parsing time: %s", err.Error())
+			}
+			action, err := cluster.HibernationAction(tc.schedule, at)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during HibernationAction: %s", err.Error())
+			}
+			if action != tc.action {
+				t.Errorf("Expected action: %q, got: %q", tc.action, action)
+			}
+		})
+	}
+}
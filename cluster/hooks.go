@@ -13,10 +13,13 @@ import (
 	"time"
 )
 
-//RunPostHooks calls posthook functions with created cluster
+//RunPostHooks calls posthook functions with created cluster, they are recorded as the steps of the creation
 func RunPostHooks(functionList []func(cluster CommonCluster), createdCluster CommonCluster) {
 	for _, i := range functionList {
-		i(createdCluster)
+		RunStep(createdCluster, hookName(i), func() error {
+			i(createdCluster)
+			return nil
+		})
 	}
 }
 
@@ -97,25 +100,13 @@ func saveKeysToConfigmap(config *rest.Config, configName string, clusterName str
 
 //InstallIngressControllerPostHook post hooks can't return value, they can log error and/or update state?
 func InstallIngressControllerPostHook(cluster CommonCluster) {
-	// --- [ Get K8S Config ] --- //
 	log = logger.WithFields(logrus.Fields{"action": "InstallIngressController"})
 
-	kubeConfig, err := cluster.GetK8sConfig()
-	if err != nil {
-		log.Errorf("Unable to fetch config for posthook: %s", err.Error())
-		return
-	}
-
-	deploymentName := "banzaicloud-stable/pipeline-cluster-ingress"
-	releaseName := "pipeline"
-
-	_, err = helm.CreateDeployment(deploymentName, releaseName, nil, kubeConfig, cluster.GetName())
-	if err != nil {
-		log.Errorf("Deploying '%s' failed due to: ", deploymentName)
-		log.Errorf("%s", err.Error())
+	if _, err := InstallAddon(cluster, AddonIngress); err != nil {
+		log.Errorf("Deploying the ingress controller failed due to: %s", err.Error())
 		return
 	}
-	log.Infof("'%s' installed", deploymentName)
+	log.Info("Ingress controller installed")
 }
 
 //GetConfigPostHook functions with func(*cluster.Cluster) signature
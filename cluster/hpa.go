@@ -0,0 +1,511 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+)
+
+// AddonMetricsAdapter is the add-on serving the Prometheus metrics of the monitoring add-on as custom and external
+// metrics of the autoscaling policies
+const AddonMetricsAdapter = "prometheus-adapter"
+
+const (
+	hpaAPIVersion        = "autoscaling/v2beta1"
+	hpaReleaseLabel      = "pipeline.banzaicloud.com/release"
+	customMetricsGroup   = "custom.metrics.k8s.io/v1beta1"
+	externalMetricsGroup = "external.metrics.k8s.io/v1beta1"
+)
+
+// Types of the metrics of the autoscaling policies
+const (
+	MetricTypeResource = "Resource"
+	MetricTypePods     = "Pods"
+	MetricTypeObject   = "Object"
+	MetricTypeExternal = "External"
+)
+
+// the resources of the not found errors of the autoscaling policies
+var (
+	helmReleaseResource = schema.GroupResource{Resource: "release"}
+	hpaGroupResource    = schema.GroupResource{Group: "autoscaling", Resource: "horizontalpodautoscalers"}
+)
+
+// autoscalingTargetKinds are the kinds of the workloads scaled by the autoscaling policies
+var autoscalingTargetKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+}
+
+// AutoscalingObject is the object described by an Object metric, e.g. the ingress of the requests
+type AutoscalingObject struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// AutoscalingMetric is a metric of an autoscaling policy and its target: the average utilization (percent of the
+// requests) or the average value of a cpu or memory Resource metric, the average value of a Pods metric, the
+// value of an Object metric, or the value or the average value of an External metric of the series of the
+// selector. The quantities are Kubernetes quantities, e.g. 500m or 10k.
+type AutoscalingMetric struct {
+	Type                     string             `json:"type" binding:"required"`
+	Name                     string             `json:"name" binding:"required"`
+	Object                   *AutoscalingObject `json:"object,omitempty"`
+	Selector                 map[string]string  `json:"selector,omitempty"`
+	TargetAverageUtilization int32              `json:"targetAverageUtilization,omitempty"`
+	TargetAverageValue       string             `json:"targetAverageValue,omitempty"`
+	TargetValue              string             `json:"targetValue,omitempty"`
+}
+
+// AutoscalingPolicy is a horizontal pod autoscaler of a workload of a deployment, it's named after the scaled
+// Deployment or StatefulSet of the release; the replicas are set by the autoscaler
+type AutoscalingPolicy struct {
+	Name            string              `json:"name" binding:"required"`
+	Kind            string              `json:"kind,omitempty"`
+	MinReplicas     int32               `json:"minReplicas"`
+	MaxReplicas     int32               `json:"maxReplicas" binding:"required"`
+	Metrics         []AutoscalingMetric `json:"metrics" binding:"required"`
+	CurrentReplicas int32               `json:"currentReplicas,omitempty"`
+	DesiredReplicas int32               `json:"desiredReplicas,omitempty"`
+}
+
+// AutoscalingMetrics are the custom metrics (resource/metric, e.g. pods/http_requests) and the external
+// metrics served to the autoscalers of the cluster
+type AutoscalingMetrics struct {
+	Custom   []string `json:"custom"`
+	External []string `json:"external"`
+}
+
+// ValidateAutoscalingPolicy checks the replicas and the targets of the metrics of the policy and sets the kind
+// and the minimum replicas by default
+func ValidateAutoscalingPolicy(policy *AutoscalingPolicy) error {
+	if errs := validation.IsDNS1123Subdomain(policy.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid workload name %q: %s", policy.Name, strings.Join(errs, ", "))
+	}
+	if policy.Kind == "" {
+		policy.Kind = "Deployment"
+	} else if !autoscalingTargetKinds[policy.Kind] {
+		return fmt.Errorf("the autoscaled workload must be a Deployment or a StatefulSet, not a %s", policy.Kind)
+	}
+	if policy.MinReplicas == 0 {
+		policy.MinReplicas = 1
+	}
+	if policy.MinReplicas < 0 || policy.MaxReplicas < policy.MinReplicas {
+		return fmt.Errorf("invalid replicas: %d-%d", policy.MinReplicas, policy.MaxReplicas)
+	}
+	if len(policy.Metrics) == 0 {
+		return errors.New("the policy must have metrics")
+	}
+	for _, metric := range policy.Metrics {
+		if err := validateAutoscalingMetric(metric); err != nil {
+			return errors.Wrapf(err, "invalid %s metric %s", metric.Type, metric.Name)
+		}
+	}
+	return nil
+}
+
+// validateAutoscalingMetric checks the target of the metric
+func validateAutoscalingMetric(metric AutoscalingMetric) error {
+	targets := 0
+	for _, quantity := range []string{metric.TargetAverageValue, metric.TargetValue} {
+		if quantity == "" {
+			continue
+		}
+		targets++
+		if _, err := resource.ParseQuantity(quantity); err != nil {
+			return err
+		}
+	}
+	if metric.TargetAverageUtilization < 0 {
+		return errors.New("the utilization must be positive")
+	} else if metric.TargetAverageUtilization > 0 {
+		targets++
+	}
+	if targets != 1 {
+		return errors.New("the metric must have one target")
+	}
+	switch metric.Type {
+	case MetricTypeResource:
+		if metric.Name != "cpu" && metric.Name != "memory" {
+			return errors.New("the resource must be cpu or memory")
+		}
+		if metric.TargetValue != "" {
+			return errors.New("the resource metrics have an average target")
+		}
+	case MetricTypePods:
+		if metric.TargetAverageValue == "" {
+			return errors.New("the pods metrics have an average value target")
+		}
+	case MetricTypeObject:
+		if metric.Object == nil || metric.Object.Kind == "" || metric.Object.Name == "" {
+			return errors.New("the object metrics need the kind and the name of the object")
+		}
+		if metric.TargetValue == "" {
+			return errors.New("the object metrics have a value target")
+		}
+	case MetricTypeExternal:
+		if metric.TargetAverageUtilization > 0 {
+			return errors.New("the external metrics have a value or an average value target")
+		}
+	default:
+		return fmt.Errorf("the type must be %s, %s, %s or %s", MetricTypeResource, MetricTypePods, MetricTypeObject, MetricTypeExternal)
+	}
+	if metric.Selector != nil && metric.Type != MetricTypeExternal {
+		return errors.New("only the external metrics have selectors")
+	}
+	return nil
+}
+
+// resourcePlural returns the resource of the kind in the names of the custom metrics
+func resourcePlural(kind string) string {
+	plural := strings.ToLower(kind)
+	if strings.HasSuffix(plural, "s") {
+		return plural + "es"
+	}
+	return plural + "s"
+}
+
+// metricsOfGroup returns the metrics served by the metrics API group, the error is a not found error if the
+// group isn't served
+func metricsOfGroup(client discovery.DiscoveryInterface, group string) ([]string, error) {
+	resources, err := client.ServerResourcesForGroupVersion(group)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, resource := range resources.APIResources {
+		if !seen[resource.Name] {
+			seen[resource.Name] = true
+			names = append(names, resource.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetAutoscalingMetrics returns the custom and external metrics served to the autoscalers of the cluster, the
+// lists are empty if the prometheus-adapter add-on (or another metrics adapter) isn't installed
+func GetAutoscalingMetrics(commonCluster CommonCluster) (*AutoscalingMetrics, error) {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	metrics := &AutoscalingMetrics{Custom: []string{}, External: []string{}}
+	for group, names := range map[string]*[]string{customMetricsGroup: &metrics.Custom, externalMetricsGroup: &metrics.External} {
+		served, err := metricsOfGroup(client.Discovery(), group)
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "error listing the metrics of %s", group)
+		}
+		if served != nil {
+			*names = served
+		}
+	}
+	return metrics, nil
+}
+
+// checkAvailableMetrics checks that the custom and external metrics of the policy are served on the cluster
+func checkAvailableMetrics(commonCluster CommonCluster, policy *AutoscalingPolicy) error {
+	var available *AutoscalingMetrics
+	for _, metric := range policy.Metrics {
+		var name string
+		var served func() []string
+		switch metric.Type {
+		case MetricTypePods:
+			name, served = "pods/"+metric.Name, func() []string { return available.Custom }
+		case MetricTypeObject:
+			name, served = resourcePlural(metric.Object.Kind)+"/"+metric.Name, func() []string { return available.Custom }
+		case MetricTypeExternal:
+			name, served = metric.Name, func() []string { return available.External }
+		default:
+			continue
+		}
+		if available == nil {
+			var err error
+			if available, err = GetAutoscalingMetrics(commonCluster); err != nil {
+				return err
+			}
+		}
+		found := false
+		for _, servedName := range served() {
+			found = found || servedName == name
+		}
+		if !found {
+			return fmt.Errorf("the %s metric %s isn't served on the cluster, check the rules of the %s add-on",
+				strings.ToLower(metric.Type), metric.Name, AddonMetricsAdapter)
+		}
+	}
+	return nil
+}
+
+// hpaResource returns the horizontal pod autoscaler resource of the release in the default namespace
+func hpaResource(release, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": hpaAPIVersion,
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata":   map[string]interface{}{"name": name, "namespace": helm.DefaultNamespace},
+	}}
+	if release != "" {
+		object.SetLabels(map[string]string{hpaReleaseLabel: release})
+	}
+	if spec != nil {
+		object.Object["spec"] = spec
+	}
+	return object
+}
+
+// hpaMetric returns the autoscaling/v2beta1 metric spec of the metric, the vendored client has no external
+// metrics so the autoscalers are unstructured
+func hpaMetric(metric AutoscalingMetric) map[string]interface{} {
+	source := map[string]interface{}{}
+	if metric.TargetAverageUtilization > 0 {
+		source["targetAverageUtilization"] = int64(metric.TargetAverageUtilization)
+	}
+	if metric.TargetAverageValue != "" {
+		source["targetAverageValue"] = metric.TargetAverageValue
+	}
+	if metric.TargetValue != "" {
+		source["targetValue"] = metric.TargetValue
+	}
+	switch metric.Type {
+	case MetricTypeResource:
+		source["name"] = metric.Name
+	case MetricTypeObject:
+		source["metricName"] = metric.Name
+		apiVersion := metric.Object.APIVersion
+		if apiVersion == "" {
+			apiVersion = "v1"
+		}
+		source["target"] = map[string]interface{}{"apiVersion": apiVersion, "kind": metric.Object.Kind, "name": metric.Object.Name}
+	case MetricTypeExternal:
+		source["metricName"] = metric.Name
+		if len(metric.Selector) > 0 {
+			labels := map[string]interface{}{}
+			for key, value := range metric.Selector {
+				labels[key] = value
+			}
+			source["metricSelector"] = map[string]interface{}{"matchLabels": labels}
+		}
+	default:
+		source["metricName"] = metric.Name
+	}
+	return map[string]interface{}{"type": metric.Type, strings.ToLower(metric.Type): source}
+}
+
+// policyFromResource returns the autoscaling policy of the horizontal pod autoscaler resource
+func policyFromResource(object *unstructured.Unstructured) AutoscalingPolicy {
+	data, _ := json.Marshal(object.Object)
+	type metricSource struct {
+		Name           string             `json:"name"`
+		MetricName     string             `json:"metricName"`
+		Target         *AutoscalingObject `json:"target"`
+		MetricSelector *struct {
+			MatchLabels map[string]string `json:"matchLabels"`
+		} `json:"metricSelector"`
+		TargetAverageUtilization int32  `json:"targetAverageUtilization"`
+		TargetAverageValue       string `json:"targetAverageValue"`
+		TargetValue              string `json:"targetValue"`
+	}
+	var fields struct {
+		Spec struct {
+			ScaleTargetRef struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"scaleTargetRef"`
+			MinReplicas int32 `json:"minReplicas"`
+			MaxReplicas int32 `json:"maxReplicas"`
+			Metrics     []struct {
+				Type     string        `json:"type"`
+				Resource *metricSource `json:"resource"`
+				Pods     *metricSource `json:"pods"`
+				Object   *metricSource `json:"object"`
+				External *metricSource `json:"external"`
+			} `json:"metrics"`
+		} `json:"spec"`
+		Status struct {
+			CurrentReplicas int32 `json:"currentReplicas"`
+			DesiredReplicas int32 `json:"desiredReplicas"`
+		} `json:"status"`
+	}
+	json.Unmarshal(data, &fields)
+	policy := AutoscalingPolicy{
+		Name:            fields.Spec.ScaleTargetRef.Name,
+		Kind:            fields.Spec.ScaleTargetRef.Kind,
+		MinReplicas:     fields.Spec.MinReplicas,
+		MaxReplicas:     fields.Spec.MaxReplicas,
+		Metrics:         []AutoscalingMetric{},
+		CurrentReplicas: fields.Status.CurrentReplicas,
+		DesiredReplicas: fields.Status.DesiredReplicas,
+	}
+	for _, spec := range fields.Spec.Metrics {
+		source := spec.Resource
+		for _, other := range []*metricSource{spec.Pods, spec.Object, spec.External} {
+			if source == nil {
+				source = other
+			}
+		}
+		if source == nil {
+			continue
+		}
+		metric := AutoscalingMetric{
+			Type:                     spec.Type,
+			Name:                     source.Name + source.MetricName,
+			Object:                   source.Target,
+			TargetAverageUtilization: source.TargetAverageUtilization,
+			TargetAverageValue:       source.TargetAverageValue,
+			TargetValue:              source.TargetValue,
+		}
+		if source.MetricSelector != nil {
+			metric.Selector = source.MetricSelector.MatchLabels
+		}
+		policy.Metrics = append(policy.Metrics, metric)
+	}
+	return policy
+}
+
+// checkAutoscalingTarget checks that the workload of the policy is a workload of the release
+func checkAutoscalingTarget(kubeConfig *[]byte, release string, policy *AutoscalingPolicy) error {
+	workload, err := helm.GetManifest(kubeConfig, workloadResource(policy.Kind, policy.Name))
+	if err != nil {
+		return err
+	}
+	for _, label := range releaseWorkloadLabels {
+		if workload.GetLabels()[label] == release {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s isn't a workload of deployment %s", policy.Kind, policy.Name, release)
+}
+
+// ListAutoscalingPolicies returns the autoscaling policies of the deployment of the cluster
+func ListAutoscalingPolicies(commonCluster CommonCluster, release string) ([]AutoscalingPolicy, error) {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	items, err := helm.ListManifests(kubeConfig, hpaResource("", "", nil), map[string]string{hpaReleaseLabel: release})
+	if err != nil {
+		return nil, err
+	}
+	policies := []AutoscalingPolicy{}
+	for i := range items {
+		policies = append(policies, policyFromResource(&items[i]))
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	return policies, nil
+}
+
+// SetAutoscalingPolicy creates or replaces the autoscaling policy of a workload of the deployment, the custom and
+// external metrics of the policy must be served on the cluster
+func SetAutoscalingPolicy(commonCluster CommonCluster, release string, policy AutoscalingPolicy) (*AutoscalingPolicy, error) {
+	if err := ValidateAutoscalingPolicy(&policy); err != nil {
+		return nil, err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	if code, err := helm.GetDeploymentStatus(release, kubeConfig); err != nil {
+		if code == http.StatusNotFound {
+			return nil, k8sErrors.NewNotFound(helmReleaseResource, release)
+		}
+		return nil, err
+	}
+	if err := checkAutoscalingTarget(kubeConfig, release, &policy); err != nil {
+		return nil, err
+	}
+	if err := checkAvailableMetrics(commonCluster, &policy); err != nil {
+		return nil, err
+	}
+	metrics := []interface{}{}
+	for _, metric := range policy.Metrics {
+		metrics = append(metrics, hpaMetric(metric))
+	}
+	object := hpaResource(release, policy.Name, map[string]interface{}{
+		"scaleTargetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": policy.Kind, "name": policy.Name},
+		"minReplicas":    int64(policy.MinReplicas),
+		"maxReplicas":    int64(policy.MaxReplicas),
+		"metrics":        metrics,
+	})
+	live, err := helm.GetManifest(kubeConfig, object)
+	if err == nil && live.GetLabels()[hpaReleaseLabel] != release {
+		return nil, fmt.Errorf("the autoscaler %s isn't managed by Pipeline for deployment %s", policy.Name, release)
+	}
+	if _, err := helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{object}, nil); err != nil {
+		return nil, err
+	}
+	applied := policyFromResource(object)
+	return &applied, nil
+}
+
+// DeleteAutoscalingPolicy deletes the autoscaling policy of a workload of the deployment, the replicas are kept
+func DeleteAutoscalingPolicy(commonCluster CommonCluster, release, name string) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	object := hpaResource("", name, nil)
+	live, err := helm.GetManifest(kubeConfig, object)
+	if err != nil {
+		return err
+	}
+	if live.GetLabels()[hpaReleaseLabel] != release {
+		return k8sErrors.NewNotFound(hpaGroupResource, name)
+	}
+	return helm.DeleteManifests(kubeConfig, []*unstructured.Unstructured{object})
+}
+
+// DeleteReleaseAutoscaling deletes the autoscaling policies of the deleted deployment
+func DeleteReleaseAutoscaling(commonCluster CommonCluster, release string) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	items, err := helm.ListManifests(kubeConfig, hpaResource("", "", nil), map[string]string{hpaReleaseLabel: release})
+	if err != nil {
+		return err
+	}
+	objects := []*unstructured.Unstructured{}
+	for i := range items {
+		objects = append(objects, &items[i])
+	}
+	return helm.DeleteManifests(kubeConfig, objects)
+}
+
+// metricsAdapterValues returns the values of the prometheus-adapter chart: the Prometheus of the monitoring
+// add-on and the rules of the config, the default rules of the chart serve the pod metrics
+func metricsAdapterValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	monitoring, err := model.GetAddon(commonCluster.GetID(), AddonMonitoring)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the monitoring add-on")
+	}
+	values := map[string]interface{}{
+		"rbac": map[string]interface{}{"create": true},
+		"prometheus": map[string]interface{}{
+			"url":  fmt.Sprintf("http://%s-prometheus-server.%s.svc", monitoring.ReleaseName, helm.DefaultNamespace),
+			"port": 80,
+		},
+	}
+	if rules := viper.Get("addons.prometheus-adapter.rules"); rules != nil {
+		values["rules"] = rules
+	}
+	return values, nil
+}
@@ -0,0 +1,60 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestValidateAutoscalingPolicy(t *testing.T) {
+
+	cpu := cluster.AutoscalingMetric{Type: cluster.MetricTypeResource, Name: "cpu", TargetAverageUtilization: 70}
+	withMetric := func(metric cluster.AutoscalingMetric) cluster.AutoscalingPolicy {
+		return cluster.AutoscalingPolicy{Name: "web", MaxReplicas: 10, Metrics: []cluster.AutoscalingMetric{metric}}
+	}
+
+	cases := []struct {
+		name        string
+		policy      cluster.AutoscalingPolicy
+		expectError bool
+	}{
+		{name: "cpu utilization", policy: withMetric(cpu)},
+		{name: "memory average", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypeResource, Name: "memory", TargetAverageValue: "512Mi"})},
+		{name: "pods", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypePods, Name: "http_requests", TargetAverageValue: "10"})},
+		{name: "object", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypeObject, Name: "requests_per_second",
+			Object: &cluster.AutoscalingObject{Kind: "Service", Name: "web"}, TargetValue: "2k"})},
+		{name: "external", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypeExternal, Name: "queue_messages_ready",
+			Selector: map[string]string{"queue": "jobs"}, TargetValue: "30"})},
+		{name: "statefulset", policy: cluster.AutoscalingPolicy{Name: "db", Kind: "StatefulSet", MinReplicas: 3, MaxReplicas: 5, Metrics: []cluster.AutoscalingMetric{cpu}}},
+		{name: "daemonset", policy: cluster.AutoscalingPolicy{Name: "agent", Kind: "DaemonSet", MaxReplicas: 5, Metrics: []cluster.AutoscalingMetric{cpu}}, expectError: true},
+		{name: "invalid name", policy: cluster.AutoscalingPolicy{Name: "Web", MaxReplicas: 10, Metrics: []cluster.AutoscalingMetric{cpu}}, expectError: true},
+		{name: "max below min", policy: cluster.AutoscalingPolicy{Name: "web", MinReplicas: 5, MaxReplicas: 2, Metrics: []cluster.AutoscalingMetric{cpu}}, expectError: true},
+		{name: "no metrics", policy: cluster.AutoscalingPolicy{Name: "web", MaxReplicas: 10}, expectError: true},
+		{name: "unknown type", policy: withMetric(cluster.AutoscalingMetric{Type: "Custom", Name: "x", TargetValue: "1"}), expectError: true},
+		{name: "unknown resource", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypeResource, Name: "gpu", TargetAverageUtilization: 50}), expectError: true},
+		{name: "two targets", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypeResource, Name: "cpu", TargetAverageUtilization: 50, TargetAverageValue: "500m"}), expectError: true},
+		{name: "no target", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypePods, Name: "http_requests"}), expectError: true},
+		{name: "invalid quantity", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypePods, Name: "http_requests", TargetAverageValue: "ten"}), expectError: true},
+		{name: "object without object", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypeObject, Name: "requests_per_second", TargetValue: "2k"}), expectError: true},
+		{name: "pods selector", policy: withMetric(cluster.AutoscalingMetric{Type: cluster.MetricTypePods, Name: "http_requests", TargetAverageValue: "10",
+			Selector: map[string]string{"path": "/"}}), expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateAutoscalingPolicy(&tc.policy)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during validating autoscaling policy: %s", err.Error())
+			}
+			if tc.policy.Kind == "" || tc.policy.MinReplicas == 0 {
+				t.Errorf("Expected the defaults of the policy, got: %v", tc.policy)
+			}
+		})
+	}
+}
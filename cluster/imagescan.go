@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/imagescan"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// scanReports caches the reports of the images of the organizations
+var scanReports = struct {
+	sync.Mutex
+	reports map[string]*imagescan.Report
+}{reports: map[string]*imagescan.Report{}}
+
+// NewImageScanner creates the image scanner of the imagescan configuration
+func NewImageScanner() (imagescan.Scanner, error) {
+	return imagescan.NewScanner(viper.GetString("imagescan.scanner"), viper.GetString("imagescan.trivyBinary"),
+		viper.GetString("imagescan.trivyServer"), viper.GetDuration("imagescan.timeout"))
+}
+
+// ScanImage scans the image with the credentials of the registry of the organization on its host, the reports
+// are reused for imagescan.cacheTTL unless the scan is forced
+func ScanImage(organizationID uint, image string, force bool) (*imagescan.Report, error) {
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return nil, errors.Wrapf(err, "invalid image %q", image)
+	}
+	key := strconv.FormatUint(uint64(organizationID), 10) + "/" + image
+	scanReports.Lock()
+	report, ok := scanReports.reports[key]
+	scanReports.Unlock()
+	if ok && !force && time.Since(report.ScannedAt) < viper.GetDuration("imagescan.cacheTTL") {
+		return report, nil
+	}
+
+	credentials := imagescan.Credentials{}
+	host := helm.ImageRegistry(image)
+	registry, err := model.GetContainerRegistryByHost(organizationID, host)
+	if err == nil {
+		item, err := secret.Store.Get(strconv.FormatUint(uint64(organizationID), 10), registry.SecretID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting the secret of registry %s", registry.Name)
+		}
+		if credentials.Username, credentials.Password, err = helm.RegistryLogin(item, host); err != nil {
+			return nil, err
+		}
+	} else if !model.IsErrorGormNotFound(err) {
+		return nil, err
+	}
+	scanner, err := NewImageScanner()
+	if err != nil {
+		return nil, err
+	}
+	if report, err = scanner.Scan(image, credentials); err != nil {
+		return nil, err
+	}
+	scanReports.Lock()
+	scanReports.reports[key] = report
+	scanReports.Unlock()
+	return report, nil
+}
+
+// ImageScanPolicy returns the scanning policy of the organization, it's nil if the organization doesn't scan
+// the images of its deployments
+func ImageScanPolicy(organizationID uint) (*imagescan.Policy, error) {
+	policyModel, err := model.GetImageScanPolicy(organizationID)
+	if model.IsErrorGormNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !policyModel.Enabled {
+		return nil, nil
+	}
+	return &imagescan.Policy{
+		Severity:      policyModel.Severity,
+		IgnoreUnfixed: policyModel.IgnoreUnfixed,
+		Ignored:       policyModel.GetIgnored(),
+	}, nil
+}
+
+// CheckImages scans the images of a deployment of the cluster against the scanning policy of its organization,
+// the error is an *imagescan.PolicyError if images have vulnerabilities the policy doesn't allow
+func CheckImages(cluster CommonCluster, images []string) error {
+	log := logger.WithFields(logrus.Fields{"tag": "CheckImages"})
+	policy, err := ImageScanPolicy(cluster.GetOrg())
+	if err != nil || policy == nil {
+		return err
+	}
+	policyErr := &imagescan.PolicyError{Severity: policy.Severity}
+	for _, image := range images {
+		report, err := ScanImage(cluster.GetOrg(), image, false)
+		if err != nil {
+			return errors.Wrapf(err, "error scanning image %s", image)
+		}
+		if violations := policy.Violations(report); len(violations) > 0 {
+			log.Infof("Image %s of cluster %s has %d vulnerabilities of %s severity or above", image, cluster.GetName(), len(violations), policy.Severity)
+			policyErr.Images = append(policyErr.Images, imagescan.ImageViolations{Image: image, Vulnerabilities: violations})
+		}
+	}
+	if len(policyErr.Images) > 0 {
+		return policyErr
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestScanImageInvalidReference(t *testing.T) {
+
+	for _, image := range []string{"", "--output=/tmp/report", "acme/web:1.0 --severity LOW", "Acme/Web", "acme/web:"} {
+		t.Run(image, func(t *testing.T) {
+			if _, err := cluster.ScanImage(1, image, true); err == nil {
+				t.Errorf("Expected error, but not got error!")
+			}
+		})
+	}
+}
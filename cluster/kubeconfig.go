@@ -0,0 +1,189 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	kubeconfigServiceAccountPrefix = "pipeline-kubeconfig-"
+	kubeconfigExpiryAnnotation     = "pipeline.banzaicloud.com/expires-at"
+	// the token controller creates the token secret of the service account
+	kubeconfigTokenRetries = 30
+	kubeconfigTokenSleep   = time.Second
+)
+
+//ExpiringConfig is a kubeconfig with the token of a temporary service account of the cluster
+type ExpiringConfig struct {
+	Config    []byte
+	ExpiresAt time.Time
+}
+
+//KubeconfigTTL parses the lifetime of a kubeconfig, the configured default is used without it
+func KubeconfigTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		ttl = viper.GetString("kubeconfig.defaultTTL")
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, fmt.Errorf("invalid kubeconfig TTL %s", ttl)
+	}
+	max := viper.GetDuration("kubeconfig.maxTTL")
+	if duration < time.Minute || (max != 0 && duration > max) {
+		return 0, fmt.Errorf("kubeconfig TTL must be between 1m and %s", max)
+	}
+	return duration, nil
+}
+
+//GenerateExpiringConfig creates a kubeconfig whose credentials expire after the TTL instead of the long-lived
+//credentials of the cluster config. The token is the token of a new cluster-admin service account, which is
+//deleted when the TTL elapses; the expired service accounts left behind by a restart are deleted with the next
+//kubeconfig of the cluster.
+func GenerateExpiringConfig(commonCluster CommonCluster, ttl time.Duration) (*ExpiringConfig, error) {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagFetchClusterConfig})
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig, err := helm.GetK8sClientConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := deleteExpiredKubeconfigServiceAccounts(client); err != nil {
+		log.Warnf("Error deleting the expired kubeconfig service accounts: %s", err.Error())
+	}
+
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	serviceAccount, err := client.CoreV1().ServiceAccounts(metav1.NamespaceSystem).Create(&v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: kubeconfigServiceAccountPrefix,
+			Annotations:  map[string]string{kubeconfigExpiryAnnotation: expiresAt.Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the kubeconfig service account")
+	}
+	name := serviceAccount.Name
+	_, err = client.RbacV1().ClusterRoleBindings().Create(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cluster-admin"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: metav1.NamespaceSystem}},
+	})
+	var token *v1.Secret
+	if err == nil {
+		token, err = serviceAccountToken(client, name)
+	}
+	if err != nil {
+		deleteKubeconfigResources(client, name)
+		return nil, errors.Wrap(err, "error creating the token of the kubeconfig service account")
+	}
+	time.AfterFunc(time.Until(expiresAt), func() {
+		deleteKubeconfigServiceAccount(commonCluster, name)
+	})
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[commonCluster.GetName()] = &clientcmdapi.Cluster{
+		Server:                   restConfig.Host,
+		CertificateAuthorityData: token.Data[v1.ServiceAccountRootCAKey],
+	}
+	config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: string(token.Data[v1.ServiceAccountTokenKey])}
+	config.Contexts[commonCluster.GetName()] = &clientcmdapi.Context{Cluster: commonCluster.GetName(), AuthInfo: name}
+	config.CurrentContext = commonCluster.GetName()
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Kubeconfig of cluster %s expiring at %s generated", commonCluster.GetName(), expiresAt)
+	return &ExpiringConfig{Config: data, ExpiresAt: expiresAt}, nil
+}
+
+// serviceAccountToken waits for the token secret of the service account
+func serviceAccountToken(client *kubernetes.Clientset, name string) (*v1.Secret, error) {
+	for i := 0; i < kubeconfigTokenRetries; i++ {
+		serviceAccount, err := client.CoreV1().ServiceAccounts(metav1.NamespaceSystem).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, reference := range serviceAccount.Secrets {
+			secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(reference.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if secret.Type == v1.SecretTypeServiceAccountToken && len(secret.Data[v1.ServiceAccountTokenKey]) != 0 {
+				return secret, nil
+			}
+		}
+		time.Sleep(kubeconfigTokenSleep)
+	}
+	return nil, fmt.Errorf("token of service account %s not found", name)
+}
+
+// deleteKubeconfigServiceAccount deletes the service account of an expired kubeconfig, its token is deleted with it
+func deleteKubeconfigServiceAccount(commonCluster CommonCluster, name string) {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagFetchClusterConfig})
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err == nil {
+		var client *kubernetes.Clientset
+		if client, err = helm.GetK8sConnection(kubeConfig); err == nil {
+			err = deleteKubeconfigResources(client, name)
+		}
+	}
+	if err != nil {
+		log.Errorf("Error deleting the expired kubeconfig service account %s: %s", name, err.Error())
+		return
+	}
+	log.Infof("Kubeconfig service account %s of cluster %s expired", name, commonCluster.GetName())
+}
+
+// deleteExpiredKubeconfigServiceAccounts deletes the service accounts of the expired kubeconfigs of the cluster
+func deleteExpiredKubeconfigServiceAccounts(client *kubernetes.Clientset) error {
+	serviceAccounts, err := client.CoreV1().ServiceAccounts(metav1.NamespaceSystem).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, serviceAccount := range serviceAccounts.Items {
+		// only the service accounts created for the kubeconfigs are deleted
+		if !strings.HasPrefix(serviceAccount.Name, kubeconfigServiceAccountPrefix) {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339, serviceAccount.Annotations[kubeconfigExpiryAnnotation])
+		if err != nil || time.Now().Before(expiresAt) {
+			continue
+		}
+		if err := deleteKubeconfigResources(client, serviceAccount.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteKubeconfigResources deletes the binding and the service account of a kubeconfig
+func deleteKubeconfigResources(client *kubernetes.Clientset, name string) error {
+	err := client.RbacV1().ClusterRoleBindings().Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	err = client.CoreV1().ServiceAccounts(metav1.NamespaceSystem).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestKubeconfigTTL(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		ttl         string
+		expected    time.Duration
+		expectError bool
+	}{
+		{name: "default", ttl: "", expected: time.Hour},
+		{name: "minutes", ttl: "30m", expected: 30 * time.Minute},
+		{name: "max", ttl: "24h", expected: 24 * time.Hour},
+		{name: "too long", ttl: "48h", expectError: true},
+		{name: "too short", ttl: "10s", expectError: true},
+		{name: "invalid", ttl: "1 day", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ttl, err := cluster.KubeconfigTTL(tc.ttl)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during KubeconfigTTL: %s", err.Error())
+			}
+			if ttl != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, ttl)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Kubernetes is the cloud type of the imported clusters
+const Kubernetes = "kubernetes"
+
+//CreateKubernetesClusterFromRequest creates the model of an imported cluster, its kubeconfig is in the Kubernetes secret
+func CreateKubernetesClusterFromRequest(name, secretId string, orgId uint) *KubeCluster {
+	return &KubeCluster{modelCluster: &model.ClusterModel{
+		Name:           name,
+		Cloud:          Kubernetes,
+		OrganizationId: orgId,
+		SecretId:       secretId,
+	}}
+}
+
+//CreateKubernetesClusterFromModel creates ClusterModel struct from model
+func CreateKubernetesClusterFromModel(clusterModel *model.ClusterModel) (*KubeCluster, error) {
+	return &KubeCluster{modelCluster: clusterModel}, nil
+}
+
+//KubeCluster is an existing Kubernetes cluster imported with its kubeconfig, Pipeline doesn't provision it
+type KubeCluster struct {
+	modelCluster *model.ClusterModel
+	APIEndpoint  string
+}
+
+func (c *KubeCluster) GetOrg() uint {
+	return c.modelCluster.OrganizationId
+}
+
+func (c *KubeCluster) GetSecretID() string {
+	return c.modelCluster.SecretId
+}
+
+//GetID returns the specified cluster id
+func (c *KubeCluster) GetID() uint {
+	return c.modelCluster.ID
+}
+
+//GetName returns the name of the cluster
+func (c *KubeCluster) GetName() string {
+	return c.modelCluster.Name
+}
+
+//GetType returns the cloud type of the cluster
+func (c *KubeCluster) GetType() string {
+	return c.modelCluster.Cloud
+}
+
+//GetModel returns the whole clusterModel
+func (c *KubeCluster) GetModel() *model.ClusterModel {
+	return c.modelCluster
+}
+
+//Persist save the cluster model
+func (c *KubeCluster) Persist() error {
+	return c.modelCluster.Save()
+}
+
+//DeleteFromDatabase deletes model from the database
+func (c *KubeCluster) DeleteFromDatabase() error {
+	err := c.modelCluster.Delete()
+	if err != nil {
+		return err
+	}
+	c.modelCluster = nil
+	return nil
+}
+
+//CreateCluster verifies that the imported cluster is reachable with its kubeconfig
+func (c *KubeCluster) CreateCluster() error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagCreateCluster})
+	version, err := c.serverVersion()
+	if err != nil {
+		return err
+	}
+	log.Infof("Cluster %s is reachable, Kubernetes version: %s", c.modelCluster.Name, version)
+	return nil
+}
+
+// serverVersion connects to the cluster and returns its Kubernetes version
+func (c *KubeCluster) serverVersion() (string, error) {
+	kubeConfig, err := c.GetK8sConfig()
+	if err != nil {
+		return "", err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return "", err
+	}
+	version, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("cluster %s is not reachable: %v", c.modelCluster.Name, err)
+	}
+	return version.GitVersion, nil
+}
+
+//GetK8sConfig returns the kubeconfig of the Kubernetes secret of the cluster
+func (c *KubeCluster) GetK8sConfig() (*[]byte, error) {
+	clusterSecret, err := GetSecret(c)
+	if err != nil {
+		return nil, err
+	}
+	if clusterSecret.SecretType != secret.Kubernetes {
+		return nil, fmt.Errorf("missmatch secret type %s versus %s", clusterSecret.SecretType, secret.Kubernetes)
+	}
+	config := []byte(clusterSecret.Values[secret.K8SConfig])
+	return &config, nil
+}
+
+//GetAPIEndpoint returns the host of the API server of the kubeconfig
+func (c *KubeCluster) GetAPIEndpoint() (string, error) {
+	if c.APIEndpoint != "" {
+		return c.APIEndpoint, nil
+	}
+	kubeConfig, err := c.GetK8sConfig()
+	if err != nil {
+		return "", err
+	}
+	config, err := helm.GetK8sClientConfig(kubeConfig)
+	if err != nil {
+		return "", err
+	}
+	server, err := url.Parse(config.Host)
+	if err != nil {
+		return "", err
+	}
+	c.APIEndpoint = server.Host
+	return c.APIEndpoint, nil
+}
+
+//GetStatus gets cluster status, the imported clusters are running while they are reachable
+func (c *KubeCluster) GetStatus() (*components.GetClusterStatusResponse, error) {
+	if _, err := c.serverVersion(); err != nil {
+		return nil, err
+	}
+	return &components.GetClusterStatusResponse{
+		Status:     http.StatusOK,
+		Name:       c.modelCluster.Name,
+		Cloud:      c.modelCluster.Cloud,
+		ResourceID: c.modelCluster.ID,
+	}, nil
+}
+
+//DeleteCluster doesn't touch the imported cluster, it's only removed from Pipeline
+func (c *KubeCluster) DeleteCluster() error {
+	return nil
+}
+
+//UpdateCluster isn't supported, the imported clusters are managed outside of Pipeline
+func (c *KubeCluster) UpdateCluster(*components.UpdateClusterRequest) error {
+	return errors.New("imported clusters can't be updated")
+}
+
+//AddDefaultsToUpdate adds defaults to update request
+func (c *KubeCluster) AddDefaultsToUpdate(*components.UpdateClusterRequest) {
+}
+
+//CheckEqualityToUpdate validates the update request
+func (c *KubeCluster) CheckEqualityToUpdate(*components.UpdateClusterRequest) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package cluster_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/secret"
+)
+
+const importKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+  name: imported
+contexts:
+- context:
+    cluster: imported
+    user: imported
+  name: imported
+current-context: imported
+users:
+- name: imported
+  user:
+    token: token
+`
+
+func TestKubeCluster(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"gitVersion": "v1.10.3"}`)
+	}))
+	defer server.Close()
+
+	org := strconv.FormatUint(uint64(organizationId), 10)
+	cases := []struct {
+		name        string
+		secretType  string
+		server      string
+		expectError bool
+	}{
+		{name: "reachable", secretType: secret.Kubernetes, server: server.URL},
+		{name: "unreachable", secretType: secret.Kubernetes, server: "http://127.0.0.1:1", expectError: true},
+		{name: "wrong secret type", secretType: secret.General, server: server.URL, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			secretID := secret.GenerateSecretID()
+			err := secret.Store.Store(org, secretID, secret.CreateSecretRequest{
+				Name:       tc.name,
+				SecretType: tc.secretType,
+				Values:     map[string]string{secret.K8SConfig: fmt.Sprintf(importKubeconfig, tc.server)},
+			})
+			if err != nil {
+				t.Fatalf("Error during storing secret: %s", err.Error())
+			}
+
+			kubeCluster := cluster.CreateKubernetesClusterFromRequest(tc.name, secretID, organizationId)
+			err = kubeCluster.CreateCluster()
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during CreateCluster: %s", err.Error())
+			}
+
+			endpoint, err := kubeCluster.GetAPIEndpoint()
+			if err != nil {
+				t.Fatalf("Error during GetAPIEndpoint: %s", err.Error())
+			}
+			serverURL, _ := url.Parse(server.URL)
+			if endpoint != serverURL.Host {
+				t.Errorf("Expected endpoint: %s, got: %s", serverURL.Host, endpoint)
+			}
+		})
+	}
+}
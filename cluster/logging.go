@@ -0,0 +1,275 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Outputs of the logging add-on
+const (
+	LoggingOutputS3            = "s3"
+	LoggingOutputElasticsearch = "elasticsearch"
+	LoggingOutputLoki          = "loki"
+)
+
+// loggingCredentials is the secret of the credentials of the output in the namespace of the logging add-on
+const loggingCredentials = "pipeline-logging-credentials"
+
+// loggingOutputSettings are the settings of the outputs, the required settings are true
+var loggingOutputSettings = map[string]map[string]bool{
+	LoggingOutputS3:            {"bucket": true, "region": true, "prefix": false},
+	LoggingOutputElasticsearch: {"host": true, "port": false, "index": false, "tls": false},
+	LoggingOutputLoki:          {"host": true, "port": false, "tls": false},
+}
+
+// loggingSecretTypes are the secret types of the credentials of the outputs
+var loggingSecretTypes = map[string]string{
+	LoggingOutputS3:            secret.Amazon,
+	LoggingOutputElasticsearch: secret.Password,
+	LoggingOutputLoki:          secret.Password,
+}
+
+//LoggingOutput describes where the logging add-on ships the logs of the cluster: an S3 bucket with an Amazon secret,
+//or an Elasticsearch or a Loki server with an optional password secret
+type LoggingOutput struct {
+	Type     string            `json:"type" binding:"required"`
+	SecretID string            `json:"secretId,omitempty"`
+	Settings map[string]string `json:"settings" binding:"required"`
+}
+
+//ValidateLoggingOutput checks the settings of the type of the output, the S3 output requires a secret
+func ValidateLoggingOutput(output LoggingOutput) error {
+	known, ok := loggingOutputSettings[output.Type]
+	if !ok {
+		return fmt.Errorf("the type of the output must be %s, %s or %s", LoggingOutputS3, LoggingOutputElasticsearch, LoggingOutputLoki)
+	}
+	for name, required := range known {
+		if required && output.Settings[name] == "" {
+			return fmt.Errorf("the %s output requires the %s setting", output.Type, name)
+		}
+	}
+	for name, value := range output.Settings {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown setting of the %s output: %s", output.Type, name)
+		}
+		switch name {
+		case "port":
+			if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("invalid port: %s", value)
+			}
+		case "tls":
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid tls setting: %s", value)
+			}
+		}
+	}
+	if output.Type == LoggingOutputS3 && output.SecretID == "" {
+		return fmt.Errorf("the %s output requires an Amazon secret", output.Type)
+	}
+	return nil
+}
+
+//GetLoggingOutput returns the output of the logging add-on of the cluster, it's nil if the cluster has no output
+func GetLoggingOutput(commonCluster CommonCluster) (*LoggingOutput, error) {
+	output, err := model.GetLoggingOutput(commonCluster.GetID())
+	if model.IsErrorGormNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &LoggingOutput{Type: output.Type, SecretID: output.SecretID, Settings: output.GetSettings()}, nil
+}
+
+//SetLoggingOutput saves the output of the cluster and installs the logging add-on with the output,
+//the installed add-on is reconfigured
+func SetLoggingOutput(commonCluster CommonCluster, output LoggingOutput) error {
+	log := logger.WithFields(logrus.Fields{"action": "SetLoggingOutput"})
+	if err := ValidateLoggingOutput(output); err != nil {
+		return err
+	}
+	if output.SecretID != "" {
+		if _, err := loggingSecret(commonCluster, output.Type, output.SecretID); err != nil {
+			return err
+		}
+	}
+	saved, err := model.GetLoggingOutput(commonCluster.GetID())
+	if model.IsErrorGormNotFound(err) {
+		saved = &model.LoggingOutputModel{ClusterModelID: commonCluster.GetID()}
+	} else if err != nil {
+		return err
+	}
+	saved.Type, saved.SecretID = output.Type, output.SecretID
+	saved.SetSettings(output.Settings)
+	if err := saved.Save(); err != nil {
+		return err
+	}
+
+	if _, err := model.GetAddon(commonCluster.GetID(), AddonLogging); model.IsErrorGormNotFound(err) {
+		_, err = InstallAddon(commonCluster, AddonLogging)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if err := prepareLogging(commonCluster); err != nil {
+		return err
+	}
+	if _, err := ReconfigureAddon(commonCluster, AddonLogging); err != nil {
+		return err
+	}
+	log.Infof("Logging output of cluster %s set to %s", commonCluster.GetName(), output.Type)
+	return nil
+}
+
+//DeleteLoggingOutput removes the logging add-on and the output of the cluster
+func DeleteLoggingOutput(commonCluster CommonCluster) error {
+	output, err := model.GetLoggingOutput(commonCluster.GetID())
+	if err != nil {
+		return err
+	}
+	if err := DeleteAddon(commonCluster, AddonLogging); err != nil && !model.IsErrorGormNotFound(err) {
+		return err
+	}
+	return output.Delete()
+}
+
+// loggingSecret returns the secret of the credentials of the output, its type must be the secret type of the output
+func loggingSecret(commonCluster CommonCluster, outputType, secretID string) (*secret.SecretsItemResponse, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), secretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the secret of the logging output")
+	}
+	if item.SecretType != loggingSecretTypes[outputType] {
+		return nil, fmt.Errorf("the secret of the %s output must be a %s, not a %s", outputType, loggingSecretTypes[outputType], item.SecretType)
+	}
+	return item, nil
+}
+
+// prepareLogging creates the secret of the credentials of the output in the namespace of the add-on, the secret
+// keys are the environment variables of fluent-bit
+func prepareLogging(commonCluster CommonCluster) error {
+	output, err := GetLoggingOutput(commonCluster)
+	if err != nil || output == nil || output.SecretID == "" {
+		return err
+	}
+	item, err := loggingSecret(commonCluster, output.Type, output.SecretID)
+	if err != nil {
+		return err
+	}
+	credentials := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: loggingCredentials, Namespace: helm.DefaultNamespace},
+		Data:       map[string][]byte{},
+	}
+	if output.Type == LoggingOutputS3 {
+		credentials.Data["AWS_ACCESS_KEY_ID"] = []byte(item.Values["AWS_ACCESS_KEY_ID"])
+		credentials.Data["AWS_SECRET_ACCESS_KEY"] = []byte(item.Values["AWS_SECRET_ACCESS_KEY"])
+	} else {
+		credentials.Data["OUTPUT_USER"] = []byte(item.Values["username"])
+		credentials.Data["OUTPUT_PASSWORD"] = []byte(item.Values["password"])
+	}
+	return errors.Wrap(applySecret(commonCluster, credentials), "error applying the credentials of the logging output")
+}
+
+// loggingValues returns the values of the fluent-bit chart, the output of the cluster replaces the output of the
+// chart with the credentials of the output in the environment
+func loggingValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	values := map[string]interface{}{"rbac": map[string]interface{}{"create": true}}
+	output, err := GetLoggingOutput(commonCluster)
+	if err != nil || output == nil {
+		return values, err
+	}
+	env := []interface{}{}
+	if output.SecretID != "" {
+		keys := []string{"OUTPUT_USER", "OUTPUT_PASSWORD"}
+		if output.Type == LoggingOutputS3 {
+			keys = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+		}
+		for _, key := range keys {
+			env = append(env, map[string]interface{}{
+				"name": key,
+				"valueFrom": map[string]interface{}{
+					"secretKeyRef": map[string]interface{}{"name": loggingCredentials, "key": key},
+				},
+			})
+		}
+	}
+	values["image"] = map[string]interface{}{
+		"fluent_bit": map[string]interface{}{"tag": viper.GetString("addons.logging.fluentBitVersion")},
+	}
+	values["env"] = env
+	values["rawConfig"] = "@INCLUDE fluent-bit-service.conf\n@INCLUDE fluent-bit-input.conf\n@INCLUDE fluent-bit-filter.conf\n" +
+		FluentBitOutput(commonCluster.GetName(), *output)
+	return values, nil
+}
+
+//FluentBitOutput returns the fluent-bit output section of the output, the credentials are read from the
+//environment of fluent-bit
+func FluentBitOutput(clusterName string, output LoggingOutput) string {
+	settings := map[string]string{"Match": "*"}
+	port := func(port string) string {
+		if output.Settings["port"] != "" {
+			return output.Settings["port"]
+		}
+		return port
+	}
+	switch output.Type {
+	case LoggingOutputS3:
+		prefix := strings.Trim(output.Settings["prefix"], "/")
+		if prefix == "" {
+			prefix = clusterName
+		}
+		settings["Name"] = "s3"
+		settings["bucket"] = output.Settings["bucket"]
+		settings["region"] = output.Settings["region"]
+		settings["total_file_size"] = "50M"
+		settings["upload_timeout"] = "10m"
+		settings["s3_key_format"] = "/" + prefix + "/$TAG/%Y/%m/%d/%H_%M_%S"
+	case LoggingOutputElasticsearch:
+		index := output.Settings["index"]
+		if index == "" {
+			index = clusterName
+		}
+		settings["Name"] = "es"
+		settings["Host"] = output.Settings["host"]
+		settings["Port"] = port("9200")
+		settings["Logstash_Format"] = "On"
+		settings["Logstash_Prefix"] = index
+		settings["Replace_Dots"] = "On"
+		settings["Retry_Limit"] = "False"
+	case LoggingOutputLoki:
+		settings["Name"] = "loki"
+		settings["Host"] = output.Settings["host"]
+		settings["Port"] = port("3100")
+		settings["Labels"] = "job=fluent-bit, cluster=" + clusterName
+	}
+	if output.Type != LoggingOutputS3 {
+		if tls, _ := strconv.ParseBool(output.Settings["tls"]); tls {
+			settings["tls"] = "On"
+		}
+		if output.SecretID != "" {
+			settings["HTTP_User"] = "${OUTPUT_USER}"
+			settings["HTTP_Passwd"] = "${OUTPUT_PASSWORD}"
+		}
+	}
+
+	names := []string{}
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{"[OUTPUT]"}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("    %s %s", name, settings[name]))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
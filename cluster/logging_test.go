@@ -0,0 +1,75 @@
+package cluster_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestValidateLoggingOutput(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		output  cluster.LoggingOutput
+		isError bool
+	}{
+		{name: "s3", output: cluster.LoggingOutput{Type: cluster.LoggingOutputS3, SecretID: "secret", Settings: map[string]string{"bucket": "logs", "region": "eu-west-1"}}, isError: false},
+		{name: "elasticsearch", output: cluster.LoggingOutput{Type: cluster.LoggingOutputElasticsearch, Settings: map[string]string{"host": "es.example.com", "port": "9243", "tls": "true"}}, isError: false},
+		{name: "loki", output: cluster.LoggingOutput{Type: cluster.LoggingOutputLoki, Settings: map[string]string{"host": "loki.example.com"}}, isError: false},
+		{name: "s3 without secret", output: cluster.LoggingOutput{Type: cluster.LoggingOutputS3, Settings: map[string]string{"bucket": "logs", "region": "eu-west-1"}}, isError: true},
+		{name: "missing setting", output: cluster.LoggingOutput{Type: cluster.LoggingOutputLoki, Settings: map[string]string{"port": "3100"}}, isError: true},
+		{name: "unknown setting", output: cluster.LoggingOutput{Type: cluster.LoggingOutputLoki, Settings: map[string]string{"host": "loki", "index": "logs"}}, isError: true},
+		{name: "invalid port", output: cluster.LoggingOutput{Type: cluster.LoggingOutputElasticsearch, Settings: map[string]string{"host": "es", "port": "99999"}}, isError: true},
+		{name: "unknown type", output: cluster.LoggingOutput{Type: "kafka", Settings: map[string]string{}}, isError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateLoggingOutput(tc.output)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+			} else if err != nil {
+				t.Errorf("Error during ValidateLoggingOutput: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestFluentBitOutput(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		output   cluster.LoggingOutput
+		expected []string
+	}{
+		{
+			name:     "s3 default prefix",
+			output:   cluster.LoggingOutput{Type: cluster.LoggingOutputS3, SecretID: "secret", Settings: map[string]string{"bucket": "logs", "region": "eu-west-1"}},
+			expected: []string{"[OUTPUT]", "    Name s3", "    bucket logs", "    s3_key_format /web/$TAG/%Y/%m/%d/%H_%M_%S"},
+		},
+		{
+			name:     "elasticsearch with credentials",
+			output:   cluster.LoggingOutput{Type: cluster.LoggingOutputElasticsearch, SecretID: "secret", Settings: map[string]string{"host": "es", "tls": "true"}},
+			expected: []string{"    Name es", "    Port 9200", "    Logstash_Prefix web", "    tls On", "    HTTP_Passwd ${OUTPUT_PASSWORD}"},
+		},
+		{
+			name:     "loki",
+			output:   cluster.LoggingOutput{Type: cluster.LoggingOutputLoki, Settings: map[string]string{"host": "loki", "port": "3101"}},
+			expected: []string{"    Name loki", "    Port 3101", "    Labels job=fluent-bit, cluster=web"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := cluster.FluentBitOutput("web", tc.output)
+			for _, line := range tc.expected {
+				if !strings.Contains(config, line+"\n") {
+					t.Errorf("Expected line %q in output:\n%s", line, config)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+)
+
+// maxMaintenanceWindow is the longest maintenance window, the next window is searched for a year
+const (
+	maxMaintenanceWindow = 7 * 24 * time.Hour
+	maintenanceLookahead = 366 * 24 * time.Hour
+)
+
+// parseMaintenanceWindow parses the start, the duration and the time zone of the window
+func parseMaintenanceWindow(window model.MaintenanceWindowModel) (*cronSchedule, time.Duration, *time.Location, error) {
+	start, err := parseCron(window.Start)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("start: %s", err.Error())
+	}
+	duration, err := time.ParseDuration(window.Duration)
+	if err != nil || duration < time.Minute || duration > maxMaintenanceWindow {
+		return nil, 0, nil, fmt.Errorf("invalid duration: %q, it must be between 1m and %s", window.Duration, maxMaintenanceWindow)
+	}
+	location, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("invalid time zone: %s", window.TimeZone)
+	}
+	return start, duration, location, nil
+}
+
+//ValidateMaintenanceWindow checks the start, the duration and the time zone of the window and that the window
+//opens within a year
+func ValidateMaintenanceWindow(window model.MaintenanceWindowModel) error {
+	_, err := NextMaintenanceWindow(window, time.Now())
+	return err
+}
+
+//InMaintenanceWindow tells whether the window is open at the time, it's open from the minutes its start matches
+//for its duration
+func InMaintenanceWindow(window model.MaintenanceWindowModel, t time.Time) (bool, error) {
+	start, duration, location, err := parseMaintenanceWindow(window)
+	if err != nil {
+		return false, err
+	}
+	t = t.In(location).Truncate(time.Minute)
+	for s := t; t.Sub(s) < duration; s = s.Add(-time.Minute) {
+		if start.matches(s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//NextMaintenanceWindow returns the time the window is open next: the time itself if the window is open then,
+//the next start of the window otherwise
+func NextMaintenanceWindow(window model.MaintenanceWindowModel, t time.Time) (time.Time, error) {
+	open, err := InMaintenanceWindow(window, t)
+	if err != nil || open {
+		return t, err
+	}
+	start, _, location, _ := parseMaintenanceWindow(window)
+	for s := t.In(location).Truncate(time.Minute).Add(time.Minute); s.Sub(t) <= maintenanceLookahead; s = s.Add(time.Minute) {
+		if start.matches(s) {
+			return s, nil
+		}
+	}
+	return t, errors.New("the maintenance window doesn't open within a year")
+}
@@ -0,0 +1,62 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+
+	saturday := model.MaintenanceWindowModel{Start: "0 2 * * 6", Duration: "4h", TimeZone: "Europe/Budapest"}
+
+	cases := []struct {
+		name        string
+		window      model.MaintenanceWindowModel
+		time        string
+		open        bool
+		next        string
+		expectError bool
+	}{
+		{name: "opening", window: saturday, time: "2026-10-17T00:00:00Z", open: true, next: "2026-10-17T00:00:00Z"},
+		{name: "last minute", window: saturday, time: "2026-10-17T03:59:30Z", open: true, next: "2026-10-17T03:59:30Z"},
+		{name: "closed", window: saturday, time: "2026-10-17T04:00:00Z", next: "2026-10-24T00:00:00Z"},
+		{name: "before", window: saturday, time: "2026-10-14T12:30:00Z", next: "2026-10-17T00:00:00Z"},
+		{name: "overnight utc", window: model.MaintenanceWindowModel{Start: "0 22 * * *", Duration: "6h"}, time: "2026-10-15T03:00:00Z", open: true, next: "2026-10-15T03:00:00Z"},
+		{name: "never opens", window: model.MaintenanceWindowModel{Start: "0 0 30 2 *", Duration: "1h"}, time: "2026-10-14T12:00:00Z", expectError: true},
+		{name: "too long", window: model.MaintenanceWindowModel{Start: "0 2 * * 6", Duration: "200h"}, time: "2026-10-14T12:00:00Z", expectError: true},
+		{name: "invalid duration", window: model.MaintenanceWindowModel{Start: "0 2 * * 6", Duration: "4 hours"}, time: "2026-10-14T12:00:00Z", expectError: true},
+		{name: "invalid time zone", window: model.MaintenanceWindowModel{Start: "0 2 * * 6", Duration: "4h", TimeZone: "Mars/Olympus"}, time: "2026-10-14T12:00:00Z", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tc.time)
+			if err != nil {
+				t.Fatalf("Error parsing time: %s", err.Error())
+			}
+			next, err := cluster.NextMaintenanceWindow(tc.window, at)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during NextMaintenanceWindow: %s", err.Error())
+			}
+			if expected, _ := time.Parse(time.RFC3339, tc.next); !next.Equal(expected) {
+				t.Errorf("Expected next window: %s, got: %s", expected, next)
+			}
+			open, err := cluster.InMaintenanceWindow(tc.window, at)
+			if err != nil {
+				t.Fatalf("Error during InMaintenanceWindow: %s", err.Error())
+			}
+			if open != tc.open {
+				t.Errorf("Expected open: %v, got: %v", tc.open, open)
+			}
+		})
+	}
+}
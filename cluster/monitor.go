@@ -24,6 +24,8 @@ type PrometheusCfg struct {
 	CaFilePath   string
 	CertFilePath string
 	KeyFile      string
+	// MetricsPath is the federation endpoint of the Prometheus of the cluster in the proxy of the API server
+	MetricsPath string
 }
 
 //UpdatePrometheusConfig updates the Prometheus configuration
@@ -57,6 +59,14 @@ func UpdatePrometheusConfig() error {
 			log.Errorf("Can't fetch cluster from database: %s, err: %s", commonCluster.GetName(), err)
 			continue
 		}
+		// only the clusters with the monitoring add-on have a Prometheus to federate
+		addon, err := model.GetAddon(commonCluster.GetID(), AddonMonitoring)
+		if err != nil {
+			if !model.IsErrorGormNotFound(err) {
+				log.Errorf("Can't fetch the monitoring add-on of cluster: %s, err: %s", commonCluster.GetName(), err)
+			}
+			continue
+		}
 		kubeEndpoint, err := commonCluster.GetAPIEndpoint()
 		if err != nil {
 			log.Errorf("Cluster endpoint is not available for cluster: %s, err: %s", commonCluster.GetName(), err)
@@ -67,8 +77,9 @@ func UpdatePrometheusConfig() error {
 		basePath := prefix + "/" + commonCluster.GetName()
 
 		cfgElement := PrometheusCfg{
-			Endpoint: kubeEndpoint,
-			Name:     commonCluster.GetName(),
+			Endpoint:    kubeEndpoint,
+			Name:        commonCluster.GetName(),
+			MetricsPath: servicePath(addon.ReleaseName+"-prometheus-server") + "/federate",
 		}
 		if configMapPath == "" {
 			cfgElement.CaFilePath = basePath + "/certificate-authority-data.pem"
@@ -0,0 +1,222 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// AddonGrafana is the add-on of the dashboards of the Prometheus of the monitoring add-on
+const AddonGrafana = "grafana"
+
+const (
+	grafanaAdminUser      = "admin"
+	grafanaDatasource     = "Prometheus"
+	grafanaDashboardsPath = "/var/lib/grafana/dashboards/default"
+)
+
+//ClusterMonitoring describes the monitoring stack of a cluster, the Prometheus and the Grafana of the cluster
+//are reachable through the proxy of the API server, the Grafana admin credentials are in the password secret
+type ClusterMonitoring struct {
+	Prometheus      string `json:"prometheus"`
+	Grafana         string `json:"grafana,omitempty"`
+	GrafanaSecretID string `json:"grafanaSecretId,omitempty"`
+}
+
+//SetMonitoring enables the monitoring stack of the cluster, it's installed after the cluster is created
+func SetMonitoring(commonCluster CommonCluster, enabled bool) {
+	commonCluster.GetModel().Monitoring = enabled
+}
+
+//GetClusterMonitoring returns the monitoring stack of the cluster, it's nil if the monitoring add-on isn't installed
+func GetClusterMonitoring(commonCluster CommonCluster) (*ClusterMonitoring, error) {
+	addons, err := ListAddons(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]string{}
+	for _, addon := range addons {
+		if addon.Installed != nil {
+			installed[addon.Name] = addon.Installed.ReleaseName
+		}
+	}
+	if _, ok := installed[AddonMonitoring]; !ok {
+		return nil, nil
+	}
+	endpoint, err := commonCluster.GetAPIEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	monitoring := &ClusterMonitoring{
+		Prometheus: "https://" + endpoint + servicePath(installed[AddonMonitoring]+"-prometheus-server") + "/",
+	}
+	if releaseName, ok := installed[AddonGrafana]; ok {
+		monitoring.Grafana = "https://" + endpoint + servicePath(releaseName) + "/"
+		monitoring.GrafanaSecretID = commonCluster.GetModel().GrafanaSecretID
+	}
+	return monitoring, nil
+}
+
+//InstallMonitoringPostHook installs Grafana and the Prometheus of the monitoring add-on if the monitoring
+//stack is enabled for the cluster
+func InstallMonitoringPostHook(commonCluster CommonCluster) {
+	log := logger.WithFields(logrus.Fields{"action": "InstallMonitoring"})
+	if !commonCluster.GetModel().Monitoring {
+		return
+	}
+	if _, err := InstallAddon(commonCluster, AddonGrafana); err != nil {
+		log.Errorf("Deploying the monitoring stack failed due to: %s", err.Error())
+		return
+	}
+	log.Info("Monitoring stack installed")
+}
+
+// servicePath is the path of the service of the default namespace in the proxy of the API server
+func servicePath(service string) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/services/%s:80/proxy", helm.DefaultNamespace, service)
+}
+
+// monitoringValues returns the values of the Prometheus chart with the alerting of the cluster, the cluster is
+// the external label of the metrics federated by the Prometheus of Pipeline
+func monitoringValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	values, err := alertingValues(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	values["rbac"] = map[string]interface{}{"create": true}
+	values["server"] = map[string]interface{}{
+		"global": map[string]interface{}{
+			"external_labels": map[string]interface{}{
+				"cluster":      commonCluster.GetName(),
+				"organization": strconv.FormatUint(uint64(commonCluster.GetOrg()), 10),
+			},
+		},
+	}
+	return values, nil
+}
+
+// configureMonitoring adds the Prometheus of the cluster to the federation of the Prometheus of Pipeline
+func configureMonitoring(commonCluster CommonCluster) error {
+	commonCluster.GetModel().Monitoring = true
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+	UpdatePrometheus()
+	return nil
+}
+
+// disableMonitoring removes the Prometheus of the cluster from the federation
+func disableMonitoring(commonCluster CommonCluster) error {
+	commonCluster.GetModel().Monitoring = false
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+	UpdatePrometheus()
+	return nil
+}
+
+// prepareGrafana generates the admin password of Grafana into a password secret of the organization,
+// the secret is kept for the upgrades
+func prepareGrafana(commonCluster CommonCluster) error {
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.GrafanaSecretID != "" {
+		return nil
+	}
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return err
+	}
+	secretID := secret.GenerateSecretID()
+	err := secret.Store.Store(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), secretID, secret.CreateSecretRequest{
+		Name:       fmt.Sprintf("cluster-%s-grafana", commonCluster.GetName()),
+		SecretType: secret.Password,
+		Values: map[string]string{
+			"username": grafanaAdminUser,
+			"password": hex.EncodeToString(data),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "error storing the Grafana password")
+	}
+	modelCluster.GrafanaSecretID = secretID
+	return commonCluster.Persist()
+}
+
+// disableGrafana deletes the admin password of the removed Grafana
+func disableGrafana(commonCluster CommonCluster) error {
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.GrafanaSecretID == "" {
+		return nil
+	}
+	err := secret.Store.Delete(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), modelCluster.GrafanaSecretID)
+	if err != nil && err != secret.ErrSecretNotFound {
+		return err
+	}
+	modelCluster.GrafanaSecretID = ""
+	return commonCluster.Persist()
+}
+
+// grafanaValues returns the values of the Grafana chart: the Prometheus of the monitoring add-on is the default
+// datasource and the dashboards of the config are downloaded from grafana.com
+func grafanaValues(commonCluster CommonCluster) (map[string]interface{}, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), commonCluster.GetModel().GrafanaSecretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the Grafana password")
+	}
+	dashboards := map[string]interface{}{}
+	for name, id := range viper.GetStringMap("monitoring.dashboards") {
+		gnetID, err := strconv.Atoi(strings.TrimSpace(fmt.Sprint(id)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid grafana.com ID of dashboard %s: %v", name, id)
+		}
+		dashboards[name] = map[string]interface{}{"gnetId": gnetID, "datasource": grafanaDatasource}
+	}
+	return map[string]interface{}{
+		"rbac":          map[string]interface{}{"create": true},
+		"adminUser":     item.Values["username"],
+		"adminPassword": item.Values["password"],
+		"datasources": map[string]interface{}{
+			"datasources.yaml": map[string]interface{}{
+				"apiVersion": 1,
+				"datasources": []interface{}{
+					map[string]interface{}{
+						"name":      grafanaDatasource,
+						"type":      "prometheus",
+						"url":       fmt.Sprintf("http://%s-prometheus-server.%s.svc", monitoringReleaseName(), helm.DefaultNamespace),
+						"access":    "proxy",
+						"isDefault": true,
+					},
+				},
+			},
+		},
+		"dashboardProviders": map[string]interface{}{
+			"dashboardproviders.yaml": map[string]interface{}{
+				"apiVersion": 1,
+				"providers": []interface{}{
+					map[string]interface{}{
+						"name":    "default",
+						"orgId":   1,
+						"folder":  "",
+						"type":    "file",
+						"options": map[string]interface{}{"path": grafanaDashboardsPath},
+					},
+				},
+			},
+		},
+		"dashboards": map[string]interface{}{"default": dashboards},
+	}, nil
+}
+
+// monitoringReleaseName returns the release name of the monitoring add-on
+func monitoringReleaseName() string {
+	addon, _ := GetCatalogAddon(AddonMonitoring)
+	return addon.ReleaseName
+}
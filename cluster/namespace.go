@@ -0,0 +1,494 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	namespaceManagedLabel   = "pipeline.banzaicloud.com/managed"
+	namespaceTeamLabel      = "pipeline.banzaicloud.com/team-id"
+	namespaceTeamAnnotation = "pipeline.banzaicloud.com/team"
+	namespaceQuotaName      = "pipeline-quota"
+	namespaceLimitRangeName = "pipeline-limits"
+	namespaceBindingPrefix  = "pipeline-team-"
+)
+
+// protectedNamespaces are the namespaces of the cluster which can't be deleted
+var protectedNamespaces = map[string]bool{
+	metav1.NamespaceDefault: true,
+	metav1.NamespaceSystem:  true,
+	metav1.NamespacePublic:  true,
+}
+
+// namespaceClusterRoles are the cluster roles the teams can be bound to in the namespaces
+var namespaceClusterRoles = map[string]bool{
+	"admin": true,
+	"edit":  true,
+	"view":  true,
+}
+
+//NamespaceLimits are the default requests and limits, and the minimum and maximum resources of the containers
+//of a namespace
+type NamespaceLimits struct {
+	Default        map[string]string `json:"default,omitempty"`
+	DefaultRequest map[string]string `json:"defaultRequest,omitempty"`
+	Max            map[string]string `json:"max,omitempty"`
+	Min            map[string]string `json:"min,omitempty"`
+}
+
+//NamespaceTeam binds the Kubernetes group of a Pipeline team to the cluster role in a namespace
+type NamespaceTeam struct {
+	TeamID      uint   `json:"teamId"`
+	Team        string `json:"team"`
+	Group       string `json:"group"`
+	ClusterRole string `json:"clusterRole"`
+}
+
+//Namespace describes a namespace of a cluster, the quota, the limits and the team bindings are only managed in
+//the namespaces created by Pipeline
+type Namespace struct {
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Status  string            `json:"status,omitempty"`
+	Managed bool              `json:"managed"`
+	Quota   map[string]string `json:"quota,omitempty"`
+	Limits  *NamespaceLimits  `json:"limits,omitempty"`
+	Teams   []NamespaceTeam   `json:"teams,omitempty"`
+}
+
+//NamespaceInUseError is returned when a namespace with releases deployed by Pipeline is deleted
+type NamespaceInUseError struct {
+	Name     string
+	Releases []string
+}
+
+func (e *NamespaceInUseError) Error() string {
+	return fmt.Sprintf("namespace %s has Pipeline releases: %s", e.Name, strings.Join(e.Releases, ", "))
+}
+
+//NamespaceTemplate returns the quota and the limits of the template of the namespaces section of the config
+func NamespaceTemplate(name string) (map[string]string, *NamespaceLimits, error) {
+	key := "namespaces.templates." + name
+	if !viper.IsSet(key) {
+		return nil, nil, fmt.Errorf("namespace template %s not found", name)
+	}
+	quota := viper.GetStringMapString(key + ".quota")
+	limits := &NamespaceLimits{
+		Default:        viper.GetStringMapString(key + ".limits.default"),
+		DefaultRequest: viper.GetStringMapString(key + ".limits.defaultRequest"),
+		Max:            viper.GetStringMapString(key + ".limits.max"),
+		Min:            viper.GetStringMapString(key + ".limits.min"),
+	}
+	if len(limits.Default)+len(limits.DefaultRequest)+len(limits.Max)+len(limits.Min) == 0 {
+		limits = nil
+	}
+	return quota, limits, nil
+}
+
+//TeamGroup returns the Kubernetes group of the team of the organization, the authentication of the cluster
+//has to put the members of the team into the group
+func TeamGroup(organization, team string) string {
+	return viper.GetString("namespaces.teamGroupPrefix") + organization + ":" + team
+}
+
+//ValidateNamespace checks the name, the resource quantities and the cluster roles of the namespace
+func ValidateNamespace(namespace Namespace) error {
+	if errs := validation.IsDNS1123Label(namespace.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name %q: %s", namespace.Name, strings.Join(errs, ", "))
+	}
+	quantities := []map[string]string{namespace.Quota}
+	if namespace.Limits != nil {
+		quantities = append(quantities, namespace.Limits.Default, namespace.Limits.DefaultRequest, namespace.Limits.Max, namespace.Limits.Min)
+	}
+	for _, resources := range quantities {
+		if _, err := resourceList(resources); err != nil {
+			return err
+		}
+	}
+	for _, team := range namespace.Teams {
+		if !namespaceClusterRoles[team.ClusterRole] {
+			return fmt.Errorf("invalid cluster role %q of team %s, it must be admin, edit or view", team.ClusterRole, team.Team)
+		}
+	}
+	return nil
+}
+
+//ListNamespaces lists the namespaces of the cluster ordered by their names
+func ListNamespaces(commonCluster CommonCluster) ([]Namespace, error) {
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	namespaces := []Namespace{}
+	for _, item := range list.Items {
+		namespace, err := namespaceDetails(client, &item)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, *namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+	return namespaces, nil
+}
+
+//GetNamespace returns the namespace of the cluster with its quota, limits and team bindings
+func GetNamespace(commonCluster CommonCluster, name string) (*Namespace, error) {
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	item, err := client.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return namespaceDetails(client, item)
+}
+
+//CreateNamespace creates the namespace labeled as managed by Pipeline, then its quota, limits and team bindings
+func CreateNamespace(commonCluster CommonCluster, namespace *Namespace) error {
+	log := logger.WithFields(logrus.Fields{"action": "CreateNamespace"})
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Namespaces().Create(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace.Name, Labels: namespaceLabels(namespace.Labels)},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error creating namespace %s", namespace.Name)
+	}
+	if err := applyNamespacePolicies(client, namespace); err != nil {
+		return err
+	}
+	namespace.Managed = true
+	log.Infof("Namespace %s of cluster %s created", namespace.Name, commonCluster.GetName())
+	return nil
+}
+
+//UpdateNamespace replaces the labels, the quota, the limits and the team bindings of a namespace managed
+//by Pipeline
+func UpdateNamespace(commonCluster CommonCluster, namespace *Namespace) error {
+	log := logger.WithFields(logrus.Fields{"action": "UpdateNamespace"})
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return err
+	}
+	item, err := client.CoreV1().Namespaces().Get(namespace.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if item.Labels[namespaceManagedLabel] != "true" {
+		return fmt.Errorf("namespace %s isn't managed by Pipeline", namespace.Name)
+	}
+	item.Labels = namespaceLabels(namespace.Labels)
+	if _, err := client.CoreV1().Namespaces().Update(item); err != nil {
+		return errors.Wrapf(err, "error updating namespace %s", namespace.Name)
+	}
+	if err := applyNamespacePolicies(client, namespace); err != nil {
+		return err
+	}
+	namespace.Managed = true
+	log.Infof("Namespace %s of cluster %s updated", namespace.Name, commonCluster.GetName())
+	return nil
+}
+
+//DeleteNamespace deletes the namespace with its resources, the system namespaces and the namespaces of the
+//releases deployed by Pipeline are protected
+func DeleteNamespace(commonCluster CommonCluster, name string) error {
+	log := logger.WithFields(logrus.Fields{"action": "DeleteNamespace"})
+	if protectedNamespaces[name] {
+		return fmt.Errorf("namespace %s can't be deleted", name)
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	if _, err := client.CoreV1().Namespaces().Get(name, metav1.GetOptions{}); err != nil {
+		return err
+	}
+	releases, err := namespaceReleases(commonCluster, kubeConfig, name)
+	if err != nil {
+		return err
+	}
+	if len(releases) > 0 {
+		return &NamespaceInUseError{Name: name, Releases: releases}
+	}
+	if err := client.CoreV1().Namespaces().Delete(name, &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "error deleting namespace %s", name)
+	}
+	log.Infof("Namespace %s of cluster %s deleted", name, commonCluster.GetName())
+	return nil
+}
+
+// namespaceReleases returns the releases of the namespace deployed by Pipeline
+func namespaceReleases(commonCluster CommonCluster, kubeConfig *[]byte, name string) ([]string, error) {
+	deployments, err := model.ListDeployments(commonCluster.GetID())
+	if err != nil {
+		return nil, err
+	}
+	managed := map[string]bool{}
+	for _, deployment := range deployments {
+		managed[deployment.ReleaseName] = true
+	}
+	releases := []string{}
+	if len(managed) == 0 {
+		return releases, nil
+	}
+	list, err := helm.ListDeployments(nil, kubeConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the releases of the cluster")
+	}
+	for _, release := range list.GetReleases() {
+		if release.Namespace == name && managed[release.Name] {
+			releases = append(releases, release.Name)
+		}
+	}
+	return releases, nil
+}
+
+func namespaceClient(commonCluster CommonCluster) (*kubernetes.Clientset, error) {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	return helm.GetK8sConnection(kubeConfig)
+}
+
+// applySecret creates the secret in the cluster, the data of an existing secret is replaced
+func applySecret(commonCluster CommonCluster, item *v1.Secret) error {
+	client, err := namespaceClient(commonCluster)
+	if err != nil {
+		return err
+	}
+	secrets := client.CoreV1().Secrets(item.Namespace)
+	live, err := secrets.Get(item.Name, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		_, err = secrets.Create(item)
+	} else if err == nil {
+		live.Data = item.Data
+		_, err = secrets.Update(live)
+	}
+	return err
+}
+
+// namespaceLabels adds the managed label to the labels of the request
+func namespaceLabels(labels map[string]string) map[string]string {
+	result := map[string]string{}
+	for key, value := range labels {
+		result[key] = value
+	}
+	result[namespaceManagedLabel] = "true"
+	return result
+}
+
+// namespaceDetails reads the quota, the limits and the team bindings of a namespace managed by Pipeline
+func namespaceDetails(client *kubernetes.Clientset, item *v1.Namespace) (*Namespace, error) {
+	namespace := &Namespace{
+		Name:    item.Name,
+		Labels:  item.Labels,
+		Status:  string(item.Status.Phase),
+		Managed: item.Labels[namespaceManagedLabel] == "true",
+	}
+	if !namespace.Managed {
+		return namespace, nil
+	}
+
+	quota, err := client.CoreV1().ResourceQuotas(item.Name).Get(namespaceQuotaName, metav1.GetOptions{})
+	if err == nil {
+		namespace.Quota = quantityMap(quota.Spec.Hard)
+	} else if !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+	limitRange, err := client.CoreV1().LimitRanges(item.Name).Get(namespaceLimitRangeName, metav1.GetOptions{})
+	if err == nil && len(limitRange.Spec.Limits) > 0 {
+		limits := limitRange.Spec.Limits[0]
+		namespace.Limits = &NamespaceLimits{
+			Default:        quantityMap(limits.Default),
+			DefaultRequest: quantityMap(limits.DefaultRequest),
+			Max:            quantityMap(limits.Max),
+			Min:            quantityMap(limits.Min),
+		}
+	} else if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+	bindings, err := client.RbacV1().RoleBindings(item.Name).List(metav1.ListOptions{LabelSelector: namespaceManagedLabel + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range bindings.Items {
+		for _, subject := range binding.Subjects {
+			teamID, _ := strconv.ParseUint(binding.Labels[namespaceTeamLabel], 10, 32)
+			namespace.Teams = append(namespace.Teams, NamespaceTeam{
+				TeamID:      uint(teamID),
+				Team:        binding.Annotations[namespaceTeamAnnotation],
+				Group:       subject.Name,
+				ClusterRole: binding.RoleRef.Name,
+			})
+		}
+	}
+	return namespace, nil
+}
+
+// applyNamespacePolicies creates, updates or deletes the quota, the limit range and the team bindings of the
+// namespace to match the namespace
+func applyNamespacePolicies(client *kubernetes.Clientset, namespace *Namespace) error {
+	quotas := client.CoreV1().ResourceQuotas(namespace.Name)
+	if len(namespace.Quota) == 0 {
+		if err := quotas.Delete(namespaceQuotaName, &metav1.DeleteOptions{}); err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "error deleting the resource quota")
+		}
+	} else {
+		hard, err := resourceList(namespace.Quota)
+		if err != nil {
+			return err
+		}
+		quota := &v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceQuotaName, Labels: namespaceLabels(nil)},
+			Spec:       v1.ResourceQuotaSpec{Hard: hard},
+		}
+		live, err := quotas.Get(namespaceQuotaName, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			_, err = quotas.Create(quota)
+		} else if err == nil {
+			live.Spec = quota.Spec
+			_, err = quotas.Update(live)
+		}
+		if err != nil {
+			return errors.Wrap(err, "error applying the resource quota")
+		}
+	}
+
+	limitRanges := client.CoreV1().LimitRanges(namespace.Name)
+	if namespace.Limits == nil {
+		if err := limitRanges.Delete(namespaceLimitRangeName, &metav1.DeleteOptions{}); err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "error deleting the limit range")
+		}
+	} else {
+		item := v1.LimitRangeItem{Type: v1.LimitTypeContainer}
+		var err error
+		if item.Default, err = resourceList(namespace.Limits.Default); err != nil {
+			return err
+		}
+		if item.DefaultRequest, err = resourceList(namespace.Limits.DefaultRequest); err != nil {
+			return err
+		}
+		if item.Max, err = resourceList(namespace.Limits.Max); err != nil {
+			return err
+		}
+		if item.Min, err = resourceList(namespace.Limits.Min); err != nil {
+			return err
+		}
+		limitRange := &v1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceLimitRangeName, Labels: namespaceLabels(nil)},
+			Spec:       v1.LimitRangeSpec{Limits: []v1.LimitRangeItem{item}},
+		}
+		live, err := limitRanges.Get(namespaceLimitRangeName, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			_, err = limitRanges.Create(limitRange)
+		} else if err == nil {
+			live.Spec = limitRange.Spec
+			_, err = limitRanges.Update(live)
+		}
+		if err != nil {
+			return errors.Wrap(err, "error applying the limit range")
+		}
+	}
+
+	return applyTeamBindings(client, namespace)
+}
+
+// applyTeamBindings replaces the role bindings of the teams created by Pipeline in the namespace
+func applyTeamBindings(client *kubernetes.Clientset, namespace *Namespace) error {
+	bindings := client.RbacV1().RoleBindings(namespace.Name)
+	desired := map[string]*rbacv1.RoleBinding{}
+	for _, team := range namespace.Teams {
+		teamID := strconv.FormatUint(uint64(team.TeamID), 10)
+		name := namespaceBindingPrefix + teamID
+		desired[name] = &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      namespaceLabels(map[string]string{namespaceTeamLabel: teamID}),
+				Annotations: map[string]string{namespaceTeamAnnotation: team.Team},
+			},
+			RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: team.ClusterRole},
+			Subjects: []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: team.Group}},
+		}
+	}
+	live, err := bindings.List(metav1.ListOptions{LabelSelector: namespaceManagedLabel + "=true"})
+	if err != nil {
+		return err
+	}
+	for _, binding := range live.Items {
+		// the role of a binding can't be changed, so the changed bindings are recreated
+		if want, ok := desired[binding.Name]; ok && want.RoleRef == binding.RoleRef {
+			binding.Subjects = want.Subjects
+			binding.Labels = want.Labels
+			binding.Annotations = want.Annotations
+			if _, err := bindings.Update(&binding); err != nil {
+				return errors.Wrapf(err, "error updating role binding %s", binding.Name)
+			}
+			delete(desired, binding.Name)
+			continue
+		}
+		if err := bindings.Delete(binding.Name, &metav1.DeleteOptions{}); err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting role binding %s", binding.Name)
+		}
+	}
+	for name, binding := range desired {
+		if _, err := bindings.Create(binding); err != nil {
+			return errors.Wrapf(err, "error creating role binding %s", name)
+		}
+	}
+	return nil
+}
+
+// resourceList parses the quantities of the resources
+func resourceList(resources map[string]string) (v1.ResourceList, error) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	list := v1.ResourceList{}
+	for name, value := range resources {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q of %s", value, name)
+		}
+		list[v1.ResourceName(name)] = quantity
+	}
+	return list, nil
+}
+
+func quantityMap(list v1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	result := map[string]string{}
+	for name, quantity := range list {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestValidateNamespace(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		namespace   cluster.Namespace
+		expectError bool
+	}{
+		{name: "name only", namespace: cluster.Namespace{Name: "team-a"}},
+		{
+			name: "quota and limits",
+			namespace: cluster.Namespace{
+				Name:   "team-a",
+				Quota:  map[string]string{"requests.cpu": "2", "requests.memory": "4Gi", "pods": "20"},
+				Limits: &cluster.NamespaceLimits{Default: map[string]string{"cpu": "500m"}, Max: map[string]string{"memory": "1Gi"}},
+			},
+		},
+		{
+			name:      "team binding",
+			namespace: cluster.Namespace{Name: "team-a", Teams: []cluster.NamespaceTeam{{TeamID: 1, Team: "devs", Group: "pipeline:org:devs", ClusterRole: "edit"}}},
+		},
+		{name: "invalid name", namespace: cluster.Namespace{Name: "Team_A"}, expectError: true},
+		{name: "invalid quota", namespace: cluster.Namespace{Name: "team-a", Quota: map[string]string{"requests.cpu": "two"}}, expectError: true},
+		{
+			name:        "invalid limit",
+			namespace:   cluster.Namespace{Name: "team-a", Limits: &cluster.NamespaceLimits{DefaultRequest: map[string]string{"memory": "1 GB"}}},
+			expectError: true,
+		},
+		{
+			name:        "unknown cluster role",
+			namespace:   cluster.Namespace{Name: "team-a", Teams: []cluster.NamespaceTeam{{TeamID: 1, Team: "devs", ClusterRole: "cluster-admin"}}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateNamespace(tc.namespace)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateNamespace: %s", err.Error())
+			}
+		})
+	}
+}
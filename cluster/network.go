@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const gkeDefaultNetwork = "default"
+
+//Network is the networking of a new cluster. VPC is the AWS VPC, the GCP network or the Azure virtual network,
+//Subnets are the subnets of the nodes (an Azure subnet is a resource ID). The API server of the clusters with
+//PrivateEndpoint is only reachable from the network, MasterCIDR is the /28 range of the private GKE control plane.
+type Network struct {
+	VPC             string   `json:"vpc,omitempty"`
+	Subnets         []string `json:"subnets,omitempty"`
+	PrivateEndpoint bool     `json:"privateEndpoint,omitempty"`
+	MasterCIDR      string   `json:"masterCidr,omitempty"`
+}
+
+//SetNetwork sets the network of a cluster before its creation, the private endpoints must be reachable by Pipeline:
+//their network has to be peered with the network of Pipeline and listed in network.reachableNetworks
+func SetNetwork(commonCluster CommonCluster, network *Network) error {
+	if network == nil {
+		return nil
+	}
+	modelCluster := commonCluster.GetModel()
+	switch commonCluster.(type) {
+	case *EKSCluster:
+		if network.MasterCIDR != "" {
+			return errors.New("masterCidr is only supported on GKE")
+		}
+		if len(network.Subnets) != 0 {
+			subnets := strings.Join(network.Subnets, ",")
+			if modelCluster.EKS.Subnets != "" && modelCluster.EKS.Subnets != subnets {
+				return errors.New("the subnets of the network and the EKS properties differ")
+			}
+			modelCluster.EKS.Subnets = subnets
+		}
+	case *GKECluster:
+		if len(network.Subnets) > 1 {
+			return errors.New("GKE clusters have a single subnetwork")
+		}
+		if network.PrivateEndpoint && network.MasterCIDR == "" {
+			return errors.New("private GKE clusters need the masterCidr of the control plane")
+		}
+		if network.MasterCIDR != "" {
+			_, cidr, err := net.ParseCIDR(network.MasterCIDR)
+			if err != nil {
+				return fmt.Errorf("invalid masterCidr %s", network.MasterCIDR)
+			}
+			if ones, _ := cidr.Mask.Size(); ones != 28 || !network.PrivateEndpoint {
+				return errors.New("masterCidr must be a /28 range of a private cluster")
+			}
+		}
+		modelCluster.Subnets = strings.Join(network.Subnets, ",")
+	case *AKSCluster:
+		if network.MasterCIDR != "" {
+			return errors.New("masterCidr is only supported on GKE")
+		}
+		if len(network.Subnets) > 1 {
+			return errors.New("AKS agent pools have a single virtual network subnet")
+		}
+		for _, subnet := range network.Subnets {
+			if !strings.Contains(subnet, "/subnets/") {
+				return fmt.Errorf("the subnet %s must be the resource ID of a virtual network subnet", subnet)
+			}
+		}
+		modelCluster.Subnets = strings.Join(network.Subnets, ",")
+	default:
+		return fmt.Errorf("custom networks are not supported on %s", commonCluster.GetType())
+	}
+	modelCluster.Network = network.VPC
+	modelCluster.PrivateEndpoint = network.PrivateEndpoint
+	modelCluster.MasterCIDR = network.MasterCIDR
+	if network.PrivateEndpoint {
+		return checkReachableNetwork(commonCluster)
+	}
+	return nil
+}
+
+// networkName returns the network of the cluster, the network of AKS subnets is in their resource ID and
+// the GKE clusters are in the default network without a network
+func networkName(commonCluster CommonCluster) string {
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.Network != "" {
+		return modelCluster.Network
+	}
+	switch commonCluster.(type) {
+	case *AKSCluster:
+		if i := strings.Index(modelCluster.Subnets, "/subnets/"); i != -1 {
+			return modelCluster.Subnets[:i]
+		}
+	case *GKECluster:
+		return gkeDefaultNetwork
+	}
+	return ""
+}
+
+// checkReachableNetwork checks that Pipeline can reach the private endpoint of the cluster in its network
+func checkReachableNetwork(commonCluster CommonCluster) error {
+	network := networkName(commonCluster)
+	if network == "" {
+		return errors.New("the vpc of the network is required for private endpoints")
+	}
+	for _, reachable := range viper.GetStringSlice("network.reachableNetworks") {
+		if strings.EqualFold(reachable, network) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Pipeline can't reach private endpoints in network %s, the network must be peered with the network of Pipeline and listed in network.reachableNetworks", network)
+}
+
+//CheckAPIEndpoint checks that Pipeline can connect to the private API endpoint of a new cluster, the post hooks
+//of the creation need the API server
+func CheckAPIEndpoint(commonCluster CommonCluster) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		return errors.Wrapf(err, "the private API endpoint isn't reachable from Pipeline in network %s", networkName(commonCluster))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/spf13/viper"
+)
+
+func TestSetNetwork(t *testing.T) {
+
+	viper.Set("network.reachableNetworks", []string{"pipeline-peered", "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/peered"})
+	defer viper.Set("network.reachableNetworks", []string{})
+
+	aksSubnet := "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/peered/subnets/nodes"
+	cases := []struct {
+		name          string
+		createRequest *components.CreateClusterRequest
+		network       *cluster.Network
+		expectError   bool
+	}{
+		{name: "no network", createRequest: gkeCreateFull},
+		{name: "gke subnetwork", createRequest: gkeCreateFull, network: &cluster.Network{VPC: "custom", Subnets: []string{"nodes"}}},
+		{name: "gke private", createRequest: gkeCreateFull, network: &cluster.Network{VPC: "pipeline-peered", PrivateEndpoint: true, MasterCIDR: "172.16.0.16/28"}},
+		{name: "gke private unreachable", createRequest: gkeCreateFull, network: &cluster.Network{PrivateEndpoint: true, MasterCIDR: "172.16.0.16/28"}, expectError: true},
+		{name: "gke private without master", createRequest: gkeCreateFull, network: &cluster.Network{VPC: "pipeline-peered", PrivateEndpoint: true}, expectError: true},
+		{name: "gke master range", createRequest: gkeCreateFull, network: &cluster.Network{VPC: "pipeline-peered", PrivateEndpoint: true, MasterCIDR: "172.16.0.0/24"}, expectError: true},
+		{name: "gke subnetworks", createRequest: gkeCreateFull, network: &cluster.Network{Subnets: []string{"a", "b"}}, expectError: true},
+		{name: "aks private", createRequest: aksCreateFull, network: &cluster.Network{Subnets: []string{aksSubnet}, PrivateEndpoint: true}},
+		{name: "aks subnet name", createRequest: aksCreateFull, network: &cluster.Network{Subnets: []string{"nodes"}}, expectError: true},
+		{name: "aks master range", createRequest: aksCreateFull, network: &cluster.Network{MasterCIDR: "172.16.0.16/28"}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(tc.createRequest, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+
+			err = cluster.SetNetwork(commonCluster, tc.network)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during SetNetwork: %s", err.Error())
+			}
+			if tc.network != nil && commonCluster.GetModel().PrivateEndpoint != tc.network.PrivateEndpoint {
+				t.Errorf("Expected private endpoint: %t", tc.network.PrivateEndpoint)
+			}
+		})
+	}
+}
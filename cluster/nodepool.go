@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+)
+
+// aksNodePoolName is the format of the AKS agent pool names
+var aksNodePoolName = regexp.MustCompile("^[a-z][a-z0-9]{0,11}$")
+
+// gkeNodePoolName is the format of the GKE node pool names
+var gkeNodePoolName = regexp.MustCompile("^[a-z]([-a-z0-9]{0,38}[a-z0-9])?$")
+
+// eksNodePoolName is the format of the EKS managed node group names
+var eksNodePoolName = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_-]{0,62}$")
+
+//NodePool describes a node pool of the create cluster request, the size of the autoscaled pools
+//is between the min and max counts
+type NodePool struct {
+	Name         string `json:"name" binding:"required"`
+	Count        int    `json:"count"`
+	InstanceType string `json:"instanceType"`
+	Autoscaling  bool   `json:"autoscaling"`
+	MinCount     int    `json:"minCount"`
+	MaxCount     int    `json:"maxCount"`
+	// Labels are the Kubernetes labels of the nodes of the pool
+	Labels map[string]string `json:"labels,omitempty"`
+	// Spot pools have spot or preemptible instances up to the max price, onDemandPercentage of their nodes are on-demand
+	Spot               bool   `json:"spot,omitempty"`
+	SpotPrice          string `json:"spotPrice,omitempty"`
+	OnDemandPercentage int    `json:"onDemandPercentage,omitempty"`
+}
+
+//ValidateNodePools validates the node pools of a cluster, the names must be unique and every pool needs a node
+func ValidateNodePools(pools []NodePool) error {
+	names := make(map[string]bool, len(pools))
+	for _, pool := range pools {
+		if pool.Name == "" {
+			return errors.New("node pool name is empty")
+		}
+		if names[pool.Name] {
+			return fmt.Errorf("duplicate node pool name: %s", pool.Name)
+		}
+		names[pool.Name] = true
+		if pool.Count < 1 {
+			return fmt.Errorf("node pool %s needs at least 1 node", pool.Name)
+		}
+		if pool.Autoscaling && (pool.MinCount < 1 || pool.MinCount > pool.Count || pool.Count > pool.MaxCount) {
+			return fmt.Errorf("node pool %s needs 1 <= minCount <= count <= maxCount", pool.Name)
+		}
+		if !pool.Spot && (pool.SpotPrice != "" || pool.OnDemandPercentage != 0) {
+			return fmt.Errorf("node pool %s has a spot price or an on-demand percentage without spot instances", pool.Name)
+		}
+		if pool.OnDemandPercentage < 0 || pool.OnDemandPercentage > 99 {
+			return fmt.Errorf("node pool %s needs 0 <= onDemandPercentage < 100", pool.Name)
+		}
+		if price, err := strconv.ParseFloat(pool.SpotPrice, 64); pool.SpotPrice != "" && (err != nil || price <= 0) {
+			return fmt.Errorf("invalid spot price of node pool %s: %s", pool.Name, pool.SpotPrice)
+		}
+	}
+	return nil
+}
+
+// nodePoolNamePattern returns the format of the node pool names of the cluster, only AKS, GKE and EKS
+// clusters support node pools yet
+func nodePoolNamePattern(commonCluster CommonCluster) (*regexp.Regexp, string, error) {
+	switch commonCluster.(type) {
+	case *AKSCluster:
+		return aksNodePoolName, "at most 12 lowercase alphanumeric characters starting with a letter", nil
+	case *GKECluster:
+		return gkeNodePoolName, "at most 40 lowercase alphanumeric characters or '-' starting with a letter", nil
+	case *EKSCluster:
+		return eksNodePoolName, "at most 63 alphanumeric characters, '-' or '_' starting with an alphanumeric character", nil
+	}
+	return nil, "", fmt.Errorf("node pools are not supported on %s", commonCluster.GetType())
+}
+
+// validateNodePool checks the name and the autoscaling of a node pool of the cluster
+func validateNodePool(commonCluster CommonCluster, pool NodePool) error {
+	namePattern, nameFormat, err := nodePoolNamePattern(commonCluster)
+	if err != nil {
+		return err
+	}
+	if !namePattern.MatchString(pool.Name) {
+		return fmt.Errorf("invalid %s node pool name %s, it must be %s", commonCluster.GetType(), pool.Name, nameFormat)
+	}
+	// the agent pools of AKS are autoscaled by the cluster-autoscaler
+	if _, ok := commonCluster.(*AKSCluster); ok && pool.Autoscaling && !commonCluster.GetModel().Autoscaler {
+		return fmt.Errorf("node pool autoscaling needs the cluster autoscaler on %s", commonCluster.GetType())
+	}
+	if !pool.Spot {
+		return nil
+	}
+	switch commonCluster.(type) {
+	case *GKECluster:
+		// the price of preemptible instances is fixed
+		if pool.SpotPrice != "" || pool.OnDemandPercentage != 0 {
+			return fmt.Errorf("preemptible node pools have neither spot price nor on-demand percentage on %s", commonCluster.GetType())
+		}
+	case *EKSCluster:
+		// the on-demand nodes are in a second node group
+		if pool.OnDemandPercentage != 0 && !namePattern.MatchString(eksOnDemandNodegroup(pool.Name)) {
+			return fmt.Errorf("invalid %s node pool name %s, it's too long for the on-demand node group", commonCluster.GetType(), pool.Name)
+		}
+	default:
+		return fmt.Errorf("spot node pools are not supported on %s", commonCluster.GetType())
+	}
+	return nil
+}
+
+// nodePoolModel returns the model of the node pool, the pools without instance type have the instance type of the cluster
+func nodePoolModel(modelCluster *model.ClusterModel, pool NodePool) model.NodePoolModel {
+	instanceType := pool.InstanceType
+	if instanceType == "" {
+		instanceType = modelCluster.NodeInstanceType
+	}
+	poolModel := model.NodePoolModel{
+		Name:               pool.Name,
+		InstanceType:       instanceType,
+		Count:              pool.Count,
+		Autoscaling:        pool.Autoscaling,
+		MinCount:           pool.MinCount,
+		MaxCount:           pool.MaxCount,
+		Spot:               pool.Spot,
+		SpotPrice:          pool.SpotPrice,
+		OnDemandPercentage: pool.OnDemandPercentage,
+	}
+	poolModel.SetLabels(pool.Labels)
+	return poolModel
+}
+
+// SetNodePools sets the node pools of a cluster before its creation, only AKS, GKE and EKS clusters support node pools yet
+func SetNodePools(commonCluster CommonCluster, pools []NodePool) error {
+	if len(pools) == 0 {
+		return nil
+	}
+	if _, _, err := nodePoolNamePattern(commonCluster); err != nil {
+		return err
+	}
+	if err := ValidateNodePools(pools); err != nil {
+		return err
+	}
+	modelCluster := commonCluster.GetModel()
+	modelCluster.NodePools = make([]model.NodePoolModel, 0, len(pools))
+	for _, pool := range pools {
+		if err := validateNodePool(commonCluster, pool); err != nil {
+			return err
+		}
+		modelCluster.NodePools = append(modelCluster.NodePools, nodePoolModel(modelCluster, pool))
+	}
+	return nil
+}
+
+// SetReleaseChannel enrolls a GKE cluster in a release channel (RAPID, REGULAR or STABLE) before its creation
+func SetReleaseChannel(commonCluster CommonCluster, channel string) error {
+	if channel == "" {
+		return nil
+	}
+	gkeCluster, ok := commonCluster.(*GKECluster)
+	if !ok {
+		return fmt.Errorf("release channels are not supported on %s", commonCluster.GetType())
+	}
+	switch channel {
+	case gkeReleaseChannelRapid, gkeReleaseChannelRegular, gkeReleaseChannelStable:
+	default:
+		return fmt.Errorf("invalid release channel %s, it must be %s, %s or %s", channel, gkeReleaseChannelRapid, gkeReleaseChannelRegular, gkeReleaseChannelStable)
+	}
+	gkeCluster.modelCluster.Google.ReleaseChannel = channel
+	return nil
+}
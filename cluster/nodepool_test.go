@@ -0,0 +1,233 @@
+package cluster_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestSetNodePools(t *testing.T) {
+
+	cases := []struct {
+		name          string
+		pools         []cluster.NodePool
+		expectedPools []model.NodePoolModel
+		expectError   bool
+	}{
+		{name: "no pools", pools: nil, expectedPools: nil},
+		{
+			name: "pools",
+			pools: []cluster.NodePool{
+				{Name: "pool1", Count: 1},
+				{Name: "pool2", Count: 3, InstanceType: "Standard_D4_v2"},
+			},
+			expectedPools: []model.NodePoolModel{
+				{Name: "pool1", Count: 1, InstanceType: clusterRequestNodeInstance},
+				{Name: "pool2", Count: 3, InstanceType: "Standard_D4_v2"},
+			},
+		},
+		{name: "duplicate name", pools: []cluster.NodePool{{Name: "pool1", Count: 1}, {Name: "pool1", Count: 2}}, expectError: true},
+		{name: "empty pool", pools: []cluster.NodePool{{Name: "pool1", Count: 0}}, expectError: true},
+		{name: "invalid AKS name", pools: []cluster.NodePool{{Name: "Pool_1", Count: 1}}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+
+			err = cluster.SetNodePools(commonCluster, tc.pools)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during SetNodePools: %s", err.Error())
+			}
+			if !reflect.DeepEqual(commonCluster.GetModel().NodePools, tc.expectedPools) {
+				t.Errorf("Expected pools: %v, got: %v", tc.expectedPools, commonCluster.GetModel().NodePools)
+			}
+		})
+	}
+
+	awsCluster, err := cluster.CreateCommonClusterFromRequest(awsCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	if err := cluster.SetNodePools(awsCluster, []cluster.NodePool{{Name: "pool1", Count: 1}}); err == nil {
+		t.Errorf("Expected error, node pools aren't supported on Amazon")
+	}
+}
+
+func TestSetGKENodePools(t *testing.T) {
+
+	cases := []struct {
+		name          string
+		pools         []cluster.NodePool
+		expectedPools []model.NodePoolModel
+		expectError   bool
+	}{
+		{
+			name:  "autoscaling",
+			pools: []cluster.NodePool{{Name: "pool-1", Count: 2, Autoscaling: true, MinCount: 1, MaxCount: 5}},
+			expectedPools: []model.NodePoolModel{
+				{Name: "pool-1", Count: 2, InstanceType: clusterRequestNodeInstance, Autoscaling: true, MinCount: 1, MaxCount: 5},
+			},
+		},
+		{name: "count out of range", pools: []cluster.NodePool{{Name: "pool-1", Count: 6, Autoscaling: true, MinCount: 1, MaxCount: 5}}, expectError: true},
+		{name: "invalid GKE name", pools: []cluster.NodePool{{Name: "pool-", Count: 1}}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(gkeCreateFull, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+
+			err = cluster.SetNodePools(commonCluster, tc.pools)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during SetNodePools: %s", err.Error())
+			}
+			if !reflect.DeepEqual(commonCluster.GetModel().NodePools, tc.expectedPools) {
+				t.Errorf("Expected pools: %v, got: %v", tc.expectedPools, commonCluster.GetModel().NodePools)
+			}
+		})
+	}
+}
+
+func TestSetReleaseChannel(t *testing.T) {
+
+	cases := []struct {
+		name          string
+		createRequest *components.CreateClusterRequest
+		channel       string
+		expectError   bool
+	}{
+		{name: "gke regular", createRequest: gkeCreateFull, channel: "REGULAR"},
+		{name: "gke invalid channel", createRequest: gkeCreateFull, channel: "NIGHTLY", expectError: true},
+		{name: "aks", createRequest: aksCreateFull, channel: "STABLE", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(tc.createRequest, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+
+			err = cluster.SetReleaseChannel(commonCluster, tc.channel)
+			if (err != nil) != tc.expectError {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if err == nil && commonCluster.GetModel().Google.ReleaseChannel != tc.channel {
+				t.Errorf("Expected release channel: %s, got: %s", tc.channel, commonCluster.GetModel().Google.ReleaseChannel)
+			}
+		})
+	}
+}
+
+func TestSetEKSNodePools(t *testing.T) {
+
+	eksCreate := &components.CreateClusterRequest{
+		Name:             clusterRequestName,
+		Location:         clusterRequestLocation,
+		Cloud:            cluster.EKS,
+		NodeInstanceType: clusterRequestNodeInstance,
+		SecretId:         clusterRequestSecretId,
+	}
+	if _, err := cluster.CreateEKSClusterFromRequest(eksCreate, nil, organizationId); err == nil {
+		t.Errorf("Expected error, the eks properties are required")
+	}
+
+	eksCluster, err := cluster.CreateEKSClusterFromRequest(eksCreate, &cluster.CreateClusterEKS{Subnets: []string{"subnet-1", "subnet-2"}}, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateEKSClusterFromRequest: %s", err.Error())
+	}
+	if subnets := eksCluster.GetModel().EKS.Subnets; subnets != "subnet-1,subnet-2" {
+		t.Errorf("Expected subnets: subnet-1,subnet-2, got: %s", subnets)
+	}
+
+	pools := []cluster.NodePool{{Name: "Pool_1", Count: 2, Autoscaling: true, MinCount: 1, MaxCount: 3}}
+	if err := cluster.SetNodePools(eksCluster, pools); err != nil {
+		t.Fatalf("Error during SetNodePools: %s", err.Error())
+	}
+	expectedPools := []model.NodePoolModel{
+		{Name: "Pool_1", Count: 2, InstanceType: clusterRequestNodeInstance, Autoscaling: true, MinCount: 1, MaxCount: 3},
+	}
+	if !reflect.DeepEqual(eksCluster.GetModel().NodePools, expectedPools) {
+		t.Errorf("Expected pools: %v, got: %v", expectedPools, eksCluster.GetModel().NodePools)
+	}
+	if err := cluster.SetNodePools(eksCluster, []cluster.NodePool{{Name: "-pool", Count: 1}}); err == nil {
+		t.Errorf("Expected error, invalid EKS node group name")
+	}
+}
+
+func TestSetSpotNodePools(t *testing.T) {
+
+	eksCreate := &components.CreateClusterRequest{
+		Name:             clusterRequestName,
+		Location:         clusterRequestLocation,
+		Cloud:            cluster.EKS,
+		NodeInstanceType: clusterRequestNodeInstance,
+		SecretId:         clusterRequestSecretId,
+	}
+	eksCluster, err := cluster.CreateEKSClusterFromRequest(eksCreate, &cluster.CreateClusterEKS{Subnets: []string{"subnet-1", "subnet-2"}}, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateEKSClusterFromRequest: %s", err.Error())
+	}
+	gkeCluster, err := cluster.CreateCommonClusterFromRequest(gkeCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	aksCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+
+	cases := []struct {
+		name          string
+		commonCluster cluster.CommonCluster
+		pool          cluster.NodePool
+		expectError   bool
+	}{
+		{name: "eks spot", commonCluster: eksCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, Spot: true, SpotPrice: "0.05", OnDemandPercentage: 20}},
+		{name: "gke preemptible", commonCluster: gkeCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, Spot: true}},
+		{name: "aks spot", commonCluster: aksCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, Spot: true}, expectError: true},
+		{name: "gke spot price", commonCluster: gkeCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, Spot: true, SpotPrice: "0.05"}, expectError: true},
+		{name: "spot price without spot", commonCluster: eksCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, SpotPrice: "0.05"}, expectError: true},
+		{name: "invalid spot price", commonCluster: eksCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, Spot: true, SpotPrice: "cheap"}, expectError: true},
+		{name: "all on-demand", commonCluster: eksCluster, pool: cluster.NodePool{Name: "pool1", Count: 3, Spot: true, OnDemandPercentage: 100}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.SetNodePools(tc.commonCluster, []cluster.NodePool{tc.pool})
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during SetNodePools: %s", err.Error())
+			}
+			if pool := tc.commonCluster.GetModel().NodePools[0]; !pool.Spot || pool.SpotPrice != tc.pool.SpotPrice || pool.OnDemandPercentage != tc.pool.OnDemandPercentage {
+				t.Errorf("Expected spot pool: %v, got: %v", tc.pool, pool)
+			}
+		})
+	}
+}
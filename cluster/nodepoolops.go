@@ -0,0 +1,373 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//NodePoolManager is implemented by the clusters whose node pools can be changed after their creation,
+//the node pools of the cluster model already contain the change when the methods are called
+type NodePoolManager interface {
+	// CreateNodePool creates the node pool in the cloud and waits for its nodes
+	CreateNodePool(pool model.NodePoolModel) error
+	// UpdateNodePool resizes and relabels the node pool, the instance type of the pool isn't changed
+	UpdateNodePool(previous, pool model.NodePoolModel) error
+	// DeleteNodePool deletes the drained node pool from the cloud
+	DeleteNodePool(pool model.NodePoolModel) error
+	// NodePoolSelector returns the Kubernetes label selector of the nodes of the node pool
+	NodePoolSelector(name string) string
+	// CheckNodeQuota checks whether the quotas of the cloud allow the given number of new nodes of the instance type
+	CheckNodeQuota(instanceType string, nodes int) error
+}
+
+//NodePoolNotFoundError is returned when the cluster doesn't have the node pool
+type NodePoolNotFoundError struct {
+	Name string
+}
+
+func (e *NodePoolNotFoundError) Error() string {
+	return fmt.Sprintf("node pool %s not found", e.Name)
+}
+
+// nodePoolManager returns the node pool manager of the cluster
+func nodePoolManager(commonCluster CommonCluster) (NodePoolManager, error) {
+	manager, ok := commonCluster.(NodePoolManager)
+	if !ok {
+		return nil, fmt.Errorf("node pools are not supported on %s", commonCluster.GetType())
+	}
+	return manager, nil
+}
+
+// findNodePool returns the index of the node pool in the node pools of the cluster
+func findNodePool(commonCluster CommonCluster, name string) (int, error) {
+	for i, pool := range commonCluster.GetModel().NodePools {
+		if pool.Name == name {
+			return i, nil
+		}
+	}
+	return -1, &NodePoolNotFoundError{Name: name}
+}
+
+// maxNodes returns the number of nodes the node pool can grow to
+func maxNodes(pool model.NodePoolModel) int {
+	if pool.Autoscaling && pool.MaxCount > pool.Count {
+		return pool.MaxCount
+	}
+	return pool.Count
+}
+
+//GetNodePools returns the node pools of the cluster
+func GetNodePools(commonCluster CommonCluster) []NodePool {
+	pools := make([]NodePool, 0, len(commonCluster.GetModel().NodePools))
+	for _, pool := range commonCluster.GetModel().NodePools {
+		pools = append(pools, NodePool{
+			Name:               pool.Name,
+			Count:              pool.Count,
+			InstanceType:       pool.InstanceType,
+			Autoscaling:        pool.Autoscaling,
+			MinCount:           pool.MinCount,
+			MaxCount:           pool.MaxCount,
+			Labels:             pool.GetLabels(),
+			Spot:               pool.Spot,
+			SpotPrice:          pool.SpotPrice,
+			OnDemandPercentage: pool.OnDemandPercentage,
+		})
+	}
+	return pools
+}
+
+//CheckAddNodePool validates a new node pool of the cluster against the existing pools and the quotas of the cloud
+func CheckAddNodePool(commonCluster CommonCluster, pool NodePool) error {
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	if err := ValidateNodePools([]NodePool{pool}); err != nil {
+		return err
+	}
+	if err := validateNodePool(commonCluster, pool); err != nil {
+		return err
+	}
+	if _, err := findNodePool(commonCluster, pool.Name); err == nil {
+		return fmt.Errorf("duplicate node pool name: %s", pool.Name)
+	}
+	poolModel := nodePoolModel(commonCluster.GetModel(), pool)
+	return manager.CheckNodeQuota(poolModel.InstanceType, maxNodes(poolModel))
+}
+
+//CheckUpdateNodePool validates the change of a node pool of the cluster, the missing instance type and labels
+//of the pool are the current ones. The pools with a new instance type or new spot properties are replaced by
+//a new pool, the quotas of the cloud must allow the nodes of both pools.
+func CheckUpdateNodePool(commonCluster CommonCluster, name string, pool *NodePool) error {
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	i, err := findNodePool(commonCluster, name)
+	if err != nil {
+		return err
+	}
+	previous := commonCluster.GetModel().NodePools[i]
+	if pool.Name == "" {
+		pool.Name = name
+	}
+	if pool.Name != name {
+		return fmt.Errorf("node pool %s can't be renamed", name)
+	}
+	if pool.InstanceType == "" {
+		pool.InstanceType = previous.InstanceType
+	}
+	if pool.Labels == nil {
+		pool.Labels = previous.GetLabels()
+	}
+	if err := ValidateNodePools([]NodePool{*pool}); err != nil {
+		return err
+	}
+	if err := validateNodePool(commonCluster, *pool); err != nil {
+		return err
+	}
+	poolModel := nodePoolModel(commonCluster.GetModel(), *pool)
+	nodes := maxNodes(poolModel)
+	if !needsReplacement(previous, poolModel) {
+		nodes -= maxNodes(previous)
+	}
+	if nodes <= 0 {
+		return nil
+	}
+	return manager.CheckNodeQuota(poolModel.InstanceType, nodes)
+}
+
+//CheckDeleteNodePool checks whether the node pool of the cluster can be deleted, the last pool of a cluster can't be
+func CheckDeleteNodePool(commonCluster CommonCluster, name string) error {
+	if _, err := nodePoolManager(commonCluster); err != nil {
+		return err
+	}
+	if _, err := findNodePool(commonCluster, name); err != nil {
+		return err
+	}
+	if len(commonCluster.GetModel().NodePools) == 1 {
+		return fmt.Errorf("node pool %s is the last node pool of the cluster", name)
+	}
+	return nil
+}
+
+//AddNodePool creates the node pool checked by CheckAddNodePool and saves it to the database
+func AddNodePool(commonCluster CommonCluster, pool NodePool) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	modelCluster := commonCluster.GetModel()
+	poolModel := nodePoolModel(modelCluster, pool)
+	modelCluster.NodePools = append(modelCluster.NodePools, poolModel)
+	err = RunStep(commonCluster, "CreateNodePool", func() error {
+		return manager.CreateNodePool(poolModel)
+	})
+	if err != nil {
+		modelCluster.NodePools = modelCluster.NodePools[:len(modelCluster.NodePools)-1]
+		return err
+	}
+	log.Infof("Node pool %s created", pool.Name)
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+	if err := deploySpotTerminationHandler(commonCluster, poolModel); err != nil {
+		return err
+	}
+	return ConfigureClusterAutoscaler(commonCluster)
+}
+
+//UpdateNodePool resizes and relabels the node pool checked by CheckUpdateNodePool, the pools with a new instance
+//type are replaced: the new pool is created, the nodes of the old pool are drained and the old pool is deleted
+func UpdateNodePool(commonCluster CommonCluster, name string, pool NodePool) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	i, err := findNodePool(commonCluster, name)
+	if err != nil {
+		return err
+	}
+	modelCluster := commonCluster.GetModel()
+	previous := modelCluster.NodePools[i]
+	poolModel := nodePoolModel(modelCluster, pool)
+	if needsReplacement(previous, poolModel) {
+		return replaceNodePool(commonCluster, manager, previous, poolModel)
+	}
+
+	poolModel.ID = previous.ID
+	poolModel.CreatedAt = previous.CreatedAt
+	poolModel.ClusterModelID = previous.ClusterModelID
+	modelCluster.NodePools[i] = poolModel
+	err = RunStep(commonCluster, "UpdateNodePool", func() error {
+		return manager.UpdateNodePool(previous, poolModel)
+	})
+	if err != nil {
+		modelCluster.NodePools[i] = previous
+		return err
+	}
+	log.Infof("Node pool %s updated", name)
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+	return ConfigureClusterAutoscaler(commonCluster)
+}
+
+// needsReplacement checks whether the change of the node pool needs a new pool, the instance type and the spot
+// properties of the pools can't be changed
+func needsReplacement(previous, pool model.NodePoolModel) bool {
+	return pool.InstanceType != previous.InstanceType || pool.Spot != previous.Spot ||
+		pool.SpotPrice != previous.SpotPrice || pool.OnDemandPercentage != previous.OnDemandPercentage
+}
+
+// replaceNodePool replaces the node pool with a pool of a new instance type, the workloads are moved
+// to the new pool by the drain of the old pool
+func replaceNodePool(commonCluster CommonCluster, manager NodePoolManager, previous, pool model.NodePoolModel) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	name, err := replacementNodePoolName(commonCluster, previous.Name)
+	if err != nil {
+		return err
+	}
+	pool.Name = name
+	modelCluster := commonCluster.GetModel()
+	modelCluster.NodePools = append(modelCluster.NodePools, pool)
+	err = RunStep(commonCluster, "CreateNodePool", func() error {
+		return manager.CreateNodePool(pool)
+	})
+	if err != nil {
+		modelCluster.NodePools = modelCluster.NodePools[:len(modelCluster.NodePools)-1]
+		return err
+	}
+	log.Infof("Node pool %s created to replace node pool %s", pool.Name, previous.Name)
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+	if err := deploySpotTerminationHandler(commonCluster, pool); err != nil {
+		return err
+	}
+
+	err = RunStep(commonCluster, "DrainNodePool", func() error {
+		return drainNodes(commonCluster, manager.NodePoolSelector(previous.Name), log)
+	})
+	if err != nil {
+		return err
+	}
+	return deleteNodePool(commonCluster, manager, previous.Name)
+}
+
+// replacementNodePoolName returns the name of the pool replacing the node pool, the number
+// at the end of the name is increased
+func replacementNodePoolName(commonCluster CommonCluster, name string) (string, error) {
+	namePattern, _, err := nodePoolNamePattern(commonCluster)
+	if err != nil {
+		return "", err
+	}
+	base := strings.TrimRight(name, "0123456789")
+	generation := 1
+	if number, err := strconv.Atoi(name[len(base):]); err == nil {
+		generation = number
+	}
+	for {
+		generation++
+		suffix := strconv.Itoa(generation)
+		prefix := base
+		for len(prefix) > 1 && !namePattern.MatchString(prefix+suffix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+		replacement := prefix + suffix
+		if !namePattern.MatchString(replacement) {
+			return "", fmt.Errorf("no replacement name for node pool %s", name)
+		}
+		if _, err := findNodePool(commonCluster, replacement); err != nil {
+			return replacement, nil
+		}
+	}
+}
+
+//DeleteNodePool drains the nodes of the node pool checked by CheckDeleteNodePool, deletes the pool and
+//removes it from the database
+func DeleteNodePool(commonCluster CommonCluster, name string) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	manager, err := nodePoolManager(commonCluster)
+	if err != nil {
+		return err
+	}
+	err = RunStep(commonCluster, "DrainNodePool", func() error {
+		return drainNodes(commonCluster, manager.NodePoolSelector(name), log)
+	})
+	if err != nil {
+		return err
+	}
+	return deleteNodePool(commonCluster, manager, name)
+}
+
+// deleteNodePool deletes the drained node pool from the cloud and the database
+func deleteNodePool(commonCluster CommonCluster, manager NodePoolManager, name string) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	i, err := findNodePool(commonCluster, name)
+	if err != nil {
+		return err
+	}
+	modelCluster := commonCluster.GetModel()
+	pool := modelCluster.NodePools[i]
+	modelCluster.NodePools = append(modelCluster.NodePools[:i:i], modelCluster.NodePools[i+1:]...)
+	err = RunStep(commonCluster, "DeleteNodePool", func() error {
+		return manager.DeleteNodePool(pool)
+	})
+	if err != nil {
+		modelCluster.NodePools = append(modelCluster.NodePools[:i:i], append([]model.NodePoolModel{pool}, modelCluster.NodePools[i:]...)...)
+		return err
+	}
+	log.Infof("Node pool %s deleted", name)
+	if err := pool.Delete(); err != nil {
+		return err
+	}
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+	return ConfigureClusterAutoscaler(commonCluster)
+}
+
+// labelNodes sets the labels of the nodes of the selector, the previous labels missing from the labels are removed,
+// it's used on the clouds without node pool labels
+func labelNodes(commonCluster CommonCluster, selector string, previous, labels map[string]string) error {
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.Labels == nil {
+			node.Labels = map[string]string{}
+		}
+		for key := range previous {
+			if _, ok := labels[key]; !ok {
+				delete(node.Labels, key)
+			}
+		}
+		for key, value := range labels {
+			node.Labels[key] = value
+		}
+		if _, err := client.CoreV1().Nodes().Update(node); err != nil {
+			return fmt.Errorf("error labelling node %s: %v", node.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestCheckNodePoolChanges(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		check       func(commonCluster cluster.CommonCluster) error
+		expectError bool
+		notFound    bool
+	}{
+		{
+			name: "duplicate pool",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckAddNodePool(commonCluster, cluster.NodePool{Name: "pool1", Count: 1})
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid name",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckAddNodePool(commonCluster, cluster.NodePool{Name: "Pool_3", Count: 1})
+			},
+			expectError: true,
+		},
+		{
+			name: "autoscaling on AKS",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckAddNodePool(commonCluster, cluster.NodePool{Name: "pool3", Count: 1, Autoscaling: true, MinCount: 1, MaxCount: 3})
+			},
+			expectError: true,
+		},
+		{
+			name: "rename",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckUpdateNodePool(commonCluster, "pool1", &cluster.NodePool{Name: "pool3", Count: 1})
+			},
+			expectError: true,
+		},
+		{
+			name: "shrink",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckUpdateNodePool(commonCluster, "pool2", &cluster.NodePool{Count: 1})
+			},
+		},
+		{
+			name: "update missing pool",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckUpdateNodePool(commonCluster, "pool3", &cluster.NodePool{Count: 1})
+			},
+			expectError: true,
+			notFound:    true,
+		},
+		{
+			name: "delete",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckDeleteNodePool(commonCluster, "pool2")
+			},
+		},
+		{
+			name: "delete missing pool",
+			check: func(commonCluster cluster.CommonCluster) error {
+				return cluster.CheckDeleteNodePool(commonCluster, "pool3")
+			},
+			expectError: true,
+			notFound:    true,
+		},
+		{
+			name: "delete last pool",
+			check: func(commonCluster cluster.CommonCluster) error {
+				if err := cluster.CheckDeleteNodePool(commonCluster, "pool2"); err != nil {
+					return err
+				}
+				commonCluster.GetModel().NodePools = commonCluster.GetModel().NodePools[:1]
+				return cluster.CheckDeleteNodePool(commonCluster, "pool1")
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+			pools := []cluster.NodePool{{Name: "pool1", Count: 1}, {Name: "pool2", Count: 3}}
+			if err := cluster.SetNodePools(commonCluster, pools); err != nil {
+				t.Fatalf("Error during SetNodePools: %s", err.Error())
+			}
+
+			err = tc.check(commonCluster)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				if _, ok := err.(*cluster.NodePoolNotFoundError); ok != tc.notFound {
+					t.Errorf("Expected not found error: %v, got: %v", tc.notFound, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during node pool check: %s", err.Error())
+			}
+		})
+	}
+
+	awsCluster, err := cluster.CreateCommonClusterFromRequest(awsCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	if err := cluster.CheckDeleteNodePool(awsCluster, "pool1"); err == nil {
+		t.Errorf("Expected error, node pools aren't supported on Amazon")
+	}
+}
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/spf13/viper"
+)
+
+// hoursPerMonth is the average number of hours of a month the monthly costs are estimated with
+const hoursPerMonth = 730
+
+const pricingCurrency = "USD"
+
+//PriceProvider is implemented by the clusters whose instance prices are known by the pricing API of the cloud
+type PriceProvider interface {
+	// InstancePrice returns the hourly price of an instance of the type in the location of the cluster,
+	// the price of the spot or preemptible instances if spot is set
+	InstancePrice(instanceType string, spot bool) (float64, error)
+}
+
+//NodePoolCost is the estimated cost of the nodes of a node pool
+type NodePoolCost struct {
+	Name         string  `json:"name"`
+	InstanceType string  `json:"instanceType"`
+	Nodes        int     `json:"nodes"`
+	Spot         bool    `json:"spot,omitempty"`
+	HourlyPrice  float64 `json:"hourlyPrice"`
+	MonthlyCost  float64 `json:"monthlyCost"`
+}
+
+//CostEstimate is the estimated monthly cost of a cluster, the node pools and the fee of the managed control plane
+type CostEstimate struct {
+	Currency     string         `json:"currency"`
+	MonthlyCost  float64        `json:"monthlyCost"`
+	ControlPlane float64        `json:"controlPlane"`
+	NodePools    []NodePoolCost `json:"nodePools"`
+}
+
+// priceCache caches the prices of the pricing APIs for pricing.cacheTTL, the prices change rarely and the
+// APIs are slow
+var priceCache = struct {
+	sync.Mutex
+	prices map[string]cachedPrice
+}{prices: map[string]cachedPrice{}}
+
+type cachedPrice struct {
+	price     float64
+	expiresAt time.Time
+}
+
+// getCachedPrice returns the cached price of the key, the price is fetched without a valid cached price
+func getCachedPrice(key string, fetch func() (float64, error)) (float64, error) {
+	priceCache.Lock()
+	cached, ok := priceCache.prices[key]
+	priceCache.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.price, nil
+	}
+	price, err := fetch()
+	if err != nil {
+		return 0, err
+	}
+	priceCache.Lock()
+	priceCache.prices[key] = cachedPrice{price: price, expiresAt: time.Now().Add(viper.GetDuration("pricing.cacheTTL"))}
+	priceCache.Unlock()
+	return price, nil
+}
+
+//EstimateCost estimates the monthly cost of the cluster with the current prices of its cloud. The nodes of a pool
+//are its current count (the initial count of a new cluster), the spot pools are estimated with the current spot
+//price up to their max price. The nodes of the regional GKE clusters are in every zone of the region.
+func EstimateCost(commonCluster CommonCluster) (*CostEstimate, error) {
+	provider, ok := commonCluster.(PriceProvider)
+	if !ok {
+		return nil, fmt.Errorf("cost estimation is not supported on %s", commonCluster.GetType())
+	}
+	modelCluster := commonCluster.GetModel()
+	pools := modelCluster.NodePools
+	if len(pools) == 0 {
+		pools = defaultNodePools(commonCluster)
+	}
+	zones := 1
+	if _, ok := commonCluster.(*GKECluster); ok && isGKERegion(modelCluster.Location) {
+		zones = gkeRegionalZones
+	}
+	estimate := &CostEstimate{
+		Currency:     pricingCurrency,
+		ControlPlane: controlPlanePrice(commonCluster) * hoursPerMonth,
+		NodePools:    make([]NodePoolCost, 0, len(pools)),
+	}
+	estimate.MonthlyCost = estimate.ControlPlane
+	for _, pool := range pools {
+		key := strings.Join([]string{commonCluster.GetType(), modelCluster.Location, pool.InstanceType, strconv.FormatBool(pool.Spot)}, "/")
+		price, err := getCachedPrice(key, func() (float64, error) {
+			return provider.InstancePrice(pool.InstanceType, pool.Spot)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting the price of %s: %s", pool.InstanceType, err.Error())
+		}
+		price = spotPrice(pool, price)
+		cost := NodePoolCost{
+			Name:         pool.Name,
+			InstanceType: pool.InstanceType,
+			Nodes:        pool.Count * zones,
+			Spot:         pool.Spot,
+			HourlyPrice:  price,
+		}
+		cost.MonthlyCost = price * float64(cost.Nodes) * hoursPerMonth
+		estimate.MonthlyCost += cost.MonthlyCost
+		estimate.NodePools = append(estimate.NodePools, cost)
+	}
+	return estimate, nil
+}
+
+// spotPrice caps the price of the spot pools with their max price
+func spotPrice(pool model.NodePoolModel, price float64) float64 {
+	if !pool.Spot || pool.SpotPrice == "" {
+		return price
+	}
+	if maxPrice, err := strconv.ParseFloat(pool.SpotPrice, 64); err == nil && maxPrice < price {
+		return maxPrice
+	}
+	return price
+}
+
+// controlPlanePrice returns the configured hourly fee of the managed control plane of the cluster
+func controlPlanePrice(commonCluster CommonCluster) float64 {
+	switch commonCluster.(type) {
+	case *EKSCluster:
+		return viper.GetFloat64("pricing.eksControlPlane")
+	case *GKECluster:
+		return viper.GetFloat64("pricing.gkeControlPlane")
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package cluster_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+// retailPrices responds the prices of the Azure Retail Prices API
+type retailPrices struct {
+	requests int
+}
+
+func (r *retailPrices) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests++
+	body := `{"Items": [
+		{"retailPrice": 0.5, "currencyCode": "USD", "skuName": "D2 v3", "productName": "Virtual Machines Dv3 Series Windows"},
+		{"retailPrice": 0.02, "currencyCode": "USD", "skuName": "D2 v3 Spot", "productName": "Virtual Machines Dv3 Series"},
+		{"retailPrice": 0.1, "currencyCode": "USD", "skuName": "D2 v3", "productName": "Virtual Machines Dv3 Series"}
+	]}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Header:     http.Header{},
+		Request:    req,
+	}, nil
+}
+
+func TestEstimateCost(t *testing.T) {
+
+	prices := &retailPrices{}
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = prices
+	defer func() { http.DefaultClient.Transport = transport }()
+
+	aksCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	for i := 0; i < 2; i++ {
+		estimate, err := cluster.EstimateCost(aksCluster)
+		if err != nil {
+			t.Fatalf("Error during EstimateCost: %s", err.Error())
+		}
+		if len(estimate.NodePools) != 1 || estimate.NodePools[0].HourlyPrice != 0.1 {
+			t.Fatalf("Expected the Linux pay-as-you-go price of the agent pool, got: %v", estimate.NodePools)
+		}
+		expected := 0.1 * float64(aksCreateFull.Properties.CreateClusterAzure.Node.AgentCount) * 730
+		if estimate.MonthlyCost != expected || estimate.ControlPlane != 0 {
+			t.Errorf("Expected monthly cost: %f, got: %f", expected, estimate.MonthlyCost)
+		}
+	}
+	if prices.requests != 1 {
+		t.Errorf("Expected the cached price, got %d requests", prices.requests)
+	}
+
+	awsCluster, err := cluster.CreateCommonClusterFromRequest(awsCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	if _, err := cluster.EstimateCost(awsCluster); err == nil {
+		t.Errorf("Expected error, but not got error!")
+	}
+}
@@ -0,0 +1,249 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	compute "google.golang.org/api/compute/v1"
+)
+
+const (
+	// the Price List API is only available in us-east-1, it has the prices of every region
+	awsPricingRegion    = "us-east-1"
+	awsPricingURL       = "https://api.pricing.us-east-1.amazonaws.com/"
+	azureRetailURL      = "https://prices.azure.com/api/retail/prices"
+	gcpBillingURL       = "https://cloudbilling.googleapis.com/v1/services/%s/skus"
+	gcpComputeService   = "6F81-5844-456A"
+	gcpUsageOnDemand    = "OnDemand"
+	gcpUsagePreemptible = "Preemptible"
+)
+
+// awsOnDemandPrice returns the hourly On-Demand price of the shared Linux instances of the type in the region
+func awsOnDemandPrice(creds *credentials.Credentials, region, instanceType string) (float64, error) {
+	attributes := map[string]string{
+		"instanceType":    instanceType,
+		"regionCode":      region,
+		"operatingSystem": "Linux",
+		"tenancy":         "Shared",
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+	}
+	filters := make([]map[string]string, 0, len(attributes))
+	for field, value := range attributes {
+		filters = append(filters, map[string]string{"Type": "TERM_MATCH", "Field": field, "Value": value})
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"ServiceCode":   "AmazonEC2",
+		"Filters":       filters,
+		"FormatVersion": "aws_v1",
+		"MaxResults":    1,
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, awsPricingURL, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AWSPriceListService.GetProducts")
+	if _, err := v4.NewSigner(creds).Sign(req, bytes.NewReader(data), "pricing", awsPricingRegion, time.Now()); err != nil {
+		return 0, err
+	}
+	var response struct {
+		PriceList []string `json:"PriceList"`
+	}
+	if err := doPricingRequest(http.DefaultClient, req, &response); err != nil {
+		return 0, err
+	}
+	if len(response.PriceList) == 0 {
+		return 0, fmt.Errorf("no On-Demand price of %s in %s", instanceType, region)
+	}
+	// the products of the price list are JSON documents
+	var product struct {
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					PricePerUnit map[string]string `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
+	}
+	if err := json.Unmarshal([]byte(response.PriceList[0]), &product); err != nil {
+		return 0, err
+	}
+	for _, term := range product.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			if price, err := strconv.ParseFloat(dimension.PricePerUnit[pricingCurrency], 64); err == nil {
+				return price, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no On-Demand price of %s in %s", instanceType, region)
+}
+
+// azureRetailPrice returns the hourly pay-as-you-go price of the Linux virtual machines of the size in the location
+// from the Azure Retail Prices API, the price of the Spot virtual machines if spot is set
+func azureRetailPrice(location, vmSize string, spot bool) (float64, error) {
+	filter := fmt.Sprintf("serviceName eq 'Virtual Machines' and armRegionName eq '%s' and armSkuName eq '%s' and priceType eq 'Consumption'", location, vmSize)
+	req, err := http.NewRequest(http.MethodGet, azureRetailURL+"?"+url.Values{"$filter": {filter}}.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	var response struct {
+		Items []struct {
+			RetailPrice  float64 `json:"retailPrice"`
+			CurrencyCode string  `json:"currencyCode"`
+			SkuName      string  `json:"skuName"`
+			ProductName  string  `json:"productName"`
+		} `json:"Items"`
+	}
+	if err := doPricingRequest(http.DefaultClient, req, &response); err != nil {
+		return 0, err
+	}
+	for _, item := range response.Items {
+		if strings.Contains(item.ProductName, "Windows") || strings.Contains(item.SkuName, "Low Priority") {
+			continue
+		}
+		if strings.HasSuffix(item.SkuName, " Spot") == spot && item.CurrencyCode == pricingCurrency {
+			return item.RetailPrice, nil
+		}
+	}
+	return 0, fmt.Errorf("no price of %s in %s", vmSize, location)
+}
+
+// gcpSku is a SKU of the Cloud Billing Catalog API
+type gcpSku struct {
+	Description string `json:"description"`
+	Category    struct {
+		ResourceFamily string `json:"resourceFamily"`
+		UsageType      string `json:"usageType"`
+	} `json:"category"`
+	ServiceRegions []string `json:"serviceRegions"`
+	PricingInfo    []struct {
+		PricingExpression struct {
+			TieredRates []struct {
+				UnitPrice struct {
+					Units string `json:"units"`
+					Nanos int64  `json:"nanos"`
+				} `json:"unitPrice"`
+			} `json:"tieredRates"`
+		} `json:"pricingExpression"`
+	} `json:"pricingInfo"`
+}
+
+// price returns the unit price of the last tier of the SKU
+func (sku *gcpSku) price() float64 {
+	if len(sku.PricingInfo) == 0 || len(sku.PricingInfo[0].PricingExpression.TieredRates) == 0 {
+		return 0
+	}
+	rates := sku.PricingInfo[0].PricingExpression.TieredRates
+	unitPrice := rates[len(rates)-1].UnitPrice
+	units, _ := strconv.ParseInt(unitPrice.Units, 10, 64)
+	return float64(units) + float64(unitPrice.Nanos)/1e9
+}
+
+// gcpInstancePrice returns the hourly price of the machine type from the Cloud Billing Catalog API, the CPUs and the
+// memory of the predefined machine types of a family are priced separately in the region
+func (s *gkeService) gcpInstancePrice(projectID, region, zone, machineType string, preemptible bool) (float64, error) {
+	computeService, err := compute.New(s.client)
+	if err != nil {
+		return 0, err
+	}
+	if zone == "" {
+		computeRegion, err := computeService.Regions.Get(projectID, region).Do()
+		if err != nil {
+			return 0, err
+		}
+		if len(computeRegion.Zones) == 0 {
+			return 0, fmt.Errorf("region %s has no zones", region)
+		}
+		zone = path.Base(computeRegion.Zones[0])
+	}
+	machine, err := computeService.MachineTypes.Get(projectID, zone, machineType).Do()
+	if err != nil {
+		return 0, fmt.Errorf("machine type %s is not available in %s: %s", machineType, zone, err.Error())
+	}
+	usageType := gcpUsageOnDemand
+	if preemptible {
+		usageType = gcpUsagePreemptible
+	}
+	// the SKUs of the family are described like "N1 Predefined Instance Core running in Belgium"
+	family := strings.ToUpper(strings.Split(machineType, "-")[0]) + " "
+	var corePrice, ramPrice float64
+	pageToken := ""
+	for {
+		query := url.Values{"currencyCode": {pricingCurrency}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(gcpBillingURL, gcpComputeService)+"?"+query.Encode(), nil)
+		if err != nil {
+			return 0, err
+		}
+		var response struct {
+			Skus          []gcpSku `json:"skus"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		if err := doPricingRequest(s.client, req, &response); err != nil {
+			return 0, err
+		}
+		for i := range response.Skus {
+			sku := &response.Skus[i]
+			if sku.Category.ResourceFamily != "Compute" || sku.Category.UsageType != usageType ||
+				!strings.HasPrefix(sku.Description, family) || strings.Contains(sku.Description, "Custom") ||
+				strings.Contains(sku.Description, "Sole Tenancy") || !containsString(sku.ServiceRegions, region) {
+				continue
+			}
+			if strings.Contains(sku.Description, "Instance Core") {
+				corePrice = sku.price()
+			} else if strings.Contains(sku.Description, "Instance Ram") {
+				ramPrice = sku.price()
+			}
+		}
+		if (corePrice != 0 && ramPrice != 0) || response.NextPageToken == "" {
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+	if corePrice == 0 || ramPrice == 0 {
+		return 0, fmt.Errorf("no price of %s in %s", machineType, region)
+	}
+	return corePrice*float64(machine.GuestCpus) + ramPrice*float64(machine.MemoryMb)/1024, nil
+}
+
+// doPricingRequest sends the request of a pricing API and decodes the response into result
+func doPricingRequest(client *http.Client, req *http.Request, result interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("pricing API %s responded %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(value)))
+	}
+	return json.Unmarshal(value, result)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
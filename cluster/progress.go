@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+// Events of the progress stream of a cluster
+const (
+	ProgressStatus     = "status"
+	ProgressStep       = "step"
+	ProgressDeployment = "deployment"
+	ProgressDeleted    = "deleted"
+)
+
+//ProgressEvent is a change of the status, of a step of the current operation or of a deployment of a cluster
+type ProgressEvent struct {
+	Name string
+	Data interface{}
+}
+
+//ProgressSnapshot is the progress of the operation and of the deployments of a cluster at a time
+type ProgressSnapshot struct {
+	Status      StatusResponse
+	Deployments []model.DeploymentModel
+}
+
+// progressStatus is the data of the status events
+type progressStatus struct {
+	Status        string `json:"status"`
+	StatusMessage string `json:"statusMessage,omitempty"`
+}
+
+// progressStep is the data of the step events, the steps are numbered from 0 in their operation
+type progressStep struct {
+	Index int `json:"index"`
+	model.ClusterStepModel
+}
+
+// progressSubscribers are notified of the changes of the clusters made by this instance of Pipeline
+var progressSubscribers = struct {
+	sync.Mutex
+	channels map[uint]map[chan struct{}]bool
+}{channels: map[uint]map[chan struct{}]bool{}}
+
+//SubscribeProgress returns a channel signaled after the changes of the progress of the cluster and the function
+//ending the subscription
+func SubscribeProgress(clusterID uint) (<-chan struct{}, func()) {
+	channel := make(chan struct{}, 1)
+	progressSubscribers.Lock()
+	defer progressSubscribers.Unlock()
+	if progressSubscribers.channels[clusterID] == nil {
+		progressSubscribers.channels[clusterID] = map[chan struct{}]bool{}
+	}
+	progressSubscribers.channels[clusterID][channel] = true
+	return channel, func() {
+		progressSubscribers.Lock()
+		defer progressSubscribers.Unlock()
+		delete(progressSubscribers.channels[clusterID], channel)
+		if len(progressSubscribers.channels[clusterID]) == 0 {
+			delete(progressSubscribers.channels, clusterID)
+		}
+	}
+}
+
+//NotifyProgress signals the subscribers of the progress of the cluster, it doesn't block
+func NotifyProgress(clusterID uint) {
+	progressSubscribers.Lock()
+	defer progressSubscribers.Unlock()
+	for channel := range progressSubscribers.channels[clusterID] {
+		select {
+		case channel <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//InProgress checks whether an operation of the cluster or a rollout of a deployment is in progress
+func (s *ProgressSnapshot) InProgress() bool {
+	switch s.Status.Status {
+	case StatusPending, StatusCreating, StatusUpdating, StatusDeleting, StatusSuspending, StatusResuming:
+		return true
+	}
+	for _, deployment := range s.Deployments {
+		if deployment.Rollout == helm.RolloutProgressing {
+			return true
+		}
+	}
+	return false
+}
+
+//GetProgress loads the progress of the cluster, it's nil if the cluster was deleted
+func GetProgress(clusterID uint) (*ProgressSnapshot, error) {
+	modelCluster, err := model.QueryCluster(map[string]interface{}{"id": clusterID})
+	if model.IsErrorGormNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	status, err := statusResponse(modelCluster)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := model.ListDeployments(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return &ProgressSnapshot{Status: *status, Deployments: deployments}, nil
+}
+
+//ProgressEvents returns the changes from the previous snapshot (nil before the first one) to the current
+//snapshot (nil if the cluster was deleted), the deleted deployments have the DELETED status
+func ProgressEvents(previous, current *ProgressSnapshot) []ProgressEvent {
+	if previous == nil {
+		previous = &ProgressSnapshot{}
+	}
+	if current == nil {
+		return []ProgressEvent{{Name: ProgressDeleted, Data: map[string]interface{}{
+			"id":   previous.Status.ResourceID,
+			"name": previous.Status.Name,
+		}}}
+	}
+	events := []ProgressEvent{}
+	if previous.Status.Status != current.Status.Status || previous.Status.StatusMessage != current.Status.StatusMessage {
+		events = append(events, ProgressEvent{Name: ProgressStatus, Data: progressStatus{
+			Status:        current.Status.Status,
+			StatusMessage: current.Status.StatusMessage,
+		}})
+	}
+	for i, step := range current.Status.Steps {
+		if i < len(previous.Status.Steps) && reflect.DeepEqual(previous.Status.Steps[i], step) {
+			continue
+		}
+		events = append(events, ProgressEvent{Name: ProgressStep, Data: progressStep{Index: i, ClusterStepModel: step}})
+	}
+	sent := map[string]model.DeploymentModel{}
+	for _, deployment := range previous.Deployments {
+		sent[deployment.ReleaseName] = deployment
+	}
+	for _, deployment := range current.Deployments {
+		last, ok := sent[deployment.ReleaseName]
+		delete(sent, deployment.ReleaseName)
+		if ok && last.Revision == deployment.Revision && last.Status == deployment.Status &&
+			last.Rollout == deployment.Rollout && last.RolloutMessage == deployment.RolloutMessage {
+			continue
+		}
+		events = append(events, ProgressEvent{Name: ProgressDeployment, Data: deployment})
+	}
+	for _, deployment := range previous.Deployments {
+		if _, ok := sent[deployment.ReleaseName]; ok {
+			events = append(events, ProgressEvent{Name: ProgressDeployment, Data: model.DeploymentModel{
+				ReleaseName: deployment.ReleaseName,
+				Chart:       deployment.Chart,
+				Revision:    deployment.Revision,
+				Status:      "DELETED",
+			}})
+		}
+	}
+	return events
+}
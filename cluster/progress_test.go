@@ -0,0 +1,66 @@
+package cluster_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestProgressEvents(t *testing.T) {
+
+	started := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Minute)
+	creating := &cluster.ProgressSnapshot{
+		Status: cluster.StatusResponse{ResourceID: 1, Name: "cluster1", Status: cluster.StatusCreating, Steps: []model.ClusterStepModel{
+			{Name: "CreateCluster", Status: cluster.StepRunning, StartedAt: started},
+		}},
+	}
+	created := &cluster.ProgressSnapshot{
+		Status: cluster.StatusResponse{ResourceID: 1, Name: "cluster1", Status: cluster.StatusRunning, Steps: []model.ClusterStepModel{
+			{Name: "CreateCluster", Status: cluster.StepDone, StartedAt: started, FinishedAt: &finished},
+			{Name: "InstallHelmPostHook", Status: cluster.StepDone, StartedAt: finished, FinishedAt: &finished},
+		}},
+	}
+	deployed := &cluster.ProgressSnapshot{
+		Status:      created.Status,
+		Deployments: []model.DeploymentModel{{ReleaseName: "web", Revision: 1, Status: "DEPLOYED", Rollout: "Progressing"}},
+	}
+	rolledOut := &cluster.ProgressSnapshot{
+		Status:      created.Status,
+		Deployments: []model.DeploymentModel{{ReleaseName: "web", Revision: 1, Status: "DEPLOYED", Rollout: "Ready"}},
+	}
+
+	cases := []struct {
+		name     string
+		previous *cluster.ProgressSnapshot
+		current  *cluster.ProgressSnapshot
+		expected []string
+	}{
+		{name: "first snapshot", previous: nil, current: creating, expected: []string{cluster.ProgressStatus, cluster.ProgressStep}},
+		{name: "no change", previous: creating, current: creating, expected: []string{}},
+		{name: "operation finished", previous: creating, current: created, expected: []string{cluster.ProgressStatus, cluster.ProgressStep, cluster.ProgressStep}},
+		{name: "deployment created", previous: created, current: deployed, expected: []string{cluster.ProgressDeployment}},
+		{name: "deployment rolled out", previous: deployed, current: rolledOut, expected: []string{cluster.ProgressDeployment}},
+		{name: "deployment deleted", previous: rolledOut, current: created, expected: []string{cluster.ProgressDeployment}},
+		{name: "cluster deleted", previous: created, current: nil, expected: []string{cluster.ProgressDeleted}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			names := []string{}
+			for _, event := range cluster.ProgressEvents(tc.previous, tc.current) {
+				names = append(names, event.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("Expected %v, got: %v", tc.expected, names)
+			}
+		})
+	}
+
+	if !deployed.InProgress() || rolledOut.InProgress() || !creating.InProgress() {
+		t.Errorf("Expected the creating cluster and the progressing rollout in progress")
+	}
+}
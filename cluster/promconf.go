@@ -79,7 +79,7 @@ func GenerateConfig(prometheusCfg []PrometheusCfg) []byte {
 		scrapeConfig := promcfg.ScrapeConfig{}
 		scrapeConfig.JobName = cluster.Name
 		scrapeConfig.HonorLabels = true
-		scrapeConfig.MetricsPath = "/api/v1/namespaces/default/services/monitor-prometheus-server:80/proxy/prometheus/federate"
+		scrapeConfig.MetricsPath = cluster.MetricsPath
 		scrapeConfig.Scheme = "https"
 		scrapeConfig.Params = url.Values{
 			"match[]": {
@@ -88,6 +88,7 @@ func GenerateConfig(prometheusCfg []PrometheusCfg) []byte {
 				`{job="kubernetes-apiservers"}`,
 				`{job="kubernetes-service-endpoints"}`,
 				`{job="kubernetes-cadvisor"}`,
+				`{job="kubernetes-nodes-cadvisor"}`,
 				`{job="banzaicloud-pushgateway"}`,
 				`{job="node_exporter"}`,
 			},
@@ -0,0 +1,60 @@
+package cluster
+
+import (
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultServiceAccount is the service account of the pods without one, its image pull secrets are used by them
+const defaultServiceAccount = "default"
+
+// InjectImagePullSecret writes the credentials of the image registry of the organization into an image pull secret
+// of the namespace and adds it to the default service account of the namespace, the secret is synced like the
+// other injected secrets
+func InjectImagePullSecret(cluster CommonCluster, registry *model.ContainerRegistryModel, namespace, name string) (*model.SecretInjectionModel, error) {
+	injection, err := injectSecret(cluster, registry.SecretID, namespace, name, registry.Host)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, err := cluster.GetK8sConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting kubeconfig")
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := setImagePullSecret(client, namespace, name, true); err != nil {
+		return nil, errors.Wrap(err, "error updating the default service account")
+	}
+	return injection, nil
+}
+
+// setImagePullSecret adds the image pull secret to the default service account of the namespace or removes it
+func setImagePullSecret(client *kubernetes.Clientset, namespace, name string, present bool) error {
+	serviceAccount, err := client.CoreV1().ServiceAccounts(namespace).Get(defaultServiceAccount, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) && !present {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	pullSecrets := []v1.LocalObjectReference{}
+	for _, reference := range serviceAccount.ImagePullSecrets {
+		if reference.Name != name {
+			pullSecrets = append(pullSecrets, reference)
+		}
+	}
+	if present {
+		pullSecrets = append(pullSecrets, v1.LocalObjectReference{Name: name})
+	} else if len(pullSecrets) == len(serviceAccount.ImagePullSecrets) {
+		return nil
+	}
+	serviceAccount.ImagePullSecrets = pullSecrets
+	_, err = client.CoreV1().ServiceAccounts(namespace).Update(serviceAccount)
+	return err
+}
@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+)
+
+//ScheduledOperationRunner runs a scheduled operation of the cluster, the cluster is running when it's started
+type ScheduledOperationRunner func(commonCluster CommonCluster, operation *model.ScheduledOperationModel) error
+
+//ScheduledOperationRunners run the scheduled operations by their kinds, the runners of the deployments are
+//registered by the api package
+var ScheduledOperationRunners = map[string]ScheduledOperationRunner{
+	model.ScheduledClusterUpgrade: runScheduledUpgrade,
+}
+
+//RunOperationScheduler starts the due operations of the queue at every interval, the queue is in the database so
+//the operations survive the restarts of Pipeline; it never returns
+func RunOperationScheduler(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"action": "OperationScheduler"})
+	for range time.Tick(interval) {
+		if err := runScheduledOperations(time.Now()); err != nil {
+			log.Errorf("Error running the scheduled operations: %s", err.Error())
+		}
+	}
+}
+
+// runScheduledOperations starts the due operations in the background, one operation of a cluster at a time; the
+// operations wait while their cluster isn't running and the ones missing the maintenance window of their cluster
+// are moved to its next window
+func runScheduledOperations(now time.Time) error {
+	log := logger.WithFields(logrus.Fields{"action": "OperationScheduler"})
+	operations, err := model.ListDueScheduledOperations(now)
+	if err != nil {
+		return err
+	}
+	running, err := model.ListScheduledOperations(0, model.ScheduledRunning, 0)
+	if err != nil {
+		return err
+	}
+	busy := map[uint]bool{}
+	for _, operation := range running {
+		busy[operation.ClusterID] = true
+	}
+	for i := range operations {
+		operation := &operations[i]
+		if busy[operation.ClusterID] {
+			continue
+		}
+		busy[operation.ClusterID] = true
+		var modelCluster model.ClusterModel
+		if err := model.GetDB().First(&modelCluster, operation.ClusterID).Error; err != nil {
+			if model.IsErrorGormNotFound(err) {
+				if started, err := operation.Start(); started && err == nil {
+					finishScheduledOperation(operation, fmt.Errorf("the cluster %d is deleted", operation.ClusterID))
+				}
+			} else {
+				log.Warnf("Error loading cluster %d: %s", operation.ClusterID, err.Error())
+			}
+			continue
+		}
+		commonCluster, err := GetCommonClusterFromModel(&modelCluster)
+		if err != nil {
+			log.Warnf("Error loading cluster %s: %s", modelCluster.Name, err.Error())
+			continue
+		}
+		if operation.MaintenanceWindow {
+			if window, err := model.GetMaintenanceWindow(operation.ClusterID); err == nil {
+				next, err := NextMaintenanceWindow(*window, now)
+				if err != nil {
+					log.Warnf("Invalid maintenance window of cluster %s: %s", modelCluster.Name, err.Error())
+					continue
+				}
+				if next.After(now) {
+					if _, err := operation.Reschedule(next); err != nil {
+						log.Warnf("Error rescheduling operation %d: %s", operation.ID, err.Error())
+					}
+					log.Infof("Operation %d of cluster %s is moved to the next maintenance window at %s", operation.ID, modelCluster.Name, next)
+					continue
+				}
+			} else if !model.IsErrorGormNotFound(err) {
+				log.Warnf("Error loading the maintenance window of cluster %s: %s", modelCluster.Name, err.Error())
+				continue
+			}
+		}
+		if ClusterStatus(commonCluster) != StatusRunning {
+			continue
+		}
+		started, err := operation.Start()
+		if err != nil {
+			log.Warnf("Error starting operation %d: %s", operation.ID, err.Error())
+			continue
+		}
+		if !started {
+			continue
+		}
+		log.Infof("Starting the %s operation %d of cluster %s", operation.Kind, operation.ID, modelCluster.Name)
+		go func() {
+			run, ok := ScheduledOperationRunners[operation.Kind]
+			if !ok {
+				finishScheduledOperation(operation, fmt.Errorf("unknown operation: %s", operation.Kind))
+				return
+			}
+			finishScheduledOperation(operation, run(commonCluster, operation))
+		}()
+	}
+	return nil
+}
+
+// finishScheduledOperation saves the result of the started operation
+func finishScheduledOperation(operation *model.ScheduledOperationModel, err error) {
+	log := logger.WithFields(logrus.Fields{"action": "OperationScheduler"})
+	now := time.Now().UTC()
+	operation.Status, operation.FinishedAt = model.ScheduledSucceeded, &now
+	if err != nil {
+		operation.Status, operation.Error = model.ScheduledFailed, err.Error()
+		log.Infof("Operation %d of cluster %d failed: %s", operation.ID, operation.ClusterID, err.Error())
+	}
+	if err := operation.Save(); err != nil {
+		log.Errorf("Error during saving scheduled operation: %s", err.Error())
+	}
+}
+
+// runScheduledUpgrade upgrades the cluster to the Kubernetes version of the queued upgrade request
+func runScheduledUpgrade(commonCluster CommonCluster, operation *model.ScheduledOperationModel) error {
+	var request UpgradeRequest
+	if err := json.Unmarshal([]byte(operation.Request), &request); err != nil {
+		return err
+	}
+	if err := CheckUpgrade(commonCluster, request); err != nil {
+		return err
+	}
+	if err := SetStatus(commonCluster, StatusUpdating, ""); err != nil {
+		return err
+	}
+	if err := UpgradeCluster(commonCluster, request); err != nil {
+		if err := SetStatus(commonCluster, StatusError, err.Error()); err != nil {
+			logger.Errorf("Error during setting cluster status: %s", err.Error())
+		}
+		return err
+	}
+	return SetStatus(commonCluster, StatusRunning, "")
+}
+
+//FailInterruptedScheduledOperations fails the scheduled operations whose run was interrupted by a restart of
+//Pipeline, the queued ones are kept
+func FailInterruptedScheduledOperations() error {
+	now := time.Now().UTC()
+	return model.GetDB().Model(&model.ScheduledOperationModel{}).Where("status = ?", model.ScheduledRunning).
+		UpdateColumns(map[string]interface{}{
+			"status":      model.ScheduledFailed,
+			"error":       "the operation was interrupted by a restart of Pipeline",
+			"finished_at": &now,
+		}).Error
+}
@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"strconv"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels of the Kubernetes Secrets managed by Pipeline
+const (
+	secretIDLabel      = "pipeline.banzaicloud.com/secret-id"
+	secretVersionLabel = "pipeline.banzaicloud.com/secret-version"
+)
+
+// applyK8sSecret creates or updates the Kubernetes Secret with the values of the Pipeline secret, it's an image pull
+// secret with the credentials of the registry if it's not empty
+func applyK8sSecret(kubeConfig *[]byte, namespace, name string, item *secret.SecretsItemResponse, registry string) error {
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	k8sSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				secretIDLabel:      item.ID,
+				secretVersionLabel: strconv.Itoa(item.Version),
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: make(map[string][]byte, len(item.Values)),
+	}
+	if registry != "" {
+		username, password, err := helm.RegistryLogin(item, registry)
+		if err != nil {
+			return err
+		}
+		dockerConfig, err := helm.DockerConfigJSON(registry, username, password)
+		if err != nil {
+			return err
+		}
+		k8sSecret.Type = v1.SecretTypeDockerConfigJson
+		k8sSecret.Data = map[string][]byte{v1.DockerConfigJsonKey: dockerConfig}
+	} else {
+		for key, value := range item.Values {
+			k8sSecret.Data[key] = []byte(value)
+		}
+	}
+
+	existing, err := client.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = client.CoreV1().Secrets(namespace).Create(k8sSecret)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if existing.Labels[secretIDLabel] != item.ID {
+		return errors.Errorf("secret %s/%s exists and isn't managed by Pipeline", namespace, name)
+	}
+	k8sSecret.ResourceVersion = existing.ResourceVersion
+	_, err = client.CoreV1().Secrets(namespace).Update(k8sSecret)
+	return err
+}
+
+// InjectSecret writes a Pipeline secret into a Kubernetes Secret of the cluster and records it, so later
+// versions of the secret are synced to the cluster
+func InjectSecret(cluster CommonCluster, secretID, namespace, name string) (*model.SecretInjectionModel, error) {
+	return injectSecret(cluster, secretID, namespace, name, "")
+}
+
+// injectSecret writes and records the Kubernetes Secret of the Pipeline secret, an image pull secret of the
+// registry if it's not empty
+func injectSecret(cluster CommonCluster, secretID, namespace, name, registry string) (*model.SecretInjectionModel, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(cluster.GetOrg()), 10), secretID)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, err := cluster.GetK8sConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting kubeconfig")
+	}
+	if err := applyK8sSecret(kubeConfig, namespace, name, item, registry); err != nil {
+		return nil, errors.Wrap(err, "error writing kubernetes secret")
+	}
+
+	injection := &model.SecretInjectionModel{}
+	database := model.GetDB()
+	err = database.Where(model.SecretInjectionModel{ClusterID: cluster.GetID(), Namespace: namespace, Name: name}).
+		Assign(model.SecretInjectionModel{OrganizationID: cluster.GetOrg(), SecretID: secretID, Version: item.Version, Registry: registry}).
+		FirstOrCreate(injection).Error
+	return injection, err
+}
+
+// ListInjectedSecrets returns the Pipeline secrets injected into the cluster
+func ListInjectedSecrets(cluster CommonCluster) ([]model.SecretInjectionModel, error) {
+	injections := []model.SecretInjectionModel{}
+	err := model.GetDB().Where(model.SecretInjectionModel{ClusterID: cluster.GetID()}).Find(&injections).Error
+	return injections, err
+}
+
+// RemoveInjectedSecret deletes an injected Kubernetes Secret from the cluster and stops syncing it
+func RemoveInjectedSecret(cluster CommonCluster, namespace, name string) error {
+	database := model.GetDB()
+	var injection model.SecretInjectionModel
+	err := database.Where(model.SecretInjectionModel{ClusterID: cluster.GetID(), Namespace: namespace, Name: name}).First(&injection).Error
+	if err != nil {
+		return err
+	}
+	kubeConfig, err := cluster.GetK8sConfig()
+	if err != nil {
+		return errors.Wrap(err, "error getting kubeconfig")
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	if err := client.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrap(err, "error deleting kubernetes secret")
+	}
+	if injection.Registry != "" {
+		if err := setImagePullSecret(client, namespace, name, false); err != nil {
+			return errors.Wrap(err, "error updating the default service account")
+		}
+	}
+	return database.Delete(&injection).Error
+}
+
+// SyncInjectedSecrets writes the current version of a Pipeline secret into the clusters it's injected into,
+// it's called when the secret is updated or rolled back
+func SyncInjectedSecrets(secretID string) {
+	log := logger.WithFields(logrus.Fields{"tag": "SyncInjectedSecrets"})
+	database := model.GetDB()
+	var injections []model.SecretInjectionModel
+	if err := database.Where(model.SecretInjectionModel{SecretID: secretID}).Find(&injections).Error; err != nil {
+		log.Errorf("Error listing injections of secret %s: %s", secretID, err.Error())
+		return
+	}
+	for i := range injections {
+		injection := &injections[i]
+		modelCluster, err := model.QueryCluster(map[string]interface{}{"id": injection.ClusterID})
+		if model.IsErrorGormNotFound(err) {
+			log.Infof("Cluster %d is deleted, removing the injection of secret %s", injection.ClusterID, secretID)
+			database.Delete(injection)
+			continue
+		}
+		var item *secret.SecretsItemResponse
+		var commonCluster CommonCluster
+		if err == nil {
+			commonCluster, err = GetCommonClusterFromModel(modelCluster)
+		}
+		if err == nil {
+			item, err = secret.Store.Get(strconv.FormatUint(uint64(injection.OrganizationID), 10), secretID)
+		}
+		if err == nil && item.Version == injection.Version {
+			continue
+		}
+		var kubeConfig *[]byte
+		if err == nil {
+			kubeConfig, err = commonCluster.GetK8sConfig()
+		}
+		if err == nil {
+			err = applyK8sSecret(kubeConfig, injection.Namespace, injection.Name, item, injection.Registry)
+		}
+		if err == nil {
+			err = database.Model(injection).Update("version", item.Version).Error
+		}
+		if err != nil {
+			log.Errorf("Error syncing secret %s to %s/%s of cluster %d: %s", secretID, injection.Namespace, injection.Name, injection.ClusterID, err.Error())
+			continue
+		}
+		log.Infof("Secret %s synced to %s/%s of cluster %d (version %d)", secretID, injection.Namespace, injection.Name, injection.ClusterID, item.Version)
+	}
+}
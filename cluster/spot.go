@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1beta2"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const terminationHandlerName = "node-termination-handler"
+
+// spotNodeSelector returns the label of the spot or preemptible nodes of the cluster and the image of
+// the termination handler of the provider
+func spotNodeSelector(commonCluster CommonCluster) (map[string]string, string, error) {
+	switch commonCluster.(type) {
+	case *EKSCluster:
+		return map[string]string{"eks.amazonaws.com/capacityType": "SPOT"}, viper.GetString("spot.awsTerminationHandlerImage"), nil
+	case *GKECluster:
+		return map[string]string{"cloud.google.com/gke-preemptible": "true"}, viper.GetString("spot.gkeTerminationHandlerImage"), nil
+	}
+	return nil, "", fmt.Errorf("spot node pools are not supported on %s", commonCluster.GetType())
+}
+
+// hasSpotNodePools checks whether the cluster has spot or preemptible node pools
+func hasSpotNodePools(commonCluster CommonCluster) bool {
+	for _, pool := range commonCluster.GetModel().NodePools {
+		if pool.Spot {
+			return true
+		}
+	}
+	return false
+}
+
+//InstallTerminationHandlerPostHook deploys the termination handler if the cluster has spot or preemptible node pools
+func InstallTerminationHandlerPostHook(cluster CommonCluster) {
+	log = logger.WithFields(logrus.Fields{"action": "InstallTerminationHandler"})
+	if !hasSpotNodePools(cluster) {
+		return
+	}
+	if err := deployTerminationHandler(cluster); err != nil {
+		log.Errorf("Deploying the termination handler failed: %s", err.Error())
+		return
+	}
+	log.Info("Termination handler deployed")
+}
+
+// deploySpotTerminationHandler deploys the termination handler after the creation of a spot node pool,
+// it's a step of the creation
+func deploySpotTerminationHandler(commonCluster CommonCluster, pool model.NodePoolModel) error {
+	if !pool.Spot {
+		return nil
+	}
+	return RunStep(commonCluster, "DeployTerminationHandler", func() error {
+		return deployTerminationHandler(commonCluster)
+	})
+}
+
+// deployTerminationHandler creates or updates the DaemonSet cordoning and draining the spot nodes when
+// their instances are reclaimed, so the workloads are moved before the nodes disappear
+func deployTerminationHandler(commonCluster CommonCluster) error {
+	nodeSelector, image, err := spotNodeSelector(commonCluster)
+	if err != nil {
+		return err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return err
+	}
+	meta := metav1.ObjectMeta{Name: terminationHandlerName, Namespace: metav1.NamespaceSystem}
+
+	_, err = client.CoreV1().ServiceAccounts(meta.Namespace).Create(&v1.ServiceAccount{ObjectMeta: meta})
+	if err != nil && !k8sErrors.IsAlreadyExists(err) {
+		return err
+	}
+	_, err = client.RbacV1().ClusterRoles().Create(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: terminationHandlerName},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "patch", "update"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+			{APIGroups: []string{""}, Resources: []string{"pods/eviction"}, Verbs: []string{"create"}},
+			{APIGroups: []string{"extensions", "apps"}, Resources: []string{"daemonsets"}, Verbs: []string{"get"}},
+		},
+	})
+	if err != nil && !k8sErrors.IsAlreadyExists(err) {
+		return err
+	}
+	_, err = client.RbacV1().ClusterRoleBindings().Create(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: terminationHandlerName},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: terminationHandlerName},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: meta.Name, Namespace: meta.Namespace}},
+	})
+	if err != nil && !k8sErrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	labels := map[string]string{"app": terminationHandlerName}
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: meta,
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					ServiceAccountName: terminationHandlerName,
+					NodeSelector:       nodeSelector,
+					// the handler runs on the cordoned and tainted nodes as well
+					Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+					Containers: []v1.Container{{
+						Name:  terminationHandlerName,
+						Image: image,
+						Env: []v1.EnvVar{
+							{Name: "NODE_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+							{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+							{Name: "NAMESPACE", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}},
+						},
+					}},
+				},
+			},
+		},
+	}
+	daemonSets := client.AppsV1beta2().DaemonSets(meta.Namespace)
+	if _, err := daemonSets.Create(daemonSet); k8sErrors.IsAlreadyExists(err) {
+		current, err := daemonSets.Get(meta.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Spec.Template = daemonSet.Spec.Template
+		_, err = daemonSets.Update(current)
+		return err
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
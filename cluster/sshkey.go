@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const sshKeyBits = 4096
+
+// the users of the nodes the SSH key is authorized for
+const (
+	eksSSHUser = "ec2-user"
+	aksSSHUser = "azureuser"
+	gkeSSHUser = "pipeline"
+)
+
+// keys of the SSH secrets
+const (
+	sshUserKey       = "user"
+	sshPublicKeyKey  = "public_key_data"
+	sshPrivateKeyKey = "private_key_data"
+)
+
+//SSHKey is the SSH key pair of the nodes of a cluster, it's stored as an SSH secret of the organization
+type SSHKey struct {
+	User       string `json:"user"`
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey,omitempty"`
+}
+
+//NewSSHKey generates an RSA key pair for the user, the public key is in the authorized_keys format
+func NewSSHKey(user string) (*SSHKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, sshKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return &SSHKey{
+		User:       user,
+		PublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		PrivateKey: string(privateKey),
+	}, nil
+}
+
+// sshUser returns the user of the nodes of the cluster
+func sshUser(commonCluster CommonCluster) (string, error) {
+	switch commonCluster.(type) {
+	case *EKSCluster:
+		return eksSSHUser, nil
+	case *AKSCluster:
+		return aksSSHUser, nil
+	case *GKECluster:
+		return gkeSSHUser, nil
+	}
+	return "", fmt.Errorf("SSH keys are not supported on %s", commonCluster.GetType())
+}
+
+// sshKeySecretName is the name of the secret of the SSH key of the cluster
+func sshKeySecretName(commonCluster CommonCluster) string {
+	return fmt.Sprintf("cluster-%s-ssh", commonCluster.GetName())
+}
+
+// storeSSHKey saves the key as the secret of the SSH key of the cluster, the secret is created for the first key
+func storeSSHKey(commonCluster CommonCluster, key *SSHKey) error {
+	modelCluster := commonCluster.GetModel()
+	secretID := modelCluster.SSHSecretID
+	if secretID == "" {
+		secretID = secret.GenerateSecretID()
+	}
+	err := secret.Store.Store(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), secretID, secret.CreateSecretRequest{
+		Name:       sshKeySecretName(commonCluster),
+		SecretType: secret.SSH,
+		Values: map[string]string{
+			sshUserKey:       key.User,
+			sshPublicKeyKey:  key.PublicKey,
+			sshPrivateKeyKey: key.PrivateKey,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "error storing the SSH key")
+	}
+	modelCluster.SSHSecretID = secretID
+	return nil
+}
+
+//GenerateSSHKey generates the SSH key of a new cluster, the public key is authorized on the nodes of the
+//cluster created afterwards. The clusters of the providers without SSH access don't get a key.
+func GenerateSSHKey(commonCluster CommonCluster) error {
+	user, err := sshUser(commonCluster)
+	if err != nil {
+		log.Info(err.Error())
+		return nil
+	}
+	key, err := NewSSHKey(user)
+	if err != nil {
+		return err
+	}
+	return storeSSHKey(commonCluster, key)
+}
+
+//GetSSHKey returns the SSH key of the cluster, it's nil for the clusters without a key
+func GetSSHKey(commonCluster CommonCluster) (*SSHKey, error) {
+	secretID := commonCluster.GetModel().SSHSecretID
+	if secretID == "" {
+		return nil, nil
+	}
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), secretID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the SSH key")
+	}
+	return &SSHKey{
+		User:       item.Values[sshUserKey],
+		PublicKey:  item.Values[sshPublicKeyKey],
+		PrivateKey: item.Values[sshPrivateKeyKey],
+	}, nil
+}
+
+//RotateSSHKey replaces the SSH key of the cluster with a new key pair. The key of the AKS agents is updated,
+//the EKS node groups and GKE node pools created after the rotation get the new key, the existing nodes keep
+//the previous key, which is a previous version of the secret, until their pools are replaced.
+func RotateSSHKey(commonCluster CommonCluster) (*SSHKey, error) {
+	user, err := sshUser(commonCluster)
+	if err != nil {
+		return nil, err
+	}
+	if commonCluster.GetModel().SSHSecretID == "" {
+		return nil, fmt.Errorf("cluster %s has no SSH key", commonCluster.GetName())
+	}
+	key, err := NewSSHKey(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := storeSSHKey(commonCluster, key); err != nil {
+		return nil, err
+	}
+	switch c := commonCluster.(type) {
+	case *EKSCluster:
+		err = c.importSSHKey(key)
+	case *AKSCluster:
+		err = c.updateNodePools(c.managedClusterRequest(c.modelCluster.Azure.AgentCount))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error applying the new SSH key")
+	}
+	return key, nil
+}
+
+//DeleteSSHKey deletes the secret of the SSH key of a deleted cluster
+func DeleteSSHKey(commonCluster CommonCluster) error {
+	secretID := commonCluster.GetModel().SSHSecretID
+	if secretID == "" {
+		return nil
+	}
+	return secret.Store.Delete(strconv.FormatUint(uint64(commonCluster.GetOrg()), 10), secretID)
+}
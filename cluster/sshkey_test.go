@@ -0,0 +1,52 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHKey(t *testing.T) {
+
+	gkeCluster, err := cluster.CreateCommonClusterFromRequest(gkeCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	if key, err := cluster.GetSSHKey(gkeCluster); err != nil || key != nil {
+		t.Fatalf("Expected no SSH key before the creation, got: %v, %v", key, err)
+	}
+	if _, err := cluster.RotateSSHKey(gkeCluster); err == nil {
+		t.Errorf("Expected error rotating the missing SSH key, but not got error!")
+	}
+
+	if err := cluster.GenerateSSHKey(gkeCluster); err != nil {
+		t.Fatalf("Error during GenerateSSHKey: %s", err.Error())
+	}
+	key, err := cluster.GetSSHKey(gkeCluster)
+	if err != nil {
+		t.Fatalf("Error during GetSSHKey: %s", err.Error())
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(key.PrivateKey))
+	if err != nil {
+		t.Fatalf("Error parsing the private key: %s", err.Error())
+	}
+	if publicKey := string(ssh.MarshalAuthorizedKey(signer.PublicKey())); publicKey != key.PublicKey+"\n" {
+		t.Errorf("Expected the public key of the private key, got: %s", key.PublicKey)
+	}
+	if key.User == "" {
+		t.Errorf("Expected the user of the nodes")
+	}
+
+	secretID := gkeCluster.GetModel().SSHSecretID
+	rotated, err := cluster.RotateSSHKey(gkeCluster)
+	if err != nil {
+		t.Fatalf("Error during RotateSSHKey: %s", err.Error())
+	}
+	if rotated.PublicKey == key.PublicKey || gkeCluster.GetModel().SSHSecretID != secretID {
+		t.Errorf("Expected a new key in the same secret")
+	}
+	if err := cluster.DeleteSSHKey(gkeCluster); err != nil {
+		t.Errorf("Error during DeleteSSHKey: %s", err.Error())
+	}
+}
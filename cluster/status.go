@@ -0,0 +1,187 @@
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+)
+
+// Cluster statuses, the clusters stored before the statuses were introduced are running
+const (
+	StatusPending  = "PENDING"
+	StatusCreating = "CREATING"
+	StatusRunning  = "RUNNING"
+	StatusUpdating = "UPDATING"
+	StatusError    = "ERROR"
+	StatusDeleting = "DELETING"
+	// the node pools of the hibernated clusters are scaled to zero (the AKS clusters are stopped)
+	StatusSuspending = "SUSPENDING"
+	StatusHibernated = "HIBERNATED"
+	StatusResuming   = "RESUMING"
+)
+
+// Step statuses
+const (
+	StepRunning = "RUNNING"
+	StepDone    = "DONE"
+	StepFailed  = "FAILED"
+)
+
+// statusTransitions are the allowed transitions of the cluster statuses, a failed cluster can be updated,
+// resumed or deleted to recover from the error; a hibernated cluster must be resumed before it's updated
+var statusTransitions = map[string][]string{
+	StatusPending:    {StatusCreating, StatusError},
+	StatusCreating:   {StatusRunning, StatusError},
+	StatusRunning:    {StatusUpdating, StatusDeleting, StatusSuspending},
+	StatusUpdating:   {StatusRunning, StatusError},
+	StatusError:      {StatusUpdating, StatusDeleting, StatusResuming},
+	StatusDeleting:   {StatusError},
+	StatusSuspending: {StatusHibernated, StatusError},
+	StatusHibernated: {StatusResuming, StatusDeleting},
+	StatusResuming:   {StatusRunning, StatusError},
+}
+
+//StatusTransitionError is returned when the cluster can't get into a status from its current status
+type StatusTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("cluster status can't change from %s to %s", e.From, e.To)
+}
+
+//StatusResponse describes the phase of the cluster and the progress of its current operation
+type StatusResponse struct {
+	ResourceID    uint                     `json:"id"`
+	Name          string                   `json:"name"`
+	Status        string                   `json:"status"`
+	StatusMessage string                   `json:"statusMessage,omitempty"`
+	Steps         []model.ClusterStepModel `json:"steps"`
+}
+
+//ClusterStatus returns the status of the cluster
+func ClusterStatus(commonCluster CommonCluster) string {
+	return modelStatus(commonCluster.GetModel())
+}
+
+// modelStatus returns the status of the cluster model
+func modelStatus(modelCluster *model.ClusterModel) string {
+	if modelCluster.Status != "" {
+		return modelCluster.Status
+	}
+	return StatusRunning
+}
+
+//ValidateStatusTransition checks whether the cluster can get into the status to from the status from
+func ValidateStatusTransition(from, to string) error {
+	for _, status := range statusTransitions[from] {
+		if status == to {
+			return nil
+		}
+	}
+	return &StatusTransitionError{From: from, To: to}
+}
+
+//SetStatus moves the cluster into a new status, message is the error of the failed clusters. The status is
+//updated only if it wasn't changed by another operation; the steps are reset when a new operation starts.
+func SetStatus(commonCluster CommonCluster, status, message string) error {
+	from := ClusterStatus(commonCluster)
+	if err := ValidateStatusTransition(from, status); err != nil {
+		return err
+	}
+	modelCluster := commonCluster.GetModel()
+	if modelCluster.ID != 0 {
+		database := model.GetDB()
+		query := database.Model(&model.ClusterModel{}).Where("id = ? AND status = ?", modelCluster.ID, modelCluster.Status).
+			UpdateColumns(map[string]interface{}{"status": status, "status_message": message})
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return &StatusTransitionError{From: from, To: status}
+		}
+		switch status {
+		case StatusCreating, StatusUpdating, StatusDeleting, StatusSuspending, StatusResuming:
+			if err := database.Where("cluster_model_id = ?", modelCluster.ID).Delete(&model.ClusterStepModel{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	modelCluster.Status = status
+	modelCluster.StatusMessage = message
+	NotifyProgress(modelCluster.ID)
+	return nil
+}
+
+//RunStep runs a step of the current operation of the cluster and records its progress
+func RunStep(commonCluster CommonCluster, name string, step func() error) error {
+	record := &model.ClusterStepModel{
+		ClusterModelID: commonCluster.GetID(),
+		Name:           name,
+		Status:         StepRunning,
+		StartedAt:      time.Now(),
+	}
+	if record.ClusterModelID != 0 {
+		if err := model.GetDB().Create(record).Error; err != nil {
+			log.Warnf("Failed to record step %s of cluster %d: %s", name, record.ClusterModelID, err.Error())
+		}
+		NotifyProgress(record.ClusterModelID)
+	}
+
+	err := step()
+
+	finished := time.Now()
+	record.FinishedAt = &finished
+	record.Status = StepDone
+	if err != nil {
+		record.Status = StepFailed
+		record.Error = err.Error()
+	}
+	if record.ID != 0 {
+		if err := model.GetDB().Save(record).Error; err != nil {
+			log.Warnf("Failed to record step %s of cluster %d: %s", name, record.ClusterModelID, err.Error())
+		}
+		NotifyProgress(record.ClusterModelID)
+	}
+	return err
+}
+
+//GetStatusResponse returns the status and the steps of the current operation of the cluster
+func GetStatusResponse(commonCluster CommonCluster) (*StatusResponse, error) {
+	return statusResponse(commonCluster.GetModel())
+}
+
+// statusResponse returns the status and the steps of the current operation of the cluster model
+func statusResponse(modelCluster *model.ClusterModel) (*StatusResponse, error) {
+	response := &StatusResponse{
+		ResourceID:    modelCluster.ID,
+		Name:          modelCluster.Name,
+		Status:        modelStatus(modelCluster),
+		StatusMessage: modelCluster.StatusMessage,
+		Steps:         []model.ClusterStepModel{},
+	}
+	if modelCluster.ID != 0 {
+		if err := model.GetDB().Where("cluster_model_id = ?", modelCluster.ID).Order("id").Find(&response.Steps).Error; err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// hookName returns the name of the posthook function
+func hookName(hook func(CommonCluster)) string {
+	name := runtime.FuncForPC(reflect.ValueOf(hook).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+//FailInterruptedOperations moves the clusters whose operation was interrupted by a restart of Pipeline into the error status
+func FailInterruptedOperations() error {
+	return model.GetDB().Model(&model.ClusterModel{}).
+		Where("status IN (?)", []string{StatusPending, StatusCreating, StatusUpdating, StatusDeleting, StatusSuspending, StatusResuming}).
+		UpdateColumns(map[string]interface{}{"status": StatusError, "status_message": "the operation was interrupted by a restart of Pipeline"}).Error
+}
@@ -0,0 +1,63 @@
+package cluster_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestSetStatus(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		from        string
+		to          string
+		expectError bool
+	}{
+		{name: "create", from: cluster.StatusPending, to: cluster.StatusCreating},
+		{name: "created", from: cluster.StatusCreating, to: cluster.StatusRunning},
+		{name: "update stored cluster", from: "", to: cluster.StatusUpdating},
+		{name: "delete failed cluster", from: cluster.StatusError, to: cluster.StatusDeleting},
+		{name: "update while creating", from: cluster.StatusCreating, to: cluster.StatusUpdating, expectError: true},
+		{name: "delete while updating", from: cluster.StatusUpdating, to: cluster.StatusDeleting, expectError: true},
+		{name: "suspend", from: cluster.StatusRunning, to: cluster.StatusSuspending},
+		{name: "resume failed cluster", from: cluster.StatusError, to: cluster.StatusResuming},
+		{name: "update hibernated cluster", from: cluster.StatusHibernated, to: cluster.StatusUpdating, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			commonCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+			if err != nil {
+				t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+			}
+			commonCluster.GetModel().Status = tc.from
+
+			err = cluster.SetStatus(commonCluster, tc.to, "")
+			if tc.expectError {
+				if _, ok := err.(*cluster.StatusTransitionError); !ok {
+					t.Errorf("Expected status transition error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during SetStatus: %s", err.Error())
+			}
+			if status := cluster.ClusterStatus(commonCluster); status != tc.to {
+				t.Errorf("Expected status: %s, got: %s", tc.to, status)
+			}
+		})
+	}
+}
+
+func TestRunStep(t *testing.T) {
+	commonCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	stepErr := errors.New("step failed")
+	if err := cluster.RunStep(commonCluster, "step", func() error { return stepErr }); err != stepErr {
+		t.Errorf("Expected error: %v, got: %v", stepErr, err)
+	}
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const maxTags = 50
+
+// the tags are GCP labels and Kubernetes label values on every provider, the keys are lower case names and
+// the values are lower case (possibly empty) label values
+var (
+	tagKeyRegexp   = regexp.MustCompile(`^[a-z]([a-z0-9_-]{0,61}[a-z0-9])?$`)
+	tagValueRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9_-]{0,61}[a-z0-9])?)?$`)
+)
+
+//ValidateTags validates the tags of a cluster, they must be valid GCP and Kubernetes labels
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("a cluster can have at most %d tags", maxTags)
+	}
+	for key, value := range tags {
+		if !tagKeyRegexp.MatchString(key) {
+			return fmt.Errorf("invalid tag key %q, the keys are lower case letters, digits, '_' and '-' starting with a letter", key)
+		}
+		if !tagValueRegexp.MatchString(value) {
+			return fmt.Errorf("invalid value %q of tag %s, the values are lower case letters, digits, '_' and '-'", value, key)
+		}
+	}
+	return nil
+}
+
+//SetTags sets the tags of a new cluster, they are propagated to its cloud resources and the labels of its nodes
+func SetTags(commonCluster CommonCluster, tags map[string]string) error {
+	if err := ValidateTags(tags); err != nil {
+		return err
+	}
+	commonCluster.GetModel().SetTags(tags)
+	return nil
+}
+
+//MatchTags checks whether the tags match every filter, a "key" filter matches the tag with any value and a
+//"key:value" filter matches the tag with the value
+func MatchTags(tags map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, ":", 2)
+		value, ok := tags[parts[0]]
+		if !ok || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+)
+
+func TestValidateTags(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		tags        map[string]string
+		expectError bool
+	}{
+		{name: "no tags", tags: nil},
+		{name: "valid", tags: map[string]string{"env": "prod", "cost_center": "r-d-42", "empty": ""}},
+		{name: "upper case key", tags: map[string]string{"Env": "prod"}, expectError: true},
+		{name: "digit key", tags: map[string]string{"1env": "prod"}, expectError: true},
+		{name: "dotted key", tags: map[string]string{"team.name": "pipeline"}, expectError: true},
+		{name: "upper case value", tags: map[string]string{"env": "Prod"}, expectError: true},
+		{name: "value ending with dash", tags: map[string]string{"env": "prod-"}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateTags(tc.tags)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateTags: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+
+	tags := map[string]string{"env": "prod", "team": "pipeline"}
+	cases := []struct {
+		name    string
+		filters []string
+		match   bool
+	}{
+		{name: "no filters", filters: nil, match: true},
+		{name: "key", filters: []string{"env"}, match: true},
+		{name: "key and value", filters: []string{"env:prod", "team:pipeline"}, match: true},
+		{name: "other value", filters: []string{"env:dev"}, match: false},
+		{name: "missing key", filters: []string{"env:prod", "owner"}, match: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if match := cluster.MatchTags(tags, tc.filters); match != tc.match {
+				t.Errorf("Expected match: %t, got: %t", tc.match, match)
+			}
+		})
+	}
+}
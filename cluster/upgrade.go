@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+//UpgradeRequest describes the Kubernetes version upgrade of a cluster, the node pools are upgraded after the
+//control plane. MaxSurge is the number of extra nodes of a node pool during its upgrade and MaxUnavailable the
+//number of nodes upgraded at once, the defaults of the provider are used without them.
+type UpgradeRequest struct {
+	Version        string `json:"version" binding:"required"`
+	MaxSurge       int    `json:"maxSurge,omitempty"`
+	MaxUnavailable int    `json:"maxUnavailable,omitempty"`
+}
+
+//ClusterUpgrader is implemented by the clusters whose Kubernetes version can be upgraded
+type ClusterUpgrader interface {
+	// KubernetesVersion returns the current version of the control plane
+	KubernetesVersion() (string, error)
+	// UpgradeControlPlane upgrades the control plane and waits for it
+	UpgradeControlPlane(version string) error
+	// UpgradeNodePool upgrades the nodes of the pool with the surge settings and waits for them
+	UpgradeNodePool(pool model.NodePoolModel, version string, maxSurge, maxUnavailable int) error
+}
+
+func clusterUpgrader(commonCluster CommonCluster) (ClusterUpgrader, error) {
+	upgrader, ok := commonCluster.(ClusterUpgrader)
+	if !ok {
+		return nil, fmt.Errorf("Kubernetes upgrades are not supported on %s", commonCluster.GetType())
+	}
+	return upgrader, nil
+}
+
+// parseKubernetesVersion parses the version without the suffix of the providers, like 1.10.5-gke.0
+func parseKubernetesVersion(version string) (*semver.Version, error) {
+	core := strings.SplitN(strings.SplitN(version, "-", 2)[0], "+", 2)[0]
+	parsed, err := semver.NewVersion(core)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes version %s", version)
+	}
+	return parsed, nil
+}
+
+//ValidateUpgrade validates the upgrade of the cluster from the current version, the minor version of
+//Kubernetes can be increased by one
+func ValidateUpgrade(commonCluster CommonCluster, current string, request UpgradeRequest) error {
+	if request.MaxSurge < 0 || request.MaxUnavailable < 0 {
+		return errors.New("maxSurge and maxUnavailable can't be negative")
+	}
+	switch commonCluster.(type) {
+	case *AKSCluster:
+		// the agent pools are upgraded with the control plane
+		if request.MaxSurge != 0 || request.MaxUnavailable != 0 {
+			return fmt.Errorf("surge settings are not supported on %s", commonCluster.GetType())
+		}
+	case *EKSCluster:
+		if request.MaxSurge != 0 {
+			return fmt.Errorf("maxSurge is not supported on %s, the node groups have maxUnavailable", commonCluster.GetType())
+		}
+	}
+	target, err := parseKubernetesVersion(request.Version)
+	if err != nil {
+		return err
+	}
+	from, err := parseKubernetesVersion(current)
+	if err != nil {
+		return err
+	}
+	if !target.GreaterThan(from) {
+		return fmt.Errorf("cluster version %s can't be upgraded to %s", current, request.Version)
+	}
+	if target.Major() != from.Major() || target.Minor() > from.Minor()+1 {
+		return fmt.Errorf("cluster version %s can be upgraded to the next minor version, not to %s", current, request.Version)
+	}
+	return nil
+}
+
+//CheckUpgrade runs the pre-flight checks of the upgrade: the version is validated by ValidateUpgrade
+//and the kubeVersion constraints of the charts of the deployments must allow the new version
+func CheckUpgrade(commonCluster CommonCluster, request UpgradeRequest) error {
+	upgrader, err := clusterUpgrader(commonCluster)
+	if err != nil {
+		return err
+	}
+	current, err := upgrader.KubernetesVersion()
+	if err != nil {
+		return err
+	}
+	if err := ValidateUpgrade(commonCluster, current, request); err != nil {
+		return err
+	}
+
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	constraints, err := helm.KubeVersionConstraints(kubeConfig, commonCluster.GetName())
+	if err != nil {
+		return errors.Wrap(err, "error checking the deployments")
+	}
+	return CheckKubeVersionConstraints(constraints, request.Version)
+}
+
+//CheckKubeVersionConstraints checks that the kubeVersion constraints of the deployments allow the version
+func CheckKubeVersionConstraints(constraints []helm.KubeVersionConstraint, version string) error {
+	target, err := parseKubernetesVersion(version)
+	if err != nil {
+		return err
+	}
+	var incompatible []string
+	for _, c := range constraints {
+		constraint, err := semver.NewConstraint(c.Constraint)
+		if err != nil {
+			log.Warnf("Invalid kubeVersion %s of chart %s: %s", c.Constraint, c.Chart, err.Error())
+			continue
+		}
+		if !constraint.Check(target) {
+			incompatible = append(incompatible, fmt.Sprintf("%s (%s needs %s)", c.Release, c.Chart, c.Constraint))
+		}
+	}
+	if len(incompatible) != 0 {
+		return fmt.Errorf("deployments incompatible with Kubernetes %s: %s", version, strings.Join(incompatible, ", "))
+	}
+	return nil
+}
+
+//UpgradeCluster upgrades the control plane and then the node pools of the cluster checked by CheckUpgrade,
+//the upgrade of every node pool is a step of the operation
+func UpgradeCluster(commonCluster CommonCluster, request UpgradeRequest) error {
+	log := logger.WithFields(logrus.Fields{"action": constants.TagUpdateCluster})
+	upgrader, err := clusterUpgrader(commonCluster)
+	if err != nil {
+		return err
+	}
+	err = RunStep(commonCluster, "UpgradeControlPlane", func() error {
+		return upgrader.UpgradeControlPlane(request.Version)
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Control plane of cluster %s upgraded to %s", commonCluster.GetName(), request.Version)
+	if err := commonCluster.Persist(); err != nil {
+		return err
+	}
+
+	for _, pool := range commonCluster.GetModel().NodePools {
+		pool := pool
+		err := RunStep(commonCluster, "UpgradeNodePool "+pool.Name, func() error {
+			return upgrader.UpgradeNodePool(pool, request.Version, request.MaxSurge, request.MaxUnavailable)
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("Node pool %s upgraded to %s", pool.Name, request.Version)
+	}
+	return commonCluster.Persist()
+}
@@ -0,0 +1,66 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/helm"
+)
+
+func TestValidateUpgrade(t *testing.T) {
+
+	aksCluster, err := cluster.CreateCommonClusterFromRequest(aksCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+	gkeCluster, err := cluster.CreateCommonClusterFromRequest(gkeCreateFull, organizationId)
+	if err != nil {
+		t.Fatalf("Error during CreateCommonClusterFromRequest: %s", err.Error())
+	}
+
+	cases := []struct {
+		name          string
+		commonCluster cluster.CommonCluster
+		current       string
+		request       cluster.UpgradeRequest
+		expectError   bool
+	}{
+		{name: "patch", commonCluster: aksCluster, current: "1.9.6", request: cluster.UpgradeRequest{Version: "1.9.9"}},
+		{name: "minor", commonCluster: gkeCluster, current: "1.9.7-gke.3", request: cluster.UpgradeRequest{Version: "1.10.5-gke.0", MaxSurge: 1}},
+		{name: "same version", commonCluster: aksCluster, current: "1.9.6", request: cluster.UpgradeRequest{Version: "1.9.6"}, expectError: true},
+		{name: "downgrade", commonCluster: gkeCluster, current: "1.10.5-gke.0", request: cluster.UpgradeRequest{Version: "1.9.7-gke.3"}, expectError: true},
+		{name: "two minor versions", commonCluster: aksCluster, current: "1.8.11", request: cluster.UpgradeRequest{Version: "1.10.3"}, expectError: true},
+		{name: "invalid version", commonCluster: aksCluster, current: "1.9.6", request: cluster.UpgradeRequest{Version: "latest"}, expectError: true},
+		{name: "surge on AKS", commonCluster: aksCluster, current: "1.9.6", request: cluster.UpgradeRequest{Version: "1.9.9", MaxSurge: 1}, expectError: true},
+		{name: "negative surge", commonCluster: gkeCluster, current: "1.9.7", request: cluster.UpgradeRequest{Version: "1.10.5", MaxUnavailable: -1}, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cluster.ValidateUpgrade(tc.commonCluster, tc.current, tc.request)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateUpgrade: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestCheckKubeVersionConstraints(t *testing.T) {
+
+	constraints := []helm.KubeVersionConstraint{
+		{Release: "ingress", Chart: "nginx-ingress-0.20.0", Constraint: ">=1.8.0"},
+		{Release: "monitor", Chart: "prometheus-6.0.0", Constraint: ">=1.8.0, <1.10.0"},
+	}
+	if err := cluster.CheckKubeVersionConstraints(constraints, "1.9.7-gke.3"); err != nil {
+		t.Errorf("Error during CheckKubeVersionConstraints: %s", err.Error())
+	}
+	if err := cluster.CheckKubeVersionConstraints(constraints, "1.10.5-gke.0"); err == nil {
+		t.Errorf("Expected error, the monitor deployment doesn't support 1.10")
+	}
+}
@@ -0,0 +1,416 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// gibibyte is the unit of the memory of the utilization
+const gibibyte = 1 << 30
+
+// the Prometheus queries of the usage of the nodes when the metrics server isn't running, the cAdvisor metrics
+// of the root cgroup of the nodes are labeled with the hostname of the nodes
+const (
+	nodeCPUUsageQuery    = `sum by (kubernetes_io_hostname) (rate(container_cpu_usage_seconds_total{id="/"}[5m]))`
+	nodeMemoryUsageQuery = `sum by (kubernetes_io_hostname) (container_memory_working_set_bytes{id="/"})`
+	nodeHostnameLabel    = "kubernetes_io_hostname"
+)
+
+// nodeResources are the CPU cores and the memory GiB of a node or a node pool
+type nodeResources struct {
+	CPU    float64
+	Memory float64
+}
+
+//RunUtilizationTracking samples the utilization of the clusters with the given interval, it never returns
+func RunUtilizationTracking(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"action": "UtilizationTracking"})
+	for range time.Tick(interval) {
+		if err := RecordClusterUtilization(); err != nil {
+			log.Errorf("Error recording the cluster utilization: %s", err.Error())
+		}
+	}
+}
+
+//RecordClusterUtilization adds a sample of the utilization of the node pools of the running clusters to their
+//daily utilization: the allocatable resources of the nodes, the requests of the pods scheduled to the nodes and
+//the usage of the nodes from the metrics server (or the Prometheus of the monitoring add-on)
+func RecordClusterUtilization() error {
+	log := logger.WithFields(logrus.Fields{"action": "UtilizationTracking"})
+	var clusters []model.ClusterModel
+	err := model.GetDB().Where("status IN (?)", []string{StatusRunning, StatusUpdating}).Find(&clusters).Error
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC().Format(costDateFormat)
+	for i := range clusters {
+		commonCluster, err := GetCommonClusterFromModel(&clusters[i])
+		if err != nil {
+			log.Warnf("Error loading cluster %s: %s", clusters[i].Name, err.Error())
+			continue
+		}
+		samples, err := clusterUtilization(commonCluster)
+		if err != nil {
+			log.Warnf("The utilization of cluster %s isn't recorded: %s", commonCluster.GetName(), err.Error())
+			continue
+		}
+		for _, sample := range samples {
+			sample.Date = date
+			if err := model.AddClusterUtilization(sample); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clusterUtilization returns a sample of the utilization of the node pools of the cluster
+func clusterUtilization(commonCluster CommonCluster) ([]model.ClusterUtilizationModel, error) {
+	log := logger.WithFields(logrus.Fields{"action": "UtilizationTracking"})
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := helm.GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := nodePoolsOfNodes(commonCluster, client)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	usage, err := nodeUsage(commonCluster, client)
+	if err != nil {
+		log.Warnf("The usage of cluster %s isn't sampled: %s", commonCluster.GetName(), err.Error())
+	}
+	requested := map[string]nodeResources{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		requests := requested[pod.Spec.NodeName]
+		for _, container := range pod.Spec.Containers {
+			requests.CPU += cores(container.Resources.Requests[v1.ResourceCPU])
+			requests.Memory += gibibytes(container.Resources.Requests[v1.ResourceMemory])
+		}
+		requested[pod.Spec.NodeName] = requests
+	}
+	modelCluster := commonCluster.GetModel()
+	samples := map[string]*model.ClusterUtilizationModel{}
+	for _, node := range nodes.Items {
+		pool := pools[node.Name]
+		sample, ok := samples[pool]
+		if !ok {
+			sample = &model.ClusterUtilizationModel{
+				OrganizationID: modelCluster.OrganizationId,
+				ClusterModelID: modelCluster.ID,
+				NodePool:       pool,
+				ClusterName:    modelCluster.Name,
+				Samples:        1,
+			}
+			if usage != nil {
+				sample.UsedSamples = 1
+			}
+			samples[pool] = sample
+		}
+		sample.AllocatableCPU += cores(node.Status.Allocatable[v1.ResourceCPU])
+		sample.AllocatableMemory += gibibytes(node.Status.Allocatable[v1.ResourceMemory])
+		sample.RequestedCPU += requested[node.Name].CPU
+		sample.RequestedMemory += requested[node.Name].Memory
+		sample.UsedCPU += usage[node.Name].CPU
+		sample.UsedMemory += usage[node.Name].Memory
+	}
+	utilization := []model.ClusterUtilizationModel{}
+	for _, sample := range samples {
+		utilization = append(utilization, *sample)
+	}
+	return utilization, nil
+}
+
+// nodePoolsOfNodes returns the node pools of the nodes of the cluster by the selectors of the node pools, the
+// nodes of the clusters without node pools have no node pool
+func nodePoolsOfNodes(commonCluster CommonCluster, client *kubernetes.Clientset) (map[string]string, error) {
+	pools := map[string]string{}
+	manager, ok := commonCluster.(NodePoolManager)
+	if !ok {
+		return pools, nil
+	}
+	nodePools := commonCluster.GetModel().NodePools
+	if len(nodePools) == 0 {
+		nodePools = defaultNodePools(commonCluster)
+	}
+	for _, pool := range nodePools {
+		list, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: manager.NodePoolSelector(pool.Name)})
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range list.Items {
+			pools[node.Name] = pool.Name
+		}
+	}
+	return pools, nil
+}
+
+// nodeUsage returns the CPU and memory usage of the nodes from the metrics server, or from the Prometheus of
+// the monitoring add-on if the metrics server isn't running
+func nodeUsage(commonCluster CommonCluster, client *kubernetes.Clientset) (map[string]nodeResources, error) {
+	data, err := client.CoreV1().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/nodes").DoRaw()
+	if err == nil {
+		return parseNodeMetrics(data)
+	}
+	monitoring, addonErr := model.GetAddon(commonCluster.GetID(), AddonMonitoring)
+	if addonErr != nil {
+		return nil, errors.Wrap(err, "the metrics server isn't running and the monitoring add-on isn't installed")
+	}
+	usage := map[string]nodeResources{}
+	service := monitoring.ReleaseName + "-prometheus-server"
+	for query, set := range map[string]func(*nodeResources, float64){
+		nodeCPUUsageQuery:    func(r *nodeResources, value float64) { r.CPU = value },
+		nodeMemoryUsageQuery: func(r *nodeResources, value float64) { r.Memory = value / gibibyte },
+	} {
+		data, err := client.CoreV1().Services(helm.DefaultNamespace).ProxyGet("http", service, "80", "api/v1/query",
+			map[string]string{"query": query}).DoRaw()
+		if err != nil {
+			return nil, err
+		}
+		values, err := parsePrometheusVector(data, nodeHostnameLabel)
+		if err != nil {
+			return nil, err
+		}
+		for node, value := range values {
+			resources := usage[node]
+			set(&resources, value)
+			usage[node] = resources
+		}
+	}
+	return usage, nil
+}
+
+// parseNodeMetrics returns the usage of the nodes of the metrics server response
+func parseNodeMetrics(data []byte) (map[string]nodeResources, error) {
+	var metrics struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Usage map[string]string `json:"usage"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+	usage := map[string]nodeResources{}
+	for _, item := range metrics.Items {
+		cpu, err := resource.ParseQuantity(item.Usage["cpu"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CPU usage of node %s", item.Metadata.Name)
+		}
+		memory, err := resource.ParseQuantity(item.Usage["memory"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid memory usage of node %s", item.Metadata.Name)
+		}
+		usage[item.Metadata.Name] = nodeResources{CPU: cores(cpu), Memory: gibibytes(memory)}
+	}
+	return usage, nil
+}
+
+// parsePrometheusVector returns the values of the vector of the response of a query by the label of its series
+func parsePrometheusVector(data []byte, label string) (map[string]float64, error) {
+	var response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Value  []interface{}     `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s", response.Error)
+	}
+	if response.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("the query must return a vector, not a %s", response.Data.ResultType)
+	}
+	values := map[string]float64{}
+	for _, series := range response.Data.Result {
+		if len(series.Value) != 2 {
+			return nil, fmt.Errorf("invalid prometheus sample: %v", series.Value)
+		}
+		value, _ := series.Value[1].(string)
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prometheus sample: %v", series.Value)
+		}
+		values[series.Metric[label]] = f
+	}
+	return values, nil
+}
+
+// cores returns the CPU quantity in cores
+func cores(quantity resource.Quantity) float64 {
+	return float64(quantity.MilliValue()) / 1000
+}
+
+// gibibytes returns the memory quantity in GiB
+func gibibytes(quantity resource.Quantity) float64 {
+	return float64(quantity.Value()) / gibibyte
+}
+
+//ResourceUtilization is the average allocatable, requested and used amount of a resource (CPU cores or memory
+//GiB) and the requested and used share of the allocatable
+type ResourceUtilization struct {
+	Allocatable    float64 `json:"allocatable"`
+	Requested      float64 `json:"requested"`
+	Used           float64 `json:"used"`
+	RequestedRatio float64 `json:"requestedRatio"`
+	UsedRatio      float64 `json:"usedRatio"`
+}
+
+//NodePoolUtilization is the utilization of a node pool in a utilization report with the waste of its spend: the
+//idle cost is the spend of the resources not requested by the pods, the unused cost is the spend of the
+//resources not used (requested or not); the larger share of CPU and memory counts
+type NodePoolUtilization struct {
+	NodePool   string              `json:"nodePool"`
+	CPU        ResourceUtilization `json:"cpu"`
+	Memory     ResourceUtilization `json:"memory"`
+	Cost       float64             `json:"cost"`
+	IdleCost   float64             `json:"idleCost"`
+	UnusedCost float64             `json:"unusedCost"`
+}
+
+//UtilizationReport is the utilization of the node pools of a cluster and the waste of their spend between two
+//days, the cluster is the total of its node pools
+type UtilizationReport struct {
+	From       string                `json:"from"`
+	To         string                `json:"to"`
+	Currency   string                `json:"currency"`
+	CPU        ResourceUtilization   `json:"cpu"`
+	Memory     ResourceUtilization   `json:"memory"`
+	Cost       float64               `json:"cost"`
+	IdleCost   float64               `json:"idleCost"`
+	UnusedCost float64               `json:"unusedCost"`
+	NodePools  []NodePoolUtilization `json:"nodePools"`
+}
+
+// utilizationSums are the sums of the samples of the daily utilization
+type utilizationSums struct {
+	samples, usedSamples               int
+	allocatableCPU, requestedCPU       float64
+	usedCPU                            float64
+	allocatableMemory, requestedMemory float64
+	usedMemory                         float64
+}
+
+func (s *utilizationSums) add(u model.ClusterUtilizationModel) {
+	s.samples += u.Samples
+	s.usedSamples += u.UsedSamples
+	s.allocatableCPU += u.AllocatableCPU
+	s.requestedCPU += u.RequestedCPU
+	s.usedCPU += u.UsedCPU
+	s.allocatableMemory += u.AllocatableMemory
+	s.requestedMemory += u.RequestedMemory
+	s.usedMemory += u.UsedMemory
+}
+
+// resources returns the average utilization of the CPU and the memory
+func (s *utilizationSums) resources() (cpu, memory ResourceUtilization) {
+	average := func(allocatable, requested, used float64) ResourceUtilization {
+		r := ResourceUtilization{}
+		if s.samples > 0 {
+			r.Allocatable, r.Requested = allocatable/float64(s.samples), requested/float64(s.samples)
+		}
+		if s.usedSamples > 0 {
+			r.Used = used / float64(s.usedSamples)
+		}
+		if r.Allocatable > 0 {
+			r.RequestedRatio, r.UsedRatio = r.Requested/r.Allocatable, r.Used/r.Allocatable
+		}
+		return r
+	}
+	return average(s.allocatableCPU, s.requestedCPU, s.usedCPU), average(s.allocatableMemory, s.requestedMemory, s.usedMemory)
+}
+
+// wastedShare returns the share of the resources not covered by the larger CPU or memory ratio
+func wastedShare(cpuRatio, memoryRatio float64) float64 {
+	ratio := cpuRatio
+	if memoryRatio > ratio {
+		ratio = memoryRatio
+	}
+	if ratio > 1 {
+		return 0
+	}
+	return 1 - ratio
+}
+
+//NewUtilizationReport sums the daily utilization per node pool and prices the waste of the node pools with their
+//daily costs, the days without usage samples have no unused cost
+func NewUtilizationReport(from, to string, utilization []model.ClusterUtilizationModel, costs []model.ClusterCostModel) *UtilizationReport {
+	report := &UtilizationReport{From: from, To: to, Currency: pricingCurrency, NodePools: []NodePoolUtilization{}}
+	dailyCosts := map[string]float64{}
+	for _, cost := range costs {
+		if cost.NodePool != controlPlaneNodePool {
+			dailyCosts[cost.Date+"/"+cost.NodePool] += cost.Cost
+		}
+	}
+	total := &utilizationSums{}
+	sums := map[string]*utilizationSums{}
+	pools := map[string]*NodePoolUtilization{}
+	for _, daily := range utilization {
+		pool, ok := pools[daily.NodePool]
+		if !ok {
+			pool = &NodePoolUtilization{NodePool: daily.NodePool}
+			pools[daily.NodePool], sums[daily.NodePool] = pool, &utilizationSums{}
+		}
+		sums[daily.NodePool].add(daily)
+		total.add(daily)
+		day := &utilizationSums{}
+		day.add(daily)
+		cpu, memory := day.resources()
+		cost := dailyCosts[daily.Date+"/"+daily.NodePool]
+		pool.Cost += cost
+		pool.IdleCost += cost * wastedShare(cpu.RequestedRatio, memory.RequestedRatio)
+		if daily.UsedSamples > 0 {
+			pool.UnusedCost += cost * wastedShare(cpu.UsedRatio, memory.UsedRatio)
+		}
+	}
+	for name, pool := range pools {
+		pool.CPU, pool.Memory = sums[name].resources()
+		report.Cost += pool.Cost
+		report.IdleCost += pool.IdleCost
+		report.UnusedCost += pool.UnusedCost
+		report.NodePools = append(report.NodePools, *pool)
+	}
+	sort.Slice(report.NodePools, func(i, j int) bool { return report.NodePools[i].NodePool < report.NodePools[j].NodePool })
+	// the node pools are sampled together so the total of the samples of a day is the samples of a node pool
+	if len(pools) > 0 {
+		total.samples /= len(pools)
+		total.usedSamples /= len(pools)
+	}
+	report.CPU, report.Memory = total.resources()
+	return report
+}
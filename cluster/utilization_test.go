@@ -0,0 +1,45 @@
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/model"
+)
+
+func TestNewUtilizationReport(t *testing.T) {
+
+	utilization := []model.ClusterUtilizationModel{
+		{ClusterModelID: 1, NodePool: "pool2", Date: "2026-10-01", Samples: 2,
+			AllocatableCPU: 4, RequestedCPU: 4, AllocatableMemory: 8, RequestedMemory: 2},
+		{ClusterModelID: 1, NodePool: "pool1", Date: "2026-10-01", Samples: 2, UsedSamples: 2,
+			AllocatableCPU: 8, RequestedCPU: 4, UsedCPU: 2, AllocatableMemory: 32, RequestedMemory: 8, UsedMemory: 16},
+	}
+	costs := []model.ClusterCostModel{
+		{ClusterModelID: 1, NodePool: "", Date: "2026-10-01", Cost: 3},
+		{ClusterModelID: 1, NodePool: "pool1", Date: "2026-10-01", Cost: 10},
+		{ClusterModelID: 1, NodePool: "pool2", Date: "2026-10-01", Cost: 6},
+	}
+	report := cluster.NewUtilizationReport("2026-10-01", "2026-10-01", utilization, costs)
+
+	if len(report.NodePools) != 2 || report.NodePools[0].NodePool != "pool1" {
+		t.Fatalf("Expected pool1 and pool2, got: %v", report.NodePools)
+	}
+	pool1 := report.NodePools[0]
+	if pool1.CPU.Allocatable != 4 || pool1.CPU.RequestedRatio != 0.5 || pool1.Memory.UsedRatio != 0.5 {
+		t.Errorf("Expected the daily averages of pool1, got: %v", pool1)
+	}
+	if pool1.IdleCost != 5 || pool1.UnusedCost != 5 {
+		t.Errorf("Expected idle and unused cost 5, got: %f, %f", pool1.IdleCost, pool1.UnusedCost)
+	}
+	pool2 := report.NodePools[1]
+	if pool2.IdleCost != 0 || pool2.UnusedCost != 0 || pool2.CPU.Used != 0 {
+		t.Errorf("Expected no waste of the fully requested pool2 without usage, got: %v", pool2)
+	}
+	if report.Cost != 16 || report.IdleCost != 5 {
+		t.Errorf("Expected the cost 16 of the node pools with idle cost 5, got: %f, %f", report.Cost, report.IdleCost)
+	}
+	if report.CPU.Allocatable != 6 || report.CPU.Requested != 4 {
+		t.Errorf("Expected 6 allocatable and 4 requested cores of the cluster, got: %v", report.CPU)
+	}
+}
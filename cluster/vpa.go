@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/banzaicloud/pipeline/helm"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/pkg/errors"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AddonVPA is the add-on recommending the resource requests of the workloads, the pods aren't updated
+const AddonVPA = "vpa"
+
+const vpaAPIVersion = "autoscaling.k8s.io/v1beta2"
+
+// releaseWorkloadLabels are the labels of the workloads of the releases set by the charts
+var releaseWorkloadLabels = []string{"release", "app.kubernetes.io/instance"}
+
+//ContainerRecommendation is the recommended CPU and memory requests of a container next to its current
+//requests: the target and the bounds of the requests the container used in the recommendation history
+type ContainerRecommendation struct {
+	Container  string            `json:"container"`
+	Requests   map[string]string `json:"requests"`
+	Target     map[string]string `json:"target,omitempty"`
+	LowerBound map[string]string `json:"lowerBound,omitempty"`
+	UpperBound map[string]string `json:"upperBound,omitempty"`
+}
+
+//WorkloadRecommendation is the recommendation of the containers of a workload of a deployment, Pending is true
+//until the recommender has enough samples
+type WorkloadRecommendation struct {
+	Kind       string                    `json:"kind"`
+	Name       string                    `json:"name"`
+	Pending    bool                      `json:"pending"`
+	Containers []ContainerRecommendation `json:"containers"`
+}
+
+// workloadResource returns the apps/v1 workload of the kind in the default namespace
+func workloadResource(kind, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": helm.DefaultNamespace},
+	}}
+}
+
+// vpaResource returns the vertical pod autoscaler of the workload in recommendation mode
+func vpaResource(release, kind, name string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": vpaAPIVersion,
+		"kind":       "VerticalPodAutoscaler",
+		"metadata":   map[string]interface{}{"name": name, "namespace": helm.DefaultNamespace},
+	}}
+	if release != "" {
+		object.SetLabels(map[string]string{hpaReleaseLabel: release})
+		object.Object["spec"] = map[string]interface{}{
+			"targetRef":    map[string]interface{}{"apiVersion": "apps/v1", "kind": kind, "name": name},
+			"updatePolicy": map[string]interface{}{"updateMode": "Off"},
+		}
+	}
+	return object
+}
+
+// releaseWorkloads returns the Deployments and the StatefulSets of the release by the labels of the charts
+func releaseWorkloads(kubeConfig *[]byte, release string) ([]unstructured.Unstructured, error) {
+	workloads := []unstructured.Unstructured{}
+	seen := map[string]bool{}
+	for kind := range autoscalingTargetKinds {
+		for _, label := range releaseWorkloadLabels {
+			items, err := helm.ListManifests(kubeConfig, workloadResource(kind, ""), map[string]string{label: release})
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				if key := kind + "/" + item.GetName(); !seen[key] {
+					seen[key] = true
+					workloads = append(workloads, item)
+				}
+			}
+		}
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].GetKind() != workloads[j].GetKind() {
+			return workloads[i].GetKind() < workloads[j].GetKind()
+		}
+		return workloads[i].GetName() < workloads[j].GetName()
+	})
+	return workloads, nil
+}
+
+//RecommendationFromResources returns the recommendation of the vertical pod autoscaler of the workload, it's
+//pending if the autoscaler is nil or has no recommendation yet
+func RecommendationFromResources(workload, vpa *unstructured.Unstructured) WorkloadRecommendation {
+	var spec struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Name      string `json:"name"`
+						Resources struct {
+							Requests map[string]string `json:"requests"`
+						} `json:"resources"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	data, _ := json.Marshal(workload.Object)
+	json.Unmarshal(data, &spec)
+	var status struct {
+		Status struct {
+			Recommendation struct {
+				ContainerRecommendations []struct {
+					ContainerName string            `json:"containerName"`
+					Target        map[string]string `json:"target"`
+					LowerBound    map[string]string `json:"lowerBound"`
+					UpperBound    map[string]string `json:"upperBound"`
+				} `json:"containerRecommendations"`
+			} `json:"recommendation"`
+		} `json:"status"`
+	}
+	if vpa != nil {
+		data, _ = json.Marshal(vpa.Object)
+		json.Unmarshal(data, &status)
+	}
+	recommendation := WorkloadRecommendation{
+		Kind:       workload.GetKind(),
+		Name:       workload.GetName(),
+		Pending:    len(status.Status.Recommendation.ContainerRecommendations) == 0,
+		Containers: []ContainerRecommendation{},
+	}
+	for _, container := range spec.Spec.Template.Spec.Containers {
+		containerRecommendation := ContainerRecommendation{Container: container.Name, Requests: container.Resources.Requests}
+		if containerRecommendation.Requests == nil {
+			containerRecommendation.Requests = map[string]string{}
+		}
+		for _, recommended := range status.Status.Recommendation.ContainerRecommendations {
+			if recommended.ContainerName == container.Name {
+				containerRecommendation.Target = recommended.Target
+				containerRecommendation.LowerBound = recommended.LowerBound
+				containerRecommendation.UpperBound = recommended.UpperBound
+			}
+		}
+		recommendation.Containers = append(recommendation.Containers, containerRecommendation)
+	}
+	return recommendation
+}
+
+//GetDeploymentRecommendations returns the recommended requests of the workloads of the deployment, the missing
+//vertical pod autoscalers of the workloads are created in recommendation mode so their recommendations are
+//pending until the recommender of the vpa add-on has enough samples
+func GetDeploymentRecommendations(commonCluster CommonCluster, release string) ([]WorkloadRecommendation, error) {
+	if _, err := model.GetAddon(commonCluster.GetID(), AddonVPA); err != nil {
+		if model.IsErrorGormNotFound(err) {
+			return nil, fmt.Errorf("the recommendations need the %s add-on", AddonVPA)
+		}
+		return nil, err
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := releaseWorkloads(kubeConfig, release)
+	if err != nil {
+		return nil, err
+	}
+	recommendations := []WorkloadRecommendation{}
+	for i := range workloads {
+		workload := &workloads[i]
+		vpa, err := helm.GetManifest(kubeConfig, vpaResource("", workload.GetKind(), workload.GetName()))
+		if k8sErrors.IsNotFound(err) {
+			vpa = nil
+			object := vpaResource(release, workload.GetKind(), workload.GetName())
+			if _, err := helm.ApplyManifests(kubeConfig, []*unstructured.Unstructured{object}, nil); err != nil {
+				return nil, errors.Wrapf(err, "error creating the vertical pod autoscaler of %s %s", workload.GetKind(), workload.GetName())
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		recommendations = append(recommendations, RecommendationFromResources(workload, vpa))
+	}
+	return recommendations, nil
+}
+
+//DeleteReleaseRecommendations deletes the vertical pod autoscalers of the deleted deployment
+func DeleteReleaseRecommendations(commonCluster CommonCluster, release string) error {
+	if _, err := model.GetAddon(commonCluster.GetID(), AddonVPA); err != nil {
+		return nil
+	}
+	kubeConfig, err := commonCluster.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	items, err := helm.ListManifests(kubeConfig, vpaResource("", "", ""), map[string]string{hpaReleaseLabel: release})
+	if err != nil {
+		return err
+	}
+	objects := []*unstructured.Unstructured{}
+	for i := range items {
+		objects = append(objects, &items[i])
+	}
+	return helm.DeleteManifests(kubeConfig, objects)
+}
@@ -0,0 +1,59 @@
+package cluster_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/cluster"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRecommendationFromResources(t *testing.T) {
+
+	workload := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web"},
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "1", "memory": "1Gi"}}},
+				map[string]interface{}{"name": "sidecar"},
+			},
+		}}},
+	}}
+	vpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"recommendation": map[string]interface{}{"containerRecommendations": []interface{}{
+			map[string]interface{}{
+				"containerName": "app",
+				"target":        map[string]interface{}{"cpu": "250m", "memory": "300Mi"},
+				"lowerBound":    map[string]interface{}{"cpu": "100m", "memory": "200Mi"},
+				"upperBound":    map[string]interface{}{"cpu": "500m", "memory": "600Mi"},
+			},
+		}}},
+	}}
+
+	cases := []struct {
+		name     string
+		vpa      *unstructured.Unstructured
+		expected cluster.WorkloadRecommendation
+	}{
+		{name: "recommended", vpa: vpa, expected: cluster.WorkloadRecommendation{Kind: "Deployment", Name: "web", Containers: []cluster.ContainerRecommendation{
+			{Container: "app", Requests: map[string]string{"cpu": "1", "memory": "1Gi"}, Target: map[string]string{"cpu": "250m", "memory": "300Mi"},
+				LowerBound: map[string]string{"cpu": "100m", "memory": "200Mi"}, UpperBound: map[string]string{"cpu": "500m", "memory": "600Mi"}},
+			{Container: "sidecar", Requests: map[string]string{}},
+		}}},
+		{name: "pending", expected: cluster.WorkloadRecommendation{Kind: "Deployment", Name: "web", Pending: true, Containers: []cluster.ContainerRecommendation{
+			{Container: "app", Requests: map[string]string{"cpu": "1", "memory": "1Gi"}},
+			{Container: "sidecar", Requests: map[string]string{}},
+		}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recommendation := cluster.RecommendationFromResources(workload, tc.vpa)
+			if !reflect.DeepEqual(recommendation, tc.expected) {
+				t.Errorf("Expected %v, got: %v", tc.expected, recommendation)
+			}
+		})
+	}
+}
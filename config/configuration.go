@@ -25,24 +25,144 @@ func init() {
 	// Set defaults TODO expand defaults
 	viper.SetDefault("drone.enabled", false)
 	viper.SetDefault("drone.url", "http://localhost:8000")
+	viper.SetDefault("drone.pipelineEndpoint", "")
+	viper.SetDefault("drone.pluginImage", "banzaicloud/plugin-pipeline-client:latest")
+	viper.SetDefault("scm.provider", "github")
+	viper.SetDefault("scm.apiURL", "")
+	viper.SetDefault("scm.statusContext", "pipeline/deploy")
+	viper.SetDefault("imagescan.scanner", "trivy")
+	viper.SetDefault("imagescan.trivyBinary", "trivy")
+	viper.SetDefault("imagescan.trivyServer", "")
+	viper.SetDefault("imagescan.timeout", "5m")
+	viper.SetDefault("imagescan.cacheTTL", "1h")
 	viper.SetDefault("helm.retryAttempt", 30)
 	viper.SetDefault("helm.retrySleepSeconds", 15)
 	viper.SetDefault("helm.stableRepositoryURL", "https://kubernetes-charts.storage.googleapis.com")
 	viper.SetDefault("helm.banzaiRepositoryURL", "http://kubernetes-charts.banzaicloud.com")
+	viper.SetDefault("helm.repositoryRefreshInterval", "1h")
+	viper.SetDefault("helm.valuesSchemaDir", "")
+	viper.SetDefault("helm.rolloutInterval", "10s")
+	viper.SetDefault("helm.rolloutTimeout", "5m")
+	viper.SetDefault("autoscaler.chart", "stable/cluster-autoscaler")
+	viper.SetDefault("autoscaler.release", "autoscaler")
+	viper.SetDefault("addons.ingress.chart", "banzaicloud-stable/pipeline-cluster-ingress")
+	viper.SetDefault("addons.ingress.version", "0.0.6")
+	viper.SetDefault("addons.monitoring.chart", "stable/prometheus")
+	viper.SetDefault("addons.monitoring.version", "6.7.4")
+	viper.SetDefault("addons.grafana.chart", "stable/grafana")
+	viper.SetDefault("addons.grafana.version", "1.11.6")
+	viper.SetDefault("monitoring.dashboards", map[string]interface{}{
+		"kubernetes-cluster": 315,
+		"node-exporter":      1860,
+	})
+	viper.SetDefault("addons.logging.chart", "stable/fluent-bit")
+	viper.SetDefault("addons.logging.version", "2.8.17")
+	viper.SetDefault("addons.logging.fluentBitVersion", "1.6.10")
+	viper.SetDefault("addons.cert-manager.chart", "stable/cert-manager")
+	viper.SetDefault("addons.cert-manager.version", "v0.3.2")
+	viper.SetDefault("addons.autoscaler.version", "0.6.4")
+	viper.SetDefault("addons.external-dns.chart", "stable/external-dns")
+	viper.SetDefault("addons.external-dns.version", "0.7.1")
+	viper.SetDefault("addons.backup.chart", "vmware-tanzu/velero")
+	viper.SetDefault("addons.backup.version", "2.7.4")
+	viper.SetDefault("addons.backup.plugins.amazon", "velero/velero-plugin-for-aws:v1.0.1")
+	viper.SetDefault("addons.backup.plugins.google", "velero/velero-plugin-for-gcp:v1.0.1")
+	viper.SetDefault("addons.backup.plugins.azure", "velero/velero-plugin-for-microsoft-azure:v1.0.1")
+	viper.SetDefault("addons.prometheus-adapter.chart", "stable/prometheus-adapter")
+	viper.SetDefault("addons.prometheus-adapter.version", "v0.4.1")
+	viper.SetDefault("addons.vpa.chart", "fairwinds-stable/vpa")
+	viper.SetDefault("addons.vpa.version", "0.1.0")
+	viper.SetDefault("dns.zone", "")
+	viper.SetDefault("dns.awsRegion", "us-east-1")
+	viper.SetDefault("dns.azureResourceGroup", "")
+	viper.SetDefault("certificates.acmeServer", "https://acme-v02.api.letsencrypt.org/directory")
+	viper.SetDefault("certificates.acmeEmail", "")
+	viper.SetDefault("certificates.expiryWarning", "336h")
+	viper.SetDefault("certificates.checkInterval", "1h")
+	viper.SetDefault("alerting.smtp.smarthost", "")
+	viper.SetDefault("alerting.smtp.from", "")
+	viper.SetDefault("alerting.smtp.username", "")
+	viper.SetDefault("alerting.smtp.password", "")
+	viper.SetDefault("progress.pollInterval", "5s")
+	viper.SetDefault("progress.heartbeatInterval", "30s")
+	viper.SetDefault("exec.dialTimeout", "30s")
+	viper.SetDefault("exec.recordStdin", true)
+	viper.SetDefault("exec.stdinLimit", 65536)
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.maxAttempts", 8)
+	viper.SetDefault("webhooks.backoff", "30s")
+	viper.SetDefault("webhooks.maxBackoff", "1h")
+	viper.SetDefault("webhooks.retryInterval", "30s")
+	viper.SetDefault("webhooks.deliveryRetention", "720h")
+	viper.SetDefault("canary.analysisInterval", "1m")
+	viper.SetDefault("canary.prometheusNamespace", "default")
+	viper.SetDefault("canary.prometheusService", "monitoring-prometheus-server")
+	viper.SetDefault("canary.prometheusPort", "80")
+	viper.SetDefault("gitops.syncInterval", "3m")
+	viper.SetDefault("namespaces.teamGroupPrefix", "pipeline:")
+	viper.SetDefault("spot.awsTerminationHandlerImage", "amazon/aws-node-termination-handler:v1.3.1")
+	viper.SetDefault("spot.gkeTerminationHandlerImage", "banzaicloud/gke-preemptible-handler:0.1.0")
+	viper.SetDefault("kubeconfig.defaultTTL", "1h")
+	viper.SetDefault("kubeconfig.maxTTL", "24h")
+	viper.SetDefault("network.reachableNetworks", []string{})
+	viper.SetDefault("network.pipelineCIDRs", []string{})
+	viper.SetDefault("pricing.cacheTTL", "24h")
+	viper.SetDefault("pricing.eksControlPlane", 0.10)
+	viper.SetDefault("pricing.gkeControlPlane", 0.10)
+	viper.SetDefault("chargeback.enabled", true)
+	viper.SetDefault("chargeback.interval", "1h")
+	viper.SetDefault("utilization.enabled", true)
+	viper.SetDefault("utilization.interval", "15m")
+	viper.SetDefault("hibernation.enabled", true)
+	viper.SetDefault("scheduler.interval", "30s")
+	viper.SetDefault("etcdsnapshots.enabled", true)
+	viper.SetDefault("etcdsnapshots.image", "k8s.gcr.io/etcd-amd64:3.2.18")
+	viper.SetDefault("databases.checkInterval", "30s")
+	viper.SetDefault("databases.createTimeout", "1h")
+	viper.SetDefault("databases.storageGb", 20)
+	viper.SetDefault("databases.charts.postgresql", "stable/postgresql")
+	viper.SetDefault("databases.charts.mysql", "stable/mysql")
 	viper.SetDefault("cloud.gkeCredentialPath", "./conf/gke_credential.json")
 	viper.SetDefault("cloud.defaultProfileName", "default")
 	viper.SetDefault("cloud.configRetryCount", 30)
 	viper.SetDefault("cloud.configRetrySleep", 15)
+	viper.SetDefault("cloud.drainTimeout", "5m")
+	viper.SetDefault("cloud.deletionConfirmTimeout", "10m")
 	viper.SetDefault("logging.kubicornloglevel", "debug")
 	viper.SetDefault("statestore.path", "./statestore")
 	viper.SetDefault("pipeline.listenport", 9090)
 	viper.SetDefault("pipeline.uipath", "/account/repos")
+	viper.SetDefault("api.v1.sunset", "")
+	viper.SetDefault("pagination.defaultLimit", 100)
+	viper.SetDefault("pagination.maxLimit", 1000)
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("grpc.enabled", false)
+	viper.SetDefault("grpc.port", 9092)
+	viper.SetDefault("grpc.insecure", false)
+	viper.SetDefault("grpc.tls.certFile", "")
+	viper.SetDefault("grpc.tls.keyFile", "")
+	viper.SetDefault("health.timeout", "5s")
 	viper.SetDefault("database.dialect", "mysql")
 	viper.SetDefault("database.port", 3306)
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.user", "kellyslater")
 	viper.SetDefault("database.password", "pipemaster123!")
 	viper.SetDefault("database.dbname", "pipelinedb")
+	viper.SetDefault("database.vault.mountpath", "database")
+	viper.SetDefault("database.vault.connmaxlifetime", "1h")
+	viper.SetDefault("vault.namespace", "")
+	viper.SetDefault("vault.retry.maxretries", 3)
+	viper.SetDefault("vault.retry.initialbackoff", "100ms")
+	viper.SetDefault("vault.retry.maxbackoff", "2s")
+	viper.SetDefault("vault.breaker.threshold", 5)
+	viper.SetDefault("vault.breaker.timeout", "30s")
+	viper.SetDefault("secrets.store", "vault")
+	viper.SetDefault("secrets.vault.mountpath", "secret")
+	viper.SetDefault("secrets.maxversions", 10)
+	viper.SetDefault("secrets.encryption", "vault")
+	viper.SetDefault("secrets.transit.role", "pipeline")
+	viper.SetDefault("secrets.transit.mountpath", "transit")
+	viper.SetDefault("secrets.transit.key", "pipeline-secrets")
 
 	ReleaseName := os.Getenv("KUBERNETES_RELEASE_NAME")
 	if ReleaseName == "" {
@@ -69,8 +189,8 @@ func GetCORS() cors.Config {
 	viper.SetDefault("cors.AllowAllOrigins", true)
 	viper.SetDefault("cors.AllowOrigins", []string{"http://", "https://"})
 	viper.SetDefault("cors.AllowMethods", []string{"PUT", "DELETE", "GET", "POST", "OPTIONS"})
-	viper.SetDefault("cors.AllowHeaders", []string{"Origin", "Authorization", "Content-Type"})
-	viper.SetDefault("cors.ExposeHeaders", []string{"Content-Length"})
+	viper.SetDefault("cors.AllowHeaders", []string{"Origin", "Authorization", "Content-Type", "X-Impersonate-User", "Idempotency-Key"})
+	viper.SetDefault("cors.ExposeHeaders", []string{"Content-Length", "Idempotent-Replayed", "X-Next-Cursor", "Link"})
 	viper.SetDefault("cors.AllowCredentials", true)
 	viper.SetDefault("cors.MaxAge", 12)
 
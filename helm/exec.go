@@ -0,0 +1,293 @@
+package helm
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// Subresources of the pods proxied through WebSocket connections
+const (
+	PodExec        = "exec"
+	PodPortForward = "portforward"
+)
+
+// Channels of the exec streams of the Kubernetes WebSocket protocols
+const (
+	ExecStdin  = 0
+	ExecStdout = 1
+	ExecStderr = 2
+)
+
+// maxRecordedFrame is the size of the largest frame buffered by the stdin recorders
+const maxRecordedFrame = 1 << 20
+
+// websocketBase64Protocol is the Kubernetes WebSocket protocol sending the channels as base64 encoded text frames
+const websocketBase64Protocol = "base64.channel.k8s.io"
+
+// upgradeHeaders are the headers of the WebSocket upgrade request passed to the API server, the extensions
+// (eg.: compression) aren't negotiated so the audited frames can be read
+var upgradeHeaders = []string{"Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Protocol"}
+
+//PodConnection is an upgraded connection to a subresource of a pod through the API server of a cluster
+type PodConnection struct {
+	net.Conn
+	// Response is the 101 Switching Protocols response of the API server, its body isn't used
+	Response *http.Response
+	reader   *bufio.Reader
+}
+
+// Read reads the data buffered after the response first
+func (c *PodConnection) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// headerRecorder records the headers of a request instead of sending it
+type headerRecorder struct {
+	header http.Header
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.header = req.Header
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+//DialPod opens a WebSocket connection to the exec or portforward subresource of the pod with the query, the
+//WebSocket headers of the client's upgrade request are passed to the API server. The API server answering
+//without switching protocols is an error.
+func DialPod(kubeConfig *[]byte, namespace, pod, subresource string, query url.Values, header http.Header, timeout time.Duration) (*PodConnection, error) {
+	config, err := GetK8sClientConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	host, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid API server address")
+	}
+	target := *host
+	target.Path = path.Join("/", host.Path, "api/v1/namespaces", namespace, "pods", pod, subresource)
+	target.RawQuery = query.Encode()
+
+	// the credentials of the kubeconfig (tokens, basic auth, auth providers) are added by the client-go wrappers
+	recorder := &headerRecorder{}
+	wrapped, err := rest.HTTPWrappersForConfig(config, recorder)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := wrapped.RoundTrip(request); err != nil {
+		return nil, err
+	}
+	request.Header = recorder.header
+	for _, name := range upgradeHeaders {
+		if values, ok := header[name]; ok {
+			request.Header[name] = values
+		}
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+
+	conn, err := dialAPIServer(config, &target, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "sending upgrade request failed")
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "reading upgrade response failed")
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		conn.Close()
+		return nil, &UpgradeError{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	conn.SetDeadline(time.Time{})
+	return &PodConnection{Conn: conn, Response: response, reader: reader}, nil
+}
+
+// dialAPIServer connects to the API server with the TLS config of the kubeconfig
+func dialAPIServer(config *rest.Config, target *url.URL, timeout time.Duration) (net.Conn, error) {
+	address := target.Host
+	if target.Port() == "" {
+		if target.Scheme == "http" {
+			address = net.JoinHostPort(target.Hostname(), "80")
+		} else {
+			address = net.JoinHostPort(target.Hostname(), "443")
+		}
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if target.Scheme == "http" {
+		return dialer.Dial("tcp", address)
+	}
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" && !tlsConfig.InsecureSkipVerify {
+		tlsConfig.ServerName = target.Hostname()
+	}
+	return tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+}
+
+//UpgradeError is the response of the API server refusing the upgrade of the connection
+type UpgradeError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements error
+func (e *UpgradeError) Error() string {
+	return fmt.Sprintf("the API server responded %d: %s", e.StatusCode, e.Message)
+}
+
+//StdinRecorder reads the WebSocket frames sent by the client of an exec session and records the data of the
+//stdin channel, up to the limit; the frames are parsed as they are written so it can observe a stream
+type StdinRecorder struct {
+	protocol  string
+	limit     int
+	buffer    []byte
+	channel   int
+	stdin     []byte
+	Truncated bool
+	// stopped is set after the limit or a frame too large to buffer, nothing is recorded afterwards
+	stopped bool
+}
+
+//NewStdinRecorder returns a recorder of the frames of the negotiated WebSocket protocol
+func NewStdinRecorder(protocol string, limit int) *StdinRecorder {
+	return &StdinRecorder{protocol: protocol, limit: limit, channel: -1}
+}
+
+//Stdin returns the recorded input
+func (r *StdinRecorder) Stdin() string {
+	return string(r.stdin)
+}
+
+// Write implements io.Writer, it never fails so it doesn't break the session
+func (r *StdinRecorder) Write(p []byte) (int, error) {
+	if r.stopped {
+		return len(p), nil
+	}
+	r.buffer = append(r.buffer, p...)
+	for !r.stopped {
+		opcode, payload, size, ok := r.nextFrame()
+		if !ok {
+			break
+		}
+		r.buffer = r.buffer[size:]
+		r.record(opcode, payload)
+	}
+	if len(r.buffer) > maxRecordedFrame {
+		r.stopped, r.Truncated = true, true
+	}
+	if r.stopped {
+		r.buffer = nil
+	}
+	return len(p), nil
+}
+
+// nextFrame unmasks the first complete frame of the buffer and returns its size
+func (r *StdinRecorder) nextFrame() (opcode byte, payload []byte, size int, ok bool) {
+	if len(r.buffer) < 2 {
+		return 0, nil, 0, false
+	}
+	opcode = r.buffer[0] & 0x0f
+	masked := r.buffer[1]&0x80 != 0
+	length := uint64(r.buffer[1] & 0x7f)
+	header := 2
+	switch length {
+	case 126:
+		if len(r.buffer) < 4 {
+			return 0, nil, 0, false
+		}
+		length = uint64(binary.BigEndian.Uint16(r.buffer[2:4]))
+		header = 4
+	case 127:
+		if len(r.buffer) < 10 {
+			return 0, nil, 0, false
+		}
+		length = binary.BigEndian.Uint64(r.buffer[2:10])
+		header = 10
+	}
+	var key []byte
+	if masked {
+		if len(r.buffer) < header+4 {
+			return 0, nil, 0, false
+		}
+		key = r.buffer[header : header+4]
+		header += 4
+	}
+	if length > uint64(len(r.buffer)-header) {
+		return 0, nil, 0, false
+	}
+	size = header + int(length)
+	payload = make([]byte, length)
+	copy(payload, r.buffer[header:size])
+	for i := range key {
+		for j := i; j < len(payload); j += 4 {
+			payload[j] ^= key[i]
+		}
+	}
+	return opcode, payload, size, true
+}
+
+// record appends the stdin data of a data frame, the continuation frames belong to the channel of the message
+func (r *StdinRecorder) record(opcode byte, payload []byte) {
+	switch opcode {
+	case 0x1, 0x2:
+		if len(payload) == 0 {
+			r.channel = -1
+			return
+		}
+		r.channel = int(payload[0])
+		if r.protocol == websocketBase64Protocol {
+			r.channel -= '0'
+		}
+		payload = payload[1:]
+	case 0x0:
+	default:
+		return
+	}
+	if r.channel != ExecStdin || len(payload) == 0 {
+		return
+	}
+	if r.protocol == websocketBase64Protocol {
+		decoded, err := base64.StdEncoding.DecodeString(string(payload))
+		if err != nil {
+			return
+		}
+		payload = decoded
+	}
+	if len(r.stdin)+len(payload) > r.limit {
+		payload = payload[:r.limit-len(r.stdin)]
+		r.stopped, r.Truncated = true, true
+	}
+	r.stdin = append(r.stdin, payload...)
+}
@@ -0,0 +1,84 @@
+package helm_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/helm"
+)
+
+// clientFrame returns a masked WebSocket frame of the client
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	key := []byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{first}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	frame = append(frame, key...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	return frame
+}
+
+func TestStdinRecorder(t *testing.T) {
+
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = 'a'
+	}
+	stream := []byte{}
+	stream = append(stream, clientFrame(true, 0x2, []byte("\x00ls -la\n"))...)
+	stream = append(stream, clientFrame(true, 0x2, []byte("\x04{\"Width\":80,\"Height\":24}"))...)
+	stream = append(stream, clientFrame(false, 0x2, []byte("\x00exi"))...)
+	stream = append(stream, clientFrame(true, 0x0, []byte("t\n"))...)
+	stream = append(stream, clientFrame(true, 0x9, []byte("ping"))...)
+	stream = append(stream, clientFrame(true, 0x2, append([]byte{0}, long...))...)
+
+	cases := []struct {
+		name      string
+		limit     int
+		chunk     int
+		expected  string
+		truncated bool
+	}{
+		{name: "whole stream", limit: 1024, chunk: len(stream), expected: "ls -la\nexit\n" + string(long)},
+		{name: "byte by byte", limit: 1024, chunk: 1, expected: "ls -la\nexit\n" + string(long)},
+		{name: "limit", limit: 10, chunk: 7, expected: "ls -la\nexi", truncated: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := helm.NewStdinRecorder("v4.channel.k8s.io", tc.limit)
+			for i := 0; i < len(stream); i += tc.chunk {
+				end := i + tc.chunk
+				if end > len(stream) {
+					end = len(stream)
+				}
+				if n, err := recorder.Write(stream[i:end]); err != nil || n != end-i {
+					t.Fatalf("Error during recording: %v", err)
+				}
+			}
+			if recorder.Stdin() != tc.expected {
+				t.Errorf("Expected %q, got: %q", tc.expected, recorder.Stdin())
+			}
+			if recorder.Truncated != tc.truncated {
+				t.Errorf("Expected truncated %t, got: %t", tc.truncated, recorder.Truncated)
+			}
+		})
+	}
+
+	recorder := helm.NewStdinRecorder("base64.channel.k8s.io", 1024)
+	recorder.Write(clientFrame(true, 0x1, []byte("0"+base64.StdEncoding.EncodeToString([]byte("whoami\n")))))
+	recorder.Write(clientFrame(true, 0x1, []byte("1"+base64.StdEncoding.EncodeToString([]byte("output")))))
+	if recorder.Stdin() != "whoami\n" {
+		t.Errorf("Expected %q, got: %q", "whoami\n", recorder.Stdin())
+	}
+}
@@ -22,6 +22,9 @@ import (
 var logger *logrus.Logger
 var log *logrus.Entry
 
+// DefaultNamespace is the namespace of the deployments
+const DefaultNamespace = "default"
+
 // Simple init for logging
 func init() {
 	logger = config.Logger()
@@ -79,7 +82,12 @@ func ListDeployments(filter *string, kubeConfig *[]byte) (*rls.ListReleasesRespo
 }
 
 //UpgradeDeployment upgrades a Helm deployment
-func UpgradeDeployment(deploymentName, chartName string, values map[string]interface{}, kubeConfig *[]byte) (string, error) {
+func UpgradeDeployment(deploymentName, chartName string, values map[string]interface{}, kubeConfig *[]byte) (*rls.UpdateReleaseResponse, error) {
+	return upgradeChart(deploymentName, chartName, values, kubeConfig, false)
+}
+
+// upgradeChart upgrades the release with the chart, Tiller only renders the release in a dry run
+func upgradeChart(deploymentName, chartName string, values map[string]interface{}, kubeConfig *[]byte, dryRun bool) (*rls.UpdateReleaseResponse, error) {
 	//Base maps for values
 	base := map[string]interface{}{}
 	//this is only to parse x=y format
@@ -89,32 +97,35 @@ func UpgradeDeployment(deploymentName, chartName string, values map[string]inter
 	base = mergeValues(base, values)
 	updateValues, err := yaml.Marshal(base)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	//Map chartName as
 
 	chartRequested, err := chartutil.Load(chartName)
 	if err != nil {
-		return "", fmt.Errorf("Error loading chart: %v", err)
+		return nil, fmt.Errorf("Error loading chart: %v", err)
 	}
 	if req, err := chartutil.LoadRequirements(chartRequested); err == nil {
 		if err := checkDependencies(chartRequested, req); err != nil {
-			return "", err
+			return nil, err
 		}
 	} else if err != chartutil.ErrRequirementsNotFound {
-		return "", fmt.Errorf("cannot load requirements: %v", err)
+		return nil, fmt.Errorf("cannot load requirements: %v", err)
+	}
+	if err := validateChartValues(chartRequested, updateValues); err != nil {
+		return nil, err
 	}
 	//Get cluster based or inCluster kubeconfig
 	hClient, err := GetHelmClient(kubeConfig)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	upgradeRes, err := hClient.UpdateReleaseFromChart(
 		deploymentName,
 		chartRequested,
 		helm.UpdateValueOverrides(updateValues),
-		helm.UpgradeDryRun(false),
+		helm.UpgradeDryRun(dryRun),
 		//helm.UpgradeRecreate(u.recreate),
 		//helm.UpgradeForce(u.force),
 		//helm.UpgradeDisableHooks(u.disableHooks),
@@ -124,17 +135,65 @@ func UpgradeDeployment(deploymentName, chartName string, values map[string]inter
 		//helm.UpgradeWait(u.wait)
 	)
 	if err != nil {
-		return "", fmt.Errorf("upgrade failed: %v", err)
+		return nil, fmt.Errorf("upgrade failed: %v", err)
+	}
+	return upgradeRes, nil
+}
+
+//UpgradeDeploymentFromRepo upgrades a Helm deployment with the chart downloaded from the repositories of the cluster
+func UpgradeDeploymentFromRepo(deploymentName, chartName string, values map[string]interface{}, kubeConfig *[]byte, path string) (*rls.UpdateReleaseResponse, error) {
+	return UpgradeDeploymentVersion(deploymentName, chartName, "", values, kubeConfig, path)
+}
+
+//UpgradeDeploymentVersion upgrades a Helm deployment with the version of the chart of the repositories of the cluster,
+//the latest version if it's empty
+func UpgradeDeploymentVersion(deploymentName, chartName, version string, values map[string]interface{}, kubeConfig *[]byte, path string) (*rls.UpdateReleaseResponse, error) {
+	downloadedChartPath, err := downloadChartFromRepo(chartName, version, generateHelmRepoPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return UpgradeDeployment(deploymentName, downloadedChartPath, values, kubeConfig)
+}
+
+//RenderUpgrade returns the manifest of the upgrade of a Helm deployment with the chart of the repositories
+//of the cluster without upgrading it
+func RenderUpgrade(deploymentName, chartName string, values map[string]interface{}, kubeConfig *[]byte, path string) (string, error) {
+	downloadedChartPath, err := downloadChartFromRepo(chartName, "", generateHelmRepoPath(path))
+	if err != nil {
+		return "", err
+	}
+	upgradeRes, err := upgradeChart(deploymentName, downloadedChartPath, values, kubeConfig, true)
+	if err != nil {
+		return "", err
 	}
-	return upgradeRes.Release.Name, nil
+	return upgradeRes.Release.Manifest, nil
 }
 
 //CreateDeployment creates a Helm deployment
 func CreateDeployment(chartName string, releaseName string, valueOverrides []byte, kubeConfig *[]byte, path string) (*rls.InstallReleaseResponse, error) {
+	return CreateDeploymentVersion(chartName, "", releaseName, valueOverrides, kubeConfig, path)
+}
+
+//CreateDeploymentVersion creates a Helm deployment of the version of the chart, the latest version if it's empty
+func CreateDeploymentVersion(chartName, version, releaseName string, valueOverrides []byte, kubeConfig *[]byte, path string) (*rls.InstallReleaseResponse, error) {
+	return installChart(chartName, version, releaseName, valueOverrides, kubeConfig, path, false)
+}
+
+//RenderDeployment returns the manifest of a Helm deployment of the latest version of the chart without installing it
+func RenderDeployment(chartName, releaseName string, valueOverrides []byte, kubeConfig *[]byte, path string) (string, error) {
+	installRes, err := installChart(chartName, "", releaseName, valueOverrides, kubeConfig, path, true)
+	if err != nil {
+		return "", err
+	}
+	return installRes.Release.Manifest, nil
+}
+
+// installChart installs the version of the chart, Tiller only renders the release in a dry run
+func installChart(chartName, version, releaseName string, valueOverrides []byte, kubeConfig *[]byte, path string, dryRun bool) (*rls.InstallReleaseResponse, error) {
 	log := logger.WithFields(logrus.Fields{"tag": constants.TagCreateDeployment})
 
-	log.Infof("Deploying chart='%s', release name='%s'.", chartName, releaseName)
-	downloadedChartPath, err := downloadChartFromRepo(chartName, generateHelmRepoPath(path))
+	log.Infof("Deploying chart='%s', version='%s', release name='%s'.", chartName, version, releaseName)
+	downloadedChartPath, err := downloadChartFromRepo(chartName, version, generateHelmRepoPath(path))
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +210,10 @@ func CreateDeployment(chartName string, releaseName string, valueOverrides []byt
 	} else if err != chartutil.ErrRequirementsNotFound {
 		return nil, fmt.Errorf("cannot load requirements: %v", err)
 	}
-	var namespace = "default"
+	if err := validateChartValues(chartRequested, valueOverrides); err != nil {
+		return nil, err
+	}
+	var namespace = DefaultNamespace
 	if len(strings.TrimSpace(releaseName)) == 0 {
 		releaseName, _ = generateName("")
 	}
@@ -164,7 +226,7 @@ func CreateDeployment(chartName string, releaseName string, valueOverrides []byt
 		namespace,
 		helm.ValueOverrides(valueOverrides),
 		helm.ReleaseName(releaseName),
-		helm.InstallDryRun(false),
+		helm.InstallDryRun(dryRun),
 		helm.InstallReuseName(true),
 		helm.InstallDisableHooks(false),
 		helm.InstallTimeout(30),
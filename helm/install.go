@@ -177,9 +177,14 @@ func generateHelmRepoPath(path string) string {
 	return stateStorePath + path + helmPostFix
 }
 
-func downloadChartFromRepo(name, path string) (string, error) {
+func downloadChartFromRepo(name, version, path string) (string, error) {
 	log := logger.WithFields(logrus.Fields{"tag": "DownloadChartFromRepo"})
 	settings := createEnvSettings(path)
+	if isOCIReference(name) {
+		log.Infof("Pulling helm chart '%s' to '%s'", name, settings.Home.Archive())
+		filename, err := pullOCIChart(name, settings)
+		return filename, errors.Wrapf(err, "Failed to download %q", name)
+	}
 	dl := downloader.ChartDownloader{
 		HelmHome: settings.Home,
 		Getters:  getter.All(settings),
@@ -190,7 +195,7 @@ func downloadChartFromRepo(name, path string) (string, error) {
 	}
 
 	log.Infof("Downloading helm chart '%s' to '%s'", name, settings.Home.Archive())
-	filename, _, err := dl.DownloadTo(name, "", settings.Home.Archive())
+	filename, _, err := dl.DownloadTo(name, version, settings.Home.Archive())
 	if err == nil {
 		lname, err := filepath.Abs(filename)
 		if err != nil {
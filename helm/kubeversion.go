@@ -0,0 +1,118 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/downloader"
+	"k8s.io/helm/pkg/getter"
+	"k8s.io/helm/pkg/repo"
+)
+
+//KubeVersionConstraint is the kubeVersion constraint of the chart of a deployment
+type KubeVersionConstraint struct {
+	Release    string `json:"release"`
+	Chart      string `json:"chart"`
+	Constraint string `json:"constraint"`
+}
+
+//KubeVersionConstraints returns the kubeVersion constraints of the charts of the deployments, the charts are
+//looked up by their name and version in the repositories of the cluster. The chart metadata of the deployments
+//doesn't have the kubeVersion field, so it's read from the Chart.yaml of the chart archive.
+func KubeVersionConstraints(kubeConfig *[]byte, clusterName string) ([]KubeVersionConstraint, error) {
+	log := logger.WithFields(logrus.Fields{"tag": "KubeVersionConstraints"})
+	deployments, err := ListDeployments(nil, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	settings := createEnvSettings(generateHelmRepoPath(clusterName))
+	repoFile, err := repo.LoadRepositoriesFile(settings.Home.RepositoryFile())
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading the chart repositories")
+	}
+
+	constraints := []KubeVersionConstraint{}
+	for _, release := range deployments.GetReleases() {
+		metadata := release.GetChart().GetMetadata()
+		if metadata == nil {
+			continue
+		}
+		found := false
+		for _, entry := range repoFile.Repositories {
+			index, err := repo.LoadIndexFile(settings.Home.CacheIndex(entry.Name))
+			if err != nil || !index.Has(metadata.Name, metadata.Version) {
+				continue
+			}
+			found = true
+			dl := downloader.ChartDownloader{HelmHome: settings.Home, Getters: getter.All(settings)}
+			os.MkdirAll(settings.Home.Archive(), 0744)
+			filename, _, err := dl.DownloadTo(entry.Name+"/"+metadata.Name, metadata.Version, settings.Home.Archive())
+			if err != nil {
+				return nil, errors.Wrapf(err, "error downloading the chart of deployment %s", release.Name)
+			}
+			constraint, err := chartKubeVersion(filename)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading the chart of deployment %s", release.Name)
+			}
+			if constraint != "" {
+				constraints = append(constraints, KubeVersionConstraint{
+					Release:    release.Name,
+					Chart:      metadata.Name + "-" + metadata.Version,
+					Constraint: constraint,
+				})
+			}
+			break
+		}
+		if !found {
+			log.Warnf("Chart %s-%s of deployment %s isn't in the repositories", metadata.Name, metadata.Version, release.Name)
+		}
+	}
+	return constraints, nil
+}
+
+// chartKubeVersion returns the kubeVersion of the Chart.yaml of the chart archive
+func chartKubeVersion(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	archive, err := gzip.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return "", errors.New("Chart.yaml not found")
+		}
+		if err != nil {
+			return "", err
+		}
+		// the chart files are in the directory of the chart
+		if path.Base(header.Name) != "Chart.yaml" || strings.Count(strings.Trim(header.Name, "/"), "/") != 1 {
+			continue
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		var chartFile struct {
+			KubeVersion string `json:"kubeVersion"`
+		}
+		if err := yaml.Unmarshal(data, &chartFile); err != nil {
+			return "", err
+		}
+		return chartFile.KubeVersion, nil
+	}
+}
@@ -0,0 +1,130 @@
+package helm
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Lines of the logs of the containers
+const (
+	DefaultLogLines = 100
+	MaxLogLines     = 1000
+)
+
+// releaseSelectors select the pods of a release by the labels of the charts
+var releaseSelectors = []string{"release", "app.kubernetes.io/instance"}
+
+//LogOptions selects the logs of the containers of a release: the last lines of the logs since the duration or
+//the time, of the container or of every container, of the previous instances of the containers if Previous is
+//set; the streamed logs of a pod are followed if Follow is set
+type LogOptions struct {
+	Container string
+	TailLines int64
+	Since     time.Duration
+	SinceTime *time.Time
+	Previous  bool
+	Follow    bool
+}
+
+//ContainerLogs are the lines of the logs of a container of a pod of a release
+type ContainerLogs struct {
+	Pod       string   `json:"pod"`
+	Container string   `json:"container"`
+	Lines     []string `json:"lines"`
+	Error     string   `json:"error,omitempty"`
+}
+
+//GetReleaseLogs returns the recent logs of the containers of the pods of the release
+func GetReleaseLogs(releaseName string, kubeConfig *[]byte, options LogOptions) ([]ContainerLogs, error) {
+	hClient, err := GetHelmClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hClient.ReleaseContent(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	client, err := GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	namespace := resp.Release.Namespace
+	pods := []v1.Pod{}
+	seen := map[string]bool{}
+	for _, label := range releaseSelectors {
+		list, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: label + "=" + releaseName})
+		if err != nil {
+			return nil, errors.Wrap(err, "error listing the pods of the release")
+		}
+		for _, pod := range list.Items {
+			if !seen[pod.Name] {
+				seen[pod.Name] = true
+				pods = append(pods, pod)
+			}
+		}
+	}
+
+	logs := []ContainerLogs{}
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			if options.Container != "" && container.Name != options.Container {
+				continue
+			}
+			containerLogs := ContainerLogs{Pod: pod.Name, Container: container.Name, Lines: []string{}}
+			raw, err := client.CoreV1().Pods(namespace).GetLogs(pod.Name, podLogOptions(container.Name, options)).Do().Raw()
+			if err != nil {
+				// the containers which haven't started have no logs
+				containerLogs.Error = err.Error()
+			}
+			scanner := bufio.NewScanner(bytes.NewReader(raw))
+			scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+			for err == nil && scanner.Scan() {
+				containerLogs.Lines = append(containerLogs.Lines, scanner.Text())
+			}
+			logs = append(logs, containerLogs)
+		}
+	}
+	return logs, nil
+}
+
+//StreamPodLogs opens the stream of the logs of a container of the pod, the container is optional in the pods
+//with one container; the stream is followed until it's closed if Follow is set
+func StreamPodLogs(namespace, pod string, kubeConfig *[]byte, options LogOptions) (io.ReadCloser, error) {
+	client, err := GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.CoreV1().Pods(namespace).Get(pod, metav1.GetOptions{}); err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Pods(namespace).GetLogs(pod, podLogOptions(options.Container, options)).Stream()
+}
+
+// podLogOptions returns the options of the logs of the container, the tail lines are limited
+func podLogOptions(container string, options LogOptions) *v1.PodLogOptions {
+	tailLines := options.TailLines
+	if tailLines <= 0 || tailLines > MaxLogLines {
+		tailLines = DefaultLogLines
+	}
+	podLogOptions := &v1.PodLogOptions{
+		Container:  container,
+		TailLines:  &tailLines,
+		Timestamps: true,
+		Previous:   options.Previous,
+		Follow:     options.Follow,
+	}
+	if options.SinceTime != nil {
+		sinceTime := metav1.NewTime(*options.SinceTime)
+		podLogOptions.SinceTime = &sinceTime
+	} else if options.Since > 0 {
+		seconds := int64(options.Since.Seconds())
+		podLogOptions.SinceSeconds = &seconds
+	}
+	return podLogOptions
+}
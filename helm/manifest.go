@@ -0,0 +1,557 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Actions of the applied manifests
+const (
+	ManifestCreated   = "created"
+	ManifestUpdated   = "updated"
+	ManifestUnchanged = "unchanged"
+	ManifestPruned    = "pruned"
+)
+
+// applyPatchType is the content type of the server-side apply patches
+const applyPatchType = types.PatchType("application/apply-patch+yaml")
+
+// DefaultFieldManager is the field manager of the resources applied by Pipeline
+const DefaultFieldManager = "pipeline"
+
+// pruneKinds are the kinds of the resources checked for pruning besides the kinds of the applied manifests
+var pruneKinds = []schema.GroupKind{
+	{Kind: "ConfigMap"},
+	{Kind: "Secret"},
+	{Kind: "Service"},
+	{Kind: "ServiceAccount"},
+	{Kind: "PersistentVolumeClaim"},
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+	{Group: "extensions", Kind: "Ingress"},
+}
+
+// documentSeparator splits the YAML documents of a manifest file
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+//ManifestResult describes an applied Kubernetes resource
+type ManifestResult struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	// Managers are the field managers of the resource after a server-side apply
+	Managers []string `json:"managers,omitempty"`
+}
+
+//ApplyOptions are the options of a server-side apply: the changed fields are owned by the field manager, the
+//conflicting fields of other managers are taken over if Force is set. Nothing is persisted in a dry run.
+//The resources matching the prune selector which are not in the manifests are deleted if it's not empty.
+type ApplyOptions struct {
+	FieldManager  string
+	Force         bool
+	DryRun        bool
+	PruneSelector string
+}
+
+//ParseManifests parses the resources of the YAML or JSON documents, the items of the lists are returned one by one
+func ParseManifests(data []byte) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	for i, document := range documentSeparator.Split(string(data), -1) {
+		if len(bytes.TrimSpace([]byte(document))) == 0 {
+			continue
+		}
+		raw, err := yaml.YAMLToJSON([]byte(document))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid document %d", i+1)
+		}
+		if string(raw) == "null" {
+			continue
+		}
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(raw); err != nil {
+			return nil, errors.Wrapf(err, "invalid document %d", i+1)
+		}
+		items := []*unstructured.Unstructured{object}
+		if object.IsList() {
+			items = nil
+			err := object.EachListItem(func(item runtime.Object) error {
+				items = append(items, item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid document %d", i+1)
+			}
+		}
+		for _, item := range items {
+			if item.GetKind() == "" || item.GetName() == "" {
+				return nil, fmt.Errorf("a resource of document %d has no kind or name", i+1)
+			}
+		}
+		objects = append(objects, items...)
+	}
+	return objects, nil
+}
+
+// manifestClient maps the kinds of the resources to the dynamic clients of the cluster
+type manifestClient struct {
+	mapper     meta.RESTMapper
+	pool       dynamic.ClientPool
+	restClient rest.Interface
+}
+
+func newManifestClient(kubeConfig *[]byte) (*manifestClient, error) {
+	config, err := GetK8sClientConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newManifestClientForConfig(config)
+}
+
+func newManifestClientForConfig(config *rest.Config) (*manifestClient, error) {
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := discovery.GetAPIGroupResources(client.Discovery())
+	if err != nil {
+		return nil, errors.Wrap(err, "error discovering the resources of the cluster")
+	}
+	return &manifestClient{
+		mapper:     discovery.NewRESTMapper(resources, meta.InterfacesForUnstructured),
+		pool:       dynamic.NewDynamicClientPool(config),
+		restClient: client.CoreV1().RESTClient(),
+	}, nil
+}
+
+// resource returns the client of the resource of the object, the namespace of the namespaced objects
+// defaults to the default namespace
+func (c *manifestClient) resource(object *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	mapping, err := c.mapping(object)
+	if err != nil {
+		return nil, err
+	}
+	return c.resourceClient(mapping, object.GetNamespace())
+}
+
+// mapping returns the REST mapping of the kind of the object, the namespace of the namespaced objects
+// defaults to the default namespace
+func (c *manifestClient) mapping(object *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if namespaced(mapping) && object.GetNamespace() == "" {
+		object.SetNamespace(DefaultNamespace)
+	}
+	return mapping, nil
+}
+
+// resourceClient returns the client of the resources of the mapping in the namespace
+func (c *manifestClient) resourceClient(mapping *meta.RESTMapping, namespace string) (dynamic.ResourceInterface, error) {
+	client, err := c.pool.ClientForGroupVersionKind(mapping.GroupVersionKind)
+	if err != nil {
+		return nil, err
+	}
+	if !namespaced(mapping) {
+		namespace = ""
+	}
+	resource := &metav1.APIResource{Name: mapping.Resource, Namespaced: namespaced(mapping), Kind: mapping.GroupVersionKind.Kind}
+	return client.Resource(resource, namespace), nil
+}
+
+func namespaced(mapping *meta.RESTMapping) bool {
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
+
+//ApplyManifests creates or updates the resources on the cluster, the labels are added to every resource
+//so they can be found later
+func ApplyManifests(kubeConfig *[]byte, objects []*unstructured.Unstructured, labels map[string]string) ([]ManifestResult, error) {
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	results := []ManifestResult{}
+	for _, object := range objects {
+		objectLabels := object.GetLabels()
+		if objectLabels == nil {
+			objectLabels = map[string]string{}
+		}
+		for key, value := range labels {
+			objectLabels[key] = value
+		}
+		object.SetLabels(objectLabels)
+
+		resource, err := client.resource(object)
+		if err != nil {
+			return results, errors.Wrapf(err, "error applying %s %s", object.GetKind(), object.GetName())
+		}
+		result := ManifestResult{Kind: object.GetKind(), Namespace: object.GetNamespace(), Name: object.GetName()}
+		live, err := resource.Get(object.GetName(), metav1.GetOptions{})
+		switch {
+		case k8sErrors.IsNotFound(err):
+			_, err = resource.Create(object)
+			result.Action = ManifestCreated
+		case err != nil:
+		case matchesLive(object.Object, live.Object):
+			result.Action = ManifestUnchanged
+		default:
+			object.SetResourceVersion(live.GetResourceVersion())
+			_, err = resource.Update(object)
+			result.Action = ManifestUpdated
+		}
+		if err != nil {
+			return results, errors.Wrapf(err, "error applying %s %s", object.GetKind(), object.GetName())
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+//GetManifest returns the live resource of the object, the error is a not found error if it doesn't exist
+func GetManifest(kubeConfig *[]byte, object *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := client.resource(object)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Get(object.GetName(), metav1.GetOptions{})
+}
+
+//ListManifests returns the live resources of the kind of the object in its namespace, the labels select them
+//if they aren't empty
+func ListManifests(kubeConfig *[]byte, object *unstructured.Unstructured, labels map[string]string) ([]unstructured.Unstructured, error) {
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := client.resource(object)
+	if err != nil {
+		return nil, err
+	}
+	selector := []string{}
+	for key, value := range labels {
+		selector = append(selector, key+"="+value)
+	}
+	sort.Strings(selector)
+	list, err := resource.List(metav1.ListOptions{LabelSelector: strings.Join(selector, ",")})
+	if err != nil {
+		return nil, err
+	}
+	items, ok := list.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected list of %s: %T", object.GetKind(), list)
+	}
+	return items.Items, nil
+}
+
+//DeleteManifests deletes the resources from the cluster, the missing resources are skipped
+func DeleteManifests(kubeConfig *[]byte, objects []*unstructured.Unstructured) error {
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		resource, err := client.resource(object)
+		if err != nil {
+			return errors.Wrapf(err, "error deleting %s %s", object.GetKind(), object.GetName())
+		}
+		if err := resource.Delete(object.GetName(), &metav1.DeleteOptions{}); err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting %s %s", object.GetKind(), object.GetName())
+		}
+	}
+	return nil
+}
+
+//ManifestDrift returns the resources of the manifests missing from the cluster or changed on the cluster
+//(kind/namespace/name)
+func ManifestDrift(kubeConfig *[]byte, objects []*unstructured.Unstructured) ([]string, error) {
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	drift := []string{}
+	for _, object := range objects {
+		resource, err := client.resource(object)
+		if err != nil {
+			return nil, err
+		}
+		id := fmt.Sprintf("%s/%s/%s", object.GetKind(), object.GetNamespace(), object.GetName())
+		live, err := resource.Get(object.GetName(), metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			drift = append(drift, id+" is missing")
+		} else if err != nil {
+			return nil, err
+		} else if !matchesLive(object.Object, live.Object) {
+			drift = append(drift, id+" is changed")
+		}
+	}
+	return drift, nil
+}
+
+// matchesLive checks that the live resource has the fields of the desired resource, the fields set by the
+// cluster (status, the metadata other than the labels and the annotations, defaults) are ignored
+func matchesLive(desired, live map[string]interface{}) bool {
+	for key, value := range desired {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			metadata, _ := value.(map[string]interface{})
+			liveMetadata, _ := live["metadata"].(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				if metadata[field] != nil && !containsValue(metadata[field], liveMetadata[field]) {
+					return false
+				}
+			}
+			continue
+		}
+		if !containsValue(value, live[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue checks that the live value contains the desired value: the fields of the objects and the
+// items of the lists are compared recursively
+func containsValue(desired, live interface{}) bool {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range desired {
+			if !containsValue(value, liveMap[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok || len(liveList) != len(desired) {
+			return false
+		}
+		for i := range desired {
+			if !containsValue(desired[i], liveList[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := number(desired); ok {
+		y, ok := number(live)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(desired, live)
+}
+
+//ServerSideApply applies the resources on the cluster with server-side apply, then prunes the resources matching
+//the prune selector of the options which are missing from the manifests
+func ServerSideApply(kubeConfig *[]byte, objects []*unstructured.Unstructured, options ApplyOptions) ([]ManifestResult, error) {
+	if options.FieldManager == "" {
+		options.FieldManager = DefaultFieldManager
+	}
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	results := []ManifestResult{}
+	applied := map[string]bool{}
+	pruned := map[schema.GroupKind]bool{}
+	for _, kind := range pruneKinds {
+		pruned[kind] = true
+	}
+	namespaces := map[string]bool{}
+	for _, object := range objects {
+		result, err := client.apply(object, options)
+		if err != nil {
+			return results, errors.Wrapf(err, "error applying %s %s", object.GetKind(), object.GetName())
+		}
+		results = append(results, *result)
+		gvk := object.GroupVersionKind()
+		applied[manifestID(gvk.GroupKind(), object.GetNamespace(), object.GetName())] = true
+		pruned[gvk.GroupKind()] = true
+		if object.GetNamespace() != "" {
+			namespaces[object.GetNamespace()] = true
+		}
+	}
+	if options.PruneSelector == "" {
+		return results, nil
+	}
+	if len(namespaces) == 0 {
+		namespaces[DefaultNamespace] = true
+	}
+	for kind := range pruned {
+		mapping, err := client.mapper.RESTMapping(kind)
+		if err != nil {
+			// the kind isn't served by the cluster
+			continue
+		}
+		listNamespaces := namespaces
+		if !namespaced(mapping) {
+			listNamespaces = map[string]bool{"": true}
+		}
+		for namespace := range listNamespaces {
+			prunedResults, err := client.prune(mapping, namespace, applied, options)
+			results = append(results, prunedResults...)
+			if err != nil {
+				return results, errors.Wrapf(err, "error pruning %s resources", kind.Kind)
+			}
+		}
+	}
+	return results, nil
+}
+
+// apply sends the object as a server-side apply patch, the action is found out from the resource versions
+func (c *manifestClient) apply(object *unstructured.Unstructured, options ApplyOptions) (*ManifestResult, error) {
+	mapping, err := c.mapping(object)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := c.resourceClient(mapping, object.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	result := &ManifestResult{Kind: object.GetKind(), Namespace: object.GetNamespace(), Name: object.GetName()}
+	if !namespaced(mapping) {
+		result.Namespace = ""
+	}
+	resourceVersion := ""
+	live, err := resource.Get(object.GetName(), metav1.GetOptions{})
+	if err == nil {
+		resourceVersion = live.GetResourceVersion()
+	} else if !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	data, err := object.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	request := c.restClient.Patch(applyPatchType).
+		AbsPath(resourcePath(mapping, result.Namespace, object.GetName())).
+		Param("fieldManager", options.FieldManager).
+		Body(data)
+	if options.Force {
+		request = request.Param("force", "true")
+	}
+	if options.DryRun {
+		request = request.Param("dryRun", "All")
+	}
+	raw, err := request.Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	appliedObject := &unstructured.Unstructured{}
+	if err := appliedObject.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	switch {
+	case resourceVersion == "":
+		result.Action = ManifestCreated
+	case resourceVersion == appliedObject.GetResourceVersion():
+		result.Action = ManifestUnchanged
+	default:
+		result.Action = ManifestUpdated
+	}
+	result.Managers = fieldManagers(appliedObject)
+	return result, nil
+}
+
+// prune deletes the resources of the mapping in the namespace matching the prune selector which weren't applied
+func (c *manifestClient) prune(mapping *meta.RESTMapping, namespace string, applied map[string]bool, options ApplyOptions) ([]ManifestResult, error) {
+	resource, err := c.resourceClient(mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+	list, err := resource.List(metav1.ListOptions{LabelSelector: options.PruneSelector})
+	if err != nil {
+		return nil, err
+	}
+	items, ok := list.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected list type %T", list)
+	}
+	results := []ManifestResult{}
+	propagation := metav1.DeletePropagationBackground
+	for _, item := range items.Items {
+		if applied[manifestID(mapping.GroupVersionKind.GroupKind(), item.GetNamespace(), item.GetName())] {
+			continue
+		}
+		if !options.DryRun {
+			err := resource.Delete(item.GetName(), &metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !k8sErrors.IsNotFound(err) {
+				return results, err
+			}
+		}
+		results = append(results, ManifestResult{
+			Kind:      mapping.GroupVersionKind.Kind,
+			Namespace: item.GetNamespace(),
+			Name:      item.GetName(),
+			Action:    ManifestPruned,
+		})
+	}
+	return results, nil
+}
+
+// resourcePath returns the API path of the resource of the mapping
+func resourcePath(mapping *meta.RESTMapping, namespace, name string) string {
+	gv := mapping.GroupVersionKind.GroupVersion()
+	path := "/apis/" + gv.String()
+	if gv.Group == "" {
+		path = "/api/" + gv.Version
+	}
+	if namespaced(mapping) && namespace != "" {
+		path += "/namespaces/" + namespace
+	}
+	return path + "/" + mapping.Resource + "/" + name
+}
+
+// manifestID identifies a resource of the cluster by its kind, namespace and name
+func manifestID(kind schema.GroupKind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind.String(), namespace, name)
+}
+
+// fieldManagers returns the managers of the managed fields of the object
+func fieldManagers(object *unstructured.Unstructured) []string {
+	metadata, _ := object.Object["metadata"].(map[string]interface{})
+	managedFields, _ := metadata["managedFields"].([]interface{})
+	managers := []string{}
+	seen := map[string]bool{}
+	for _, field := range managedFields {
+		entry, _ := field.(map[string]interface{})
+		manager, _ := entry["manager"].(string)
+		if manager != "" && !seen[manager] {
+			seen[manager] = true
+			managers = append(managers, manager)
+		}
+	}
+	return managers
+}
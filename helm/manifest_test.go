@@ -0,0 +1,64 @@
+package helm_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/helm"
+)
+
+func TestParseManifests(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		manifests   string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name: "documents",
+			manifests: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+# empty document
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+			expected: []string{"ConfigMap/config", "Deployment/web"},
+		},
+		{
+			name:      "list",
+			manifests: `{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}}]}`,
+			expected:  []string{"Service/web"},
+		},
+		{name: "no name", manifests: "apiVersion: v1\nkind: ConfigMap\n", expectError: true},
+		{name: "invalid yaml", manifests: "kind: [ConfigMap", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects, err := helm.ParseManifests([]byte(tc.manifests))
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error during ParseManifests: %s", err.Error())
+			}
+			if len(objects) != len(tc.expected) {
+				t.Fatalf("Expected resources: %v, got: %d", tc.expected, len(objects))
+			}
+			for i, object := range objects {
+				if id := object.GetKind() + "/" + object.GetName(); id != tc.expected[i] {
+					t.Errorf("Expected resource: %s, got: %s", tc.expected[i], id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+)
+
+const (
+	ociScheme               = "oci://"
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	helmChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	// registryConfigFile is the file of the registry credentials in the Helm home of a cluster
+	registryConfigFile = "registry.json"
+	// gcpAccessTokenUser is the user name of the access tokens of the Google registries
+	gcpAccessTokenUser = "oauth2accesstoken"
+	gcpScope           = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// registryConfig is the registry credentials of a Helm home by registry host
+type registryConfig struct {
+	Auths map[string]registryAuth `json:"auths"`
+}
+
+// registryAuth is the basic auth of a registry, the token service of the registry exchanges it to a bearer token
+type registryAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// isOCIReference checks whether the chart is in an OCI registry (oci://registry/repository/chart:version)
+func isOCIReference(name string) bool {
+	return strings.HasPrefix(name, ociScheme)
+}
+
+// parseOCIReference splits the reference of a chart into the registry host, the repository and the version of the chart
+func parseOCIReference(ref string) (string, string, string, error) {
+	name := strings.TrimPrefix(ref, ociScheme)
+	i := strings.Index(name, "/")
+	j := strings.LastIndex(name, ":")
+	if i <= 0 || j <= i+1 || j == len(name)-1 {
+		return "", "", "", fmt.Errorf("invalid OCI chart reference %q, it must be like oci://registry/repository/chart:version", ref)
+	}
+	return name[:i], name[i+1 : j], name[j+1:], nil
+}
+
+// registryLogin returns the registry credentials of the OCI repository of an organization
+func registryLogin(repository model.HelmRepositoryModel) (registryAuth, error) {
+	if repository.SecretID == "" {
+		return registryAuth{}, nil
+	}
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(repository.OrganizationID), 10), repository.SecretID)
+	if err != nil {
+		return registryAuth{}, errors.Wrapf(err, "error getting the secret of repository %s", repository.Name)
+	}
+	host, _, _ := registryHost(repository.URL)
+	return secretRegistryAuth(item, host)
+}
+
+// secretRegistryAuth returns the credentials of the registry from the secret: the basic auth of a password secret,
+// an ECR authorization token, a Google access token or the service principal of an Azure secret
+func secretRegistryAuth(item *secret.SecretsItemResponse, host string) (registryAuth, error) {
+	switch item.SecretType {
+	case secret.Password:
+		return registryAuth{Username: item.Values["username"], Password: item.Values["password"]}, nil
+	case secret.Amazon:
+		return ecrLogin(host, item.Values)
+	case secret.Google:
+		data, err := json.Marshal(item.Values)
+		if err != nil {
+			return registryAuth{}, err
+		}
+		config, err := google.JWTConfigFromJSON(data, gcpScope)
+		if err != nil {
+			return registryAuth{}, err
+		}
+		token, err := config.TokenSource(context.Background()).Token()
+		if err != nil {
+			return registryAuth{}, err
+		}
+		return registryAuth{Username: gcpAccessTokenUser, Password: token.AccessToken}, nil
+	case secret.Azure:
+		return registryAuth{Username: item.Values["AZURE_CLIENT_ID"], Password: item.Values["AZURE_CLIENT_SECRET"]}, nil
+	}
+	return registryAuth{}, errors.Errorf("a %s can't log in to registry %s", item.SecretType, host)
+}
+
+// registryHost returns the host of the OCI repository URL (oci://registry/path)
+func registryHost(repositoryURL string) (string, string, error) {
+	u, err := url.Parse(repositoryURL)
+	if err != nil {
+		return "", "", err
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// ecrLogin exchanges the AWS credentials to the authorization token of the ECR registry
+// (<account>.dkr.ecr.<region>.amazonaws.com)
+func ecrLogin(host string, values map[string]string) (registryAuth, error) {
+	parts := strings.Split(host, ".")
+	if len(parts) < 6 || parts[1] != "dkr" || parts[2] != "ecr" {
+		return registryAuth{}, errors.Errorf("%s isn't an ECR registry", host)
+	}
+	region := parts[3]
+	body := []byte("{}")
+	req, err := http.NewRequest(http.MethodPost, "https://api.ecr."+region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return registryAuth{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken")
+	creds := credentials.NewStaticCredentials(values["AWS_ACCESS_KEY_ID"], values["AWS_SECRET_ACCESS_KEY"], "")
+	if _, err := v4.NewSigner(creds).Sign(req, bytes.NewReader(body), "ecr", region, time.Now()); err != nil {
+		return registryAuth{}, err
+	}
+	var response struct {
+		AuthorizationData []struct {
+			AuthorizationToken string `json:"authorizationToken"`
+		} `json:"authorizationData"`
+	}
+	resp, err := registryResponse(http.DefaultClient.Do(req))
+	if err != nil {
+		return registryAuth{}, errors.Wrap(err, "error getting the ECR authorization token")
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return registryAuth{}, err
+	}
+	if len(response.AuthorizationData) == 0 {
+		return registryAuth{}, errors.New("no ECR authorization token")
+	}
+	token, err := base64.StdEncoding.DecodeString(response.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return registryAuth{}, err
+	}
+	credential := strings.SplitN(string(token), ":", 2)
+	if len(credential) != 2 {
+		return registryAuth{}, errors.New("invalid ECR authorization token")
+	}
+	return registryAuth{Username: credential[0], Password: credential[1]}, nil
+}
+
+// checkRegistry logs in to the OCI repository to check its credentials
+func checkRegistry(repository model.HelmRepositoryModel) error {
+	auth, err := registryLogin(repository)
+	if err != nil {
+		return err
+	}
+	host, path, err := registryHost(repository.URL)
+	if err != nil {
+		return err
+	}
+	resp, err := registryGet(fmt.Sprintf("https://%s/v2/", host), "", path, auth)
+	if err != nil {
+		return errors.Errorf("%q is not a valid OCI registry or cannot be reached: %s", repository.URL, err.Error())
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// writeRegistryConfig saves the credentials of the registries into the Helm home
+func writeRegistryConfig(settings helm_env.EnvSettings, auths map[string]registryAuth) error {
+	data, err := json.Marshal(registryConfig{Auths: auths})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(settings.Home.String(), registryConfigFile), data, 0600)
+}
+
+// readRegistryAuth returns the credentials of the registry saved in the Helm home, they're empty if there are none
+func readRegistryAuth(settings helm_env.EnvSettings, host string) (registryAuth, error) {
+	data, err := ioutil.ReadFile(filepath.Join(settings.Home.String(), registryConfigFile))
+	if os.IsNotExist(err) {
+		return registryAuth{}, nil
+	} else if err != nil {
+		return registryAuth{}, err
+	}
+	var config registryConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return registryAuth{}, err
+	}
+	return config.Auths[host], nil
+}
+
+// pullOCIChart downloads the chart archive of the OCI reference into the archive directory of the Helm home
+// with the registry credentials of the home
+func pullOCIChart(ref string, settings helm_env.EnvSettings) (string, error) {
+	log := logger.WithFields(logrus.Fields{"tag": "PullOCIChart"})
+	host, repository, version, err := parseOCIReference(ref)
+	if err != nil {
+		return "", err
+	}
+	auth, err := readRegistryAuth(settings, host)
+	if err != nil {
+		return "", err
+	}
+	base := fmt.Sprintf("https://%s/v2/%s", host, repository)
+	resp, err := registryGet(base+"/manifests/"+version, ociManifestMediaType, repository, auth)
+	if err != nil {
+		return "", errors.Wrapf(err, "error getting the manifest of %s", ref)
+	}
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	digest := ""
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == helmChartLayerMediaType {
+			digest = layer.Digest
+		}
+	}
+	if digest == "" {
+		return "", errors.Errorf("%s isn't a Helm chart", ref)
+	}
+
+	resp, err = registryGet(base+"/blobs/"+digest, "", repository, auth)
+	if err != nil {
+		return "", errors.Wrapf(err, "error downloading %s", ref)
+	}
+	defer resp.Body.Close()
+	if err := os.MkdirAll(settings.Home.Archive(), 0755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(settings.Home.Archive(), fmt.Sprintf("%s-%s.tgz", filepath.Base(repository), version))
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return "", err
+	}
+	log.Debugf("Fetched helm chart '%s' to '%s'", ref, filename)
+	return filepath.Abs(filename)
+}
+
+// registryGet sends a GET request to the registry, the credentials are sent as basic auth or exchanged to
+// a pull token of the repository if the registry asks for them
+func registryGet(requestURL, accept, repository string, auth registryAuth) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err == nil && accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, err
+	}
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return registryResponse(resp, nil)
+	}
+	resp.Body.Close()
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		token, err := registryToken(challenge, repository, auth)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	return registryResponse(http.DefaultClient.Do(req))
+}
+
+// registryToken gets a pull token of the repository from the token service of the bearer challenge
+// (Bearer realm="...",service="...")
+func registryToken(challenge, repository string, auth registryAuth) (string, error) {
+	params := map[string]string{}
+	for _, param := range strings.Split(challenge[len("bearer "):], ",") {
+		if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", errors.Errorf("invalid registry auth challenge: %s", challenge)
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if repository != "" {
+		query.Set("scope", "repository:"+repository+":pull")
+	}
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if auth.Username != "" || auth.Password != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := registryResponse(http.DefaultClient.Do(req))
+	if err != nil {
+		return "", errors.Wrap(err, "error getting registry token")
+	}
+	defer resp.Body.Close()
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return token.Token, nil
+}
+
+// registryResponse turns the error status of the response into an error
+func registryResponse(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("%s responded %d: %s", resp.Request.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
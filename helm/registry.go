@@ -0,0 +1,129 @@
+package helm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+)
+
+// DockerHub is the registry of the images without a registry host
+const DockerHub = "docker.io"
+
+// dockerHubAliases are the hosts of Docker Hub, the credentials of docker.io are written for index.docker.io
+var dockerHubAliases = map[string]bool{DockerHub: true, "index.docker.io": true, "registry-1.docker.io": true}
+
+// podSpecPaths are the paths of the pod spec in the workload kinds, the path of a pod template otherwise
+var podSpecPaths = map[string][]string{
+	"Pod":     {"spec"},
+	"CronJob": {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// RegistryLogin returns the username and the password of the image registry on the host from the secret,
+// cloud secrets are exchanged to the tokens of the cloud registries
+func RegistryLogin(item *secret.SecretsItemResponse, host string) (string, string, error) {
+	auth, err := secretRegistryAuth(item, host)
+	return auth.Username, auth.Password, err
+}
+
+// CheckContainerRegistry logs in to the image registry on the host with the secret of the organization
+func CheckContainerRegistry(organizationID uint, secretID, host string) error {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(organizationID), 10), secretID)
+	if err != nil {
+		return err
+	}
+	username, password, err := RegistryLogin(item, host)
+	if err != nil {
+		return err
+	}
+	resp, err := registryGet(fmt.Sprintf("https://%s/v2/", RegistryAPIHost(host)), "", "", registryAuth{Username: username, Password: password})
+	if err != nil {
+		return errors.Errorf("%s is not a valid image registry or cannot be reached: %s", host, err.Error())
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// RegistryAPIHost returns the host of the registry API, Docker Hub serves it on registry-1.docker.io
+func RegistryAPIHost(host string) string {
+	if dockerHubAliases[host] {
+		return "registry-1.docker.io"
+	}
+	return host
+}
+
+// NormalizeRegistryHost returns the host of a registry without scheme and path, the hosts of Docker Hub
+// are docker.io
+func NormalizeRegistryHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.ToLower(strings.SplitN(host, "/", 2)[0])
+	if dockerHubAliases[host] {
+		return DockerHub
+	}
+	return host
+}
+
+// ImageRegistry returns the registry host of an image reference, the first component of the name is a host if
+// it has a dot or a port or it's localhost, images are on Docker Hub otherwise
+func ImageRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return NormalizeRegistryHost(parts[0])
+	}
+	return DockerHub
+}
+
+// DockerConfigJSON returns the .dockerconfigjson of an image pull secret of the registry
+func DockerConfigJSON(host, username, password string) ([]byte, error) {
+	if dockerHubAliases[host] {
+		host = "https://index.docker.io/v1/"
+	}
+	type dockerAuth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	return json.Marshal(map[string]map[string]dockerAuth{
+		"auths": {host: {
+			Username: username,
+			Password: password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+		}},
+	})
+}
+
+// ManifestImages returns the images of the containers and the init containers of the workloads of a manifest
+func ManifestImages(manifest string) ([]string, error) {
+	objects, err := ParseManifests([]byte(manifest))
+	if err != nil {
+		return nil, err
+	}
+	images := map[string]bool{}
+	for _, object := range objects {
+		path, ok := podSpecPaths[object.GetKind()]
+		if !ok {
+			path = []string{"spec", "template", "spec"}
+		}
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, _ := nestedField(object.Object, append(path, field)...).([]interface{})
+			for _, container := range containers {
+				if container, ok := container.(map[string]interface{}); ok {
+					if image, ok := container["image"].(string); ok && image != "" {
+						images[image] = true
+					}
+				}
+			}
+		}
+	}
+	result := make([]string, 0, len(images))
+	for image := range images {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+	return result, nil
+}
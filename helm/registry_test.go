@@ -0,0 +1,73 @@
+package helm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/helm"
+)
+
+func TestImageRegistry(t *testing.T) {
+
+	cases := map[string]string{
+		"nginx":                       helm.DockerHub,
+		"banzaicloud/pipeline:0.3":    helm.DockerHub,
+		"index.docker.io/library/web": helm.DockerHub,
+		"gcr.io/acme/web@sha256:abc":  "gcr.io",
+		"localhost:5000/web":          "localhost:5000",
+		"localhost/web":               "localhost",
+	}
+	for image, expected := range cases {
+		if registry := helm.ImageRegistry(image); registry != expected {
+			t.Errorf("Expected %s, got: %s", expected, registry)
+		}
+	}
+}
+
+func TestManifestImages(t *testing.T) {
+
+	manifest := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: acme/web:1.0
+      containers:
+      - name: web
+        image: acme/web:1.0
+      - name: proxy
+        image: envoyproxy/envoy:v1.8.0
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: acme/cleanup:1.0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	images, err := helm.ManifestImages(manifest)
+	if err != nil {
+		t.Fatalf("Error during parsing manifest: %s", err.Error())
+	}
+	expected := []string{"acme/cleanup:1.0", "acme/web:1.0", "envoyproxy/envoy:v1.8.0"}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("Expected %v, got: %v", expected, images)
+	}
+}
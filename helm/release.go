@@ -0,0 +1,121 @@
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/helm/pkg/helm"
+	"k8s.io/helm/pkg/timeconv"
+)
+
+// maxReleaseHistory is the number of revisions listed in the history of a release
+const maxReleaseHistory = 256
+
+//ReleaseRevision describes a revision of a Helm release
+type ReleaseRevision struct {
+	Revision    int32  `json:"revision"`
+	Chart       string `json:"chart"`
+	Status      string `json:"status"`
+	Updated     string `json:"updated"`
+	Description string `json:"description,omitempty"`
+}
+
+//ValueChange is a changed value of a release, Path is the dot separated keys of the nested value.
+//From is missing from the added values and To is missing from the removed ones.
+type ValueChange struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+//DeploymentHistory lists the revisions of the release, the latest revision is the first
+func DeploymentHistory(releaseName string, kubeConfig *[]byte) ([]ReleaseRevision, error) {
+	hClient, err := GetHelmClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hClient.ReleaseHistory(releaseName, helm.WithMaxHistory(maxReleaseHistory))
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]ReleaseRevision, 0, len(resp.Releases))
+	for _, r := range resp.Releases {
+		revisions = append(revisions, ReleaseRevision{
+			Revision:    r.Version,
+			Chart:       fmt.Sprintf("%s-%s", r.Chart.Metadata.Name, r.Chart.Metadata.Version),
+			Status:      r.Info.Status.Code.String(),
+			Updated:     timeconv.String(r.Info.LastDeployed),
+			Description: r.Info.Description,
+		})
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+	return revisions, nil
+}
+
+//RollbackDeployment rolls the release back to the revision, the rollback is a new revision of the release
+func RollbackDeployment(releaseName string, revision int32, kubeConfig *[]byte) (int32, error) {
+	hClient, err := GetHelmClient(kubeConfig)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := hClient.RollbackRelease(releaseName, helm.RollbackVersion(revision), helm.RollbackTimeout(30))
+	if err != nil {
+		return 0, fmt.Errorf("rollback failed: %v", err)
+	}
+	return resp.Release.Version, nil
+}
+
+//GetDeploymentValues returns the current revision of the release and its values
+func GetDeploymentValues(releaseName string, kubeConfig *[]byte) (int32, map[string]interface{}, error) {
+	hClient, err := GetHelmClient(kubeConfig)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := hClient.ReleaseContent(releaseName)
+	if err != nil {
+		return 0, nil, err
+	}
+	values := map[string]interface{}{}
+	if config := resp.Release.GetConfig(); config != nil && config.Raw != "" {
+		if err := yaml.Unmarshal([]byte(config.Raw), &values); err != nil {
+			return 0, nil, err
+		}
+	}
+	return resp.Release.Version, values, nil
+}
+
+//DiffValues returns the changes from the values to the new values ordered by their paths,
+//nested maps are compared key by key and other values (like lists) as a whole
+func DiffValues(from, to map[string]interface{}) []ValueChange {
+	changes := []ValueChange{}
+	diffValues("", from, to, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func diffValues(prefix string, from, to map[string]interface{}, changes *[]ValueChange) {
+	for key, value := range from {
+		path := prefix + key
+		newValue, ok := to[key]
+		if !ok {
+			*changes = append(*changes, ValueChange{Path: path, From: value})
+			continue
+		}
+		valueMap, isMap := value.(map[string]interface{})
+		newMap, isNewMap := newValue.(map[string]interface{})
+		if isMap && isNewMap {
+			diffValues(path+".", valueMap, newMap, changes)
+			continue
+		}
+		if !reflect.DeepEqual(value, newValue) {
+			*changes = append(*changes, ValueChange{Path: path, From: value, To: newValue})
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			*changes = append(*changes, ValueChange{Path: prefix + key, To: value})
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package helm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/helm"
+)
+
+func TestDiffValues(t *testing.T) {
+
+	from := map[string]interface{}{
+		"replicaCount": 1.0,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.13"},
+		"ingress":      map[string]interface{}{"enabled": true},
+		"args":         []interface{}{"-v"},
+	}
+	to := map[string]interface{}{
+		"replicaCount": 3.0,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.14"},
+		"ingress":      false,
+		"args":         []interface{}{"-v"},
+		"resources":    map[string]interface{}{"limits": map[string]interface{}{"cpu": "100m"}},
+	}
+
+	expected := []helm.ValueChange{
+		{Path: "image.tag", From: "1.13", To: "1.14"},
+		{Path: "ingress", From: map[string]interface{}{"enabled": true}, To: false},
+		{Path: "replicaCount", From: 1.0, To: 3.0},
+		{Path: "resources", To: map[string]interface{}{"limits": map[string]interface{}{"cpu": "100m"}}},
+	}
+	if changes := helm.DiffValues(from, to); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected changes: %v, got: %v", expected, changes)
+	}
+	if changes := helm.DiffValues(to, to); len(changes) != 0 {
+		t.Errorf("Expected no changes, got: %v", changes)
+	}
+}
@@ -0,0 +1,253 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/getter"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/repo"
+)
+
+// repositoryNamePattern is the pattern of the names of the chart repositories, the charts are referenced as repository/chart
+var repositoryNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+//ChartSearchResult describes the latest version of a chart of a repository, Name is the reference of the chart
+//in the deployments (repository/chart)
+type ChartSearchResult struct {
+	Repository  string `json:"repository"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	AppVersion  string `json:"appVersion,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+//ValidateRepository checks the name and the URL of a chart repository of an organization, the URL is an http(s)
+//chart repository or an OCI registry (oci://registry/path). The names of the default repositories of the clusters are reserved
+func ValidateRepository(name, repositoryURL string) error {
+	if !repositoryNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid repository name %q, it must consist of lower case alphanumeric characters or '-'", name)
+	}
+	if name == stableRepository || name == banzaiRepository {
+		return fmt.Errorf("repository name %q is reserved", name)
+	}
+	u, err := url.Parse(repositoryURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "oci") || u.Host == "" {
+		return fmt.Errorf("invalid repository URL %q, it must be an http, https or oci URL", repositoryURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("the credentials of repository %q must be stored in a secret", name)
+	}
+	return nil
+}
+
+// repositoryIndexFile returns the path of the cached index of the chart repository
+func repositoryIndexFile(repository model.HelmRepositoryModel) string {
+	const stateStorePath = "./statestore/_repositories/"
+	return filepath.Join(stateStorePath, strconv.FormatUint(uint64(repository.OrganizationID), 10), repository.Name+"-index.yaml")
+}
+
+// repositoryURL returns the URL of the chart repository with the basic auth credentials of its secret
+func repositoryURL(repository model.HelmRepositoryModel) (string, error) {
+	if repository.SecretID == "" {
+		return repository.URL, nil
+	}
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(repository.OrganizationID), 10), repository.SecretID)
+	if err != nil {
+		return "", errors.Wrapf(err, "error getting the secret of repository %s", repository.Name)
+	}
+	if item.SecretType != secret.Password {
+		return "", errors.Errorf("the secret of repository %s must be a %s", repository.Name, secret.Password)
+	}
+	u, err := url.Parse(repository.URL)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword(item.Values["username"], item.Values["password"])
+	return u.String(), nil
+}
+
+//RefreshRepositoryIndex downloads the index of the chart repository into the cache, the time or the error
+//of the download is saved in the repository
+func RefreshRepositoryIndex(repository *model.HelmRepositoryModel) error {
+	err := downloadRepositoryIndex(*repository)
+	if err == nil {
+		now := time.Now()
+		repository.IndexUpdatedAt = &now
+		repository.IndexError = ""
+	} else {
+		repository.IndexError = err.Error()
+	}
+	if saveErr := repository.Save(); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// downloadRepositoryIndex downloads the index of the chart repository into its cache file, OCI registries have
+// no index so only their credentials are checked
+func downloadRepositoryIndex(repository model.HelmRepositoryModel) error {
+	if isOCIReference(repository.URL) {
+		return checkRegistry(repository)
+	}
+	u, err := repositoryURL(repository)
+	if err != nil {
+		return err
+	}
+	indexFile, err := filepath.Abs(repositoryIndexFile(repository))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(indexFile), 0755); err != nil {
+		return err
+	}
+	r, err := repo.NewChartRepository(&repo.Entry{Name: repository.Name, URL: u, Cache: indexFile}, getter.All(helm_env.EnvSettings{}))
+	if err != nil {
+		return errors.Wrap(err, "cannot create a new chart repository")
+	}
+	if err := r.DownloadIndexFile(""); err != nil {
+		return errors.Errorf("%q is not a valid chart repository or cannot be reached: %s", repository.URL, err.Error())
+	}
+	return nil
+}
+
+//RunRepositoryRefresh refreshes the cached indexes of every chart repository with the given interval, it never returns
+func RunRepositoryRefresh(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"tag": "RefreshHelmRepositories"})
+	for range time.Tick(interval) {
+		repositories, err := model.ListHelmRepositories(0)
+		if err != nil {
+			log.Errorf("Error listing the chart repositories: %s", err.Error())
+			continue
+		}
+		for i := range repositories {
+			if err := RefreshRepositoryIndex(&repositories[i]); err != nil {
+				log.Warnf("Error refreshing chart repository %s: %s", repositories[i].Name, err.Error())
+			}
+		}
+	}
+}
+
+//AddOrganizationRepositories adds the chart repositories of the organization to the Helm home of the cluster
+//with their cached indexes, so the charts of the repositories can be deployed. The credentials of the OCI registries
+//are logged in to the registry config of the home instead.
+func AddOrganizationRepositories(organizationID uint, path string) error {
+	repositories, err := model.ListHelmRepositories(organizationID)
+	if err != nil || len(repositories) == 0 {
+		return err
+	}
+	settings := createEnvSettings(generateHelmRepoPath(path))
+	if err := ensureDirectories(settings); err != nil {
+		return err
+	}
+	if err := ensureDefaultRepos(settings); err != nil {
+		return err
+	}
+	repoFile := settings.Home.RepositoryFile()
+	file, err := repo.LoadRepositoriesFile(repoFile)
+	if err != nil {
+		return err
+	}
+	auths := map[string]registryAuth{}
+	for i := range repositories {
+		repository := &repositories[i]
+		if isOCIReference(repository.URL) {
+			host, _, err := registryHost(repository.URL)
+			if err != nil {
+				return err
+			}
+			if auths[host], err = registryLogin(*repository); err != nil {
+				return err
+			}
+			continue
+		}
+		if repository.IndexUpdatedAt == nil {
+			if err := RefreshRepositoryIndex(repository); err != nil {
+				return err
+			}
+		}
+		u, err := repositoryURL(*repository)
+		if err != nil {
+			return err
+		}
+		index, err := ioutil.ReadFile(repositoryIndexFile(*repository))
+		if err != nil {
+			return errors.Wrapf(err, "error reading the index of repository %s", repository.Name)
+		}
+		cache := settings.Home.CacheIndex(repository.Name)
+		if err := ioutil.WriteFile(cache, index, 0644); err != nil {
+			return err
+		}
+		file.Update(&repo.Entry{Name: repository.Name, URL: u, Cache: cache})
+	}
+	if len(auths) > 0 {
+		if err := writeRegistryConfig(settings, auths); err != nil {
+			return err
+		}
+	}
+	// the repository file contains the credentials of the repositories
+	return file.WriteFile(repoFile, 0600)
+}
+
+//SearchCharts searches the latest versions of the charts of the cached indexes of the repositories of the organization,
+//the charts of the OCI registries can't be searched
+func SearchCharts(organizationID uint, query string) ([]ChartSearchResult, error) {
+	log := logger.WithFields(logrus.Fields{"tag": "SearchCharts"})
+	repositories, err := model.ListHelmRepositories(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	results := []ChartSearchResult{}
+	for _, repository := range repositories {
+		if isOCIReference(repository.URL) {
+			continue
+		}
+		index, err := repo.LoadIndexFile(repositoryIndexFile(repository))
+		if err != nil {
+			log.Warnf("The index of repository %s isn't cached: %s", repository.Name, err.Error())
+			continue
+		}
+		results = append(results, SearchIndex(repository.Name, index, query)...)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+//SearchIndex returns the latest versions of the charts of the repository index matching the query, the query
+//matches the names, the descriptions and the keywords of the charts case insensitively (every chart if it's empty)
+func SearchIndex(repository string, index *repo.IndexFile, query string) []ChartSearchResult {
+	query = strings.ToLower(query)
+	index.SortEntries()
+	results := []ChartSearchResult{}
+	for name, versions := range index.Entries {
+		if len(versions) == 0 || versions[0].Metadata == nil {
+			continue
+		}
+		latest := versions[0]
+		text := strings.ToLower(strings.Join(append([]string{name, latest.Description}, latest.Keywords...), " "))
+		if !strings.Contains(text, query) {
+			continue
+		}
+		results = append(results, ChartSearchResult{
+			Repository:  repository,
+			Name:        repository + "/" + name,
+			Version:     latest.Version,
+			AppVersion:  latest.AppVersion,
+			Description: latest.Description,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
@@ -0,0 +1,237 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Rollout states of the releases
+const (
+	RolloutProgressing = "Progressing"
+	RolloutReady       = "Ready"
+	RolloutFailed      = "Failed"
+)
+
+// crashReasons are the waiting reasons of the containers which don't start without a change
+var crashReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+	"InvalidImageName":           true,
+}
+
+//WorkloadStatus describes the rollout of a deployment, stateful set, daemon set or job of a release
+type WorkloadStatus struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Desired   int64  `json:"desired"`
+	Updated   int64  `json:"updated"`
+	Ready     int64  `json:"ready"`
+	RolledOut bool   `json:"rolledOut"`
+}
+
+//RolloutStatus aggregates the status of the workloads of a release, it's ready if every workload is rolled out,
+//no pod is crashing and every persistent volume claim is bound. Crashing pods and pending claims are listed as
+//namespace/name (with the reason of the pods).
+type RolloutStatus struct {
+	Ready               bool             `json:"ready"`
+	Workloads           []WorkloadStatus `json:"workloads"`
+	CrashingPods        []string         `json:"crashingPods,omitempty"`
+	PendingVolumeClaims []string         `json:"pendingVolumeClaims,omitempty"`
+}
+
+//Summary describes what the rollout is waiting for
+func (s *RolloutStatus) Summary() string {
+	if s.Ready {
+		return "every workload is ready"
+	}
+	problems := []string{}
+	for _, workload := range s.Workloads {
+		if !workload.RolledOut {
+			problems = append(problems, fmt.Sprintf("%s %s/%s has %d of %d ready", workload.Kind, workload.Namespace, workload.Name, workload.Ready, workload.Desired))
+		}
+	}
+	if len(s.CrashingPods) > 0 {
+		problems = append(problems, "crashing pods: "+strings.Join(s.CrashingPods, ", "))
+	}
+	if len(s.PendingVolumeClaims) > 0 {
+		problems = append(problems, "pending volume claims: "+strings.Join(s.PendingVolumeClaims, ", "))
+	}
+	return strings.Join(problems, "; ")
+}
+
+//GetRolloutStatus checks the workloads, the pods and the persistent volume claims of the manifest of the release
+func GetRolloutStatus(releaseName string, kubeConfig *[]byte) (*RolloutStatus, error) {
+	hClient, err := GetHelmClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hClient.ReleaseContent(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := ParseManifests([]byte(resp.Release.Manifest))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing the manifest of the release")
+	}
+	client, err := newManifestClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	k8sClient, err := GetK8sConnection(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &RolloutStatus{Workloads: []WorkloadStatus{}}
+	for _, object := range objects {
+		if object.GetNamespace() == "" {
+			object.SetNamespace(resp.Release.Namespace)
+		}
+		kind := object.GetKind()
+		if kind != "Deployment" && kind != "StatefulSet" && kind != "DaemonSet" && kind != "Job" && kind != "PersistentVolumeClaim" {
+			continue
+		}
+		resource, err := client.resource(object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting %s %s", kind, object.GetName())
+		}
+		live, err := resource.Get(object.GetName(), metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			live = object
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "error getting %s %s", kind, object.GetName())
+		}
+		id := object.GetNamespace() + "/" + object.GetName()
+		if kind == "PersistentVolumeClaim" {
+			if phase, _ := nestedField(live.Object, "status", "phase").(string); phase != string(v1.ClaimBound) {
+				status.PendingVolumeClaims = append(status.PendingVolumeClaims, id)
+			}
+			continue
+		}
+
+		workload := workloadStatus(live)
+		status.Workloads = append(status.Workloads, workload)
+		if kind == "Job" && workload.RolledOut {
+			// the failed pods of a completed job were retried
+			continue
+		}
+		selector := "job-name=" + object.GetName()
+		if matchLabels, ok := nestedField(live.Object, "spec", "selector", "matchLabels").(map[string]interface{}); ok && kind != "Job" {
+			set := labels.Set{}
+			for key, value := range matchLabels {
+				set[key] = fmt.Sprint(value)
+			}
+			selector = labels.SelectorFromSet(set).String()
+		}
+		pods, err := k8sClient.CoreV1().Pods(object.GetNamespace()).List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing the pods of %s %s", kind, object.GetName())
+		}
+		for _, pod := range pods.Items {
+			if reason := crashReason(pod); reason != "" {
+				status.CrashingPods = append(status.CrashingPods, fmt.Sprintf("%s/%s (%s)", pod.Namespace, pod.Name, reason))
+			}
+		}
+	}
+
+	status.Ready = len(status.CrashingPods) == 0 && len(status.PendingVolumeClaims) == 0
+	for _, workload := range status.Workloads {
+		status.Ready = status.Ready && workload.RolledOut
+	}
+	return status, nil
+}
+
+//WaitForRollout polls the rollout status of the release until it's ready or the timeout expires,
+//the last status is returned in both cases
+func WaitForRollout(releaseName string, kubeConfig *[]byte, interval, timeout time.Duration) (*RolloutStatus, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := GetRolloutStatus(releaseName, kubeConfig)
+		if err == nil && status.Ready {
+			return status, nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, err
+			}
+			return status, fmt.Errorf("rollout of %s timed out: %s", releaseName, status.Summary())
+		}
+		time.Sleep(interval)
+	}
+}
+
+// workloadStatus reads the desired, the updated and the ready replicas of the live workload
+func workloadStatus(live *unstructured.Unstructured) WorkloadStatus {
+	workload := WorkloadStatus{Kind: live.GetKind(), Namespace: live.GetNamespace(), Name: live.GetName()}
+	object := live.Object
+	switch workload.Kind {
+	case "Deployment", "StatefulSet":
+		workload.Desired = 1
+		if _, ok := number(nestedField(object, "spec", "replicas")); ok {
+			workload.Desired = nestedInt(object, "spec", "replicas")
+		}
+		workload.Updated = nestedInt(object, "status", "updatedReplicas")
+		workload.Ready = nestedInt(object, "status", "readyReplicas")
+		if workload.Kind == "Deployment" {
+			workload.Ready = nestedInt(object, "status", "availableReplicas")
+		}
+		observed := nestedInt(object, "status", "observedGeneration") >= live.GetGeneration()
+		workload.RolledOut = observed && workload.Updated >= workload.Desired && workload.Ready >= workload.Desired
+	case "DaemonSet":
+		workload.Desired = nestedInt(object, "status", "desiredNumberScheduled")
+		workload.Updated = nestedInt(object, "status", "updatedNumberScheduled")
+		workload.Ready = nestedInt(object, "status", "numberReady")
+		workload.RolledOut = workload.Updated >= workload.Desired && workload.Ready >= workload.Desired
+	case "Job":
+		workload.Desired = 1
+		if _, ok := number(nestedField(object, "spec", "completions")); ok {
+			workload.Desired = nestedInt(object, "spec", "completions")
+		}
+		workload.Ready = nestedInt(object, "status", "succeeded")
+		workload.Updated = workload.Ready
+		workload.RolledOut = workload.Ready >= workload.Desired
+	}
+	return workload
+}
+
+// crashReason returns why the pod is crashing, it's empty if the pod isn't crashing
+func crashReason(pod v1.Pod) string {
+	if pod.Status.Phase == v1.PodFailed {
+		return "Failed"
+	}
+	statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+	for _, container := range statuses {
+		if waiting := container.State.Waiting; waiting != nil && crashReasons[waiting.Reason] {
+			return waiting.Reason
+		}
+	}
+	return ""
+}
+
+func nestedField(object map[string]interface{}, fields ...string) interface{} {
+	var value interface{} = object
+	for _, field := range fields {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[field]
+	}
+	return value
+}
+
+func nestedInt(object map[string]interface{}, fields ...string) int64 {
+	n, _ := number(nestedField(object, fields...))
+	return int64(n)
+}
@@ -0,0 +1,378 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// chartSchemaFile is the JSON schema of the values of a chart
+const chartSchemaFile = "values.schema.json"
+
+//ValueError describes an invalid field of the deployment values, Path is the path of the field (e.g. image.tag)
+type ValueError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+//ValuesValidationError is returned if the deployment values don't match the schemas of the chart
+type ValuesValidationError struct {
+	Chart  string       `json:"chart"`
+	Errors []ValueError `json:"errors"`
+}
+
+func (e *ValuesValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, valueError := range e.Errors {
+		messages[i] = valueError.Path + ": " + valueError.Message
+	}
+	return fmt.Sprintf("invalid values of chart %s: %s", e.Chart, strings.Join(messages, "; "))
+}
+
+// validateChartValues validates the values of the chart merged with the given YAML values against the
+// values.schema.json of the chart and the override schema of Pipeline (<helm.valuesSchemaDir>/<chart>.schema.json)
+func validateChartValues(chrt *chart.Chart, rawValues []byte) error {
+	schemas, err := chartSchemas(chrt)
+	if err != nil || len(schemas) == 0 {
+		return err
+	}
+	values, err := chartutil.CoalesceValues(chrt, &chart.Config{Raw: string(rawValues)})
+	if err != nil {
+		return errors.Wrap(err, "error merging the values of the chart")
+	}
+	var valueErrors []ValueError
+	for _, schema := range schemas {
+		valueErrors = append(valueErrors, ValidateValues(schema, values)...)
+	}
+	if len(valueErrors) > 0 {
+		return &ValuesValidationError{Chart: chrt.GetMetadata().GetName(), Errors: valueErrors}
+	}
+	return nil
+}
+
+// chartSchemas returns the schema of the chart and the override schema of Pipeline if they exist
+func chartSchemas(chrt *chart.Chart) ([]map[string]interface{}, error) {
+	var schemas []map[string]interface{}
+	for _, file := range chrt.GetFiles() {
+		if file.TypeUrl == chartSchemaFile {
+			schema, err := parseSchema(file.Value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", chartSchemaFile)
+			}
+			schemas = append(schemas, schema)
+		}
+	}
+	if dir := viper.GetString("helm.valuesSchemaDir"); dir != "" {
+		data, err := ioutil.ReadFile(filepath.Join(dir, chrt.GetMetadata().GetName()+".schema.json"))
+		if err == nil {
+			schema, err := parseSchema(data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid override schema of chart %s", chrt.GetMetadata().GetName())
+			}
+			schemas = append(schemas, schema)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+func parseSchema(data []byte) (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	err := json.Unmarshal(data, &schema)
+	return schema, err
+}
+
+//ValidateValues validates the values against the JSON schema, it supports the type, enum, const, properties,
+//required, additionalProperties, items, length, range, pattern, allOf, anyOf, oneOf and local $ref keywords
+func ValidateValues(schema map[string]interface{}, values interface{}) []ValueError {
+	v := schemaValidator{root: schema}
+	v.validate(schema, values, "")
+	return v.errors
+}
+
+type schemaValidator struct {
+	root   map[string]interface{}
+	errors []ValueError
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	v.errors = append(v.errors, ValueError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches checks the value against the schema without recording the errors
+func (v *schemaValidator) matches(schema interface{}, value interface{}, path string) bool {
+	sub := schemaValidator{root: v.root}
+	sub.validate(schema, value, path)
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) validate(schemaValue interface{}, value interface{}, path string) {
+	if values, ok := value.(chartutil.Values); ok {
+		value = map[string]interface{}(values)
+	}
+	schema, ok := schemaValue.(map[string]interface{})
+	if !ok {
+		// boolean schemas: false rejects every value
+		if allowed, ok := schemaValue.(bool); ok && !allowed {
+			v.fail(path, "is not allowed")
+		}
+		return
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%s", err.Error())
+			return
+		}
+		v.validate(resolved, value, path)
+		return
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		valid := false
+		for _, t := range types {
+			valid = valid || isType(value, t)
+		}
+		if !valid {
+			v.fail(path, "must be %s, not %s", strings.Join(types, " or "), typeName(value))
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || equalValues(e, value)
+		}
+		if !found {
+			v.fail(path, "must be one of %v", enum)
+		}
+	}
+	if c, ok := schema["const"]; ok && !equalValues(c, value) {
+		v.fail(path, "must be %v", c)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	case []interface{}:
+		v.validateArray(schema, value, path)
+	case string:
+		length := float64(len([]rune(value)))
+		if min, ok := number(schema["minLength"]); ok && length < min {
+			v.fail(path, "must be at least %v characters long", min)
+		}
+		if max, ok := number(schema["maxLength"]); ok && length > max {
+			v.fail(path, "must be at most %v characters long", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err != nil {
+				v.fail(path, "invalid pattern %q in the schema", pattern)
+			} else if !re.MatchString(value) {
+				v.fail(path, "must match %q", pattern)
+			}
+		}
+	default:
+		if n, ok := number(value); ok {
+			if min, ok := number(schema["minimum"]); ok && n < min {
+				v.fail(path, "must be greater than or equal to %v", min)
+			}
+			if max, ok := number(schema["maximum"]); ok && n > max {
+				v.fail(path, "must be less than or equal to %v", max)
+			}
+			if min, ok := number(schema["exclusiveMinimum"]); ok && n <= min {
+				v.fail(path, "must be greater than %v", min)
+			}
+			if max, ok := number(schema["exclusiveMaximum"]); ok && n >= max {
+				v.fail(path, "must be less than %v", max)
+			}
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			v.validate(s, value, path)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		valid := false
+		for _, s := range anyOf {
+			valid = valid || v.matches(s, value, path)
+		}
+		if !valid {
+			v.fail(path, "must match at least one of the anyOf schemas")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, s := range oneOf {
+			if v.matches(s, value, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.fail(path, "must match exactly one of the oneOf schemas, it matches %d", matched)
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := value[name]; !ok {
+				v.fail(joinPath(path, name), "is required")
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := properties[name]; ok {
+			v.validate(property, value[name], joinPath(path, name))
+		} else if additional, ok := schema["additionalProperties"]; ok {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.fail(joinPath(path, name), "is not allowed")
+			} else {
+				v.validate(additional, value[name], joinPath(path, name))
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	length := float64(len(value))
+	if min, ok := number(schema["minItems"]); ok && length < min {
+		v.fail(path, "must have at least %v items", min)
+	}
+	if max, ok := number(schema["maxItems"]); ok && length > max {
+		v.fail(path, "must have at most %v items", max)
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// resolve returns the schema of a local reference (#/definitions/name)
+func (v *schemaValidator) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.Errorf("unsupported schema reference %q", ref)
+	}
+	var current interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.Replace(strings.Replace(part, "~1", "/", -1), "~0", "~", -1)
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid schema reference %q", ref)
+		}
+		if current, ok = object[part]; !ok {
+			return nil, errors.Errorf("invalid schema reference %q", ref)
+		}
+	}
+	return current, nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func isType(value interface{}, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := number(value)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := number(value)
+		return ok
+	}
+	return typeName(value) == t
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func equalValues(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
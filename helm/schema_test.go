@@ -0,0 +1,68 @@
+package helm_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/helm"
+)
+
+const testValuesSchema = `{
+	"type": "object",
+	"required": ["image"],
+	"properties": {
+		"image": {
+			"type": "object",
+			"required": ["repository"],
+			"properties": {
+				"repository": {"type": "string", "minLength": 1},
+				"pullPolicy": {"enum": ["Always", "IfNotPresent", "Never"]}
+			}
+		},
+		"replicaCount": {"type": "integer", "minimum": 1},
+		"ports": {"type": "array", "items": {"$ref": "#/definitions/port"}}
+	},
+	"definitions": {
+		"port": {"type": "integer", "maximum": 65535}
+	}
+}`
+
+func TestValidateValues(t *testing.T) {
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(testValuesSchema), &schema); err != nil {
+		t.Fatalf("Error parsing schema: %s", err.Error())
+	}
+
+	cases := []struct {
+		name     string
+		values   string
+		expected []string
+	}{
+		{name: "valid", values: `{"image": {"repository": "nginx", "pullPolicy": "Always"}, "replicaCount": 2, "ports": [80, 443]}`},
+		{name: "missing field", values: `{"image": {}}`, expected: []string{"image.repository"}},
+		{name: "wrong type", values: `{"image": {"repository": "nginx"}, "replicaCount": "two"}`, expected: []string{"replicaCount"}},
+		{name: "not an integer", values: `{"image": {"repository": "nginx"}, "replicaCount": 1.5}`, expected: []string{"replicaCount"}},
+		{name: "out of range", values: `{"image": {"repository": "nginx"}, "replicaCount": 0, "ports": [80, 70000]}`, expected: []string{"ports[1]", "replicaCount"}},
+		{name: "enum", values: `{"image": {"repository": "nginx", "pullPolicy": "Sometimes"}}`, expected: []string{"image.pullPolicy"}},
+		{name: "root required", values: `{}`, expected: []string{"image"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var values map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.values), &values); err != nil {
+				t.Fatalf("Error parsing values: %s", err.Error())
+			}
+			valueErrors := helm.ValidateValues(schema, values)
+			if len(valueErrors) != len(tc.expected) {
+				t.Fatalf("Expected invalid fields: %v, got: %v", tc.expected, valueErrors)
+			}
+			for i, valueError := range valueErrors {
+				if valueError.Path != tc.expected[i] {
+					t.Errorf("Expected invalid field: %s, got: %s", tc.expected[i], valueError.Path)
+				}
+			}
+		})
+	}
+}
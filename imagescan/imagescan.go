@@ -0,0 +1,135 @@
+package imagescan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severities of the vulnerabilities, from the least severe
+const (
+	SeverityUnknown  = "UNKNOWN"
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+// ScannerTrivy is the scanner running the Trivy CLI
+const ScannerTrivy = "trivy"
+
+var severityRanks = map[string]int{
+	SeverityUnknown:  0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+//ValidSeverity checks whether the severity is known
+func ValidSeverity(severity string) bool {
+	_, ok := severityRanks[severity]
+	return ok
+}
+
+//Vulnerability is a vulnerability of a package of an image
+type Vulnerability struct {
+	ID               string `json:"id"`
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installedVersion"`
+	FixedVersion     string `json:"fixedVersion,omitempty"`
+	Severity         string `json:"severity"`
+	Title            string `json:"title,omitempty"`
+}
+
+//Report is the result of the scan of an image
+type Report struct {
+	Image           string          `json:"image"`
+	ScannedAt       time.Time       `json:"scannedAt"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+//Summary counts the vulnerabilities of the report by severity
+func (r *Report) Summary() map[string]int {
+	summary := map[string]int{}
+	for _, vulnerability := range r.Vulnerabilities {
+		summary[vulnerability.Severity]++
+	}
+	return summary
+}
+
+//Credentials is the basic auth of the registry of a scanned image, it's empty for public images
+type Credentials struct {
+	Username string
+	Password string
+}
+
+//Scanner scans the vulnerabilities of container images
+type Scanner interface {
+	Scan(image string, credentials Credentials) (*Report, error)
+}
+
+//NewScanner creates the scanner of the kind, the server is the remote scanner service to use if it's not empty
+func NewScanner(kind, binary, server string, timeout time.Duration) (Scanner, error) {
+	switch kind {
+	case ScannerTrivy:
+		return NewTrivyScanner(binary, server, timeout), nil
+	}
+	return nil, fmt.Errorf("unsupported image scanner: %s", kind)
+}
+
+//Policy decides which vulnerabilities prevent the deployment of an image: the ones of the severity or above,
+//except the ignored IDs and, with IgnoreUnfixed, the ones without a fix
+type Policy struct {
+	Severity      string   `json:"severity"`
+	IgnoreUnfixed bool     `json:"ignoreUnfixed"`
+	Ignored       []string `json:"ignored,omitempty"`
+}
+
+//Violations returns the vulnerabilities of the report the policy doesn't allow, the most severe first
+func (p Policy) Violations(report *Report) []Vulnerability {
+	threshold, ok := severityRanks[p.Severity]
+	if !ok {
+		threshold = severityRanks[SeverityCritical]
+	}
+	ignored := make(map[string]bool, len(p.Ignored))
+	for _, id := range p.Ignored {
+		ignored[id] = true
+	}
+	violations := []Vulnerability{}
+	for _, vulnerability := range report.Vulnerabilities {
+		if severityRanks[vulnerability.Severity] < threshold || ignored[vulnerability.ID] {
+			continue
+		}
+		if p.IgnoreUnfixed && vulnerability.FixedVersion == "" {
+			continue
+		}
+		violations = append(violations, vulnerability)
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		return severityRanks[violations[i].Severity] > severityRanks[violations[j].Severity]
+	})
+	return violations
+}
+
+//ImageViolations are the vulnerabilities of an image the policy doesn't allow
+type ImageViolations struct {
+	Image           string          `json:"image"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+//PolicyError is returned when images of a deployment have vulnerabilities the policy doesn't allow
+type PolicyError struct {
+	Severity string
+	Images   []ImageViolations
+}
+
+// Error implements error
+func (e *PolicyError) Error() string {
+	images := make([]string, 0, len(e.Images))
+	for _, image := range e.Images {
+		images = append(images, fmt.Sprintf("%s (%d)", image.Image, len(image.Vulnerabilities)))
+	}
+	return fmt.Sprintf("images have vulnerabilities of %s severity or above: %s", e.Severity, strings.Join(images, ", "))
+}
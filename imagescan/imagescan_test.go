@@ -0,0 +1,91 @@
+package imagescan_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/imagescan"
+)
+
+const trivyReport = `{"SchemaVersion": 2, "ArtifactName": "acme/web:1.0", "Results": [
+  {"Target": "acme/web:1.0 (alpine 3.8.2)", "Vulnerabilities": [
+    {"VulnerabilityID": "CVE-2019-1", "PkgName": "openssl", "InstalledVersion": "1.1.1a", "FixedVersion": "1.1.1b", "Severity": "CRITICAL"},
+    {"VulnerabilityID": "CVE-2019-2", "PkgName": "musl", "InstalledVersion": "1.1.19", "Severity": "HIGH"},
+    {"VulnerabilityID": "CVE-2019-3", "PkgName": "busybox", "InstalledVersion": "1.28.4", "FixedVersion": "1.28.5", "Severity": "MEDIUM"}
+  ]},
+  {"Target": "app/package-lock.json"}
+]}`
+
+// fakeTrivy writes a script printing the report instead of Trivy, it fails without the registry credentials
+func fakeTrivy(t *testing.T, output string) string {
+	dir, err := ioutil.TempDir("", "trivy")
+	if err != nil {
+		t.Fatalf("Error during creating temp dir: %s", err.Error())
+	}
+	binary := filepath.Join(dir, "trivy")
+	script := "#!/bin/sh\n[ \"$TRIVY_USERNAME\" = user ] || { echo unauthorized >&2; exit 1; }\ncat <<'EOF'\n" + output + "\nEOF\n"
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("Error during writing fake trivy: %s", err.Error())
+	}
+	return binary
+}
+
+func TestTrivyScanner(t *testing.T) {
+
+	for _, output := range []string{trivyReport, `[{"Target": "acme/web:1.0", "Vulnerabilities": [{"VulnerabilityID": "CVE-2019-1", "Severity": "critical"}]}]`} {
+		binary := fakeTrivy(t, output)
+		defer os.RemoveAll(filepath.Dir(binary))
+		scanner := imagescan.NewTrivyScanner(binary, "", time.Minute)
+
+		if _, err := scanner.Scan("acme/web:1.0", imagescan.Credentials{}); err == nil {
+			t.Errorf("Expected error, but not got error!")
+		}
+		report, err := scanner.Scan("acme/web:1.0", imagescan.Credentials{Username: "user", Password: "password"})
+		if err != nil {
+			t.Fatalf("Error during scanning image: %s", err.Error())
+		}
+		if len(report.Vulnerabilities) == 0 || report.Vulnerabilities[0].ID != "CVE-2019-1" || report.Vulnerabilities[0].Severity != imagescan.SeverityCritical {
+			t.Errorf("Expected the critical CVE-2019-1, got: %v", report.Vulnerabilities)
+		}
+	}
+}
+
+func TestPolicyViolations(t *testing.T) {
+
+	report := &imagescan.Report{Image: "acme/web:1.0", Vulnerabilities: []imagescan.Vulnerability{
+		{ID: "CVE-2019-3", Severity: imagescan.SeverityMedium, FixedVersion: "1.28.5"},
+		{ID: "CVE-2019-2", Severity: imagescan.SeverityHigh},
+		{ID: "CVE-2019-1", Severity: imagescan.SeverityCritical, FixedVersion: "1.1.1b"},
+	}}
+	cases := []struct {
+		name     string
+		policy   imagescan.Policy
+		expected []string
+	}{
+		{name: "critical", policy: imagescan.Policy{Severity: imagescan.SeverityCritical}, expected: []string{"CVE-2019-1"}},
+		{name: "high", policy: imagescan.Policy{Severity: imagescan.SeverityHigh}, expected: []string{"CVE-2019-1", "CVE-2019-2"}},
+		{name: "ignore unfixed", policy: imagescan.Policy{Severity: imagescan.SeverityLow, IgnoreUnfixed: true}, expected: []string{"CVE-2019-1", "CVE-2019-3"}},
+		{name: "ignored", policy: imagescan.Policy{Severity: imagescan.SeverityHigh, Ignored: []string{"CVE-2019-1"}}, expected: []string{"CVE-2019-2"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := tc.policy.Violations(report)
+			ids := []string{}
+			for _, violation := range violations {
+				ids = append(ids, violation.ID)
+			}
+			if len(ids) != len(tc.expected) {
+				t.Fatalf("Expected %v, got: %v", tc.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != tc.expected[i] {
+					t.Errorf("Expected %v, got: %v", tc.expected, ids)
+				}
+			}
+		})
+	}
+}
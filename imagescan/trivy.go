@@ -0,0 +1,102 @@
+package imagescan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// trivyScanner runs the Trivy CLI, in client mode if there is a Trivy server
+type trivyScanner struct {
+	binary  string
+	server  string
+	timeout time.Duration
+}
+
+// trivyResult is a scanned target (the OS or a language package file) of the JSON output of Trivy
+type trivyResult struct {
+	Target          string `json:"Target"`
+	Vulnerabilities []struct {
+		VulnerabilityID  string `json:"VulnerabilityID"`
+		PkgName          string `json:"PkgName"`
+		InstalledVersion string `json:"InstalledVersion"`
+		FixedVersion     string `json:"FixedVersion"`
+		Severity         string `json:"Severity"`
+		Title            string `json:"Title"`
+	} `json:"Vulnerabilities"`
+}
+
+//NewTrivyScanner creates a Scanner running the Trivy binary, the scans are sent to the Trivy server if it's
+//not empty
+func NewTrivyScanner(binary, server string, timeout time.Duration) Scanner {
+	return &trivyScanner{binary: binary, server: server, timeout: timeout}
+}
+
+// Scan implements Scanner, the registry credentials are passed in the environment of Trivy
+func (s *trivyScanner) Scan(image string, credentials Credentials) (*Report, error) {
+	args := []string{"image", "--quiet", "--no-progress", "--format", "json"}
+	if s.server != "" {
+		args = append(args, "--server", s.server)
+	}
+	args = append(args, "--", image)
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.binary, args...)
+	cmd.Env = os.Environ()
+	if credentials.Username != "" || credentials.Password != "" {
+		cmd.Env = append(cmd.Env, "TRIVY_USERNAME="+credentials.Username, "TRIVY_PASSWORD="+credentials.Password)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, errors.Errorf("scanning %s timed out after %s", image, s.timeout)
+	} else if err != nil {
+		return nil, errors.Errorf("error scanning %s: %s %s", image, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return parseTrivyReport(image, output)
+}
+
+// parseTrivyReport reads the JSON output of Trivy, a list of results before Trivy 0.20 and a report
+// with the list of results since
+func parseTrivyReport(image string, output []byte) (*Report, error) {
+	var results []trivyResult
+	if trimmed := bytes.TrimSpace(output); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &results); err != nil {
+			return nil, errors.Wrap(err, "error parsing the report of trivy")
+		}
+	} else {
+		var report struct {
+			Results []trivyResult `json:"Results"`
+		}
+		if err := json.Unmarshal(trimmed, &report); err != nil {
+			return nil, errors.Wrap(err, "error parsing the report of trivy")
+		}
+		results = report.Results
+	}
+
+	report := &Report{Image: image, ScannedAt: time.Now(), Vulnerabilities: []Vulnerability{}}
+	for _, result := range results {
+		for _, vulnerability := range result.Vulnerabilities {
+			severity := strings.ToUpper(vulnerability.Severity)
+			if !ValidSeverity(severity) {
+				severity = SeverityUnknown
+			}
+			report.Vulnerabilities = append(report.Vulnerabilities, Vulnerability{
+				ID:               vulnerability.VulnerabilityID,
+				Package:          vulnerability.PkgName,
+				InstalledVersion: vulnerability.InstalledVersion,
+				FixedVersion:     vulnerability.FixedVersion,
+				Severity:         severity,
+				Title:            vulnerability.Title,
+			})
+		}
+	}
+	return report, nil
+}
@@ -2,17 +2,24 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/banzaicloud/pipeline/api"
+	"github.com/banzaicloud/pipeline/apiversion"
 	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
 	"github.com/banzaicloud/pipeline/config"
+	"github.com/banzaicloud/pipeline/helm"
 	"github.com/banzaicloud/pipeline/model"
 	"github.com/banzaicloud/pipeline/model/defaults"
 	"github.com/banzaicloud/pipeline/notify"
+	"github.com/banzaicloud/pipeline/rpc"
+	"github.com/banzaicloud/pipeline/secret"
 	"github.com/banzaicloud/pipeline/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/qor/auth/auth_identity"
 	sessionManager "github.com/qor/session/manager"
 	"github.com/sirupsen/logrus"
@@ -69,11 +76,51 @@ func main() {
 		&model.ClusterModel{},
 		&model.AmazonClusterModel{},
 		&model.AzureClusterModel{},
+		&model.EKSClusterModel{},
+		&model.NodePoolModel{},
+		&model.ClusterStepModel{},
 		&model.GoogleClusterModel{},
 		&auth_identity.AuthIdentity{},
 		&auth.User{},
 		&auth.UserOrganization{},
 		&auth.Organization{},
+		&auth.AccessTokenModel{},
+		&auth.ServiceAccount{},
+		&auth.TokenAuditEvent{},
+		&auth.RevokedTokenModel{},
+		&auth.RefreshTokenModel{},
+		&auth.Team{},
+		&auth.Policy{},
+		&secret.SecretGrant{},
+		&model.SecretInjectionModel{},
+		&model.ClusterProfileModel{},
+		&model.ClusterCostModel{},
+		&model.ClusterUtilizationModel{},
+		&model.HibernationScheduleModel{},
+		&model.DeploymentModel{},
+		&model.AddonModel{},
+		&model.CanaryModel{},
+		&model.GitOpsAppModel{},
+		&model.CertificateModel{},
+		&model.AlertRuleModel{},
+		&model.AlertReceiverModel{},
+		&model.LoggingOutputModel{},
+		&model.HelmRepositoryModel{},
+		&model.EventModel{},
+		&model.IdempotencyKeyModel{},
+		&model.WebhookModel{},
+		&model.WebhookDeliveryModel{},
+		&model.PodSessionModel{},
+		&model.CIRepositoryModel{},
+		&model.ContainerRegistryModel{},
+		&model.ImageScanPolicyModel{},
+		&model.DeploymentApprovalModel{},
+		&model.MaintenanceWindowModel{},
+		&model.ScheduledOperationModel{},
+		&model.BackupBucketModel{},
+		&model.EtcdSnapshotPolicyModel{},
+		&model.ObjectStoreBucketModel{},
+		&model.ManagedDatabaseModel{},
 		&defaults.AWSProfile{},
 		&defaults.AKSProfile{},
 		&defaults.GKEProfile{}).Error; err != nil {
@@ -83,6 +130,35 @@ func main() {
 
 	defaults.SetDefaultValues()
 
+	if err := cluster.FailInterruptedOperations(); err != nil {
+		logger.Errorf("Error during failing the interrupted cluster operations: %s", err.Error())
+	}
+	if err := cluster.FailInterruptedScheduledOperations(); err != nil {
+		logger.Errorf("Error during failing the interrupted scheduled operations: %s", err.Error())
+	}
+
+	if viper.GetBool("chargeback.enabled") {
+		go cluster.RunCostTracking(viper.GetDuration("chargeback.interval"))
+	}
+	if viper.GetBool("utilization.enabled") {
+		go cluster.RunUtilizationTracking(viper.GetDuration("utilization.interval"))
+	}
+
+	go helm.RunRepositoryRefresh(viper.GetDuration("helm.repositoryRefreshInterval"))
+	go cluster.RunCanaryAnalysis(viper.GetDuration("canary.analysisInterval"))
+	go cluster.RunGitOpsReconciler(viper.GetDuration("gitops.syncInterval"))
+	go cluster.RunCertificateMonitor(viper.GetDuration("certificates.checkInterval"))
+	go notify.RunWebhookDeliveries(viper.GetDuration("webhooks.retryInterval"))
+	go cluster.RunOperationScheduler(viper.GetDuration("scheduler.interval"))
+	go cluster.RunDatabaseProvisioner(viper.GetDuration("databases.checkInterval"))
+
+	if viper.GetBool("hibernation.enabled") {
+		go cluster.RunHibernationScheduler()
+	}
+	if viper.GetBool("etcdsnapshots.enabled") {
+		go cluster.RunEtcdSnapshotScheduler()
+	}
+
 	router := gin.Default()
 
 	router.Use(cors.New(config.GetCORS()))
@@ -95,63 +171,304 @@ func main() {
 
 	authGroup := router.Group("/auth/")
 	{
+		authGroup.POST("/refresh", auth.RefreshToken)
+		authGroup.POST("/ldap/login", authHandler)
+		authGroup.POST("/saml/callback", authHandler)
 		authGroup.GET("/*w", authHandler)
 		authGroup.GET("/*w/*w", authHandler)
 	}
 
+	// Access tokens can be restricted to these resources with scopes
+	clusterScope := auth.ScopeMiddleware(auth.ScopeResourceCluster)
+	deploymentScope := auth.ScopeMiddleware(auth.ScopeResourceDeployment)
+	profileScope := auth.ScopeMiddleware(auth.ScopeResourceProfile)
+	secretScope := auth.ScopeMiddleware(auth.ScopeResourceSecret)
+	organizationScope := auth.ScopeMiddleware(auth.ScopeResourceOrganization)
+	tokenScope := auth.RequireScope(auth.ScopeAll)
+
+	// Viewers can read, members can modify the resources of an organization,
+	// admins manage its members, teams and service accounts
+	orgAdmin := auth.RequireOrganizationRole(auth.RoleAdmin)
+	// the exec and port-forward sessions are opened with GET requests but they change the pods
+	orgMember := auth.RequireOrganizationRole(auth.RoleMember)
+	clusterWriteScope := auth.RequireScope(auth.ScopeResourceCluster + ":write")
+
 	v1 := router.Group("/api/v1/")
 	{
-		v1.Use(auth.Handler)
+		v1.Use(apiversion.Deprecation(viper.GetString("api.v1.sunset")), auth.Handler, auth.ImpersonationMiddleware, api.ValidateRequestBody, api.SelectFields)
 		orgs := v1.Group("/orgs")
 		{
-			orgs.Use(api.OrganizationMiddleware)
-			orgs.POST("/:orgid/clusters", api.CreateCluster)
+			orgs.Use(api.OrganizationMiddleware, auth.OrganizationRoleMiddleware)
+			orgs.POST("/:orgid/clusters", clusterScope, api.Idempotent, api.CreateCluster)
+			orgs.POST("/:orgid/import/clusters", clusterScope, api.ImportCluster)
 			//v1.GET("/status", api.Status)
-			orgs.GET("/:orgid/clusters", api.FetchClusters)
-			orgs.GET("/:orgid/clusters/:id", api.FetchCluster)
-			orgs.PUT("/:orgid/clusters/:id", api.UpdateCluster)
-			orgs.DELETE("/:orgid/clusters/:id", api.DeleteCluster)
-			orgs.HEAD("/:orgid/clusters/:id", api.GetClusterStatus)
-			orgs.GET("/:orgid/clusters/:id/config", api.GetClusterConfig)
-			orgs.GET("/:orgid/clusters/:id/apiendpoint", api.GetApiEndpoint)
-			orgs.POST("/:orgid/clusters/:id/monitoring", api.UpdateMonitoring)
-			orgs.GET("/:orgid/clusters/:id/endpoints", api.ListEndpoints)
-			orgs.GET("/:orgid/clusters/:id/deployments", api.ListDeployments)
-			orgs.POST("/:orgid/clusters/:id/deployments", api.CreateDeployment)
-			orgs.HEAD("/:orgid/clusters/:id/deployments", api.GetTillerStatus)
-			orgs.DELETE("/:orgid/clusters/:id/deployments/:name", api.DeleteDeployment)
-			orgs.PUT("/:orgid/clusters/:id/deployments/:name", api.UpgradeDeployment)
-			orgs.HEAD("/:orgid/clusters/:id/deployments/:name", api.HelmDeploymentStatus)
-			orgs.POST("/:orgid/clusters/:id/helminit", api.InitHelmOnCluster)
-			orgs.GET("/:orgid/profiles/cluster/:type", api.GetClusterProfiles)
-			orgs.POST("/:orgid/profiles/cluster", api.AddClusterProfile)
-			orgs.PUT("/:orgid/profiles/cluster", api.UpdateClusterProfile)
-			orgs.DELETE("/:orgid/profiles/cluster/:type/:name", api.DeleteClusterProfile)
-			orgs.GET("/:orgid/secrets", api.ListSecrets)
-			orgs.GET("/:orgid/secrets/:type", api.ListSecrets)
-			orgs.POST("/:orgid/secrets", api.AddSecrets)
-			orgs.DELETE("/:orgid/secrets/:secretid", api.DeleteSecrets)
-			orgs.GET("/:orgid/users", api.GetUsers)
-			orgs.GET("/:orgid/users/:id", api.GetUsers)
-
-			orgs.GET("/:orgid/allowed/secrets/", api.ListAllowedSecretTypes)
-			orgs.GET("/:orgid/allowed/secrets/:type", api.ListAllowedSecretTypes)
+			orgs.GET("/:orgid/clusters", clusterScope, api.FetchClusters)
+			orgs.GET("/:orgid/clusters/:id", clusterScope, api.FetchCluster)
+			orgs.PUT("/:orgid/clusters/:id", clusterScope, api.UpdateCluster)
+			orgs.DELETE("/:orgid/clusters/:id", clusterScope, api.DeleteCluster)
+			orgs.HEAD("/:orgid/clusters/:id", clusterScope, api.GetClusterStatus)
+			orgs.GET("/:orgid/clusters/:id/status", clusterScope, api.GetClusterPhase)
+			orgs.GET("/:orgid/clusters/:id/status/stream", clusterScope, api.StreamClusterStatus)
+			orgs.GET("/:orgid/clusters/:id/tags", clusterScope, api.GetClusterTags)
+			orgs.GET("/:orgid/clusters/:id/cost", clusterScope, api.GetClusterCost)
+			orgs.GET("/:orgid/clusters/:id/costs", clusterScope, api.GetClusterCosts)
+			orgs.GET("/:orgid/clusters/:id/utilization", clusterScope, api.GetClusterUtilization)
+			orgs.PUT("/:orgid/clusters/:id/protection", clusterScope, api.SetDeletionProtection)
+			orgs.GET("/:orgid/clusters/:id/approval", clusterScope, api.GetDeploymentApprovalSettings)
+			orgs.PUT("/:orgid/clusters/:id/approval", clusterScope, orgAdmin, api.SetDeploymentApprovalSettings)
+			orgs.GET("/:orgid/clusters/:id/hibernation", clusterScope, api.GetHibernationSchedule)
+			orgs.PUT("/:orgid/clusters/:id/hibernation", clusterScope, api.SetHibernationSchedule)
+			orgs.DELETE("/:orgid/clusters/:id/hibernation", clusterScope, api.DeleteHibernationSchedule)
+			orgs.GET("/:orgid/clusters/:id/maintenancewindow", clusterScope, api.GetMaintenanceWindow)
+			orgs.PUT("/:orgid/clusters/:id/maintenancewindow", clusterScope, api.SetMaintenanceWindow)
+			orgs.DELETE("/:orgid/clusters/:id/maintenancewindow", clusterScope, api.DeleteMaintenanceWindow)
+			orgs.POST("/:orgid/clusters/:id/suspend", clusterScope, api.SuspendCluster)
+			orgs.POST("/:orgid/clusters/:id/resume", clusterScope, api.ResumeCluster)
+			orgs.GET("/:orgid/clusters/:id/nodepools", clusterScope, api.GetNodePools)
+			orgs.POST("/:orgid/clusters/:id/nodepools", clusterScope, api.AddNodePool)
+			orgs.PUT("/:orgid/clusters/:id/nodepools/:name", clusterScope, api.UpdateNodePool)
+			orgs.DELETE("/:orgid/clusters/:id/nodepools/:name", clusterScope, api.DeleteNodePool)
+			orgs.POST("/:orgid/clusters/:id/upgrade", clusterScope, api.UpgradeCluster)
+			orgs.GET("/:orgid/clusters/:id/config", clusterScope, orgAdmin, api.GetClusterConfig)
+			orgs.GET("/:orgid/clusters/:id/kubeconfig", clusterWriteScope, orgAdmin, api.GetClusterKubeconfig)
+			orgs.GET("/:orgid/clusters/:id/sshkey", clusterScope, orgAdmin, api.GetClusterSSHKey)
+			orgs.POST("/:orgid/clusters/:id/sshkey/rotate", clusterScope, orgAdmin, api.RotateClusterSSHKey)
+			orgs.GET("/:orgid/clusters/:id/apiendpoint", clusterScope, api.GetApiEndpoint)
+			orgs.POST("/:orgid/clusters/:id/monitoring", clusterScope, api.UpdateMonitoring)
+			orgs.GET("/:orgid/clusters/:id/endpoints", clusterScope, api.ListEndpoints)
+			orgs.GET("/:orgid/clusters/:id/namespaces/:namespace/pods/:pod/exec", clusterWriteScope, orgMember, api.ExecPod)
+			orgs.GET("/:orgid/clusters/:id/namespaces/:namespace/pods/:pod/portforward", clusterWriteScope, orgMember, api.PortForwardPod)
+			orgs.GET("/:orgid/clusters/:id/podsessions", clusterScope, orgAdmin, api.ListPodSessions)
+			orgs.POST("/:orgid/clusters/:id/ci/repos", deploymentScope, api.CreateCIRepository)
+			orgs.GET("/:orgid/clusters/:id/podsessions/:sessionid", clusterScope, orgAdmin, api.GetPodSession)
+			orgs.GET("/:orgid/clusters/:id/deployments", deploymentScope, api.ListDeployments)
+			orgs.POST("/:orgid/clusters/:id/deployments", deploymentScope, api.Idempotent, api.CreateDeployment)
+			orgs.HEAD("/:orgid/clusters/:id/deployments", deploymentScope, api.GetTillerStatus)
+			orgs.DELETE("/:orgid/clusters/:id/deployments/:name", deploymentScope, api.DeleteDeployment)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name", deploymentScope, api.GetDeployment)
+			orgs.PUT("/:orgid/clusters/:id/deployments/:name", deploymentScope, api.Idempotent, api.UpgradeDeployment)
+			orgs.POST("/:orgid/clusters/:id/deployments/:name/rollback", deploymentScope, api.RollbackDeployment)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name/history", deploymentScope, api.GetDeploymentHistory)
+			orgs.HEAD("/:orgid/clusters/:id/deployments/:name", deploymentScope, api.HelmDeploymentStatus)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name/status", deploymentScope, api.HelmDeploymentStatus)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name/logs", deploymentScope, api.GetDeploymentLogs)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name/canary", deploymentScope, api.GetCanary)
+			orgs.POST("/:orgid/clusters/:id/deployments/:name/canary", deploymentScope, api.StartCanary)
+			orgs.POST("/:orgid/clusters/:id/deployments/:name/canary/promote", deploymentScope, api.PromoteCanary)
+			orgs.POST("/:orgid/clusters/:id/deployments/:name/canary/abort", deploymentScope, api.AbortCanary)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name/autoscaling", deploymentScope, api.ListAutoscalingPolicies)
+			orgs.PUT("/:orgid/clusters/:id/deployments/:name/autoscaling", deploymentScope, api.SetAutoscalingPolicy)
+			orgs.DELETE("/:orgid/clusters/:id/deployments/:name/autoscaling/:workload", deploymentScope, api.DeleteAutoscalingPolicy)
+			orgs.GET("/:orgid/clusters/:id/deployments/:name/recommendations", deploymentScope, api.GetDeploymentRecommendations)
+			orgs.GET("/:orgid/clusters/:id/autoscalingmetrics", clusterScope, api.GetAutoscalingMetrics)
+			orgs.GET("/:orgid/clusters/:id/addons", deploymentScope, api.ListAddons)
+			orgs.POST("/:orgid/clusters/:id/addons/:name", deploymentScope, api.InstallAddon)
+			orgs.POST("/:orgid/clusters/:id/addons/:name/upgrade", deploymentScope, api.UpgradeAddon)
+			orgs.DELETE("/:orgid/clusters/:id/addons/:name", deploymentScope, api.DeleteAddon)
+			orgs.GET("/:orgid/clusters/:id/logging", deploymentScope, api.GetLoggingOutput)
+			orgs.PUT("/:orgid/clusters/:id/logging", deploymentScope, api.SetLoggingOutput)
+			orgs.DELETE("/:orgid/clusters/:id/logging", deploymentScope, api.DeleteLoggingOutput)
+			orgs.GET("/:orgid/clusters/:id/backupbucket", clusterScope, api.GetBackupBucket)
+			orgs.PUT("/:orgid/clusters/:id/backupbucket", clusterScope, api.SetBackupBucket)
+			orgs.DELETE("/:orgid/clusters/:id/backupbucket", clusterScope, api.DeleteBackupBucket)
+			orgs.GET("/:orgid/clusters/:id/backups", clusterScope, api.ListBackups)
+			orgs.POST("/:orgid/clusters/:id/backups", clusterScope, api.CreateBackup)
+			orgs.GET("/:orgid/clusters/:id/backups/:name", clusterScope, api.GetBackup)
+			orgs.DELETE("/:orgid/clusters/:id/backups/:name", clusterScope, api.DeleteBackup)
+			orgs.POST("/:orgid/clusters/:id/backups/:name/restore", clusterScope, api.RestoreBackup)
+			orgs.GET("/:orgid/clusters/:id/restores", clusterScope, api.ListRestores)
+			orgs.GET("/:orgid/clusters/:id/restores/:name", clusterScope, api.GetRestore)
+			orgs.GET("/:orgid/clusters/:id/backupschedules", clusterScope, api.ListBackupSchedules)
+			orgs.POST("/:orgid/clusters/:id/backupschedules", clusterScope, api.SetBackupSchedule)
+			orgs.PUT("/:orgid/clusters/:id/backupschedules/:name", clusterScope, api.SetBackupSchedule)
+			orgs.DELETE("/:orgid/clusters/:id/backupschedules/:name", clusterScope, api.DeleteBackupSchedule)
+			orgs.GET("/:orgid/clusters/:id/etcdsnapshotpolicy", clusterScope, api.GetEtcdSnapshotPolicy)
+			orgs.PUT("/:orgid/clusters/:id/etcdsnapshotpolicy", clusterScope, api.SetEtcdSnapshotPolicy)
+			orgs.DELETE("/:orgid/clusters/:id/etcdsnapshotpolicy", clusterScope, api.DeleteEtcdSnapshotPolicy)
+			orgs.GET("/:orgid/clusters/:id/etcdsnapshots", clusterScope, api.ListEtcdSnapshots)
+			orgs.POST("/:orgid/clusters/:id/etcdsnapshots", clusterScope, api.CreateEtcdSnapshot)
+			orgs.GET("/:orgid/clusters/:id/etcdsnapshots/:name", clusterScope, api.GetEtcdSnapshot)
+			orgs.DELETE("/:orgid/clusters/:id/etcdsnapshots/:name", clusterScope, api.DeleteEtcdSnapshot)
+			orgs.POST("/:orgid/clusters/:id/etcdsnapshots/:name/restore", clusterScope, api.RestoreEtcdSnapshot)
+			orgs.GET("/:orgid/clusters/:id/databases", clusterScope, api.ListDatabases)
+			orgs.POST("/:orgid/clusters/:id/databases", clusterScope, api.CreateDatabase)
+			orgs.GET("/:orgid/clusters/:id/databases/:name", clusterScope, api.GetDatabase)
+			orgs.DELETE("/:orgid/clusters/:id/databases/:name", clusterScope, api.DeleteDatabase)
+			orgs.GET("/:orgid/clusters/:id/gitops", deploymentScope, api.ListGitOpsApps)
+			orgs.POST("/:orgid/clusters/:id/gitops", deploymentScope, api.CreateGitOpsApp)
+			orgs.GET("/:orgid/clusters/:id/gitops/:name", deploymentScope, api.GetGitOpsApp)
+			orgs.POST("/:orgid/clusters/:id/gitops/:name/sync", deploymentScope, api.SyncGitOpsApp)
+			orgs.DELETE("/:orgid/clusters/:id/gitops/:name", deploymentScope, api.DeleteGitOpsApp)
+			orgs.POST("/:orgid/clusters/:id/manifests", deploymentScope, api.ApplyManifests)
+			orgs.GET("/:orgid/clusters/:id/dns", clusterScope, api.GetClusterDNS)
+			orgs.GET("/:orgid/clusters/:id/monitoring", clusterScope, api.GetClusterMonitoring)
+			orgs.GET("/:orgid/clusters/:id/namespaces", clusterScope, api.ListNamespaces)
+			orgs.POST("/:orgid/clusters/:id/namespaces", clusterScope, api.CreateNamespace)
+			orgs.GET("/:orgid/clusters/:id/namespaces/:namespace", clusterScope, api.GetNamespace)
+			orgs.PUT("/:orgid/clusters/:id/namespaces/:namespace", clusterScope, api.UpdateNamespace)
+			orgs.DELETE("/:orgid/clusters/:id/namespaces/:namespace", clusterScope, api.DeleteNamespace)
+			orgs.GET("/:orgid/clusters/:id/namespaces/:namespace/pods/:pod/logs", clusterScope, api.StreamPodLogs)
+			orgs.GET("/:orgid/clusters/:id/certificates", clusterScope, api.ListCertificates)
+			orgs.POST("/:orgid/clusters/:id/certificates", clusterScope, api.RequestCertificate)
+			orgs.GET("/:orgid/clusters/:id/certificates/:namespace/:name", clusterScope, api.GetCertificate)
+			orgs.DELETE("/:orgid/clusters/:id/certificates/:namespace/:name", clusterScope, api.DeleteCertificate)
+			orgs.POST("/:orgid/clusters/:id/helminit", clusterScope, api.InitHelmOnCluster)
+			orgs.GET("/:orgid/clusters/:id/secrets", clusterScope, secretScope, api.ListClusterSecrets)
+			orgs.POST("/:orgid/clusters/:id/secrets", clusterScope, secretScope, api.InjectClusterSecret)
+			orgs.DELETE("/:orgid/clusters/:id/secrets/:name", clusterScope, secretScope, api.RemoveClusterSecret)
+			orgs.POST("/:orgid/clusters/:id/registries/:name/pullsecrets", clusterScope, secretScope, api.InjectImagePullSecrets)
+			orgs.GET("/:orgid/cloud/azure/versions", clusterScope, api.GetAKSVersions)
+			orgs.GET("/:orgid/alerting/rules", clusterScope, api.ListAlertRules)
+			orgs.POST("/:orgid/alerting/rules", clusterScope, api.CreateAlertRule)
+			orgs.GET("/:orgid/alerting/rules/:ruleid", clusterScope, api.GetAlertRule)
+			orgs.PUT("/:orgid/alerting/rules/:ruleid", clusterScope, api.UpdateAlertRule)
+			orgs.DELETE("/:orgid/alerting/rules/:ruleid", clusterScope, api.DeleteAlertRule)
+			orgs.GET("/:orgid/alerting/receivers", clusterScope, api.ListAlertReceivers)
+			orgs.POST("/:orgid/alerting/receivers", clusterScope, api.CreateAlertReceiver)
+			orgs.GET("/:orgid/alerting/receivers/:receiverid", clusterScope, api.GetAlertReceiver)
+			orgs.PUT("/:orgid/alerting/receivers/:receiverid", clusterScope, api.UpdateAlertReceiver)
+			orgs.DELETE("/:orgid/alerting/receivers/:receiverid", clusterScope, api.DeleteAlertReceiver)
+
+			orgs.GET("/:orgid/helm/repos", deploymentScope, api.ListHelmRepositories)
+			orgs.POST("/:orgid/helm/repos", deploymentScope, api.AddHelmRepository)
+			orgs.POST("/:orgid/helm/repos/:name/refresh", deploymentScope, api.RefreshHelmRepository)
+			orgs.DELETE("/:orgid/helm/repos/:name", deploymentScope, api.DeleteHelmRepository)
+			orgs.GET("/:orgid/registries", deploymentScope, api.ListContainerRegistries)
+			orgs.POST("/:orgid/registries", deploymentScope, api.AddContainerRegistry)
+			orgs.DELETE("/:orgid/registries/:name", deploymentScope, api.DeleteContainerRegistry)
+			orgs.POST("/:orgid/imagescans", deploymentScope, api.ScanImage)
+			orgs.GET("/:orgid/imagescan/policy", deploymentScope, api.GetImageScanPolicy)
+			orgs.PUT("/:orgid/imagescan/policy", deploymentScope, orgAdmin, api.SetImageScanPolicy)
+			orgs.GET("/:orgid/helm/charts", deploymentScope, api.SearchHelmCharts)
+
+			orgs.GET("/:orgid/profiles/cluster/:type", profileScope, api.GetClusterProfiles)
+			orgs.POST("/:orgid/profiles/cluster", profileScope, api.AddClusterProfile)
+			orgs.PUT("/:orgid/profiles/cluster", profileScope, api.UpdateClusterProfile)
+			orgs.DELETE("/:orgid/profiles/cluster/:type/:name", profileScope, api.DeleteClusterProfile)
+			orgs.GET("/:orgid/clusterprofiles", profileScope, api.ListOrganizationClusterProfiles)
+			orgs.POST("/:orgid/clusterprofiles", profileScope, api.CreateOrganizationClusterProfile)
+			orgs.GET("/:orgid/clusterprofiles/:name", profileScope, api.GetOrganizationClusterProfile)
+			orgs.PUT("/:orgid/clusterprofiles/:name", profileScope, api.UpdateOrganizationClusterProfile)
+			orgs.DELETE("/:orgid/clusterprofiles/:name", profileScope, api.DeleteOrganizationClusterProfile)
+			orgs.GET("/:orgid/secrets", secretScope, api.ListSecrets)
+			orgs.GET("/:orgid/secrets/:secretid", secretScope, api.GetSecret)
+			orgs.POST("/:orgid/secrets", secretScope, api.AddSecrets)
+			orgs.DELETE("/:orgid/secrets/:secretid", secretScope, api.DeleteSecrets)
+			orgs.PUT("/:orgid/secrets/:secretid", secretScope, api.UpdateSecret)
+			orgs.GET("/:orgid/secrets/:secretid/versions", secretScope, api.ListSecretVersions)
+			orgs.POST("/:orgid/secrets/:secretid/rollback", secretScope, api.RollbackSecret)
+			orgs.POST("/:orgid/secrets/:secretid/verify", secretScope, api.VerifySecret)
+			orgs.GET("/:orgid/secrets/:secretid/buckets", secretScope, api.ListAccountBuckets)
+			orgs.GET("/:orgid/buckets", secretScope, api.ListBuckets)
+			orgs.POST("/:orgid/buckets", secretScope, api.CreateBucket)
+			orgs.GET("/:orgid/buckets/:provider/:name", secretScope, api.GetBucket)
+			orgs.DELETE("/:orgid/buckets/:provider/:name", secretScope, api.DeleteBucket)
+			orgs.GET("/:orgid/secrets/:secretid/grants", secretScope, orgAdmin, api.ListSecretGrants)
+			orgs.POST("/:orgid/secrets/:secretid/grants", secretScope, orgAdmin, api.ShareSecret)
+			orgs.DELETE("/:orgid/secrets/:secretid/grants/:granteeid", secretScope, orgAdmin, api.RevokeSecretGrant)
+			orgs.GET("/:orgid/costs", organizationScope, clusterScope, api.GetOrganizationCosts)
+			orgs.GET("/:orgid/users", organizationScope, api.GetUsers)
+			orgs.GET("/:orgid/users/:id", organizationScope, api.GetUsers)
+			orgs.PUT("/:orgid/users/:id/role", organizationScope, orgAdmin, auth.SetMemberRole)
+			orgs.PUT("/:orgid/twofactor", organizationScope, orgAdmin, auth.SetOrganizationTwoFactor)
+			orgs.GET("/:orgid/teams", organizationScope, auth.GetTeams)
+			orgs.GET("/:orgid/teams/:teamid", organizationScope, auth.GetTeam)
+			orgs.POST("/:orgid/teams", organizationScope, orgAdmin, auth.CreateTeam)
+			orgs.PUT("/:orgid/teams/:teamid", organizationScope, orgAdmin, auth.UpdateTeam)
+			orgs.DELETE("/:orgid/teams/:teamid", organizationScope, orgAdmin, auth.DeleteTeam)
+			orgs.PUT("/:orgid/teams/:teamid/users/:userid", organizationScope, orgAdmin, auth.AddTeamMember)
+			orgs.DELETE("/:orgid/teams/:teamid/users/:userid", organizationScope, orgAdmin, auth.RemoveTeamMember)
+			orgs.GET("/:orgid/events", organizationScope, api.ListEvents)
+			orgs.GET("/:orgid/deployments", deploymentScope, api.ListDeploymentApprovals)
+			orgs.GET("/:orgid/deployments/:approvalid", deploymentScope, api.GetDeploymentApproval)
+			orgs.POST("/:orgid/deployments/:approvalid/approve", deploymentScope, api.ApproveDeployment)
+			orgs.POST("/:orgid/deployments/:approvalid/reject", deploymentScope, api.RejectDeployment)
+			orgs.GET("/:orgid/scheduledoperations", clusterScope, api.ListScheduledOperations)
+			orgs.GET("/:orgid/scheduledoperations/:operationid", clusterScope, api.GetScheduledOperation)
+			orgs.DELETE("/:orgid/scheduledoperations/:operationid", clusterScope, api.CancelScheduledOperation)
+			orgs.GET("/:orgid/ci/repos", deploymentScope, api.ListCIRepositories)
+			orgs.GET("/:orgid/ci/repos/:owner/:name/config", deploymentScope, api.GetCIRepositoryConfig)
+			orgs.DELETE("/:orgid/ci/repos/:owner/:name", deploymentScope, api.DeleteCIRepository)
+			orgs.GET("/:orgid/webhooks", organizationScope, orgAdmin, api.ListWebhooks)
+			orgs.POST("/:orgid/webhooks", organizationScope, orgAdmin, api.CreateWebhook)
+			orgs.GET("/:orgid/webhooks/:webhookid", organizationScope, orgAdmin, api.GetWebhook)
+			orgs.PUT("/:orgid/webhooks/:webhookid", organizationScope, orgAdmin, api.UpdateWebhook)
+			orgs.DELETE("/:orgid/webhooks/:webhookid", organizationScope, orgAdmin, api.DeleteWebhook)
+			orgs.POST("/:orgid/webhooks/:webhookid/ping", organizationScope, orgAdmin, api.PingWebhook)
+			orgs.GET("/:orgid/webhooks/:webhookid/deliveries", organizationScope, orgAdmin, api.ListWebhookDeliveries)
+			orgs.GET("/:orgid/webhooks/:webhookid/deliveries/:deliveryid", organizationScope, orgAdmin, api.GetWebhookDelivery)
+			orgs.POST("/:orgid/webhooks/:webhookid/deliveries/:deliveryid/redeliver", organizationScope, orgAdmin, api.RedeliverWebhookDelivery)
+			orgs.GET("/:orgid/policies", organizationScope, auth.GetPolicies)
+			orgs.GET("/:orgid/policies/:policyid", organizationScope, auth.GetPolicy)
+			orgs.POST("/:orgid/policies", organizationScope, orgAdmin, auth.CreatePolicy)
+			orgs.PUT("/:orgid/policies/:policyid", organizationScope, orgAdmin, auth.UpdatePolicy)
+			orgs.DELETE("/:orgid/policies/:policyid", organizationScope, orgAdmin, auth.DeletePolicy)
+			orgs.POST("/:orgid/serviceaccounts", organizationScope, auth.UserMiddleware, orgAdmin, auth.CreateServiceAccount)
+			orgs.GET("/:orgid/serviceaccounts", organizationScope, auth.UserMiddleware, orgAdmin, auth.GetServiceAccounts)
+			orgs.DELETE("/:orgid/serviceaccounts/:said", organizationScope, auth.UserMiddleware, orgAdmin, auth.DeleteServiceAccount)
+			orgs.POST("/:orgid/serviceaccounts/:said/tokens", tokenScope, auth.UserMiddleware, orgAdmin, auth.GenerateServiceAccountToken)
+			orgs.GET("/:orgid/serviceaccounts/:said/tokens", tokenScope, auth.UserMiddleware, orgAdmin, auth.GetServiceAccountTokens)
+			orgs.DELETE("/:orgid/serviceaccounts/:said/tokens/:id", tokenScope, auth.UserMiddleware, orgAdmin, auth.DeleteServiceAccountToken)
+
+			orgs.GET("/:orgid/allowed/secrets/", secretScope, api.ListAllowedSecretTypes)
+			orgs.GET("/:orgid/allowed/secrets/:type", secretScope, api.ListAllowedSecretTypes)
 		}
 		//v1.GET("/clusters/gke/:projectid/:zone/serverconf", cluster.GetGkeServerConfig) // todo think about it and move
-		v1.GET("/token", auth.GenerateToken)
-		v1.GET("/orgs", api.GetOrganizations)
-		v1.GET("/orgs/:orgid", api.GetOrganizations)
-		v1.POST("/orgs", api.CreateOrganization)
+		// Service accounts can access the organization routes above only
+		v1.GET("/token", tokenScope, auth.UserMiddleware, auth.GenerateToken)
+		v1.POST("/tokens", tokenScope, auth.UserMiddleware, auth.GenerateToken)
+		v1.GET("/tokens", tokenScope, auth.UserMiddleware, auth.GetTokens)
+		v1.DELETE("/tokens", tokenScope, auth.UserMiddleware, auth.DeleteTokens)
+		v1.DELETE("/tokens/:id", tokenScope, auth.UserMiddleware, auth.DeleteToken)
+		v1.POST("/tokens/:id/rotate", tokenScope, auth.UserMiddleware, auth.RotateToken)
+		v1.GET("/sessions", tokenScope, auth.UserMiddleware, auth.GetSessions)
+		v1.DELETE("/sessions", tokenScope, auth.UserMiddleware, auth.DeleteSessions)
+		v1.DELETE("/sessions/:id", tokenScope, auth.UserMiddleware, auth.DeleteSession)
+		v1.POST("/2fa/enroll", tokenScope, auth.UserMiddleware, auth.EnrollTwoFactor)
+		v1.DELETE("/2fa", tokenScope, auth.UserMiddleware, auth.DisableTwoFactor)
+		// Sessions waiting for the second factor have no scopes
+		v1.POST("/2fa/verify", auth.UserMiddleware, auth.VerifyTwoFactor)
+		v1.GET("/admin/tokens", tokenScope, auth.UserMiddleware, auth.AdminMiddleware, auth.GetAllTokens)
+		v1.POST("/admin/secrets/rewrap", secretScope, auth.UserMiddleware, auth.AdminMiddleware, api.RewrapSecrets)
+		v1.GET("/audit/tokens", tokenScope, auth.UserMiddleware, auth.GetTokenAuditEvents)
+		v1.GET("/orgs", organizationScope, auth.UserMiddleware, api.GetOrganizations)
+		v1.GET("/orgs/:orgid", organizationScope, auth.UserMiddleware, api.GetOrganizations)
+		v1.POST("/orgs", organizationScope, auth.UserMiddleware, api.CreateOrganization)
 	}
 
+	// Kubernetes probes: liveness needs the database only, readiness every dependency
+	healthChecks := api.DefaultHealthChecks()
+	router.GET("/healthz", api.HealthHandler(healthChecks, "database"))
+	router.GET("/readyz", api.HealthHandler(healthChecks))
+
 	router.GET("/api", api.MetaHandler(router, "/api"))
+	router.GET("/api/openapi.json", api.OpenAPIHandler(router, "/api/v1", Version))
+	router.GET("/metrics", gin.WrapH(prometheus.Handler()))
+
+	// the v2 routes are served by the handlers of the v1 routes with the v2 requests and responses
+	handler := apiversion.Handler(router, apiversion.V2)
+
+	// the gRPC API is served by the handlers of the REST API
+	if viper.GetBool("grpc.enabled") {
+		options, err := rpc.ServerOptions(viper.GetString("grpc.tls.certFile"), viper.GetString("grpc.tls.keyFile"), viper.GetBool("grpc.insecure"))
+		if err != nil {
+			logger.Panicf("Error configuring the gRPC API: %s", err.Error())
+		}
+		go func() {
+			if err := rpc.ListenAndServe(fmt.Sprintf(":%d", viper.GetInt("grpc.port")), handler, options...); err != nil {
+				logger.Errorf("Error serving the gRPC API: %s", err.Error())
+			}
+		}()
+	}
 
 	notify.SlackNotify("API is already running")
-	var listenPort string
+	listenPort := ":8080"
 	port := viper.GetInt("pipeline.listenport")
 	if port != 0 {
 		listenPort = fmt.Sprintf(":%d", port)
-		logger.Info("Pipeline API listening on port ", listenPort)
 	}
-	router.Run(listenPort)
+	logger.Info("Pipeline API listening on port ", listenPort)
+	if err := http.ListenAndServe(listenPort, handler); err != nil {
+		logger.Panicf("Error serving the API: %s", err.Error())
+	}
 }
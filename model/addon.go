@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+//AddonModel describes an add-on of the catalog installed on a cluster: the release of the add-on and the version
+//of its chart, the add-on can be upgraded to the version of the catalog
+type AddonModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index:idx_cluster_addon" json:"-"`
+	Name           string    `gorm:"unique_index:idx_cluster_addon" json:"name"`
+	ReleaseName    string    `json:"releaseName"`
+	Chart          string    `json:"chart"`
+	Version        string    `json:"version"`
+}
+
+// TableName sets AddonModel's table name
+func (AddonModel) TableName() string {
+	return "cluster_addons"
+}
+
+//GetAddon loads the installed add-on of the cluster, the error is gorm.ErrRecordNotFound if it isn't installed
+func GetAddon(clusterID uint, name string) (*AddonModel, error) {
+	var addon AddonModel
+	if err := GetDB().Where(AddonModel{ClusterModelID: clusterID, Name: name}).First(&addon).Error; err != nil {
+		return nil, err
+	}
+	return &addon, nil
+}
+
+//ListAddons loads the installed add-ons of the cluster
+func ListAddons(clusterID uint) ([]AddonModel, error) {
+	addons := []AddonModel{}
+	err := GetDB().Where(AddonModel{ClusterModelID: clusterID}).Order("name").Find(&addons).Error
+	return addons, err
+}
+
+//Save creates or replaces the installed add-on
+func (a *AddonModel) Save() error {
+	if a.ID == 0 {
+		current, err := GetAddon(a.ClusterModelID, a.Name)
+		if err == nil {
+			a.ID = current.ID
+			a.CreatedAt = current.CreatedAt
+		} else if !IsErrorGormNotFound(err) {
+			return err
+		}
+	}
+	return GetDB().Save(a).Error
+}
+
+//Delete the installed add-on from DB
+func (a *AddonModel) Delete() error {
+	return GetDB().Delete(a).Error
+}
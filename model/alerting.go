@@ -0,0 +1,170 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//AlertRuleModel describes a Prometheus alerting rule of an organization, the rules without a cluster are
+//deployed to every cluster of the organization
+type AlertRuleModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"index" json:"-"`
+	ClusterModelID uint      `json:"clusterId,omitempty"`
+	Name           string    `json:"name"`
+	Expr           string    `gorm:"type:text" json:"expr"`
+	For            string    `json:"for,omitempty"`
+	Severity       string    `json:"severity"`
+	Summary        string    `gorm:"type:text" json:"summary,omitempty"`
+	Description    string    `gorm:"type:text" json:"description,omitempty"`
+	// Labels is the JSON of the additional labels of the alerts
+	Labels string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets AlertRuleModel's table name
+func (AlertRuleModel) TableName() string {
+	return "alert_rules"
+}
+
+//GetLabels returns the additional labels of the alerts of the rule
+func (r *AlertRuleModel) GetLabels() map[string]string {
+	return jsonStringMap(r.Labels)
+}
+
+//SetLabels sets the additional labels of the alerts of the rule
+func (r *AlertRuleModel) SetLabels(labels map[string]string) {
+	r.Labels = stringMapJSON(labels)
+}
+
+//Save the alerting rule to DB
+func (r *AlertRuleModel) Save() error {
+	return GetDB().Save(r).Error
+}
+
+//Delete the alerting rule from DB
+func (r *AlertRuleModel) Delete() error {
+	return GetDB().Delete(r).Error
+}
+
+//AlertReceiverModel describes an Alertmanager receiver of an organization, the alerts matching the labels of
+//Matchers are routed to the receiver; the receivers without a cluster get the alerts of every cluster
+type AlertReceiverModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"unique_index:idx_organization_receiver" json:"-"`
+	ClusterModelID uint      `json:"clusterId,omitempty"`
+	Name           string    `gorm:"unique_index:idx_organization_receiver" json:"name"`
+	Type           string    `json:"type"`
+	// Settings is the JSON of the settings of the type of the receiver
+	Settings string `gorm:"type:text" json:"-"`
+	// Matchers is the JSON of the labels of the alerts of the receiver
+	Matchers string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets AlertReceiverModel's table name
+func (AlertReceiverModel) TableName() string {
+	return "alert_receivers"
+}
+
+//GetSettings returns the settings of the receiver
+func (r *AlertReceiverModel) GetSettings() map[string]string {
+	return jsonStringMap(r.Settings)
+}
+
+//SetSettings sets the settings of the receiver
+func (r *AlertReceiverModel) SetSettings(settings map[string]string) {
+	r.Settings = stringMapJSON(settings)
+}
+
+//GetMatchers returns the labels of the alerts of the receiver
+func (r *AlertReceiverModel) GetMatchers() map[string]string {
+	return jsonStringMap(r.Matchers)
+}
+
+//SetMatchers sets the labels of the alerts of the receiver
+func (r *AlertReceiverModel) SetMatchers(matchers map[string]string) {
+	r.Matchers = stringMapJSON(matchers)
+}
+
+//Save the receiver to DB
+func (r *AlertReceiverModel) Save() error {
+	return GetDB().Save(r).Error
+}
+
+//Delete the receiver from DB
+func (r *AlertReceiverModel) Delete() error {
+	return GetDB().Delete(r).Error
+}
+
+//GetAlertRule loads an alerting rule of the organization, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetAlertRule(organizationID, id uint) (*AlertRuleModel, error) {
+	var rule AlertRuleModel
+	if err := GetDB().Where(AlertRuleModel{ID: id, OrganizationID: organizationID}).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+//ListAlertRules loads the alerting rules of the organization, only the rules deployed to the cluster
+//if the cluster ID isn't 0
+func ListAlertRules(organizationID, clusterID uint) ([]AlertRuleModel, error) {
+	rules := []AlertRuleModel{}
+	db := GetDB().Where("organization_id = ?", organizationID)
+	if clusterID != 0 {
+		db = db.Where("cluster_model_id IN (?)", []uint{0, clusterID})
+	}
+	err := db.Order("name").Find(&rules).Error
+	return rules, err
+}
+
+//GetAlertReceiver loads a receiver of the organization, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetAlertReceiver(organizationID, id uint) (*AlertReceiverModel, error) {
+	var receiver AlertReceiverModel
+	if err := GetDB().Where(AlertReceiverModel{ID: id, OrganizationID: organizationID}).First(&receiver).Error; err != nil {
+		return nil, err
+	}
+	return &receiver, nil
+}
+
+//GetAlertReceiverByName loads the receiver of the organization with the name
+func GetAlertReceiverByName(organizationID uint, name string) (*AlertReceiverModel, error) {
+	var receiver AlertReceiverModel
+	if err := GetDB().Where(AlertReceiverModel{OrganizationID: organizationID, Name: name}).First(&receiver).Error; err != nil {
+		return nil, err
+	}
+	return &receiver, nil
+}
+
+//ListAlertReceivers loads the receivers of the organization, only the receivers of the alerts of the cluster
+//if the cluster ID isn't 0
+func ListAlertReceivers(organizationID, clusterID uint) ([]AlertReceiverModel, error) {
+	receivers := []AlertReceiverModel{}
+	db := GetDB().Where("organization_id = ?", organizationID)
+	if clusterID != 0 {
+		db = db.Where("cluster_model_id IN (?)", []uint{0, clusterID})
+	}
+	err := db.Order("name").Find(&receivers).Error
+	return receivers, err
+}
+
+func jsonStringMap(data string) map[string]string {
+	values := map[string]string{}
+	if data != "" {
+		json.Unmarshal([]byte(data), &values)
+	}
+	return values
+}
+
+func stringMapJSON(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
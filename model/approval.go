@@ -0,0 +1,90 @@
+package model
+
+import (
+	"time"
+)
+
+// Statuses of the deployment approvals
+const (
+	ApprovalPending  = "PENDING_APPROVAL"
+	ApprovalApproved = "APPROVED"
+	ApprovalRejected = "REJECTED"
+	ApprovalFailed   = "FAILED"
+)
+
+// Actions of the deployment approvals
+const (
+	ApprovalActionCreate   = "create"
+	ApprovalActionUpgrade  = "upgrade"
+	ApprovalActionRollback = "rollback"
+	ApprovalActionApply    = "apply"
+)
+
+//DeploymentApprovalModel is a deployment of a protected cluster waiting for or resolved by an approver, it's the
+//audit trail of the approval: who requested it, who resolved it, when and why
+type DeploymentApprovalModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	ClusterID      uint      `gorm:"index" json:"clusterId"`
+	Action         string    `json:"action"`
+	ReleaseName    string    `json:"releaseName,omitempty"`
+	Chart          string    `json:"chart"`
+	// Request is the JSON of the deployment request, it's run by the approver
+	Request     string `gorm:"type:text" json:"-"`
+	Status      string `gorm:"index" json:"status"`
+	RequesterID uint   `json:"-"`
+	RequestedBy string `json:"requestedBy"`
+	// ResolvedBy is the actor approving or rejecting the deployment, Comment is the reason given
+	ResolvedBy string     `json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Comment    string     `gorm:"type:text" json:"comment,omitempty"`
+	// Revision is the revision of the approved deployment, Error is the error of the failed one
+	Revision int32  `json:"revision,omitempty"`
+	Error    string `gorm:"type:text" json:"error,omitempty"`
+	// CommitRepository and CommitSHA are the commit of the CI build requesting the deployment
+	CommitRepository string `json:"commitRepository,omitempty"`
+	CommitSHA        string `json:"commitSha,omitempty"`
+}
+
+// TableName sets DeploymentApprovalModel's table name
+func (DeploymentApprovalModel) TableName() string {
+	return "deployment_approvals"
+}
+
+//ListDeploymentApprovals loads the deployment approvals of the organization, the newest first, filtered by
+//status and cluster if they aren't empty
+func ListDeploymentApprovals(organizationID uint, status string, clusterID uint) ([]DeploymentApprovalModel, error) {
+	approvals := []DeploymentApprovalModel{}
+	err := GetDB().Where(DeploymentApprovalModel{OrganizationID: organizationID, Status: status, ClusterID: clusterID}).
+		Order("id desc").Find(&approvals).Error
+	return approvals, err
+}
+
+//GetDeploymentApproval loads a deployment approval of the organization, the error is gorm.ErrRecordNotFound
+//if it doesn't exist
+func GetDeploymentApproval(organizationID, id uint) (*DeploymentApprovalModel, error) {
+	var approval DeploymentApprovalModel
+	if err := GetDB().Where(DeploymentApprovalModel{OrganizationID: organizationID, ID: id}).First(&approval).Error; err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+//Save the deployment approval to DB
+func (a *DeploymentApprovalModel) Save() error {
+	return GetDB().Save(a).Error
+}
+
+//Resolve approves or rejects the pending deployment, it returns false if it has been resolved already
+func (a *DeploymentApprovalModel) Resolve(status, actor, comment string) (bool, error) {
+	now := time.Now().UTC()
+	result := GetDB().Model(&DeploymentApprovalModel{}).Where("id = ? AND status = ?", a.ID, ApprovalPending).
+		Updates(map[string]interface{}{"status": status, "resolved_by": actor, "resolved_at": &now, "comment": comment})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	a.Status, a.ResolvedBy, a.ResolvedAt, a.Comment = status, actor, &now, comment
+	return true, nil
+}
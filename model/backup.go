@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+)
+
+//BackupBucketModel describes the object store bucket of the backups of a cluster: the Velero backups of the
+//cluster are stored under Prefix in Bucket of Provider with the credentials of SecretID; the clusters restoring
+//the backups of another cluster use its bucket ReadOnly
+type BackupBucketModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index" json:"clusterId"`
+	Provider       string    `json:"provider"`
+	Bucket         string    `json:"bucket"`
+	Prefix         string    `json:"prefix,omitempty"`
+	SecretID       string    `json:"secretId"`
+	ReadOnly       bool      `json:"readOnly"`
+	// Settings is the JSON of the settings of the provider of the bucket
+	Settings string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets BackupBucketModel's table name
+func (BackupBucketModel) TableName() string {
+	return "cluster_backup_buckets"
+}
+
+//GetSettings returns the settings of the provider of the bucket
+func (b *BackupBucketModel) GetSettings() map[string]string {
+	return jsonStringMap(b.Settings)
+}
+
+//SetSettings sets the settings of the provider of the bucket
+func (b *BackupBucketModel) SetSettings(settings map[string]string) {
+	b.Settings = stringMapJSON(settings)
+}
+
+//GetBackupBucket loads the backup bucket of the cluster, the error is gorm.ErrRecordNotFound if it has no bucket
+func GetBackupBucket(clusterID uint) (*BackupBucketModel, error) {
+	var bucket BackupBucketModel
+	if err := GetDB().Where(BackupBucketModel{ClusterModelID: clusterID}).First(&bucket).Error; err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+//Save the backup bucket to DB
+func (b *BackupBucketModel) Save() error {
+	return GetDB().Save(b).Error
+}
+
+//Delete the backup bucket from DB
+func (b *BackupBucketModel) Delete() error {
+	return GetDB().Delete(b).Error
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//CanaryModel describes a canary or blue-green release of a deployment: the release of the new chart and values
+//installed alongside the stable release, the share of the traffic of the ingress it gets and the Prometheus SLO
+//rolling it back when it's breached (the query value is above the threshold)
+type CanaryModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index:idx_cluster_canary" json:"-"`
+	ReleaseName    string    `gorm:"unique_index:idx_cluster_canary" json:"releaseName"`
+	CanaryRelease  string    `json:"canaryRelease"`
+	Strategy       string    `json:"strategy"`
+	Chart          string    `json:"chart"`
+	// Values is the JSON of the values of the canary release
+	Values        string  `gorm:"type:text" json:"-"`
+	Namespace     string  `json:"namespace"`
+	Ingress       string  `json:"ingress"`
+	StableService string  `json:"stableService"`
+	CanaryService string  `json:"canaryService"`
+	Weight        int     `json:"weight"`
+	Query         string  `gorm:"type:text" json:"query,omitempty"`
+	Threshold     float64 `json:"threshold,omitempty"`
+	Status        string  `json:"status"`
+	Message       string  `gorm:"type:text" json:"message,omitempty"`
+}
+
+// TableName sets CanaryModel's table name
+func (CanaryModel) TableName() string {
+	return "cluster_canaries"
+}
+
+//GetValues returns the values of the canary release
+func (c *CanaryModel) GetValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	if c.Values != "" {
+		json.Unmarshal([]byte(c.Values), &values)
+	}
+	return values
+}
+
+//SetValues sets the values of the canary release
+func (c *CanaryModel) SetValues(values map[string]interface{}) {
+	c.Values = ""
+	if len(values) > 0 {
+		if data, err := json.Marshal(values); err == nil {
+			c.Values = string(data)
+		}
+	}
+}
+
+//GetCanary loads the last canary of the release of the cluster, the error is gorm.ErrRecordNotFound
+//if the release had no canary
+func GetCanary(clusterID uint, releaseName string) (*CanaryModel, error) {
+	var canary CanaryModel
+	if err := GetDB().Where(CanaryModel{ClusterModelID: clusterID, ReleaseName: releaseName}).First(&canary).Error; err != nil {
+		return nil, err
+	}
+	return &canary, nil
+}
+
+//ListCanariesByStatus loads the canaries of every cluster in the status
+func ListCanariesByStatus(status string) ([]CanaryModel, error) {
+	canaries := []CanaryModel{}
+	err := GetDB().Where(CanaryModel{Status: status}).Find(&canaries).Error
+	return canaries, err
+}
+
+//Save creates or replaces the canary of the release
+func (c *CanaryModel) Save() error {
+	if c.ID == 0 {
+		current, err := GetCanary(c.ClusterModelID, c.ReleaseName)
+		if err == nil {
+			c.ID = current.ID
+			c.CreatedAt = current.CreatedAt
+		} else if !IsErrorGormNotFound(err) {
+			return err
+		}
+	}
+	return GetDB().Save(c).Error
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//CertificateModel describes a TLS certificate of a cluster issued by cert-manager into the secret of its namespace,
+//ExpiresAt is the expiry of the issued certificate checked last
+type CertificateModel struct {
+	ID             uint       `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	ClusterModelID uint       `gorm:"unique_index:idx_cluster_certificate" json:"-"`
+	Namespace      string     `gorm:"unique_index:idx_cluster_certificate" json:"namespace"`
+	Name           string     `gorm:"unique_index:idx_cluster_certificate" json:"name"`
+	SecretName     string     `json:"secretName"`
+	Status         string     `json:"status"`
+	Message        string     `gorm:"type:text" json:"message,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	CheckedAt      *time.Time `json:"checkedAt,omitempty"`
+	// DNSNames is the JSON of the DNS names of the certificate
+	DNSNames string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets CertificateModel's table name
+func (CertificateModel) TableName() string {
+	return "cluster_certificates"
+}
+
+//GetDNSNames returns the DNS names of the certificate
+func (c *CertificateModel) GetDNSNames() []string {
+	names := []string{}
+	if c.DNSNames != "" {
+		json.Unmarshal([]byte(c.DNSNames), &names)
+	}
+	return names
+}
+
+//SetDNSNames sets the DNS names of the certificate
+func (c *CertificateModel) SetDNSNames(names []string) {
+	c.DNSNames = ""
+	if len(names) > 0 {
+		if data, err := json.Marshal(names); err == nil {
+			c.DNSNames = string(data)
+		}
+	}
+}
+
+//GetCertificate loads the certificate of the namespace of the cluster, the error is gorm.ErrRecordNotFound
+//if it doesn't exist
+func GetCertificate(clusterID uint, namespace, name string) (*CertificateModel, error) {
+	var certificate CertificateModel
+	err := GetDB().Where(CertificateModel{ClusterModelID: clusterID, Namespace: namespace, Name: name}).First(&certificate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
+
+//ListCertificates loads the certificates of the cluster, the certificates of every cluster if the ID is 0
+func ListCertificates(clusterID uint) ([]CertificateModel, error) {
+	certificates := []CertificateModel{}
+	err := GetDB().Where(CertificateModel{ClusterModelID: clusterID}).Order("namespace, name").Find(&certificates).Error
+	return certificates, err
+}
+
+//Save the certificate to DB
+func (c *CertificateModel) Save() error {
+	return GetDB().Save(c).Error
+}
+
+//Delete the certificate from DB
+func (c *CertificateModel) Delete() error {
+	return GetDB().Delete(c).Error
+}
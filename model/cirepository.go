@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+)
+
+//CIRepositoryModel binds a source repository activated in the CI/CD flow to the cluster its builds deploy to,
+//UserID is the user whose SCM account has the webhook of the repository
+type CIRepositoryModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"unique_index:idx_ci_repository" json:"organizationId"`
+	Owner          string    `gorm:"unique_index:idx_ci_repository" json:"owner"`
+	Name           string    `gorm:"unique_index:idx_ci_repository" json:"name"`
+	ClusterID      uint      `gorm:"index" json:"clusterId"`
+	Branch         string    `json:"branch"`
+	Chart          string    `json:"chart,omitempty"`
+	UserID         uint      `json:"userId"`
+	DroneID        int64     `json:"droneId"`
+	Link           string    `json:"link,omitempty"`
+}
+
+// TableName sets CIRepositoryModel's table name
+func (CIRepositoryModel) TableName() string {
+	return "ci_repositories"
+}
+
+//FullName returns the owner/name of the repository
+func (r *CIRepositoryModel) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+//ListCIRepositories loads the repositories of the organization
+func ListCIRepositories(organizationID uint) ([]CIRepositoryModel, error) {
+	repositories := []CIRepositoryModel{}
+	err := GetDB().Where(CIRepositoryModel{OrganizationID: organizationID}).Order("owner, name").Find(&repositories).Error
+	return repositories, err
+}
+
+//GetCIRepository loads a repository of the organization, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetCIRepository(organizationID uint, owner, name string) (*CIRepositoryModel, error) {
+	var repository CIRepositoryModel
+	if err := GetDB().Where(CIRepositoryModel{OrganizationID: organizationID, Owner: owner, Name: name}).First(&repository).Error; err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+//Save the repository to DB
+func (r *CIRepositoryModel) Save() error {
+	return GetDB().Save(r).Error
+}
+
+//Delete the repository from DB
+func (r *CIRepositoryModel) Delete() error {
+	return GetDB().Delete(r).Error
+}
@@ -2,7 +2,9 @@ package model
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/banzaicloud/banzai-types/constants"
 	"github.com/jinzhu/gorm"
@@ -17,9 +19,47 @@ type ClusterModel struct {
 	Cloud            string
 	OrganizationId   uint
 	SecretId         string
-	Amazon           AmazonClusterModel
-	Azure            AzureClusterModel
-	Google           GoogleClusterModel
+	// KubeConfig is the envelope encrypted Kubernetes config of the cluster
+	KubeConfig string `gorm:"type:text"`
+	Amazon     AmazonClusterModel
+	Azure      AzureClusterModel
+	Google     GoogleClusterModel
+	EKS        EKSClusterModel
+	NodePools  []NodePoolModel `gorm:"foreignkey:ClusterModelID"`
+	// DeletionProtection must be disabled before the cluster can be deleted
+	DeletionProtection bool
+	// DeletionToken is the SHA-256 hash of the token confirming the deletion of the cluster
+	DeletionToken       string
+	DeletionTokenExpiry *time.Time
+	// Status is the phase of the cluster, StatusMessage is the last error
+	Status        string
+	StatusMessage string `gorm:"type:text"`
+	// Autoscaler marks the clusters with the cluster-autoscaler
+	Autoscaler bool
+	// SSHSecretID is the ID of the SSH secret with the key pair of the nodes
+	SSHSecretID string
+	// Network is the VPC, the GCP network or the Azure virtual network of the cluster and Subnets are the
+	// subnets of its nodes, the EKS subnets are the subnets of the EKS model
+	Network string
+	Subnets string
+	// PrivateEndpoint marks the clusters whose API server is only reachable from their network,
+	// MasterCIDR is the range of the control plane of the private GKE clusters
+	PrivateEndpoint bool
+	MasterCIDR      string
+	// Tags is the JSON of the tags of the cluster, they are the tags of its cloud resources and labels of its nodes
+	Tags string `gorm:"type:text"`
+	// DNSSecretID is the cloud secret of the zone of the DNS records of the cluster, DNSDomain is the domain of
+	// the organization in the zone; both of them are empty without DNS automation
+	DNSSecretID string
+	DNSDomain   string
+	// Monitoring marks the clusters with the Prometheus and Grafana monitoring stack, GrafanaSecretID is the
+	// password secret of the Grafana admin
+	Monitoring      bool
+	GrafanaSecretID string
+	// Protected marks the clusters whose deployments wait for an approval, DeploymentApprovers is the JSON of
+	// the logins of the approvers, the admins of the organization approve them if it's empty
+	Protected           bool
+	DeploymentApprovers string `gorm:"type:text"`
 }
 
 //AmazonClusterModel describes the amazon cluster model
@@ -40,6 +80,8 @@ type AzureClusterModel struct {
 	AgentCount        int
 	AgentName         string
 	KubernetesVersion string
+	// ResourceGroupCreated marks the resource groups created by Pipeline, they are deleted with the cluster
+	ResourceGroupCreated bool
 }
 
 //GoogleClusterModel describes the google cluster model
@@ -50,6 +92,23 @@ type GoogleClusterModel struct {
 	NodeVersion    string
 	NodeCount      int
 	ServiceAccount string
+	// ReleaseChannel is the GKE release channel of the cluster, empty if it isn't enrolled
+	ReleaseChannel string
+}
+
+//EKSClusterModel describes the EKS cluster model, the managed node groups are the node pools of the cluster
+type EKSClusterModel struct {
+	ClusterModelId  uint `gorm:"primary_key"`
+	Version         string
+	RoleArn         string
+	NodeRoleArn     string
+	Subnets         string
+	SecurityGroups  string
+	OIDCProviderArn string
+	// RolesCreated marks the IAM roles created by Pipeline, they are deleted with the cluster
+	RolesCreated bool
+	// AWSAuth is the JSON of the IAM roles and users mapped in the aws-auth ConfigMap
+	AWSAuth string `gorm:"type:text"`
 }
 
 //Save the cluster to DB
@@ -127,11 +186,68 @@ func QueryCluster(filter map[string]interface{}) (*ClusterModel, error) {
 	return &cluster, nil
 }
 
+//LoadNodePools loads the node pools of the cluster from the DB
+func (cs *ClusterModel) LoadNodePools() error {
+	return GetDB().Where(NodePoolModel{ClusterModelID: cs.ID}).Order("id").Find(&cs.NodePools).Error
+}
+
+// GetTags returns the tags of the cluster
+func (cs *ClusterModel) GetTags() map[string]string {
+	tags := map[string]string{}
+	if cs.Tags != "" {
+		json.Unmarshal([]byte(cs.Tags), &tags)
+	}
+	return tags
+}
+
+// SetTags sets the tags of the cluster
+func (cs *ClusterModel) SetTags(tags map[string]string) {
+	if len(tags) == 0 {
+		cs.Tags = ""
+		return
+	}
+	data, _ := json.Marshal(tags)
+	cs.Tags = string(data)
+}
+
+// GetDeploymentApprovers returns the logins of the approvers of the deployments of the cluster
+func (cs *ClusterModel) GetDeploymentApprovers() []string {
+	approvers := []string{}
+	if cs.DeploymentApprovers != "" {
+		json.Unmarshal([]byte(cs.DeploymentApprovers), &approvers)
+	}
+	return approvers
+}
+
+// SetDeploymentApprovers sets the logins of the approvers of the deployments of the cluster
+func (cs *ClusterModel) SetDeploymentApprovers(approvers []string) {
+	if len(approvers) == 0 {
+		cs.DeploymentApprovers = ""
+		return
+	}
+	data, _ := json.Marshal(approvers)
+	cs.DeploymentApprovers = string(data)
+}
+
+// NodeLabels returns the Kubernetes labels of the nodes of the pool, the tags of the cluster with the labels of the pool
+func (cs *ClusterModel) NodeLabels(pool NodePoolModel) map[string]string {
+	labels := cs.GetTags()
+	for key, value := range pool.GetLabels() {
+		labels[key] = value
+	}
+	return labels
+}
+
 //GetSimpleClusterWithId returns a simple cluster model
 func GetSimpleClusterWithId(id uint) ClusterModel {
 	return ClusterModel{Model: gorm.Model{ID: id}}
 }
 
+//TableName sets the EKSClusterModel's table name
+func (EKSClusterModel) TableName() string {
+	return "eks_cluster_properties"
+}
+
 //TableName sets the GoogleClusterModel's table name
 func (GoogleClusterModel) TableName() string {
 	return constants.TableNameGoogleProperties
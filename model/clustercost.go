@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//ClusterCostModel describes the daily spend of a node pool of a cluster, Date is the UTC day (2006-01-02).
+//The costs of the managed control plane have no node pool. The costs of the deleted clusters are kept.
+type ClusterCostModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"index" json:"-"`
+	ClusterModelID uint      `gorm:"unique_index:idx_cluster_cost_day" json:"clusterId"`
+	NodePool       string    `gorm:"unique_index:idx_cluster_cost_day" json:"nodePool"`
+	Date           string    `gorm:"unique_index:idx_cluster_cost_day" json:"date"`
+	ClusterName    string    `json:"clusterName"`
+	Cloud          string    `json:"cloud"`
+	InstanceType   string    `json:"instanceType,omitempty"`
+	InstanceHours  float64   `json:"instanceHours"`
+	Cost           float64   `json:"cost"`
+}
+
+// TableName sets ClusterCostModel's table name
+func (ClusterCostModel) TableName() string {
+	return "cluster_costs"
+}
+
+//AddClusterCost adds the instance hours and the cost to the daily spend of the node pool
+func AddClusterCost(cost ClusterCostModel) error {
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	key := ClusterCostModel{ClusterModelID: cost.ClusterModelID, NodePool: cost.NodePool, Date: cost.Date}
+	var daily ClusterCostModel
+	err := tx.Where(key).Attrs(ClusterCostModel{
+		OrganizationID: cost.OrganizationID,
+		ClusterName:    cost.ClusterName,
+		Cloud:          cost.Cloud,
+		InstanceType:   cost.InstanceType,
+	}).FirstOrCreate(&daily).Error
+	if err == nil {
+		err = tx.Model(&daily).UpdateColumns(map[string]interface{}{
+			"instance_hours": gorm.Expr("instance_hours + ?", cost.InstanceHours),
+			"cost":           gorm.Expr("cost + ?", cost.Cost),
+			"updated_at":     time.Now(),
+		}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+//QueryClusterCosts loads the daily spend matching the filter between the days from and to (both inclusive)
+func QueryClusterCosts(filter ClusterCostModel, from, to string) ([]ClusterCostModel, error) {
+	costs := []ClusterCostModel{}
+	err := GetDB().Where(filter).Where("date >= ? AND date <= ?", from, to).
+		Order("date, cluster_model_id, node_pool").Find(&costs).Error
+	return costs, err
+}
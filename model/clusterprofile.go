@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+//ClusterProfileModel describes a named cluster profile of an organization, Spec is the JSON of the create cluster
+//request without the cluster name. The clusters created with the profile get its spec with the fields of the
+//request on top of it.
+type ClusterProfileModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"unique_index:idx_cluster_profile_name" json:"-"`
+	Name           string    `gorm:"unique_index:idx_cluster_profile_name" json:"name"`
+	Cloud          string    `json:"cloud"`
+	Location       string    `json:"location"`
+	Spec           string    `gorm:"type:text" json:"-"`
+}
+
+// TableName sets ClusterProfileModel's table name
+func (ClusterProfileModel) TableName() string {
+	return "cluster_profiles"
+}
+
+//GetClusterProfile loads the cluster profile of the organization from the DB
+func GetClusterProfile(organizationID uint, name string) (*ClusterProfileModel, error) {
+	var profile ClusterProfileModel
+	err := GetDB().Where(ClusterProfileModel{OrganizationID: organizationID, Name: name}).First(&profile).Error
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+//ListClusterProfiles loads the cluster profiles of the organization from the DB
+func ListClusterProfiles(organizationID uint) ([]ClusterProfileModel, error) {
+	profiles := []ClusterProfileModel{}
+	err := GetDB().Where(ClusterProfileModel{OrganizationID: organizationID}).Order("name").Find(&profiles).Error
+	return profiles, err
+}
+
+//Save the cluster profile to DB
+func (profile *ClusterProfileModel) Save() error {
+	return GetDB().Save(profile).Error
+}
+
+//Delete the cluster profile from DB
+func (profile *ClusterProfileModel) Delete() error {
+	return GetDB().Delete(profile).Error
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+//ClusterStepModel describes a step of the current operation (create, update or delete) of a cluster
+type ClusterStepModel struct {
+	ID             uint       `gorm:"primary_key" json:"-"`
+	ClusterModelID uint       `gorm:"index" json:"-"`
+	Name           string     `json:"name"`
+	Status         string     `json:"status"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt      time.Time  `json:"startedAt"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+}
+
+// TableName sets ClusterStepModel's table name
+func (ClusterStepModel) TableName() string {
+	return "cluster_steps"
+}
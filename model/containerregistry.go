@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+)
+
+//ContainerRegistryModel describes an image registry of an organization, the secret holds the credentials
+//which pull its images
+type ContainerRegistryModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"unique_index:idx_org_container_registry" json:"-"`
+	Name           string    `gorm:"unique_index:idx_org_container_registry" json:"name"`
+	Host           string    `json:"host"`
+	SecretID       string    `json:"secretId"`
+}
+
+// TableName sets ContainerRegistryModel's table name
+func (ContainerRegistryModel) TableName() string {
+	return "container_registries"
+}
+
+//ListContainerRegistries loads the image registries of the organization
+func ListContainerRegistries(organizationID uint) ([]ContainerRegistryModel, error) {
+	registries := []ContainerRegistryModel{}
+	err := GetDB().Where(ContainerRegistryModel{OrganizationID: organizationID}).Order("name").Find(&registries).Error
+	return registries, err
+}
+
+//GetContainerRegistry loads the image registry of the organization, the error is gorm.ErrRecordNotFound
+//if the organization has no registry with the name
+func GetContainerRegistry(organizationID uint, name string) (*ContainerRegistryModel, error) {
+	var registry ContainerRegistryModel
+	if err := GetDB().Where(ContainerRegistryModel{OrganizationID: organizationID, Name: name}).First(&registry).Error; err != nil {
+		return nil, err
+	}
+	return &registry, nil
+}
+
+//GetContainerRegistryByHost loads the image registry of the organization on the host, the error is
+//gorm.ErrRecordNotFound if there is none
+func GetContainerRegistryByHost(organizationID uint, host string) (*ContainerRegistryModel, error) {
+	var registry ContainerRegistryModel
+	if err := GetDB().Where(ContainerRegistryModel{OrganizationID: organizationID, Host: host}).First(&registry).Error; err != nil {
+		return nil, err
+	}
+	return &registry, nil
+}
+
+//Save the image registry to DB
+func (r *ContainerRegistryModel) Save() error {
+	return GetDB().Save(r).Error
+}
+
+//Delete the image registry from DB
+func (r *ContainerRegistryModel) Delete() error {
+	return GetDB().Delete(r).Error
+}
@@ -1,9 +1,9 @@
 package model
 
 import (
+	"database/sql"
 	"sync"
 
-	"github.com/banzaicloud/bank-vaults/database"
 	"github.com/banzaicloud/pipeline/config"
 	"github.com/jinzhu/gorm"
 	// blank import is used here for simplicity
@@ -34,17 +34,19 @@ func ConnectDB(dbName string) *gorm.DB {
 	user := viper.GetString("database.user")
 	password := viper.GetString("database.password")
 	dataSource := "@tcp(" + host + ":" + port + ")/" + dbName + "?charset=utf8&parseTime=True&loc=Local"
+	var database *gorm.DB
+	var err error
 	if role != "" {
-		var err error
-		dataSource, err = database.DynamicSecretDataSource("mysql", role+dataSource)
+		var sqlDB *sql.DB
+		sqlDB, err = openDynamicSecretDB(role, dataSource)
 		if err != nil {
-			log.Error("Database dyanimc secret acquisition failed")
+			log.Error("Database dynamic secret acquisition failed")
 			panic(err.Error())
 		}
+		database, err = gorm.Open("mysql", sqlDB)
 	} else {
-		dataSource = user + ":" + password + dataSource
+		database, err = gorm.Open("mysql", user+":"+password+dataSource)
 	}
-	database, err := gorm.Open("mysql", dataSource)
 	if err != nil {
 		log.Error("Database connection failed")
 		panic(err.Error())
@@ -59,6 +61,12 @@ func GetDB() *gorm.DB {
 	return db
 }
 
+//SetDB replaces the database of the models, the configured one is not connected
+func SetDB(database *gorm.DB) {
+	dbOnce.Do(func() {})
+	db = database
+}
+
 //IsErrorGormNotFound returns gorm.ErrRecordNotFound
 func IsErrorGormNotFound(err error) bool {
 	return err == gorm.ErrRecordNotFound
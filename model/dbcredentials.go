@@ -0,0 +1,131 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/pipeline/vaultclient"
+	"github.com/go-sql-driver/mysql"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// dbCredentialsRetryInterval is the wait between the failed credential requests
+const dbCredentialsRetryInterval = 10 * time.Second
+
+// dynamicCredentials are the MySQL credentials of a role of Vault's database secrets engine,
+// the lease is renewed in the background and new credentials are requested when it can't be renewed anymore
+type dynamicCredentials struct {
+	sync.RWMutex
+	client   *vaultapi.Client
+	path     string
+	username string
+	password string
+}
+
+func newDynamicCredentials(client *vaultapi.Client, mountPath, role string) (*dynamicCredentials, *vaultapi.Secret, error) {
+	credentials := &dynamicCredentials{client: client, path: mountPath + "/creds/" + role}
+	secret, err := credentials.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	go credentials.renew(secret)
+	return credentials, secret, nil
+}
+
+// read requests new credentials from Vault
+func (credentials *dynamicCredentials) read() (*vaultapi.Secret, error) {
+	secret, err := credentials.client.Logical().Read(credentials.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read db credentials")
+	}
+	if secret == nil {
+		return nil, errors.New("failed to find '" + credentials.path + "' secret in vault")
+	}
+	username, _ := secret.Data["username"].(string)
+	password, _ := secret.Data["password"].(string)
+	credentials.Lock()
+	credentials.username, credentials.password = username, password
+	credentials.Unlock()
+	return secret, nil
+}
+
+func (credentials *dynamicCredentials) get() (string, string) {
+	credentials.RLock()
+	defer credentials.RUnlock()
+	return credentials.username, credentials.password
+}
+
+// renew keeps the lease of the credentials alive until its max TTL, then switches to new credentials
+func (credentials *dynamicCredentials) renew(secret *vaultapi.Secret) {
+	log := logger.WithFields(logrus.Fields{"action": "RenewDBCredentials"})
+	for {
+		lease := time.Duration(secret.LeaseDuration) * time.Second
+		renewer, err := credentials.client.NewRenewer(&vaultapi.RenewerInput{Secret: secret})
+		if err == nil {
+			go renewer.Renew()
+			err = <-renewer.DoneCh()
+		}
+		if err == vaultapi.ErrRenewerNotRenewable {
+			if lease == 0 {
+				// The credentials don't expire
+				return
+			}
+			time.Sleep(lease * 2 / 3)
+		} else if err != nil {
+			log.Warnf("Failed to renew database credentials: %s", err)
+		}
+
+		for {
+			next, err := credentials.read()
+			if err == nil {
+				log.Info("Database credentials refreshed")
+				secret = next
+				break
+			}
+			log.Errorf("Failed to refresh database credentials: %s", err)
+			time.Sleep(dbCredentialsRetryInterval)
+		}
+	}
+}
+
+// dynamicCredentialsConnector opens the new connections of the pool with the current credentials,
+// address is the data source without the credentials, eg.: "@tcp(localhost:3306)/pipelinedb"
+type dynamicCredentialsConnector struct {
+	credentials *dynamicCredentials
+	address     string
+}
+
+func (connector dynamicCredentialsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	username, password := connector.credentials.get()
+	return connector.Driver().Open(username + ":" + password + connector.address)
+}
+
+func (connector dynamicCredentialsConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+// openDynamicSecretDB opens a connection pool with the credentials of the Vault database role,
+// the connections are recycled before the credentials they were opened with expire
+func openDynamicSecretDB(role, address string) (*sql.DB, error) {
+	client, err := vaultclient.NewClient(role)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to establish vault connection")
+	}
+	credentials, secret, err := newDynamicCredentials(client.Vault(), viper.GetString("database.vault.mountpath"), role)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB := sql.OpenDB(dynamicCredentialsConnector{credentials: credentials, address: address})
+	maxLifetime := viper.GetDuration("database.vault.connmaxlifetime")
+	if lease := time.Duration(secret.LeaseDuration) * time.Second; lease > 0 && (maxLifetime == 0 || lease/2 < maxLifetime) {
+		maxLifetime = lease / 2
+	}
+	sqlDB.SetConnMaxLifetime(maxLifetime)
+	return sqlDB, nil
+}
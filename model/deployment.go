@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//DeploymentModel describes the desired state of a Helm release of a cluster: the chart of the repositories of
+//the cluster, its values and the revision deployed by Pipeline. The live release drifts if it's changed outside.
+type DeploymentModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index:idx_cluster_release" json:"-"`
+	ReleaseName    string    `gorm:"unique_index:idx_cluster_release" json:"releaseName"`
+	Chart          string    `json:"chart"`
+	Revision       int32     `json:"revision"`
+	Status         string    `json:"status"`
+	// Rollout is the state of the rollout of the workloads of the revision
+	Rollout        string `json:"rollout,omitempty"`
+	RolloutMessage string `gorm:"type:text" json:"rolloutMessage,omitempty"`
+	// Values is the JSON of the values of the release
+	Values string `gorm:"type:text" json:"-"`
+	// CommitRepository and CommitSHA are the owner/name repository and the commit of the CI build deploying
+	// the revision, the outcome of the rollout is reported to the commit
+	CommitRepository string `json:"commitRepository,omitempty"`
+	CommitSHA        string `json:"commitSha,omitempty"`
+}
+
+// TableName sets DeploymentModel's table name
+func (DeploymentModel) TableName() string {
+	return "cluster_deployments"
+}
+
+//GetValues returns the values of the release
+func (d *DeploymentModel) GetValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	if d.Values != "" {
+		json.Unmarshal([]byte(d.Values), &values)
+	}
+	return values
+}
+
+//SetValues sets the values of the release
+func (d *DeploymentModel) SetValues(values map[string]interface{}) {
+	d.Values = ""
+	if len(values) > 0 {
+		if data, err := json.Marshal(values); err == nil {
+			d.Values = string(data)
+		}
+	}
+}
+
+//GetDeployment loads the desired state of the release of the cluster, the error is gorm.ErrRecordNotFound
+//if the release wasn't deployed by Pipeline
+func GetDeployment(clusterID uint, releaseName string) (*DeploymentModel, error) {
+	var deployment DeploymentModel
+	if err := GetDB().Where(DeploymentModel{ClusterModelID: clusterID, ReleaseName: releaseName}).First(&deployment).Error; err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+//ListDeployments loads the desired states of the releases of the cluster
+func ListDeployments(clusterID uint) ([]DeploymentModel, error) {
+	deployments := []DeploymentModel{}
+	err := GetDB().Where(DeploymentModel{ClusterModelID: clusterID}).Order("release_name").Find(&deployments).Error
+	return deployments, err
+}
+
+//Save creates or replaces the desired state of the release
+func (d *DeploymentModel) Save() error {
+	if d.ID == 0 {
+		current, err := GetDeployment(d.ClusterModelID, d.ReleaseName)
+		if err == nil {
+			d.ID = current.ID
+			d.CreatedAt = current.CreatedAt
+		} else if !IsErrorGormNotFound(err) {
+			return err
+		}
+	}
+	return GetDB().Save(d).Error
+}
+
+//DeleteDeployment deletes the desired state of the release of the cluster
+func DeleteDeployment(clusterID uint, releaseName string) error {
+	return GetDB().Where(DeploymentModel{ClusterModelID: clusterID, ReleaseName: releaseName}).Delete(&DeploymentModel{}).Error
+}
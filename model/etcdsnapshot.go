@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+//EtcdSnapshotPolicyModel describes where and when the etcd of a cluster is saved: Schedule is a cron expression
+//in the time zone of the policy (UTC if it's empty), the snapshots are written to the S3 bucket with the Amazon
+//secret. At most Retention snapshots younger than MaxAgeDays are kept, zero is no limit.
+type EtcdSnapshotPolicyModel struct {
+	ID             uint       `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	ClusterModelID uint       `gorm:"unique_index" json:"clusterId"`
+	Schedule       string     `json:"schedule"`
+	TimeZone       string     `json:"timeZone,omitempty"`
+	Bucket         string     `json:"bucket"`
+	Region         string     `json:"region"`
+	Prefix         string     `json:"prefix,omitempty"`
+	SecretID       string     `json:"secretId"`
+	Retention      int        `json:"retention"`
+	MaxAgeDays     int        `json:"maxAgeDays"`
+	LastSnapshotAt *time.Time `json:"lastSnapshotAt,omitempty"`
+	LastError      string     `gorm:"type:text" json:"lastError,omitempty"`
+}
+
+// TableName sets EtcdSnapshotPolicyModel's table name
+func (EtcdSnapshotPolicyModel) TableName() string {
+	return "cluster_etcd_snapshot_policies"
+}
+
+//GetEtcdSnapshotPolicy loads the etcd snapshot policy of the cluster, the error is gorm.ErrRecordNotFound
+//if the cluster has no policy
+func GetEtcdSnapshotPolicy(clusterID uint) (*EtcdSnapshotPolicyModel, error) {
+	var policy EtcdSnapshotPolicyModel
+	if err := GetDB().Where(EtcdSnapshotPolicyModel{ClusterModelID: clusterID}).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+//SaveEtcdSnapshotPolicy creates or replaces the etcd snapshot policy of its cluster, the result of the last
+//snapshot is kept
+func SaveEtcdSnapshotPolicy(policy *EtcdSnapshotPolicyModel) error {
+	current, err := GetEtcdSnapshotPolicy(policy.ClusterModelID)
+	if err == nil {
+		policy.ID = current.ID
+		policy.CreatedAt = current.CreatedAt
+		policy.LastSnapshotAt = current.LastSnapshotAt
+		policy.LastError = current.LastError
+	} else if !IsErrorGormNotFound(err) {
+		return err
+	}
+	return GetDB().Save(policy).Error
+}
+
+//SetEtcdSnapshotResult records the time and the error of the last snapshot of the cluster
+func SetEtcdSnapshotResult(clusterID uint, t time.Time, message string) error {
+	return GetDB().Model(&EtcdSnapshotPolicyModel{}).Where(EtcdSnapshotPolicyModel{ClusterModelID: clusterID}).
+		Updates(map[string]interface{}{"last_snapshot_at": t, "last_error": message}).Error
+}
+
+//DeleteEtcdSnapshotPolicy deletes the etcd snapshot policy of the cluster
+func DeleteEtcdSnapshotPolicy(clusterID uint) error {
+	return GetDB().Where(EtcdSnapshotPolicyModel{ClusterModelID: clusterID}).Delete(&EtcdSnapshotPolicyModel{}).Error
+}
+
+//ListEtcdSnapshotPolicies loads the etcd snapshot policies of every cluster
+func ListEtcdSnapshotPolicies() ([]EtcdSnapshotPolicyModel, error) {
+	var policies []EtcdSnapshotPolicyModel
+	err := GetDB().Find(&policies).Error
+	return policies, err
+}
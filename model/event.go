@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/banzaicloud/pipeline/pagination"
+)
+
+//EventModel is an entry of the activity stream of an organization, Actor is the user or the service account
+//of the action and ClusterID is the cluster of the resource if it belongs to a cluster
+type EventModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	Time           time.Time `gorm:"index" json:"time"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	Type           string    `gorm:"size:64;index" json:"type"`
+	Actor          string    `gorm:"size:64" json:"actor,omitempty"`
+	ClusterID      uint      `gorm:"index" json:"clusterId,omitempty"`
+	Resource       string    `json:"resource,omitempty"`
+	Message        string    `gorm:"type:text" json:"message,omitempty"`
+}
+
+// TableName sets EventModel's table name
+func (EventModel) TableName() string {
+	return "organization_events"
+}
+
+//Save the event to DB
+func (e *EventModel) Save() error {
+	return GetDB().Save(e).Error
+}
+
+//EventFilter selects the events of an organization, the empty fields match every event
+type EventFilter struct {
+	Types     []string
+	ClusterID uint
+	Since     time.Time
+	Until     time.Time
+	Page      int
+	Limit     int
+}
+
+//ListEvents loads a page of the events of the organization matching the filter and the number of the matching
+//events, the page is selected by the cursor of the pagination parameters or by the page number of the filter
+func ListEvents(organizationID uint, filter EventFilter, page *pagination.Params) ([]EventModel, int, error) {
+	query := GetDB().Model(&EventModel{}).Where("organization_id = ?", organizationID)
+	if len(filter.Types) != 0 {
+		query = query.Where("type IN (?)", filter.Types)
+	}
+	if filter.ClusterID != 0 {
+		query = query.Where("cluster_id = ?", filter.ClusterID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("time >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("time < ?", filter.Until)
+	}
+	query = page.Filter(query)
+	var total int
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	query = page.Page(query)
+	if !page.HasCursor() {
+		query = query.Offset((filter.Page - 1) * filter.Limit)
+	}
+	events := []EventModel{}
+	err := query.Find(&events).Error
+	return events, total, err
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//GitOpsAppModel describes an application of a cluster synced from a Git repository: the manifests of the path of
+//the branch, or the Helm values file of the path deployed with the chart. SecretID is the password secret of the
+//repository (empty if it's public). Revision is the commit synced last.
+type GitOpsAppModel struct {
+	ID             uint       `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	ClusterModelID uint       `gorm:"unique_index:idx_cluster_gitops_app" json:"-"`
+	Name           string     `gorm:"unique_index:idx_cluster_gitops_app" json:"name"`
+	RepositoryURL  string     `json:"repositoryUrl"`
+	Branch         string     `json:"branch"`
+	Path           string     `json:"path"`
+	SecretID       string     `json:"secretId,omitempty"`
+	Kind           string     `json:"kind"`
+	Chart          string     `json:"chart,omitempty"`
+	AutoSync       bool       `json:"autoSync"`
+	Revision       string     `json:"revision,omitempty"`
+	SyncStatus     string     `json:"syncStatus"`
+	Message        string     `gorm:"type:text" json:"message,omitempty"`
+	SyncedAt       *time.Time `json:"syncedAt,omitempty"`
+	CheckedAt      *time.Time `json:"checkedAt,omitempty"`
+	// Drift is the JSON of the differences of the cluster from the repository
+	Drift string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets GitOpsAppModel's table name
+func (GitOpsAppModel) TableName() string {
+	return "cluster_gitops_apps"
+}
+
+//GetDrift returns the differences of the cluster from the repository
+func (a *GitOpsAppModel) GetDrift() []string {
+	drift := []string{}
+	if a.Drift != "" {
+		json.Unmarshal([]byte(a.Drift), &drift)
+	}
+	return drift
+}
+
+//SetDrift sets the differences of the cluster from the repository
+func (a *GitOpsAppModel) SetDrift(drift []string) {
+	a.Drift = ""
+	if len(drift) > 0 {
+		if data, err := json.Marshal(drift); err == nil {
+			a.Drift = string(data)
+		}
+	}
+}
+
+//GetGitOpsApp loads the application of the cluster, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetGitOpsApp(clusterID uint, name string) (*GitOpsAppModel, error) {
+	var app GitOpsAppModel
+	if err := GetDB().Where(GitOpsAppModel{ClusterModelID: clusterID, Name: name}).First(&app).Error; err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+//ListGitOpsApps loads the applications of the cluster, the applications of every cluster if the ID is 0
+func ListGitOpsApps(clusterID uint) ([]GitOpsAppModel, error) {
+	apps := []GitOpsAppModel{}
+	err := GetDB().Where(GitOpsAppModel{ClusterModelID: clusterID}).Order("name").Find(&apps).Error
+	return apps, err
+}
+
+//Save the application to DB
+func (a *GitOpsAppModel) Save() error {
+	return GetDB().Save(a).Error
+}
+
+//Delete the application from DB
+func (a *GitOpsAppModel) Delete() error {
+	return GetDB().Delete(a).Error
+}
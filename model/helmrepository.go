@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+//HelmRepositoryModel describes a chart repository of an organization, SecretID is the password secret of the
+//basic auth of the repository (empty if it's public). The index of the repository is cached and refreshed.
+type HelmRepositoryModel struct {
+	ID             uint       `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	OrganizationID uint       `gorm:"unique_index:idx_org_helm_repository" json:"-"`
+	Name           string     `gorm:"unique_index:idx_org_helm_repository" json:"name"`
+	URL            string     `json:"url"`
+	SecretID       string     `json:"secretId,omitempty"`
+	IndexUpdatedAt *time.Time `json:"indexUpdatedAt,omitempty"`
+	IndexError     string     `gorm:"type:text" json:"indexError,omitempty"`
+}
+
+// TableName sets HelmRepositoryModel's table name
+func (HelmRepositoryModel) TableName() string {
+	return "helm_repositories"
+}
+
+//ListHelmRepositories loads the chart repositories of the organization, every repository if the ID is 0
+func ListHelmRepositories(organizationID uint) ([]HelmRepositoryModel, error) {
+	repositories := []HelmRepositoryModel{}
+	err := GetDB().Where(HelmRepositoryModel{OrganizationID: organizationID}).Order("name").Find(&repositories).Error
+	return repositories, err
+}
+
+//GetHelmRepository loads the chart repository of the organization, the error is gorm.ErrRecordNotFound
+//if the organization has no repository with the name
+func GetHelmRepository(organizationID uint, name string) (*HelmRepositoryModel, error) {
+	var repository HelmRepositoryModel
+	if err := GetDB().Where(HelmRepositoryModel{OrganizationID: organizationID, Name: name}).First(&repository).Error; err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+//Save the chart repository to DB
+func (r *HelmRepositoryModel) Save() error {
+	return GetDB().Save(r).Error
+}
+
+//Delete the chart repository from DB
+func (r *HelmRepositoryModel) Delete() error {
+	return GetDB().Delete(r).Error
+}
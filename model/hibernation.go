@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+//HibernationScheduleModel describes when a cluster is suspended and resumed, Suspend and Resume are cron
+//expressions in the time zone of the schedule (UTC if it's empty)
+type HibernationScheduleModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index" json:"clusterId"`
+	Suspend        string    `json:"suspend"`
+	Resume         string    `json:"resume"`
+	TimeZone       string    `json:"timeZone,omitempty"`
+}
+
+// TableName sets HibernationScheduleModel's table name
+func (HibernationScheduleModel) TableName() string {
+	return "cluster_hibernation_schedules"
+}
+
+//GetHibernationSchedule loads the hibernation schedule of the cluster, the error is gorm.ErrRecordNotFound
+//if the cluster has no schedule
+func GetHibernationSchedule(clusterID uint) (*HibernationScheduleModel, error) {
+	var schedule HibernationScheduleModel
+	if err := GetDB().Where(HibernationScheduleModel{ClusterModelID: clusterID}).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+//SaveHibernationSchedule creates or replaces the hibernation schedule of its cluster
+func SaveHibernationSchedule(schedule *HibernationScheduleModel) error {
+	current, err := GetHibernationSchedule(schedule.ClusterModelID)
+	if err == nil {
+		schedule.ID = current.ID
+		schedule.CreatedAt = current.CreatedAt
+	} else if !IsErrorGormNotFound(err) {
+		return err
+	}
+	return GetDB().Save(schedule).Error
+}
+
+//DeleteHibernationSchedule deletes the hibernation schedule of the cluster
+func DeleteHibernationSchedule(clusterID uint) error {
+	return GetDB().Where(HibernationScheduleModel{ClusterModelID: clusterID}).Delete(&HibernationScheduleModel{}).Error
+}
+
+//ListHibernationSchedules loads the hibernation schedules of every cluster
+func ListHibernationSchedules() ([]HibernationScheduleModel, error) {
+	var schedules []HibernationScheduleModel
+	err := GetDB().Find(&schedules).Error
+	return schedules, err
+}
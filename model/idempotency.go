@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+)
+
+//IdempotencyKeyModel is a request of a caller (a user or a service account) of an organization with an
+//Idempotency-Key header: the fingerprint of the request and its response, StatusCode is zero while the request
+//is in progress
+type IdempotencyKeyModel struct {
+	ID             uint      `gorm:"primary_key"`
+	CreatedAt      time.Time `gorm:"index"`
+	OrganizationID uint      `gorm:"unique_index:idx_idempotency_key"`
+	Caller         string    `gorm:"size:64;unique_index:idx_idempotency_key"`
+	Key            string    `gorm:"size:255;unique_index:idx_idempotency_key"`
+	Fingerprint    string    `gorm:"size:64"`
+	StatusCode     int
+	ContentType    string
+	Body           string `gorm:"type:text"`
+}
+
+// TableName sets IdempotencyKeyModel's table name
+func (IdempotencyKeyModel) TableName() string {
+	return "idempotency_keys"
+}
+
+//GetIdempotencyKey loads the request of the idempotency key of the caller of the organization
+func GetIdempotencyKey(organizationID uint, caller, key string) (*IdempotencyKeyModel, error) {
+	var idempotencyKey IdempotencyKeyModel
+	err := GetDB().Where(map[string]interface{}{"organization_id": organizationID, "caller": caller, "key": key}).First(&idempotencyKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+//CreateIdempotencyKey saves the request of a new idempotency key, it fails if the key is already saved
+func CreateIdempotencyKey(idempotencyKey *IdempotencyKeyModel) error {
+	return GetDB().Create(idempotencyKey).Error
+}
+
+//SaveIdempotencyResponse saves the response of the request of the idempotency key
+func SaveIdempotencyResponse(idempotencyKey *IdempotencyKeyModel) error {
+	return GetDB().Model(idempotencyKey).UpdateColumns(map[string]interface{}{
+		"status_code":  idempotencyKey.StatusCode,
+		"content_type": idempotencyKey.ContentType,
+		"body":         idempotencyKey.Body,
+	}).Error
+}
+
+//DeleteIdempotencyKey deletes the idempotency key, the next request with the key is run again
+func DeleteIdempotencyKey(idempotencyKey *IdempotencyKeyModel) error {
+	return GetDB().Delete(idempotencyKey).Error
+}
+
+//DeleteExpiredIdempotencyKeys deletes the idempotency keys of the organization created before the time
+func DeleteExpiredIdempotencyKeys(organizationID uint, before time.Time) error {
+	return GetDB().Where("organization_id = ? AND created_at < ?", organizationID, before).Delete(&IdempotencyKeyModel{}).Error
+}
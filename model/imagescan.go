@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//ImageScanPolicyModel is the image scanning policy of an organization, the images of its deployments can't have
+//vulnerabilities of the severity or above, except the ignored ones
+type ImageScanPolicyModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"unique_index" json:"-"`
+	Enabled        bool      `json:"enabled"`
+	Severity       string    `json:"severity"`
+	// IgnoreUnfixed ignores the vulnerabilities without a fixed version of their package
+	IgnoreUnfixed bool `json:"ignoreUnfixed"`
+	// Ignored is the JSON of the IDs of the accepted vulnerabilities
+	Ignored string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets ImageScanPolicyModel's table name
+func (ImageScanPolicyModel) TableName() string {
+	return "image_scan_policies"
+}
+
+//GetIgnored returns the IDs of the accepted vulnerabilities
+func (p *ImageScanPolicyModel) GetIgnored() []string {
+	ignored := []string{}
+	if p.Ignored != "" {
+		json.Unmarshal([]byte(p.Ignored), &ignored)
+	}
+	return ignored
+}
+
+//SetIgnored sets the IDs of the accepted vulnerabilities
+func (p *ImageScanPolicyModel) SetIgnored(ignored []string) {
+	p.Ignored = ""
+	if len(ignored) > 0 {
+		if data, err := json.Marshal(ignored); err == nil {
+			p.Ignored = string(data)
+		}
+	}
+}
+
+//GetImageScanPolicy loads the image scanning policy of the organization, the error is gorm.ErrRecordNotFound
+//if it has none
+func GetImageScanPolicy(organizationID uint) (*ImageScanPolicyModel, error) {
+	var policy ImageScanPolicyModel
+	if err := GetDB().Where(ImageScanPolicyModel{OrganizationID: organizationID}).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+//Save the image scanning policy to DB
+func (p *ImageScanPolicyModel) Save() error {
+	return GetDB().Save(p).Error
+}
+
+//Delete the image scanning policy from DB
+func (p *ImageScanPolicyModel) Delete() error {
+	return GetDB().Delete(p).Error
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+)
+
+//LoggingOutputModel describes the output of the logs collected by the logging add-on of a cluster, SecretID is
+//the secret of the credentials of the output
+type LoggingOutputModel struct {
+	ID             uint `gorm:"primary_key"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ClusterModelID uint `gorm:"unique_index"`
+	Type           string
+	SecretID       string
+	// Settings is the JSON of the settings of the type of the output
+	Settings string `gorm:"type:text"`
+}
+
+// TableName sets LoggingOutputModel's table name
+func (LoggingOutputModel) TableName() string {
+	return "cluster_logging_outputs"
+}
+
+//GetSettings returns the settings of the output
+func (o *LoggingOutputModel) GetSettings() map[string]string {
+	return jsonStringMap(o.Settings)
+}
+
+//SetSettings sets the settings of the output
+func (o *LoggingOutputModel) SetSettings(settings map[string]string) {
+	o.Settings = stringMapJSON(settings)
+}
+
+//GetLoggingOutput loads the logging output of the cluster, the error is gorm.ErrRecordNotFound if it has no output
+func GetLoggingOutput(clusterID uint) (*LoggingOutputModel, error) {
+	var output LoggingOutputModel
+	if err := GetDB().Where(LoggingOutputModel{ClusterModelID: clusterID}).First(&output).Error; err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+//Save the logging output to DB
+func (o *LoggingOutputModel) Save() error {
+	return GetDB().Save(o).Error
+}
+
+//Delete the logging output from DB
+func (o *LoggingOutputModel) Delete() error {
+	return GetDB().Delete(o).Error
+}
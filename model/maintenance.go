@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+//MaintenanceWindowModel describes when the disruptive operations of a cluster may run: the windows open at
+//the Start cron expression in the time zone of the window (UTC if it's empty) and last for Duration; the
+//deployments and upgrades of the cluster are queued for its next window if it's Enforced
+type MaintenanceWindowModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index" json:"clusterId"`
+	Start          string    `json:"start"`
+	Duration       string    `json:"duration"`
+	TimeZone       string    `json:"timeZone,omitempty"`
+	Enforced       bool      `json:"enforced"`
+}
+
+// TableName sets MaintenanceWindowModel's table name
+func (MaintenanceWindowModel) TableName() string {
+	return "cluster_maintenance_windows"
+}
+
+//GetMaintenanceWindow loads the maintenance window of the cluster, the error is gorm.ErrRecordNotFound
+//if the cluster has no window
+func GetMaintenanceWindow(clusterID uint) (*MaintenanceWindowModel, error) {
+	var window MaintenanceWindowModel
+	if err := GetDB().Where(MaintenanceWindowModel{ClusterModelID: clusterID}).First(&window).Error; err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+//SaveMaintenanceWindow creates or replaces the maintenance window of its cluster
+func SaveMaintenanceWindow(window *MaintenanceWindowModel) error {
+	current, err := GetMaintenanceWindow(window.ClusterModelID)
+	if err == nil {
+		window.ID = current.ID
+		window.CreatedAt = current.CreatedAt
+	} else if !IsErrorGormNotFound(err) {
+		return err
+	}
+	return GetDB().Save(window).Error
+}
+
+//DeleteMaintenanceWindow deletes the maintenance window of the cluster
+func DeleteMaintenanceWindow(clusterID uint) error {
+	return GetDB().Where(MaintenanceWindowModel{ClusterModelID: clusterID}).Delete(&MaintenanceWindowModel{}).Error
+}
@@ -0,0 +1,80 @@
+package model
+
+import "time"
+
+//ManagedDatabaseModel is a database provisioned by Pipeline for the applications of a cluster: a managed
+//database of Provider (created with the cloud secret CloudSecretID) or a database release in the cluster.
+//The connection details are kept in the password secret SecretID, it's injected as SecretName into Namespace
+//once the database is ready.
+type ManagedDatabaseModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `json:"organizationId"`
+	ClusterID      uint      `gorm:"unique_index:idx_cluster_database" json:"clusterId"`
+	Name           string    `gorm:"unique_index:idx_cluster_database" json:"name"`
+	// Identifier is the name of the instance at the provider, or the release name in the cluster
+	Identifier    string `json:"identifier"`
+	Engine        string `json:"engine"`
+	Provider      string `json:"provider"`
+	Version       string `json:"version,omitempty"`
+	InstanceType  string `json:"instanceType,omitempty"`
+	StorageGB     int    `json:"storageGb"`
+	CloudSecretID string `json:"cloudSecretId,omitempty"`
+	// Settings is the JSON of the settings of the provider of the database
+	Settings      string `gorm:"type:text" json:"-"`
+	Namespace     string `json:"namespace"`
+	SecretName    string `json:"secretName"`
+	SecretID      string `json:"secretId"`
+	Status        string `json:"status"`
+	StatusMessage string `gorm:"type:text" json:"statusMessage,omitempty"`
+	CreatedBy     string `json:"createdBy,omitempty"`
+}
+
+// TableName sets ManagedDatabaseModel's table name
+func (ManagedDatabaseModel) TableName() string {
+	return "managed_databases"
+}
+
+//GetSettings returns the settings of the provider of the database
+func (d *ManagedDatabaseModel) GetSettings() map[string]string {
+	return jsonStringMap(d.Settings)
+}
+
+//SetSettings sets the settings of the provider of the database
+func (d *ManagedDatabaseModel) SetSettings(settings map[string]string) {
+	d.Settings = stringMapJSON(settings)
+}
+
+//ListManagedDatabases loads the databases of the cluster
+func ListManagedDatabases(clusterID uint) ([]ManagedDatabaseModel, error) {
+	var databases []ManagedDatabaseModel
+	err := GetDB().Where(ManagedDatabaseModel{ClusterID: clusterID}).Order("name").Find(&databases).Error
+	return databases, err
+}
+
+//ListManagedDatabasesInStatus loads the databases of every cluster in the status
+func ListManagedDatabasesInStatus(status string) ([]ManagedDatabaseModel, error) {
+	var databases []ManagedDatabaseModel
+	err := GetDB().Where(ManagedDatabaseModel{Status: status}).Find(&databases).Error
+	return databases, err
+}
+
+//GetManagedDatabase loads the database of the cluster, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetManagedDatabase(clusterID uint, name string) (*ManagedDatabaseModel, error) {
+	var database ManagedDatabaseModel
+	if err := GetDB().Where(ManagedDatabaseModel{ClusterID: clusterID, Name: name}).First(&database).Error; err != nil {
+		return nil, err
+	}
+	return &database, nil
+}
+
+//Save the database to DB
+func (d *ManagedDatabaseModel) Save() error {
+	return GetDB().Save(d).Error
+}
+
+//Delete the database from DB
+func (d *ManagedDatabaseModel) Delete() error {
+	return GetDB().Delete(d).Error
+}
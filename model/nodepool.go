@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//NodePoolModel describes a node pool of a cluster, the clusters without node pools have the single pool of their cloud properties
+type NodePoolModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterModelID uint      `gorm:"unique_index:idx_node_pool_name" json:"-"`
+	Name           string    `gorm:"unique_index:idx_node_pool_name" json:"name"`
+	InstanceType   string    `json:"instanceType"`
+	Count          int       `json:"count"`
+	Autoscaling    bool      `json:"autoscaling"`
+	MinCount       int       `json:"minCount"`
+	MaxCount       int       `json:"maxCount"`
+	// Labels is the JSON of the Kubernetes labels of the nodes
+	Labels string `gorm:"type:text" json:"-"`
+	// Spot pools have spot or preemptible instances, the max price and the on-demand share are optional
+	Spot               bool   `json:"spot"`
+	SpotPrice          string `json:"spotPrice"`
+	OnDemandPercentage int    `json:"onDemandPercentage"`
+}
+
+// TableName sets NodePoolModel's table name
+func (NodePoolModel) TableName() string {
+	return "node_pools"
+}
+
+// GetLabels returns the Kubernetes labels of the nodes of the pool
+func (pool *NodePoolModel) GetLabels() map[string]string {
+	labels := map[string]string{}
+	if pool.Labels != "" {
+		json.Unmarshal([]byte(pool.Labels), &labels)
+	}
+	return labels
+}
+
+// SetLabels sets the Kubernetes labels of the nodes of the pool
+func (pool *NodePoolModel) SetLabels(labels map[string]string) {
+	if len(labels) == 0 {
+		pool.Labels = ""
+		return
+	}
+	data, _ := json.Marshal(labels)
+	pool.Labels = string(data)
+}
+
+// Delete deletes the node pool from the database
+func (pool *NodePoolModel) Delete() error {
+	if pool.ID == 0 {
+		return nil
+	}
+	return GetDB().Delete(pool).Error
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+//ObjectStoreBucketModel is a bucket created by Pipeline for an organization: it's managed with the credentials
+//of SecretID through the object store of Provider with the settings of the provider
+type ObjectStoreBucketModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	OrganizationID uint      `gorm:"unique_index:idx_org_bucket" json:"organizationId"`
+	Provider       string    `gorm:"unique_index:idx_org_bucket" json:"provider"`
+	Name           string    `gorm:"unique_index:idx_org_bucket" json:"name"`
+	SecretID       string    `json:"secretId"`
+	CreatedBy      string    `json:"createdBy,omitempty"`
+	// Settings is the JSON of the settings of the provider of the bucket
+	Settings string `gorm:"type:text" json:"-"`
+}
+
+// TableName sets ObjectStoreBucketModel's table name
+func (ObjectStoreBucketModel) TableName() string {
+	return "object_store_buckets"
+}
+
+//GetSettings returns the settings of the provider of the bucket
+func (b *ObjectStoreBucketModel) GetSettings() map[string]string {
+	return jsonStringMap(b.Settings)
+}
+
+//SetSettings sets the settings of the provider of the bucket
+func (b *ObjectStoreBucketModel) SetSettings(settings map[string]string) {
+	b.Settings = stringMapJSON(settings)
+}
+
+//ListObjectStoreBuckets loads the buckets of the organization, the buckets of every provider if it's empty
+func ListObjectStoreBuckets(organizationID uint, provider string) ([]ObjectStoreBucketModel, error) {
+	var buckets []ObjectStoreBucketModel
+	err := GetDB().Where(ObjectStoreBucketModel{OrganizationID: organizationID, Provider: provider}).Order("name").Find(&buckets).Error
+	return buckets, err
+}
+
+//GetObjectStoreBucket loads the bucket of the organization, the error is gorm.ErrRecordNotFound if the bucket
+//isn't managed by Pipeline
+func GetObjectStoreBucket(organizationID uint, provider, name string) (*ObjectStoreBucketModel, error) {
+	var bucket ObjectStoreBucketModel
+	query := ObjectStoreBucketModel{OrganizationID: organizationID, Provider: provider, Name: name}
+	if err := GetDB().Where(query).First(&bucket).Error; err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+//Save the bucket to DB
+func (b *ObjectStoreBucketModel) Save() error {
+	return GetDB().Save(b).Error
+}
+
+//Delete the bucket from DB
+func (b *ObjectStoreBucketModel) Delete() error {
+	return GetDB().Delete(b).Error
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"time"
+)
+
+// Statuses of the scheduled operations
+const (
+	ScheduledQueued    = "QUEUED"
+	ScheduledRunning   = "RUNNING"
+	ScheduledSucceeded = "SUCCEEDED"
+	ScheduledFailed    = "FAILED"
+	ScheduledCancelled = "CANCELLED"
+)
+
+// Kinds of the scheduled operations
+const (
+	ScheduledDeploymentCreate  = "deployment.create"
+	ScheduledDeploymentUpgrade = "deployment.upgrade"
+	ScheduledClusterUpgrade    = "cluster.upgrade"
+)
+
+//ScheduledOperationModel is a deployment or a cluster upgrade queued to run at RunAt, the operations restricted
+//to the maintenance window of the cluster wait until the window is open
+type ScheduledOperationModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"index" json:"organizationId"`
+	ClusterID      uint      `gorm:"index" json:"clusterId"`
+	Kind           string    `json:"kind"`
+	ReleaseName    string    `json:"releaseName,omitempty"`
+	// Request is the JSON of the request of the operation, it's run by the scheduler
+	Request           string    `gorm:"type:text" json:"-"`
+	RunAt             time.Time `gorm:"index" json:"runAt"`
+	MaintenanceWindow bool      `json:"maintenanceWindow"`
+	Status            string    `gorm:"index" json:"status"`
+	RequestedBy       string    `json:"requestedBy"`
+	// CancelledBy is the actor cancelling the queued operation
+	CancelledBy string     `json:"cancelledBy,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	// CommitRepository and CommitSHA are the commit of the CI build scheduling the deployment
+	CommitRepository string `json:"commitRepository,omitempty"`
+	CommitSHA        string `json:"commitSha,omitempty"`
+}
+
+// TableName sets ScheduledOperationModel's table name
+func (ScheduledOperationModel) TableName() string {
+	return "scheduled_operations"
+}
+
+//ListScheduledOperations loads the scheduled operations of the organization in the order they run, filtered by
+//status and cluster if they aren't empty
+func ListScheduledOperations(organizationID uint, status string, clusterID uint) ([]ScheduledOperationModel, error) {
+	operations := []ScheduledOperationModel{}
+	err := GetDB().Where(ScheduledOperationModel{OrganizationID: organizationID, Status: status, ClusterID: clusterID}).
+		Order("run_at, id").Find(&operations).Error
+	return operations, err
+}
+
+//ListDueScheduledOperations loads the queued operations of every organization which are due at the time
+func ListDueScheduledOperations(t time.Time) ([]ScheduledOperationModel, error) {
+	operations := []ScheduledOperationModel{}
+	err := GetDB().Where("status = ? AND run_at <= ?", ScheduledQueued, t).Order("run_at, id").Find(&operations).Error
+	return operations, err
+}
+
+//GetScheduledOperation loads a scheduled operation of the organization, the error is gorm.ErrRecordNotFound
+//if it doesn't exist
+func GetScheduledOperation(organizationID, id uint) (*ScheduledOperationModel, error) {
+	var operation ScheduledOperationModel
+	if err := GetDB().Where(ScheduledOperationModel{OrganizationID: organizationID, ID: id}).First(&operation).Error; err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+//Save the scheduled operation to DB
+func (o *ScheduledOperationModel) Save() error {
+	return GetDB().Save(o).Error
+}
+
+//Start moves the queued operation into the running status, it returns false if it isn't queued anymore so one
+//operation is run only once
+func (o *ScheduledOperationModel) Start() (bool, error) {
+	now := time.Now().UTC()
+	result := GetDB().Model(&ScheduledOperationModel{}).Where("id = ? AND status = ?", o.ID, ScheduledQueued).
+		Updates(map[string]interface{}{"status": ScheduledRunning, "started_at": &now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	o.Status, o.StartedAt = ScheduledRunning, &now
+	return true, nil
+}
+
+//Cancel cancels the queued operation, it returns false if it has been started or cancelled already
+func (o *ScheduledOperationModel) Cancel(actor string) (bool, error) {
+	now := time.Now().UTC()
+	result := GetDB().Model(&ScheduledOperationModel{}).Where("id = ? AND status = ?", o.ID, ScheduledQueued).
+		Updates(map[string]interface{}{"status": ScheduledCancelled, "cancelled_by": actor, "finished_at": &now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	o.Status, o.CancelledBy, o.FinishedAt = ScheduledCancelled, actor, &now
+	return true, nil
+}
+
+//Reschedule moves the queued operation to run at the time, it returns false if it isn't queued anymore
+func (o *ScheduledOperationModel) Reschedule(runAt time.Time) (bool, error) {
+	result := GetDB().Model(&ScheduledOperationModel{}).Where("id = ? AND status = ?", o.ID, ScheduledQueued).
+		Update("run_at", runAt)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	o.RunAt = runAt
+	return true, nil
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+//SecretInjectionModel describes a Pipeline secret materialized as a Kubernetes Secret in a cluster,
+//the Kubernetes Secret is updated when the Pipeline secret changes
+type SecretInjectionModel struct {
+	ID             uint      `gorm:"primary_key" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ClusterID      uint      `gorm:"unique_index:idx_secret_injection" json:"clusterId"`
+	OrganizationID uint      `json:"organizationId"`
+	SecretID       string    `gorm:"index" json:"secretId"`
+	Namespace      string    `gorm:"unique_index:idx_secret_injection" json:"namespace"`
+	Name           string    `gorm:"unique_index:idx_secret_injection" json:"name"`
+	// Version is the version of the Pipeline secret last written to the cluster
+	Version int `json:"version"`
+	// Registry is the host of the image registry of an image pull secret, the Pipeline secret holds its credentials
+	Registry string `json:"registry,omitempty"`
+}
+
+// TableName sets SecretInjectionModel's table name
+func (SecretInjectionModel) TableName() string {
+	return "secret_injections"
+}
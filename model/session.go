@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+)
+
+//PodSessionModel is the audit record of an exec or port-forward session proxied to a pod of a cluster, Actor is
+//the user or the service account who opened it; Stdin is the recorded input of the exec sessions
+type PodSessionModel struct {
+	ID             uint       `gorm:"primary_key" json:"id"`
+	OrganizationID uint       `gorm:"index" json:"organizationId"`
+	ClusterID      uint       `gorm:"index" json:"clusterId"`
+	Actor          string     `gorm:"size:64" json:"actor"`
+	RemoteAddr     string     `json:"remoteAddr,omitempty"`
+	Kind           string     `gorm:"size:16" json:"kind"`
+	Namespace      string     `json:"namespace"`
+	Pod            string     `json:"pod"`
+	Container      string     `json:"container,omitempty"`
+	Command        string     `gorm:"type:text" json:"command,omitempty"`
+	Ports          string     `json:"ports,omitempty"`
+	TTY            bool       `json:"tty"`
+	StartedAt      time.Time  `gorm:"index" json:"startedAt"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+	BytesIn        int64      `json:"bytesIn"`
+	BytesOut       int64      `json:"bytesOut"`
+	Stdin          string     `gorm:"type:text" json:"stdin,omitempty"`
+	StdinTruncated bool       `json:"stdinTruncated,omitempty"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+}
+
+// TableName sets PodSessionModel's table name
+func (PodSessionModel) TableName() string {
+	return "pod_sessions"
+}
+
+//Save the session to DB
+func (s *PodSessionModel) Save() error {
+	return GetDB().Save(s).Error
+}
+
+//ListPodSessions loads the latest sessions of the cluster, the newest first, without their input
+func ListPodSessions(clusterID uint, limit int) ([]PodSessionModel, error) {
+	sessions := []PodSessionModel{}
+	err := GetDB().Where(PodSessionModel{ClusterID: clusterID}).Order("id desc").Limit(limit).Find(&sessions).Error
+	for i := range sessions {
+		sessions[i].Stdin = ""
+	}
+	return sessions, err
+}
+
+//GetPodSession loads a session of the cluster, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetPodSession(clusterID, id uint) (*PodSessionModel, error) {
+	var session PodSessionModel
+	if err := GetDB().Where(PodSessionModel{ID: id, ClusterID: clusterID}).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
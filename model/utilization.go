@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//ClusterUtilizationModel describes the daily utilization of a node pool of a cluster, Date is the UTC day
+//(2006-01-02). The CPU (cores) and the memory (GiB) allocatable on the nodes, requested by the pods and used
+//are the sums of the samples of the day, the usage is only summed in the UsedSamples with metrics. The nodes
+//outside the node pools have no node pool.
+type ClusterUtilizationModel struct {
+	ID                uint      `gorm:"primary_key" json:"-"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	OrganizationID    uint      `gorm:"index" json:"-"`
+	ClusterModelID    uint      `gorm:"unique_index:idx_cluster_utilization_day" json:"clusterId"`
+	NodePool          string    `gorm:"unique_index:idx_cluster_utilization_day" json:"nodePool"`
+	Date              string    `gorm:"unique_index:idx_cluster_utilization_day" json:"date"`
+	ClusterName       string    `json:"clusterName"`
+	Samples           int       `json:"samples"`
+	UsedSamples       int       `json:"usedSamples"`
+	AllocatableCPU    float64   `json:"allocatableCpu"`
+	RequestedCPU      float64   `json:"requestedCpu"`
+	UsedCPU           float64   `json:"usedCpu"`
+	AllocatableMemory float64   `json:"allocatableMemory"`
+	RequestedMemory   float64   `json:"requestedMemory"`
+	UsedMemory        float64   `json:"usedMemory"`
+}
+
+// TableName sets ClusterUtilizationModel's table name
+func (ClusterUtilizationModel) TableName() string {
+	return "cluster_utilization"
+}
+
+//AddClusterUtilization adds the samples to the daily utilization of the node pool
+func AddClusterUtilization(utilization ClusterUtilizationModel) error {
+	tx := GetDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	key := ClusterUtilizationModel{ClusterModelID: utilization.ClusterModelID, NodePool: utilization.NodePool, Date: utilization.Date}
+	var daily ClusterUtilizationModel
+	err := tx.Where(key).Attrs(ClusterUtilizationModel{
+		OrganizationID: utilization.OrganizationID,
+		ClusterName:    utilization.ClusterName,
+	}).FirstOrCreate(&daily).Error
+	if err == nil {
+		err = tx.Model(&daily).UpdateColumns(map[string]interface{}{
+			"samples":            gorm.Expr("samples + ?", utilization.Samples),
+			"used_samples":       gorm.Expr("used_samples + ?", utilization.UsedSamples),
+			"allocatable_cpu":    gorm.Expr("allocatable_cpu + ?", utilization.AllocatableCPU),
+			"requested_cpu":      gorm.Expr("requested_cpu + ?", utilization.RequestedCPU),
+			"used_cpu":           gorm.Expr("used_cpu + ?", utilization.UsedCPU),
+			"allocatable_memory": gorm.Expr("allocatable_memory + ?", utilization.AllocatableMemory),
+			"requested_memory":   gorm.Expr("requested_memory + ?", utilization.RequestedMemory),
+			"used_memory":        gorm.Expr("used_memory + ?", utilization.UsedMemory),
+			"updated_at":         time.Now(),
+		}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+//QueryClusterUtilization loads the daily utilization matching the filter between the days from and to (both
+//inclusive)
+func QueryClusterUtilization(filter ClusterUtilizationModel, from, to string) ([]ClusterUtilizationModel, error) {
+	utilization := []ClusterUtilizationModel{}
+	err := GetDB().Where(filter).Where("date >= ? AND date <= ?", from, to).
+		Order("date, cluster_model_id, node_pool").Find(&utilization).Error
+	return utilization, err
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// States of the deliveries of the webhooks
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+//WebhookModel is a webhook subscription of an organization receiving its events, the payloads are signed with
+//the secret; the webhooks without types receive every event
+type WebhookModel struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	OrganizationID uint      `gorm:"index" json:"-"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"-"`
+	// Types is the comma separated list of the event types sent to the webhook
+	Types string `json:"-"`
+}
+
+// TableName sets WebhookModel's table name
+func (WebhookModel) TableName() string {
+	return "organization_webhooks"
+}
+
+//GetTypes returns the event types sent to the webhook
+func (w *WebhookModel) GetTypes() []string {
+	if w.Types == "" {
+		return []string{}
+	}
+	return strings.Split(w.Types, ",")
+}
+
+//SetTypes sets the event types sent to the webhook
+func (w *WebhookModel) SetTypes(types []string) {
+	w.Types = strings.Join(types, ",")
+}
+
+//Accepts checks whether the event type is sent to the webhook
+func (w *WebhookModel) Accepts(eventType string) bool {
+	types := w.GetTypes()
+	for _, t := range types {
+		if t == eventType {
+			return true
+		}
+	}
+	return len(types) == 0
+}
+
+//ListWebhooks loads the webhooks of the organization
+func ListWebhooks(organizationID uint) ([]WebhookModel, error) {
+	webhooks := []WebhookModel{}
+	err := GetDB().Where(WebhookModel{OrganizationID: organizationID}).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+//GetWebhook loads a webhook of the organization, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetWebhook(organizationID, id uint) (*WebhookModel, error) {
+	var webhook WebhookModel
+	if err := GetDB().Where(WebhookModel{ID: id, OrganizationID: organizationID}).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+//GetWebhookByID loads a webhook of any organization
+func GetWebhookByID(id uint) (*WebhookModel, error) {
+	var webhook WebhookModel
+	if err := GetDB().First(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+//Save the webhook to DB
+func (w *WebhookModel) Save() error {
+	return GetDB().Save(w).Error
+}
+
+//Delete the webhook and its deliveries from DB
+func (w *WebhookModel) Delete() error {
+	if err := GetDB().Where(WebhookDeliveryModel{WebhookID: w.ID}).Delete(WebhookDeliveryModel{}).Error; err != nil {
+		return err
+	}
+	return GetDB().Delete(w).Error
+}
+
+//WebhookDeliveryModel is a delivery of an event to a webhook, the pending deliveries are attempted at NextAttempt;
+//the response of the last attempt is kept for debugging
+type WebhookDeliveryModel struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	WebhookID    uint       `gorm:"index" json:"webhookId"`
+	EventID      uint       `json:"eventId"`
+	EventType    string     `json:"eventType"`
+	Payload      string     `gorm:"type:text" json:"payload"`
+	Status       string     `gorm:"index" json:"status"`
+	Attempts     int        `json:"attempts"`
+	NextAttempt  time.Time  `gorm:"index" json:"nextAttempt,omitempty"`
+	ResponseCode int        `json:"responseCode,omitempty"`
+	ResponseBody string     `gorm:"type:text" json:"responseBody,omitempty"`
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	DeliveredAt  *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// TableName sets WebhookDeliveryModel's table name
+func (WebhookDeliveryModel) TableName() string {
+	return "webhook_deliveries"
+}
+
+//ListWebhookDeliveries loads the latest deliveries of the webhook, the newest first, filtered by the status
+func ListWebhookDeliveries(webhookID uint, status string, limit int) ([]WebhookDeliveryModel, error) {
+	query := GetDB().Where(WebhookDeliveryModel{WebhookID: webhookID})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	deliveries := []WebhookDeliveryModel{}
+	err := query.Order("id desc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+//GetWebhookDelivery loads a delivery of the webhook, the error is gorm.ErrRecordNotFound if it doesn't exist
+func GetWebhookDelivery(webhookID, id uint) (*WebhookDeliveryModel, error) {
+	var delivery WebhookDeliveryModel
+	if err := GetDB().Where(WebhookDeliveryModel{ID: id, WebhookID: webhookID}).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+//DueWebhookDeliveries loads the pending deliveries to attempt at the time
+func DueWebhookDeliveries(now time.Time) ([]WebhookDeliveryModel, error) {
+	deliveries := []WebhookDeliveryModel{}
+	err := GetDB().Where("status = ? AND next_attempt <= ?", WebhookDeliveryPending, now).Order("next_attempt").Find(&deliveries).Error
+	return deliveries, err
+}
+
+//ClaimWebhookDelivery counts an attempt of the pending delivery and postpones its next attempt if it wasn't
+//attempted since it was loaded, so only one attempt is made at a time; it's false if another attempt claimed it
+func ClaimWebhookDelivery(delivery *WebhookDeliveryModel, nextAttempt time.Time) (bool, error) {
+	result := GetDB().Model(WebhookDeliveryModel{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, WebhookDeliveryPending, delivery.Attempts).
+		Updates(map[string]interface{}{"attempts": delivery.Attempts + 1, "next_attempt": nextAttempt})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+	delivery.Attempts++
+	delivery.NextAttempt = nextAttempt
+	return true, nil
+}
+
+//DeleteWebhookDeliveries deletes the finished deliveries created before the time
+func DeleteWebhookDeliveries(before time.Time) error {
+	return GetDB().Where("status <> ? AND created_at < ?", WebhookDeliveryPending, before).Delete(WebhookDeliveryModel{}).Error
+}
+
+//Save the delivery to DB
+func (d *WebhookDeliveryModel) Save() error {
+	return GetDB().Save(d).Error
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Types of the events of the activity stream of the organizations
+const (
+	EventClusterCreated       = "cluster.created"
+	EventClusterUpdated       = "cluster.updated"
+	EventClusterDeleted       = "cluster.deleted"
+	EventNodePoolCreated      = "nodepool.created"
+	EventNodePoolUpdated      = "nodepool.updated"
+	EventNodePoolDeleted      = "nodepool.deleted"
+	EventDeploymentCreated    = "deployment.created"
+	EventDeploymentUpgraded   = "deployment.upgraded"
+	EventDeploymentRolledBack = "deployment.rolledback"
+	EventDeploymentDeleted    = "deployment.deleted"
+	EventTokenRevoked         = "token.revoked"
+	EventPodExec              = "pod.exec"
+	EventPodPortForward       = "pod.portforward"
+	EventApprovalRequested    = "deployment.approvalrequested"
+	EventDeploymentApproved   = "deployment.approved"
+	EventDeploymentRejected   = "deployment.rejected"
+	EventOperationScheduled   = "operation.scheduled"
+	EventOperationCancelled   = "operation.cancelled"
+)
+
+// EventTypes are the types of the recorded events
+var EventTypes = []string{
+	EventClusterCreated, EventClusterUpdated, EventClusterDeleted,
+	EventNodePoolCreated, EventNodePoolUpdated, EventNodePoolDeleted,
+	EventDeploymentCreated, EventDeploymentUpgraded, EventDeploymentRolledBack, EventDeploymentDeleted,
+	EventTokenRevoked, EventPodExec, EventPodPortForward,
+	EventApprovalRequested, EventDeploymentApproved, EventDeploymentRejected,
+	EventOperationScheduled, EventOperationCancelled,
+}
+
+//RecordEvent saves the event to the activity stream of its organization and delivers it to the webhooks of the
+//organization in the background, the errors are only logged because the action is already done
+func RecordEvent(event model.EventModel) {
+	log := logger.WithFields(logrus.Fields{"tag": "RecordEvent"})
+	event.Time = time.Now().UTC()
+	if err := event.Save(); err != nil {
+		log.Errorf("Error saving %s event of organization %d: %s", event.Type, event.OrganizationID, err.Error())
+		return
+	}
+	go deliverEvent(event)
+}
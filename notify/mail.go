@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+//SendMail sends a plain text mail through the SMTP server of alerting.smtp, nothing is sent without a smarthost
+func SendMail(to []string, subject, body string) error {
+	smarthost := viper.GetString("alerting.smtp.smarthost")
+	if smarthost == "" || len(to) == 0 {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(smarthost)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if username := viper.GetString("alerting.smtp.username"); username != "" {
+		auth = smtp.PlainAuth("", username, viper.GetString("alerting.smtp.password"), host)
+	}
+	from := viper.GetString("alerting.smtp.from")
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		from, strings.Join(to, ", "), subject, body)
+	return smtp.SendMail(smarthost, auth, from, to, []byte(message))
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Headers of the payloads sent to the webhooks
+const (
+	WebhookSignatureHeader = "X-Pipeline-Signature"
+	WebhookEventHeader     = "X-Pipeline-Event"
+	WebhookDeliveryHeader  = "X-Pipeline-Delivery"
+)
+
+// EventWebhookPing is the type of the test events sent to a webhook, they aren't recorded
+const EventWebhookPing = "webhook.ping"
+
+// maxResponseBody is the length of the response of a webhook kept in the delivery log
+const maxResponseBody = 1024
+
+// deliverEvent queues a delivery of the event to every webhook of its organization accepting its type
+func deliverEvent(event model.EventModel) {
+	log := logger.WithFields(logrus.Fields{"tag": "DeliverEvent"})
+	webhooks, err := model.ListWebhooks(event.OrganizationID)
+	if err != nil {
+		log.Errorf("Error listing the webhooks of organization %d: %s", event.OrganizationID, err.Error())
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("Error marshalling %s event: %s", event.Type, err.Error())
+		return
+	}
+	for i := range webhooks {
+		if !webhooks[i].Accepts(event.Type) {
+			continue
+		}
+		if _, err := QueueDelivery(&webhooks[i], event.ID, event.Type, payload); err != nil {
+			log.Errorf("Error queueing %s event to webhook %d: %s", event.Type, webhooks[i].ID, err.Error())
+		}
+	}
+}
+
+//QueueDelivery saves a pending delivery of the payload to the webhook and makes the first attempt in the
+//background, the failed attempts are retried by RunWebhookDeliveries
+func QueueDelivery(webhook *model.WebhookModel, eventID uint, eventType string, payload []byte) (*model.WebhookDeliveryModel, error) {
+	delivery := &model.WebhookDeliveryModel{
+		WebhookID:   webhook.ID,
+		EventID:     eventID,
+		EventType:   eventType,
+		Payload:     string(payload),
+		Status:      model.WebhookDeliveryPending,
+		NextAttempt: time.Now().UTC(),
+	}
+	if err := delivery.Save(); err != nil {
+		return nil, err
+	}
+	go attemptDelivery(*webhook, *delivery)
+	return delivery, nil
+}
+
+//PingWebhook queues a test event to the webhook
+func PingWebhook(webhook *model.WebhookModel) (*model.WebhookDeliveryModel, error) {
+	payload, err := json.Marshal(model.EventModel{
+		Time:           time.Now().UTC(),
+		OrganizationID: webhook.OrganizationID,
+		Type:           EventWebhookPing,
+		Resource:       strconv.FormatUint(uint64(webhook.ID), 10),
+		Message:        fmt.Sprintf("Test event of webhook %d", webhook.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return QueueDelivery(webhook, 0, EventWebhookPing, payload)
+}
+
+//RunWebhookDeliveries retries the due pending deliveries every interval and deletes the finished deliveries
+//older than webhooks.deliveryRetention
+func RunWebhookDeliveries(interval time.Duration) {
+	log := logger.WithFields(logrus.Fields{"tag": "WebhookDeliveries"})
+	for range time.Tick(interval) {
+		deliveries, err := model.DueWebhookDeliveries(time.Now().UTC())
+		if err != nil {
+			log.Errorf("Error listing the pending webhook deliveries: %s", err.Error())
+			continue
+		}
+		for _, delivery := range deliveries {
+			webhook, err := model.GetWebhookByID(delivery.WebhookID)
+			if err != nil {
+				log.Errorf("Error getting webhook %d: %s", delivery.WebhookID, err.Error())
+				continue
+			}
+			attemptDelivery(*webhook, delivery)
+		}
+		retention := viper.GetDuration("webhooks.deliveryRetention")
+		if err := model.DeleteWebhookDeliveries(time.Now().UTC().Add(-retention)); err != nil {
+			log.Errorf("Error deleting the old webhook deliveries: %s", err.Error())
+		}
+	}
+}
+
+// attemptDelivery sends the payload of the delivery to the webhook and saves the outcome, the deliveries are
+// failed after webhooks.maxAttempts attempts; the next attempt is claimed before sending, so the delivery is
+// retried if Pipeline stops during the attempt
+func attemptDelivery(webhook model.WebhookModel, delivery model.WebhookDeliveryModel) {
+	log := logger.WithFields(logrus.Fields{"tag": "AttemptDelivery"})
+	backoff := WebhookBackoff(delivery.Attempts+1, viper.GetDuration("webhooks.backoff"), viper.GetDuration("webhooks.maxBackoff"))
+	timeout := viper.GetDuration("webhooks.timeout")
+	claimed, err := model.ClaimWebhookDelivery(&delivery, time.Now().UTC().Add(timeout+backoff))
+	if err != nil {
+		log.Errorf("Error claiming delivery %d: %s", delivery.ID, err.Error())
+		return
+	} else if !claimed {
+		return
+	}
+
+	delivery.ResponseCode, delivery.ResponseBody, err = sendWebhook(webhook, delivery, timeout)
+	now := time.Now().UTC()
+	if err == nil {
+		delivery.Status, delivery.Error, delivery.DeliveredAt = model.WebhookDeliveryDelivered, "", &now
+	} else {
+		delivery.Error = err.Error()
+		delivery.NextAttempt = now.Add(backoff)
+		if delivery.Attempts >= viper.GetInt("webhooks.maxAttempts") {
+			delivery.Status = model.WebhookDeliveryFailed
+		}
+		log.Warnf("Attempt %d of delivery %d to webhook %d failed: %s", delivery.Attempts, delivery.ID, webhook.ID, err.Error())
+	}
+	if err := delivery.Save(); err != nil {
+		log.Errorf("Error saving delivery %d: %s", delivery.ID, err.Error())
+	}
+}
+
+// sendWebhook posts the payload of the delivery to the webhook, signed with the secret of the webhook if it's
+// set; the non-2xx responses are errors
+func sendWebhook(webhook model.WebhookModel, delivery model.WebhookDeliveryModel, timeout time.Duration) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, delivery.EventType)
+	request.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	if webhook.Secret != "" {
+		request.Header.Set(WebhookSignatureHeader, WebhookSignature(webhook.Secret, payload))
+	}
+	response, err := webhookClient(timeout).Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseBody))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, string(body), fmt.Errorf("the webhook responded %s", response.Status)
+	}
+	return response.StatusCode, string(body), nil
+}
+
+// webhookClient returns the HTTP client of the deliveries, it connects to public addresses only and doesn't follow
+// the redirects, so the webhooks can't reach the internal services through Pipeline
+func webhookClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         utils.PublicDialer(timeout).DialContext,
+			TLSHandshakeTimeout: timeout,
+			DisableKeepAlives:   true,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+//WebhookSignature returns the signature header value of the payload, the hex HMAC-SHA256 of the payload keyed
+//with the secret of the webhook
+func WebhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//WebhookBackoff returns the delay after the attempt of a delivery, it doubles the base delay after each attempt
+//up to the max delay
+func WebhookBackoff(attempt int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
@@ -0,0 +1,39 @@
+package notify_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/notify"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		attempt  int
+		expected time.Duration
+	}{
+		{name: "first attempt", attempt: 1, expected: 30 * time.Second},
+		{name: "second attempt", attempt: 2, expected: time.Minute},
+		{name: "fifth attempt", attempt: 5, expected: 8 * time.Minute},
+		{name: "max backoff", attempt: 9, expected: time.Hour},
+		{name: "many attempts", attempt: 100, expected: time.Hour},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if backoff := notify.WebhookBackoff(tc.attempt, 30*time.Second, time.Hour); backoff != tc.expected {
+				t.Errorf("Expected %s, got: %s", tc.expected, backoff)
+			}
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// echo -n '{"type":"cluster.created"}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=91a87a9336f905d95d07e4d12d48756c8fff132b06d234bef812c5f0525fb5ee"
+	if signature := notify.WebhookSignature("secret", []byte(`{"type":"cluster.created"}`)); signature != expected {
+		t.Errorf("Expected %s, got: %s", expected, signature)
+	}
+}
@@ -0,0 +1,59 @@
+package objectstore
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// amazonObjectStore manages the S3 buckets of the region
+type amazonObjectStore struct {
+	client *s3.S3
+	region string
+}
+
+// newAmazonObjectStore returns the S3 client of the region with the keys of the Amazon secret
+func newAmazonObjectStore(values map[string]string, settings map[string]string) (*amazonObjectStore, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(settings["region"]),
+		Credentials: credentials.NewStaticCredentials(values["AWS_ACCESS_KEY_ID"], values["AWS_SECRET_ACCESS_KEY"], ""),
+		HTTPClient:  httpClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &amazonObjectStore{client: s3.New(sess), region: settings["region"]}, nil
+}
+
+// CreateBucket implements ObjectStore, the bucket is created in the region with the default encryption of S3
+func (s *amazonObjectStore) CreateBucket(name string) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(name), ACL: aws.String(s3.BucketCannedACLPrivate)}
+	// us-east-1 is the default location, S3 rejects it as a location constraint
+	if s.region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(s.region)}
+	}
+	if _, err := s.client.CreateBucket(input); err != nil {
+		return err
+	}
+	return s.client.WaitUntilBucketExists(&s3.HeadBucketInput{Bucket: aws.String(name)})
+}
+
+// ListBuckets implements ObjectStore, S3 lists the buckets of every region
+func (s *amazonObjectStore) ListBuckets() ([]Bucket, error) {
+	output, err := s.client.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+	buckets := []Bucket{}
+	for _, bucket := range output.Buckets {
+		buckets = append(buckets, Bucket{Name: aws.StringValue(bucket.Name), CreatedAt: bucket.CreationDate})
+	}
+	return buckets, nil
+}
+
+// DeleteBucket implements ObjectStore
+func (s *amazonObjectStore) DeleteBucket(name string) error {
+	_, err := s.client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(name)})
+	return err
+}
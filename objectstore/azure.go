@@ -0,0 +1,109 @@
+package objectstore
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// azureStorageAPIVersion is the version of the storage Resource Manager API with the blob containers
+const azureStorageAPIVersion = "2018-02-01"
+
+// azureObjectStore manages the blob containers of the storage account through the Resource Manager, the
+// account keys aren't needed
+type azureObjectStore struct {
+	client *http.Client
+	// account is the Resource Manager URL of the storage account
+	account string
+}
+
+// azureContainer is a blob container resource of the Resource Manager
+type azureContainer struct {
+	Name       string `json:"name"`
+	Properties struct {
+		PublicAccess     string     `json:"publicAccess,omitempty"`
+		LastModifiedTime *time.Time `json:"lastModifiedTime,omitempty"`
+	} `json:"properties"`
+}
+
+// azureTransport authorizes the requests with the token of the service principal
+type azureTransport struct {
+	token *adal.ServicePrincipalToken
+}
+
+// RoundTrip implements http.RoundTripper, the token is refreshed before it expires
+func (t *azureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.token.EnsureFresh(); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token.OAuthToken())
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newAzureObjectStore returns the client of the storage account authenticated with the service principal of
+// the Azure secret
+func newAzureObjectStore(values map[string]string, settings map[string]string) (*azureObjectStore, error) {
+	oauthConfig, err := adal.NewOAuthConfig(azure.PublicCloud.ActiveDirectoryEndpoint, values["AZURE_TENANT_ID"])
+	if err != nil {
+		return nil, err
+	}
+	token, err := adal.NewServicePrincipalToken(*oauthConfig, values["AZURE_CLIENT_ID"], values["AZURE_CLIENT_SECRET"],
+		azure.PublicCloud.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	token.SetSender(httpClient)
+	account := fmt.Sprintf("%ssubscriptions/%s/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/%s",
+		azure.PublicCloud.ResourceManagerEndpoint, url.PathEscape(values["AZURE_SUBSCRIPTION_ID"]),
+		url.PathEscape(settings["resourceGroup"]), url.PathEscape(settings["storageAccount"]))
+	return &azureObjectStore{
+		client:  &http.Client{Timeout: httpClient.Timeout, Transport: &azureTransport{token: token}},
+		account: account,
+	}, nil
+}
+
+// containerURL returns the Resource Manager URL of the container, or of the containers if the name is empty
+func (s *azureObjectStore) containerURL(name string) string {
+	path := s.account + "/blobServices/default/containers"
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path + "?api-version=" + azureStorageAPIVersion
+}
+
+// CreateBucket implements ObjectStore, the container has no public access
+func (s *azureObjectStore) CreateBucket(name string) error {
+	var container azureContainer
+	container.Properties.PublicAccess = "None"
+	return requestJSON(ProviderAzure, s.client, http.MethodPut, s.containerURL(name), container, nil)
+}
+
+// ListBuckets implements ObjectStore, the containers of the storage account are listed
+func (s *azureObjectStore) ListBuckets() ([]Bucket, error) {
+	buckets := []Bucket{}
+	next := s.containerURL("")
+	for next != "" {
+		var page struct {
+			Value    []azureContainer `json:"value"`
+			NextLink string           `json:"nextLink"`
+		}
+		if err := requestJSON(ProviderAzure, s.client, http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, container := range page.Value {
+			buckets = append(buckets, Bucket{Name: container.Name, CreatedAt: container.Properties.LastModifiedTime})
+		}
+		next = strings.TrimSpace(page.NextLink)
+	}
+	return buckets, nil
+}
+
+// DeleteBucket implements ObjectStore, Azure deletes the blobs of the container too
+func (s *azureObjectStore) DeleteBucket(name string) error {
+	return requestJSON(ProviderAzure, s.client, http.MethodDelete, s.containerURL(name), nil, nil)
+}
@@ -0,0 +1,89 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// googleStorageURL is the address of the JSON API of Cloud Storage
+const googleStorageURL = "https://www.googleapis.com/storage/v1"
+
+// googleObjectStore manages the GCS buckets of the project through the JSON API
+type googleObjectStore struct {
+	client   *http.Client
+	project  string
+	location string
+}
+
+// googleBucket is a bucket resource of the JSON API
+type googleBucket struct {
+	Name        string     `json:"name"`
+	Location    string     `json:"location,omitempty"`
+	TimeCreated *time.Time `json:"timeCreated,omitempty"`
+}
+
+// newGoogleObjectStore returns the client authenticated with the service account of the Google secret
+func newGoogleObjectStore(values map[string]string, settings map[string]string) (*googleObjectStore, error) {
+	serviceAccount, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	config, err := google.JWTConfigFromJSON(serviceAccount, "https://www.googleapis.com/auth/devstorage.full_control")
+	if err != nil {
+		return nil, err
+	}
+	store := &googleObjectStore{project: settings["project"], location: settings["location"]}
+	if store.project == "" {
+		store.project = values["project_id"]
+	}
+	if store.location == "" {
+		store.location = "US"
+	}
+	if store.project == "" {
+		return nil, fmt.Errorf("the google buckets require the project setting")
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+	store.client = config.Client(ctx)
+	return store, nil
+}
+
+// CreateBucket implements ObjectStore, the bucket and its objects are private to the project
+func (s *googleObjectStore) CreateBucket(name string) error {
+	query := url.Values{"project": {s.project}, "predefinedAcl": {"projectPrivate"}, "predefinedDefaultObjectAcl": {"projectPrivate"}}
+	bucket := googleBucket{Name: name, Location: s.location}
+	return requestJSON(ProviderGoogle, s.client, http.MethodPost, googleStorageURL+"/b?"+query.Encode(), bucket, nil)
+}
+
+// ListBuckets implements ObjectStore, every page of the buckets of the project is listed
+func (s *googleObjectStore) ListBuckets() ([]Bucket, error) {
+	buckets := []Bucket{}
+	query := url.Values{"project": {s.project}}
+	for {
+		var page struct {
+			Items         []googleBucket `json:"items"`
+			NextPageToken string         `json:"nextPageToken"`
+		}
+		if err := requestJSON(ProviderGoogle, s.client, http.MethodGet, googleStorageURL+"/b?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, bucket := range page.Items {
+			buckets = append(buckets, Bucket{Name: bucket.Name, Location: bucket.Location, CreatedAt: bucket.TimeCreated})
+		}
+		if page.NextPageToken == "" {
+			return buckets, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// DeleteBucket implements ObjectStore
+func (s *googleObjectStore) DeleteBucket(name string) error {
+	return requestJSON(ProviderGoogle, s.client, http.MethodDelete, googleStorageURL+"/b/"+url.PathEscape(name), nil, nil)
+}
@@ -0,0 +1,140 @@
+package objectstore
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+)
+
+//BucketRequest describes a bucket of an organization, its provider is the provider of the type of the secret
+type BucketRequest struct {
+	Name     string            `json:"name" binding:"required"`
+	SecretID string            `json:"secretId" binding:"required"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+//ManagedBucket is a bucket created by Pipeline for an organization
+type ManagedBucket struct {
+	Name      string            `json:"name"`
+	Provider  string            `json:"provider"`
+	SecretID  string            `json:"secretId"`
+	Settings  map[string]string `json:"settings,omitempty"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// managedBucket returns the bucket of the model
+func managedBucket(bucket *model.ObjectStoreBucketModel) *ManagedBucket {
+	return &ManagedBucket{
+		Name:      bucket.Name,
+		Provider:  bucket.Provider,
+		SecretID:  bucket.SecretID,
+		Settings:  bucket.GetSettings(),
+		CreatedBy: bucket.CreatedBy,
+		CreatedAt: bucket.CreatedAt,
+	}
+}
+
+// objectStoreOfSecret returns the object store and the provider of the secret of the organization
+func objectStoreOfSecret(organizationID uint, secretID string, settings map[string]string) (ObjectStore, string, error) {
+	item, err := secret.Store.Get(strconv.FormatUint(uint64(organizationID), 10), secretID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error getting the secret of the bucket")
+	}
+	provider, err := ProviderOfSecret(item.SecretType)
+	if err != nil {
+		return nil, "", err
+	}
+	store, err := NewObjectStore(provider, item.Values, settings)
+	if err != nil {
+		return nil, "", err
+	}
+	return store, provider, nil
+}
+
+//EnsureManagedBucket creates the bucket of the request for the organization, the bucket is returned unchanged
+//if it's already managed by Pipeline; created is false then
+func EnsureManagedBucket(organizationID uint, createdBy string, request BucketRequest) (bucket *ManagedBucket, created bool, err error) {
+	store, provider, err := objectStoreOfSecret(organizationID, request.SecretID, request.Settings)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := ValidateBucketName(provider, request.Name); err != nil {
+		return nil, false, err
+	}
+	saved, err := model.GetObjectStoreBucket(organizationID, provider, request.Name)
+	if err == nil {
+		return managedBucket(saved), false, nil
+	} else if !model.IsErrorGormNotFound(err) {
+		return nil, false, err
+	}
+	if err := store.CreateBucket(request.Name); err != nil {
+		return nil, false, errors.Wrapf(err, "error creating the %s bucket %s", provider, request.Name)
+	}
+	saved = &model.ObjectStoreBucketModel{
+		OrganizationID: organizationID,
+		Provider:       provider,
+		Name:           request.Name,
+		SecretID:       request.SecretID,
+		CreatedBy:      createdBy,
+	}
+	saved.SetSettings(request.Settings)
+	if err := saved.Save(); err != nil {
+		return nil, false, err
+	}
+	return managedBucket(saved), true, nil
+}
+
+//ListManagedBuckets lists the buckets created by Pipeline for the organization, of every provider if it's empty
+func ListManagedBuckets(organizationID uint, provider string) ([]ManagedBucket, error) {
+	saved, err := model.ListObjectStoreBuckets(organizationID, provider)
+	if err != nil {
+		return nil, err
+	}
+	buckets := []ManagedBucket{}
+	for i := range saved {
+		buckets = append(buckets, *managedBucket(&saved[i]))
+	}
+	return buckets, nil
+}
+
+//GetManagedBucket returns the bucket of the provider created by Pipeline for the organization, the error is
+//gorm.ErrRecordNotFound if the bucket isn't managed
+func GetManagedBucket(organizationID uint, provider, name string) (*ManagedBucket, error) {
+	saved, err := model.GetObjectStoreBucket(organizationID, provider, name)
+	if err != nil {
+		return nil, err
+	}
+	return managedBucket(saved), nil
+}
+
+//DeleteManagedBucket deletes the bucket of the provider created by Pipeline for the organization with the
+//secret it was created with, the bucket already deleted from the cloud is only forgotten
+func DeleteManagedBucket(organizationID uint, provider, name string) error {
+	saved, err := model.GetObjectStoreBucket(organizationID, provider, name)
+	if err != nil {
+		return err
+	}
+	store, _, err := objectStoreOfSecret(organizationID, saved.SecretID, saved.GetSettings())
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteBucket(name); err != nil && StatusCode(err) != http.StatusNotFound {
+		return errors.Wrapf(err, "error deleting the %s bucket %s", provider, name)
+	}
+	return saved.Delete()
+}
+
+//ListAccountBuckets lists every bucket of the cloud account of the secret, not only the ones managed by
+//Pipeline
+func ListAccountBuckets(organizationID uint, secretID string, settings map[string]string) ([]Bucket, error) {
+	store, _, err := objectStoreOfSecret(organizationID, secretID, settings)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListBuckets()
+}
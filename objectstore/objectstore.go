@@ -0,0 +1,178 @@
+package objectstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/pkg/errors"
+)
+
+// Providers of the object stores
+const (
+	ProviderAmazon = "amazon"
+	ProviderGoogle = "google"
+	ProviderAzure  = "azure"
+)
+
+// httpClient is the client of the storage APIs of Google and Azure
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SecretTypes are the types of the secrets of the providers
+var SecretTypes = map[string]string{
+	ProviderAmazon: secret.Amazon,
+	ProviderGoogle: secret.Google,
+	ProviderAzure:  secret.Azure,
+}
+
+// providerSettings are the known settings of the providers, the required ones are true: the region of S3, the
+// location and the project of GCS (US and the project of the service account by default) and the storage
+// account of the Azure Blob containers
+var providerSettings = map[string]map[string]bool{
+	ProviderAmazon: {"region": true},
+	ProviderGoogle: {"location": false, "project": false},
+	ProviderAzure:  {"resourceGroup": true, "storageAccount": true},
+}
+
+// the bucket names accepted by every provider: S3 and GCS allow dots too, Azure containers only hyphens
+var (
+	bucketNamePattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	containerNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9]|-[a-z0-9])+$`)
+)
+
+//Bucket is a bucket of an object store, a container of a storage account on Azure
+type Bucket struct {
+	Name      string     `json:"name"`
+	Location  string     `json:"location,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+//ObjectStore manages the buckets of a cloud account
+type ObjectStore interface {
+	// CreateBucket creates a private bucket
+	CreateBucket(name string) error
+	// ListBuckets lists the buckets of the account
+	ListBuckets() ([]Bucket, error)
+	// DeleteBucket deletes the bucket, S3 and GCS only delete the empty buckets
+	DeleteBucket(name string) error
+}
+
+//NewObjectStore returns the object store of the provider with the values of its secret and its settings
+func NewObjectStore(provider string, values map[string]string, settings map[string]string) (ObjectStore, error) {
+	if err := ValidateSettings(provider, settings); err != nil {
+		return nil, err
+	}
+	switch provider {
+	case ProviderAmazon:
+		return newAmazonObjectStore(values, settings)
+	case ProviderGoogle:
+		return newGoogleObjectStore(values, settings)
+	default:
+		return newAzureObjectStore(values, settings)
+	}
+}
+
+//ValidateSettings checks the provider and its settings
+func ValidateSettings(provider string, settings map[string]string) error {
+	known, ok := providerSettings[provider]
+	if !ok {
+		return fmt.Errorf("the provider must be %s, %s or %s", ProviderAmazon, ProviderGoogle, ProviderAzure)
+	}
+	for name, required := range known {
+		if required && settings[name] == "" {
+			return fmt.Errorf("the %s buckets require the %s setting", provider, name)
+		}
+	}
+	for name := range settings {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown setting of the %s buckets: %s", provider, name)
+		}
+	}
+	return nil
+}
+
+//ValidateBucketName checks the name against the naming rules of the buckets of the provider
+func ValidateBucketName(provider, name string) error {
+	pattern := bucketNamePattern
+	if provider == ProviderAzure {
+		pattern = containerNamePattern
+	}
+	if len(name) < 3 || len(name) > 63 || !pattern.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid %s bucket name: %q", provider, name)
+	}
+	return nil
+}
+
+//ProviderOfSecret returns the provider of the type of the secret
+func ProviderOfSecret(secretType string) (string, error) {
+	for provider, providerSecretType := range SecretTypes {
+		if providerSecretType == secretType {
+			return provider, nil
+		}
+	}
+	return "", fmt.Errorf("the buckets are managed with a %s, %s or %s, not a %s", secret.Amazon, secret.Google, secret.Azure, secretType)
+}
+
+//APIError is an error response of the storage API of Google or Azure
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s responded %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+//StatusCode returns the HTTP status of the error of the provider, it's zero for the other errors
+func StatusCode(err error) int {
+	switch err := errors.Cause(err).(type) {
+	case *APIError:
+		return err.StatusCode
+	case awserr.RequestFailure:
+		return err.StatusCode()
+	}
+	return 0
+}
+
+// requestJSON calls a storage API with the client, the JSON response is decoded to the result if it's not nil
+func requestJSON(provider string, client *http.Client, method, url string, body, result interface{}) error {
+	data := []byte{}
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{Provider: provider, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+	return nil
+}
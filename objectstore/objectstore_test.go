@@ -0,0 +1,91 @@
+package objectstore_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/objectstore"
+	"github.com/banzaicloud/pipeline/secret"
+)
+
+func TestValidateBucketName(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		provider    string
+		bucket      string
+		expectError bool
+	}{
+		{name: "amazon", provider: objectstore.ProviderAmazon, bucket: "acme-logs.eu"},
+		{name: "google", provider: objectstore.ProviderGoogle, bucket: "acme-spark-data"},
+		{name: "azure", provider: objectstore.ProviderAzure, bucket: "acme-backups"},
+		{name: "too short", provider: objectstore.ProviderAmazon, bucket: "ab", expectError: true},
+		{name: "uppercase", provider: objectstore.ProviderGoogle, bucket: "Acme", expectError: true},
+		{name: "double dot", provider: objectstore.ProviderAmazon, bucket: "acme..logs", expectError: true},
+		{name: "azure dot", provider: objectstore.ProviderAzure, bucket: "acme.backups", expectError: true},
+		{name: "azure double hyphen", provider: objectstore.ProviderAzure, bucket: "acme--backups", expectError: true},
+		{name: "trailing hyphen", provider: objectstore.ProviderGoogle, bucket: "acme-", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := objectstore.ValidateBucketName(tc.provider, tc.bucket)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateBucketName: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		provider    string
+		settings    map[string]string
+		expectError bool
+	}{
+		{name: "amazon", provider: objectstore.ProviderAmazon, settings: map[string]string{"region": "eu-west-1"}},
+		{name: "google defaults", provider: objectstore.ProviderGoogle},
+		{name: "azure", provider: objectstore.ProviderAzure, settings: map[string]string{"resourceGroup": "rg", "storageAccount": "acme"}},
+		{name: "amazon without region", provider: objectstore.ProviderAmazon, expectError: true},
+		{name: "azure without account", provider: objectstore.ProviderAzure, settings: map[string]string{"resourceGroup": "rg"}, expectError: true},
+		{name: "unknown setting", provider: objectstore.ProviderGoogle, settings: map[string]string{"region": "eu"}, expectError: true},
+		{name: "unknown provider", provider: "minio", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := objectstore.ValidateSettings(tc.provider, tc.settings)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error, but not got error!")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error during ValidateSettings: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestProviderOfSecret(t *testing.T) {
+
+	for provider, secretType := range objectstore.SecretTypes {
+		got, err := objectstore.ProviderOfSecret(secretType)
+		if err != nil {
+			t.Errorf("Error during ProviderOfSecret: %s", err.Error())
+		} else if got != provider {
+			t.Errorf("Expected %v, got: %v", provider, got)
+		}
+	}
+	if _, err := objectstore.ProviderOfSecret(secret.SSH); err == nil {
+		t.Errorf("Expected error, but not got error!")
+	}
+}
@@ -0,0 +1,352 @@
+// Package pagination pages, filters and sorts the lists of the API by the limit, cursor, sort and filter query
+// parameters. The cursor is an opaque token of the position after the last item of the previous page, so the
+// pages don't shift when the items of the previous pages are created or deleted.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/viper"
+)
+
+// The headers of the cursor of the next page
+const (
+	NextCursorHeader = "X-Next-Cursor"
+	linkHeader       = "Link"
+)
+
+// idColumn is the column of the IDs of the items in the database, it breaks the ties of the sort
+const idColumn = "id"
+
+//Field is a field of the listed items, Column is its column in the database
+type Field struct {
+	Column string
+	Sort   bool
+	Filter bool
+}
+
+//Options are the fields of a list and its default sort (a field name, with a - prefix for descending), the
+//limits override the configured limits of the pages
+type Options struct {
+	Fields       map[string]Field
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+//Params are the pagination parameters of a list request, a zero Limit lists every item
+type Params struct {
+	Limit   int
+	Sort    string
+	Desc    bool
+	Filters map[string][]string
+	fields  map[string]Field
+	cursor  *cursor
+}
+
+// cursor is the sort value and the ID of the last item of the previous page
+type cursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	Time  bool        `json:"t,omitempty"`
+	ID    interface{} `json:"id"`
+}
+
+//Item is an item of a list loaded without a database query, Fields are the values of its fields by their names
+type Item struct {
+	ID     string
+	Fields map[string]interface{}
+}
+
+//Parse parses the pagination parameters of the request: limit (at most the max limit), sort=field or
+//sort=-field, filter=field:value (the values of the same field are alternatives) and cursor. The v1 lists
+//list every item by default, the newer versions list a page of the default limit.
+func Parse(c *gin.Context, options Options) (*Params, error) {
+	params := &Params{Filters: map[string][]string{}, fields: options.Fields}
+
+	maxLimit := options.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = viper.GetInt("pagination.maxLimit")
+	}
+	params.Limit = options.DefaultLimit
+	if params.Limit == 0 && !apiversion.IsV1(c) {
+		params.Limit = viper.GetInt("pagination.defaultLimit")
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid limit: %q", value)
+		}
+		params.Limit = limit
+	}
+	if params.Limit > maxLimit {
+		return nil, fmt.Errorf("the limit must be at most %d", maxLimit)
+	}
+
+	sortBy := c.DefaultQuery("sort", options.DefaultSort)
+	params.Desc = strings.HasPrefix(sortBy, "-")
+	params.Sort = strings.TrimPrefix(sortBy, "-")
+	if field, ok := options.Fields[params.Sort]; params.Sort != "" && (!ok || !field.Sort) {
+		return nil, fmt.Errorf("the list can't be sorted by %q", params.Sort)
+	}
+
+	for _, filter := range c.QueryArray("filter") {
+		parts := strings.SplitN(filter, ":", 2)
+		if field, ok := options.Fields[parts[0]]; len(parts) != 2 || !ok || !field.Filter {
+			return nil, fmt.Errorf("invalid filter: %q", filter)
+		}
+		params.Filters[parts[0]] = append(params.Filters[parts[0]], parts[1])
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil || cursor.Sort != sortBy {
+			return nil, fmt.Errorf("invalid cursor: %q", value)
+		}
+		params.cursor = cursor
+	}
+	return params, nil
+}
+
+//HasCursor checks whether the request continues a previous page
+func (p *Params) HasCursor() bool {
+	return p.cursor != nil
+}
+
+//Query applies the filters, the sort, the cursor and the limit to the query of the list
+func (p *Params) Query(query *gorm.DB) *gorm.DB {
+	return p.Page(p.Filter(query))
+}
+
+//Filter applies the filters to the query of the list
+func (p *Params) Filter(query *gorm.DB) *gorm.DB {
+	for name, values := range p.Filters {
+		query = query.Where(p.fields[name].Column+" IN (?)", values)
+	}
+	return query
+}
+
+//Page applies the sort, the cursor and the limit to the query of the list, it loads one more item than the limit
+//to know whether there is a next page
+func (p *Params) Page(query *gorm.DB) *gorm.DB {
+	direction, operator := "asc", ">"
+	if p.Desc {
+		direction, operator = "desc", "<"
+	}
+	if p.Sort == "" {
+		if p.cursor != nil {
+			query = query.Where(idColumn+" "+operator+" ?", p.cursor.ID)
+		}
+		query = query.Order(idColumn + " " + direction)
+	} else {
+		column := p.fields[p.Sort].Column
+		if p.cursor != nil {
+			query = query.Where(fmt.Sprintf("%s %s ? OR (%s = ? AND %s %s ?)", column, operator, column, idColumn, operator),
+				p.cursor.Value, p.cursor.Value, p.cursor.ID)
+		}
+		query = query.Order(column + " " + direction).Order(idColumn + " " + direction)
+	}
+	if p.Limit > 0 {
+		query = query.Limit(p.Limit + 1)
+	}
+	return query
+}
+
+//Next returns the number of the loaded items of the page and the cursor of the next page, an empty cursor on the
+//last page, key returns the sort value and the ID of the ith loaded item
+func (p *Params) Next(loaded int, key func(i int) (value interface{}, id interface{})) (int, string) {
+	if p.Limit == 0 || loaded <= p.Limit {
+		return loaded, ""
+	}
+	value, id := key(p.Limit - 1)
+	return p.Limit, p.encodeCursor(value, id)
+}
+
+//Slice filters, sorts and pages the items of a list loaded without a database query, it returns the indexes of
+//the items of the page and the cursor of the next page
+func (p *Params) Slice(items []Item) ([]int, string) {
+	indexes := []int{}
+	for i, item := range items {
+		if p.matches(item) && (p.cursor == nil || p.after(item)) {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return p.less(items[indexes[i]], items[indexes[j]])
+	})
+	length, next := p.Next(len(indexes), func(i int) (interface{}, interface{}) {
+		item := items[indexes[i]]
+		return item.Fields[p.Sort], item.ID
+	})
+	return indexes[:length], next
+}
+
+//SetNext sets the headers of the cursor of the next page: the cursor and the Link of the next page
+func SetNext(c *gin.Context, next string) {
+	if next == "" {
+		return
+	}
+	c.Header(NextCursorHeader, next)
+	query := c.Request.URL.Query()
+	query.Set("cursor", next)
+	link := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+	c.Writer.Header().Add(linkHeader, "<"+link.String()+`>; rel="next"`)
+}
+
+// matches checks whether the item matches the filters
+func (p *Params) matches(item Item) bool {
+	for name, values := range p.Filters {
+		matched := false
+		for _, value := range values {
+			if fmt.Sprint(item.Fields[name]) == value {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// after checks whether the item comes after the cursor
+func (p *Params) after(item Item) bool {
+	last := Item{ID: fmt.Sprint(p.cursor.ID), Fields: map[string]interface{}{p.Sort: p.cursor.Value}}
+	return p.less(last, item)
+}
+
+// less compares the items by the sort field and by their IDs
+func (p *Params) less(a, b Item) bool {
+	order := 0
+	if p.Sort != "" {
+		order = compare(a.Fields[p.Sort], b.Fields[p.Sort])
+	}
+	if order == 0 {
+		order = strings.Compare(a.ID, b.ID)
+	}
+	if p.Desc {
+		return order > 0
+	}
+	return order < 0
+}
+
+// compare compares the values of a field, the missing values come first
+func compare(a, b interface{}) int {
+	a, b = dereference(a), dereference(b)
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	switch a := a.(type) {
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	case int, int32, int64, uint, uint64, float64:
+		x, _ := strconv.ParseFloat(fmt.Sprint(a), 64)
+		y, _ := strconv.ParseFloat(fmt.Sprint(b), 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// dereference returns the time of a time pointer, nil if it's not set
+func dereference(value interface{}) interface{} {
+	if t, ok := value.(*time.Time); ok {
+		if t == nil {
+			return nil
+		}
+		return *t
+	}
+	return value
+}
+
+// encodeCursor encodes the cursor of the position after the item of the value and the ID
+func (p *Params) encodeCursor(value, id interface{}) string {
+	sortBy := p.Sort
+	if p.Desc {
+		sortBy = "-" + sortBy
+	}
+	c := cursor{Sort: sortBy, Value: dereference(value), ID: id}
+	if t, ok := c.Value.(time.Time); ok {
+		c.Value, c.Time = t.UTC().Format(time.RFC3339Nano), true
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor, the times and the numbers get back their types
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var c cursor
+	if err := decoder.Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.ID == nil {
+		return nil, fmt.Errorf("missing ID")
+	}
+	c.ID = number(c.ID)
+	c.Value = number(c.Value)
+	if text, ok := c.Value.(string); ok && c.Time {
+		if c.Value, err = time.Parse(time.RFC3339Nano, text); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// number converts the JSON numbers to integers or floats
+func number(value interface{}) interface{} {
+	n, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+//BadRequest responds the error of the pagination parameters
+func BadRequest(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, components.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: "Invalid pagination parameters",
+		Error:   err.Error(),
+	})
+}
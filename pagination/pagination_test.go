@@ -0,0 +1,144 @@
+package pagination_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/apiversion"
+	"github.com/banzaicloud/pipeline/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+var testOptions = pagination.Options{
+	Fields: map[string]pagination.Field{
+		"name":      {Sort: true, Filter: true},
+		"createdAt": {Sort: true},
+		"cloud":     {Filter: true},
+	},
+}
+
+// testRouter lists the IDs of the items of the page of the request
+func testRouter(items []pagination.Item) http.Handler {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/clusters", func(c *gin.Context) {
+		page, err := pagination.Parse(c, testOptions)
+		if err != nil {
+			pagination.BadRequest(c, err)
+			return
+		}
+		indexes, next := page.Slice(items)
+		pagination.SetNext(c, next)
+		ids := []string{}
+		for _, i := range indexes {
+			ids = append(ids, items[i].ID)
+		}
+		c.JSON(http.StatusOK, ids)
+	})
+	return apiversion.Handler(router, apiversion.V2)
+}
+
+func TestSlice(t *testing.T) {
+
+	viper.Set("pagination.defaultLimit", 2)
+	viper.Set("pagination.maxLimit", 10)
+	created := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	items := []pagination.Item{}
+	for i, name := range []string{"d", "b", "a", "c", "e"} {
+		items = append(items, pagination.Item{ID: string('1' + byte(i)), Fields: map[string]interface{}{
+			"name":      name,
+			"createdAt": created.Add(time.Duration(i) * time.Hour),
+			"cloud":     []string{"amazon", "google"}[i%2],
+		}})
+	}
+	router := testRouter(items)
+
+	cases := []struct {
+		name          string
+		path          string
+		expectedPages [][]string
+	}{
+		{name: "v1 lists every item", path: "/api/v1/clusters", expectedPages: [][]string{{"1", "2", "3", "4", "5"}}},
+		{name: "v2 default limit", path: "/api/v2/clusters", expectedPages: [][]string{{"1", "2"}, {"3", "4"}, {"5"}}},
+		{name: "sort by name", path: "/api/v1/clusters?sort=name&limit=3", expectedPages: [][]string{{"3", "2", "4"}, {"1", "5"}}},
+		{name: "newest first", path: "/api/v1/clusters?sort=-createdAt&limit=2", expectedPages: [][]string{{"5", "4"}, {"3", "2"}, {"1"}}},
+		{name: "filter", path: "/api/v2/clusters?filter=cloud:amazon&filter=name:a&filter=name:e&sort=-name", expectedPages: [][]string{{"5", "3"}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pages := [][]string{}
+			path := tc.path
+			for path != "" {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("Expected %v, got: %v %v", http.StatusOK, recorder.Code, recorder.Body.String())
+				}
+				var ids []string
+				if err := json.Unmarshal(recorder.Body.Bytes(), &ids); err != nil {
+					t.Fatalf("Error during parsing response: %s", err.Error())
+				}
+				pages = append(pages, ids)
+				path = ""
+				if next := recorder.Header().Get(pagination.NextCursorHeader); next != "" {
+					if !strings.Contains(recorder.Header().Get("Link"), url.QueryEscape(next)) {
+						t.Errorf("Expected the Link of the next page, got: %v", recorder.Header().Get("Link"))
+					}
+					path = tc.path + "&cursor=" + next
+					if !strings.Contains(tc.path, "?") {
+						path = tc.path + "?cursor=" + next
+					}
+				}
+				if len(pages) > len(tc.expectedPages) {
+					break
+				}
+			}
+			if !reflect.DeepEqual(pages, tc.expectedPages) {
+				t.Errorf("Expected %v, got: %v", tc.expectedPages, pages)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+
+	viper.Set("pagination.maxLimit", 10)
+	router := testRouter([]pagination.Item{{ID: "1"}, {ID: "2"}})
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/clusters?sort=name&limit=1", nil))
+	cursor := recorder.Header().Get(pagination.NextCursorHeader)
+	if cursor == "" {
+		t.Fatalf("Expected the cursor of the next page, got: %v", recorder.Header())
+	}
+
+	cases := []struct {
+		name  string
+		query string
+	}{
+		{name: "invalid limit", query: "limit=0"},
+		{name: "limit too high", query: "limit=11"},
+		{name: "unknown sort", query: "sort=status"},
+		{name: "filter of a sort field", query: "filter=createdAt:2018"},
+		{name: "filter without value", query: "filter=name"},
+		{name: "invalid cursor", query: "cursor=abc"},
+		{name: "cursor of another sort", query: "sort=-name&cursor=" + cursor},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/clusters?"+tc.query, nil))
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected %v, got: %v", http.StatusBadRequest, recorder.Code)
+			}
+		})
+	}
+}
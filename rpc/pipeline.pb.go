@@ -0,0 +1,1237 @@
+// Code generated by protoc-gen-go.
+// source: pipeline.proto
+// DO NOT EDIT!
+
+/*
+Package rpc is a generated protocol buffer package.
+
+It is generated from these files:
+
+	pipeline.proto
+
+It has these top-level messages:
+
+	Cluster
+	ClusterStep
+	ClusterStatus
+	ListClustersRequest
+	ListClustersResponse
+	GetClusterRequest
+	CreateClusterRequest
+	DeleteClusterRequest
+	DeleteClusterResponse
+	WatchClusterRequest
+	Deployment
+	ListDeploymentsRequest
+	ListDeploymentsResponse
+	CreateDeploymentRequest
+	CreateDeploymentResponse
+	DeleteDeploymentRequest
+	DeleteDeploymentResponse
+	Token
+	ListTokensRequest
+	ListTokensResponse
+	CreateTokenRequest
+	CreateTokenResponse
+	DeleteTokenRequest
+	DeleteTokenResponse
+	Secret
+	ListSecretsRequest
+	ListSecretsResponse
+	CreateSecretRequest
+	DeleteSecretRequest
+	DeleteSecretResponse
+*/
+package rpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/duration"
+import google_protobuf1 "github.com/golang/protobuf/ptypes/timestamp"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Cluster struct {
+	Id               uint32 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Name             string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Cloud            string `protobuf:"bytes,3,opt,name=cloud" json:"cloud,omitempty"`
+	Location         string `protobuf:"bytes,4,opt,name=location" json:"location,omitempty"`
+	NodeInstanceType string `protobuf:"bytes,5,opt,name=node_instance_type,json=nodeInstanceType" json:"node_instance_type,omitempty"`
+}
+
+func (m *Cluster) Reset()                    { *m = Cluster{} }
+func (m *Cluster) String() string            { return proto.CompactTextString(m) }
+func (*Cluster) ProtoMessage()               {}
+func (*Cluster) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type ClusterStep struct {
+	Name       string                      `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Status     string                      `protobuf:"bytes,2,opt,name=status" json:"status,omitempty"`
+	Error      string                      `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	StartedAt  *google_protobuf1.Timestamp `protobuf:"bytes,4,opt,name=started_at,json=startedAt" json:"started_at,omitempty"`
+	FinishedAt *google_protobuf1.Timestamp `protobuf:"bytes,5,opt,name=finished_at,json=finishedAt" json:"finished_at,omitempty"`
+}
+
+func (m *ClusterStep) Reset()                    { *m = ClusterStep{} }
+func (m *ClusterStep) String() string            { return proto.CompactTextString(m) }
+func (*ClusterStep) ProtoMessage()               {}
+func (*ClusterStep) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *ClusterStep) GetStartedAt() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.StartedAt
+	}
+	return nil
+}
+
+func (m *ClusterStep) GetFinishedAt() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.FinishedAt
+	}
+	return nil
+}
+
+type ClusterStatus struct {
+	Id            uint32         `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Name          string         `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Status        string         `protobuf:"bytes,3,opt,name=status" json:"status,omitempty"`
+	StatusMessage string         `protobuf:"bytes,4,opt,name=status_message,json=statusMessage" json:"status_message,omitempty"`
+	Steps         []*ClusterStep `protobuf:"bytes,5,rep,name=steps" json:"steps,omitempty"`
+}
+
+func (m *ClusterStatus) Reset()                    { *m = ClusterStatus{} }
+func (m *ClusterStatus) String() string            { return proto.CompactTextString(m) }
+func (*ClusterStatus) ProtoMessage()               {}
+func (*ClusterStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *ClusterStatus) GetSteps() []*ClusterStep {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+type ListClustersRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	// the clusters are filtered with key or key:value tags
+	Tags []string `protobuf:"bytes,2,rep,name=tags" json:"tags,omitempty"`
+}
+
+func (m *ListClustersRequest) Reset()                    { *m = ListClustersRequest{} }
+func (m *ListClustersRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListClustersRequest) ProtoMessage()               {}
+func (*ListClustersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+type ListClustersResponse struct {
+	Clusters []*Cluster `protobuf:"bytes,1,rep,name=clusters" json:"clusters,omitempty"`
+}
+
+func (m *ListClustersResponse) Reset()                    { *m = ListClustersResponse{} }
+func (m *ListClustersResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListClustersResponse) ProtoMessage()               {}
+func (*ListClustersResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ListClustersResponse) GetClusters() []*Cluster {
+	if m != nil {
+		return m.Clusters
+	}
+	return nil
+}
+
+type GetClusterRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	ClusterId      uint32 `protobuf:"varint,2,opt,name=cluster_id,json=clusterId" json:"cluster_id,omitempty"`
+}
+
+func (m *GetClusterRequest) Reset()                    { *m = GetClusterRequest{} }
+func (m *GetClusterRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetClusterRequest) ProtoMessage()               {}
+func (*GetClusterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type CreateClusterRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	// the JSON create cluster request of the REST API, its properties depend on the cloud
+	Request []byte `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+}
+
+func (m *CreateClusterRequest) Reset()                    { *m = CreateClusterRequest{} }
+func (m *CreateClusterRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateClusterRequest) ProtoMessage()               {}
+func (*CreateClusterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type DeleteClusterRequest struct {
+	OrganizationId    uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	ClusterId         uint32 `protobuf:"varint,2,opt,name=cluster_id,json=clusterId" json:"cluster_id,omitempty"`
+	ConfirmationToken string `protobuf:"bytes,3,opt,name=confirmation_token,json=confirmationToken" json:"confirmation_token,omitempty"`
+	Force             bool   `protobuf:"varint,4,opt,name=force" json:"force,omitempty"`
+	Drain             bool   `protobuf:"varint,5,opt,name=drain" json:"drain,omitempty"`
+}
+
+func (m *DeleteClusterRequest) Reset()                    { *m = DeleteClusterRequest{} }
+func (m *DeleteClusterRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteClusterRequest) ProtoMessage()               {}
+func (*DeleteClusterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+type DeleteClusterResponse struct {
+	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+	// the token confirming the deletion, it's only set without a token in the request
+	ConfirmationToken string                      `protobuf:"bytes,2,opt,name=confirmation_token,json=confirmationToken" json:"confirmation_token,omitempty"`
+	ExpiresAt         *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt" json:"expires_at,omitempty"`
+}
+
+func (m *DeleteClusterResponse) Reset()                    { *m = DeleteClusterResponse{} }
+func (m *DeleteClusterResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteClusterResponse) ProtoMessage()               {}
+func (*DeleteClusterResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *DeleteClusterResponse) GetExpiresAt() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+type WatchClusterRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	ClusterId      uint32 `protobuf:"varint,2,opt,name=cluster_id,json=clusterId" json:"cluster_id,omitempty"`
+	UntilDone      bool   `protobuf:"varint,3,opt,name=until_done,json=untilDone" json:"until_done,omitempty"`
+}
+
+func (m *WatchClusterRequest) Reset()                    { *m = WatchClusterRequest{} }
+func (m *WatchClusterRequest) String() string            { return proto.CompactTextString(m) }
+func (*WatchClusterRequest) ProtoMessage()               {}
+func (*WatchClusterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+type Deployment struct {
+	ReleaseName string `protobuf:"bytes,1,opt,name=release_name,json=releaseName" json:"release_name,omitempty"`
+	Chart       string `protobuf:"bytes,2,opt,name=chart" json:"chart,omitempty"`
+	Version     int32  `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	Updated     string `protobuf:"bytes,4,opt,name=updated" json:"updated,omitempty"`
+	Status      string `protobuf:"bytes,5,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *Deployment) Reset()                    { *m = Deployment{} }
+func (m *Deployment) String() string            { return proto.CompactTextString(m) }
+func (*Deployment) ProtoMessage()               {}
+func (*Deployment) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type ListDeploymentsRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	ClusterId      uint32 `protobuf:"varint,2,opt,name=cluster_id,json=clusterId" json:"cluster_id,omitempty"`
+}
+
+func (m *ListDeploymentsRequest) Reset()                    { *m = ListDeploymentsRequest{} }
+func (m *ListDeploymentsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListDeploymentsRequest) ProtoMessage()               {}
+func (*ListDeploymentsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+type ListDeploymentsResponse struct {
+	Deployments []*Deployment `protobuf:"bytes,1,rep,name=deployments" json:"deployments,omitempty"`
+}
+
+func (m *ListDeploymentsResponse) Reset()                    { *m = ListDeploymentsResponse{} }
+func (m *ListDeploymentsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListDeploymentsResponse) ProtoMessage()               {}
+func (*ListDeploymentsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *ListDeploymentsResponse) GetDeployments() []*Deployment {
+	if m != nil {
+		return m.Deployments
+	}
+	return nil
+}
+
+type CreateDeploymentRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	ClusterId      uint32 `protobuf:"varint,2,opt,name=cluster_id,json=clusterId" json:"cluster_id,omitempty"`
+	Chart          string `protobuf:"bytes,3,opt,name=chart" json:"chart,omitempty"`
+	ReleaseName    string `protobuf:"bytes,4,opt,name=release_name,json=releaseName" json:"release_name,omitempty"`
+	Version        string `protobuf:"bytes,5,opt,name=version" json:"version,omitempty"`
+	// the JSON object of the values of the chart
+	Values []byte `protobuf:"bytes,6,opt,name=values,proto3" json:"values,omitempty"`
+}
+
+func (m *CreateDeploymentRequest) Reset()                    { *m = CreateDeploymentRequest{} }
+func (m *CreateDeploymentRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateDeploymentRequest) ProtoMessage()               {}
+func (*CreateDeploymentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+type CreateDeploymentResponse struct {
+	ReleaseName string `protobuf:"bytes,1,opt,name=release_name,json=releaseName" json:"release_name,omitempty"`
+	Notes       string `protobuf:"bytes,2,opt,name=notes" json:"notes,omitempty"`
+	// the deployment of the protected clusters waits for an approval, the scheduled deployments for their time
+	Pending bool `protobuf:"varint,3,opt,name=pending" json:"pending,omitempty"`
+}
+
+func (m *CreateDeploymentResponse) Reset()                    { *m = CreateDeploymentResponse{} }
+func (m *CreateDeploymentResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateDeploymentResponse) ProtoMessage()               {}
+func (*CreateDeploymentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+type DeleteDeploymentRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	ClusterId      uint32 `protobuf:"varint,2,opt,name=cluster_id,json=clusterId" json:"cluster_id,omitempty"`
+	ReleaseName    string `protobuf:"bytes,3,opt,name=release_name,json=releaseName" json:"release_name,omitempty"`
+}
+
+func (m *DeleteDeploymentRequest) Reset()                    { *m = DeleteDeploymentRequest{} }
+func (m *DeleteDeploymentRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteDeploymentRequest) ProtoMessage()               {}
+func (*DeleteDeploymentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+type DeleteDeploymentResponse struct {
+	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *DeleteDeploymentResponse) Reset()                    { *m = DeleteDeploymentResponse{} }
+func (m *DeleteDeploymentResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteDeploymentResponse) ProtoMessage()               {}
+func (*DeleteDeploymentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+type Token struct {
+	Id           string                      `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name         string                      `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	CreatedAt    *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+	ExpiresAt    *google_protobuf1.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt" json:"expires_at,omitempty"`
+	Scopes       []string                    `protobuf:"bytes,5,rep,name=scopes" json:"scopes,omitempty"`
+	AllowedCidrs []string                    `protobuf:"bytes,6,rep,name=allowed_cidrs,json=allowedCidrs" json:"allowed_cidrs,omitempty"`
+}
+
+func (m *Token) Reset()                    { *m = Token{} }
+func (m *Token) String() string            { return proto.CompactTextString(m) }
+func (*Token) ProtoMessage()               {}
+func (*Token) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *Token) GetCreatedAt() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+func (m *Token) GetExpiresAt() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+type ListTokensRequest struct {
+}
+
+func (m *ListTokensRequest) Reset()                    { *m = ListTokensRequest{} }
+func (m *ListTokensRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListTokensRequest) ProtoMessage()               {}
+func (*ListTokensRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+type ListTokensResponse struct {
+	Tokens []*Token `protobuf:"bytes,1,rep,name=tokens" json:"tokens,omitempty"`
+}
+
+func (m *ListTokensResponse) Reset()                    { *m = ListTokensResponse{} }
+func (m *ListTokensResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListTokensResponse) ProtoMessage()               {}
+func (*ListTokensResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *ListTokensResponse) GetTokens() []*Token {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+type CreateTokenRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// the token never expires without a ttl
+	Ttl          *google_protobuf.Duration `protobuf:"bytes,2,opt,name=ttl" json:"ttl,omitempty"`
+	Scopes       []string                  `protobuf:"bytes,3,rep,name=scopes" json:"scopes,omitempty"`
+	AllowedCidrs []string                  `protobuf:"bytes,4,rep,name=allowed_cidrs,json=allowedCidrs" json:"allowed_cidrs,omitempty"`
+}
+
+func (m *CreateTokenRequest) Reset()                    { *m = CreateTokenRequest{} }
+func (m *CreateTokenRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateTokenRequest) ProtoMessage()               {}
+func (*CreateTokenRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *CreateTokenRequest) GetTtl() *google_protobuf.Duration {
+	if m != nil {
+		return m.Ttl
+	}
+	return nil
+}
+
+type CreateTokenResponse struct {
+	Id    string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Token string `protobuf:"bytes,2,opt,name=token" json:"token,omitempty"`
+}
+
+func (m *CreateTokenResponse) Reset()                    { *m = CreateTokenResponse{} }
+func (m *CreateTokenResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateTokenResponse) ProtoMessage()               {}
+func (*CreateTokenResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+type DeleteTokenRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *DeleteTokenRequest) Reset()                    { *m = DeleteTokenRequest{} }
+func (m *DeleteTokenRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteTokenRequest) ProtoMessage()               {}
+func (*DeleteTokenRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+type DeleteTokenResponse struct {
+}
+
+func (m *DeleteTokenResponse) Reset()                    { *m = DeleteTokenResponse{} }
+func (m *DeleteTokenResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteTokenResponse) ProtoMessage()               {}
+func (*DeleteTokenResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+type Secret struct {
+	Id            string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name          string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Type          string `protobuf:"bytes,3,opt,name=type" json:"type,omitempty"`
+	SharedBy      string `protobuf:"bytes,4,opt,name=shared_by,json=sharedBy" json:"shared_by,omitempty"`
+	Version       int32  `protobuf:"varint,5,opt,name=version" json:"version,omitempty"`
+	Status        string `protobuf:"bytes,6,opt,name=status" json:"status,omitempty"`
+	StatusMessage string `protobuf:"bytes,7,opt,name=status_message,json=statusMessage" json:"status_message,omitempty"`
+}
+
+func (m *Secret) Reset()                    { *m = Secret{} }
+func (m *Secret) String() string            { return proto.CompactTextString(m) }
+func (*Secret) ProtoMessage()               {}
+func (*Secret) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+type ListSecretsRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	Type           string `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+}
+
+func (m *ListSecretsRequest) Reset()                    { *m = ListSecretsRequest{} }
+func (m *ListSecretsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListSecretsRequest) ProtoMessage()               {}
+func (*ListSecretsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+type ListSecretsResponse struct {
+	Secrets []*Secret `protobuf:"bytes,1,rep,name=secrets" json:"secrets,omitempty"`
+}
+
+func (m *ListSecretsResponse) Reset()                    { *m = ListSecretsResponse{} }
+func (m *ListSecretsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListSecretsResponse) ProtoMessage()               {}
+func (*ListSecretsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *ListSecretsResponse) GetSecrets() []*Secret {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+type CreateSecretRequest struct {
+	OrganizationId uint32            `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	Name           string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Type           string            `protobuf:"bytes,3,opt,name=type" json:"type,omitempty"`
+	Values         map[string]string `protobuf:"bytes,4,rep,name=values" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CreateSecretRequest) Reset()                    { *m = CreateSecretRequest{} }
+func (m *CreateSecretRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateSecretRequest) ProtoMessage()               {}
+func (*CreateSecretRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *CreateSecretRequest) GetValues() map[string]string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+type DeleteSecretRequest struct {
+	OrganizationId uint32 `protobuf:"varint,1,opt,name=organization_id,json=organizationId" json:"organization_id,omitempty"`
+	SecretId       string `protobuf:"bytes,2,opt,name=secret_id,json=secretId" json:"secret_id,omitempty"`
+}
+
+func (m *DeleteSecretRequest) Reset()                    { *m = DeleteSecretRequest{} }
+func (m *DeleteSecretRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteSecretRequest) ProtoMessage()               {}
+func (*DeleteSecretRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+type DeleteSecretResponse struct {
+}
+
+func (m *DeleteSecretResponse) Reset()                    { *m = DeleteSecretResponse{} }
+func (m *DeleteSecretResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteSecretResponse) ProtoMessage()               {}
+func (*DeleteSecretResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func init() {
+	proto.RegisterType((*Cluster)(nil), "pipeline.v1.Cluster")
+	proto.RegisterType((*ClusterStep)(nil), "pipeline.v1.ClusterStep")
+	proto.RegisterType((*ClusterStatus)(nil), "pipeline.v1.ClusterStatus")
+	proto.RegisterType((*ListClustersRequest)(nil), "pipeline.v1.ListClustersRequest")
+	proto.RegisterType((*ListClustersResponse)(nil), "pipeline.v1.ListClustersResponse")
+	proto.RegisterType((*GetClusterRequest)(nil), "pipeline.v1.GetClusterRequest")
+	proto.RegisterType((*CreateClusterRequest)(nil), "pipeline.v1.CreateClusterRequest")
+	proto.RegisterType((*DeleteClusterRequest)(nil), "pipeline.v1.DeleteClusterRequest")
+	proto.RegisterType((*DeleteClusterResponse)(nil), "pipeline.v1.DeleteClusterResponse")
+	proto.RegisterType((*WatchClusterRequest)(nil), "pipeline.v1.WatchClusterRequest")
+	proto.RegisterType((*Deployment)(nil), "pipeline.v1.Deployment")
+	proto.RegisterType((*ListDeploymentsRequest)(nil), "pipeline.v1.ListDeploymentsRequest")
+	proto.RegisterType((*ListDeploymentsResponse)(nil), "pipeline.v1.ListDeploymentsResponse")
+	proto.RegisterType((*CreateDeploymentRequest)(nil), "pipeline.v1.CreateDeploymentRequest")
+	proto.RegisterType((*CreateDeploymentResponse)(nil), "pipeline.v1.CreateDeploymentResponse")
+	proto.RegisterType((*DeleteDeploymentRequest)(nil), "pipeline.v1.DeleteDeploymentRequest")
+	proto.RegisterType((*DeleteDeploymentResponse)(nil), "pipeline.v1.DeleteDeploymentResponse")
+	proto.RegisterType((*Token)(nil), "pipeline.v1.Token")
+	proto.RegisterType((*ListTokensRequest)(nil), "pipeline.v1.ListTokensRequest")
+	proto.RegisterType((*ListTokensResponse)(nil), "pipeline.v1.ListTokensResponse")
+	proto.RegisterType((*CreateTokenRequest)(nil), "pipeline.v1.CreateTokenRequest")
+	proto.RegisterType((*CreateTokenResponse)(nil), "pipeline.v1.CreateTokenResponse")
+	proto.RegisterType((*DeleteTokenRequest)(nil), "pipeline.v1.DeleteTokenRequest")
+	proto.RegisterType((*DeleteTokenResponse)(nil), "pipeline.v1.DeleteTokenResponse")
+	proto.RegisterType((*Secret)(nil), "pipeline.v1.Secret")
+	proto.RegisterType((*ListSecretsRequest)(nil), "pipeline.v1.ListSecretsRequest")
+	proto.RegisterType((*ListSecretsResponse)(nil), "pipeline.v1.ListSecretsResponse")
+	proto.RegisterType((*CreateSecretRequest)(nil), "pipeline.v1.CreateSecretRequest")
+	proto.RegisterType((*DeleteSecretRequest)(nil), "pipeline.v1.DeleteSecretRequest")
+	proto.RegisterType((*DeleteSecretResponse)(nil), "pipeline.v1.DeleteSecretResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Clusters service
+
+type ClustersClient interface {
+	ListClusters(ctx context.Context, in *ListClustersRequest, opts ...grpc.CallOption) (*ListClustersResponse, error)
+	GetCluster(ctx context.Context, in *GetClusterRequest, opts ...grpc.CallOption) (*Cluster, error)
+	// CreateCluster starts the creation of the cluster, WatchCluster follows its progress
+	CreateCluster(ctx context.Context, in *CreateClusterRequest, opts ...grpc.CallOption) (*ClusterStatus, error)
+	// DeleteCluster returns a confirmation token without one, the deletion starts with the token
+	DeleteCluster(ctx context.Context, in *DeleteClusterRequest, opts ...grpc.CallOption) (*DeleteClusterResponse, error)
+	// WatchCluster streams the status of the cluster when it changes, the stream ends when the cluster is
+	// deleted (or its operation is done with until_done)
+	WatchCluster(ctx context.Context, in *WatchClusterRequest, opts ...grpc.CallOption) (Clusters_WatchClusterClient, error)
+}
+
+type clustersClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewClustersClient(cc *grpc.ClientConn) ClustersClient {
+	return &clustersClient{cc}
+}
+
+func (c *clustersClient) ListClusters(ctx context.Context, in *ListClustersRequest, opts ...grpc.CallOption) (*ListClustersResponse, error) {
+	out := new(ListClustersResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Clusters/ListClusters", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clustersClient) GetCluster(ctx context.Context, in *GetClusterRequest, opts ...grpc.CallOption) (*Cluster, error) {
+	out := new(Cluster)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Clusters/GetCluster", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clustersClient) CreateCluster(ctx context.Context, in *CreateClusterRequest, opts ...grpc.CallOption) (*ClusterStatus, error) {
+	out := new(ClusterStatus)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Clusters/CreateCluster", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clustersClient) DeleteCluster(ctx context.Context, in *DeleteClusterRequest, opts ...grpc.CallOption) (*DeleteClusterResponse, error) {
+	out := new(DeleteClusterResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Clusters/DeleteCluster", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clustersClient) WatchCluster(ctx context.Context, in *WatchClusterRequest, opts ...grpc.CallOption) (Clusters_WatchClusterClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Clusters_serviceDesc.Streams[0], c.cc, "/pipeline.v1.Clusters/WatchCluster", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clustersWatchClusterClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Clusters_WatchClusterClient interface {
+	Recv() (*ClusterStatus, error)
+	grpc.ClientStream
+}
+
+type clustersWatchClusterClient struct {
+	grpc.ClientStream
+}
+
+func (x *clustersWatchClusterClient) Recv() (*ClusterStatus, error) {
+	m := new(ClusterStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Clusters service
+
+type ClustersServer interface {
+	ListClusters(context.Context, *ListClustersRequest) (*ListClustersResponse, error)
+	GetCluster(context.Context, *GetClusterRequest) (*Cluster, error)
+	// CreateCluster starts the creation of the cluster, WatchCluster follows its progress
+	CreateCluster(context.Context, *CreateClusterRequest) (*ClusterStatus, error)
+	// DeleteCluster returns a confirmation token without one, the deletion starts with the token
+	DeleteCluster(context.Context, *DeleteClusterRequest) (*DeleteClusterResponse, error)
+	// WatchCluster streams the status of the cluster when it changes, the stream ends when the cluster is
+	// deleted (or its operation is done with until_done)
+	WatchCluster(*WatchClusterRequest, Clusters_WatchClusterServer) error
+}
+
+func RegisterClustersServer(s *grpc.Server, srv ClustersServer) {
+	s.RegisterService(&_Clusters_serviceDesc, srv)
+}
+
+func _Clusters_ListClusters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClustersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClustersServer).ListClusters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Clusters/ListClusters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClustersServer).ListClusters(ctx, req.(*ListClustersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clusters_GetCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClustersServer).GetCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Clusters/GetCluster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClustersServer).GetCluster(ctx, req.(*GetClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clusters_CreateCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClustersServer).CreateCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Clusters/CreateCluster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClustersServer).CreateCluster(ctx, req.(*CreateClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clusters_DeleteCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClustersServer).DeleteCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Clusters/DeleteCluster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClustersServer).DeleteCluster(ctx, req.(*DeleteClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clusters_WatchCluster_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchClusterRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClustersServer).WatchCluster(m, &clustersWatchClusterServer{stream})
+}
+
+type Clusters_WatchClusterServer interface {
+	Send(*ClusterStatus) error
+	grpc.ServerStream
+}
+
+type clustersWatchClusterServer struct {
+	grpc.ServerStream
+}
+
+func (x *clustersWatchClusterServer) Send(m *ClusterStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Clusters_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pipeline.v1.Clusters",
+	HandlerType: (*ClustersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListClusters",
+			Handler:    _Clusters_ListClusters_Handler,
+		},
+		{
+			MethodName: "GetCluster",
+			Handler:    _Clusters_GetCluster_Handler,
+		},
+		{
+			MethodName: "CreateCluster",
+			Handler:    _Clusters_CreateCluster_Handler,
+		},
+		{
+			MethodName: "DeleteCluster",
+			Handler:    _Clusters_DeleteCluster_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCluster",
+			Handler:       _Clusters_WatchCluster_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pipeline.proto",
+}
+
+// Client API for Deployments service
+
+type DeploymentsClient interface {
+	ListDeployments(ctx context.Context, in *ListDeploymentsRequest, opts ...grpc.CallOption) (*ListDeploymentsResponse, error)
+	CreateDeployment(ctx context.Context, in *CreateDeploymentRequest, opts ...grpc.CallOption) (*CreateDeploymentResponse, error)
+	DeleteDeployment(ctx context.Context, in *DeleteDeploymentRequest, opts ...grpc.CallOption) (*DeleteDeploymentResponse, error)
+}
+
+type deploymentsClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewDeploymentsClient(cc *grpc.ClientConn) DeploymentsClient {
+	return &deploymentsClient{cc}
+}
+
+func (c *deploymentsClient) ListDeployments(ctx context.Context, in *ListDeploymentsRequest, opts ...grpc.CallOption) (*ListDeploymentsResponse, error) {
+	out := new(ListDeploymentsResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Deployments/ListDeployments", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deploymentsClient) CreateDeployment(ctx context.Context, in *CreateDeploymentRequest, opts ...grpc.CallOption) (*CreateDeploymentResponse, error) {
+	out := new(CreateDeploymentResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Deployments/CreateDeployment", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deploymentsClient) DeleteDeployment(ctx context.Context, in *DeleteDeploymentRequest, opts ...grpc.CallOption) (*DeleteDeploymentResponse, error) {
+	out := new(DeleteDeploymentResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Deployments/DeleteDeployment", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Deployments service
+
+type DeploymentsServer interface {
+	ListDeployments(context.Context, *ListDeploymentsRequest) (*ListDeploymentsResponse, error)
+	CreateDeployment(context.Context, *CreateDeploymentRequest) (*CreateDeploymentResponse, error)
+	DeleteDeployment(context.Context, *DeleteDeploymentRequest) (*DeleteDeploymentResponse, error)
+}
+
+func RegisterDeploymentsServer(s *grpc.Server, srv DeploymentsServer) {
+	s.RegisterService(&_Deployments_serviceDesc, srv)
+}
+
+func _Deployments_ListDeployments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeploymentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeploymentsServer).ListDeployments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Deployments/ListDeployments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeploymentsServer).ListDeployments(ctx, req.(*ListDeploymentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Deployments_CreateDeployment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDeploymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeploymentsServer).CreateDeployment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Deployments/CreateDeployment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeploymentsServer).CreateDeployment(ctx, req.(*CreateDeploymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Deployments_DeleteDeployment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteDeploymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeploymentsServer).DeleteDeployment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Deployments/DeleteDeployment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeploymentsServer).DeleteDeployment(ctx, req.(*DeleteDeploymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Deployments_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pipeline.v1.Deployments",
+	HandlerType: (*DeploymentsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDeployments",
+			Handler:    _Deployments_ListDeployments_Handler,
+		},
+		{
+			MethodName: "CreateDeployment",
+			Handler:    _Deployments_CreateDeployment_Handler,
+		},
+		{
+			MethodName: "DeleteDeployment",
+			Handler:    _Deployments_DeleteDeployment_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pipeline.proto",
+}
+
+// Client API for Tokens service
+
+type TokensClient interface {
+	ListTokens(ctx context.Context, in *ListTokensRequest, opts ...grpc.CallOption) (*ListTokensResponse, error)
+	CreateToken(ctx context.Context, in *CreateTokenRequest, opts ...grpc.CallOption) (*CreateTokenResponse, error)
+	DeleteToken(ctx context.Context, in *DeleteTokenRequest, opts ...grpc.CallOption) (*DeleteTokenResponse, error)
+}
+
+type tokensClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTokensClient(cc *grpc.ClientConn) TokensClient {
+	return &tokensClient{cc}
+}
+
+func (c *tokensClient) ListTokens(ctx context.Context, in *ListTokensRequest, opts ...grpc.CallOption) (*ListTokensResponse, error) {
+	out := new(ListTokensResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Tokens/ListTokens", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) CreateToken(ctx context.Context, in *CreateTokenRequest, opts ...grpc.CallOption) (*CreateTokenResponse, error) {
+	out := new(CreateTokenResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Tokens/CreateToken", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) DeleteToken(ctx context.Context, in *DeleteTokenRequest, opts ...grpc.CallOption) (*DeleteTokenResponse, error) {
+	out := new(DeleteTokenResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Tokens/DeleteToken", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Tokens service
+
+type TokensServer interface {
+	ListTokens(context.Context, *ListTokensRequest) (*ListTokensResponse, error)
+	CreateToken(context.Context, *CreateTokenRequest) (*CreateTokenResponse, error)
+	DeleteToken(context.Context, *DeleteTokenRequest) (*DeleteTokenResponse, error)
+}
+
+func RegisterTokensServer(s *grpc.Server, srv TokensServer) {
+	s.RegisterService(&_Tokens_serviceDesc, srv)
+}
+
+func _Tokens_ListTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).ListTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Tokens/ListTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).ListTokens(ctx, req.(*ListTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tokens_CreateToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).CreateToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Tokens/CreateToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).CreateToken(ctx, req.(*CreateTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tokens_DeleteToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).DeleteToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Tokens/DeleteToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).DeleteToken(ctx, req.(*DeleteTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Tokens_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pipeline.v1.Tokens",
+	HandlerType: (*TokensServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListTokens",
+			Handler:    _Tokens_ListTokens_Handler,
+		},
+		{
+			MethodName: "CreateToken",
+			Handler:    _Tokens_CreateToken_Handler,
+		},
+		{
+			MethodName: "DeleteToken",
+			Handler:    _Tokens_DeleteToken_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pipeline.proto",
+}
+
+// Client API for Secrets service
+
+type SecretsClient interface {
+	ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error)
+	CreateSecret(ctx context.Context, in *CreateSecretRequest, opts ...grpc.CallOption) (*Secret, error)
+	DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error)
+}
+
+type secretsClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSecretsClient(cc *grpc.ClientConn) SecretsClient {
+	return &secretsClient{cc}
+}
+
+func (c *secretsClient) ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error) {
+	out := new(ListSecretsResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Secrets/ListSecrets", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *secretsClient) CreateSecret(ctx context.Context, in *CreateSecretRequest, opts ...grpc.CallOption) (*Secret, error) {
+	out := new(Secret)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Secrets/CreateSecret", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *secretsClient) DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error) {
+	out := new(DeleteSecretResponse)
+	err := grpc.Invoke(ctx, "/pipeline.v1.Secrets/DeleteSecret", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Secrets service
+
+type SecretsServer interface {
+	ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error)
+	CreateSecret(context.Context, *CreateSecretRequest) (*Secret, error)
+	DeleteSecret(context.Context, *DeleteSecretRequest) (*DeleteSecretResponse, error)
+}
+
+func RegisterSecretsServer(s *grpc.Server, srv SecretsServer) {
+	s.RegisterService(&_Secrets_serviceDesc, srv)
+}
+
+func _Secrets_ListSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSecretsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecretsServer).ListSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Secrets/ListSecrets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecretsServer).ListSecrets(ctx, req.(*ListSecretsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Secrets_CreateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecretsServer).CreateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Secrets/CreateSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecretsServer).CreateSecret(ctx, req.(*CreateSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Secrets_DeleteSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecretsServer).DeleteSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pipeline.v1.Secrets/DeleteSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecretsServer).DeleteSecret(ctx, req.(*DeleteSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Secrets_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pipeline.v1.Secrets",
+	HandlerType: (*SecretsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSecrets",
+			Handler:    _Secrets_ListSecrets_Handler,
+		},
+		{
+			MethodName: "CreateSecret",
+			Handler:    _Secrets_CreateSecret_Handler,
+		},
+		{
+			MethodName: "DeleteSecret",
+			Handler:    _Secrets_DeleteSecret_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pipeline.proto",
+}
+
+func init() { proto.RegisterFile("pipeline.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 1380 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x57, 0x4d, 0x8f, 0xdb, 0xc4,
+	0x1b, 0x97, 0xe3, 0x4d, 0x76, 0xf3, 0x64, 0xb3, 0x6d, 0x27, 0x69, 0xd7, 0x7f, 0xff, 0xd5, 0x36,
+	0x3b, 0x6d, 0x45, 0x05, 0x6d, 0x5a, 0x16, 0x0e, 0x2c, 0xbd, 0xb0, 0x6d, 0x2a, 0x58, 0x89, 0x56,
+	0xe0, 0xae, 0x8a, 0xa0, 0x48, 0xc1, 0xb5, 0x67, 0xb3, 0x56, 0x1d, 0xdb, 0xd8, 0x93, 0x42, 0x10,
+	0x47, 0xae, 0x1c, 0xe0, 0xda, 0x1b, 0x77, 0xae, 0x48, 0x7c, 0x02, 0x2e, 0x7c, 0x06, 0xae, 0x7c,
+	0x04, 0x0e, 0x5c, 0xd0, 0xbc, 0x25, 0xe3, 0x97, 0x24, 0xdb, 0x55, 0x7b, 0xcb, 0xf3, 0xcc, 0x6f,
+	0xc6, 0xbf, 0xe7, 0xfd, 0x09, 0x6c, 0x25, 0x41, 0x42, 0xc2, 0x20, 0x22, 0xfd, 0x24, 0x8d, 0x69,
+	0x8c, 0x5a, 0x33, 0xf9, 0xf9, 0xdb, 0xf6, 0xa5, 0x51, 0x1c, 0x8f, 0x42, 0x72, 0x8b, 0x1f, 0x3d,
+	0x9d, 0x1c, 0xdd, 0xf2, 0x27, 0xa9, 0x4b, 0x83, 0x38, 0x12, 0x60, 0xfb, 0x72, 0xf1, 0x9c, 0x06,
+	0x63, 0x92, 0x51, 0x77, 0x9c, 0x08, 0x00, 0xfe, 0xd1, 0x80, 0xf5, 0x7b, 0xe1, 0x24, 0xa3, 0x24,
+	0x45, 0x5b, 0x50, 0x0b, 0x7c, 0xcb, 0xe8, 0x19, 0xd7, 0xdb, 0x4e, 0x2d, 0xf0, 0x11, 0x82, 0xb5,
+	0xc8, 0x1d, 0x13, 0xab, 0xd6, 0x33, 0xae, 0x37, 0x1d, 0xfe, 0x1b, 0x75, 0xa1, 0xee, 0x85, 0xf1,
+	0xc4, 0xb7, 0x4c, 0xae, 0x14, 0x02, 0xb2, 0x61, 0x23, 0x8c, 0x3d, 0xfe, 0x61, 0x6b, 0x8d, 0x1f,
+	0xcc, 0x64, 0x74, 0x03, 0x50, 0x14, 0xfb, 0x64, 0x18, 0x44, 0x19, 0x75, 0x23, 0x8f, 0x0c, 0xe9,
+	0x34, 0x21, 0x56, 0x9d, 0xa3, 0xce, 0xb2, 0x93, 0x03, 0x79, 0x70, 0x38, 0x4d, 0x08, 0xfe, 0xc3,
+	0x80, 0x96, 0xe4, 0xf3, 0x88, 0x92, 0x64, 0xc6, 0xc1, 0xd0, 0x38, 0x5c, 0x80, 0x46, 0x46, 0x5d,
+	0x3a, 0xc9, 0x24, 0x33, 0x29, 0x31, 0x6e, 0x24, 0x4d, 0xe3, 0x54, 0x71, 0xe3, 0x02, 0xda, 0x03,
+	0xc8, 0xa8, 0x9b, 0x52, 0xe2, 0x0f, 0x5d, 0xca, 0xd9, 0xb5, 0x76, 0xed, 0xbe, 0xf0, 0x4b, 0x5f,
+	0xf9, 0xa5, 0x7f, 0xa8, 0xfc, 0xe2, 0x34, 0x25, 0x7a, 0x9f, 0xa2, 0x3b, 0xd0, 0x3a, 0x0a, 0xa2,
+	0x20, 0x3b, 0x16, 0x77, 0xeb, 0x2b, 0xef, 0x82, 0x82, 0xef, 0x53, 0xfc, 0x8b, 0x01, 0xed, 0x99,
+	0x25, 0x9c, 0xdf, 0x49, 0xfc, 0x3b, 0xb7, 0xcd, 0xcc, 0xd9, 0x76, 0x0d, 0xb6, 0xc4, 0xaf, 0xe1,
+	0x98, 0x64, 0x99, 0x3b, 0x22, 0xd2, 0xcf, 0x6d, 0xa1, 0x7d, 0x20, 0x94, 0xa8, 0x0f, 0xf5, 0x8c,
+	0x92, 0x24, 0xb3, 0xea, 0x3d, 0xf3, 0x7a, 0x6b, 0xd7, 0xea, 0x6b, 0xc9, 0xd2, 0xd7, 0xfc, 0xea,
+	0x08, 0x18, 0x76, 0xa0, 0xf3, 0x71, 0x90, 0x51, 0x79, 0x92, 0x39, 0xe4, 0xeb, 0x09, 0xc9, 0x28,
+	0x7a, 0x03, 0xce, 0xc4, 0xe9, 0xc8, 0x8d, 0x82, 0xef, 0x78, 0x0c, 0x87, 0x33, 0xda, 0x5b, 0xba,
+	0xfa, 0x80, 0x9b, 0x40, 0xdd, 0x11, 0x0b, 0x84, 0xc9, 0x4c, 0x60, 0xbf, 0xf1, 0x47, 0xd0, 0xcd,
+	0xbf, 0x99, 0x25, 0x71, 0x94, 0x11, 0x74, 0x1b, 0x36, 0x3c, 0xa9, 0xb3, 0x0c, 0x4e, 0xaf, 0x5b,
+	0x45, 0xcf, 0x99, 0xa1, 0xf0, 0x13, 0x38, 0xf7, 0x21, 0x51, 0x0f, 0xbd, 0x34, 0xb7, 0x8b, 0x00,
+	0xf2, 0x25, 0x86, 0xa9, 0x71, 0x4c, 0x53, 0x6a, 0x0e, 0x7c, 0xfc, 0x39, 0x74, 0xef, 0xa5, 0xc4,
+	0xa5, 0xe4, 0xb4, 0xef, 0x5b, 0xb0, 0x9e, 0x8a, 0x3b, 0xfc, 0xf1, 0x4d, 0x47, 0x89, 0xf8, 0x77,
+	0x03, 0xba, 0x03, 0x12, 0x12, 0x4a, 0x5e, 0x0f, 0x77, 0x74, 0x13, 0x90, 0x17, 0x47, 0x47, 0x41,
+	0x3a, 0x16, 0xef, 0xd0, 0xf8, 0x19, 0x89, 0x64, 0xc6, 0x9c, 0xd3, 0x4f, 0x0e, 0xd9, 0x01, 0x2b,
+	0x8c, 0xa3, 0x38, 0xf5, 0x44, 0xce, 0x6c, 0x38, 0x42, 0x60, 0x5a, 0x3f, 0x75, 0x83, 0x88, 0xe7,
+	0xf5, 0x86, 0x23, 0x04, 0xfc, 0xc2, 0x80, 0xf3, 0x05, 0xee, 0x32, 0x7e, 0x16, 0xac, 0xab, 0xdc,
+	0x13, 0xd5, 0xa8, 0xc4, 0x05, 0x74, 0x6a, 0x8b, 0xe8, 0xec, 0x01, 0x90, 0x6f, 0x93, 0x20, 0x25,
+	0x19, 0xab, 0x2a, 0x73, 0x75, 0x45, 0x4a, 0xf4, 0x3e, 0xc5, 0xdf, 0x43, 0xe7, 0x33, 0x97, 0x7a,
+	0xc7, 0xaf, 0xc9, 0xaf, 0x17, 0x01, 0x26, 0x11, 0x0d, 0xc2, 0xa1, 0x1f, 0x47, 0x84, 0x33, 0xdb,
+	0x70, 0x9a, 0x5c, 0x33, 0x88, 0x23, 0x82, 0x7f, 0x32, 0x00, 0x06, 0x24, 0x09, 0xe3, 0xe9, 0x98,
+	0x44, 0x14, 0xed, 0xc0, 0x66, 0x4a, 0x42, 0xe2, 0x66, 0x64, 0xa8, 0xf5, 0xa8, 0x96, 0xd4, 0x3d,
+	0x54, 0xed, 0xf2, 0xd8, 0x4d, 0xa9, 0x74, 0x86, 0x10, 0x98, 0x27, 0x9f, 0x93, 0x34, 0x63, 0xdd,
+	0x92, 0x7d, 0xa3, 0xee, 0x28, 0x91, 0x9d, 0x4c, 0x12, 0xdf, 0xa5, 0xc4, 0x97, 0xf5, 0xad, 0x44,
+	0xad, 0x31, 0xd4, 0xf5, 0xc6, 0x80, 0xbf, 0x82, 0x0b, 0xac, 0xda, 0xe6, 0xb4, 0xb2, 0x57, 0x5d,
+	0x28, 0x87, 0xb0, 0x5d, 0xfa, 0x82, 0x4c, 0x89, 0x3d, 0x68, 0xf9, 0x73, 0xb5, 0xac, 0xea, 0xed,
+	0x5c, 0x55, 0xcf, 0xaf, 0x39, 0x3a, 0x16, 0xff, 0x69, 0xc0, 0xb6, 0xa8, 0x3f, 0x0d, 0xf1, 0x8a,
+	0xc3, 0x39, 0xf3, 0xbe, 0xa9, 0x7b, 0xbf, 0x18, 0xb6, 0xb5, 0x72, 0xd8, 0xb4, 0x00, 0x09, 0x6f,
+	0x2b, 0x91, 0x85, 0xe1, 0xb9, 0x1b, 0x4e, 0x48, 0x66, 0x35, 0x78, 0xcd, 0x4b, 0x09, 0x8f, 0xc1,
+	0x2a, 0x5b, 0x23, 0xbd, 0x74, 0xb2, 0x3c, 0x89, 0x62, 0x4a, 0xd4, 0x44, 0x13, 0x02, 0xa3, 0x91,
+	0x90, 0xc8, 0x0f, 0xa2, 0x91, 0xcc, 0x45, 0x25, 0xe2, 0x1f, 0x0c, 0xd8, 0x16, 0x55, 0xfa, 0xfa,
+	0xbc, 0x57, 0xa4, 0x6d, 0x96, 0x68, 0xe3, 0x77, 0xc1, 0x2a, 0xb3, 0x58, 0xd5, 0x2e, 0xf0, 0x5f,
+	0x06, 0xd4, 0x45, 0x27, 0x98, 0x4f, 0xc4, 0xe6, 0xc2, 0x89, 0xb8, 0x07, 0xe0, 0x71, 0xcf, 0xfa,
+	0x27, 0xec, 0x16, 0x12, 0xbd, 0x4f, 0x0b, 0x8d, 0x66, 0xed, 0x25, 0x1a, 0x0d, 0x2f, 0x37, 0x2f,
+	0x4e, 0x88, 0x98, 0xa4, 0x4d, 0x47, 0x4a, 0xe8, 0x0a, 0xb4, 0xdd, 0x30, 0x8c, 0xbf, 0x21, 0xfe,
+	0xd0, 0x0b, 0xfc, 0x94, 0xa5, 0x01, 0x3b, 0xde, 0x94, 0xca, 0x7b, 0x4c, 0x87, 0x3b, 0x70, 0x8e,
+	0x55, 0x0c, 0xb7, 0x51, 0x95, 0x23, 0xfe, 0x00, 0x90, 0xae, 0x94, 0x5e, 0x7a, 0x13, 0x1a, 0xbc,
+	0x5b, 0xaa, 0xe2, 0x41, 0xb9, 0xe2, 0xe1, 0x60, 0x47, 0x22, 0xf0, 0xcf, 0x06, 0x20, 0x91, 0x64,
+	0x42, 0x2f, 0xe3, 0x5d, 0xb5, 0x22, 0xbd, 0x05, 0x26, 0xa5, 0x21, 0xf7, 0x63, 0x6b, 0xf7, 0x7f,
+	0x25, 0x93, 0x07, 0x72, 0x4b, 0x74, 0x18, 0x4a, 0xb3, 0xd5, 0x5c, 0x6e, 0xeb, 0x5a, 0x85, 0xad,
+	0x77, 0xa0, 0x93, 0xe3, 0x24, 0xed, 0x2a, 0x46, 0xb6, 0x0b, 0x75, 0x7d, 0x2a, 0x08, 0x01, 0x5f,
+	0x05, 0x24, 0xf2, 0x27, 0x67, 0x50, 0xe1, 0x2e, 0x3e, 0x0f, 0x9d, 0x1c, 0x4a, 0x7c, 0x02, 0xff,
+	0x66, 0x40, 0xe3, 0x11, 0xf1, 0x52, 0x42, 0x4f, 0x94, 0x47, 0x6c, 0x55, 0x61, 0x9b, 0xa7, 0x48,
+	0x63, 0xfe, 0x1b, 0xfd, 0x1f, 0x9a, 0xd9, 0xb1, 0x9b, 0x12, 0x7f, 0xf8, 0x74, 0xaa, 0x16, 0x57,
+	0xa1, 0xb8, 0x3b, 0x2d, 0x36, 0x81, 0x7a, 0xae, 0x09, 0xc8, 0x5e, 0xdc, 0x58, 0xb1, 0xa4, 0xad,
+	0x57, 0x2c, 0x69, 0xf8, 0x53, 0x91, 0x09, 0x82, 0xfb, 0xe9, 0x76, 0xae, 0x69, 0x32, 0x33, 0x8e,
+	0xfd, 0xc6, 0x03, 0xe8, 0xe4, 0x9e, 0x94, 0x51, 0xb8, 0x09, 0xeb, 0x99, 0x50, 0xc9, 0xf4, 0xea,
+	0xe4, 0xd2, 0x4b, 0xc0, 0x1d, 0x85, 0xc1, 0x7f, 0x1b, 0x2a, 0x98, 0xf2, 0xe4, 0x14, 0xd4, 0x4e,
+	0xe4, 0xf7, 0xc1, 0xac, 0x8b, 0xae, 0x71, 0x5a, 0x37, 0xf2, 0x8b, 0x60, 0x99, 0x42, 0xff, 0x31,
+	0x87, 0xdf, 0x8f, 0x68, 0x3a, 0x55, 0x3d, 0xd7, 0xde, 0x83, 0x96, 0xa6, 0x46, 0x67, 0xc1, 0x7c,
+	0x46, 0xa6, 0x32, 0x0b, 0xd8, 0x4f, 0x96, 0x74, 0x1c, 0xaa, 0x92, 0x8e, 0x0b, 0xef, 0xd7, 0xde,
+	0x33, 0xf0, 0x13, 0x95, 0x52, 0xa7, 0x34, 0x94, 0x25, 0x0e, 0xbf, 0xa9, 0x3a, 0x27, 0x4b, 0x1c,
+	0xae, 0x38, 0xf0, 0xf1, 0x05, 0xe8, 0xe6, 0x1f, 0x17, 0xd1, 0xd8, 0x7d, 0x61, 0xc2, 0x86, 0xda,
+	0x8a, 0xd1, 0x23, 0xd8, 0xd4, 0xb7, 0x64, 0xd4, 0xcb, 0xb9, 0xa0, 0x62, 0x29, 0xb7, 0x77, 0x96,
+	0x20, 0x64, 0xbc, 0xef, 0x02, 0xcc, 0x17, 0x66, 0x74, 0x29, 0x77, 0xa1, 0xb4, 0x49, 0xdb, 0x95,
+	0xeb, 0x37, 0x7a, 0x08, 0xed, 0xdc, 0x5e, 0x8c, 0x76, 0x2a, 0x82, 0x53, 0x78, 0xc9, 0xae, 0xfe,
+	0x9f, 0xc1, 0x8b, 0xe2, 0x31, 0xb4, 0x73, 0xfb, 0x64, 0xe1, 0xbd, 0xaa, 0x3d, 0xd9, 0xc6, 0xcb,
+	0x20, 0xd2, 0xd6, 0x87, 0xb0, 0xa9, 0xaf, 0x82, 0x05, 0x07, 0x56, 0x6c, 0x89, 0xcb, 0x58, 0xde,
+	0x36, 0x76, 0x7f, 0xad, 0x41, 0x4b, 0xdb, 0x71, 0xd0, 0x97, 0x70, 0xa6, 0xb0, 0xf6, 0xa0, 0x2b,
+	0xa5, 0x08, 0x94, 0xd7, 0x2e, 0xfb, 0xea, 0x72, 0x90, 0x64, 0x3f, 0x84, 0xb3, 0xc5, 0x7d, 0x01,
+	0x5d, 0xad, 0x70, 0x74, 0x69, 0xbc, 0xdb, 0xd7, 0x56, 0xa0, 0xe6, 0x1f, 0x28, 0x8e, 0xe6, 0xc2,
+	0x07, 0x16, 0xec, 0x0f, 0xf6, 0xb5, 0x15, 0x28, 0x99, 0xcd, 0xff, 0x1a, 0xd0, 0x10, 0xc3, 0x0c,
+	0x3d, 0x00, 0x98, 0x8f, 0xb6, 0x42, 0xda, 0x95, 0x06, 0xa1, 0x7d, 0x79, 0xe1, 0xb9, 0xa4, 0xfe,
+	0x09, 0xb4, 0xb4, 0x91, 0x82, 0x2e, 0x57, 0x18, 0xac, 0xcf, 0x0b, 0xbb, 0xb7, 0x18, 0x30, 0x7f,
+	0x51, 0x9b, 0x20, 0x85, 0x17, 0xcb, 0x13, 0xc8, 0xee, 0x2d, 0x06, 0x48, 0xeb, 0xff, 0x31, 0x60,
+	0x5d, 0x76, 0x5b, 0xf6, 0xba, 0xd6, 0x7c, 0x51, 0xd9, 0xbe, 0x7c, 0xa7, 0xb7, 0x7b, 0x8b, 0x01,
+	0x92, 0xef, 0x7d, 0xd8, 0xd4, 0x9b, 0x20, 0xea, 0xad, 0xea, 0x8f, 0x76, 0x55, 0x63, 0x67, 0x3d,
+	0x46, 0x6f, 0x44, 0xa8, 0xca, 0xac, 0xfc, 0x33, 0x3b, 0x4b, 0x10, 0x82, 0xdb, 0xdd, 0xfa, 0x17,
+	0x66, 0x9a, 0x78, 0x4f, 0x1b, 0x7c, 0x99, 0x78, 0xe7, 0xbf, 0x01, 0x00, 0xf9, 0x9b, 0xad, 0xcf,
+	0x9f, 0x12, 0x00, 0x00,
+}
@@ -0,0 +1,470 @@
+// Package rpc is the gRPC API of Pipeline, the services are served by the handlers of the REST API.
+//
+// The messages and the services of pipeline.pb.go are generated from pipeline.proto with `make rpc`.
+package rpc
+
+//go:generate protoc --go_out=plugins=grpc:. pipeline.proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	htype "github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/banzaicloud/pipeline/auth"
+	"github.com/banzaicloud/pipeline/cluster"
+	"github.com/banzaicloud/pipeline/config"
+	"github.com/banzaicloud/pipeline/secret"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+var logger *logrus.Logger
+var log *logrus.Entry
+
+// Simple init for logging
+func init() {
+	logger = config.Logger()
+	log = logger.WithFields(logrus.Fields{"tag": "gRPC"})
+}
+
+// apiPrefix is the prefix of the routes of the REST API
+const apiPrefix = "/api/v1"
+
+// httpCodes are the gRPC codes of the HTTP error responses, the other client errors are invalid arguments and
+// the other server errors are internal errors
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.FailedPrecondition,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+//Server implements the gRPC services with the handler of the REST API: the calls are served as REST requests
+//with the access token of the authorization metadata, so the authentication, the scopes and the policies of
+//the REST API apply to them
+type Server struct {
+	handler http.Handler
+}
+
+//NewServer returns the gRPC server of the services served by the handler of the REST API
+func NewServer(handler http.Handler, options ...grpc.ServerOption) *grpc.Server {
+	s := &Server{handler: handler}
+	server := grpc.NewServer(options...)
+	RegisterClustersServer(server, s)
+	RegisterDeploymentsServer(server, s)
+	RegisterTokensServer(server, s)
+	RegisterSecretsServer(server, s)
+	return server
+}
+
+//ServerOptions returns the options of the TLS credentials of the certificate and the key files, the access
+//tokens of the calls must not travel in plaintext so the API is only served without TLS if insecure is set (e.g.
+//behind a proxy terminating TLS)
+func ServerOptions(certFile, keyFile string, insecure bool) ([]grpc.ServerOption, error) {
+	if certFile == "" && keyFile == "" {
+		if !insecure {
+			return nil, errors.New("the gRPC API needs grpc.tls.certFile and grpc.tls.keyFile, or grpc.insecure to serve it without TLS")
+		}
+		log.Warn("The gRPC API is served without TLS")
+		return nil, nil
+	}
+	creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{grpc.Creds(creds)}, nil
+}
+
+//ListenAndServe serves the gRPC API on the address with the handler of the REST API, it only returns on error
+func ListenAndServe(address string, handler http.Handler, options ...grpc.ServerOption) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	log.Infof("Pipeline gRPC API listening on %s", address)
+	return NewServer(handler, options...).Serve(listener)
+}
+
+// call serves the REST request with the access token and the peer address of the gRPC call and decodes the JSON
+// response into out, it returns the status of the response; the error responses are converted to gRPC errors
+func (s *Server) call(ctx context.Context, method, path string, query url.Values, body []byte, out interface{}) (int, error) {
+	target := apiPrefix + path
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return 0, grpc.Errorf(codes.Internal, "%s", err.Error())
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	if md, ok := metadata.FromContext(ctx); ok && len(md["authorization"]) != 0 {
+		request.Header.Set("Authorization", md["authorization"][0])
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		request.RemoteAddr = p.Addr.String()
+	}
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, request)
+	if recorder.Code >= http.StatusBadRequest {
+		return recorder.Code, responseError(recorder.Code, recorder.Body.Bytes())
+	}
+	if out != nil && recorder.Body.Len() != 0 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			log.Errorf("Error decoding the response of %s %s: %s", method, path, err.Error())
+			return recorder.Code, grpc.Errorf(codes.Internal, "invalid response: %s", err.Error())
+		}
+	}
+	return recorder.Code, nil
+}
+
+// responseError returns the gRPC error of the error response
+func responseError(status int, body []byte) error {
+	code, ok := httpCodes[status]
+	if !ok {
+		code = codes.Internal
+		if status < http.StatusInternalServerError {
+			code = codes.InvalidArgument
+		}
+	}
+	var response components.ErrorResponse
+	if err := json.Unmarshal(body, &response); err != nil || response.Message == "" {
+		return grpc.Errorf(code, "%s", http.StatusText(status))
+	}
+	if response.Error != "" && response.Error != response.Message {
+		return grpc.Errorf(code, "%s: %s", response.Message, response.Error)
+	}
+	return grpc.Errorf(code, "%s", response.Message)
+}
+
+// jsonBody returns the JSON body of a REST request
+func jsonBody(v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err.Error())
+	}
+	return body, nil
+}
+
+// timestampProto returns the timestamp of the time, nil for the zero time
+func timestampProto(t *time.Time) *timestamp.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	ts, err := ptypes.TimestampProto(*t)
+	if err != nil {
+		return nil
+	}
+	return ts
+}
+
+// clusterPath returns the path of the REST resource of the cluster
+func clusterPath(organizationID, clusterID uint32) string {
+	return fmt.Sprintf("/orgs/%d/clusters/%d", organizationID, clusterID)
+}
+
+func clusterMessage(status components.GetClusterStatusResponse) *Cluster {
+	return &Cluster{
+		Id:               uint32(status.ResourceID),
+		Name:             status.Name,
+		Cloud:            status.Cloud,
+		Location:         status.Location,
+		NodeInstanceType: status.NodeInstanceType,
+	}
+}
+
+func clusterStatusMessage(status *cluster.StatusResponse) *ClusterStatus {
+	message := &ClusterStatus{
+		Id:            uint32(status.ResourceID),
+		Name:          status.Name,
+		Status:        status.Status,
+		StatusMessage: status.StatusMessage,
+		Steps:         []*ClusterStep{},
+	}
+	for i := range status.Steps {
+		step := &status.Steps[i]
+		message.Steps = append(message.Steps, &ClusterStep{
+			Name:       step.Name,
+			Status:     step.Status,
+			Error:      step.Error,
+			StartedAt:  timestampProto(&step.StartedAt),
+			FinishedAt: timestampProto(step.FinishedAt),
+		})
+	}
+	return message
+}
+
+//ListClusters lists the clusters of the organization with the tags
+func (s *Server) ListClusters(ctx context.Context, in *ListClustersRequest) (*ListClustersResponse, error) {
+	var clusters []components.GetClusterStatusResponse
+	query := url.Values{"tag": in.Tags}
+	if _, err := s.call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%d/clusters", in.OrganizationId), query, nil, &clusters); err != nil {
+		return nil, err
+	}
+	response := &ListClustersResponse{Clusters: []*Cluster{}}
+	for _, status := range clusters {
+		response.Clusters = append(response.Clusters, clusterMessage(status))
+	}
+	return response, nil
+}
+
+//GetCluster returns the cluster
+func (s *Server) GetCluster(ctx context.Context, in *GetClusterRequest) (*Cluster, error) {
+	var status components.GetClusterStatusResponse
+	if _, err := s.call(ctx, http.MethodGet, clusterPath(in.OrganizationId, in.ClusterId), nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return clusterMessage(status), nil
+}
+
+//CreateCluster starts the creation of the cluster of the JSON request of the REST API
+func (s *Server) CreateCluster(ctx context.Context, in *CreateClusterRequest) (*ClusterStatus, error) {
+	var status cluster.StatusResponse
+	path := fmt.Sprintf("/orgs/%d/clusters", in.OrganizationId)
+	if _, err := s.call(ctx, http.MethodPost, path, nil, in.Request, &status); err != nil {
+		return nil, err
+	}
+	return clusterStatusMessage(&status), nil
+}
+
+//DeleteCluster returns the token confirming the deletion of the cluster, or starts the deletion with the token
+func (s *Server) DeleteCluster(ctx context.Context, in *DeleteClusterRequest) (*DeleteClusterResponse, error) {
+	var response struct {
+		Message           string     `json:"message"`
+		ConfirmationToken string     `json:"confirmationToken"`
+		ExpiresAt         *time.Time `json:"expiresAt"`
+	}
+	query := url.Values{}
+	if in.ConfirmationToken != "" {
+		query.Set("confirm", in.ConfirmationToken)
+		query.Set("force", strconv.FormatBool(in.Force))
+		query.Set("drain", strconv.FormatBool(in.Drain))
+	}
+	if _, err := s.call(ctx, http.MethodDelete, clusterPath(in.OrganizationId, in.ClusterId), query, nil, &response); err != nil {
+		return nil, err
+	}
+	return &DeleteClusterResponse{
+		Message:           response.Message,
+		ConfirmationToken: response.ConfirmationToken,
+		ExpiresAt:         timestampProto(response.ExpiresAt),
+	}, nil
+}
+
+//WatchCluster sends the status of the cluster when it changes until the cluster is deleted, or until its
+//operation is done with until_done; the changes of the other instances of Pipeline are polled
+func (s *Server) WatchCluster(in *WatchClusterRequest, stream Clusters_WatchClusterServer) error {
+	ctx := stream.Context()
+	var status cluster.StatusResponse
+	if _, err := s.call(ctx, http.MethodGet, clusterPath(in.OrganizationId, in.ClusterId)+"/status", nil, nil, &status); err != nil {
+		return err
+	}
+	changes, unsubscribe := cluster.SubscribeProgress(status.ResourceID)
+	defer unsubscribe()
+	poll := time.NewTicker(viper.GetDuration("progress.pollInterval"))
+	defer poll.Stop()
+	var last *cluster.StatusResponse
+	for {
+		current, err := cluster.GetProgress(status.ResourceID)
+		if err != nil {
+			log.Errorf("Error getting the progress of cluster %d: %s", status.ResourceID, err.Error())
+			return grpc.Errorf(codes.Internal, "%s", err.Error())
+		}
+		if current == nil {
+			return nil
+		}
+		if last == nil || !reflect.DeepEqual(*last, current.Status) {
+			if err := stream.Send(clusterStatusMessage(&current.Status)); err != nil {
+				return err
+			}
+			last = &current.Status
+		}
+		if in.UntilDone && !current.InProgress() {
+			return nil
+		}
+		select {
+		case <-changes:
+		case <-poll.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//ListDeployments lists the deployments of the cluster
+func (s *Server) ListDeployments(ctx context.Context, in *ListDeploymentsRequest) (*ListDeploymentsResponse, error) {
+	var releases []htype.ListDeploymentResponse
+	if _, err := s.call(ctx, http.MethodGet, clusterPath(in.OrganizationId, in.ClusterId)+"/deployments", nil, nil, &releases); err != nil {
+		return nil, err
+	}
+	response := &ListDeploymentsResponse{Deployments: []*Deployment{}}
+	for _, release := range releases {
+		response.Deployments = append(response.Deployments, &Deployment{
+			ReleaseName: release.Name,
+			Chart:       release.Chart,
+			Version:     release.Version,
+			Updated:     release.Updated,
+			Status:      release.Status,
+		})
+	}
+	return response, nil
+}
+
+//CreateDeployment installs the chart on the cluster, it's pending if the deployment waits for an approval or for
+//its schedule
+func (s *Server) CreateDeployment(ctx context.Context, in *CreateDeploymentRequest) (*CreateDeploymentResponse, error) {
+	request := htype.CreateDeploymentRequest{Name: in.Chart, ReleaseName: in.ReleaseName, Version: in.Version}
+	if len(in.Values) != 0 {
+		if err := json.Unmarshal(in.Values, &request.Values); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid values: %s", err.Error())
+		}
+	}
+	body, err := jsonBody(request)
+	if err != nil {
+		return nil, err
+	}
+	var release htype.CreateDeploymentResponse
+	code, err := s.call(ctx, http.MethodPost, clusterPath(in.OrganizationId, in.ClusterId)+"/deployments", nil, body, &release)
+	if err != nil {
+		return nil, err
+	}
+	if code == http.StatusAccepted {
+		return &CreateDeploymentResponse{ReleaseName: in.ReleaseName, Pending: true}, nil
+	}
+	return &CreateDeploymentResponse{ReleaseName: release.ReleaseName, Notes: release.Notes}, nil
+}
+
+//DeleteDeployment deletes the deployment of the cluster
+func (s *Server) DeleteDeployment(ctx context.Context, in *DeleteDeploymentRequest) (*DeleteDeploymentResponse, error) {
+	var response htype.DeleteResponse
+	path := clusterPath(in.OrganizationId, in.ClusterId) + "/deployments/" + url.PathEscape(in.ReleaseName)
+	if _, err := s.call(ctx, http.MethodDelete, path, nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return &DeleteDeploymentResponse{Message: response.Message}, nil
+}
+
+//ListTokens lists the access tokens of the current user
+func (s *Server) ListTokens(ctx context.Context, in *ListTokensRequest) (*ListTokensResponse, error) {
+	var tokens []auth.Token
+	if _, err := s.call(ctx, http.MethodGet, "/tokens", nil, nil, &tokens); err != nil {
+		return nil, err
+	}
+	response := &ListTokensResponse{Tokens: []*Token{}}
+	for i := range tokens {
+		token := &tokens[i]
+		response.Tokens = append(response.Tokens, &Token{
+			Id:           token.ID,
+			Name:         token.Name,
+			CreatedAt:    timestampProto(&token.CreatedAt),
+			ExpiresAt:    timestampProto(token.ExpiresAt),
+			Scopes:       token.Scopes,
+			AllowedCidrs: token.AllowedCIDRs,
+		})
+	}
+	return response, nil
+}
+
+//CreateToken creates an access token of the current user
+func (s *Server) CreateToken(ctx context.Context, in *CreateTokenRequest) (*CreateTokenResponse, error) {
+	query := url.Values{"scope": in.Scopes, "cidr": in.AllowedCidrs}
+	if in.Name != "" {
+		query.Set("name", in.Name)
+	}
+	if in.Ttl != nil {
+		ttl, err := ptypes.Duration(in.Ttl)
+		if err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid ttl: %s", err.Error())
+		}
+		query.Set("ttl", ttl.String())
+	}
+	var response CreateTokenResponse
+	if _, err := s.call(ctx, http.MethodPost, "/tokens", query, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+//DeleteToken revokes an access token of the current user
+func (s *Server) DeleteToken(ctx context.Context, in *DeleteTokenRequest) (*DeleteTokenResponse, error) {
+	if _, err := s.call(ctx, http.MethodDelete, "/tokens/"+url.PathEscape(in.Id), nil, nil, nil); err != nil {
+		return nil, err
+	}
+	return &DeleteTokenResponse{}, nil
+}
+
+//ListSecrets lists the secrets of the organization of the type, all of them without a type
+func (s *Server) ListSecrets(ctx context.Context, in *ListSecretsRequest) (*ListSecretsResponse, error) {
+	query := url.Values{}
+	if in.Type != "" {
+		query.Set("type", in.Type)
+	}
+	var secrets secret.ListSecretsResponse
+	if _, err := s.call(ctx, http.MethodGet, fmt.Sprintf("/orgs/%d/secrets", in.OrganizationId), query, nil, &secrets); err != nil {
+		return nil, err
+	}
+	response := &ListSecretsResponse{Secrets: []*Secret{}}
+	for _, item := range secrets.Secrets {
+		response.Secrets = append(response.Secrets, &Secret{
+			Id:            item.ID,
+			Name:          item.Name,
+			Type:          item.SecretType,
+			SharedBy:      item.SharedBy,
+			Version:       int32(item.Version),
+			Status:        item.Status,
+			StatusMessage: item.StatusMessage,
+		})
+	}
+	return response, nil
+}
+
+//CreateSecret stores a secret of the organization
+func (s *Server) CreateSecret(ctx context.Context, in *CreateSecretRequest) (*Secret, error) {
+	body, err := jsonBody(secret.CreateSecretRequest{Name: in.Name, SecretType: in.Type, Values: in.Values})
+	if err != nil {
+		return nil, err
+	}
+	var response secret.CreateSecretResponse
+	if _, err := s.call(ctx, http.MethodPost, fmt.Sprintf("/orgs/%d/secrets", in.OrganizationId), nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &Secret{Id: response.SecretID, Name: response.Name, Type: response.SecretType}, nil
+}
+
+//DeleteSecret deletes a secret of the organization
+func (s *Server) DeleteSecret(ctx context.Context, in *DeleteSecretRequest) (*DeleteSecretResponse, error) {
+	path := fmt.Sprintf("/orgs/%d/secrets/%s", in.OrganizationId, url.PathEscape(in.SecretId))
+	if _, err := s.call(ctx, http.MethodDelete, path, nil, nil, nil); err != nil {
+		return nil, err
+	}
+	return &DeleteSecretResponse{}, nil
+}
@@ -0,0 +1,210 @@
+package rpc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/pipeline/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// testRouter is a REST API with the routes of the clusters and the tokens of the test organization
+func testRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/orgs/1/clusters", func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer token" {
+			c.JSON(http.StatusUnauthorized, components.ErrorResponse{Code: http.StatusUnauthorized, Message: "Invalid token"})
+			return
+		}
+		c.JSON(http.StatusOK, []components.GetClusterStatusResponse{
+			{Status: http.StatusOK, Name: c.Query("tag"), Cloud: "amazon", Location: "eu-west-1", ResourceID: 7},
+		})
+	})
+	router.GET("/api/v1/orgs/1/clusters/8", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, components.ErrorResponse{Code: http.StatusNotFound, Message: "Cluster not found", Error: "record not found"})
+	})
+	router.POST("/api/v1/tokens", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Query("ttl") + "/" + c.Query("scope"), "token": c.Query("name")})
+	})
+	return router
+}
+
+func TestServer(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error during listening: %s", err.Error())
+	}
+	server := rpc.NewServer(testRouter())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Error during dialing: %s", err.Error())
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	authorized := metadata.NewContext(ctx, metadata.Pairs("authorization", "Bearer token"))
+
+	clusters := rpc.NewClustersClient(conn)
+	list, err := clusters.ListClusters(authorized, &rpc.ListClustersRequest{OrganizationId: 1, Tags: []string{"env:dev"}})
+	if err != nil {
+		t.Fatalf("Error during listing clusters: %s", err.Error())
+	}
+	if len(list.Clusters) != 1 || list.Clusters[0].Id != 7 || list.Clusters[0].Name != "env:dev" {
+		t.Errorf("Expected cluster 7 of the tag, got: %v", list.Clusters)
+	}
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{name: "unauthenticated", code: codes.Unauthenticated, call: func() error {
+			_, err := clusters.ListClusters(ctx, &rpc.ListClustersRequest{OrganizationId: 1})
+			return err
+		}},
+		{name: "not found", code: codes.NotFound, call: func() error {
+			_, err := clusters.GetCluster(authorized, &rpc.GetClusterRequest{OrganizationId: 1, ClusterId: 8})
+			return err
+		}},
+		{name: "unknown route", code: codes.NotFound, call: func() error {
+			_, err := rpc.NewSecretsClient(conn).ListSecrets(authorized, &rpc.ListSecretsRequest{OrganizationId: 1})
+			return err
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.call()
+			if err == nil {
+				t.Fatalf("Expected error, but not got error!")
+			}
+			if grpc.Code(err) != tc.code {
+				t.Errorf("Expected %v, got: %v", tc.code, err)
+			}
+		})
+	}
+
+	token, err := rpc.NewTokensClient(conn).CreateToken(authorized, &rpc.CreateTokenRequest{
+		Name:   "cli",
+		Ttl:    ptypes.DurationProto(time.Hour),
+		Scopes: []string{"cluster:read"},
+	})
+	if err != nil {
+		t.Fatalf("Error during creating token: %s", err.Error())
+	}
+	if token.Id != "1h0m0s/cluster:read" || token.Token != "cli" {
+		t.Errorf("Expected the ttl, the scope and the name of the token, got: %v", token)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate of 127.0.0.1 and its key into the directory
+func writeTestCertificate(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error during generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error during creating certificate: %s", err.Error())
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	return certFile, keyFile, certificate
+}
+
+func TestServerOptions(t *testing.T) {
+
+	if _, err := rpc.ServerOptions("", "", false); err == nil {
+		t.Errorf("Expected error, but not got error!")
+	}
+	if options, err := rpc.ServerOptions("", "", true); err != nil || len(options) != 0 {
+		t.Errorf("Expected the plaintext server, got: %v %v", options, err)
+	}
+	if _, err := rpc.ServerOptions("missing.crt", "missing.key", true); err == nil {
+		t.Errorf("Expected error, but not got error!")
+	}
+
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatalf("Error during creating directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, certificate := writeTestCertificate(t, dir)
+	options, err := rpc.ServerOptions(certFile, keyFile, false)
+	if err != nil {
+		t.Fatalf("Error during configuring TLS: %s", err.Error())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error during listening: %s", err.Error())
+	}
+	server := rpc.NewServer(testRouter(), options...)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	cases := []struct {
+		name        string
+		dialOption  grpc.DialOption
+		expectError bool
+	}{
+		{name: "tls", dialOption: grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, ""))},
+		{name: "plaintext", dialOption: grpc.WithInsecure(), expectError: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := grpc.Dial(listener.Addr().String(), tc.dialOption)
+			if err != nil {
+				t.Fatalf("Error during dialing: %s", err.Error())
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			authorized := metadata.NewContext(ctx, metadata.Pairs("authorization", "Bearer token"))
+			_, err = rpc.NewClustersClient(conn).ListClusters(authorized, &rpc.ListClustersRequest{OrganizationId: 1})
+			if tc.expectError && err == nil {
+				t.Errorf("Expected error, but not got error!")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Error during listing clusters: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package scm
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+// githubClient reports the commit statuses through the GitHub API
+type githubClient struct {
+	client *github.Client
+}
+
+// newGitHubClient returns a GitHub client authenticated with the OAuth token, the API URL is set for GitHub
+// Enterprise
+func newGitHubClient(apiURL, token string) (*githubClient, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+	client := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+	if apiURL != "" {
+		baseURL, err := url.Parse(strings.TrimSuffix(apiURL, "/") + "/")
+		if err != nil {
+			return nil, err
+		}
+		client.BaseURL = baseURL
+	}
+	return &githubClient{client: client}, nil
+}
+
+// SetCommitStatus implements Client
+func (c *githubClient) SetCommitStatus(owner, name, sha string, status CommitStatus) error {
+	_, _, err := c.client.Repositories.CreateStatus(context.Background(), owner, name, sha, &github.RepoStatus{
+		State:       github.String(status.State),
+		TargetURL:   github.String(status.TargetURL),
+		Description: github.String(status.Description),
+		Context:     github.String(status.Context),
+	})
+	return err
+}
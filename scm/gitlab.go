@@ -0,0 +1,65 @@
+package scm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultGitLabURL is the address of gitlab.com
+const defaultGitLabURL = "https://gitlab.com"
+
+// gitlabStates are the GitLab states of the commit statuses
+var gitlabStates = map[string]string{
+	StatePending: "running",
+	StateSuccess: "success",
+	StateFailure: "failed",
+}
+
+// gitlabClient reports the commit statuses through the v4 API of GitLab
+type gitlabClient struct {
+	url   string
+	token string
+}
+
+// newGitLabClient returns a GitLab client authenticated with the OAuth token
+func newGitLabClient(apiURL, token string) *gitlabClient {
+	if apiURL == "" {
+		apiURL = defaultGitLabURL
+	}
+	return &gitlabClient{url: strings.TrimSuffix(apiURL, "/"), token: token}
+}
+
+// SetCommitStatus implements Client, the project is addressed by its path
+func (c *gitlabClient) SetCommitStatus(owner, name, sha string, status CommitStatus) error {
+	body, err := json.Marshal(map[string]string{
+		"state":       gitlabStates[status.State],
+		"target_url":  status.TargetURL,
+		"description": status.Description,
+		"name":        status.Context,
+	})
+	if err != nil {
+		return err
+	}
+	project := url.PathEscape(owner + "/" + name)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v4/projects/%s/statuses/%s", c.url, project, url.PathEscape(sha)), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("GitLab responded %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
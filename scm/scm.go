@@ -0,0 +1,51 @@
+package scm
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Providers of the source code management systems
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// States of the commit statuses
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+)
+
+// httpClient is the client of the SCM APIs
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+//CommitStatus is the status of a commit reported by Pipeline, Context distinguishes the statuses of the
+//different checks of a commit and TargetURL links the details of the status
+type CommitStatus struct {
+	State       string
+	TargetURL   string
+	Description string
+	Context     string
+}
+
+//Client reports the statuses of the commits of the repositories with the token of a user
+type Client interface {
+	// SetCommitStatus creates a status of the commit of the owner/name repository
+	SetCommitStatus(owner, name, sha string, status CommitStatus) error
+}
+
+//NewClient returns the client of the provider, apiURL is the address of the API of a self-hosted SCM (empty for
+//github.com)
+func NewClient(provider, apiURL, token string) (Client, error) {
+	switch provider {
+	case ProviderGitHub:
+		return newGitHubClient(apiURL, token)
+	case ProviderGitLab:
+		return newGitLabClient(apiURL, token), nil
+	default:
+		return nil, fmt.Errorf("unsupported SCM provider: %q", provider)
+	}
+}
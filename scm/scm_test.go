@@ -0,0 +1,64 @@
+package scm_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/scm"
+)
+
+func TestSetCommitStatus(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		provider string
+		path     string
+		state    string
+	}{
+		{name: "github", provider: scm.ProviderGitHub, path: "/repos/acme/web/statuses/abc123", state: "failure"},
+		{name: "gitlab", provider: scm.ProviderGitLab, path: "/api/v4/projects/acme%2Fweb/statuses/abc123", state: "failed"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var path, authorization string
+			var body map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, authorization = r.URL.EscapedPath(), r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&body)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("{}"))
+			}))
+			defer server.Close()
+
+			client, err := scm.NewClient(tc.provider, server.URL, "token")
+			if err != nil {
+				t.Fatalf("Error during creating client: %s", err.Error())
+			}
+			err = client.SetCommitStatus("acme", "web", "abc123", scm.CommitStatus{
+				State:       scm.StateFailure,
+				TargetURL:   "https://pipeline.example.com/deployments/web",
+				Description: "Rollout failed",
+				Context:     "pipeline/deploy",
+			})
+			if err != nil {
+				t.Fatalf("Error during setting commit status: %s", err.Error())
+			}
+			if path != tc.path {
+				t.Errorf("Expected %s, got: %s", tc.path, path)
+			}
+			if authorization != "Bearer token" {
+				t.Errorf("Expected the bearer token, got: %q", authorization)
+			}
+			if body["state"] != tc.state {
+				t.Errorf("Expected state %s, got: %v", tc.state, body["state"])
+			}
+		})
+	}
+
+	if _, err := scm.NewClient("svn", "", "token"); err == nil {
+		t.Errorf("Expected error, but not got error!")
+	}
+}
@@ -0,0 +1,183 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/banzaicloud/pipeline/vaultclient"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// envelopePrefix marks the values encrypted by an Encrypter, the format is
+// "pipeline:v1:<data key wrapped by Vault>:<base64 nonce and ciphertext>"
+const envelopePrefix = "pipeline:v1:"
+
+// Encryption is the Encrypter of the values written to the database, nil if encryption is disabled
+var Encryption Encrypter
+
+// Encrypter envelope-encrypts sensitive values (eg.: kubeconfigs) before they're written to the database
+type Encrypter interface {
+	Encrypt(plaintext []byte) (string, error)
+	Decrypt(ciphertext string) ([]byte, error)
+	// Rewrap re-encrypts the data key of a ciphertext with the latest version of the key encryption key,
+	// the encrypted value itself is kept
+	Rewrap(ciphertext string) (string, error)
+	// RotateKey creates a new version of the key encryption key, the old versions still decrypt
+	RotateKey() error
+}
+
+// NewEncrypter creates the Encrypter of the secrets.encryption driver ("vault" or "none")
+func NewEncrypter(driver string) (Encrypter, error) {
+	switch driver {
+	case "vault":
+		client, err := vaultclient.NewClient(viper.GetString("secrets.transit.role"))
+		if err != nil {
+			return nil, err
+		}
+		return NewVaultTransitEncrypter(client.Vault(), viper.GetString("secrets.transit.mountpath"), viper.GetString("secrets.transit.key")), nil
+	case "none", "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown secret encryption driver %q", driver)
+}
+
+// vaultTransitEncrypter generates a data key for every value with Vault's transit engine,
+// the value is encrypted locally with AES-GCM and only the wrapped data key is decrypted by Vault,
+// eg.: vault write -f transit/keys/pipeline-secrets
+type vaultTransitEncrypter struct {
+	logical   *vaultapi.Logical
+	mountPath string
+	key       string
+}
+
+// NewVaultTransitEncrypter creates an Encrypter using the transit key of the given mount
+func NewVaultTransitEncrypter(client *vaultapi.Client, mountPath, key string) Encrypter {
+	return &vaultTransitEncrypter{
+		logical:   client.Logical(),
+		mountPath: strings.Trim(mountPath, "/"),
+		key:       key,
+	}
+}
+
+func (encrypter *vaultTransitEncrypter) path(operation string) string {
+	return fmt.Sprintf("%s/%s/%s", encrypter.mountPath, operation, encrypter.key)
+}
+
+func (encrypter *vaultTransitEncrypter) Encrypt(plaintext []byte) (string, error) {
+	secret, err := encrypter.logical.Write(encrypter.path("datakey/plaintext"), map[string]interface{}{"bits": 256})
+	if err != nil {
+		return "", errors.Wrap(err, "error generating data key")
+	}
+	if secret == nil {
+		return "", fmt.Errorf("transit key %s/%s not found", encrypter.mountPath, encrypter.key)
+	}
+	wrappedKey, _ := secret.Data["ciphertext"].(string)
+	encodedKey, _ := secret.Data["plaintext"].(string)
+	dataKey, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid data key returned by Vault")
+	}
+	return sealEnvelope(dataKey, wrappedKey, plaintext)
+}
+
+func (encrypter *vaultTransitEncrypter) Decrypt(ciphertext string) ([]byte, error) {
+	wrappedKey, sealed, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := encrypter.logical.Write(encrypter.path("decrypt"), map[string]interface{}{"ciphertext": wrappedKey})
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting data key")
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("transit key %s/%s not found", encrypter.mountPath, encrypter.key)
+	}
+	encodedKey, _ := secret.Data["plaintext"].(string)
+	dataKey, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data key returned by Vault")
+	}
+	return openEnvelope(dataKey, sealed)
+}
+
+func (encrypter *vaultTransitEncrypter) Rewrap(ciphertext string) (string, error) {
+	wrappedKey, sealed, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	secret, err := encrypter.logical.Write(encrypter.path("rewrap"), map[string]interface{}{"ciphertext": wrappedKey})
+	if err != nil {
+		return "", errors.Wrap(err, "error rewrapping data key")
+	}
+	if secret == nil {
+		return "", fmt.Errorf("transit key %s/%s not found", encrypter.mountPath, encrypter.key)
+	}
+	rewrapped, _ := secret.Data["ciphertext"].(string)
+	return envelopePrefix + rewrapped + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (encrypter *vaultTransitEncrypter) RotateKey() error {
+	_, err := encrypter.logical.Write(fmt.Sprintf("%s/keys/%s/rotate", encrypter.mountPath, encrypter.key), nil)
+	return errors.Wrap(err, "error rotating transit key")
+}
+
+// IsEncrypted checks whether the value was encrypted by an Encrypter
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+func sealEnvelope(dataKey []byte, wrappedKey string, plaintext []byte) (string, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return envelopePrefix + wrappedKey + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// parseEnvelope splits the envelope to the wrapped data key ("vault:v<version>:<base64>") and the sealed value
+func parseEnvelope(ciphertext string) (string, []byte, error) {
+	if !IsEncrypted(ciphertext) {
+		return "", nil, errors.New("value is not encrypted")
+	}
+	envelope := strings.TrimPrefix(ciphertext, envelopePrefix)
+	separator := strings.LastIndex(envelope, ":")
+	if separator < 0 {
+		return "", nil, errors.New("invalid encrypted value")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(envelope[separator+1:])
+	if err != nil {
+		return "", nil, errors.Wrap(err, "invalid encrypted value")
+	}
+	return envelope[:separator], sealed, nil
+}
+
+func openEnvelope(dataKey []byte, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted value")
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func newGCM(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,101 @@
+package secret_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/secret"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// fakeTransit wraps the data keys by prefixing them with the key version, like "vault:v1:<base64>"
+type fakeTransit struct {
+	version int
+}
+
+func (transit *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&request)
+	unwrap := func() string {
+		parts := strings.SplitN(fmt.Sprint(request["ciphertext"]), ":", 3)
+		return parts[2]
+	}
+	data := map[string]interface{}{}
+	switch r.URL.Path {
+	case "/v1/transit/datakey/plaintext/test":
+		key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{byte(transit.version)}, 32))
+		data["plaintext"] = key
+		data["ciphertext"] = fmt.Sprintf("vault:v%d:%s", transit.version, key)
+	case "/v1/transit/decrypt/test":
+		data["plaintext"] = unwrap()
+	case "/v1/transit/rewrap/test":
+		data["ciphertext"] = fmt.Sprintf("vault:v%d:%s", transit.version, unwrap())
+	case "/v1/transit/keys/test/rotate":
+		transit.version++
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func TestVaultTransitEncrypter(t *testing.T) {
+
+	server := httptest.NewServer(&fakeTransit{version: 1})
+	defer server.Close()
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("test")
+	encrypter := secret.NewVaultTransitEncrypter(client, "/transit/", "test")
+
+	plaintext := []byte("apiVersion: v1\nkind: Config\n")
+	ciphertext, err := encrypter.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !secret.IsEncrypted(ciphertext) || strings.Contains(ciphertext, "kind: Config") {
+		t.Fatalf("Value is not encrypted: %s", ciphertext)
+	}
+	if !strings.HasPrefix(ciphertext, "pipeline:v1:vault:v1:") {
+		t.Errorf("Data key is not wrapped with v1: %s", ciphertext)
+	}
+
+	if err := encrypter.RotateKey(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rewrapped, err := encrypter.Rewrap(ciphertext)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(rewrapped, "pipeline:v1:vault:v2:") {
+		t.Errorf("Data key is not rewrapped with v2: %s", rewrapped)
+	}
+
+	for _, value := range []string{ciphertext, rewrapped} {
+		decrypted, err := encrypter.Decrypt(value)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("Expected: %q, but got: %q", plaintext, decrypted)
+		}
+	}
+
+	tampered := ciphertext[:len(ciphertext)-4] + "AAA="
+	if _, err := encrypter.Decrypt(tampered); err == nil {
+		t.Error("Expected an error for a tampered value")
+	}
+	if _, err := encrypter.Decrypt("plain text"); err == nil {
+		t.Error("Expected an error for a value which isn't encrypted")
+	}
+}
@@ -0,0 +1,309 @@
+package secret
+
+import (
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/pipeline/model"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// ErrSecretReadOnly is returned for writes of secrets shared with the organization by another one
+var ErrSecretReadOnly = errors.New("shared secrets are read-only")
+
+// SecretGrant gives an organization read access to a secret of another organization,
+// eg.: a shared AWS billing account
+type SecretGrant struct {
+	ID                    uint      `gorm:"primary_key" json:"-"`
+	CreatedAt             time.Time `json:"createdAt"`
+	OrganizationID        string    `gorm:"not null;index" json:"organizationId"`
+	SecretID              string    `gorm:"not null;unique_index:idx_secret_grantee" json:"secretId"`
+	GranteeOrganizationID string    `gorm:"not null;unique_index:idx_secret_grantee" json:"granteeOrganizationId"`
+}
+
+// TableName sets SecretGrant's table name
+func (SecretGrant) TableName() string {
+	return "secret_grants"
+}
+
+// GrantStore stores the grants of the shared secrets,
+// Lookup returns nil if the secret isn't shared with the organization
+type GrantStore interface {
+	Grant(grant *SecretGrant) error
+	Lookup(secretID, granteeOrganizationID string) (*SecretGrant, error)
+	// List returns the grants of a secret
+	List(organizationID, secretID string) ([]SecretGrant, error)
+	// ListGrantee returns the secrets shared with an organization
+	ListGrantee(granteeOrganizationID string) ([]SecretGrant, error)
+	Revoke(organizationID, secretID, granteeOrganizationID string) error
+	// RevokeAll removes the grants of a deleted secret
+	RevokeAll(organizationID, secretID string) error
+}
+
+// NewGrantStore creates the GrantStore of the secrets.store driver, the grants of the secrets
+// in Vault are stored in the database, the ones of the "memory" driver in memory
+func NewGrantStore(driver string) (GrantStore, error) {
+	switch driver {
+	case "vault":
+		return NewDBGrantStore(), nil
+	case "memory":
+		return NewInMemoryGrantStore(), nil
+	}
+	return nil, errors.Errorf("unknown secret store driver %q", driver)
+}
+
+// Database implementation
+
+type dbGrantStore struct{}
+
+// NewDBGrantStore creates a GrantStore storing the grants in the secret_grants table
+func NewDBGrantStore() GrantStore {
+	return dbGrantStore{}
+}
+
+func (dbGrantStore) Grant(grant *SecretGrant) error {
+	return model.GetDB().Where(SecretGrant{SecretID: grant.SecretID, GranteeOrganizationID: grant.GranteeOrganizationID}).
+		Attrs(SecretGrant{OrganizationID: grant.OrganizationID}).FirstOrCreate(grant).Error
+}
+
+func (dbGrantStore) Lookup(secretID, granteeOrganizationID string) (*SecretGrant, error) {
+	var grant SecretGrant
+	err := model.GetDB().Where(SecretGrant{SecretID: secretID, GranteeOrganizationID: granteeOrganizationID}).First(&grant).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (dbGrantStore) List(organizationID, secretID string) ([]SecretGrant, error) {
+	grants := []SecretGrant{}
+	err := model.GetDB().Where(SecretGrant{OrganizationID: organizationID, SecretID: secretID}).Find(&grants).Error
+	return grants, err
+}
+
+func (dbGrantStore) ListGrantee(granteeOrganizationID string) ([]SecretGrant, error) {
+	grants := []SecretGrant{}
+	err := model.GetDB().Where(SecretGrant{GranteeOrganizationID: granteeOrganizationID}).Find(&grants).Error
+	return grants, err
+}
+
+func (dbGrantStore) Revoke(organizationID, secretID, granteeOrganizationID string) error {
+	return model.GetDB().Where(SecretGrant{OrganizationID: organizationID, SecretID: secretID, GranteeOrganizationID: granteeOrganizationID}).
+		Delete(SecretGrant{}).Error
+}
+
+func (dbGrantStore) RevokeAll(organizationID, secretID string) error {
+	return model.GetDB().Where(SecretGrant{OrganizationID: organizationID, SecretID: secretID}).Delete(SecretGrant{}).Error
+}
+
+// In-memory implementation
+
+type inMemoryGrantStore struct {
+	sync.RWMutex
+	grants []SecretGrant
+}
+
+// NewInMemoryGrantStore is a basic in-memory GrantStore implementation (thread-safe) for development
+func NewInMemoryGrantStore() GrantStore {
+	return &inMemoryGrantStore{}
+}
+
+func (store *inMemoryGrantStore) Grant(grant *SecretGrant) error {
+	store.Lock()
+	defer store.Unlock()
+	for _, existing := range store.grants {
+		if existing.SecretID == grant.SecretID && existing.GranteeOrganizationID == grant.GranteeOrganizationID {
+			*grant = existing
+			return nil
+		}
+	}
+	grant.ID = uint(len(store.grants) + 1)
+	grant.CreatedAt = time.Now()
+	store.grants = append(store.grants, *grant)
+	return nil
+}
+
+func (store *inMemoryGrantStore) find(match func(*SecretGrant) bool) []SecretGrant {
+	store.RLock()
+	defer store.RUnlock()
+	grants := []SecretGrant{}
+	for i := range store.grants {
+		if match(&store.grants[i]) {
+			grants = append(grants, store.grants[i])
+		}
+	}
+	return grants
+}
+
+func (store *inMemoryGrantStore) Lookup(secretID, granteeOrganizationID string) (*SecretGrant, error) {
+	grants := store.find(func(grant *SecretGrant) bool {
+		return grant.SecretID == secretID && grant.GranteeOrganizationID == granteeOrganizationID
+	})
+	if len(grants) == 0 {
+		return nil, nil
+	}
+	return &grants[0], nil
+}
+
+func (store *inMemoryGrantStore) List(organizationID, secretID string) ([]SecretGrant, error) {
+	return store.find(func(grant *SecretGrant) bool {
+		return grant.OrganizationID == organizationID && grant.SecretID == secretID
+	}), nil
+}
+
+func (store *inMemoryGrantStore) ListGrantee(granteeOrganizationID string) ([]SecretGrant, error) {
+	return store.find(func(grant *SecretGrant) bool {
+		return grant.GranteeOrganizationID == granteeOrganizationID
+	}), nil
+}
+
+func (store *inMemoryGrantStore) remove(match func(*SecretGrant) bool) {
+	store.Lock()
+	defer store.Unlock()
+	grants := store.grants[:0]
+	for _, grant := range store.grants {
+		if !match(&grant) {
+			grants = append(grants, grant)
+		}
+	}
+	store.grants = grants
+}
+
+func (store *inMemoryGrantStore) Revoke(organizationID, secretID, granteeOrganizationID string) error {
+	store.remove(func(grant *SecretGrant) bool {
+		return grant.OrganizationID == organizationID && grant.SecretID == secretID && grant.GranteeOrganizationID == granteeOrganizationID
+	})
+	return nil
+}
+
+func (store *inMemoryGrantStore) RevokeAll(organizationID, secretID string) error {
+	store.remove(func(grant *SecretGrant) bool {
+		return grant.OrganizationID == organizationID && grant.SecretID == secretID
+	})
+	return nil
+}
+
+// Sharing implementation
+
+// sharingSecretStore enforces the grants on top of a SecretStore: the secrets shared with an organization
+// can be read (and are listed) as its own ones, but only the owner organization can change them
+type sharingSecretStore struct {
+	SecretStore
+	grants GrantStore
+}
+
+// NewSharingSecretStore wraps a SecretStore with the grants of the shared secrets
+func NewSharingSecretStore(store SecretStore, grants GrantStore) SecretStore {
+	return &sharingSecretStore{SecretStore: store, grants: grants}
+}
+
+// checkWritable returns ErrSecretReadOnly if the secret is shared with the organization
+func (ss *sharingSecretStore) checkWritable(organizationID, secretID string) error {
+	grant, err := ss.grants.Lookup(secretID, organizationID)
+	if err != nil {
+		return err
+	}
+	if grant != nil {
+		return ErrSecretReadOnly
+	}
+	return nil
+}
+
+// owner returns the organization of a secret readable by the organization
+func (ss *sharingSecretStore) owner(organizationID, secretID string) (string, error) {
+	grant, err := ss.grants.Lookup(secretID, organizationID)
+	if err != nil {
+		return "", err
+	}
+	if grant == nil {
+		return "", ErrSecretNotFound
+	}
+	return grant.OrganizationID, nil
+}
+
+func (ss *sharingSecretStore) Store(organizationID, secretID string, value CreateSecretRequest) error {
+	if err := ss.checkWritable(organizationID, secretID); err != nil {
+		return err
+	}
+	return ss.SecretStore.Store(organizationID, secretID, value)
+}
+
+func (ss *sharingSecretStore) Get(organizationID, secretID string) (*SecretsItemResponse, error) {
+	item, err := ss.SecretStore.Get(organizationID, secretID)
+	if err != ErrSecretNotFound {
+		return item, err
+	}
+	owner, err := ss.owner(organizationID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	if item, err = ss.SecretStore.Get(owner, secretID); err != nil {
+		return nil, err
+	}
+	item.SharedBy = owner
+	return item, nil
+}
+
+func (ss *sharingSecretStore) List(organizationID, secretType string) ([]SecretsItemResponse, error) {
+	items, err := ss.SecretStore.List(organizationID, secretType)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := ss.grants.ListGrantee(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		item, err := ss.SecretStore.Get(grant.OrganizationID, grant.SecretID)
+		if err == ErrSecretNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if len(secretType) == 0 || item.SecretType == secretType {
+			item.Values = nil
+			item.SharedBy = grant.OrganizationID
+			items = append(items, *item)
+		}
+	}
+	return items, nil
+}
+
+func (ss *sharingSecretStore) Delete(organizationID, secretID string) error {
+	if err := ss.checkWritable(organizationID, secretID); err != nil {
+		return err
+	}
+	if err := ss.SecretStore.Delete(organizationID, secretID); err != nil {
+		return err
+	}
+	return ss.grants.RevokeAll(organizationID, secretID)
+}
+
+func (ss *sharingSecretStore) Versions(organizationID, secretID string) ([]SecretVersion, error) {
+	versions, err := ss.SecretStore.Versions(organizationID, secretID)
+	if err != ErrSecretNotFound {
+		return versions, err
+	}
+	owner, err := ss.owner(organizationID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	return ss.SecretStore.Versions(owner, secretID)
+}
+
+func (ss *sharingSecretStore) Rollback(organizationID, secretID string, version int) error {
+	if err := ss.checkWritable(organizationID, secretID); err != nil {
+		return err
+	}
+	return ss.SecretStore.Rollback(organizationID, secretID, version)
+}
+
+func (ss *sharingSecretStore) SetStatus(organizationID, secretID string, version int, status, message string) error {
+	if err := ss.checkWritable(organizationID, secretID); err != nil {
+		return err
+	}
+	return ss.SecretStore.SetStatus(organizationID, secretID, version, status, message)
+}
@@ -0,0 +1,56 @@
+package secret_test
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/pipeline/secret"
+)
+
+func TestSharingSecretStore(t *testing.T) {
+
+	grants := secret.NewInMemoryGrantStore()
+	store := secret.NewSharingSecretStore(secret.NewInMemorySecretStore(), grants)
+	billing := secret.CreateSecretRequest{
+		Name:       "billing",
+		SecretType: secret.Amazon,
+		Values:     map[string]string{"AWS_ACCESS_KEY_ID": "id", "AWS_SECRET_ACCESS_KEY": "secret"},
+	}
+	if err := store.Store("1", "s1", billing); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := store.Get("2", "s1"); err != secret.ErrSecretNotFound {
+		t.Fatalf("Expected: %v, but got: %v", secret.ErrSecretNotFound, err)
+	}
+
+	if err := grants.Grant(&secret.SecretGrant{OrganizationID: "1", SecretID: "s1", GranteeOrganizationID: "2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	item, err := store.Get("2", "s1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if item.SharedBy != "1" || item.Values["AWS_ACCESS_KEY_ID"] != "id" {
+		t.Errorf("Unexpected shared secret: %+v", item)
+	}
+	if items, _ := store.List("2", secret.Amazon); len(items) != 1 || items[0].SharedBy != "1" {
+		t.Errorf("Expected the shared secret in the list, but got: %+v", items)
+	}
+	if items, _ := store.List("3", ""); len(items) != 0 {
+		t.Errorf("Expected no secrets, but got: %+v", items)
+	}
+
+	if err := store.Store("2", "s1", billing); err != secret.ErrSecretReadOnly {
+		t.Errorf("Expected: %v, but got: %v", secret.ErrSecretReadOnly, err)
+	}
+	if err := store.Delete("2", "s1"); err != secret.ErrSecretReadOnly {
+		t.Errorf("Expected: %v, but got: %v", secret.ErrSecretReadOnly, err)
+	}
+
+	if err := store.Delete("1", "s1"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if grant, _ := grants.Lookup("s1", "2"); grant != nil {
+		t.Errorf("Expected the grants of the deleted secret to be revoked, but got: %+v", grant)
+	}
+}
@@ -0,0 +1,134 @@
+package secret
+
+import (
+	"sort"
+	"sync"
+)
+
+// In-memory implementation
+
+type inMemorySecret struct {
+	versions      []storedVersion
+	status        string
+	statusMessage string
+}
+
+func (secret *inMemorySecret) item(secretID string) SecretsItemResponse {
+	current := secret.versions[len(secret.versions)-1]
+	return SecretsItemResponse{
+		ID:            secretID,
+		Name:          current.Value.Name,
+		SecretType:    current.Value.SecretType,
+		Version:       current.Version,
+		Status:        secret.status,
+		StatusMessage: secret.statusMessage,
+	}
+}
+
+type inMemorySecretStore struct {
+	sync.RWMutex
+	secrets map[string]map[string]*inMemorySecret
+}
+
+// NewInMemorySecretStore is a basic in-memory SecretStore implementation (thread-safe) for development,
+// the secrets are lost on restart
+func NewInMemorySecretStore() SecretStore {
+	return &inMemorySecretStore{secrets: make(map[string]map[string]*inMemorySecret)}
+}
+
+func copyValues(values map[string]string) map[string]string {
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (ss *inMemorySecretStore) Store(organizationID, secretID string, value CreateSecretRequest) error {
+	ss.Lock()
+	defer ss.Unlock()
+	if ss.secrets[organizationID] == nil {
+		ss.secrets[organizationID] = make(map[string]*inMemorySecret)
+	}
+	secret := ss.secrets[organizationID][secretID]
+	if secret == nil {
+		secret = &inMemorySecret{}
+		ss.secrets[organizationID][secretID] = secret
+	}
+	value.Values = copyValues(value.Values)
+	secret.versions = addVersion(secret.versions, value)
+	secret.status, secret.statusMessage = initialStatus(value.SecretType), ""
+	return nil
+}
+
+func (ss *inMemorySecretStore) Get(organizationID, secretID string) (*SecretsItemResponse, error) {
+	ss.RLock()
+	defer ss.RUnlock()
+	secret, ok := ss.secrets[organizationID][secretID]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	item := secret.item(secretID)
+	item.Values = copyValues(secret.versions[len(secret.versions)-1].Value.Values)
+	return &item, nil
+}
+
+func (ss *inMemorySecretStore) List(organizationID, secretType string) ([]SecretsItemResponse, error) {
+	ss.RLock()
+	defer ss.RUnlock()
+	responseItems := make([]SecretsItemResponse, 0)
+	for secretID, secret := range ss.secrets[organizationID] {
+		item := secret.item(secretID)
+		if len(secretType) == 0 || item.SecretType == secretType {
+			responseItems = append(responseItems, item)
+		}
+	}
+	// Vault lists the keys in lexicographical order too
+	sort.Slice(responseItems, func(i, j int) bool { return responseItems[i].ID < responseItems[j].ID })
+	return responseItems, nil
+}
+
+func (ss *inMemorySecretStore) Delete(organizationID, secretID string) error {
+	ss.Lock()
+	defer ss.Unlock()
+	delete(ss.secrets[organizationID], secretID)
+	return nil
+}
+
+func (ss *inMemorySecretStore) Versions(organizationID, secretID string) ([]SecretVersion, error) {
+	ss.RLock()
+	defer ss.RUnlock()
+	secret, ok := ss.secrets[organizationID][secretID]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	return listVersions(secret.versions), nil
+}
+
+func (ss *inMemorySecretStore) Rollback(organizationID, secretID string, version int) error {
+	ss.RLock()
+	secret, ok := ss.secrets[organizationID][secretID]
+	var value *CreateSecretRequest
+	err := ErrSecretNotFound
+	if ok {
+		value, err = findVersion(secret.versions, version)
+	}
+	ss.RUnlock()
+	if err != nil {
+		return err
+	}
+	return ss.Store(organizationID, secretID, *value)
+}
+
+func (ss *inMemorySecretStore) SetStatus(organizationID, secretID string, version int, status, message string) error {
+	ss.Lock()
+	defer ss.Unlock()
+	secret, ok := ss.secrets[organizationID][secretID]
+	if !ok {
+		return ErrSecretNotFound
+	}
+	if secret.versions[len(secret.versions)-1].Version == version {
+		secret.status, secret.statusMessage = status, message
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package secret_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/secret"
+)
+
+func TestInMemorySecretStoreVersions(t *testing.T) {
+
+	store := secret.NewInMemorySecretStore()
+	request := func(key string) secret.CreateSecretRequest {
+		return secret.CreateSecretRequest{
+			Name:       "aws",
+			SecretType: secret.Amazon,
+			Values:     map[string]string{"AWS_ACCESS_KEY_ID": key, "AWS_SECRET_ACCESS_KEY": "secret"},
+		}
+	}
+
+	if err := store.Store("1", "s1", request("good")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := store.Store("1", "s1", request("typo")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	versions, err := store.Versions("1", "s1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || !versions[0].Current || versions[1].Current {
+		t.Fatalf("Unexpected versions: %+v", versions)
+	}
+
+	if err := store.Rollback("1", "s1", 1); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	item, err := store.Get("1", "s1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(item.Values, request("good").Values) {
+		t.Errorf("Expected the values of version 1, but got: %v", item.Values)
+	}
+	if versions, _ := store.Versions("1", "s1"); len(versions) != 3 || versions[0].Version != 3 {
+		t.Errorf("Expected the rollback to create version 3, but got: %+v", versions)
+	}
+
+	if err := store.Rollback("1", "s1", 5); err != secret.ErrSecretVersionNotFound {
+		t.Errorf("Expected: %v, but got: %v", secret.ErrSecretVersionNotFound, err)
+	}
+	if _, err := store.Versions("1", "unknown"); err != secret.ErrSecretNotFound {
+		t.Errorf("Expected: %v, but got: %v", secret.ErrSecretNotFound, err)
+	}
+}
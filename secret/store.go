@@ -4,16 +4,22 @@ import (
 	"fmt"
 	"github.com/banzaicloud/bank-vaults/vault"
 	"github.com/banzaicloud/pipeline/config"
+	"github.com/banzaicloud/pipeline/vaultclient"
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"strings"
 )
 
 var logger *logrus.Logger
 
-// Store object that wraps up vault logical store
-var Store *secretStore
+// Store is the SecretStore of the organizations' secrets
+var Store SecretStore
+
+// Grants is the GrantStore of the secrets shared between organizations
+var Grants GrantStore
 
 // Validated secret types
 const (
@@ -22,16 +28,62 @@ const (
 	Google     = "GOOGLE_SECRET"
 	General    = "GENERAL_SECRET"
 	Kubernetes = "KUBERNETES_SECRET"
+	SSH        = "SSH_SECRET"
+	Password   = "PASSWORD_SECRET"
 )
 
+// K8SConfig is the key of the kubeconfig in the Kubernetes secrets
+const K8SConfig = "K8Sconfig"
+
 func init() {
 	logger = config.Logger()
-	Store = newVaultSecretStore()
+	var err error
+	if Grants, err = NewGrantStore(viper.GetString("secrets.store")); err != nil {
+		panic(err)
+	}
+	if Store, err = NewSecretStore(viper.GetString("secrets.store"), Grants); err != nil {
+		panic(err)
+	}
+	if Encryption, err = NewEncrypter(viper.GetString("secrets.encryption")); err != nil {
+		panic(err)
+	}
+}
+
+// ErrSecretNotFound is returned by SecretStore.Get for unknown secrets
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore is general interface for storing the typed secrets (cloud credentials, SSH keys) of the organizations
+type SecretStore interface {
+	Store(organizationID, secretID string, value CreateSecretRequest) error
+	Get(organizationID, secretID string) (*SecretsItemResponse, error)
+	// List returns the secrets of the organization without their values, filtered by type if it's not empty
+	List(organizationID, secretType string) ([]SecretsItemResponse, error)
+	Delete(organizationID, secretID string) error
+	// Versions returns the kept versions of a secret, the latest first
+	Versions(organizationID, secretID string) ([]SecretVersion, error)
+	// Rollback stores the value of an earlier version as the new version of the secret
+	Rollback(organizationID, secretID string, version int) error
+	// SetStatus saves the verification status of a version of the secret,
+	// it's ignored if the secret has been changed since
+	SetStatus(organizationID, secretID string, version int, status, message string) error
 }
 
-type secretStore struct {
-	client  *vault.Client
-	logical *vaultapi.Logical
+// NewSecretStore creates the SecretStore of the secrets.store driver ("vault" or "memory"),
+// enforcing the grants of the shared secrets
+func NewSecretStore(driver string, grants GrantStore) (SecretStore, error) {
+	switch driver {
+	case "vault":
+		return NewSharingSecretStore(newVaultSecretStore(viper.GetString("secrets.vault.mountpath")), grants), nil
+	case "memory":
+		return NewSharingSecretStore(NewInMemorySecretStore(), grants), nil
+	}
+	return nil, fmt.Errorf("unknown secret store driver %q", driver)
+}
+
+type vaultSecretStore struct {
+	client    *vault.Client
+	logical   *vaultapi.Logical
+	mountPath string
 }
 
 // CreateSecretResponse API response for AddSecrets
@@ -59,6 +111,12 @@ type SecretsItemResponse struct {
 	Name       string            `json:"name"`
 	SecretType string            `json:"type"`
 	Values     map[string]string `json:"-"`
+	// SharedBy is the organization which shared the secret, empty for the own secrets of the organization
+	SharedBy string `json:"sharedBy,omitempty"`
+	Version  int    `json:"version,omitempty"`
+	// Status is the result of the verification of cloud credentials against their provider
+	Status        string `json:"status,omitempty"`
+	StatusMessage string `json:"statusMessage,omitempty"`
 }
 
 // AllowedFilteredSecretTypesResponse for API response for AllowedSecretTypes/:type
@@ -71,14 +129,19 @@ type AllowedSecretTypesResponse struct {
 	Allowed map[string][]string `json:"allowed"`
 }
 
-func newVaultSecretStore() *secretStore {
+func newVaultSecretStore(mountPath string) *vaultSecretStore {
 	role := "pipeline"
-	client, err := vault.NewClient(role)
+	client, err := vaultclient.NewClient(role)
 	if err != nil {
 		panic(err)
 	}
 	logical := client.Vault().Logical()
-	return &secretStore{client: client, logical: logical}
+	return &vaultSecretStore{client: client, logical: logical, mountPath: strings.Trim(mountPath, "/")}
+}
+
+// orgPath returns the Vault path of the secrets of an organization, eg.: secret/orgs/1
+func (ss *vaultSecretStore) orgPath(organizationID string) string {
+	return fmt.Sprintf("%s/orgs/%s", ss.mountPath, organizationID)
 }
 
 // GenerateSecretID uuid for new secrets
@@ -112,6 +175,18 @@ var DefaultRules = map[string][]string{
 		"auth_provider_x509_cert_url",
 		"client_x509_cert_url",
 	},
+	Kubernetes: {
+		K8SConfig,
+	},
+	SSH: {
+		"user",
+		"public_key_data",
+		"private_key_data",
+	},
+	Password: {
+		"username",
+		"password",
+	},
 }
 
 // Validate SecretRequest
@@ -130,32 +205,109 @@ func (c *CreateSecretRequest) Validate() error {
 }
 
 // Delete secret secret/orgs/:orgid:/:id: scope
-func (ss *secretStore) Delete(organizationID, secretID string) error {
+func (ss *vaultSecretStore) Delete(organizationID, secretID string) error {
 	log := logger.WithFields(logrus.Fields{"tag": "DeleteSecret"})
-	log.Debugf("Delete sectret: %s", fmt.Sprintf("secret/orgs/%s/%s", organizationID, secretID))
-	_, err := ss.logical.Delete(fmt.Sprintf("secret/orgs/%s/%s", organizationID, secretID))
+	secretPath := ss.orgPath(organizationID) + "/" + secretID
+	log.Debugf("Delete sectret: %s", secretPath)
+	_, err := ss.logical.Delete(secretPath)
 	return err
 }
 
 // Save secret secret/orgs/:orgid:/:id: scope
-func (ss *secretStore) Store(organizationID, secretID string, value CreateSecretRequest) error {
+func (ss *vaultSecretStore) Store(organizationID, secretID string, value CreateSecretRequest) error {
 	log := logger.WithFields(logrus.Fields{"tag": "StoreSecret"})
 	log.Infof("Storing secret")
-	path := fmt.Sprintf("secret/orgs/%s/%s", organizationID, secretID)
-	data := map[string]interface{}{"value": value}
+	path := ss.orgPath(organizationID) + "/" + secretID
+	history, err := ss.history(path)
+	if err != nil {
+		return errors.Wrap(err, "Error during reading secret versions")
+	}
+	data := map[string]interface{}{"value": value, "versions": addVersion(history, value), "status": initialStatus(value.SecretType)}
 	if _, err := ss.logical.Write(path, data); err != nil {
 		return errors.Wrap(err, "Error during storing secret")
 	}
 	return nil
 }
 
+// history reads the version history of the secret, nil if the secret doesn't exist
+func (ss *vaultSecretStore) history(path string) ([]storedVersion, error) {
+	secret, err := ss.logical.Read(path)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	return decodeVersions(secret.Data)
+}
+
+// Versions lists the versions of secret/orgs/:orgid:/:id:
+func (ss *vaultSecretStore) Versions(organizationID, secretID string) ([]SecretVersion, error) {
+	history, err := ss.history(ss.orgPath(organizationID) + "/" + secretID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return nil, ErrSecretNotFound
+	}
+	return listVersions(history), nil
+}
+
+// Rollback restores a version of secret/orgs/:orgid:/:id:
+func (ss *vaultSecretStore) Rollback(organizationID, secretID string, version int) error {
+	history, err := ss.history(ss.orgPath(organizationID) + "/" + secretID)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		return ErrSecretNotFound
+	}
+	value, err := findVersion(history, version)
+	if err != nil {
+		return err
+	}
+	return ss.Store(organizationID, secretID, *value)
+}
+
+// SetStatus saves the verification status of secret/orgs/:orgid:/:id:
+func (ss *vaultSecretStore) SetStatus(organizationID, secretID string, version int, status, message string) error {
+	path := ss.orgPath(organizationID) + "/" + secretID
+	secret, err := ss.logical.Read(path)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return ErrSecretNotFound
+	}
+	history, err := decodeVersions(secret.Data)
+	if err != nil {
+		return err
+	}
+	if history[len(history)-1].Version != version {
+		return nil
+	}
+	secret.Data["status"] = status
+	secret.Data["statusMessage"] = message
+	_, err = ss.logical.Write(path, secret.Data)
+	return errors.Wrap(err, "Error during storing secret status")
+}
+
+// itemMetadata sets the version and the status of the secret read from Vault
+func itemMetadata(item *SecretsItemResponse, data map[string]interface{}) {
+	if history, err := decodeVersions(data); err == nil && len(history) > 0 {
+		item.Version = history[len(history)-1].Version
+	}
+	item.Status, _ = data["status"].(string)
+	item.StatusMessage, _ = data["statusMessage"].(string)
+}
+
 // Retrieve secret secret/orgs/:orgid:/:id: scope
-func (ss *secretStore) Get(organizationID string, secretID string) (*SecretsItemResponse, error) {
-	secretPath := fmt.Sprintf("secret/orgs/%s/%s", organizationID, secretID)
+func (ss *vaultSecretStore) Get(organizationID string, secretID string) (*SecretsItemResponse, error) {
+	secretPath := ss.orgPath(organizationID) + "/" + secretID
 	secret, err := ss.logical.Read(secretPath)
 	if err != nil {
 		return nil, err
 	}
+	if secret == nil {
+		return nil, ErrSecretNotFound
+	}
 	secretData := secret.Data["value"].(map[string]interface{})
 	secretResp := &SecretsItemResponse{
 		ID:         secretID,
@@ -168,18 +320,19 @@ func (ss *secretStore) Get(organizationID string, secretID string) (*SecretsItem
 		parsedValues[k] = v.(string)
 	}
 	secretResp.Values = parsedValues
+	itemMetadata(secretResp, secret.Data)
 
 	return secretResp, nil
 }
 
 // List secret secret/orgs/:orgid:/ scope
-func (ss *secretStore) List(organizationID, secretType string) ([]SecretsItemResponse, error) {
+func (ss *vaultSecretStore) List(organizationID, secretType string) ([]SecretsItemResponse, error) {
 	log := logger.WithFields(logrus.Fields{"tag": "ListSecret"})
 	log.Info("Listing secrets")
 	responseItems := make([]SecretsItemResponse, 0)
 
 	log.Debugf("Searching for organizations secrets [%s]", organizationID)
-	orgSecretPath := fmt.Sprintf("secret/orgs/%s", organizationID)
+	orgSecretPath := ss.orgPath(organizationID)
 
 	if secret, err := ss.logical.List(orgSecretPath); err != nil {
 		log.Errorf("Error listing secrets: %s", err.Error())
@@ -198,6 +351,7 @@ func (ss *secretStore) List(organizationID, secretType string) ([]SecretsItemRes
 						Name:       secretData["name"].(string),
 						SecretType: sType,
 					}
+					itemMetadata(&sir, secret.Data)
 					responseItems = append(responseItems, sir)
 				}
 			}
@@ -0,0 +1,137 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/google"
+)
+
+// Verification statuses of the cloud credentials
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusInvalid  = "invalid"
+)
+
+// verificationTimeout limits the requests to the cloud providers
+const verificationTimeout = 30 * time.Second
+
+// Verifiers check the credentials of the secret types against their provider,
+// secrets of other types aren't verified
+var Verifiers = map[string]func(values map[string]string) error{
+	Amazon: verifyAmazon,
+	Azure:  verifyAzure,
+	Google: verifyGoogle,
+}
+
+func initialStatus(secretType string) string {
+	if _, ok := Verifiers[secretType]; ok {
+		return StatusPending
+	}
+	return ""
+}
+
+// verifyAmazon calls STS GetCallerIdentity, which needs no permissions
+func verifyAmazon(values map[string]string) error {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials(values["AWS_ACCESS_KEY_ID"], values["AWS_SECRET_ACCESS_KEY"], ""),
+		HTTPClient:  &http.Client{Timeout: verificationTimeout},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	return err
+}
+
+// verifyAzure fetches a Resource Manager token of the service principal
+func verifyAzure(values map[string]string) error {
+	oauthConfig, err := adal.NewOAuthConfig(azure.PublicCloud.ActiveDirectoryEndpoint, values["AZURE_TENANT_ID"])
+	if err != nil {
+		return err
+	}
+	token, err := adal.NewServicePrincipalToken(*oauthConfig, values["AZURE_CLIENT_ID"], values["AZURE_CLIENT_SECRET"],
+		azure.PublicCloud.ResourceManagerEndpoint)
+	if err != nil {
+		return err
+	}
+	token.SetSender(&http.Client{Timeout: verificationTimeout})
+	return token.Refresh()
+}
+
+// verifyGoogle fetches a token of the service account and checks it with the tokeninfo endpoint
+func verifyGoogle(values map[string]string) error {
+	serviceAccount, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	config, err := google.JWTConfigFromJSON(serviceAccount, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), verificationTimeout)
+	defer cancel()
+	token, err := config.TokenSource(ctx).Token()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: verificationTimeout}
+	resp, err := client.Get("https://www.googleapis.com/oauth2/v3/tokeninfo?access_token=" + url.QueryEscape(token.AccessToken))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tokeninfo returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Verify checks the current version of a cloud credential secret against its provider and saves the result,
+// it's run in the background after the secret is stored
+func Verify(organizationID, secretID string) error {
+	log := logger.WithFields(logrus.Fields{"tag": "VerifySecret"})
+	item, err := Store.Get(organizationID, secretID)
+	if err != nil {
+		return err
+	}
+	if item.SharedBy != "" {
+		// Shared secrets are verified by the owner organization
+		return Verify(item.SharedBy, secretID)
+	}
+	verify, ok := Verifiers[item.SecretType]
+	if !ok {
+		return nil
+	}
+	status, message := StatusVerified, ""
+	if err := verify(item.Values); err != nil {
+		status, message = StatusInvalid, err.Error()
+		log.Infof("Secret %s/%s is invalid: %s", organizationID, secretID, message)
+	} else {
+		log.Infof("Secret %s/%s is verified", organizationID, secretID)
+	}
+	return errors.Wrap(Store.SetStatus(organizationID, secretID, item.Version, status, message), "Error during storing secret status")
+}
+
+// VerifyAsync verifies the secret in the background
+func VerifyAsync(organizationID, secretID string) {
+	go func() {
+		if err := Verify(organizationID, secretID); err != nil {
+			logger.WithFields(logrus.Fields{"tag": "VerifySecret"}).Errorf("Error during verifying secret %s/%s: %s", organizationID, secretID, err.Error())
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package secret_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/secret"
+)
+
+func TestVerify(t *testing.T) {
+
+	store, verifier := secret.Store, secret.Verifiers[secret.Amazon]
+	defer func() { secret.Store, secret.Verifiers[secret.Amazon] = store, verifier }()
+	secret.Store = secret.NewInMemorySecretStore()
+	secret.Verifiers[secret.Amazon] = func(values map[string]string) error {
+		if values["AWS_ACCESS_KEY_ID"] != "valid" {
+			return errors.New("InvalidClientTokenId")
+		}
+		return nil
+	}
+
+	cases := []struct {
+		name          string
+		secretType    string
+		accessKey     string
+		initialStatus string
+		status        string
+	}{
+		{name: "valid credentials", secretType: secret.Amazon, accessKey: "valid", initialStatus: secret.StatusPending, status: secret.StatusVerified},
+		{name: "invalid credentials", secretType: secret.Amazon, accessKey: "typo", initialStatus: secret.StatusPending, status: secret.StatusInvalid},
+		{name: "not verified type", secretType: secret.SSH, initialStatus: "", status: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := secret.CreateSecretRequest{
+				Name:       tc.name,
+				SecretType: tc.secretType,
+				Values:     map[string]string{"AWS_ACCESS_KEY_ID": tc.accessKey},
+			}
+			if err := secret.Store.Store("1", tc.name, request); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if item, _ := secret.Store.Get("1", tc.name); item.Status != tc.initialStatus {
+				t.Errorf("Expected initial status: %q, but got: %q", tc.initialStatus, item.Status)
+			}
+			if err := secret.Verify("1", tc.name); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if item, _ := secret.Store.Get("1", tc.name); item.Status != tc.status {
+				t.Errorf("Expected status: %q, but got: %q", tc.status, item.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package secret
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ErrSecretVersionNotFound is returned by SecretStore.Rollback for unknown versions
+var ErrSecretVersionNotFound = errors.New("secret version not found")
+
+// SecretVersion describes a stored version of a secret, the values are never listed
+type SecretVersion struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	Current   bool      `json:"current,omitempty"`
+}
+
+// storedVersion is a version of a secret kept for rollbacks, the last one is the current value
+type storedVersion struct {
+	Version   int                 `json:"version"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Value     CreateSecretRequest `json:"value"`
+}
+
+// addVersion appends the value as a new version to the history of a secret,
+// only the last secrets.maxversions versions are kept
+func addVersion(history []storedVersion, value CreateSecretRequest) []storedVersion {
+	version := 1
+	if len(history) > 0 {
+		version = history[len(history)-1].Version + 1
+	}
+	history = append(history, storedVersion{Version: version, CreatedAt: time.Now().UTC(), Value: value})
+	if max := viper.GetInt("secrets.maxversions"); max > 0 && len(history) > max {
+		history = history[len(history)-max:]
+	}
+	return history
+}
+
+// findVersion returns the value of a version from the history of a secret
+func findVersion(history []storedVersion, version int) (*CreateSecretRequest, error) {
+	for i := range history {
+		if history[i].Version == version {
+			return &history[i].Value, nil
+		}
+	}
+	return nil, ErrSecretVersionNotFound
+}
+
+// listVersions returns the versions of a secret, the latest first
+func listVersions(history []storedVersion) []SecretVersion {
+	versions := make([]SecretVersion, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		versions = append(versions, SecretVersion{
+			Version:   history[i].Version,
+			CreatedAt: history[i].CreatedAt,
+			Current:   i == len(history)-1,
+		})
+	}
+	return versions
+}
+
+// decodeVersions converts the version history read from Vault, secrets stored before
+// versioning have their current value as the first version
+func decodeVersions(data map[string]interface{}) ([]storedVersion, error) {
+	var history []storedVersion
+	raw, ok := data["versions"]
+	if !ok {
+		raw = []interface{}{map[string]interface{}{"version": 1, "value": data["value"]}}
+	}
+	encoded, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(encoded, &history)
+	}
+	return history, errors.Wrap(err, "invalid secret versions")
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// internalNetworks are the networks which aren't routed on the internet: the current network, the private,
+// shared (carrier-grade NAT), loopback and link-local networks of IPv4 and IPv6
+var internalNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+//IsPublicIP checks whether the address is routed on the internet, the addresses of the internal networks,
+//the unspecified and the multicast addresses aren't
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+//IsInternalHost checks whether the host of a URL is localhost or an address which isn't public, the names are
+//checked when they're resolved
+func IsInternalHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return !IsPublicIP(ip)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+//PublicDialer returns a dialer refusing to connect to the addresses which aren't public, the address is checked
+//after the name resolution so a host can't be rebound to an internal address after it was validated
+func PublicDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("connecting to the internal address %s isn't allowed", host)
+			}
+			return nil
+		},
+	}
+}
+
+//ResolvePublicHost resolves the host and checks that all its addresses are public, it returns the first one
+func ResolvePublicHost(host string) (net.IP, error) {
+	addresses, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range addresses {
+		if !IsPublicIP(ip) {
+			return nil, fmt.Errorf("host %s resolves to the internal address %s", host, ip)
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("host %s has no address", host)
+	}
+	return addresses[0], nil
+}
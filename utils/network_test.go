@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/utils"
+)
+
+func TestIsPublicIP(t *testing.T) {
+
+	cases := []struct {
+		ip       string
+		expected bool
+	}{
+		{ip: "93.184.216.34", expected: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", expected: true},
+		{ip: "127.0.0.1"},
+		{ip: "10.0.0.1"},
+		{ip: "172.20.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "100.64.0.1"},
+		{ip: "169.254.169.254"},
+		{ip: "0.0.0.0"},
+		{ip: "::1"},
+		{ip: "::"},
+		{ip: "fd00::1"},
+		{ip: "fe80::1"},
+		{ip: "::ffff:127.0.0.1"},
+		{ip: "224.0.0.1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ip, func(t *testing.T) {
+			if public := utils.IsPublicIP(net.ParseIP(tc.ip)); public != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, public)
+			}
+		})
+	}
+}
+
+func TestIsInternalHost(t *testing.T) {
+
+	for host, expected := range map[string]bool{"localhost": true, "api.localhost": true, "127.0.0.1": true, "::1": true, "example.com": false, "93.184.216.34": false} {
+		if internal := utils.IsInternalHost(host); internal != expected {
+			t.Errorf("Expected %v, got: %v (%s)", expected, internal, host)
+		}
+	}
+}
+
+func TestPublicDialer(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: utils.PublicDialer(time.Second).DialContext}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("Expected error, but not got error!")
+	}
+}
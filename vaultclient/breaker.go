@@ -0,0 +1,108 @@
+package vaultclient
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned without contacting Vault while the circuit breaker is open
+var ErrUnavailable = errors.New("Vault unavailable")
+
+// States of the circuit breaker
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// CircuitBreaker fails the requests fast after threshold consecutive failures,
+// once the timeout passes a single request is let through to probe Vault
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	timeout   time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError error
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker, a threshold of 0 disables it
+func NewCircuitBreaker(threshold int, timeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, timeout: timeout, now: time.Now}
+}
+
+// Allow returns ErrUnavailable if the request must not be sent to Vault
+func (breaker *CircuitBreaker) Allow() error {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	switch breaker.state() {
+	case StateOpen:
+		return ErrUnavailable
+	case StateHalfOpen:
+		if breaker.probing {
+			return ErrUnavailable
+		}
+		breaker.probing = true
+	}
+	return nil
+}
+
+// Success closes the breaker
+func (breaker *CircuitBreaker) Success() {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.failures = 0
+	breaker.probing = false
+	breaker.lastError = nil
+}
+
+// Failure records a failed request, the breaker opens at the threshold or when a probe fails
+func (breaker *CircuitBreaker) Failure(err error) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.failures++
+	breaker.lastError = err
+	if breaker.probing || (breaker.threshold > 0 && breaker.failures == breaker.threshold) {
+		breaker.openedAt = breaker.now()
+	}
+	breaker.probing = false
+}
+
+// State returns the state of the breaker and the error of the last failed request
+func (breaker *CircuitBreaker) State() (string, error) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return breaker.state(), breaker.lastError
+}
+
+func (breaker *CircuitBreaker) state() string {
+	if breaker.threshold <= 0 || breaker.failures < breaker.threshold {
+		return StateClosed
+	}
+	if breaker.now().Sub(breaker.openedAt) < breaker.timeout {
+		return StateOpen
+	}
+	return StateHalfOpen
+}
+
+// IsUnavailable checks whether a Vault operation failed because the circuit breaker is open
+func IsUnavailable(err error) bool {
+	for err != nil {
+		if err == ErrUnavailable {
+			return true
+		}
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case interface{ Cause() error }:
+			err = e.Cause()
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package vaultclient
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var breakerOnce sync.Once
+var breaker *CircuitBreaker
+
+// Breaker returns the circuit breaker shared by the Vault clients of Pipeline, configured by vault.breaker
+func Breaker() *CircuitBreaker {
+	breakerOnce.Do(func() {
+		breaker = NewCircuitBreaker(viper.GetInt("vault.breaker.threshold"), viper.GetDuration("vault.breaker.timeout"))
+	})
+	return breaker
+}
+
+// RetryOptions configures the retries of the failed Vault requests,
+// the backoff grows exponentially from InitialBackoff up to MaxBackoff with full jitter
+type RetryOptions struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// RetryOptionsFromConfig returns the retry options of vault.retry in the configuration
+func RetryOptionsFromConfig() RetryOptions {
+	return RetryOptions{
+		MaxRetries:     viper.GetInt("vault.retry.maxretries"),
+		InitialBackoff: viper.GetDuration("vault.retry.initialbackoff"),
+		MaxBackoff:     viper.GetDuration("vault.retry.maxbackoff"),
+	}
+}
+
+func (options RetryOptions) backoff(attempt int) time.Duration {
+	backoff := options.InitialBackoff << uint(attempt)
+	if backoff <= 0 || backoff > options.MaxBackoff {
+		backoff = options.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// retryTransport retries the requests failed with a network error or because Vault is sealed
+// or unreachable behind a proxy (502, 503, 504), the failures are recorded by the circuit breaker
+type retryTransport struct {
+	transport http.RoundTripper
+	breaker   *CircuitBreaker
+	options   RetryOptions
+}
+
+// NewRetryTransport wraps the transport of a Vault client with retries and a circuit breaker
+func NewRetryTransport(transport http.RoundTripper, breaker *CircuitBreaker, options RetryOptions) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return retryTransport{transport: transport, breaker: breaker, options: options}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.breaker.Allow(); err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := t.transport.RoundTrip(req)
+		if !retryable(resp, err) {
+			t.breaker.Success()
+			return resp, err
+		}
+		if err == nil {
+			t.breaker.Failure(fmt.Errorf("Vault responded %s", resp.Status))
+		} else {
+			t.breaker.Failure(err)
+		}
+		if attempt >= t.options.MaxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.options.backoff(attempt)):
+		}
+	}
+}
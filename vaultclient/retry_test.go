@@ -0,0 +1,115 @@
+package vaultclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/pipeline/vaultclient"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func newRetryClient(t *testing.T, url string, breaker *vaultclient.CircuitBreaker, maxRetries int) *vaultapi.Client {
+	transport := vaultclient.NewRetryTransport(nil, breaker, vaultclient.RetryOptions{
+		MaxRetries:     maxRetries,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	})
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: url, HttpClient: &http.Client{Transport: transport}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("token")
+	return client
+}
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		name          string
+		failures      int32
+		status        int
+		maxRetries    int
+		expectedCalls int32
+		expectError   bool
+	}{
+		{name: "success", failures: 0, status: http.StatusServiceUnavailable, maxRetries: 3, expectedCalls: 1},
+		{name: "sealed then unsealed", failures: 2, status: http.StatusServiceUnavailable, maxRetries: 3, expectedCalls: 3},
+		{name: "retries exhausted", failures: 10, status: http.StatusBadGateway, maxRetries: 2, expectedCalls: 3, expectError: true},
+		{name: "client errors are not retried", failures: 10, status: http.StatusForbidden, maxRetries: 3, expectedCalls: 1, expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data": {"value": "secret"}}`))
+			}))
+			defer server.Close()
+
+			client := newRetryClient(t, server.URL, vaultclient.NewCircuitBreaker(0, 0), tc.maxRetries)
+			_, err := client.Logical().Write("secret/value", map[string]interface{}{"value": "secret"})
+
+			if (err != nil) != tc.expectError {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if calls != tc.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tc.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"value": "secret"}}`))
+	}))
+	defer server.Close()
+
+	breaker := vaultclient.NewCircuitBreaker(3, 50*time.Millisecond)
+	client := newRetryClient(t, server.URL, breaker, 5)
+
+	if _, err := client.Logical().Read("secret/value"); !vaultclient.IsUnavailable(err) {
+		t.Fatalf("expected Vault unavailable error, got: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected the breaker to open after 3 calls, got %d", calls)
+	}
+	if state, lastError := breaker.State(); state != vaultclient.StateOpen || !strings.Contains(lastError.Error(), "503") {
+		t.Errorf("expected open breaker with the last error, got %s: %v", state, lastError)
+	}
+
+	if _, err := client.Logical().Read("secret/value"); !vaultclient.IsUnavailable(err) {
+		t.Errorf("expected fail fast, got: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected no calls while the breaker is open, got %d", calls)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	if state, _ := breaker.State(); state != vaultclient.StateHalfOpen {
+		t.Errorf("expected half-open breaker, got %s", state)
+	}
+	if _, err := client.Logical().Read("secret/value"); err != nil {
+		t.Errorf("unexpected error after Vault recovered: %v", err)
+	}
+	if state, _ := breaker.State(); state != vaultclient.StateClosed {
+		t.Errorf("expected closed breaker, got %s", state)
+	}
+}
@@ -0,0 +1,70 @@
+package vaultclient
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/banzaicloud/bank-vaults/vault"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/spf13/viper"
+)
+
+// NamespaceHeader is the header selecting the namespace of Vault Enterprise
+const NamespaceHeader = "X-Vault-Namespace"
+
+// Namespace returns the Vault Enterprise namespace of Pipeline, the VAULT_NAMESPACE env var
+// takes precedence over vault.namespace of the configuration
+func Namespace() string {
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return viper.GetString("vault.namespace")
+}
+
+// NewClient creates a Vault client logged in with the role like vault.NewClient does,
+// every request of the client (the login included) is sent to the namespace of Pipeline
+func NewClient(role string) (*vault.Client, error) {
+	return vault.NewClientWithConfig(NewConfig(), role)
+}
+
+// NewConfig returns the default Vault client configuration (VAULT_ADDR, VAULT_TOKEN, etc.)
+// with the transport of Pipeline: namespaced requests, retries and the shared circuit breaker
+func NewConfig() *vaultapi.Config {
+	config := vaultapi.DefaultConfig()
+	transport := NewTransport(config.HttpClient.Transport, Namespace())
+	config.HttpClient.Transport = NewRetryTransport(transport, Breaker(), RetryOptionsFromConfig())
+	return config
+}
+
+// namespaceTransport sets the namespace header of the requests
+type namespaceTransport struct {
+	namespace string
+	transport http.RoundTripper
+}
+
+// NewTransport wraps the transport of a Vault client, the requests are sent to the given namespace
+// (if it isn't empty)
+func NewTransport(transport http.RoundTripper, namespace string) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if namespace == "" {
+		return transport
+	}
+	return namespaceTransport{namespace: namespace, transport: transport}
+}
+
+func (t namespaceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.WithContext(req.Context())
+	req.Header = cloneHeader(req.Header)
+	req.Header.Set(NamespaceHeader, t.namespace)
+	return t.transport.RoundTrip(req)
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header)+1)
+	for key, values := range header {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
+}
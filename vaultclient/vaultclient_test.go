@@ -0,0 +1,50 @@
+package vaultclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banzaicloud/pipeline/vaultclient"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestNewTransport(t *testing.T) {
+	cases := []struct {
+		name      string
+		namespace string
+	}{
+		{name: "no namespace", namespace: ""},
+		{name: "namespace", namespace: "team-a/pipeline"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var header string
+			var present bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, present = r.Header[vaultclient.NamespaceHeader]
+				header = r.Header.Get(vaultclient.NamespaceHeader)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data": {"value": "secret"}}`))
+			}))
+			defer server.Close()
+
+			client, err := vaultapi.NewClient(&vaultapi.Config{
+				Address:    server.URL,
+				HttpClient: &http.Client{Transport: vaultclient.NewTransport(nil, tc.namespace)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.SetToken("token")
+			if _, err := client.Logical().Read("secret/value"); err != nil {
+				t.Fatal(err)
+			}
+
+			if present != (tc.namespace != "") || header != tc.namespace {
+				t.Errorf("expected namespace header %q, got %q", tc.namespace, header)
+			}
+		})
+	}
+}